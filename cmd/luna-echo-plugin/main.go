@@ -0,0 +1,30 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/echo_plugin"
+)
+
+func main() {
+	address := flag.String("address", "127.0.0.1:9090", "The address on which to serve the echo model plugin.")
+	flag.Parse()
+
+	lis, err := net.Listen("tcp", *address)
+
+	if err != nil {
+		log.Fatalf("failed to listen on %s: %s", *address, err.Error())
+	}
+
+	log.Printf("echo model plugin is serving on %s", *address)
+
+	if err := echo_plugin.Serve(lis); err != nil {
+		log.Fatal(err)
+	}
+}
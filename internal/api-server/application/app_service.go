@@ -12,6 +12,8 @@ import (
 	"github.com/lunarianss/Luna/infrastructure/log"
 	assembler "github.com/lunarianss/Luna/internal/api-server/assembler/app"
 	"github.com/lunarianss/Luna/internal/api-server/config"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_llm_cache_config"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_structured_output_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
@@ -253,6 +255,22 @@ func (as *AppService) UpdateAppModelConfig(ctx context.Context, modelConfig *cha
 		return errors.WithCode(code.ErrForbidden, "You don't have the permission for %s", tenant.Name)
 	}
 
+	completionParams, err := as.providerDomain.ValidateModelParameters(ctx, tenant.ID, modelConfig.Model.Provider, modelConfig.Model.Name, modelConfig.Model.CompletionParams, true)
+
+	if err != nil {
+		return err
+	}
+
+	modelConfig.Model.CompletionParams = completionParams
+
+	if _, _, err := app_structured_output_config.NewStructuredOutputConfigManager().ValidateAndSetDefaults(modelConfig); err != nil {
+		return err
+	}
+
+	if _, _, err := app_llm_cache_config.NewLLMCacheConfigManager().ValidateAndSetDefaults(modelConfig); err != nil {
+		return err
+	}
+
 	configEntity := assembler.ConvertToConfigEntity(modelConfig)
 	configRecord := configEntity.ConvertToAppConfigPoEntity()
 	configRecord.AppID = appID
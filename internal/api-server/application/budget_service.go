@@ -0,0 +1,172 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
+	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/budget"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const DEFAULT_BUDGET_CURRENCY = "USD"
+
+type BudgetService struct {
+	budgetDomain  *budgetDomain.BudgetDomain
+	accountDomain *accountDomain.AccountDomain
+	appDomain     *appDomain.AppDomain
+}
+
+func NewBudgetService(budgetDomain *budgetDomain.BudgetDomain, accountDomain *accountDomain.AccountDomain, appDomain *appDomain.AppDomain) *BudgetService {
+	return &BudgetService{
+		budgetDomain:  budgetDomain,
+		accountDomain: accountDomain,
+		appDomain:     appDomain,
+	}
+}
+
+func (bs *BudgetService) ListBudgets(ctx context.Context, accountID string) (*dto.ListBudgetsResponse, error) {
+	tenantRecord, _, err := bs.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	budgets, err := bs.budgetDomain.BudgetRepo.ListTenantBudgets(ctx, tenantRecord.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	items := make([]*dto.BudgetItem, 0, len(budgets))
+
+	for _, budget := range budgets {
+		spend, err := bs.budgetDomain.GetSpend(ctx, budget, now)
+
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, &dto.BudgetItem{
+			Budget:      budget,
+			Spend:       spend,
+			PeriodStart: budget.PeriodStart(now).Unix(),
+			PeriodEnd:   budget.PeriodEnd(now).Unix(),
+		})
+	}
+
+	return &dto.ListBudgetsResponse{Data: items}, nil
+}
+
+// SaveBudget creates the budget of the workspace or the app, or updates it when it exists.
+func (bs *BudgetService) SaveBudget(ctx context.Context, accountID string, params *dto.SaveBudgetBody) (*po_entity.Budget, error) {
+	tenantRecord, tenantJoin, err := bs.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !tenantJoin.IsPrivilegedRole() {
+		return nil, errors.WithCode(code.ErrForbidden, "tenant %s don't have the permission", tenantRecord.Name)
+	}
+
+	if params.HardLimit > 0 && params.SoftLimit > params.HardLimit {
+		return nil, errors.WithCode(code.ErrValidation, "soft limit %.4f is greater than hard limit %.4f", params.SoftLimit, params.HardLimit)
+	}
+
+	if params.AppID != "" {
+		if _, err := bs.appDomain.AppRepo.GetTenantApp(ctx, params.AppID, tenantRecord.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	accountRecord, err := bs.accountDomain.AccountRepo.GetAccountByID(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	budget, err := bs.budgetDomain.BudgetRepo.GetBudgetByScope(ctx, tenantRecord.ID, params.AppID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	isNew := budget == nil
+
+	if isNew {
+		budget = &po_entity.Budget{
+			TenantID:  tenantRecord.ID,
+			AppID:     params.AppID,
+			CreatedBy: accountRecord.ID,
+			Enabled:   1,
+		}
+	}
+
+	budget.Period = params.Period
+	budget.Currency = params.Currency
+	budget.SoftLimit = params.SoftLimit
+	budget.HardLimit = params.HardLimit
+	budget.NotifyEmails = params.NotifyEmails
+
+	if budget.Currency == "" {
+		budget.Currency = DEFAULT_BUDGET_CURRENCY
+	}
+
+	if len(budget.NotifyEmails) == 0 {
+		budget.NotifyEmails = []string{accountRecord.Email}
+	}
+
+	if params.Enabled != nil {
+		if *params.Enabled {
+			budget.Enabled = 1
+		} else {
+			budget.Enabled = 0
+		}
+	}
+
+	if isNew {
+		err = bs.budgetDomain.BudgetRepo.CreateBudget(ctx, budget)
+	} else {
+		err = bs.budgetDomain.BudgetRepo.UpdateBudget(ctx, budget)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return budget, nil
+}
+
+func (bs *BudgetService) DeleteBudget(ctx context.Context, accountID string, budgetID string) error {
+	tenantRecord, tenantJoin, err := bs.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if !tenantJoin.IsPrivilegedRole() {
+		return errors.WithCode(code.ErrForbidden, "tenant %s don't have the permission", tenantRecord.Name)
+	}
+
+	budget, err := bs.budgetDomain.BudgetRepo.GetBudgetByID(ctx, tenantRecord.ID, budgetID)
+
+	if err != nil {
+		return err
+	}
+
+	if budget == nil {
+		return errors.WithCode(code.ErrResourceNotFound, "budget %s is not found", budgetID)
+	}
+
+	return bs.budgetDomain.BudgetRepo.DeleteBudget(ctx, tenantRecord.ID, budgetID)
+}
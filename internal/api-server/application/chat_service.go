@@ -19,6 +19,7 @@ import (
 	agentDomain "github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	po_chat "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
@@ -39,11 +40,12 @@ type ChatService struct {
 	chatDomain     *chatDomain.ChatDomain
 	datasetDomain  *datasetDomain.DatasetDomain
 	agentDomain    *agentDomain.AgentDomain
+	budgetDomain   *budgetDomain.BudgetDomain
 	redis          *redis.Client
 	config         *config.Config
 }
 
-func NewChatService(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, accountDomain *accountDomain.AccountDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, agentDomain *agentDomain.AgentDomain, redis *redis.Client, config *config.Config) *ChatService {
+func NewChatService(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, accountDomain *accountDomain.AccountDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, agentDomain *agentDomain.AgentDomain, budgetDomain *budgetDomain.BudgetDomain, redis *redis.Client, config *config.Config) *ChatService {
 	return &ChatService{
 		appDomain:      appDomain,
 		providerDomain: providerDomain,
@@ -52,6 +54,7 @@ func NewChatService(appDomain *appDomain.AppDomain, providerDomain *domain_servi
 		datasetDomain:  datasetDomain,
 		redis:          redis,
 		agentDomain:    agentDomain,
+		budgetDomain:   budgetDomain,
 		config:         config,
 	}
 }
@@ -151,14 +154,14 @@ func (s *ChatService) Generate(ctx context.Context, appID, accountID string, arg
 	}
 
 	if appModel.Mode == string(biz_entity.CHAT) {
-		chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.redis)
+		chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.budgetDomain, s.redis)
 
 		if err := chatAppGenerator.Generate(ctx, appModel, accountRecord, args, invokeFrom, true); err != nil {
 			return err
 		}
 	} else if appModel.Mode == string(biz_entity.AGENT_CHAT) {
 
-		chatAppGenerator := app_agent_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.redis, s.agentDomain, s.config)
+		chatAppGenerator := app_agent_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.budgetDomain, s.redis, s.agentDomain, s.config)
 
 		if err := chatAppGenerator.Generate(ctx, appModel, accountRecord, args, invokeFrom, true); err != nil {
 			return err
@@ -168,6 +171,36 @@ func (s *ChatService) Generate(ctx context.Context, appID, accountID string, arg
 	return nil
 }
 
+// ResumeAgentChat decides the tool call of the agent chat message waiting for approval and streams the resumed agent.
+func (s *ChatService) ResumeAgentChat(ctx context.Context, appID, accountID, messageID string, args *dto.AgentApprovalBody, invokeFrom biz_entity_app_generate.InvokeFrom) error {
+
+	accountRecord, err := s.accountDomain.AccountRepo.GetAccountByID(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	tenant, _, err := s.accountDomain.GetCurrentTenantOfAccount(ctx, accountRecord.ID)
+
+	if err != nil {
+		return err
+	}
+
+	appModel, err := s.appDomain.AppRepo.GetTenantApp(ctx, appID, tenant.ID)
+
+	if err != nil {
+		return err
+	}
+
+	if appModel.Mode != string(biz_entity.AGENT_CHAT) {
+		return errors.WithCode(code.ErrAgentApprovalNotPending, "app %s is not an agent chat app", appModel.ID)
+	}
+
+	agentChatGenerator := app_agent_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.budgetDomain, s.redis, s.agentDomain, s.config)
+
+	return agentChatGenerator.Resume(ctx, appModel, accountRecord, messageID, args, invokeFrom)
+}
+
 func (s *ChatService) ListConsoleMessagesOfConversation(ctx context.Context, accountID, appID string, args *dto.ListChatMessageQuery) (*dto.ListChatMessagesResponse, error) {
 
 	var (
@@ -19,6 +19,7 @@ import (
 	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
 	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
 	dto "github.com/lunarianss/Luna/internal/api-server/dto/provider"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
 )
@@ -92,6 +93,14 @@ func (mpSrv *ModelProviderService) GetProviderList(ctx context.Context, accountI
 			},
 		}
 
+		if systemConfiguration := providerConfiguration.SystemConfiguration; systemConfiguration != nil && systemConfiguration.Enabled {
+			providerResponse.SystemConfiguration = &dto.SystemConfigurationResponse{
+				Enabled:             true,
+				CurrentQuotaType:    systemConfiguration.CurrentQuotaType,
+				QuotaConfigurations: systemConfiguration.QuotaConfigurations,
+			}
+		}
+
 		providerListResponse = append(providerListResponse, providerResponse)
 
 	}
@@ -147,6 +156,41 @@ func (mpSrv *ModelProviderService) SaveProviderCredentials(ctx context.Context,
 	return nil
 }
 
+func (mpSrv *ModelProviderService) GetProviderQuota(ctx context.Context, accountID string, provider string) (*dto.ProviderQuotaResponse, error) {
+
+	tenantRecord, _, err := mpSrv.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	quota, err := mpSrv.providerDomain.GetQuota(ctx, tenantRecord.ID, provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ProviderQuotaResponse{
+		Enabled: quota != nil,
+		Quota:   quota,
+	}, nil
+}
+
+func (mpSrv *ModelProviderService) ResetProviderQuota(ctx context.Context, accountID string, provider string, params *dto.ResetProviderQuotaBody) error {
+
+	tenantRecord, tenantJoin, err := mpSrv.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if !tenantJoin.IsPrivilegedRole() {
+		return errors.WithCode(code.ErrForbidden, "tenant %s don't have the permission", tenantRecord.Name)
+	}
+
+	return mpSrv.providerDomain.ResetQuota(ctx, tenantRecord.ID, provider, po_entity.ProviderQuotaType(params.QuotaType), biz_entity_provider_config.QuotaUnit(params.QuotaUnit), params.QuotaLimit)
+}
+
 func (mpSrv *ModelProviderService) getIconName(providerEntity *biz_entity.ProviderStaticConfiguration, iconType, lang string) (string, error) {
 	var (
 		iconName string
@@ -7,6 +7,7 @@ package service
 import (
 	"context"
 
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
@@ -46,6 +47,10 @@ func (ms *ModelService) SaveModelCredentials(ctx context.Context, tenantId, mode
 		return errors.WithCode(code.ErrProviderMapModel, "provider %s not found in map provider configuration", provider)
 	}
 
+	if err := model_registry.ValidateModelCredentials(ctx, provider, modelTpe, model, credentials); err != nil {
+		return err
+	}
+
 	err = providerConfiguration.AddOrUpdateCustomModelCredentials(ctx, credentials, modelTpe, model)
 
 	if err != nil {
@@ -317,3 +322,188 @@ func (ms *ModelService) GetProviderModels(ctx context.Context, accountID string,
 	}, nil
 
 }
+
+func (ms *ModelService) GetLoadBalancingConfigs(ctx context.Context, accountID, provider, model, modelType string) (*dto.LoadBalancingConfigsResponse, error) {
+	tenantRecord, _, err := ms.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	providerConfiguration, err := ms.getProviderConfiguration(ctx, tenantRecord.ID, provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	modelSetting, err := ms.providerDomain.ModelRepo.GetTenantModelSetting(ctx, tenantRecord.ID, provider, model, modelType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	configRecords, err := ms.providerDomain.ModelRepo.GetModelLoadBalancingConfigs(ctx, tenantRecord.ID, provider, model, modelType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	loadBalancingConfigs := &dto.LoadBalancingConfigsResponse{
+		Strategy: string(po_entity.ROUND_ROBIN),
+		Configs:  make([]*dto.LoadBalancingConfigItem, 0, len(configRecords)),
+	}
+
+	if modelSetting != nil {
+		loadBalancingConfigs.Enabled = modelSetting.LoadBalancingEnabled == 1
+		loadBalancingConfigs.Strategy = modelSetting.LoadBalancingStrategy
+	}
+
+	for _, configRecord := range configRecords {
+		credentials, err := ms.providerDomain.DecryptLoadBalancingCredentials(tenantRecord.ID, providerConfiguration.Provider, configRecord)
+
+		if err != nil {
+			return nil, err
+		}
+
+		loadBalancingConfigs.Configs = append(loadBalancingConfigs.Configs, &dto.LoadBalancingConfigItem{
+			ID:          configRecord.ID,
+			Name:        configRecord.Name,
+			Credentials: ms.providerDomain.ObfuscateLoadBalancingCredentials(providerConfiguration.Provider, credentials),
+			Weight:      configRecord.Weight,
+			Enabled:     configRecord.Enabled == 1,
+		})
+	}
+
+	return loadBalancingConfigs, nil
+}
+
+func (ms *ModelService) CreateLoadBalancingConfig(ctx context.Context, accountID, provider string, params *dto.CreateLoadBalancingConfigBody) error {
+	tenantRecord, _, err := ms.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	providerConfiguration, err := ms.getProviderConfiguration(ctx, tenantRecord.ID, provider)
+
+	if err != nil {
+		return err
+	}
+
+	if err := model_registry.ValidateModelCredentials(ctx, provider, params.ModelType, params.Model, params.Credentials); err != nil {
+		return err
+	}
+
+	configRecord := &po_entity.LoadBalancingModelConfig{
+		TenantID:     tenantRecord.ID,
+		ProviderName: provider,
+		ModelName:    params.Model,
+		ModelType:    params.ModelType,
+		Name:         params.Name,
+		Weight:       params.Weight,
+		Enabled:      1,
+	}
+
+	if params.Enabled != nil && !*params.Enabled {
+		configRecord.Enabled = 0
+	}
+
+	return ms.providerDomain.SaveLoadBalancingConfig(ctx, tenantRecord.ID, providerConfiguration.Provider, configRecord, params.Credentials)
+}
+
+func (ms *ModelService) UpdateLoadBalancingConfig(ctx context.Context, accountID, provider, configID string, params *dto.UpdateLoadBalancingConfigBody) error {
+	tenantRecord, _, err := ms.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	providerConfiguration, err := ms.getProviderConfiguration(ctx, tenantRecord.ID, provider)
+
+	if err != nil {
+		return err
+	}
+
+	configRecord, err := ms.getLoadBalancingConfig(ctx, tenantRecord.ID, provider, configID)
+
+	if err != nil {
+		return err
+	}
+
+	originCredentials, err := ms.providerDomain.DecryptLoadBalancingCredentials(tenantRecord.ID, providerConfiguration.Provider, configRecord)
+
+	if err != nil {
+		return err
+	}
+
+	credentials := ms.providerDomain.MergeHiddenLoadBalancingCredentials(params.Credentials, originCredentials)
+
+	if err := model_registry.ValidateModelCredentials(ctx, provider, configRecord.ModelType, configRecord.ModelName, credentials); err != nil {
+		return err
+	}
+
+	configRecord.Name = params.Name
+	configRecord.Weight = params.Weight
+
+	if params.Enabled != nil {
+		if *params.Enabled {
+			configRecord.Enabled = 1
+		} else {
+			configRecord.Enabled = 0
+		}
+	}
+
+	return ms.providerDomain.SaveLoadBalancingConfig(ctx, tenantRecord.ID, providerConfiguration.Provider, configRecord, credentials)
+}
+
+func (ms *ModelService) DeleteLoadBalancingConfig(ctx context.Context, accountID, provider, configID string) error {
+	tenantRecord, _, err := ms.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ms.getLoadBalancingConfig(ctx, tenantRecord.ID, provider, configID); err != nil {
+		return err
+	}
+
+	return ms.providerDomain.ModelRepo.DeleteLoadBalancingConfig(ctx, tenantRecord.ID, configID)
+}
+
+func (ms *ModelService) UpdateModelLoadBalancing(ctx context.Context, accountID, provider string, params *dto.UpdateModelLoadBalancingBody) error {
+	tenantRecord, _, err := ms.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ms.getProviderConfiguration(ctx, tenantRecord.ID, provider); err != nil {
+		return err
+	}
+
+	return ms.providerDomain.UpdateModelLoadBalancing(ctx, tenantRecord.ID, provider, params.Model, params.ModelType, params.Enabled, po_entity.LoadBalancingStrategy(params.Strategy))
+}
+
+func (ms *ModelService) getProviderConfiguration(ctx context.Context, tenantID, provider string) (*biz_entity_provider_config.ProviderConfiguration, error) {
+	providerConfigurations, _, err := ms.providerDomain.GetConfigurations(ctx, tenantID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return providerConfigurations.GetConfigurationByProvider(ctx, provider)
+}
+
+func (ms *ModelService) getLoadBalancingConfig(ctx context.Context, tenantID, provider, configID string) (*po_entity.LoadBalancingModelConfig, error) {
+	configRecord, err := ms.providerDomain.ModelRepo.GetLoadBalancingConfigByID(ctx, tenantID, configID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if configRecord == nil || configRecord.ProviderName != provider {
+		return nil, errors.WithCode(code.ErrResourceNotFound, "load balancing config %s of provider %s not found", configID, provider)
+	}
+
+	return configRecord, nil
+}
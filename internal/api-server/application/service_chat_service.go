@@ -16,6 +16,7 @@ import (
 	po_account "github.com/lunarianss/Luna/internal/api-server/domain/account/entity/po_entity"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
@@ -37,10 +38,11 @@ type ServiceChatService struct {
 	providerDomain *domain_service.ProviderDomain
 	config         *config.Config
 	datasetDomain  *datasetDomain.DatasetDomain
+	budgetDomain   *budgetDomain.BudgetDomain
 	redis          *redis.Client
 }
 
-func NewServiceChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomain *accountDomain.AccountDomain, appDomain *appDomain.AppDomain, config *config.Config, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain) *ServiceChatService {
+func NewServiceChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomain *accountDomain.AccountDomain, appDomain *appDomain.AppDomain, config *config.Config, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, budgetDomain *budgetDomain.BudgetDomain) *ServiceChatService {
 	return &ServiceChatService{
 		webAppDomain:   webAppDomain,
 		accountDomain:  accountDomain,
@@ -48,6 +50,7 @@ func NewServiceChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomai
 		config:         config,
 		providerDomain: providerDomain,
 		chatDomain:     chatDomain,
+		budgetDomain:   budgetDomain,
 	}
 }
 
@@ -79,7 +82,7 @@ func (s *ServiceChatService) baseChat(ctx context.Context, app *po_entity.App, t
 		}
 	}
 
-	chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.redis)
+	chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.budgetDomain, s.redis)
 
 	chatMessageBodyDto := assembler.ConvertToCreateChatMessageBody(args)
 
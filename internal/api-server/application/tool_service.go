@@ -3,10 +3,16 @@ package service
 import (
 	"context"
 
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/api"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
+	po_account "github.com/lunarianss/Luna/internal/api-server/domain/account/entity/po_entity"
 	agentDomain "github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/agent"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
 )
 
 type ToolService struct {
@@ -47,3 +53,387 @@ func (ts *ToolService) GetBuiltInLabels(ctx context.Context) ([]*biz_entity.Tool
 func (ts *ToolService) GetIconPath(ctx context.Context, provider string) (string, error) {
 	return ts.agentDomain.ResolveProviderPath(provider)
 }
+
+func (ts *ToolService) GetApiToolProviders(ctx context.Context, accountID string) ([]*biz_entity.UserToolProvider, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.ListApiToolProviders(ctx, tenant.ID)
+}
+
+func (ts *ToolService) ParseApiSchema(ctx context.Context, schema string) (*dto.ParseApiSchemaResponse, error) {
+	schemaType, bundles, err := ts.agentDomain.ParseApiSchema(ctx, schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ParseApiSchemaResponse{SchemaType: string(schemaType), ParametersSchema: bundles}, nil
+}
+
+// GetRemoteApiSchema fetches the schema of the url, the schema is parsed so that the invalid ones are rejected early.
+func (ts *ToolService) GetRemoteApiSchema(ctx context.Context, schemaURL string) (string, error) {
+	schema, err := api.FetchSchema(ctx, schemaURL)
+
+	if err != nil {
+		return "", err
+	}
+
+	if _, _, err := ts.agentDomain.ParseApiSchema(ctx, string(schema)); err != nil {
+		return "", err
+	}
+
+	return string(schema), nil
+}
+
+func (ts *ToolService) AddApiToolProvider(ctx context.Context, accountID string, params *dto.AddApiToolProviderBody) (*biz_entity.UserToolProvider, error) {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &po_entity.ToolApiProvider{
+		TenantID: tenant.ID,
+		UserID:   accountID,
+	}
+
+	if err := ts.saveApiToolProvider(ctx, tenant, provider, params); err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.ApiProviderToUserProvider(provider)
+}
+
+func (ts *ToolService) UpdateApiToolProvider(ctx context.Context, accountID string, params *dto.UpdateApiToolProviderBody) (*biz_entity.UserToolProvider, error) {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getApiToolProvider(ctx, tenant.ID, params.OriginalProvider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ts.saveApiToolProvider(ctx, tenant, provider, &params.AddApiToolProviderBody); err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.ApiProviderToUserProvider(provider)
+}
+
+func (ts *ToolService) DeleteApiToolProvider(ctx context.Context, accountID string, providerName string) error {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ts.getApiToolProvider(ctx, tenant.ID, providerName); err != nil {
+		return err
+	}
+
+	return ts.agentDomain.AgentRepo.DeleteApiToolProvider(ctx, tenant.ID, providerName)
+}
+
+func (ts *ToolService) GetApiToolProvider(ctx context.Context, accountID string, providerName string) (*dto.ApiToolProviderDetail, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getApiToolProvider(ctx, tenant.ID, providerName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider, err := ts.agentDomain.ApiProviderToUserProvider(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ApiToolProviderDetail{
+		ID:          provider.ID,
+		Provider:    provider.Name,
+		Icon:        provider.Icon,
+		Description: provider.Description,
+		SchemaType:  provider.SchemaType,
+		Schema:      provider.Schema,
+		Credentials: userProvider.MaskedCredentials,
+		Tools:       userProvider.Tools,
+	}, nil
+}
+
+func (ts *ToolService) GetApiProviderTools(ctx context.Context, accountID string, providerName string) ([]*biz_entity.UserTool, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getApiToolProvider(ctx, tenant.ID, providerName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider, err := ts.agentDomain.ApiProviderToUserProvider(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return userProvider.Tools, nil
+}
+
+func (ts *ToolService) GetMcpToolProviders(ctx context.Context, accountID string) ([]*biz_entity.UserToolProvider, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.ListMcpToolProviders(ctx, tenant.ID)
+}
+
+func (ts *ToolService) AddMcpToolProvider(ctx context.Context, accountID string, params *dto.AddMcpToolProviderBody) (*biz_entity.UserToolProvider, error) {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := &po_entity.ToolMcpProvider{
+		TenantID: tenant.ID,
+		UserID:   accountID,
+	}
+
+	if err := ts.saveMcpToolProvider(ctx, tenant, provider, params); err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.McpProviderToUserProvider(provider)
+}
+
+func (ts *ToolService) UpdateMcpToolProvider(ctx context.Context, accountID string, params *dto.UpdateMcpToolProviderBody) (*biz_entity.UserToolProvider, error) {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getMcpToolProvider(ctx, tenant.ID, params.ProviderID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ts.saveMcpToolProvider(ctx, tenant, provider, &params.AddMcpToolProviderBody); err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.McpProviderToUserProvider(provider)
+}
+
+// RefreshMcpToolProvider lists the tools of the server again with the stored config.
+func (ts *ToolService) RefreshMcpToolProvider(ctx context.Context, accountID string, providerID string) (*biz_entity.UserToolProvider, error) {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getMcpToolProvider(ctx, tenant.ID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hide := func(secrets map[string]string) map[string]string {
+		hidden := make(map[string]string, len(secrets))
+
+		for k := range secrets {
+			hidden[k] = agentDomain.HIDDEN_API_KEY
+		}
+		return hidden
+	}
+
+	server := &biz_entity.McpServerConfig{
+		Transport: biz_entity.McpTransportType(provider.Transport),
+		Command:   provider.Command,
+		Args:      provider.Args,
+		Env:       hide(provider.Env),
+		URL:       provider.URL,
+		Headers:   hide(provider.Headers),
+	}
+
+	if err := ts.agentDomain.SaveMcpToolProvider(ctx, provider, server, tenant.EncryptPublicKey); err != nil {
+		return nil, err
+	}
+
+	return ts.agentDomain.McpProviderToUserProvider(provider)
+}
+
+func (ts *ToolService) DeleteMcpToolProvider(ctx context.Context, accountID string, providerID string) error {
+	tenant, err := ts.getPrivilegedTenant(ctx, accountID)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := ts.getMcpToolProvider(ctx, tenant.ID, providerID); err != nil {
+		return err
+	}
+
+	return ts.agentDomain.DeleteMcpToolProvider(ctx, tenant.ID, providerID)
+}
+
+func (ts *ToolService) GetMcpToolProvider(ctx context.Context, accountID string, providerID string) (*dto.McpToolProviderDetail, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getMcpToolProvider(ctx, tenant.ID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider, err := ts.agentDomain.McpProviderToUserProvider(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.McpToolProviderDetail{
+		ID:          provider.ID,
+		Provider:    provider.Name,
+		Icon:        provider.Icon,
+		Description: provider.Description,
+		Server:      userProvider.MaskedCredentials,
+		Tools:       userProvider.Tools,
+	}, nil
+}
+
+func (ts *ToolService) GetMcpProviderTools(ctx context.Context, accountID string, providerID string) ([]*biz_entity.UserTool, error) {
+	tenant, _, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider, err := ts.getMcpToolProvider(ctx, tenant.ID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider, err := ts.agentDomain.McpProviderToUserProvider(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return userProvider.Tools, nil
+}
+
+func (ts *ToolService) saveApiToolProvider(ctx context.Context, tenant *po_account.Tenant, provider *po_entity.ToolApiProvider, params *dto.AddApiToolProviderBody) error {
+	schema := params.Schema
+
+	if schema == "" {
+		if params.SchemaURL == "" {
+			return errors.WithCode(code.ErrApiToolSchema, "either schema or schema_url of the api tool provider is required")
+		}
+
+		fetchedSchema, err := api.FetchSchema(ctx, params.SchemaURL)
+
+		if err != nil {
+			return err
+		}
+		schema = string(fetchedSchema)
+	}
+
+	provider.Name = params.Provider
+	provider.Icon = params.Icon
+	provider.Description = params.Description
+	provider.Schema = schema
+
+	credentials := &biz_entity.ApiProviderCredentials{
+		AuthType:     biz_entity.ApiAuthType(params.Credentials.AuthType),
+		APIKeyName:   params.Credentials.APIKeyName,
+		APIKeyPrefix: params.Credentials.APIKeyPrefix,
+		APIKeyValue:  params.Credentials.APIKeyValue,
+	}
+
+	return ts.agentDomain.SaveApiToolProvider(ctx, provider, credentials, tenant.EncryptPublicKey)
+}
+
+func (ts *ToolService) getApiToolProvider(ctx context.Context, tenantID, providerName string) (*po_entity.ToolApiProvider, error) {
+	provider, err := ts.agentDomain.AgentRepo.GetApiToolProviderByName(ctx, tenantID, providerName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == nil {
+		return nil, errors.WithCode(code.ErrResourceNotFound, "api tool provider %s is not found", providerName)
+	}
+
+	return provider, nil
+}
+
+func (ts *ToolService) getPrivilegedTenant(ctx context.Context, accountID string) (*po_account.Tenant, error) {
+	tenant, tenantJoin, err := ts.accountDomain.GetCurrentTenantOfAccount(ctx, accountID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !tenantJoin.IsPrivilegedRole() {
+		return nil, errors.WithCode(code.ErrForbidden, "tenant %s don't have the permission", tenant.Name)
+	}
+
+	return tenant, nil
+}
+
+func (ts *ToolService) saveMcpToolProvider(ctx context.Context, tenant *po_account.Tenant, provider *po_entity.ToolMcpProvider, params *dto.AddMcpToolProviderBody) error {
+	provider.Name = params.Provider
+	provider.Icon = params.Icon
+	provider.Description = params.Description
+
+	server := &biz_entity.McpServerConfig{
+		Transport: biz_entity.McpTransportType(params.Server.Transport),
+		Command:   params.Server.Command,
+		Args:      params.Server.Args,
+		Env:       params.Server.Env,
+		URL:       params.Server.URL,
+		Headers:   params.Server.Headers,
+	}
+
+	return ts.agentDomain.SaveMcpToolProvider(ctx, provider, server, tenant.EncryptPublicKey)
+}
+
+func (ts *ToolService) getMcpToolProvider(ctx context.Context, tenantID, providerID string) (*po_entity.ToolMcpProvider, error) {
+	provider, err := ts.agentDomain.AgentRepo.GetMcpToolProviderByID(ctx, tenantID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == nil {
+		return nil, errors.WithCode(code.ErrResourceNotFound, "mcp tool provider %s is not found", providerID)
+	}
+
+	return provider, nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
@@ -32,10 +33,11 @@ type WebChatService struct {
 	providerDomain *domain_service.ProviderDomain
 	config         *config.Config
 	datasetDomain  *datasetDomain.DatasetDomain
+	budgetDomain   *budgetDomain.BudgetDomain
 	redis          *redis.Client
 }
 
-func NewWebChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomain *accountDomain.AccountDomain, appDomain *appDomain.AppDomain, config *config.Config, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, redis *redis.Client) *WebChatService {
+func NewWebChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomain *accountDomain.AccountDomain, appDomain *appDomain.AppDomain, config *config.Config, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, budgetDomain *budgetDomain.BudgetDomain, redis *redis.Client) *WebChatService {
 	return &WebChatService{
 		webAppDomain:   webAppDomain,
 		accountDomain:  accountDomain,
@@ -44,6 +46,7 @@ func NewWebChatService(webAppDomain *webAppDomain.WebAppDomain, accountDomain *a
 		providerDomain: providerDomain,
 		chatDomain:     chatDomain,
 		datasetDomain:  datasetDomain,
+		budgetDomain:   budgetDomain,
 		redis:          redis,
 	}
 }
@@ -62,7 +65,7 @@ func (s *WebChatService) Chat(ctx context.Context, appID, endUserID string, args
 		return err
 	}
 
-	chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.redis)
+	chatAppGenerator := app_chat_generator.NewChatAppGenerator(s.appDomain, s.providerDomain, s.chatDomain, s.datasetDomain, s.budgetDomain, s.redis)
 
 	if err := chatAppGenerator.Generate(ctx, appModel, endUserRecord, args, invokeFrom, true); err != nil {
 		return err
@@ -32,6 +32,8 @@ func ConvertToConfigEntity(dtoAppConfig *dto.AppModelConfigDto) *biz_entity.AppM
 		ExternalDataTools:             dtoAppConfig.ExternalDataTools,
 		FileUpload:                    dtoAppConfig.FileUpload,
 		TextToSpeech:                  biz_entity.AppModelConfigEnable(dtoAppConfig.TextToSpeech),
+		StructuredOutput:              (*biz_entity.StructuredOutput)(dtoAppConfig.StructuredOutput),
+		LLMCache:                      (*biz_entity.LLMCache)(dtoAppConfig.LLMCache),
 	}
 }
 
@@ -42,9 +44,22 @@ func ConvertToModelEntity(dtoModel dto.ModelDto) biz_entity.ModelInfo {
 		Name:             dtoModel.Name,
 		Mode:             dtoModel.Mode,
 		CompletionParams: dtoModel.CompletionParams,
+		Fallbacks:        ConvertToFallbackModelsEntity(dtoModel.Fallbacks),
 	}
 }
 
+func ConvertToFallbackModelsEntity(dtoFallbacks []*dto.FallbackModelDto) []*biz_entity.FallbackModelInfo {
+	fallbacks := make([]*biz_entity.FallbackModelInfo, 0, len(dtoFallbacks))
+
+	for _, dtoFallback := range dtoFallbacks {
+		fallbacks = append(fallbacks, &biz_entity.FallbackModelInfo{
+			Provider: dtoFallback.Provider,
+			Name:     dtoFallback.Name,
+		})
+	}
+	return fallbacks
+}
+
 func ConvertToUserInputEntity(userInputs []dto.UserInputForm) []biz_entity.UserInputForm {
 	var returnUserInput []biz_entity.UserInputForm
 
@@ -86,13 +101,14 @@ func ConvertToBizAgentTools(agentTools []*dto.AgentTools) []*biz_entity.AgentToo
 
 	for _, agentTool := range agentTools {
 		poAgentTools = append(poAgentTools, &biz_entity.AgentTools{
-			Enabled:        agentTool.Enabled,
-			ProviderID:     agentTool.ProviderID,
-			ProviderName:   agentTool.ProviderName,
-			ProviderType:   agentTool.ProviderType,
-			ToolLabel:      agentTool.ToolLabel,
-			ToolName:       agentTool.ToolName,
-			ToolParameters: agentTool.ToolParameters,
+			Enabled:          agentTool.Enabled,
+			ProviderID:       agentTool.ProviderID,
+			ProviderName:     agentTool.ProviderName,
+			ProviderType:     agentTool.ProviderType,
+			ToolLabel:        agentTool.ToolLabel,
+			ToolName:         agentTool.ToolName,
+			ToolParameters:   agentTool.ToolParameters,
+			RequiresApproval: agentTool.RequiresApproval,
 		})
 	}
 	return poAgentTools
@@ -28,10 +28,11 @@ func (acm *AgentConfigManager) Convert(config *dto.AppModelConfigDto) (*biz_enti
 
 	for _, agentTool := range config.AgentMode.Tools {
 		agentTools = append(agentTools, &biz_entity.AgentToolEntity{
-			ProviderType:   biz_entity.AgentToolProviderType(agentTool.ProviderType),
-			ProviderID:     agentTool.ProviderID,
-			ToolName:       agentTool.ToolName,
-			ToolParameters: agentTool.ToolParameters,
+			ProviderType:     biz_entity.AgentToolProviderType(agentTool.ProviderType),
+			ProviderID:       agentTool.ProviderID,
+			ToolName:         agentTool.ToolName,
+			ToolParameters:   agentTool.ToolParameters,
+			RequiresApproval: agentTool.RequiresApproval,
 		})
 	}
 
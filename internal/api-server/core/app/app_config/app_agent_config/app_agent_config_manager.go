@@ -11,6 +11,7 @@ import (
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	assembler "github.com/lunarianss/Luna/internal/api-server/assembler/app"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_model_config"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_moderation_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_prompt_template"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_variable_config"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
@@ -38,6 +39,15 @@ func (m *ChatAgentAppConfigManager) ConfigValidate(ctx context.Context, tenantID
 
 	config, _, err := modelConfigManager.ValidateAndSetDefaults(ctx, tenantID, config)
 
+	if err != nil {
+		return nil, err
+	}
+
+	// moderation
+	sensitiveWordAvoidanceConfigManager := app_moderation_config.NewSensitiveWordAvoidanceConfigManager()
+
+	config, _, err = sensitiveWordAvoidanceConfigManager.ValidateAndSetDefaults(config)
+
 	if err != nil {
 		return nil, err
 	}
@@ -105,7 +115,7 @@ func (m *ChatAgentAppConfigManager) GetAppConfig(ctx context.Context, appModel *
 				TenantID:               appModel.TenantID,
 				AppID:                  appModel.ID,
 				AppMode:                appModel.Mode,
-				SensitiveWordAvoidance: nil,
+				SensitiveWordAvoidance: app_moderation_config.NewSensitiveWordAvoidanceConfigManager().Convert(configDict),
 				AdditionalFeatures:     nil,
 				Variables:              variables.Convert(configDict),
 			},
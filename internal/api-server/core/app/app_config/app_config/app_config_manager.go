@@ -10,8 +10,11 @@ import (
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	assembler "github.com/lunarianss/Luna/internal/api-server/assembler/app"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_llm_cache_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_model_config"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_moderation_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_prompt_template"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_structured_output_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_variable_config"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
@@ -48,6 +51,35 @@ func (m *ChatAppConfigManager) ConfigValidate(ctx context.Context, tenantID stri
 
 	relatedConfigKeys = append(relatedConfigKeys, currentRelatedConfigKeys...)
 
+	// moderation
+	sensitiveWordAvoidanceConfigManager := app_moderation_config.NewSensitiveWordAvoidanceConfigManager()
+
+	config, currentRelatedConfigKeys, err = sensitiveWordAvoidanceConfigManager.ValidateAndSetDefaults(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	relatedConfigKeys = append(relatedConfigKeys, currentRelatedConfigKeys...)
+
+	// structured output
+	config, currentRelatedConfigKeys, err = app_structured_output_config.NewStructuredOutputConfigManager().ValidateAndSetDefaults(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	relatedConfigKeys = append(relatedConfigKeys, currentRelatedConfigKeys...)
+
+	// llm cache
+	config, currentRelatedConfigKeys, err = app_llm_cache_config.NewLLMCacheConfigManager().ValidateAndSetDefaults(config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	relatedConfigKeys = append(relatedConfigKeys, currentRelatedConfigKeys...)
+
 	// todo Filter out extra parameters
 	return config, nil
 }
@@ -108,7 +140,7 @@ func (m *ChatAppConfigManager) GetAppConfig(ctx context.Context, appModel *po_en
 				TenantID:               appModel.TenantID,
 				AppID:                  appModel.ID,
 				AppMode:                appModel.Mode,
-				SensitiveWordAvoidance: nil,
+				SensitiveWordAvoidance: app_moderation_config.NewSensitiveWordAvoidanceConfigManager().Convert(configDict),
 				AdditionalFeatures:     nil,
 				Variables:              variables.Convert(configDict),
 			},
@@ -117,6 +149,8 @@ func (m *ChatAppConfigManager) GetAppConfig(ctx context.Context, appModel *po_en
 			AppModelConfigID:   appModelConfig.ID,
 			Model:              modelConfigEntity,
 			PromptTemplate:     promptTemplate,
+			StructuredOutput:   app_structured_output_config.NewStructuredOutputConfigManager().Convert(configDict),
+			LLMCache:           app_llm_cache_config.NewLLMCacheConfigManager().Convert(configDict),
 		},
 	}, nil
 }
@@ -0,0 +1,80 @@
+package app_llm_cache_config
+
+import (
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/llm_cache"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/chat"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type LLMCacheConfigManager struct{}
+
+func NewLLMCacheConfigManager() *LLMCacheConfigManager {
+	return &LLMCacheConfigManager{}
+}
+
+// Convert returns nil when the llm cache is not enabled.
+func (*LLMCacheConfigManager) Convert(appModelConfig *dto.AppModelConfigDto) *biz_entity.LLMCacheEntity {
+	llmCache := appModelConfig.LLMCache
+
+	if llmCache == nil || !llmCache.Enabled {
+		return nil
+	}
+
+	return &biz_entity.LLMCacheEntity{
+		Mode:                llmCache.Mode,
+		TTL:                 llmCache.TTL,
+		SimilarityThreshold: llmCache.SimilarityThreshold,
+		EmbeddingProvider:   llmCache.EmbeddingProvider,
+		EmbeddingModel:      llmCache.EmbeddingModel,
+	}
+}
+
+func (m *LLMCacheConfigManager) ValidateAndSetDefaults(config *dto.AppModelConfigDto) (*dto.AppModelConfigDto, []string, error) {
+	if config.LLMCache == nil {
+		config.LLMCache = &dto.LLMCacheDto{
+			Enabled: false,
+		}
+	}
+
+	llmCache := config.LLMCache
+
+	if !llmCache.Enabled {
+		return config, []string{"llm_cache"}, nil
+	}
+
+	if llmCache.Mode == "" {
+		llmCache.Mode = llm_cache.EXACT_MODE
+	}
+
+	if llmCache.Mode != llm_cache.EXACT_MODE && llmCache.Mode != llm_cache.SEMANTIC_MODE {
+		return nil, nil, errors.WithCode(code.ErrLLMCacheConfig, "mode of the llm cache must be %s or %s", llm_cache.EXACT_MODE, llm_cache.SEMANTIC_MODE)
+	}
+
+	if llmCache.TTL == 0 {
+		llmCache.TTL = llm_cache.DEFAULT_TTL
+	}
+
+	if llmCache.TTL < 0 || llmCache.TTL > llm_cache.MAX_TTL {
+		return nil, nil, errors.WithCode(code.ErrLLMCacheConfig, "ttl of the llm cache must be between 1 and %d seconds", llm_cache.MAX_TTL)
+	}
+
+	if llmCache.Mode == llm_cache.EXACT_MODE {
+		return config, []string{"llm_cache"}, nil
+	}
+
+	if llmCache.SimilarityThreshold == 0 {
+		llmCache.SimilarityThreshold = llm_cache.DEFAULT_SIMILARITY_THRESHOLD
+	}
+
+	if llmCache.SimilarityThreshold < 0 || llmCache.SimilarityThreshold > 1 {
+		return nil, nil, errors.WithCode(code.ErrLLMCacheConfig, "similarity threshold of the llm cache must be between 0 and 1")
+	}
+
+	if (llmCache.EmbeddingProvider == "") != (llmCache.EmbeddingModel == "") {
+		return nil, nil, errors.WithCode(code.ErrLLMCacheConfig, "embedding provider and embedding model of the llm cache must be set together")
+	}
+
+	return config, []string{"llm_cache"}, nil
+}
@@ -7,6 +7,8 @@ package app_model_config
 import (
 	"context"
 
+	"github.com/lunarianss/Luna/infrastructure/log"
+
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
@@ -31,6 +33,11 @@ func (c *ModelConfigConverter) Convert(ctx context.Context, appConfig *biz_entit
 	if err != nil {
 		return nil, err
 	}
+
+	if err := c.ProviderDomain.CheckQuota(providerModelBundle.Configuration); err != nil {
+		return nil, err
+	}
+
 	modelTypeInstance := providerModelBundle.ModelTypeInstance
 
 	credentials, err := providerModelBundle.Configuration.GetCurrentCredentials(common.LLM, modelConfig.Model)
@@ -54,6 +61,44 @@ func (c *ModelConfigConverter) Convert(ctx context.Context, appConfig *biz_entit
 		Credentials:         credentials,
 		Stop:                modelConfig.Stop,
 		Parameters:          modelConfig.Parameters,
+		Fallbacks:           c.convertFallbacks(ctx, appConfig.TenantID, modelConfig.Fallbacks),
 	}, nil
 
 }
+
+// convertFallbacks resolves the credentials of the fallback chain, a fallback model which can't be resolved
+// (e.g. the provider credentials were removed after the app was configured) is skipped instead of failing the whole app.
+func (c *ModelConfigConverter) convertFallbacks(ctx context.Context, tenantID string, fallbacks []*biz_entity_app_config.FallbackModelConfigEntity) []*biz_entity.ModelIntegratedInstance {
+	modelInstances := make([]*biz_entity.ModelIntegratedInstance, 0, len(fallbacks))
+
+	for _, fallback := range fallbacks {
+		providerModelBundle, err := c.ProviderDomain.GetProviderModelBundle(ctx, tenantID, fallback.Provider, common.LLM)
+
+		if err != nil {
+			log.Warnf("skip fallback model %s/%s: %s", fallback.Provider, fallback.Model, err.Error())
+			continue
+		}
+
+		if err := c.ProviderDomain.CheckQuota(providerModelBundle.Configuration); err != nil {
+			log.Warnf("skip fallback model %s/%s: %s", fallback.Provider, fallback.Model, err.Error())
+			continue
+		}
+
+		credentials, err := providerModelBundle.Configuration.GetCurrentCredentials(common.LLM, fallback.Model)
+
+		if err != nil {
+			log.Warnf("skip fallback model %s/%s: %s", fallback.Provider, fallback.Model, err.Error())
+			continue
+		}
+
+		modelInstances = append(modelInstances, &biz_entity.ModelIntegratedInstance{
+			ProviderModelBundle: providerModelBundle,
+			Model:               fallback.Model,
+			Provider:            providerModelBundle.Configuration.Provider.Provider,
+			Credentials:         credentials,
+			ModelTypeInstance:   providerModelBundle.ModelTypeInstance,
+		})
+	}
+
+	return modelInstances
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_model_config
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type fakeProviderRepo struct {
+	repository.ProviderRepo
+	records map[string][]*po_entity.Provider
+}
+
+func (pr *fakeProviderRepo) GetMapTenantModelProviders(ctx context.Context, tenantId string) (map[string][]*po_entity.Provider, error) {
+	return pr.records, nil
+}
+
+func (pr *fakeProviderRepo) GetSystemProviders(ctx context.Context) ([]*biz_entity.ProviderStaticConfiguration, []string, error) {
+	providers := []string{"openai", "anthropic", "deepseek"}
+	providerEntities := make([]*biz_entity.ProviderStaticConfiguration, 0, len(providers))
+
+	for _, provider := range providers {
+		providerEntities = append(providerEntities, &biz_entity.ProviderStaticConfiguration{Provider: provider})
+	}
+	return providerEntities, providers, nil
+}
+
+func (pr *fakeProviderRepo) GetProviderInstance(ctx context.Context, provider string) (*biz_entity.ProviderRuntime, error) {
+	return &biz_entity.ProviderRuntime{ModelConfPath: "../../../model_runtime/model_providers/" + provider}, nil
+}
+
+type fakeModelRepo struct {
+	repository.ModelRepo
+}
+
+func (mr *fakeModelRepo) GetTenantModelSettings(ctx context.Context, tenantId string) ([]*po_entity.ProviderModelSetting, error) {
+	return nil, nil
+}
+
+func (mr *fakeModelRepo) GetTenantLoadBalancingConfigs(ctx context.Context, tenantId string) ([]*po_entity.LoadBalancingModelConfig, error) {
+	return nil, nil
+}
+
+func providerRecord(provider string, quotaLimit, quotaUsed int64) *po_entity.Provider {
+	return &po_entity.Provider{
+		TenantID:        "tenant-1",
+		ProviderName:    provider,
+		ProviderType:    string(po_entity.CUSTOM),
+		EncryptedConfig: provider + "-key",
+		QuotaType:       string(po_entity.TRIAL),
+		QuotaLimit:      &quotaLimit,
+		QuotaUsed:       quotaUsed,
+	}
+}
+
+func TestModelConfigConverterQuota(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	providerRepo := &fakeProviderRepo{
+		records: map[string][]*po_entity.Provider{
+			"openai":    {providerRecord("openai", 100, 100)},
+			"anthropic": {providerRecord("anthropic", 100, 99)},
+			"deepseek":  {providerRecord("deepseek", 0, 3)},
+		},
+	}
+	converter := NewModelConfigConverter(domain_service.NewProviderDomain(providerRepo, &fakeModelRepo{}, nil, nil))
+
+	appConfig := &biz_entity_app_config.EasyUIBasedAppConfig{
+		AppConfig: &biz_entity_app_config.AppConfig{TenantID: "tenant-1"},
+		Model: &biz_entity_app_config.ModelConfigEntity{
+			Provider: "anthropic",
+			Model:    "claude-3-5-sonnet-20240620",
+			Fallbacks: []*biz_entity_app_config.FallbackModelConfigEntity{
+				{Provider: "openai", Model: "gpt-4o"},
+				{Provider: "deepseek", Model: "deepseek-chat"},
+				{Provider: "anthropic", Model: "claude-3-haiku-20240307"},
+			},
+		},
+	}
+
+	modelConfig, err := converter.Convert(context.Background(), appConfig, false)
+
+	if err != nil {
+		t.Fatalf("Convert() error = %v", err)
+	}
+
+	// the fallbacks of the exhausted providers are skipped
+	if len(modelConfig.Fallbacks) != 1 || modelConfig.Fallbacks[0].Model != "claude-3-haiku-20240307" {
+		t.Fatalf("unexpected fallbacks %+v", modelConfig.Fallbacks)
+	}
+
+	if modelConfig.Fallbacks[0].Credentials["openai_api_key"] != "anthropic-key" {
+		t.Errorf("unexpected credentials %v of the fallback", modelConfig.Fallbacks[0].Credentials)
+	}
+
+	appConfig.Model.Provider, appConfig.Model.Model = "openai", "gpt-4o"
+
+	if _, err := converter.Convert(context.Background(), appConfig, false); !errors.IsCode(err, code.ErrProviderQuotaExceed) {
+		t.Errorf("expected ErrProviderQuotaExceed of the exhausted model, got %v", err)
+	}
+}
@@ -6,6 +6,7 @@ package app_model_config
 
 import (
 	"context"
+	"fmt"
 	"slices"
 	"strings"
 
@@ -77,15 +78,70 @@ func (m *ModelConfigManager) ValidateAndSetDefaults(ctx context.Context, tenantI
 		config.Model.Mode = modelModeStr
 	}
 
+	if err := m.validateFallbacks(ctx, providerConfigurations, orderedProviders, config); err != nil {
+		return nil, nil, err
+	}
+
+	completionParams, err := m.ProviderDomain.ValidateModelParameters(ctx, tenantID, config.Model.Provider, config.Model.Name, config.Model.CompletionParams, true)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	config.Model.CompletionParams = completionParams
+
 	return config, []string{"model"}, nil
 }
 
+// validateFallbacks checks every model of the fallback chain is an available llm and differs from the models before it.
+func (m *ModelConfigManager) validateFallbacks(ctx context.Context, providerConfigurations *biz_entity_provider_config.ProviderConfigurations, orderedProviders []string, config *dto.AppModelConfigDto) error {
+	chain := []string{fmt.Sprintf("%s/%s", config.Model.Provider, config.Model.Name)}
+
+	for _, fallback := range config.Model.Fallbacks {
+		if fallback == nil || fallback.Provider == "" || fallback.Name == "" {
+			return errors.WithCode(code.ErrRequiredCorrectModel, "fallback model requires both provider and name")
+		}
+
+		fallbackKey := fmt.Sprintf("%s/%s", fallback.Provider, fallback.Name)
+
+		if slices.Contains(chain, fallbackKey) {
+			return errors.WithCode(code.ErrRequiredCorrectModel, "fallback model %s is duplicated in the chain", fallbackKey)
+		}
+
+		availableModels, err := providerConfigurations.GetModels(ctx, orderedProviders, fallback.Provider, common.LLM, false)
+
+		if err != nil {
+			return err
+		}
+
+		if !slices.ContainsFunc(availableModels, func(availableModel *biz_entity_provider_config.ModelWithProvider) bool {
+			return availableModel.Model == fallback.Name
+		}) {
+			return errors.WithCode(code.ErrRequiredCorrectModel, "fallback model %s not found", fallbackKey)
+		}
+
+		chain = append(chain, fallbackKey)
+	}
+
+	return nil
+}
+
 func (m *ModelConfigManager) Convert(ctx context.Context, config *dto.AppModelConfigDto) (*biz_entity_app_config.ModelConfigEntity, error) {
 
+	fallbacks := make([]*biz_entity_app_config.FallbackModelConfigEntity, 0, len(config.Model.Fallbacks))
+
+	for _, fallback := range config.Model.Fallbacks {
+		fallbacks = append(fallbacks, &biz_entity_app_config.FallbackModelConfigEntity{
+			Provider: fallback.Provider,
+			Model:    fallback.Name,
+		})
+	}
+
 	return &biz_entity_app_config.ModelConfigEntity{
 		Provider:   config.Model.Provider,
 		Model:      config.Model.Name,
 		Mode:       config.Model.Mode,
 		Parameters: config.Model.CompletionParams,
+		Fallbacks:  fallbacks,
 	}, nil
 }
@@ -0,0 +1,50 @@
+package app_moderation_config
+
+import (
+	"github.com/lunarianss/Luna/internal/api-server/core/moderation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/chat"
+)
+
+type SensitiveWordAvoidanceConfigManager struct{}
+
+func NewSensitiveWordAvoidanceConfigManager() *SensitiveWordAvoidanceConfigManager {
+	return &SensitiveWordAvoidanceConfigManager{}
+}
+
+// Convert returns nil when the sensitive word avoidance feature is not enabled.
+func (*SensitiveWordAvoidanceConfigManager) Convert(appModelConfig *dto.AppModelConfigDto) *biz_entity.SensitiveWordAvoidanceEntity {
+	sensitiveWordAvoidance := appModelConfig.SensitiveWordAvoidance
+
+	if sensitiveWordAvoidance == nil {
+		return nil
+	}
+
+	if enabled, ok := sensitiveWordAvoidance["enabled"].(bool); !ok || !enabled {
+		return nil
+	}
+
+	moderationType, _ := sensitiveWordAvoidance["type"].(string)
+	config, _ := sensitiveWordAvoidance["config"].(map[string]interface{})
+
+	return &biz_entity.SensitiveWordAvoidanceEntity{
+		Type:   moderationType,
+		Config: config,
+	}
+}
+
+func (m *SensitiveWordAvoidanceConfigManager) ValidateAndSetDefaults(config *dto.AppModelConfigDto) (*dto.AppModelConfigDto, []string, error) {
+	if config.SensitiveWordAvoidance == nil {
+		config.SensitiveWordAvoidance = map[string]interface{}{
+			"enabled": false,
+		}
+	}
+
+	if sensitiveWordAvoidance := m.Convert(config); sensitiveWordAvoidance != nil {
+		if _, err := moderation.ParseModerationConfig(sensitiveWordAvoidance); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return config, []string{"sensitive_word_avoidance"}, nil
+}
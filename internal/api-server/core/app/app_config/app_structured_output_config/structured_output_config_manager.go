@@ -0,0 +1,69 @@
+package app_structured_output_config
+
+import (
+	"regexp"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/structured_output"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/chat"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const DEFAULT_STRUCTURED_OUTPUT_NAME = "output"
+
+// the name is sent to the model services as the name of the json schema, which only accept these characters
+var structuredOutputNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+type StructuredOutputConfigManager struct{}
+
+func NewStructuredOutputConfigManager() *StructuredOutputConfigManager {
+	return &StructuredOutputConfigManager{}
+}
+
+// Convert returns nil when the structured output feature is not enabled.
+func (*StructuredOutputConfigManager) Convert(appModelConfig *dto.AppModelConfigDto) *biz_entity.StructuredOutputEntity {
+	structuredOutput := appModelConfig.StructuredOutput
+
+	if structuredOutput == nil || !structuredOutput.Enabled {
+		return nil
+	}
+
+	name := structuredOutput.Name
+
+	if name == "" {
+		name = DEFAULT_STRUCTURED_OUTPUT_NAME
+	}
+
+	return &biz_entity.StructuredOutputEntity{
+		Name:   name,
+		Schema: structuredOutput.Schema,
+	}
+}
+
+func (m *StructuredOutputConfigManager) ValidateAndSetDefaults(config *dto.AppModelConfigDto) (*dto.AppModelConfigDto, []string, error) {
+	if config.StructuredOutput == nil {
+		config.StructuredOutput = &dto.StructuredOutputDto{
+			Enabled: false,
+			Schema:  map[string]interface{}{},
+		}
+	}
+
+	if !config.StructuredOutput.Enabled {
+		return config, []string{"structured_output"}, nil
+	}
+
+	if config.StructuredOutput.Name == "" {
+		config.StructuredOutput.Name = DEFAULT_STRUCTURED_OUTPUT_NAME
+	}
+
+	if !structuredOutputNameRegex.MatchString(config.StructuredOutput.Name) {
+		return nil, nil, errors.WithCode(code.ErrStructuredOutputSchema, "name %s of the structured output can only contain a-z, A-Z, 0-9, underscores and dashes, with a maximum length of 64", config.StructuredOutput.Name)
+	}
+
+	if err := structured_output.CheckSchema(config.StructuredOutput.Schema); err != nil {
+		return nil, nil, errors.WithCode(code.ErrStructuredOutputSchema, "invalid json schema of the structured output: %s", err.Error())
+	}
+
+	return config, []string{"structured_output"}, nil
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 
 	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/rag/rerank"
 	"github.com/lunarianss/Luna/internal/api-server/core/rag/vector_db"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
@@ -18,6 +19,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// ANNOTATION_RERANK_CANDIDATES is the count of the annotations searched for the rerank model to choose the hit from.
+const ANNOTATION_RERANK_CANDIDATES = 4
+
 type annotationReplyFeature struct {
 	chatDomain     *chatDomain.ChatDomain
 	datasetDomain  *datasetDomain.DatasetDomain
@@ -73,12 +77,36 @@ func (arf *annotationReplyFeature) Query(ctx context.Context, app *po_entity.App
 		return nil, err
 	}
 
-	hitDocuments, err := vector.SearchByVector(ctx, query, 1, scoreThreshold)
+	rerankRunner, err := rerank.NewTenantRerankRunner(ctx, arf.providerDomain, app.TenantID, accountID)
+
+	if err != nil {
+		log.Warnf("rerank model of tenant %s is not available, use the best vector hit: %s", app.TenantID, err.Error())
+	}
+
+	topK := 1
+
+	if rerankRunner != nil {
+		topK = ANNOTATION_RERANK_CANDIDATES
+	}
+
+	hitDocuments, err := vector.SearchByVector(ctx, query, topK, scoreThreshold)
 
 	if err != nil {
 		return nil, err
 	}
 
+	// the candidates are above the score threshold already, the rerank model only decides which one is the hit and
+	// the score of the hit history stays the vector similarity which the threshold is configured for
+	if rerankRunner != nil && len(hitDocuments) > 1 {
+		rerankDocuments, err := rerankRunner.Run(ctx, query, hitDocuments, 0, 1)
+
+		if err != nil {
+			log.Warnf("rerank annotations of app %s failed, use the best vector hit: %s", app.ID, err.Error())
+		} else if len(rerankDocuments) > 0 {
+			hitDocuments = rerankDocuments
+		}
+	}
+
 	if len(hitDocuments) != 0 {
 		annotationID := hitDocuments[0].Metadata["annotation_id"]
 		score := hitDocuments[0].Score
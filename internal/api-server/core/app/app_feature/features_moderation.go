@@ -0,0 +1,45 @@
+package app_feature
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/moderation"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+)
+
+type inputModerationFeature struct {
+	providerDomain *providerDomain.ProviderDomain
+}
+
+func NewInputModerationFeature(providerDomain *providerDomain.ProviderDomain) *inputModerationFeature {
+	return &inputModerationFeature{
+		providerDomain: providerDomain,
+	}
+}
+
+// Check runs the input moderation of the app before the llm is invoked, the returned moderation is nil when
+// the sensitive word avoidance feature is disabled and is used to moderate the outputs afterwards. The failed
+// moderation is logged and the inputs pass.
+func (imf *inputModerationFeature) Check(ctx context.Context, appConfig *biz_entity_app_config.AppConfig, inputs map[string]interface{}, query string) (moderation.IModeration, *moderation.ModerationResult, error) {
+	appModeration, err := moderation.NewModeration(ctx, imf.providerDomain, appConfig.TenantID, appConfig.SensitiveWordAvoidance)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if appModeration == nil {
+		return nil, &moderation.ModerationResult{}, nil
+	}
+
+	result, err := appModeration.ModerationForInputs(ctx, inputs, query)
+
+	// the inputs are let through when the moderation isn't available, as the outputs are
+	if err != nil {
+		log.Errorf("input moderation failed, the inputs are passed without it: %s", err.Error())
+		return appModeration, &moderation.ModerationResult{}, nil
+	}
+
+	return appModeration, result, nil
+}
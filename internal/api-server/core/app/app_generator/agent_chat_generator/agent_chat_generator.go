@@ -2,9 +2,11 @@ package app_agent_chat_generator
 
 import (
 	"context"
+	"fmt"
 	"runtime/debug"
 	"time"
 
+	"github.com/bsm/redislock"
 	"github.com/google/uuid"
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	"github.com/lunarianss/Luna/infrastructure/log"
@@ -16,9 +18,11 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/core/app/task_pipeline"
 	agentDomain "github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	biz_entity_agent_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_agent_generator"
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
@@ -34,8 +38,16 @@ import (
 
 const UUID_NIL = "00000000-0000-0000-0000-000000000000"
 
+const (
+	AGENT_APPROVAL_APPROVE  = "approve"
+	AGENT_APPROVAL_REJECT   = "reject"
+	AGENT_APPROVAL_LOCK     = "agent_approval_lock_%s"
+	AGENT_APPROVAL_LOCK_TTL = 10 * time.Second
+)
+
 type IAgentChatAppGenerator interface {
 	Generate(c context.Context, appModel *po_entity.App, user repository.BaseAccount, args *dto.CreateChatMessageBody, invokeFrom biz_entity_app_generate.InvokeFrom, stream bool) error
+	Resume(c context.Context, appModel *po_entity.App, user repository.BaseAccount, messageID string, args *dto.AgentApprovalBody, invokeFrom biz_entity_app_generate.InvokeFrom) error
 }
 
 type AgentChatGenerator struct {
@@ -43,19 +55,21 @@ type AgentChatGenerator struct {
 	ProviderDomain *domain_service.ProviderDomain
 	chatDomain     *chatDomain.ChatDomain
 	DatasetDomain  *datasetDomain.DatasetDomain
+	BudgetDomain   *budgetDomain.BudgetDomain
 	redis          *redis.Client
 	agentDomain    *agentDomain.AgentDomain
 	appConfig      *biz_entity_app_config.AgentChatAppConfig
 	config         *config.Config
 }
 
-func NewChatAppGenerator(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, redis *redis.Client, agentDomain *agentDomain.AgentDomain, config *config.Config) *AgentChatGenerator {
+func NewChatAppGenerator(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, budgetDomain *budgetDomain.BudgetDomain, redis *redis.Client, agentDomain *agentDomain.AgentDomain, config *config.Config) *AgentChatGenerator {
 
 	return &AgentChatGenerator{
 		AppDomain:      appDomain,
 		ProviderDomain: providerDomain,
 		chatDomain:     chatDomain,
 		DatasetDomain:  datasetDomain,
+		BudgetDomain:   budgetDomain,
 		redis:          redis,
 		config:         config,
 		agentDomain:    agentDomain,
@@ -103,6 +117,10 @@ func (acg *AgentChatGenerator) Generate(c context.Context, appModel *po_entity.A
 		extras["auto_generate_conversation_name"] = args.AutoGenerateConversationName
 	}
 
+	if err := acg.BudgetDomain.CheckBudget(c, appModel.TenantID, appModel.ID); err != nil {
+		return err
+	}
+
 	if args.ConversationID != "" {
 		conversationRecord, err = acg.chatDomain.MessageRepo.GetConversationByUser(c, appModel.ID, args.ConversationID, user)
 
@@ -191,20 +209,161 @@ func (acg *AgentChatGenerator) Generate(c context.Context, appModel *po_entity.A
 		po_entity.AppMode("agent-chat"),
 		string(invokeFrom))
 
-	taskScheduler := app_agent_chat_runner.NewAgentChatAppTaskScheduler(applicationGenerateEntity, acg.chatDomain.MessageRepo, messageRecord, acg.chatDomain.AnnotationRepo, nil)
+	taskScheduler := app_agent_chat_runner.NewAgentChatAppTaskScheduler(applicationGenerateEntity, acg.chatDomain.MessageRepo, messageRecord, acg.chatDomain.AnnotationRepo, acg.ProviderDomain, nil)
+
+	flusher := task_pipeline.NewAgentChatFlusher(applicationGenerateEntity, acg.agentDomain.AgentRepo, messageRecord)
+
+	flusher.InitFlusher(c)
+
+	go acg.ListenQueue(queueManager)
+
+	acg.generateGoRoutine(c, applicationGenerateEntity, conversationRecord.ID, messageRecord.ID, queueManager, taskScheduler, flusher, nil)
+
+	acg.recordSpend(c, appModel, messageRecord.ID, taskScheduler)
+
+	return nil
+}
+
+// Resume records the decision on the tool call of the agent paused for approvals, the agent resumes streaming once
+// all the tool calls of the round are decided and the stream ends with the approval required event again otherwise.
+func (acg *AgentChatGenerator) Resume(c context.Context, appModel *po_entity.App, user repository.BaseAccount, messageID string, args *dto.AgentApprovalBody, invokeFrom biz_entity_app_generate.InvokeFrom) error {
+
+	messageRecord, err := acg.chatDomain.MessageRepo.GetMessageByApp(c, messageID, appModel.ID)
+
+	if err != nil {
+		return err
+	}
+
+	conversationRecord, err := acg.chatDomain.MessageRepo.GetConversationByUser(c, appModel.ID, messageRecord.ConversationID, user)
+
+	if err != nil {
+		return err
+	}
+
+	if err := acg.BudgetDomain.CheckBudget(c, appModel.TenantID, appModel.ID); err != nil {
+		return err
+	}
+
+	agentThought, err := acg.decideApproval(c, messageRecord.ID, user.GetAccountID(), args)
+
+	if err != nil {
+		return err
+	}
+
+	appModelConfig, err := acg.getAppModelConfig(c, appModel, conversationRecord)
+
+	if err != nil {
+		return err
+	}
+
+	appConfig, err := app_agent_config.NewAgentChatAppConfigManager(acg.ProviderDomain).GetAppConfig(c, appModel, appModelConfig, conversationRecord, nil)
+
+	if err != nil {
+		return err
+	}
+
+	acg.appConfig = appConfig
+
+	modelConf, err := app_model_config.NewModelConfigConverter(acg.ProviderDomain).Convert(c, appConfig.EasyUIBasedAppConfig, true)
+
+	if err != nil {
+		return err
+	}
+
+	applicationGenerateEntity := &biz_entity_app_generate.AgentChatAppGenerateEntity{
+		EasyUIBasedAppGenerateEntity: &biz_entity_app_generate.EasyUIBasedAppGenerateEntity{
+			AppConfig: appConfig.EasyUIBasedAppConfig,
+			ModelConf: modelConf,
+			AppGenerateEntity: &biz_entity_app_generate.AppGenerateEntity{
+				TaskID:     uuid.NewString(),
+				AppConfig:  appConfig.AppConfig,
+				Stream:     true,
+				Inputs:     messageRecord.Inputs,
+				UserID:     user.GetAccountID(),
+				InvokeFrom: invokeFrom,
+				Extras:     make(map[string]interface{}),
+			},
+			Query: messageRecord.Query,
+		},
+		AgentEntity:     appConfig.AgentEntity,
+		ConversationID:  conversationRecord.ID,
+		ParentMessageID: messageRecord.ParentMessageID,
+	}
 
 	flusher := task_pipeline.NewAgentChatFlusher(applicationGenerateEntity, acg.agentDomain.AgentRepo, messageRecord)
 
 	flusher.InitFlusher(c)
 
+	if len(agentThought.PendingApprovals()) > 0 {
+		return flusher.AgentApprovalRequiredToStreamResponse(c, agentThought.ID)
+	}
+
+	queueManager := biz_entity_agent_generator.NewAgentStreamGenerateQueue(
+		uuid.NewString(),
+		applicationGenerateEntity.EasyUIBasedAppGenerateEntity.UserID,
+		conversationRecord.ID,
+		messageRecord.ID,
+		po_entity.AppMode("agent-chat"),
+		string(invokeFrom))
+
+	taskScheduler := app_agent_chat_runner.NewAgentChatAppTaskScheduler(applicationGenerateEntity, acg.chatDomain.MessageRepo, messageRecord, acg.chatDomain.AnnotationRepo, acg.ProviderDomain, nil)
+
 	go acg.ListenQueue(queueManager)
 
-	acg.generateGoRoutine(c, applicationGenerateEntity, conversationRecord.ID, messageRecord.ID, queueManager, taskScheduler, flusher)
+	acg.generateGoRoutine(c, applicationGenerateEntity, conversationRecord.ID, messageRecord.ID, queueManager, taskScheduler, flusher, agentThought)
+
+	acg.recordSpend(c, appModel, messageRecord.ID, taskScheduler)
 
 	return nil
 }
 
-func (g *AgentChatGenerator) generateGoRoutine(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, conversationID string, messageID string, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, taskPipeline app_agent_chat_runner.IAgentChatAppTaskScheduler, flusher biz_entity.AgentFlusher) {
+// decideApproval records the decision on the pending tool call of the message, the decisions on the tool calls of
+// the same round are serialized for exactly one of them to resume the agent.
+func (acg *AgentChatGenerator) decideApproval(c context.Context, messageID, userID string, args *dto.AgentApprovalBody) (*po_agent.MessageAgentThought, error) {
+
+	lock, err := redislock.New(acg.redis).Obtain(c, fmt.Sprintf(AGENT_APPROVAL_LOCK, messageID), AGENT_APPROVAL_LOCK_TTL, &redislock.Options{
+		RetryStrategy: redislock.LimitRetry(redislock.LinearBackoff(100*time.Millisecond), 30),
+	})
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRedis, err.Error())
+	}
+
+	defer lock.Release(c)
+
+	agentThoughts, err := acg.agentDomain.AgentRepo.GetAgentThoughtByMessage(c, messageID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, agentThought := range agentThoughts {
+		approval, ok := agentThought.ToolApprovals[args.ToolCallID]
+
+		if !ok || approval.Status != po_agent.TOOL_APPROVAL_PENDING {
+			continue
+		}
+
+		approval.Status = po_agent.TOOL_APPROVAL_APPROVED
+
+		if args.Action == AGENT_APPROVAL_REJECT {
+			approval.Status = po_agent.TOOL_APPROVAL_REJECTED
+		}
+
+		approval.DecidedBy = userID
+		approval.DecidedAt = time.Now().UTC().Unix()
+
+		if err := acg.agentDomain.AgentRepo.UpdateAgentThought(c, agentThought); err != nil {
+			return nil, err
+		}
+
+		return agentThought, nil
+	}
+
+	return nil, errors.WithCode(code.ErrAgentApprovalNotPending, "tool call %s of message %s is not waiting for approval", args.ToolCallID, messageID)
+}
+
+func (g *AgentChatGenerator) generateGoRoutine(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, conversationID string, messageID string, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, taskPipeline app_agent_chat_runner.IAgentChatAppTaskScheduler, flusher biz_entity.AgentFlusher, resumeThought *po_agent.MessageAgentThought) {
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -236,9 +395,24 @@ func (g *AgentChatGenerator) generateGoRoutine(ctx context.Context, applicationG
 		return
 	}
 
+	if resumeThought != nil {
+		appRunner.Resume(ctx, applicationGenerateEntity, message, conversation, queueManager, taskPipeline, flusher, g.appConfig, resumeThought)
+		return
+	}
+
 	appRunner.Run(ctx, applicationGenerateEntity, message, conversation, queueManager, taskPipeline, flusher, g.appConfig)
 }
 
+// recordSpend adds the price of every round run by the scheduler to the budgets of the app, the rounds before
+// the pause for approvals are recorded by the scheduler which paused.
+func (g *AgentChatGenerator) recordSpend(c context.Context, appModel *po_entity.App, messageID string, taskScheduler app_agent_chat_runner.IAgentChatAppTaskScheduler) {
+	price, currency := taskScheduler.Spend()
+
+	if err := g.BudgetDomain.RecordSpend(c, appModel.TenantID, appModel.ID, price, currency); err != nil {
+		log.Errorf("failed to record spend of message %s: %v", messageID, err)
+	}
+}
+
 func (g *AgentChatGenerator) ListenQueue(queueManager biz_entity_base_stream_generator.IStreamGenerateQueue) {
 	queueManager.Listen()
 }
@@ -19,9 +19,11 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_config/app_model_config"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_runner/app_chat_runner"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/task_pipeline"
+	"github.com/lunarianss/Luna/internal/api-server/core/structured_output"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
 	biz_entity_app_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_chat_generator"
@@ -48,16 +50,18 @@ type ChatAppGenerator struct {
 	ProviderDomain *domain_service.ProviderDomain
 	chatDomain     *chatDomain.ChatDomain
 	DatasetDomain  *datasetDomain.DatasetDomain
+	BudgetDomain   *budgetDomain.BudgetDomain
 	redis          *redis.Client
 }
 
-func NewChatAppGenerator(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, redis *redis.Client) *ChatAppGenerator {
+func NewChatAppGenerator(appDomain *appDomain.AppDomain, providerDomain *domain_service.ProviderDomain, chatDomain *chatDomain.ChatDomain, datasetDomain *datasetDomain.DatasetDomain, budgetDomain *budgetDomain.BudgetDomain, redis *redis.Client) *ChatAppGenerator {
 
 	return &ChatAppGenerator{
 		AppDomain:      appDomain,
 		ProviderDomain: providerDomain,
 		chatDomain:     chatDomain,
 		DatasetDomain:  datasetDomain,
+		BudgetDomain:   budgetDomain,
 		redis:          redis,
 	}
 
@@ -100,6 +104,10 @@ func (g *ChatAppGenerator) baseGenerate(c context.Context, appModel *po_entity.A
 		extras["auto_generate_conversation_name"] = args.AutoGenerateConversationName
 	}
 
+	if err := g.BudgetDomain.CheckBudget(c, appModel.TenantID, appModel.ID); err != nil {
+		return nil, nil, nil, err
+	}
+
 	if args.ConversationID != "" {
 		conversationRecord, err = g.chatDomain.MessageRepo.GetConversationByUser(c, appModel.ID, args.ConversationID, user)
 
@@ -191,13 +199,65 @@ func (g *ChatAppGenerator) GenerateNonStream(c context.Context, appModel *po_ent
 		return nil, err
 	}
 
-	err = task_pipeline.NewNonStreamTaskPipeline(applicationGenerateEntity, g.chatDomain.MessageRepo, messageRecord, llmResult, nil).ProcessNonStream(c)
+	structuredOutput, llmResult, err := g.validateStructuredOutput(c, applicationGenerateEntity, llmResult)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = task_pipeline.NewNonStreamTaskPipeline(applicationGenerateEntity, g.chatDomain.MessageRepo, messageRecord, llmResult, nil, g.ProviderDomain).ProcessNonStream(c)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return assembler.CovertToServiceChatCompletionResponse(messageRecord, conversationRecord.ID, llmResult), nil
+	g.recordSpend(c, appModel, messageRecord.ID)
+
+	response := assembler.CovertToServiceChatCompletionResponse(messageRecord, conversationRecord.ID, llmResult)
+	response.StructuredOutput = structuredOutput
+
+	return response, nil
+}
+
+// validateStructuredOutput validates the answer against the json schema of the structured output, the model is asked
+// to repair the answer up to MAX_REPAIR_ATTEMPTS times and the usage of the repairs is added to the result. The
+// results which are not generated by the model (the preset response of moderation or the annotation reply) are
+// returned as they are.
+func (g *ChatAppGenerator) validateStructuredOutput(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, llmResult *biz_entity_base_stream_generator.LLMResult) (interface{}, *biz_entity_base_stream_generator.LLMResult, error) {
+	schema := applicationGenerateEntity.AppConfig.StructuredOutput
+
+	if schema == nil || llmResult.Provider == "" {
+		return nil, llmResult, nil
+	}
+
+	appRunner := app_chat_runner.NewAppChatRunner(app_chat_runner.NewAppBaseChatRunner(), g.AppDomain, g.chatDomain, g.ProviderDomain, g.DatasetDomain, g.redis)
+
+	for attempt := 0; ; attempt++ {
+		answer, _ := llmResult.Message.Content.(string)
+
+		structuredOutput, err := structured_output.Parse(answer, schema.Schema)
+
+		if err == nil {
+			return structuredOutput, llmResult, nil
+		}
+
+		if attempt == structured_output.MAX_REPAIR_ATTEMPTS {
+			return nil, nil, errors.WrapC(err, code.ErrStructuredOutputInvalid, "answer doesn't conform to the json schema of %s after %d repairs: %s", schema.Name, attempt, err.Error())
+		}
+
+		log.Warnf("repair the answer of the structured output %s: %s", schema.Name, err.Error())
+
+		repairedResult, err := appRunner.RepairNonStream(ctx, applicationGenerateEntity, llmResult, err)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		repairedResult.PromptMessage = llmResult.PromptMessage
+		repairedResult.Usage = llmResult.Usage.Plus(repairedResult.Usage)
+		repairedResult.Fallbacks = append(llmResult.Fallbacks, repairedResult.Fallbacks...)
+		llmResult = repairedResult
+	}
 }
 
 func (g *ChatAppGenerator) Generate(c context.Context, appModel *po_entity.App, user repository.BaseAccount, args *dto.CreateChatMessageBody, invokeFrom biz_entity_app_generate.InvokeFrom, stream bool) error {
@@ -220,13 +280,29 @@ func (g *ChatAppGenerator) Generate(c context.Context, appModel *po_entity.App,
 
 	go g.ListenQueue(queueManager)
 
-	task_pipeline.NewChatAppTaskPipeline(applicationGenerateEntity, streamResultChunkQueue, streamFinalChunkQueue, g.chatDomain.MessageRepo, messageRecord, g.chatDomain.AnnotationRepo).Process(c)
+	task_pipeline.NewChatAppTaskPipeline(applicationGenerateEntity, streamResultChunkQueue, streamFinalChunkQueue, g.chatDomain.MessageRepo, messageRecord, g.chatDomain.AnnotationRepo, g.ProviderDomain).Process(c)
+
+	g.recordSpend(c, appModel, messageRecord.ID)
 
 	// queueManager.Debug()
 
 	return nil
 }
 
+// recordSpend adds the price of the saved message to the budgets of the app.
+func (g *ChatAppGenerator) recordSpend(c context.Context, appModel *po_entity.App, messageID string) {
+	messageRecord, err := g.chatDomain.MessageRepo.GetMessageByID(c, messageID)
+
+	if err != nil {
+		log.Errorf("failed to get message %s to record spend: %v", messageID, err)
+		return
+	}
+
+	if err := g.BudgetDomain.RecordSpend(c, appModel.TenantID, appModel.ID, messageRecord.TotalPrice, messageRecord.Currency); err != nil {
+		log.Errorf("failed to record spend of message %s: %v", messageID, err)
+	}
+}
+
 func (g *ChatAppGenerator) ListenQueue(queueManager biz_entity_base_stream_generator.IStreamGenerateQueue) {
 	queueManager.Listen()
 }
@@ -11,14 +11,24 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/token_buffer_memory"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
 	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+const (
+	// DEFAULT_REST_TOKENS is the token limit of the histories when the context size of the model is unknown
+	DEFAULT_REST_TOKENS  = 2000
+	MAX_TOKENS_PARAMETER = "max_tokens"
 )
 
 type SimplePromptTransform struct {
@@ -73,7 +83,7 @@ func (s *SimplePromptTransform) GetChatModelPromptMessage(appMode po_entity.AppM
 	}
 
 	if memory != nil {
-		promptMessages, err = s.appendChatHistories(context.TODO(), memory, promptMessages)
+		promptMessages, err = s.appendChatHistories(context.TODO(), memory, promptMessages, s.calculateRestTokens(append(promptMessages, s.GetLastUserMessage(query, nil)), modelConfig))
 		if err != nil {
 			return nil, nil, err
 		}
@@ -200,9 +210,9 @@ func (s *SimplePromptTransform) promptFileName(appMode po_entity.AppMode, _, _ s
 	}
 }
 
-func (s *SimplePromptTransform) appendChatHistories(ctx context.Context, memory token_buffer_memory.ITokenBufferMemory, ps []*biz_entity_chat_prompt_message.PromptMessage) ([]*biz_entity_chat_prompt_message.PromptMessage, error) {
+func (s *SimplePromptTransform) appendChatHistories(ctx context.Context, memory token_buffer_memory.ITokenBufferMemory, ps []*biz_entity_chat_prompt_message.PromptMessage, restTokens int) ([]*biz_entity_chat_prompt_message.PromptMessage, error) {
 
-	msgs, err := memory.GetHistoryPromptMessage(ctx, 2000, 0)
+	msgs, err := memory.GetHistoryPromptMessage(ctx, restTokens, 0)
 
 	if err != nil {
 		return nil, err
@@ -210,3 +220,46 @@ func (s *SimplePromptTransform) appendChatHistories(ctx context.Context, memory
 	ps = append(ps, msgs...)
 	return ps, nil
 }
+
+// calculateRestTokens returns the tokens left for the histories, which is the context size of the model minus the
+// tokens reserved for the completion and the tokens of the current prompt messages.
+func (s *SimplePromptTransform) calculateRestTokens(promptMessages []*biz_entity_chat_prompt_message.PromptMessage, modelConfig *biz_entity_provider_config.ModelConfigWithCredentialsEntity) int {
+	if modelConfig.ModelSchema == nil || modelConfig.ModelSchema.ProviderModel == nil {
+		return DEFAULT_REST_TOKENS
+	}
+
+	contextSize := toInt(modelConfig.ModelSchema.ModelProperties[common.CONTEXT_SIZE])
+
+	if contextSize <= 0 {
+		return DEFAULT_REST_TOKENS
+	}
+
+	var maxTokens int
+
+	for _, parameterRule := range modelConfig.ModelSchema.ParameterRules {
+		if parameterRule.Name == MAX_TOKENS_PARAMETER || parameterRule.UseTemplate == MAX_TOKENS_PARAMETER {
+			maxTokens = toInt(modelConfig.Parameters[parameterRule.Name])
+		}
+	}
+
+	currentTokens := model_registry.GetNumTokens(model_registry.GetLLMTokenizer(modelConfig.Provider, modelConfig.Model), util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
+		return pm
+	}), nil)
+
+	return max(contextSize-maxTokens-currentTokens, 0)
+}
+
+func toInt(v any) int {
+	switch v := v.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case string:
+		i, _ := strconv.Atoi(v)
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const TOOL_CALL_REJECTED = "the user rejected the call of tool %s, go on without it"
+
+// agentApprovalState is the state of the runner paused for the approvals of the tool calls, it is saved with the
+// agent thought of the round and restored when the approvals are decided.
+type agentApprovalState struct {
+	Strategy          biz_entity_app_config.Strategy `json:"strategy"`
+	InteractionStep   int                            `json:"interaction_step"`
+	AgentThoughtCount int                            `json:"agent_thought_count"`
+	PromptMessages    []*approvalPromptMessage       `json:"prompt_messages"`
+	AssistantThoughts []*approvalPromptMessage       `json:"assistant_thoughts,omitempty"`
+	Stop              []string                       `json:"stop,omitempty"`
+	Scratchpad        string                         `json:"scratchpad,omitempty"`
+	Thought           string                         `json:"thought,omitempty"`
+	ToolCalls         []*ToolCall                    `json:"tool_calls"`
+}
+
+// approvalPromptMessage is a prompt message of any role as it is saved in the approval state.
+type approvalPromptMessage struct {
+	Role       biz_entity_chat_prompt_message.PromptMessageRole `json:"role"`
+	Content    any                                              `json:"content,omitempty"`
+	Name       string                                           `json:"name,omitempty"`
+	ToolCalls  []*biz_entity_openai_standard_response.ToolCall  `json:"tool_calls,omitempty"`
+	ToolCallID string                                           `json:"tool_call_id,omitempty"`
+}
+
+func encodePromptMessages(promptMessages []biz_entity_chat_prompt_message.IPromptMessage) []*approvalPromptMessage {
+	encoded := make([]*approvalPromptMessage, 0, len(promptMessages))
+
+	for _, promptMessage := range promptMessages {
+		switch pm := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			encoded = append(encoded, &approvalPromptMessage{Role: pm.Role, Content: pm.Content, Name: pm.Name, ToolCalls: pm.ToolCalls})
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			encoded = append(encoded, &approvalPromptMessage{Role: pm.Role, Content: pm.Content, Name: pm.Name, ToolCallID: pm.ToolCallID})
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			encoded = append(encoded, &approvalPromptMessage{Role: pm.Role, Content: pm.Content, Name: pm.Name})
+		}
+	}
+
+	return encoded
+}
+
+// decodePromptMessages restores the prompt messages of the approval state, the contents which were not a string
+// are the contents of the vision messages.
+func decodePromptMessages(encoded []*approvalPromptMessage) ([]biz_entity_chat_prompt_message.IPromptMessage, error) {
+	promptMessages := make([]biz_entity_chat_prompt_message.IPromptMessage, 0, len(encoded))
+
+	for _, em := range encoded {
+		promptMessage := &biz_entity_chat_prompt_message.PromptMessage{Role: em.Role, Content: em.Content, Name: em.Name}
+
+		switch content := em.Content.(type) {
+		case nil:
+			promptMessage.Content = ""
+		case []any:
+			var messageContents []*biz_entity_chat_prompt_message.PromptMessageContent
+
+			contentBytes, err := json.Marshal(content)
+
+			if err != nil {
+				return nil, errors.WithSCode(code.ErrAgentApprovalState, err.Error())
+			}
+
+			if err := json.Unmarshal(contentBytes, &messageContents); err != nil {
+				return nil, errors.WithSCode(code.ErrAgentApprovalState, err.Error())
+			}
+
+			promptMessage.Content = messageContents
+		}
+
+		switch {
+		case em.Role == biz_entity_chat_prompt_message.ASSISTANT && len(em.ToolCalls) > 0:
+			promptMessages = append(promptMessages, &biz_entity_chat_prompt_message.AssistantPromptMessage{PromptMessage: promptMessage, ToolCalls: em.ToolCalls})
+		case em.Role == biz_entity_chat_prompt_message.TOOL:
+			promptMessages = append(promptMessages, &biz_entity_chat_prompt_message.ToolPromptMessage{PromptMessage: promptMessage, ToolCallID: em.ToolCallID})
+		default:
+			promptMessages = append(promptMessages, promptMessage)
+		}
+	}
+
+	return promptMessages, nil
+}
+
+// decodeApprovalState decodes the state saved with the agent thought paused for the approvals.
+func decodeApprovalState(agentThought *po_agent.MessageAgentThought) (*agentApprovalState, error) {
+	var state agentApprovalState
+
+	if agentThought.ApprovalState == "" {
+		return nil, errors.WithCode(code.ErrAgentApprovalState, "agent thought %s is not paused for approvals", agentThought.ID)
+	}
+
+	if err := json.Unmarshal([]byte(agentThought.ApprovalState), &state); err != nil {
+		return nil, errors.WithSCode(code.ErrAgentApprovalState, err.Error())
+	}
+
+	if len(state.ToolCalls) == 0 {
+		return nil, errors.WithCode(code.ErrAgentApprovalState, "agent thought %s has no tool calls to resume", agentThought.ID)
+	}
+
+	return &state, nil
+}
+
+// requiresApproval reports whether the tool is configured to be approved before it is invoked.
+func (br *BaseAgentRunner) requiresApproval(toolName string) bool {
+	if br.appConfig.AgentEntity == nil {
+		return false
+	}
+
+	for _, tool := range br.appConfig.AgentEntity.Tools {
+		if tool.ToolName == toolName {
+			return tool.RequiresApproval
+		}
+	}
+
+	return false
+}
+
+// pauseForApproval pauses the runner when a tool call of the round requires approval and is not decided yet, the
+// state of the runner is saved with the agent thought and the stream ends with the approval required event.
+func (br *BaseAgentRunner) pauseForApproval(ctx context.Context, agentThought *po_agent.MessageAgentThought, toolCalls []*ToolCall, state *agentApprovalState) (bool, error) {
+	for _, toolCall := range toolCalls {
+		if _, ok := agentThought.ToolApprovals[toolCall.ToolCallID]; ok || !br.requiresApproval(toolCall.ToolCallName) {
+			continue
+		}
+
+		if agentThought.ToolApprovals == nil {
+			agentThought.ToolApprovals = make(map[string]*po_agent.ToolApproval)
+		}
+
+		agentThought.ToolApprovals[toolCall.ToolCallID] = &po_agent.ToolApproval{
+			ToolName:  toolCall.ToolCallName,
+			ToolInput: toolCall.TollCallArgs,
+			Status:    po_agent.TOOL_APPROVAL_PENDING,
+		}
+	}
+
+	if len(agentThought.PendingApprovals()) == 0 {
+		return false, nil
+	}
+
+	state.InteractionStep = br.interactionStep
+	state.AgentThoughtCount = br.agentThoughtCount
+	state.ToolCalls = toolCalls
+
+	encoded, err := json.Marshal(state)
+
+	if err != nil {
+		return false, errors.WithSCode(code.ErrAgentApprovalState, err.Error())
+	}
+
+	agentThought.ApprovalState = string(encoded)
+
+	if err := br.agentDomain.UpdateAgentThought(ctx, agentThought); err != nil {
+		return false, err
+	}
+
+	if err := br.agentFlusher.AgentApprovalRequiredToStreamResponse(ctx, agentThought.ID); err != nil {
+		return false, err
+	}
+
+	br.paused = true
+	return true, nil
+}
+
+// invokeApprovedToolCalls invokes the tool calls of the round except the rejected ones, which are answered with the
+// rejection for the model to go on without them.
+func (br *BaseAgentRunner) invokeApprovedToolCalls(ctx context.Context, agentThought *po_agent.MessageAgentThought, toolCalls []*ToolCall) ([]*biz_agent.ToolArtifact, []string, error) {
+	var approvedToolCalls []*ToolCall
+
+	rejected := func(toolCall *ToolCall) bool {
+		approval, ok := agentThought.ToolApprovals[toolCall.ToolCallID]
+		return ok && approval.Status == po_agent.TOOL_APPROVAL_REJECTED
+	}
+
+	for _, toolCall := range toolCalls {
+		if !rejected(toolCall) {
+			approvedToolCalls = append(approvedToolCalls, toolCall)
+		}
+	}
+
+	approvedArtifacts, messageFileIDs, err := br.invokeToolCalls(ctx, approvedToolCalls)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	toolArtifacts := make([]*biz_agent.ToolArtifact, 0, len(toolCalls))
+
+	for _, toolCall := range toolCalls {
+		if rejected(toolCall) {
+			toolArtifacts = append(toolArtifacts, toolErrorArtifact(toolCall, fmt.Sprintf(TOOL_CALL_REJECTED, toolCall.ToolCallName)))
+			continue
+		}
+
+		toolArtifacts = append(toolArtifacts, approvedArtifacts[0])
+		approvedArtifacts = approvedArtifacts[1:]
+	}
+
+	return toolArtifacts, messageFileIDs, nil
+}
+
+// resumeFrom restores the rounds of the runner paused at the agent thought, the tool calls of the thought are
+// invoked first when the runner runs.
+func (br *BaseAgentRunner) resumeFrom(state *agentApprovalState, agentThought *po_agent.MessageAgentThought) {
+	br.interactionStep = state.InteractionStep
+	br.agentThoughtCount = state.AgentThoughtCount
+	br.resumeState = state
+	br.resumeThought = agentThought
+}
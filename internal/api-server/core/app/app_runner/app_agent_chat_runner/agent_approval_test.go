@@ -0,0 +1,95 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+)
+
+func TestApprovalStatePromptMessages(t *testing.T) {
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("be helpful"),
+		biz_entity_chat_prompt_message.NewUserMessage([]*biz_entity_chat_prompt_message.PromptMessageContent{{Type: biz_entity_chat_prompt_message.TEXT, Data: "what is in it"}}),
+		&biz_entity_chat_prompt_message.AssistantPromptMessage{
+			PromptMessage: biz_entity_chat_prompt_message.NewAssistantMessage(""),
+			ToolCalls: []*biz_entity_openai_standard_response.ToolCall{{
+				ID:       "call-0",
+				Type:     "function",
+				Function: &biz_entity_openai_standard_response.ToolCallFunction{Name: "send", Arguments: `{"text":"hi"}`},
+			}},
+		},
+		&biz_entity_chat_prompt_message.ToolPromptMessage{
+			PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{Role: biz_entity_chat_prompt_message.TOOL, Content: "sent", Name: "send"},
+			ToolCallID:    "call-0",
+		},
+	}
+
+	encoded, err := json.Marshal(encodePromptMessages(promptMessages))
+
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var stored []*approvalPromptMessage
+
+	if err := json.Unmarshal(encoded, &stored); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+
+	restored, err := decodePromptMessages(stored)
+
+	if err != nil {
+		t.Fatalf("decodePromptMessages() error = %v", err)
+	}
+
+	for i, promptMessage := range promptMessages {
+		wantData, _ := promptMessage.ConvertToRequestData()
+		gotData, err := restored[i].ConvertToRequestData()
+
+		want, _ := json.Marshal(wantData)
+		got, _ := json.Marshal(gotData)
+
+		if err != nil || reflect.TypeOf(restored[i]) != reflect.TypeOf(promptMessage) || string(got) != string(want) {
+			t.Errorf("prompt message %d restored as %T %s, %v, want %T %s", i, restored[i], got, err, promptMessage, want)
+		}
+	}
+}
+
+func TestInvokeApprovedToolCalls(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(&sleepTool{})
+
+	toolCalls := sleepToolCalls(5, 5, 5)
+	agentThought := &po_agent.MessageAgentThought{
+		ToolApprovals: map[string]*po_agent.ToolApproval{
+			"call-0": {ToolName: "sleep", Status: po_agent.TOOL_APPROVAL_APPROVED},
+			"call-1": {ToolName: "sleep", Status: po_agent.TOOL_APPROVAL_REJECTED},
+		},
+	}
+
+	toolArtifacts, _, err := testAgentRunner(common.MULTI_TOOL_CALL).invokeApprovedToolCalls(context.Background(), agentThought, toolCalls)
+
+	if err != nil {
+		t.Fatalf("invokeApprovedToolCalls() error = %v", err)
+	}
+
+	for i, want := range []string{"slept 5", fmt.Sprintf(TOOL_CALL_REJECTED, "sleep"), "slept 5"} {
+		if toolArtifacts[i].ToolCallID != toolCalls[i].ToolCallID || toolArtifacts[i].ToolResponse != want {
+			t.Errorf("artifact %d = %+v, want %q", i, toolArtifacts[i], want)
+		}
+	}
+}
@@ -16,6 +16,7 @@ import (
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
 	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
 	"github.com/lunarianss/Luna/internal/api-server/domain/chat/repository"
+	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
 	"github.com/lunarianss/Luna/internal/infrastructure/util"
@@ -23,7 +24,8 @@ import (
 
 type IAgentChatAppTaskScheduler interface {
 	Process(ctx context.Context)
-	SetFunctionCallRunner(*FunctionCallAgentRunner)
+	SetAgentRunner(IAgentRunner)
+	Spend() (float64, string)
 }
 
 type agentChatAppTaskScheduler struct {
@@ -35,21 +37,23 @@ type agentChatAppTaskScheduler struct {
 	MessageRepo            repository.MessageRepo
 	AnnotationRepo         repository.AnnotationRepo
 	AgentRepo              repo_agent.AgentRepo
+	ProviderDomain         *providerDomain.ProviderDomain
 
 	flusher   http.Flusher
 	sender    io.Writer
 	taskState *biz_entity_base_stream_generator.ChatAppTaskState
-	runner    *FunctionCallAgentRunner
+	runner    IAgentRunner
 }
 
 func NewAgentChatAppTaskScheduler(
 	applicationGenerateEntity biz_entity_app_generate.BasedAppGenerateEntity,
-	messageRepo repository.MessageRepo, message *po_entity.Message, annotationRepo repository.AnnotationRepo, runner *FunctionCallAgentRunner) *agentChatAppTaskScheduler {
+	messageRepo repository.MessageRepo, message *po_entity.Message, annotationRepo repository.AnnotationRepo, providerDomain *providerDomain.ProviderDomain, runner IAgentRunner) *agentChatAppTaskScheduler {
 	return &agentChatAppTaskScheduler{
 		BasedAppGenerateEntity: applicationGenerateEntity,
 		Message:                message,
 		MessageRepo:            messageRepo,
 		AnnotationRepo:         annotationRepo,
+		ProviderDomain:         providerDomain,
 		runner:                 runner,
 		taskState: &biz_entity_base_stream_generator.ChatAppTaskState{
 			LLMResult: biz_entity_base_stream_generator.NewEmptyLLMResult(),
@@ -57,7 +61,7 @@ func NewAgentChatAppTaskScheduler(
 	}
 }
 
-func (tpp *agentChatAppTaskScheduler) SetFunctionCallRunner(runner *FunctionCallAgentRunner) {
+func (tpp *agentChatAppTaskScheduler) SetAgentRunner(runner IAgentRunner) {
 	tpp.runner = runner
 }
 
@@ -78,6 +82,15 @@ func (tpp *agentChatAppTaskScheduler) Process(ctx context.Context) {
 		return
 	}
 
+	// the stream ends with the approval required event, the message is saved once the conversation resumes
+	if tpp.runner.Paused() {
+		if err := tpp.saveSpend(ctx); err != nil {
+			log.Errorf("failed to save spend of the paused message: %v", err)
+		}
+		tpp.deductQuota(ctx)
+		return
+	}
+
 	if err := tpp.saveMessage(ctx); err != nil {
 		log.Errorf("failed to save message: %v", err)
 		return
@@ -131,6 +144,10 @@ func (tpp *agentChatAppTaskScheduler) messageErrToStreamResponse(ctx context.Con
 		errStr = "Your quota for Luna Hosted Model Provider has been exhausted. Please go to Settings -> Model Provider to complete your own provider credentials."
 	}
 
+	if errors.IsCode(err, code.ErrProviderQuotaExceed) {
+		errStr = "Your quota of the model provider has been exhausted. Please contact the administrator of the workspace to reset the quota."
+	}
+
 	messageRecord, err := tpp.MessageRepo.GetMessageByID(ctx, tpp.Message.ID)
 
 	if err != nil {
@@ -226,13 +243,93 @@ func (tpp *agentChatAppTaskScheduler) saveMessage(c context.Context) error {
 	messageRecord.AnswerTokens = tpp.taskState.LLMResult.Usage.CompletionTokens
 	messageRecord.AnswerPriceUnit = tpp.taskState.LLMResult.Usage.CompletionPriceUnit
 	messageRecord.AnswerUnitPrice = tpp.taskState.LLMResult.Usage.CompletionUnitPrice
-	messageRecord.TotalPrice = tpp.taskState.LLMResult.Usage.TotalPrice
-	messageRecord.Currency = tpp.taskState.LLMResult.Usage.Currency
+	tpp.addSpend(messageRecord)
+
+	// the model actually used differs from the app model config when the fallback chain was gone down
+	if tpp.taskState.LLMResult.Provider != "" {
+		messageRecord.ModelProvider = tpp.taskState.LLMResult.Provider
+		messageRecord.ModelID = tpp.taskState.LLMResult.Model
+	}
+
+	if len(tpp.taskState.LLMResult.Fallbacks) > 0 {
+		if tpp.taskState.Metadata == nil {
+			tpp.taskState.Metadata = make(map[string]interface{})
+		}
+		tpp.taskState.Metadata["fallbacks"] = tpp.taskState.LLMResult.Fallbacks
+	}
+
 	messageRecord.MessageMetadata = tpp.taskState.Metadata
 
 	if err := tpp.MessageRepo.UpdateMessage(c, messageRecord); err != nil {
 		return err
 	}
 
+	tpp.deductQuota(c)
+
 	return nil
 }
+
+// Spend returns the price of the rounds run by the scheduler, the message resumed after the approvals is run by
+// several schedulers and each of them spends its own rounds.
+func (tpp *agentChatAppTaskScheduler) Spend() (float64, string) {
+	var (
+		price    float64
+		currency string
+	)
+
+	if tpp.runner == nil {
+		return 0, ""
+	}
+
+	for _, llmResult := range tpp.runner.LLMResults() {
+		if !llmResult.Generated() || llmResult.Usage == nil {
+			continue
+		}
+
+		price += llmResult.Usage.TotalPrice
+
+		if currency == "" {
+			currency = llmResult.Usage.Currency
+		}
+	}
+
+	return price, currency
+}
+
+// addSpend adds the spend of the rounds to the price of the message, which carries the rounds before the pause.
+func (tpp *agentChatAppTaskScheduler) addSpend(messageRecord *po_entity.Message) {
+	price, currency := tpp.Spend()
+
+	messageRecord.TotalPrice += price
+
+	if currency != "" {
+		messageRecord.Currency = currency
+	}
+}
+
+// saveSpend saves the spend of the rounds before the pause, the budgets sum the prices of the saved messages.
+func (tpp *agentChatAppTaskScheduler) saveSpend(c context.Context) error {
+	messageRecord, err := tpp.MessageRepo.GetMessageByID(c, tpp.Message.ID)
+
+	if err != nil {
+		return err
+	}
+
+	tpp.addSpend(messageRecord)
+
+	return tpp.MessageRepo.UpdateMessage(c, messageRecord)
+}
+
+// deductQuota deducts every round of the model calls from the quota of the provider which served it, the cached
+// rounds didn't call the model.
+func (tpp *agentChatAppTaskScheduler) deductQuota(c context.Context) {
+	for _, llmResult := range tpp.runner.LLMResults() {
+		if !llmResult.Generated() {
+			continue
+		}
+
+		if err := tpp.ProviderDomain.DeductQuota(c, tpp.GetTenantID(), llmResult.Provider, llmResult.Model, llmResult.Usage.TotalTokens); err != nil {
+			log.Errorf("failed to deduct quota of provider %s: %v", llmResult.Provider, err)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	po_chat "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	repo_chat "github.com/lunarianss/Luna/internal/api-server/domain/chat/repository"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	po_provider "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	repo_provider "github.com/lunarianss/Luna/internal/api-server/domain/provider/repository"
+)
+
+type fakeGenerateEntity struct {
+	biz_entity_app_generate.BasedAppGenerateEntity
+}
+
+func (e *fakeGenerateEntity) GetTenantID() string {
+	return "tenant-1"
+}
+
+func (e *fakeGenerateEntity) GetTaskID() string {
+	return "task-1"
+}
+
+func (e *fakeGenerateEntity) GetConversationID() string {
+	return "conversation-1"
+}
+
+type fakeAgentRunner struct {
+	IAgentRunner
+	llmResults []*biz_entity_base_stream_generator.LLMResult
+	paused     bool
+}
+
+func (r *fakeAgentRunner) Run(ctx context.Context, message *po_chat.Message, query string) (*biz_entity_base_stream_generator.ChatAppTaskState, error) {
+	return &biz_entity_base_stream_generator.ChatAppTaskState{LLMResult: r.llmResults[len(r.llmResults)-1]}, nil
+}
+
+func (r *fakeAgentRunner) LLMResults() []*biz_entity_base_stream_generator.LLMResult {
+	return r.llmResults
+}
+
+func (r *fakeAgentRunner) Paused() bool {
+	return r.paused
+}
+
+type fakeMessageRepo struct {
+	repo_chat.MessageRepo
+	message po_chat.Message
+}
+
+func (mr *fakeMessageRepo) GetMessageByID(ctx context.Context, messageID string) (*po_chat.Message, error) {
+	message := mr.message
+	return &message, nil
+}
+
+func (mr *fakeMessageRepo) UpdateMessage(ctx context.Context, message *po_chat.Message) error {
+	mr.message = *message
+	return nil
+}
+
+func pricedRound(price float64, cacheHit bool) *biz_entity_base_stream_generator.LLMResult {
+	usage := biz_entity_base_stream_generator.NewEmptyLLMUsage()
+	usage.TotalPrice, usage.Currency = price, "USD"
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		Provider:      "openai",
+		Model:         "gpt-4o",
+		CacheHit:      cacheHit,
+		Message:       biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("answer"),
+		PromptMessage: make([]biz_entity_chat_prompt_message.IPromptMessage, 0),
+		Usage:         usage,
+	}
+}
+
+// processLeg runs the scheduler of a leg of the message, which is paused for approvals or saved at the end.
+func processLeg(t *testing.T, messageRepo *fakeMessageRepo, runner *fakeAgentRunner) *agentChatAppTaskScheduler {
+	t.Helper()
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	tpp := NewAgentChatAppTaskScheduler(&fakeGenerateEntity{}, messageRepo, &po_chat.Message{ID: "message-1"}, nil, &domain_service.ProviderDomain{ProviderRepo: &fakeQuotaProviderRepo{}}, runner)
+
+	tpp.Process(ctx)
+
+	return tpp
+}
+
+type fakeQuotaProviderRepo struct {
+	repo_provider.ProviderRepo
+	used []string
+}
+
+func (pr *fakeQuotaProviderRepo) GetTenantProvider(ctx context.Context, tenant string, providerName string, providerType string) (*po_provider.Provider, error) {
+	quotaLimit := int64(10)
+
+	return &po_provider.Provider{
+		ProviderName: providerName,
+		ProviderType: string(po_provider.CUSTOM),
+		QuotaType:    string(po_provider.TRIAL),
+		QuotaUnit:    string(biz_entity_provider_config.TIMES),
+		QuotaLimit:   &quotaLimit,
+	}, nil
+}
+
+func (pr *fakeQuotaProviderRepo) IncreaseProviderQuotaUsed(ctx context.Context, tenantID string, providerName string, quotaType string, used int64) error {
+	pr.used = append(pr.used, providerName)
+	return nil
+}
+
+func TestAgentDeductQuota(t *testing.T) {
+	providerRepo := &fakeQuotaProviderRepo{}
+
+	tpp := NewAgentChatAppTaskScheduler(&fakeGenerateEntity{}, nil, nil, nil, &domain_service.ProviderDomain{ProviderRepo: providerRepo}, &fakeAgentRunner{
+		llmResults: []*biz_entity_base_stream_generator.LLMResult{
+			{Provider: "openai", Model: "gpt-4o", Usage: biz_entity_base_stream_generator.NewEmptyLLMUsage()},
+			{Provider: "openai", Model: "gpt-4o", CacheHit: true, Usage: biz_entity_base_stream_generator.NewEmptyLLMUsage()},
+			{Provider: "anthropic", Model: "claude-3-haiku-20240307", Usage: biz_entity_base_stream_generator.NewEmptyLLMUsage()},
+		},
+	})
+
+	tpp.deductQuota(context.Background())
+
+	// the cached round didn't call the model
+	if len(providerRepo.used) != 2 || providerRepo.used[0] != "openai" || providerRepo.used[1] != "anthropic" {
+		t.Errorf("deducted the quota of %v, want the generated rounds", providerRepo.used)
+	}
+}
+
+func TestAgentSpend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	messageRepo := &fakeMessageRepo{message: po_chat.Message{ID: "message-1"}}
+
+	tpp := processLeg(t, messageRepo, &fakeAgentRunner{llmResults: []*biz_entity_base_stream_generator.LLMResult{pricedRound(0.25, false), pricedRound(0.5, false)}})
+
+	// every round of the message is spent, not only the last one
+	if price, currency := tpp.Spend(); price != 0.75 || currency != "USD" {
+		t.Errorf("Spend() = %v %s of two rounds, want 0.75 USD", price, currency)
+	}
+
+	if messageRepo.message.TotalPrice != 0.75 || messageRepo.message.Currency != "USD" {
+		t.Errorf("saved price = %v %s, want 0.75 USD", messageRepo.message.TotalPrice, messageRepo.message.Currency)
+	}
+
+	messageRepo = &fakeMessageRepo{message: po_chat.Message{ID: "message-1"}}
+
+	paused := processLeg(t, messageRepo, &fakeAgentRunner{llmResults: []*biz_entity_base_stream_generator.LLMResult{pricedRound(0.25, false), pricedRound(0.5, false)}, paused: true})
+
+	if price, _ := paused.Spend(); price != 0.75 {
+		t.Errorf("Spend() = %v before the pause, want 0.75", price)
+	}
+
+	if messageRepo.message.TotalPrice != 0.75 {
+		t.Errorf("saved price = %v of the paused message, want 0.75", messageRepo.message.TotalPrice)
+	}
+
+	// the cached round of the resumed leg costs nothing
+	resumed := processLeg(t, messageRepo, &fakeAgentRunner{llmResults: []*biz_entity_base_stream_generator.LLMResult{pricedRound(2, true), pricedRound(1, false)}})
+
+	if price, _ := resumed.Spend(); price != 1 {
+		t.Errorf("Spend() = %v after the resume, want 1", price)
+	}
+
+	if messageRepo.message.TotalPrice != 1.75 {
+		t.Errorf("saved price = %v of the resumed message, want 1.75 of both legs", messageRepo.message.TotalPrice)
+	}
+}
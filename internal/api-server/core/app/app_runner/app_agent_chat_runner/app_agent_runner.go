@@ -9,10 +9,13 @@ import (
 
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_feature"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/token_buffer_memory"
+	"github.com/lunarianss/Luna/internal/api-server/core/moderation"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/mcp"
 	"github.com/lunarianss/Luna/internal/infrastructure/util"
 
 	agentDomain "github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	biz_entity_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
@@ -95,9 +98,11 @@ func (r *appAgentChatRunner) baseRun(ctx context.Context, applicationGenerateEnt
 		return nil, nil, nil, nil, err
 	}
 
-	modelInstance := model_registry.NewModelRegisterCaller(applicationGenerateEntity.AppConfig.Model.Model, string(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType), applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.Provider.Provider, credentials, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance)
+	return r.modelCallerWithFallbacks(applicationGenerateEntity, credentials), promptMessages, stop, appRecord, nil
+}
 
-	return modelInstance, promptMessages, stop, appRecord, nil
+func (r *appAgentChatRunner) modelCallerWithFallbacks(applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, credentials map[string]interface{}) model_registry.IModelRegistryCall {
+	return model_registry.NewModelRegisterCallerWithFallbacks(applicationGenerateEntity.AppConfig.Model.Model, string(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType), applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.Provider.Provider, credentials, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance, r.LoadBalancer(r.redis, applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType, applicationGenerateEntity.AppConfig.Model.Model), r.FallbackModels(r.redis, applicationGenerateEntity.ModelConf), nil)
 }
 
 func (r *appAgentChatRunner) Run(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, message *po_entity_chat.Message, conversation *po_entity_chat.Conversation, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, taskScheduler IAgentChatAppTaskScheduler, flusher biz_entity_agent.AgentFlusher, appConfig *biz_entity_app_config.AgentChatAppConfig) {
@@ -109,6 +114,18 @@ func (r *appAgentChatRunner) Run(ctx context.Context, applicationGenerateEntity
 		return
 	}
 
+	appModeration, moderationResult, err := r.ModerationForInputs(ctx, applicationGenerateEntity)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	if moderationResult.Flagged {
+		r.DirectOutStream(applicationGenerateEntity, message, conversation, queueManager, moderationResult.PresetResponse, promptMessages)
+		return
+	}
+
 	if applicationGenerateEntity.Query != "" {
 		annotation, err := r.QueryAppAnnotationToReply(ctx, app, message, applicationGenerateEntity.Query, applicationGenerateEntity.EasyUIBasedAppGenerateEntity.UserID, string(applicationGenerateEntity.EasyUIBasedAppGenerateEntity.InvokeFrom))
 
@@ -129,40 +146,121 @@ func (r *appAgentChatRunner) Run(ctx context.Context, applicationGenerateEntity
 		}
 	}
 
-	if applicationGenerateEntity.Strategy == biz_entity_app_config.FUNCTION_CALLING {
-		toolRuntimeMap, promptToolMessage, err := r.InitPromptTools()
+	if appModeration != nil && appModeration.OutputsEnabled() {
+		queueManager = moderation.NewOutputModerationQueue(ctx, queueManager, appModeration)
+	}
 
-		if err != nil {
+	toolRuntimeMap, promptToolMessage, err := r.InitPromptTools(ctx)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	promptMessageInterfaces := util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
+		return pm
+	})
+
+	var agentRunner IAgentRunner
+
+	switch AgentStrategy(applicationGenerateEntity) {
+	case biz_entity_app_config.FUNCTION_CALLING:
+		agentRunner = NewFunctionCallAgentRunner(app.TenantID, applicationGenerateEntity, conversation, r.agentDomain, queueManager, flusher, promptToolMessage, promptMessageInterfaces, toolRuntimeMap, modelCaller, appConfig, "builtin", r.secretKet, r.fileBaseUrl, r.bucket)
+	default:
+		// the chain of thought prompts the model of every round with the ReAct prompt
+		agentRunner = NewCotAgentRunner(applicationGenerateEntity, conversation, r.agentDomain, queueManager, flusher, promptToolMessage, promptMessageInterfaces, stop, toolRuntimeMap, modelCaller, appConfig, "builtin", r.secretKet, r.fileBaseUrl, r.bucket)
+	}
+
+	taskScheduler.SetAgentRunner(agentRunner)
+
+	taskScheduler.Process(ctx)
+}
+
+// Resume resumes the runner paused at the agent thought once the approvals of its tool calls are decided, the runner
+// is restored from the approval state with the model and the tools of the current app config.
+func (r *appAgentChatRunner) Resume(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, message *po_entity_chat.Message, conversation *po_entity_chat.Conversation, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, taskScheduler IAgentChatAppTaskScheduler, flusher biz_entity_agent.AgentFlusher, appConfig *biz_entity_app_config.AgentChatAppConfig, agentThought *po_agent.MessageAgentThought) {
+
+	state, err := decodeApprovalState(agentThought)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	promptMessages, err := decodePromptMessages(state.PromptMessages)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	credentials, err := applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.GetCurrentCredentials(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType, applicationGenerateEntity.AppConfig.Model.Model)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	modelCaller := r.modelCallerWithFallbacks(applicationGenerateEntity, credentials)
+
+	// the inputs were moderated when the message was generated, the outputs of the resumed rounds still are
+	appModeration, err := moderation.NewModeration(ctx, r.ProviderDomain, applicationGenerateEntity.AppConfig.TenantID, applicationGenerateEntity.AppConfig.SensitiveWordAvoidance)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	if appModeration != nil && appModeration.OutputsEnabled() {
+		queueManager = moderation.NewOutputModerationQueue(ctx, queueManager, appModeration)
+	}
+
+	toolRuntimeMap, promptToolMessage, err := r.InitPromptTools(ctx)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	var agentRunner IAgentRunner
+
+	switch state.Strategy {
+	case biz_entity_app_config.FUNCTION_CALLING:
+		fcRunner := NewFunctionCallAgentRunner(r.tenantID, applicationGenerateEntity, conversation, r.agentDomain, queueManager, flusher, promptToolMessage, promptMessages, toolRuntimeMap, modelCaller, appConfig, "builtin", r.secretKet, r.fileBaseUrl, r.bucket)
+
+		if err := fcRunner.restore(state, agentThought); err != nil {
 			queueManager.PushErr(err)
 			return
 		}
 
-		go modelCaller.InvokeLLM(ctx, util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
-			return pm
-		}), queueManager, applicationGenerateEntity.ModelConf.Parameters, promptToolMessage, stop, applicationGenerateEntity.UserID, nil)
-
-		agentRunner := NewFunctionCallAgentRunner(app.TenantID, applicationGenerateEntity, conversation, r.agentDomain, queueManager, flusher, promptToolMessage, util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
-			return pm
-		}), toolRuntimeMap, modelCaller, appConfig, "builtin", r.secretKet, r.fileBaseUrl, r.bucket)
+		agentRunner = fcRunner
+	default:
+		cotRunner := NewCotAgentRunner(applicationGenerateEntity, conversation, r.agentDomain, queueManager, flusher, promptToolMessage, promptMessages, nil, toolRuntimeMap, modelCaller, appConfig, "builtin", r.secretKet, r.fileBaseUrl, r.bucket)
+		cotRunner.restore(state, agentThought)
+		agentRunner = cotRunner
+	}
 
-		taskScheduler.SetFunctionCallRunner(agentRunner)
+	taskScheduler.SetAgentRunner(agentRunner)
 
-		taskScheduler.Process(ctx)
-	}
+	taskScheduler.Process(ctx)
 }
 
 func (r *appAgentChatRunner) QueryAppAnnotationToReply(ctx context.Context, appRecord *po_entity.App, message *po_entity_chat.Message, query, accountID, invokeFrom string) (*po_entity_chat.MessageAnnotation, error) {
 	return app_feature.NewAnnotationReplyFeature(r.ChatDomain, r.DatasetDomain, r.ProviderDomain, r.redis).Query(ctx, appRecord, message, query, accountID, invokeFrom)
 }
 
-func (r *appAgentChatRunner) InitPromptTools() (map[string]*biz_entity_agent.ToolRuntimeConfiguration, []*biz_entity_chat_prompt_message.PromptMessageTool, error) {
+func (r *appAgentChatRunner) ModerationForInputs(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity) (moderation.IModeration, *moderation.ModerationResult, error) {
+	return app_feature.NewInputModerationFeature(r.ProviderDomain).Check(ctx, applicationGenerateEntity.AppConfig.AppConfig, applicationGenerateEntity.EasyUIBasedAppGenerateEntity.Inputs, applicationGenerateEntity.Query)
+}
+
+func (r *appAgentChatRunner) InitPromptTools(ctx context.Context) (map[string]*biz_entity_agent.ToolRuntimeConfiguration, []*biz_entity_chat_prompt_message.PromptMessageTool, error) {
 	var (
 		promptMessageTools []*biz_entity_chat_prompt_message.PromptMessageTool
 		toolInstance       = make(map[string]*biz_entity_agent.ToolRuntimeConfiguration)
 	)
 
 	for _, tool := range r.appConfig.AgentEntity.Tools {
-		promptTool, toolRuntime, err := r.convertToolToPromptMessageTool(tool)
+		promptTool, toolRuntime, err := r.convertToolToPromptMessageTool(ctx, tool)
 
 		if err != nil {
 			return nil, nil, err
@@ -175,8 +273,8 @@ func (r *appAgentChatRunner) InitPromptTools() (map[string]*biz_entity_agent.Too
 	return toolInstance, promptMessageTools, nil
 }
 
-func (r *appAgentChatRunner) convertToolToPromptMessageTool(tool *biz_entity_app_config.AgentToolEntity) (*biz_entity_chat_prompt_message.PromptMessageTool, *biz_entity_agent.ToolRuntimeConfiguration, error) {
-	toolRuntime, err := r.agentDomain.GetAgentToolRuntime(r.tenantID, r.appConfig.AppID, tool, "builtin")
+func (r *appAgentChatRunner) convertToolToPromptMessageTool(ctx context.Context, tool *biz_entity_app_config.AgentToolEntity) (*biz_entity_chat_prompt_message.PromptMessageTool, *biz_entity_agent.ToolRuntimeConfiguration, error) {
+	toolRuntime, err := r.agentDomain.GetAgentToolRuntime(ctx, r.tenantID, r.appConfig.AppID, tool, "builtin")
 
 	if err != nil {
 		return nil, nil, err
@@ -188,6 +286,12 @@ func (r *appAgentChatRunner) convertToolToPromptMessageTool(tool *biz_entity_app
 		Parameters:  biz_entity_chat_prompt_message.NewPromptMessageToolParameter(),
 	}
 
+	// the input schema of the mcp tools is passed as is, the nested arrays and objects aren't flattened into strings
+	if toolRuntime.Mcp != nil {
+		promptMessageTool.Parameters = mcp.PromptMessageToolParameter(toolRuntime.Mcp.Bundle.InputSchema)
+		return promptMessageTool, toolRuntime, nil
+	}
+
 	parameters := toolRuntime.GetAllRuntimeParameters()
 
 	for _, parameter := range parameters {
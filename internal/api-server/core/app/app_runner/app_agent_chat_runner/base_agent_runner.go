@@ -0,0 +1,468 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
+	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+)
+
+const (
+	// MAX_PARALLEL_TOOL_CALLS caps the tool calls of one round running at the same time unless it is configured
+	MAX_PARALLEL_TOOL_CALLS = 4
+	// TOOL_CALL_TIMEOUT bounds every tool call unless it is configured, the deadline of the request applies when it
+	// is earlier
+	TOOL_CALL_TIMEOUT = 60 * time.Second
+)
+
+var (
+	maxParallelToolCalls atomic.Int64
+	toolCallTimeout      atomic.Int64
+)
+
+// SetToolCallLimits sets how many tool calls of a round run at the same time and how long every tool call may take,
+// MAX_PARALLEL_TOOL_CALLS and TOOL_CALL_TIMEOUT apply to the values not positive.
+func SetToolCallLimits(maxParallel int, timeout time.Duration) {
+	maxParallelToolCalls.Store(int64(maxParallel))
+	toolCallTimeout.Store(int64(timeout))
+}
+
+func toolCallLimits() (int, time.Duration) {
+	maxParallel, timeout := int(maxParallelToolCalls.Load()), time.Duration(toolCallTimeout.Load())
+
+	if maxParallel <= 0 {
+		maxParallel = MAX_PARALLEL_TOOL_CALLS
+	}
+
+	if timeout <= 0 {
+		timeout = TOOL_CALL_TIMEOUT
+	}
+
+	return maxParallel, timeout
+}
+
+// IAgentRunner runs the rounds of the agent for the message, the task scheduler saves the message with the returned
+// state and deducts the quota of every round. The message isn't saved while the runner is paused for approvals.
+type IAgentRunner interface {
+	Run(ctx context.Context, message *po_entity.Message, query string) (*biz_entity_base_stream_generator.ChatAppTaskState, error)
+	LLMResults() []*biz_entity_base_stream_generator.LLMResult
+	Paused() bool
+}
+
+var (
+	_ IAgentRunner = (*FunctionCallAgentRunner)(nil)
+	_ IAgentRunner = (*CotAgentRunner)(nil)
+)
+
+// AgentStrategy picks the strategy of the agent by the declared features of the model, the models able to call tools
+// run with function calling and the others with the chain of thought, whatever the strategy of the app config is.
+func AgentStrategy(applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity) biz_entity_app_config.Strategy {
+	modelSchema := applicationGenerateEntity.ModelConf.ModelSchema
+
+	if modelSchema == nil || modelSchema.ProviderModel == nil {
+		return applicationGenerateEntity.Strategy
+	}
+
+	if slices.Contains(modelSchema.Features, common.TOOL_CALL) || slices.Contains(modelSchema.Features, common.MULTI_TOOL_CALL) {
+		return biz_entity_app_config.FUNCTION_CALLING
+	}
+
+	return biz_entity_app_config.CHAIN_OF_THOUGHT
+}
+
+// BaseAgentRunner holds what the runners of the strategies share, the queue of the model call of the round, the
+// agent thoughts and the tool invocations.
+type BaseAgentRunner struct {
+	agentThoughtCount         int
+	applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity
+	appConfig                 *biz_entity_app_config.AgentChatAppConfig
+	conversation              *po_entity.Conversation
+	agentDomain               *domain_service.AgentDomain
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	agentFlusher        biz_agent.AgentFlusher
+	promptToolMessages  []*biz_entity_chat_prompt_message.PromptMessageTool
+	modelCaller         model_registry.IModelRegistryCall
+	promptMessages      []biz_entity_chat_prompt_message.IPromptMessage
+	toolRuntimeMap      map[string]*biz_agent.ToolRuntimeConfiguration
+	message             *po_entity.Message
+	interactionStep     int
+	maxInteractionSteps int
+	taskState           *biz_entity_base_stream_generator.ChatAppTaskState
+	llmResults          []*biz_entity_base_stream_generator.LLMResult
+	providerType        string
+	secretKet           string
+	fileBaseUrl         string
+	bucket              string
+	queueDrained        bool
+	paused              bool
+	resumeState         *agentApprovalState
+	resumeThought       *po_agent.MessageAgentThought
+}
+
+func NewBaseAgentRunner(applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, conversation *po_entity.Conversation, agentDomain *domain_service.AgentDomain, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, agentFlusher biz_agent.AgentFlusher,
+	promptToolMessage []*biz_entity_chat_prompt_message.PromptMessageTool, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, toolRuntimeMap map[string]*biz_agent.ToolRuntimeConfiguration, modelCaller model_registry.IModelRegistryCall, appConfig *biz_entity_app_config.AgentChatAppConfig, providerType string, secretKet, fileBaseUrl string, bucket string) *BaseAgentRunner {
+
+	return &BaseAgentRunner{
+		applicationGenerateEntity: applicationGenerateEntity,
+		conversation:              conversation,
+		secretKet:                 secretKet,
+		fileBaseUrl:               fileBaseUrl,
+		toolRuntimeMap:            toolRuntimeMap,
+		agentDomain:               agentDomain,
+		IStreamGenerateQueue:      queueManager,
+		providerType:              providerType,
+		bucket:                    bucket,
+		agentFlusher:              agentFlusher,
+		promptToolMessages:        promptToolMessage,
+		promptMessages:            promptMessage,
+		appConfig:                 appConfig,
+		modelCaller:               modelCaller,
+		interactionStep:           1,
+		maxInteractionSteps:       int(math.Min(float64(applicationGenerateEntity.MaxIteration), 5)),
+		taskState: &biz_entity_base_stream_generator.ChatAppTaskState{
+			LLMResult: biz_entity_base_stream_generator.NewEmptyLLMResult(),
+		},
+	}
+}
+
+// LLMResults returns the results of every round of the model calls.
+func (br *BaseAgentRunner) LLMResults() []*biz_entity_base_stream_generator.LLMResult {
+	return br.llmResults
+}
+
+// Paused reports whether the runner stopped for the approvals of the tool calls.
+func (br *BaseAgentRunner) Paused() bool {
+	return br.paused
+}
+
+// interactionInvokeLLM invokes the model of the round, the first round takes the queue listened by the generator and
+// the next rounds a forked queue since the queue of the former round has been drained and closed.
+func (br *BaseAgentRunner) interactionInvokeLLM(ctx context.Context, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, promptToolMessages []*biz_entity_chat_prompt_message.PromptMessageTool, stop []string) {
+	if br.queueDrained {
+		br.IStreamGenerateQueue = br.Fork()
+
+		go br.IStreamGenerateQueue.Listen()
+	}
+
+	br.queueDrained = true
+
+	go br.modelCaller.InvokeLLM(ctx, promptMessages, br.IStreamGenerateQueue, br.applicationGenerateEntity.ModelConf.Parameters, promptToolMessages, stop, br.applicationGenerateEntity.UserID, nil)
+}
+
+// handleStreamAgentMessageQueue creates the agent thought of the round and consumes the queue of the model call, the
+// chunks and the end of the call are passed to the handlers of the runner.
+func (br *BaseAgentRunner) handleStreamAgentMessageQueue(ctx context.Context, handleResultChunk func(*biz_entity_base_stream_generator.MessageQueueMessage) error, handleFinalChunk func(*biz_entity_base_stream_generator.MessageQueueMessage)) (*po_agent.MessageAgentThought, error) {
+	isOccurredErr := false
+	isNormalQuit := false
+
+	agentThought, err := br.CreateAgentThought(ctx, br.message.ID, "", "", make(map[string]string, 0), []string{})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = br.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resultQueue, finalQueue, errorQueue := br.GetQueues()
+
+QuitLoop:
+	for {
+		select {
+		case resultMessage := <-resultQueue:
+			handleResultChunk(resultMessage)
+		case finalMessage, ok := <-finalQueue:
+			isNormalQuit = true
+			if !ok {
+				break QuitLoop
+			}
+			handleFinalChunk(finalMessage)
+		case errorMessage, ok := <-errorQueue:
+			isOccurredErr = true
+			if !ok {
+				break QuitLoop
+			}
+			if mc, ok := errorMessage.Event.(*biz_entity_base_stream_generator.QueueErrorEvent); ok {
+				log.Errorf("found queue error event: %#+v", mc.Err)
+				return nil, mc.Err
+			}
+		}
+	}
+
+	if isNormalQuit {
+		for len(resultQueue) > 0 {
+			resultMessage := <-resultQueue
+			handleResultChunk(resultMessage)
+		}
+
+		br.CloseOutNormalExit()
+	}
+
+	if isOccurredErr {
+		for len(resultQueue) > 0 {
+			resultMessage := <-resultQueue
+			handleResultChunk(resultMessage)
+		}
+		br.CloseOutErr()
+	}
+
+	return agentThought, nil
+}
+
+// parallelToolCalls reports whether the model is declared to emit several tool calls at once, the calls of one
+// round are independent and run concurrently.
+func (br *BaseAgentRunner) parallelToolCalls() bool {
+	modelSchema := br.applicationGenerateEntity.ModelConf.ModelSchema
+
+	return modelSchema != nil && modelSchema.ProviderModel != nil && slices.Contains(modelSchema.Features, common.MULTI_TOOL_CALL)
+}
+
+// invokeToolCalls invokes the tool calls of the round, at most the configured number of them at the same time when
+// the model emits several at once. The artifacts and the files are collected in the order of the calls, the files
+// are streamed in that order once all the calls are done.
+func (br *BaseAgentRunner) invokeToolCalls(ctx context.Context, toolCalls []*ToolCall) ([]*biz_agent.ToolArtifact, []string, error) {
+	var (
+		toolArtifacts  = make([]*biz_agent.ToolArtifact, len(toolCalls))
+		toolFileIDs    = make([][]string, len(toolCalls))
+		messageFileIDs []string
+	)
+
+	if len(toolCalls) > 1 && br.parallelToolCalls() {
+		var (
+			wg             sync.WaitGroup
+			maxParallel, _ = toolCallLimits()
+			semaphore      = make(chan struct{}, maxParallel)
+		)
+
+		for i, toolCall := range toolCalls {
+			wg.Add(1)
+			semaphore <- struct{}{}
+
+			go func() {
+				defer func() {
+					if r := recover(); r != nil {
+						log.Errorf("tool %s panicked: %v", toolCall.ToolCallName, r)
+						toolArtifacts[i] = toolErrorArtifact(toolCall, fmt.Sprintf("tool %s failed unexpectedly", toolCall.ToolCallName))
+					}
+					<-semaphore
+					wg.Done()
+				}()
+
+				toolArtifacts[i], toolFileIDs[i] = br.invokeToolCall(ctx, toolCall)
+			}()
+		}
+
+		wg.Wait()
+	} else {
+		for i, toolCall := range toolCalls {
+			toolArtifacts[i], toolFileIDs[i] = br.invokeToolCall(ctx, toolCall)
+		}
+	}
+
+	for _, fileIDs := range toolFileIDs {
+		for _, fileID := range fileIDs {
+			if err := br.agentFlusher.AgentMessageFileToStreamResponse(ctx, fileID, br.secretKet, br.fileBaseUrl); err != nil {
+				return nil, nil, err
+			}
+
+			messageFileIDs = append(messageFileIDs, fileID)
+		}
+	}
+
+	return toolArtifacts, messageFileIDs, nil
+}
+
+// invokeToolCall invokes the tool requested by the model within the configured timeout, a tool missing from the agent
+// config is answered with an error artifact instead of failing the round.
+func (br *BaseAgentRunner) invokeToolCall(ctx context.Context, toolCall *ToolCall) (*biz_agent.ToolArtifact, []string) {
+	var messageFileIDs []string
+
+	toolRuntimeIns, ok := br.toolRuntimeMap[toolCall.ToolCallName]
+
+	if !ok {
+		return toolErrorArtifact(toolCall, fmt.Sprintf("there is not a tool named %s", toolCall.ToolCallName)), nil
+	}
+
+	_, timeout := toolCallLimits()
+
+	toolCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	toolEngine := domain_service.NewToolEngine(toolRuntimeIns, br.message, br.providerType, br.agentDomain, br.bucket)
+
+	toolInvokeResponse := toolEngine.AgentInvoke(toolCtx, toolCall.TollCallArgs, br.applicationGenerateEntity.UserID, br.appConfig.TenantID, biz_agent.InvokeFrom(br.applicationGenerateEntity.InvokeFrom))
+
+	if toolInvokeResponse.ToolInvokeMeta.Error != "" && errors.Is(toolCtx.Err(), context.DeadlineExceeded) {
+		toolInvokeResponse.InvokeToolPrompt = fmt.Sprintf("tool %s timed out", toolCall.ToolCallName)
+	}
+
+	for _, messageFile := range toolInvokeResponse.MessageFiles {
+		messageFileIDs = append(messageFileIDs, messageFile.MessageFile)
+	}
+
+	return &biz_agent.ToolArtifact{
+		ToolCallID:   toolCall.ToolCallID,
+		ToolCallName: toolCall.ToolCallName,
+		ToolResponse: toolInvokeResponse.InvokeToolPrompt,
+		Meta:         toolInvokeResponse.ToolInvokeMeta,
+	}, messageFileIDs
+}
+
+func toolErrorArtifact(toolCall *ToolCall, message string) *biz_agent.ToolArtifact {
+	return &biz_agent.ToolArtifact{
+		ToolCallID:   toolCall.ToolCallID,
+		ToolCallName: toolCall.ToolCallName,
+		ToolResponse: message,
+		Meta:         biz_agent.ErrorInvokeMetaIns(message),
+	}
+}
+
+// getObservationAndMeta returns the observation and the meta of the tools of the agent thought, which are keyed by the
+// tool names as the tool of the thought is. The calls to the same tool in the round are aggregated, the responses
+// are joined by the lines in the order of the calls, the time costs are summed and the errors are joined.
+func (br *BaseAgentRunner) getObservationAndMeta(toolArtifacts []*biz_agent.ToolArtifact) (map[string]string, map[string]*po_agent.ToolEngineInvokeMeta) {
+
+	var (
+		observation = make(map[string]string)
+		meta        = make(map[string]*po_agent.ToolEngineInvokeMeta)
+	)
+
+	for _, artifact := range toolArtifacts {
+		artifactMeta := biz_agent.ConvertToPoMeta(artifact.Meta)
+		toolMeta, ok := meta[artifact.ToolCallName]
+
+		if !ok {
+			observation[artifact.ToolCallName] = artifact.ToolResponse
+			meta[artifact.ToolCallName] = artifactMeta
+			continue
+		}
+
+		observation[artifact.ToolCallName] += "\n" + artifact.ToolResponse
+		toolMeta.TimeCost += artifactMeta.TimeCost
+
+		if artifactMeta.Error != "" {
+			toolMeta.Error = strings.TrimPrefix(toolMeta.Error+"; "+artifactMeta.Error, "; ")
+		}
+	}
+
+	return observation, meta
+
+}
+
+func (br *BaseAgentRunner) CreateAgentThought(ctx context.Context, messageID, message, toolName string, toolInput map[string]string, messageFileIDs []string) (*po_agent.MessageAgentThought, error) {
+
+	thoughtObject := &po_agent.MessageAgentThought{
+		MessageID:     messageID,
+		Tool:          toolName,
+		ToolInput:     toolInput,
+		Message:       message,
+		Position:      br.agentThoughtCount + 1,
+		Currency:      "USD",
+		CreatedByRole: "account",
+		MessageFiles:  messageFileIDs,
+		CreatedBy:     br.applicationGenerateEntity.EasyUIBasedAppGenerateEntity.UserID,
+	}
+
+	thought, err := br.agentDomain.AgentRepo.CreateAgentThought(ctx, thoughtObject)
+
+	if err != nil {
+		return nil, err
+	}
+
+	br.agentThoughtCount += 1
+	return thought, nil
+}
+
+func (br *BaseAgentRunner) SaveAgentThought(ctx context.Context, agentThought *po_agent.MessageAgentThought, toolName string, toolInput map[string]string, thought string, observation map[string]string, toolInvokeMeta map[string]*po_agent.ToolEngineInvokeMeta, answer string, messageIDs []string, llmUsage *biz_entity_base_stream_generator.LLMUsage) (*po_agent.MessageAgentThought, error) {
+
+	if thought != "" {
+		agentThought.Thought = thought
+	}
+
+	if toolName != "" {
+		agentThought.Tool = toolName
+	}
+
+	if len(toolInput) > 0 {
+		agentThought.ToolInput = toolInput
+	}
+
+	if len(observation) > 0 {
+		agentThought.Observation = observation
+	}
+
+	if answer != "" {
+		agentThought.Answer = answer
+	}
+
+	if len(messageIDs) > 0 {
+		agentThought.MessageFiles = messageIDs
+	}
+
+	if agentThought.ToolMetaStr == nil {
+		agentThought.ToolMetaStr = make(map[string]*po_agent.ToolEngineInvokeMeta, 0)
+	}
+
+	if len(toolInvokeMeta) > 0 {
+		agentThought.ToolMetaStr = toolInvokeMeta
+	}
+
+	if agentThought.Tool != "" {
+		tools := strings.Split(agentThought.Tool, ";")
+
+		labels := agentThought.ToolLabelsStr
+
+		if labels == nil {
+			labels = make(map[string]map[string]any, 0)
+		}
+
+		for _, tool := range tools {
+			if tool == "" {
+				continue
+			}
+
+			_, ok := labels[tool]
+
+			if ok {
+				continue
+			}
+
+			toolRuntime := br.agentDomain.ToolManager.GetToolByIdentity(tool)
+			if toolRuntime != nil {
+				labels[tool] = map[string]any{"en_US": toolRuntime.Identity.Label.En_US, "zh_Hans": toolRuntime.Identity.Label.Zh_Hans}
+			} else {
+				labels[tool] = map[string]any{"en_US": tool, "zh_Hans": tool}
+			}
+		}
+	}
+
+	if err := br.agentDomain.UpdateAgentThought(ctx, agentThought); err != nil {
+		return nil, err
+	}
+
+	return agentThought, nil
+}
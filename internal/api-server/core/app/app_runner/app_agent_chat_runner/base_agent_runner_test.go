@@ -0,0 +1,219 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+)
+
+// sleepTool sleeps for the milliseconds of its parameters, or until the context is done, and records how many calls
+// run at the same time.
+type sleepTool struct {
+	running    atomic.Int32
+	maxRunning atomic.Int32
+}
+
+func (st *sleepTool) Register() string {
+	return "fake/sleep"
+}
+
+func (st *sleepTool) Invoke(ctx context.Context, userID string, toolParameters []byte, toolRuntime *biz_agent.ToolRuntimeConfiguration) ([]*biz_agent.ToolInvokeMessage, error) {
+	var parameters struct {
+		Millis int `json:"millis"`
+	}
+
+	json.Unmarshal(toolParameters, &parameters)
+
+	running := st.running.Add(1)
+	defer st.running.Add(-1)
+
+	for {
+		maxRunning := st.maxRunning.Load()
+
+		if running <= maxRunning || st.maxRunning.CompareAndSwap(maxRunning, running) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(time.Duration(parameters.Millis) * time.Millisecond):
+		return []*biz_agent.ToolInvokeMessage{{Type: biz_agent.TEXT, Message: fmt.Sprintf("slept %d", parameters.Millis)}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func testAgentRunner(features ...common.ModelFeature) *BaseAgentRunner {
+	toolRuntime := &biz_agent.ToolRuntimeConfiguration{
+		ToolStaticConfiguration: &biz_agent.ToolStaticConfiguration{
+			Identity: &biz_agent.ToolIdentity{Name: "sleep", Provider: "fake"},
+		},
+	}
+
+	return &BaseAgentRunner{
+		applicationGenerateEntity: &biz_entity_app_generate.AgentChatAppGenerateEntity{
+			EasyUIBasedAppGenerateEntity: &biz_entity_app_generate.EasyUIBasedAppGenerateEntity{
+				AppGenerateEntity: &biz_entity_app_generate.AppGenerateEntity{UserID: "user-1"},
+				ModelConf: &biz_entity_provider_config.ModelConfigWithCredentialsEntity{
+					ModelSchema: &biz_entity_model.AIModelStaticConfiguration{
+						ProviderModel: &common.ProviderModel{Features: features},
+					},
+				},
+			},
+		},
+		appConfig: &biz_entity_app_config.AgentChatAppConfig{
+			EasyUIBasedAppConfig: &biz_entity_app_config.EasyUIBasedAppConfig{
+				AppConfig: &biz_entity_app_config.AppConfig{TenantID: "tenant-1"},
+			},
+		},
+		toolRuntimeMap: map[string]*biz_agent.ToolRuntimeConfiguration{"sleep": toolRuntime},
+		message:        &po_entity.Message{ConversationID: "conversation-1"},
+		providerType:   "builtin",
+	}
+}
+
+func sleepToolCalls(millis ...int) []*ToolCall {
+	toolCalls := make([]*ToolCall, 0, len(millis))
+
+	for i, ms := range millis {
+		toolCalls = append(toolCalls, &ToolCall{ToolCallID: fmt.Sprintf("call-%d", i), ToolCallName: "sleep", TollCallArgs: fmt.Sprintf(`{"millis": %d}`, ms)})
+	}
+
+	return toolCalls
+}
+
+func TestInvokeToolCalls(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	tool := &sleepTool{}
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(tool)
+
+	// the later calls finish first, the artifacts keep the order of the calls
+	toolCalls := append(sleepToolCalls(80, 60, 40, 20, 10, 5), &ToolCall{ToolCallID: "call-6", ToolCallName: "missing"})
+	toolArtifacts, messageFileIDs, err := testAgentRunner(common.TOOL_CALL, common.MULTI_TOOL_CALL).invokeToolCalls(context.Background(), toolCalls)
+
+	if err != nil || len(messageFileIDs) != 0 {
+		t.Fatalf("invokeToolCalls() = %v, %v", messageFileIDs, err)
+	}
+
+	for i, millis := range []int{80, 60, 40, 20, 10, 5} {
+		if toolArtifacts[i].ToolCallID != toolCalls[i].ToolCallID || toolArtifacts[i].ToolResponse != fmt.Sprintf("slept %d", millis) {
+			t.Errorf("artifact %d = %+v", i, toolArtifacts[i])
+		}
+	}
+
+	if toolArtifacts[6].ToolResponse != "there is not a tool named missing" {
+		t.Errorf("artifact of the missing tool = %+v", toolArtifacts[6])
+	}
+
+	if maxRunning := tool.maxRunning.Load(); maxRunning < 2 || maxRunning > MAX_PARALLEL_TOOL_CALLS {
+		t.Errorf("%d calls ran at the same time, want 2 to %d", maxRunning, MAX_PARALLEL_TOOL_CALLS)
+	}
+
+	tool.maxRunning.Store(0)
+
+	if _, _, err := testAgentRunner(common.TOOL_CALL).invokeToolCalls(context.Background(), sleepToolCalls(10, 10, 10)); err != nil {
+		t.Fatalf("invokeToolCalls() error = %v", err)
+	}
+
+	if maxRunning := tool.maxRunning.Load(); maxRunning != 1 {
+		t.Errorf("%d calls ran at the same time, want them sequential without multi-tool-call", maxRunning)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	toolArtifacts, _, err = testAgentRunner(common.MULTI_TOOL_CALL).invokeToolCalls(ctx, sleepToolCalls(5, 5000))
+
+	if err != nil {
+		t.Fatalf("invokeToolCalls() error = %v", err)
+	}
+
+	if toolArtifacts[0].ToolResponse != "slept 5" || toolArtifacts[1].ToolResponse != "tool sleep timed out" || toolArtifacts[1].Meta.Error == "" {
+		t.Errorf("artifacts with the deadline = %+v, %+v", toolArtifacts[0], toolArtifacts[1])
+	}
+}
+
+func TestObservationOfParallelCalls(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	tool := &sleepTool{}
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(tool)
+
+	runner := testAgentRunner(common.TOOL_CALL, common.MULTI_TOOL_CALL)
+	toolCalls := sleepToolCalls(30, 10)
+	toolArtifacts, _, err := runner.invokeToolCalls(context.Background(), toolCalls)
+
+	if err != nil {
+		t.Fatalf("invokeToolCalls() error = %v", err)
+	}
+
+	if maxRunning := tool.maxRunning.Load(); maxRunning != 2 {
+		t.Errorf("%d calls ran at the same time, want 2", maxRunning)
+	}
+
+	observation, meta := runner.getObservationAndMeta(toolArtifacts)
+
+	// both calls to the tool are kept in the order of the calls
+	if len(observation) != 1 || observation["sleep"] != "slept 30\nslept 10" {
+		t.Errorf("observation = %q, want the responses of both calls", observation)
+	}
+
+	if want := toolArtifacts[0].Meta.TimeCost + toolArtifacts[1].Meta.TimeCost; len(meta) != 1 || meta["sleep"].TimeCost != want {
+		t.Errorf("time cost = %v, want %v of both calls", meta["sleep"].TimeCost, want)
+	}
+
+	var inputs []map[string]int
+
+	if err := json.Unmarshal([]byte((&FunctionCallAgentRunner{}).getToolInputs(toolCalls)["sleep"]), &inputs); err != nil || len(inputs) != 2 || inputs[0]["millis"] != 30 || inputs[1]["millis"] != 10 {
+		t.Errorf("tool inputs = %v, %v, want the arguments of both calls", inputs, err)
+	}
+}
+
+func TestToolCallLimits(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	tool := &sleepTool{}
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(tool)
+
+	SetToolCallLimits(2, 30*time.Millisecond)
+	defer SetToolCallLimits(0, 0)
+
+	toolArtifacts, _, err := testAgentRunner(common.MULTI_TOOL_CALL).invokeToolCalls(context.Background(), sleepToolCalls(10, 10, 10, 10, 5000))
+
+	if err != nil {
+		t.Fatalf("invokeToolCalls() error = %v", err)
+	}
+
+	if maxRunning := tool.maxRunning.Load(); maxRunning != 2 {
+		t.Errorf("%d calls ran at the same time, want the configured 2", maxRunning)
+	}
+
+	if toolArtifacts[4].ToolResponse != "tool sleep timed out" {
+		t.Errorf("artifact past the configured timeout = %+v", toolArtifacts[4])
+	}
+
+	// the defaults apply to the limits not positive
+	SetToolCallLimits(0, -time.Second)
+
+	if maxParallel, timeout := toolCallLimits(); maxParallel != MAX_PARALLEL_TOOL_CALLS || timeout != TOOL_CALL_TIMEOUT {
+		t.Errorf("toolCallLimits() = %d, %v, want the defaults", maxParallel, timeout)
+	}
+}
@@ -0,0 +1,345 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
+	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+)
+
+// REACT_PROMPT_TEMPLATE is the system prompt of the chain of thought, the instruction is the pre prompt of the app
+// and the tools are rendered as json.
+const REACT_PROMPT_TEMPLATE = `Respond to the human as helpfully and accurately as possible.
+
+{{instruction}}
+
+You have access to the following tools:
+
+{{tools}}
+
+Use the following format:
+
+Question: the input question you must answer
+Thought: you should always think about what to do
+Action: the action to take, should be one of [{{tool_names}}]
+Action Input: the input to the action, a json object of the parameters of the tool
+Observation: the result of the action
+... (this Thought/Action/Action Input/Observation can repeat N times)
+Thought: I now know the final answer
+Final Answer: the final answer to the original input question
+
+Begin! Take only one Action at a time and stop after its Action Input, the Observation is given to you.`
+
+const (
+	REACT_NO_TOOLS_PROMPT    = "No tools are available, respond with the Final Answer."
+	REACT_CONTINUE_PROMPT    = "Continue with the Thought following the last Observation."
+	REACT_LAST_ROUND_PROMPT  = "No more actions are allowed, respond with the Final Answer now."
+	REACT_OBSERVATION_STOP   = OBSERVATION_KEYWORD
+	REACT_EMPTY_ACTION_INPUT = "{}"
+)
+
+// CotAgentRunner runs the agent with the chain of thought for the models without tool calls, the tools are described
+// in the system prompt and the actions are parsed from the ReAct output. The former rounds are given back to the model
+// as the scratchpad of the assistant.
+type CotAgentRunner struct {
+	*BaseAgentRunner
+	stop       []string
+	parser     *ReActOutputParser
+	scratchpad strings.Builder
+}
+
+func NewCotAgentRunner(applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, conversation *po_entity.Conversation, agentDomain *domain_service.AgentDomain, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, agentFlusher biz_agent.AgentFlusher,
+	promptToolMessage []*biz_entity_chat_prompt_message.PromptMessageTool, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, stop []string, toolRuntimeMap map[string]*biz_agent.ToolRuntimeConfiguration, modelCaller model_registry.IModelRegistryCall, appConfig *biz_entity_app_config.AgentChatAppConfig, providerType string, secretKet, fileBaseUrl string, bucket string) *CotAgentRunner {
+
+	return &CotAgentRunner{
+		BaseAgentRunner: NewBaseAgentRunner(applicationGenerateEntity, conversation, agentDomain, queueManager, agentFlusher, promptToolMessage, promptMessage, toolRuntimeMap, modelCaller, appConfig, providerType, secretKet, fileBaseUrl, bucket),
+		stop:            append(slices.Clone(stop), REACT_OBSERVATION_STOP),
+	}
+}
+
+func (cra *CotAgentRunner) Run(ctx context.Context, message *po_entity.Message, query string) (*biz_entity_base_stream_generator.ChatAppTaskState, error) {
+	cra.message = message
+
+	// the round paused for the approval goes on with its action
+	if agentThought := cra.resumeThought; agentThought != nil {
+		cra.resumeThought = nil
+
+		if err := cra.runAction(ctx, agentThought, cra.resumeState.Thought, cra.resumeState.ToolCalls[0]); err != nil {
+			return nil, err
+		}
+
+		cra.interactionStep++
+	}
+
+	for ; !cra.paused && cra.interactionStep <= cra.maxInteractionSteps; cra.interactionStep++ {
+		lastRound := cra.interactionStep == cra.maxInteractionSteps
+		promptMessages := cra.organizePromptMessages(lastRound)
+
+		cra.parser = NewReActOutputParser()
+
+		cra.interactionInvokeLLM(ctx, promptMessages, nil, cra.stop)
+
+		agentThought, err := cra.handleStreamAgentMessageQueue(ctx, cra.handleResultChunk, cra.handleFinalChunk)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if text := cra.parser.Flush(); text != "" {
+			if err := cra.agentFlusher.AgentMessageToStreamResponse(text); err != nil {
+				return nil, err
+			}
+		}
+
+		result := cra.parser.Result()
+
+		// the action of the last round isn't taken, the thought is all the answer there is
+		if result.Action == nil || lastRound {
+			answer := result.FinalAnswer
+
+			if result.Action != nil {
+				answer = result.Thought
+			}
+
+			agentThought, err = cra.SaveAgentThought(ctx, agentThought, "", nil, result.Thought, nil, nil, answer, nil, nil)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if err := cra.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID); err != nil {
+				return nil, err
+			}
+
+			cra.setAnswer(answer)
+			return cra.taskState, nil
+		}
+
+		toolCall := &ToolCall{
+			ToolCallID:   uuid.NewString(),
+			ToolCallName: result.Action.Name,
+			TollCallArgs: cra.toolArguments(result.Action),
+		}
+
+		// before tool call update agent thought
+		agentThought, err = cra.SaveAgentThought(ctx, agentThought, toolCall.ToolCallName, map[string]string{toolCall.ToolCallName: toolCall.TollCallArgs}, result.Thought, nil, nil, "", nil, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := cra.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID); err != nil {
+			return nil, err
+		}
+
+		if err := cra.runAction(ctx, agentThought, result.Thought, toolCall); err != nil {
+			return nil, err
+		}
+	}
+
+	return cra.taskState, nil
+}
+
+// runAction invokes the tool of the action unless the round is paused for the approval, the action and its
+// observation are added to the scratchpad.
+func (cra *CotAgentRunner) runAction(ctx context.Context, agentThought *po_agent.MessageAgentThought, thought string, toolCall *ToolCall) error {
+	approvalState := &agentApprovalState{
+		Strategy:       biz_entity_app_config.CHAIN_OF_THOUGHT,
+		PromptMessages: encodePromptMessages(cra.promptMessages),
+		Stop:           cra.stop,
+		Scratchpad:     cra.scratchpad.String(),
+		Thought:        thought,
+	}
+
+	if paused, err := cra.pauseForApproval(ctx, agentThought, []*ToolCall{toolCall}, approvalState); err != nil || paused {
+		return err
+	}
+
+	toolArtifacts, messageFileIDs, err := cra.invokeApprovedToolCalls(ctx, agentThought, []*ToolCall{toolCall})
+
+	if err != nil {
+		return err
+	}
+
+	toolArtifact := toolArtifacts[0]
+	observation, meta := cra.getObservationAndMeta(toolArtifacts)
+
+	// after tool call update agent thought
+	agentThought, err = cra.SaveAgentThought(ctx, agentThought, "", nil, "", observation, meta, "", messageFileIDs, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if err := cra.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&cra.scratchpad, "%s %s\n%s %s\n%s %s\n%s %s\n", THOUGHT_KEYWORD, thought, ACTION_KEYWORD, toolCall.ToolCallName, ACTION_INPUT_KEYWORD, toolCall.TollCallArgs, OBSERVATION_KEYWORD, toolArtifact.ToolResponse)
+
+	return nil
+}
+
+// restore restores the runner paused at the agent thought with the approval state saved by runAction.
+func (cra *CotAgentRunner) restore(state *agentApprovalState, agentThought *po_agent.MessageAgentThought) {
+	cra.stop = state.Stop
+	cra.scratchpad.WriteString(state.Scratchpad)
+	cra.resumeFrom(state, agentThought)
+}
+
+// organizePromptMessages replaces the system prompt with the ReAct prompt which takes the pre prompt as the
+// instruction, the scratchpad of the former rounds follows the query.
+func (cra *CotAgentRunner) organizePromptMessages(lastRound bool) []biz_entity_chat_prompt_message.IPromptMessage {
+	var (
+		instruction    string
+		historyMessage = cra.promptMessages
+		promptMessages = make([]biz_entity_chat_prompt_message.IPromptMessage, 0, len(cra.promptMessages)+3)
+	)
+
+	if len(historyMessage) > 0 && historyMessage[0].GetRole() == string(biz_entity_chat_prompt_message.SYSTEM) {
+		instruction = historyMessage[0].GetContent()
+		historyMessage = historyMessage[1:]
+	}
+
+	promptMessages = append(promptMessages, biz_entity_chat_prompt_message.NewSystemMessage(cra.reActPrompt(instruction, lastRound)))
+	promptMessages = append(promptMessages, historyMessage...)
+
+	if cra.scratchpad.Len() > 0 {
+		continuePrompt := REACT_CONTINUE_PROMPT
+
+		if lastRound {
+			continuePrompt = REACT_LAST_ROUND_PROMPT
+		}
+
+		promptMessages = append(promptMessages, biz_entity_chat_prompt_message.NewAssistantMessage(cra.scratchpad.String()), biz_entity_chat_prompt_message.NewUserMessage(continuePrompt))
+	}
+
+	return promptMessages
+}
+
+// reActPrompt renders the tools into the ReAct prompt, the last round is rendered without tools.
+func (cra *CotAgentRunner) reActPrompt(instruction string, lastRound bool) string {
+	var (
+		tools     []string
+		toolNames []string
+	)
+
+	if !lastRound {
+		for _, promptTool := range cra.promptToolMessages {
+			encoded, err := json.Marshal(promptTool)
+
+			if err != nil {
+				continue
+			}
+
+			tools = append(tools, string(encoded))
+			toolNames = append(toolNames, promptTool.Name)
+		}
+	}
+
+	if len(tools) == 0 {
+		tools = append(tools, REACT_NO_TOOLS_PROMPT)
+	}
+
+	return strings.NewReplacer(
+		"{{instruction}}", instruction,
+		"{{tools}}", strings.Join(tools, "\n"),
+		"{{tool_names}}", strings.Join(toolNames, ", "),
+	).Replace(REACT_PROMPT_TEMPLATE)
+}
+
+// toolArguments returns the json object of the tool parameters, the plain input of the tool with a single parameter
+// is taken as the value of that parameter.
+func (cra *CotAgentRunner) toolArguments(action *ReActAction) string {
+	if action.Input == "" {
+		return REACT_EMPTY_ACTION_INPUT
+	}
+
+	if strings.HasPrefix(action.Input, "{") {
+		return action.Input
+	}
+
+	for _, promptTool := range cra.promptToolMessages {
+		if promptTool.Name != action.Name || promptTool.Parameters == nil || len(promptTool.Parameters.Properties) != 1 {
+			continue
+		}
+
+		for name := range promptTool.Parameters.Properties {
+			var value any = action.Input
+
+			// a quoted string or a number is decoded, the rest is passed as the text
+			if err := json.Unmarshal([]byte(action.Input), &value); err != nil {
+				value = action.Input
+			}
+
+			encoded, err := json.Marshal(map[string]any{name: value})
+
+			if err != nil {
+				break
+			}
+
+			return string(encoded)
+		}
+	}
+
+	return action.Input
+}
+
+// setAnswer saves the final answer as the message instead of the ReAct output of the last round.
+func (cra *CotAgentRunner) setAnswer(answer string) {
+	llmResult := *cra.taskState.LLMResult
+	llmResult.Message = biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(answer)
+	cra.taskState.LLMResult = &llmResult
+}
+
+// handleResultChunk feeds the chunks of the round to the ReAct parser, the rounds are invoked without tools so the
+// providers stream them as llm chunks instead of agent messages.
+func (cra *CotAgentRunner) handleResultChunk(message *biz_entity_base_stream_generator.MessageQueueMessage) error {
+	var chunk *biz_entity_base_stream_generator.LLMResultChunk
+
+	switch chunkEvent := message.Event.(type) {
+	case *biz_entity_base_stream_generator.QueueLLMChunkEvent:
+		chunk = chunkEvent.Chunk
+	case *biz_entity_base_stream_generator.QueueAgentMessageEvent:
+		chunk = chunkEvent.Chunk
+	case *biz_entity_base_stream_generator.QueueMessageReplaceEvent:
+		// the replaced output is parsed again so that it is taken as the final answer of the round
+		cra.parser = NewReActOutputParser()
+		cra.parser.Feed(chunkEvent.Text)
+		cra.parser.Flush()
+		return cra.agentFlusher.MessageReplaceToStreamResponse(chunkEvent.Text)
+	default:
+		return nil
+	}
+
+	deltaText, _ := chunk.Delta.Message.Content.(string)
+
+	if text := cra.parser.Feed(deltaText); text != "" {
+		return cra.agentFlusher.AgentMessageToStreamResponse(text)
+	}
+
+	return nil
+}
+
+func (cra *CotAgentRunner) handleFinalChunk(message *biz_entity_base_stream_generator.MessageQueueMessage) {
+	if mc, ok := message.Event.(*biz_entity_base_stream_generator.QueueMessageEndEvent); ok {
+		cra.taskState.LLMResult = mc.LLMResult
+		cra.llmResults = append(cra.llmResults, mc.LLMResult)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
+	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	po_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/repository"
+	po_app "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_agent_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_agent_generator"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+)
+
+// reActModelCaller streams the recorded ReAct output of each round in small chunks as the providers do for the
+// calls without tools.
+type reActModelCaller struct {
+	model_registry.IModelRegistryCall
+	outputs []string
+	prompts [][]biz_entity_chat_prompt_message.IPromptMessage
+	stops   [][]string
+}
+
+func (mc *reActModelCaller) InvokeLLM(ctx context.Context, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, modelParameters map[string]interface{}, tools []*biz_entity_chat_prompt_message.PromptMessageTool, stop []string, user string, callbacks interface{}) {
+	output := mc.outputs[len(mc.prompts)]
+	mc.prompts = append(mc.prompts, promptMessages)
+	mc.stops = append(mc.stops, stop)
+
+	for i := 0; i < len(output); i += 4 {
+		queueManager.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+			Chunk: &biz_entity_base_stream_generator.LLMResultChunk{
+				Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+					Index:   i,
+					Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(output[i:min(i+4, len(output))]),
+				},
+			},
+		})
+	}
+
+	queueManager.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+		LLMResult: &biz_entity_base_stream_generator.LLMResult{
+			Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(output),
+			Usage:   biz_entity_base_stream_generator.NewEmptyLLMUsage(),
+		},
+	})
+}
+
+type fakeAgentFlusher struct {
+	biz_agent.AgentFlusher
+	messages strings.Builder
+}
+
+func (af *fakeAgentFlusher) AgentThoughtToStreamResponse(ctx context.Context, agentThoughtID string) error {
+	return nil
+}
+
+func (af *fakeAgentFlusher) AgentMessageToStreamResponse(answer string) error {
+	af.messages.WriteString(answer)
+	return nil
+}
+
+func (af *fakeAgentFlusher) MessageReplaceToStreamResponse(answer string) error {
+	af.messages.Reset()
+	af.messages.WriteString(answer)
+	return nil
+}
+
+type fakeAgentRepo struct {
+	repository.AgentRepo
+	thoughts []*po_agent.MessageAgentThought
+}
+
+func (ar *fakeAgentRepo) CreateAgentThought(ctx context.Context, agentThought *po_agent.MessageAgentThought) (*po_agent.MessageAgentThought, error) {
+	agentThought.ID = fmt.Sprintf("thought-%d", len(ar.thoughts))
+	ar.thoughts = append(ar.thoughts, agentThought)
+	return agentThought, nil
+}
+
+func (ar *fakeAgentRepo) UpdateAgentThought(ctx context.Context, agentThought *po_agent.MessageAgentThought) error {
+	return nil
+}
+
+func TestCotAgentRunnerRun(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(&sleepTool{})
+
+	modelCaller := &reActModelCaller{
+		outputs: []string{
+			"Thought: I should sleep first.\nAction: sleep\nAction Input: 5\nObservation: made up",
+			"Thought: I have slept.\nFinal Answer: Slept for 5 ms.",
+		},
+	}
+	agentRepo := &fakeAgentRepo{}
+	agentFlusher := &fakeAgentFlusher{}
+
+	queue := biz_entity_agent_stream_generator.NewAgentStreamGenerateQueue("task-1", "user-1", "conversation-1", "message-1", po_app.AGENT_CHAT, "debugger")
+	go queue.Listen()
+
+	baseRunner := testAgentRunner()
+	baseRunner.IStreamGenerateQueue = queue
+	baseRunner.agentDomain = &domain_service.AgentDomain{ToolManager: &tools.ToolManager{}, AgentRepo: agentRepo}
+	baseRunner.agentFlusher = agentFlusher
+	baseRunner.modelCaller = modelCaller
+	baseRunner.interactionStep = 1
+	baseRunner.maxInteractionSteps = 3
+	baseRunner.taskState = &biz_entity_base_stream_generator.ChatAppTaskState{LLMResult: biz_entity_base_stream_generator.NewEmptyLLMResult()}
+	baseRunner.promptMessages = []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("Be brief."),
+		biz_entity_chat_prompt_message.NewUserMessage("Sleep for 5 ms."),
+	}
+	baseRunner.promptToolMessages = []*biz_entity_chat_prompt_message.PromptMessageTool{{
+		Name:        "sleep",
+		Description: "Sleep for the milliseconds",
+		Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{
+			Type:       "object",
+			Properties: biz_entity_chat_prompt_message.PromptMessageToolProperties{"millis": {Type: "integer"}},
+		},
+	}}
+
+	cra := &CotAgentRunner{BaseAgentRunner: baseRunner, stop: []string{REACT_OBSERVATION_STOP}}
+
+	taskState, err := cra.Run(context.Background(), &po_entity.Message{ID: "message-1"}, "Sleep for 5 ms.")
+
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if answer := taskState.LLMResult.Message.Content; answer != "Slept for 5 ms." {
+		t.Errorf("answer = %q", answer)
+	}
+
+	if streamed := agentFlusher.messages.String(); streamed != "I should sleep first.\nI have slept.\nSlept for 5 ms." {
+		t.Errorf("streamed %q", streamed)
+	}
+
+	if len(agentRepo.thoughts) != 2 {
+		t.Fatalf("%d agent thoughts, want 2", len(agentRepo.thoughts))
+	}
+
+	action, final := agentRepo.thoughts[0], agentRepo.thoughts[1]
+
+	if action.Tool != "sleep" || action.ToolInput["sleep"] != `{"millis":5}` || action.Observation["sleep"] != "slept 5" || action.Thought != "I should sleep first." {
+		t.Errorf("thought of the action = %+v", action)
+	}
+
+	if final.Answer != "Slept for 5 ms." || final.Thought != "I have slept." {
+		t.Errorf("thought of the answer = %+v", final)
+	}
+
+	if len(modelCaller.prompts) != 2 || !slices.Contains(modelCaller.stops[0], REACT_OBSERVATION_STOP) {
+		t.Fatalf("model called %d times with stop %v", len(modelCaller.prompts), modelCaller.stops)
+	}
+
+	// the second round is given the action and the real observation as the scratchpad
+	secondRound := modelCaller.prompts[1]
+	scratchpad := secondRound[len(secondRound)-2].GetContent()
+
+	if !strings.Contains(scratchpad, "Action: sleep\nAction Input: {\"millis\":5}\nObservation: slept 5") || !strings.Contains(secondRound[0].GetContent(), "Be brief.") {
+		t.Errorf("prompt of the second round = %v", secondRound)
+	}
+}
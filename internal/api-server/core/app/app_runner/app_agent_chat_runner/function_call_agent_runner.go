@@ -2,11 +2,8 @@ package app_agent_chat_runner
 
 import (
 	"context"
-	"fmt"
-	"math"
 	"strings"
 
-	"github.com/lunarianss/Luna/infrastructure/log"
 	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	"github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	biz_agent "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
@@ -20,17 +17,13 @@ import (
 )
 
 type RunnerRuntimeParameters struct {
-	message               *po_entity.Message
 	functionCallState     bool
-	interactionStep       int
-	maxInteractionSteps   int
 	toolCalls             []*ToolCall
 	toolCallNames         string
 	toolCallInputs        map[string]string
 	historyPromptMessages []*biz_entity_chat_prompt_message.PromptMessage
 	assistantThoughts     []biz_entity_chat_prompt_message.IPromptMessage
 	toolResponse          []*ToolResponseItem
-	taskState             *biz_entity_base_stream_generator.ChatAppTaskState
 	fullAssistant         string
 }
 
@@ -48,70 +41,43 @@ type ToolResponseItem struct {
 }
 
 type FunctionCallAgentRunner struct {
-	agentThoughtCount         int
-	applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity
-	appConfig                 *biz_entity_app_config.AgentChatAppConfig
-	conversation              *po_entity.Conversation
-	agentDomain               *domain_service.AgentDomain
-	biz_entity_base_stream_generator.IStreamGenerateQueue
-	agentFlusher       biz_agent.AgentFlusher
-	promptToolMessages []*biz_entity_chat_prompt_message.PromptMessageTool
-	modelCaller        model_registry.IModelRegistryCall
-	promptMessages     []biz_entity_chat_prompt_message.IPromptMessage
-	toolRuntimeMap     map[string]*biz_agent.ToolRuntimeConfiguration
+	*BaseAgentRunner
 	*RunnerRuntimeParameters
-	providerType string
-	secretKet    string
-	fileBaseUrl  string
-	bucket       string
 }
 
 func NewFunctionCallAgentRunner(tenantID string, applicationGenerateEntity *biz_entity_app_generate.AgentChatAppGenerateEntity, conversation *po_entity.Conversation, agentDomain *domain_service.AgentDomain, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, agentFlusher biz_agent.AgentFlusher,
 	promptToolMessage []*biz_entity_chat_prompt_message.PromptMessageTool, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, toolRuntimeMap map[string]*biz_agent.ToolRuntimeConfiguration, modelCaller model_registry.IModelRegistryCall, appConfig *biz_entity_app_config.AgentChatAppConfig, providerType string, secretKet, fileBaseUrl string, bucket string) *FunctionCallAgentRunner {
 
 	return &FunctionCallAgentRunner{
-		applicationGenerateEntity: applicationGenerateEntity,
-		conversation:              conversation,
-		secretKet:                 secretKet,
-		fileBaseUrl:               fileBaseUrl,
-		toolRuntimeMap:            toolRuntimeMap,
-		agentDomain:               agentDomain,
-		IStreamGenerateQueue:      queueManager,
-		providerType:              providerType,
-		bucket:                    bucket,
-		agentFlusher:              agentFlusher,
-		promptToolMessages:        promptToolMessage,
-		promptMessages:            promptMessage,
-		appConfig:                 appConfig,
-		modelCaller:               modelCaller,
+		BaseAgentRunner: NewBaseAgentRunner(applicationGenerateEntity, conversation, agentDomain, queueManager, agentFlusher, promptToolMessage, promptMessage, toolRuntimeMap, modelCaller, appConfig, providerType, secretKet, fileBaseUrl, bucket),
 		RunnerRuntimeParameters: &RunnerRuntimeParameters{
-			interactionStep:       1,
 			historyPromptMessages: make([]*biz_entity_chat_prompt_message.PromptMessage, 0),
 			functionCallState:     true,
-			maxInteractionSteps:   int(math.Min(float64(applicationGenerateEntity.MaxIteration), 5)),
 			toolResponse:          make([]*ToolResponseItem, 0),
-			taskState: &biz_entity_base_stream_generator.ChatAppTaskState{
-				LLMResult: biz_entity_base_stream_generator.NewEmptyLLMResult(),
-			},
 		},
 	}
 }
 
 type ToolCall struct {
-	ToolCallID   string
-	ToolCallName string
-	TollCallArgs string
+	ToolCallID   string `json:"id"`
+	ToolCallName string `json:"name"`
+	TollCallArgs string `json:"arguments"`
 }
 
 func (fca *FunctionCallAgentRunner) Run(ctx context.Context, message *po_entity.Message, query string) (*biz_entity_base_stream_generator.ChatAppTaskState, error) {
 
 	fca.message = message
 
-	for fca.functionCallState && fca.interactionStep <= int(fca.maxInteractionSteps) {
-		var (
-			messageFileIDs []string
-		)
+	// the round paused for the approvals goes on with its tool calls
+	if agentThought := fca.resumeThought; agentThought != nil {
+		fca.resumeThought = nil
 
+		if err := fca.runToolCalls(ctx, agentThought); err != nil {
+			return nil, err
+		}
+	}
+
+	for !fca.paused && fca.functionCallState && fca.interactionStep <= int(fca.maxInteractionSteps) {
 		fca.functionCallState = false
 
 		if fca.interactionStep == fca.maxInteractionSteps {
@@ -120,11 +86,9 @@ func (fca *FunctionCallAgentRunner) Run(ctx context.Context, message *po_entity.
 
 		fca.organizePromptMessage()
 
-		if fca.interactionStep > 1 {
-			go fca.interactionInvokeLLM(ctx)
-		}
+		fca.interactionInvokeLLM(ctx, fca.promptMessages, fca.promptToolMessages, make([]string, 0))
 
-		agentThought, err := fca.handleStreamAgentMessageQueue(ctx)
+		agentThought, err := fca.handleStreamAgentMessageQueue(ctx, fca.handleResultChunk, fca.handleFinalChunk)
 		if err != nil {
 			return nil, err
 		}
@@ -157,158 +121,85 @@ func (fca *FunctionCallAgentRunner) Run(ctx context.Context, message *po_entity.
 			return nil, err
 		}
 
-		var toolArtifacts []*biz_agent.ToolArtifact
-
-		for _, toolCall := range fca.toolCalls {
-
-			toolRuntimeIns, ok := fca.toolRuntimeMap[toolCall.ToolCallName]
-
-			if !ok {
-				toolArtifacts = append(toolArtifacts, &biz_agent.ToolArtifact{
-					ToolCallID:   toolCall.ToolCallID,
-					ToolCallName: toolCall.ToolCallName,
-					ToolResponse: fmt.Sprintf("there is not a tool named %s", toolCall.ToolCallName),
-					Meta:         biz_agent.ErrorInvokeMetaIns(fmt.Sprintf("there is not a tool named %s", toolCall.ToolCallName)),
-				})
-			}
-
-			toolEngine := domain_service.NewToolEngine(toolRuntimeIns, message, fca.providerType, fca.agentDomain, fca.bucket)
-
-			toolInvokeResponse := toolEngine.AgentInvoke(ctx, toolCall.TollCallArgs, fca.applicationGenerateEntity.UserID, fca.appConfig.TenantID, biz_agent.InvokeFrom(fca.applicationGenerateEntity.InvokeFrom))
-
-			for _, messageFile := range toolInvokeResponse.MessageFiles {
-				err := fca.agentFlusher.AgentMessageFileToStreamResponse(ctx, messageFile.MessageFile, fca.secretKet, fca.fileBaseUrl)
-
-				if err != nil {
-					return nil, err
-				}
-
-				messageFileIDs = append(messageFileIDs, messageFile.MessageFile)
-			}
-
-			toolArtifacts = append(toolArtifacts, &biz_agent.ToolArtifact{
-				ToolCallID:   toolCall.ToolCallID,
-				ToolCallName: toolCall.ToolCallName,
-				ToolResponse: toolInvokeResponse.InvokeToolPrompt,
-				Meta:         toolInvokeResponse.ToolInvokeMeta,
-			})
-
-			if toolInvokeResponse.InvokeToolPrompt != "" {
-				fca.assistantThoughts = append(fca.assistantThoughts, &biz_entity_chat_prompt_message.ToolPromptMessage{
-					PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{
-						Content: toolInvokeResponse.InvokeToolPrompt,
-						Role:    biz_entity_chat_prompt_message.TOOL,
-						Name:    toolCall.ToolCallName,
-					},
-					ToolCallID: toolCall.ToolCallID,
-				})
-			}
-		}
-
-		if len(toolArtifacts) > 0 {
-			observation, meta := fca.getObservationAndMeta(toolArtifacts)
-
-			toolInvokeMeta := make(map[string]*biz_agent.ToolEngineInvokeMeta)
-
-			for _, toolArtifact := range toolArtifacts {
-				toolInvokeMeta[toolArtifact.ToolCallName] = toolArtifact.Meta
-			}
-
-			// after tool call update agent thought
-			agentThought, err = fca.SaveAgentThought(ctx, agentThought, "", nil, "", observation, meta, "", messageFileIDs, nil)
-
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if err := fca.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID); err != nil {
+		if err := fca.runToolCalls(ctx, agentThought); err != nil {
 			return nil, err
 		}
-
-		fca.interactionStep += 1
-		fca.toolCalls = make([]*ToolCall, 0)
-		fca.toolCallNames = ""
-		fca.toolCallInputs = make(map[string]string)
-		fca.fullAssistant = ""
 	}
 
 	return fca.taskState, nil
 }
 
-func (fca *FunctionCallAgentRunner) interactionInvokeLLM(ctx context.Context) {
-	fca.IStreamGenerateQueue.Debug()
-	fca.IStreamGenerateQueue = fca.Fork()
-
-	go fca.IStreamGenerateQueue.Listen()
-
-	// log.Info(color.GreenString("========== 下一次 Agent 迭代 ==========="))
-
-	// util.LogCompleteInfo(fca.promptMessages)
-
-	fca.modelCaller.InvokeLLM(ctx, fca.promptMessages, fca.IStreamGenerateQueue, fca.applicationGenerateEntity.ModelConf.Parameters, fca.promptToolMessages, make([]string, 0), fca.applicationGenerateEntity.UserID, nil)
-}
-
-func (fca *FunctionCallAgentRunner) handleStreamAgentMessageQueue(ctx context.Context) (*po_agent.MessageAgentThought, error) {
-	// isFirstChunk := true
-	isOccurredErr := false
-	isNormalQuit := false
-
-	agentThought, err := fca.CreateAgentThought(ctx, fca.message.ID, "", "", make(map[string]string, 0), []string{})
+// runToolCalls invokes the tool calls of the round unless the round is paused for the approvals, the tool responses
+// are given to the model of the next round.
+func (fca *FunctionCallAgentRunner) runToolCalls(ctx context.Context, agentThought *po_agent.MessageAgentThought) error {
+	approvalState := &agentApprovalState{
+		Strategy:          biz_entity_app_config.FUNCTION_CALLING,
+		PromptMessages:    encodePromptMessages(fca.promptMessages),
+		AssistantThoughts: encodePromptMessages(fca.assistantThoughts),
+	}
 
-	if err != nil {
-		return nil, err
+	if paused, err := fca.pauseForApproval(ctx, agentThought, fca.toolCalls, approvalState); err != nil || paused {
+		return err
 	}
 
-	err = fca.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID)
+	toolArtifacts, messageFileIDs, err := fca.invokeApprovedToolCalls(ctx, agentThought, fca.toolCalls)
 
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	resultQueue, finalQueue, errorQueue := fca.GetQueues()
-
-QuitLoop:
-	for {
-		select {
-		case resultMessage := <-resultQueue:
-			fca.handleResultChunk(resultMessage)
-		case finalMessage, ok := <-finalQueue:
-			isNormalQuit = true
-			if !ok {
-				break QuitLoop
-			}
-			fca.handleFinalChunk(finalMessage)
-		case errorMessage, ok := <-errorQueue:
-			isOccurredErr = true
-			if !ok {
-				break QuitLoop
-			}
-			if mc, ok := errorMessage.Event.(*biz_entity_base_stream_generator.QueueErrorEvent); ok {
-				log.Errorf("found queue error event: %#+v", mc.Err)
-				return nil, mc.Err
-			}
+	for _, toolArtifact := range toolArtifacts {
+		if toolArtifact.ToolResponse != "" {
+			fca.assistantThoughts = append(fca.assistantThoughts, &biz_entity_chat_prompt_message.ToolPromptMessage{
+				PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{
+					Content: toolArtifact.ToolResponse,
+					Role:    biz_entity_chat_prompt_message.TOOL,
+					Name:    toolArtifact.ToolCallName,
+				},
+				ToolCallID: toolArtifact.ToolCallID,
+			})
 		}
 	}
 
-	if isNormalQuit {
-		for len(resultQueue) > 0 {
-			resultMessage := <-resultQueue
-			fca.handleResultChunk(resultMessage)
+	if len(toolArtifacts) > 0 {
+		observation, meta := fca.getObservationAndMeta(toolArtifacts)
+
+		// after tool call update agent thought
+		agentThought, err = fca.SaveAgentThought(ctx, agentThought, "", nil, "", observation, meta, "", messageFileIDs, nil)
+
+		if err != nil {
+			return err
 		}
+	}
 
-		fca.CloseOutNormalExit()
+	if err := fca.agentFlusher.AgentThoughtToStreamResponse(ctx, agentThought.ID); err != nil {
+		return err
 	}
 
-	if isOccurredErr {
-		for len(resultQueue) > 0 {
-			resultMessage := <-resultQueue
-			fca.handleResultChunk(resultMessage)
-		}
-		fca.CloseOutErr()
+	fca.interactionStep += 1
+	fca.toolCalls = make([]*ToolCall, 0)
+	fca.toolCallNames = ""
+	fca.toolCallInputs = make(map[string]string)
+	fca.fullAssistant = ""
+
+	return nil
+}
+
+// restore restores the runner paused at the agent thought with the approval state saved by runToolCalls.
+func (fca *FunctionCallAgentRunner) restore(state *agentApprovalState, agentThought *po_agent.MessageAgentThought) error {
+	assistantThoughts, err := decodePromptMessages(state.AssistantThoughts)
+
+	if err != nil {
+		return err
 	}
 
-	return agentThought, nil
+	fca.assistantThoughts = assistantThoughts
+	fca.toolCalls = state.ToolCalls
+	fca.toolCallNames = fca.getToolNames(state.ToolCalls)
+	fca.toolCallInputs = fca.getToolInputs(state.ToolCalls)
+	fca.resumeFrom(state, agentThought)
+
+	return nil
 }
 
 func (fca *FunctionCallAgentRunner) handleFinalChunk(message *biz_entity_base_stream_generator.MessageQueueMessage) {
@@ -321,6 +212,7 @@ func (fca *FunctionCallAgentRunner) handleFinalChunk(message *biz_entity_base_st
 		}
 		fca.fullAssistant = mc.LLMResult.Message.GetContent()
 		fca.taskState.LLMResult = mc.LLMResult
+		fca.llmResults = append(fca.llmResults, mc.LLMResult)
 	}
 }
 
@@ -330,6 +222,8 @@ func (fca *FunctionCallAgentRunner) handleResultChunk(message *biz_entity_base_s
 		if err := fca.agentFlusher.AgentMessageToStreamResponse(deltaText.(string)); err != nil {
 			return err
 		}
+	} else if replaceEvent, ok := message.Event.(*biz_entity_base_stream_generator.QueueMessageReplaceEvent); ok {
+		return fca.agentFlusher.MessageReplaceToStreamResponse(replaceEvent.Text)
 	}
 	return nil
 }
@@ -375,26 +269,24 @@ func (fca *FunctionCallAgentRunner) getToolNames(tools []*ToolCall) string {
 	return strings.Join(toolNames, ";")
 }
 
-func (faa *FunctionCallAgentRunner) getObservationAndMeta(toolArtifacts []*biz_agent.ToolArtifact) (map[string]string, map[string]*po_agent.ToolEngineInvokeMeta) {
-
+// getToolInputs returns the inputs of the tools keyed by the tool names, the inputs of the tool called several times
+// in the round are the json array of the arguments of its calls.
+func (fca *FunctionCallAgentRunner) getToolInputs(tools []*ToolCall) map[string]string {
 	var (
-		observation = make(map[string]string)
-		meta        = make(map[string]*po_agent.ToolEngineInvokeMeta)
+		toolInput = make(map[string]string)
+		toolArgs  = make(map[string][]string)
 	)
 
-	for _, artifact := range toolArtifacts {
-		observation[artifact.ToolCallName] = artifact.ToolResponse
-		meta[artifact.ToolCallName] = biz_agent.ConvertToPoMeta(artifact.Meta)
+	for _, tool := range tools {
+		toolArgs[tool.ToolCallName] = append(toolArgs[tool.ToolCallName], tool.TollCallArgs)
 	}
 
-	return observation, meta
-
-}
-func (fca *FunctionCallAgentRunner) getToolInputs(tools []*ToolCall) map[string]string {
-	var toolInput = make(map[string]string)
-
-	for _, tool := range tools {
-		toolInput[tool.ToolCallName] = tool.TollCallArgs
+	for name, args := range toolArgs {
+		if len(args) == 1 {
+			toolInput[name] = args[0]
+			continue
+		}
+		toolInput[name] = "[" + strings.Join(args, ",") + "]"
 	}
 	return toolInput
 }
@@ -404,97 +296,3 @@ func (fca *FunctionCallAgentRunner) organizePromptMessage() {
 		fca.promptMessages = append(fca.promptMessages, fca.assistantThoughts...)
 	}
 }
-
-func (fca *FunctionCallAgentRunner) CreateAgentThought(ctx context.Context, messageID, message, toolName string, toolInput map[string]string, messageFileIDs []string) (*po_agent.MessageAgentThought, error) {
-
-	thoughtObject := &po_agent.MessageAgentThought{
-		MessageID:     messageID,
-		Tool:          toolName,
-		ToolInput:     toolInput,
-		Message:       message,
-		Position:      fca.agentThoughtCount + 1,
-		Currency:      "USD",
-		CreatedByRole: "account",
-		MessageFiles:  messageFileIDs,
-		CreatedBy:     fca.applicationGenerateEntity.EasyUIBasedAppGenerateEntity.UserID,
-	}
-
-	thought, err := fca.agentDomain.AgentRepo.CreateAgentThought(ctx, thoughtObject)
-
-	if err != nil {
-		return nil, err
-	}
-
-	fca.agentThoughtCount += 1
-	return thought, nil
-}
-
-func (fca *FunctionCallAgentRunner) SaveAgentThought(ctx context.Context, agentThought *po_agent.MessageAgentThought, toolName string, toolInput map[string]string, thought string, observation map[string]string, toolInvokeMeta map[string]*po_agent.ToolEngineInvokeMeta, answer string, messageIDs []string, llmUsage *biz_entity_base_stream_generator.LLMUsage) (*po_agent.MessageAgentThought, error) {
-
-	if thought != "" {
-		agentThought.Thought = thought
-	}
-
-	if toolName != "" {
-		agentThought.Tool = toolName
-	}
-
-	if len(toolInput) > 0 {
-		agentThought.ToolInput = toolInput
-	}
-
-	if len(observation) > 0 {
-		agentThought.Observation = observation
-	}
-
-	if answer != "" {
-		agentThought.Answer = answer
-	}
-
-	if len(messageIDs) > 0 {
-		agentThought.MessageFiles = messageIDs
-	}
-
-	if agentThought.ToolMetaStr == nil {
-		agentThought.ToolMetaStr = make(map[string]*po_agent.ToolEngineInvokeMeta, 0)
-	}
-
-	if len(toolInvokeMeta) > 0 {
-		agentThought.ToolMetaStr = toolInvokeMeta
-	}
-
-	if agentThought.Tool != "" {
-		tools := strings.Split(agentThought.Tool, ";")
-
-		labels := agentThought.ToolLabelsStr
-
-		if labels == nil {
-			labels = make(map[string]map[string]any, 0)
-		}
-
-		for _, tool := range tools {
-			if tool == "" {
-				continue
-			}
-
-			_, ok := labels[tool]
-
-			if ok {
-				continue
-			}
-
-			toolRuntime := fca.agentDomain.ToolManager.GetToolByIdentity(tool)
-			if toolRuntime != nil {
-				labels[tool] = map[string]any{"en_US": toolRuntime.Identity.Label.En_US, "zh_Hans": toolRuntime.Identity.Label.Zh_Hans}
-			} else {
-				labels[tool] = map[string]any{"en_US": tool, "zh_Hans": tool}
-			}
-		}
-	}
-
-	if err := fca.agentDomain.UpdateAgentThought(ctx, agentThought); err != nil {
-		return nil, err
-	}
-
-	return agentThought, nil
-}
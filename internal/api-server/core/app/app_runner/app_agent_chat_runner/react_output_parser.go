@@ -0,0 +1,292 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+const (
+	THOUGHT_KEYWORD      = "Thought:"
+	ACTION_KEYWORD       = "Action:"
+	ACTION_INPUT_KEYWORD = "Action Input:"
+	OBSERVATION_KEYWORD  = "Observation:"
+	FINAL_ANSWER_KEYWORD = "Final Answer:"
+	FINAL_ANSWER_ACTION  = "Final Answer"
+	CODE_FENCE           = "```"
+)
+
+type reActSection int
+
+const (
+	thoughtSection reActSection = iota
+	actionSection
+	actionInputSection
+	observationSection
+	finalAnswerSection
+)
+
+var reActKeywords = []struct {
+	keyword string
+	section reActSection
+}{
+	{THOUGHT_KEYWORD, thoughtSection},
+	{ACTION_INPUT_KEYWORD, actionInputSection},
+	{ACTION_KEYWORD, actionSection},
+	{OBSERVATION_KEYWORD, observationSection},
+	{FINAL_ANSWER_KEYWORD, finalAnswerSection},
+}
+
+// ReActAction is the tool call of the round, the input is the json of the tool parameters.
+type ReActAction struct {
+	Name  string
+	Input string
+}
+
+// ReActResult is the parsed output of the round, either the action or the final answer is set.
+type ReActResult struct {
+	Thought     string
+	Action      *ReActAction
+	FinalAnswer string
+}
+
+// ReActOutputParser parses the ReAct output of the model while it is streamed, the keywords are recognized at the
+// start of the lines and the lines inside the code fences are never taken as keywords. Feed returns the text of the
+// thought and the final answer to be streamed to the user, the actions are held back. The start of the line which
+// may still turn into a keyword is held back until it is decided.
+type ReActOutputParser struct {
+	pending      string
+	lineDecided  bool
+	afterKeyword bool
+	inFence      bool
+	section      reActSection
+	sectionStart bool
+	stopped      bool
+	sections     map[reActSection]*strings.Builder
+}
+
+func NewReActOutputParser() *ReActOutputParser {
+	return &ReActOutputParser{
+		section:      thoughtSection,
+		sectionStart: true,
+		sections: map[reActSection]*strings.Builder{
+			thoughtSection:     {},
+			actionSection:      {},
+			actionInputSection: {},
+			observationSection: {},
+			finalAnswerSection: {},
+		},
+	}
+}
+
+// Feed parses the chunk of the output and returns the text to be streamed.
+func (p *ReActOutputParser) Feed(chunk string) string {
+	p.pending += chunk
+	return p.parse(false)
+}
+
+// Flush parses the held back text at the end of the output and returns the text to be streamed.
+func (p *ReActOutputParser) Flush() string {
+	return p.parse(true)
+}
+
+func (p *ReActOutputParser) parse(flush bool) string {
+	var out strings.Builder
+
+	for p.pending != "" {
+		if !p.lineDecided && !p.decideLine(flush) {
+			break
+		}
+
+		var text string
+
+		if i := strings.IndexByte(p.pending, '\n'); i < 0 {
+			text, p.pending = p.pending, ""
+		} else {
+			text, p.pending = p.pending[:i+1], p.pending[i+1:]
+			p.lineDecided = false
+		}
+
+		out.WriteString(p.write(text))
+	}
+
+	return out.String()
+}
+
+// decideLine decides whether the line starts with a keyword or a code fence, it returns false when the line has to
+// be held back for more text. The text following a keyword is checked again for the code fence.
+func (p *ReActOutputParser) decideLine(flush bool) bool {
+	trimmed := strings.TrimLeft(p.pending, " \t")
+	complete := flush || strings.Contains(p.pending, "\n")
+
+	if strings.HasPrefix(trimmed, CODE_FENCE) {
+		p.inFence = !p.inFence
+		p.lineDecided, p.afterKeyword = true, false
+		return true
+	}
+
+	if !p.inFence && !p.afterKeyword {
+		for _, keyword := range reActKeywords {
+			if len(trimmed) >= len(keyword.keyword) && strings.EqualFold(trimmed[:len(keyword.keyword)], keyword.keyword) {
+				p.switchSection(keyword.section)
+				p.pending = trimmed[len(keyword.keyword):]
+				p.afterKeyword = true
+				return p.pending != "" && p.decideLine(flush)
+			}
+		}
+	}
+
+	if !complete && p.mayBecomeKeyword(trimmed) {
+		return false
+	}
+
+	p.lineDecided, p.afterKeyword = true, false
+	return true
+}
+
+func (p *ReActOutputParser) mayBecomeKeyword(trimmed string) bool {
+	if strings.HasPrefix(CODE_FENCE, trimmed) {
+		return true
+	}
+
+	if p.inFence || p.afterKeyword {
+		return false
+	}
+
+	for _, keyword := range reActKeywords {
+		if len(trimmed) < len(keyword.keyword) && strings.EqualFold(keyword.keyword[:len(trimmed)], trimmed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// switchSection starts the section of the keyword, the observation and a second action are made up by the model
+// which didn't stop at the stop words, the rest of the output is ignored.
+func (p *ReActOutputParser) switchSection(section reActSection) {
+	if section == observationSection || (p.sections[actionSection].Len() != 0 && (section == thoughtSection || section == actionSection)) {
+		p.stopped = true
+	}
+
+	p.section = section
+	p.sectionStart = true
+}
+
+func (p *ReActOutputParser) write(text string) string {
+	if p.stopped {
+		return ""
+	}
+
+	if p.sectionStart {
+		if text = strings.TrimLeft(text, " \t\r\n"); text == "" {
+			return ""
+		}
+		p.sectionStart = false
+	}
+
+	p.sections[p.section].WriteString(text)
+
+	if p.section == thoughtSection || p.section == finalAnswerSection {
+		return text
+	}
+
+	return ""
+}
+
+// Result returns the parsed output, the output without any action is taken as the final answer. The action written
+// as a json blob of action and action_input is accepted as well.
+func (p *ReActOutputParser) Result() *ReActResult {
+	var (
+		thought     = strings.TrimSpace(p.sections[thoughtSection].String())
+		action      = strings.TrimSpace(p.sections[actionSection].String())
+		actionInput = strings.TrimSpace(p.sections[actionInputSection].String())
+		finalAnswer = strings.TrimSpace(p.sections[finalAnswerSection].String())
+	)
+
+	if blob := extractJSONObject(action); blob != nil {
+		var actionBlob struct {
+			Action      string          `json:"action"`
+			ActionInput json.RawMessage `json:"action_input"`
+		}
+
+		if err := json.Unmarshal(blob, &actionBlob); err == nil && actionBlob.Action != "" {
+			action, actionInput = actionBlob.Action, string(actionBlob.ActionInput)
+
+			var answer string
+
+			if strings.EqualFold(action, FINAL_ANSWER_ACTION) && json.Unmarshal(actionBlob.ActionInput, &answer) == nil {
+				actionInput = answer
+			}
+		}
+	}
+
+	action = strings.Trim(action, "`'\"[] \t\r\n")
+
+	if strings.EqualFold(action, FINAL_ANSWER_ACTION) {
+		finalAnswer = actionInput
+		action = ""
+	}
+
+	if action == "" {
+		if finalAnswer == "" {
+			finalAnswer = thought
+		}
+		return &ReActResult{Thought: thought, FinalAnswer: finalAnswer}
+	}
+
+	return &ReActResult{
+		Thought: thought,
+		Action: &ReActAction{
+			Name:  action,
+			Input: normalizeActionInput(actionInput),
+		},
+	}
+}
+
+// normalizeActionInput returns the first json object of the input, which may be wrapped in a code fence, the other
+// inputs are returned without the fence.
+func normalizeActionInput(input string) string {
+	if object := extractJSONObject(input); object != nil {
+		return string(object)
+	}
+
+	input = strings.TrimSpace(input)
+
+	if strings.HasPrefix(input, CODE_FENCE) {
+		input = strings.TrimPrefix(input, CODE_FENCE)
+
+		if i := strings.IndexByte(input, '\n'); i >= 0 {
+			input = input[i+1:]
+		}
+		input = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(input), CODE_FENCE))
+	}
+
+	return input
+}
+
+func extractJSONObject(text string) json.RawMessage {
+	i := strings.IndexByte(text, '{')
+
+	if i < 0 {
+		return nil
+	}
+
+	var object json.RawMessage
+
+	if err := json.NewDecoder(strings.NewReader(text[i:])).Decode(&object); err != nil {
+		return nil
+	}
+
+	var compacted bytes.Buffer
+
+	if err := json.Compact(&compacted, object); err != nil {
+		return nil
+	}
+
+	return compacted.Bytes()
+}
@@ -0,0 +1,101 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package app_agent_chat_runner
+
+import (
+	"testing"
+)
+
+func TestReActOutputParser(t *testing.T) {
+	tests := []struct {
+		name        string
+		output      string
+		streamed    string
+		thought     string
+		action      string
+		actionInput string
+		finalAnswer string
+	}{
+		{
+			name:        "action with fenced input",
+			output:      "Thought: I need to search.\nAction: search\nAction Input: ```json\n{\"query\": \"go\"}\n```",
+			streamed:    "I need to search.\n",
+			thought:     "I need to search.",
+			action:      "search",
+			actionInput: `{"query":"go"}`,
+		},
+		{
+			name:        "final answer",
+			output:      "Thought: I know it.\nfinal answer: It is 42.",
+			streamed:    "I know it.\nIt is 42.",
+			thought:     "I know it.",
+			finalAnswer: "It is 42.",
+		},
+		{
+			name:        "no keywords",
+			output:      "The answer is 42.\nThanks",
+			streamed:    "The answer is 42.\nThanks",
+			thought:     "The answer is 42.\nThanks",
+			finalAnswer: "The answer is 42.\nThanks",
+		},
+		{
+			name:        "json blob action",
+			output:      "Thought: Look it up.\nAction:\n```\n{\n  \"action\": \"search\",\n  \"action_input\": {\"q\": 1}\n}\n```\n",
+			streamed:    "Look it up.\n",
+			thought:     "Look it up.",
+			action:      "search",
+			actionInput: `{"q":1}`,
+		},
+		{
+			name:        "json blob final answer",
+			output:      "Thought: Done.\nAction:\n```json\n{\"action\": \"Final Answer\", \"action_input\": \"Bye\"}\n```",
+			streamed:    "Done.\n",
+			thought:     "Done.",
+			finalAnswer: "Bye",
+		},
+		{
+			name:        "made up observation",
+			output:      "Action: `weather`\nAction Input: {\"city\": \"Paris\"} \nObservation: sunny\nThought: Final Answer: sunny",
+			action:      "weather",
+			actionInput: `{"city":"Paris"}`,
+		},
+		{
+			name:        "keywords inside fence",
+			output:      "Action: write\nAction Input:\n```\n{\"text\": \"a\nThought: b\"}\n```",
+			action:      "write",
+			actionInput: "{\"text\": \"a\nThought: b\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, size := range []int{1, 3, 7, len(tt.output)} {
+			parser := NewReActOutputParser()
+
+			var streamed string
+
+			for i := 0; i < len(tt.output); i += size {
+				streamed += parser.Feed(tt.output[i:min(i+size, len(tt.output))])
+			}
+
+			streamed += parser.Flush()
+			result := parser.Result()
+
+			if streamed != tt.streamed {
+				t.Errorf("%s with chunks of %d streamed %q, want %q", tt.name, size, streamed, tt.streamed)
+			}
+
+			if result.Thought != tt.thought || result.FinalAnswer != tt.finalAnswer {
+				t.Errorf("%s with chunks of %d = %+v, want thought %q and final answer %q", tt.name, size, result, tt.thought, tt.finalAnswer)
+			}
+
+			switch {
+			case tt.action == "" && result.Action != nil:
+				t.Errorf("%s with chunks of %d parsed the action %+v", tt.name, size, result.Action)
+			case tt.action != "" && (result.Action == nil || result.Action.Name != tt.action || result.Action.Input != tt.actionInput):
+				t.Errorf("%s with chunks of %d parsed the action %+v, want %s %s", tt.name, size, result.Action, tt.action, tt.actionInput)
+			}
+		}
+	}
+}
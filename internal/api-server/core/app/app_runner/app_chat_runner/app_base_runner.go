@@ -6,12 +6,15 @@ import (
 
 	prompt "github.com/lunarianss/Luna/internal/api-server/core/app/app_prompt"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/token_buffer_memory"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
 	po_entity_app "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
 	po_entity_chat "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
 	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
 	"github.com/lunarianss/Luna/internal/infrastructure/util"
+	"github.com/redis/go-redis/v9"
 
 	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
@@ -45,6 +48,45 @@ func (r *AppBaseChatRunner) OrganizePromptMessage(ctx context.Context, appRecord
 	return promptMessages, stop, err
 }
 
+// FallbackModels converts the resolved fallback chain of the app model config to the fallback models of the caller.
+func (r *AppBaseChatRunner) FallbackModels(redis *redis.Client, modelConfig *biz_entity_provider_config.ModelConfigWithCredentialsEntity) []*model_registry.FallbackModel {
+	fallbacks := make([]*model_registry.FallbackModel, 0, len(modelConfig.Fallbacks))
+
+	for _, fallback := range modelConfig.Fallbacks {
+		fallbacks = append(fallbacks, &model_registry.FallbackModel{
+			Model:        fallback.Model,
+			Provider:     fallback.Provider,
+			Credentials:  fallback.Credentials,
+			ModelRuntime: fallback.ModelTypeInstance,
+			LoadBalancer: r.LoadBalancer(redis, fallback.ProviderModelBundle.Configuration, fallback.ModelTypeInstance.ModelType, fallback.Model),
+		})
+	}
+
+	return fallbacks
+}
+
+// LoadBalancer returns the load balancer of the model, nil is returned when load balancing is not enabled for it.
+func (r *AppBaseChatRunner) LoadBalancer(redis *redis.Client, configuration *biz_entity_provider_config.ProviderConfiguration, modelType common.ModelType, model string) *model_registry.LoadBalancer {
+	modelSetting := configuration.GetLoadBalancingSettings(modelType, model)
+
+	if modelSetting == nil {
+		return nil
+	}
+
+	configs := make([]*model_registry.LoadBalancingConfig, 0, len(modelSetting.LoadBalancingConfigs))
+
+	for _, config := range modelSetting.LoadBalancingConfigs {
+		configs = append(configs, &model_registry.LoadBalancingConfig{
+			ID:          config.ID,
+			Name:        config.Name,
+			Credentials: config.Credentials,
+			Weight:      config.Weight,
+		})
+	}
+
+	return model_registry.NewLoadBalancer(redis, configuration.TenantId, configuration.Provider.Provider, string(modelType), model, modelSetting.LoadBalancingStrategy, configs)
+}
+
 func (r *AppBaseChatRunner) DirectOutStream(applicationGenerateEntity biz_entity_app_generate.BasedAppGenerateEntity, message *po_entity_chat.Message, conversation *po_entity_chat.Conversation, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, text string, promptMessages []*biz_entity_chat_prompt_message.PromptMessage) {
 
 	index := 0
@@ -7,8 +7,13 @@ package app_chat_runner
 import (
 	"context"
 
+	"github.com/lunarianss/Luna/infrastructure/log"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/app_feature"
 	"github.com/lunarianss/Luna/internal/api-server/core/app/token_buffer_memory"
+	"github.com/lunarianss/Luna/internal/api-server/core/llm_cache"
+	"github.com/lunarianss/Luna/internal/api-server/core/moderation"
+	"github.com/lunarianss/Luna/internal/api-server/core/rag/cache_embedding"
+	"github.com/lunarianss/Luna/internal/api-server/core/structured_output"
 	"github.com/lunarianss/Luna/internal/infrastructure/util"
 
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
@@ -19,7 +24,9 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
 	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
 	"github.com/redis/go-redis/v9"
 
 	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
@@ -76,9 +83,66 @@ func (r *appChatRunner) baseRun(ctx context.Context, applicationGenerateEntity *
 		return nil, nil, nil, nil, err
 	}
 
-	modelInstance := model_registry.NewModelRegisterCaller(applicationGenerateEntity.AppConfig.Model.Model, string(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType), applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.Provider.Provider, credentials, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance)
+	// the models which don't take the schema natively are instructed to answer with it in the prompt
+	if structuredOutput := applicationGenerateEntity.AppConfig.StructuredOutput; structuredOutput != nil && !structured_output.SupportsJSONSchema(applicationGenerateEntity.ModelConf.ModelSchema) {
+		promptMessages, err = structured_output.WithInstruction(promptMessages, structuredOutput)
 
-	return modelInstance, promptMessages, stop, appRecord, nil
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+
+	return r.modelCaller(applicationGenerateEntity, credentials, r.llmCache(ctx, applicationGenerateEntity)), promptMessages, stop, appRecord, nil
+}
+
+func (r *appChatRunner) modelCaller(applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, credentials map[string]interface{}, cache model_registry.ILLMCache) model_registry.IModelRegistryCall {
+	return model_registry.NewModelRegisterCallerWithFallbacks(applicationGenerateEntity.AppConfig.Model.Model, string(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType), applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.Provider.Provider, credentials, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance, r.LoadBalancer(r.redis, applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration, applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType, applicationGenerateEntity.AppConfig.Model.Model), r.FallbackModels(r.redis, applicationGenerateEntity.ModelConf), cache)
+}
+
+// llmCache returns nil when the llm cache of the app is disabled, or the embedding model of the semantic mode is
+// not available, in which case the model is invoked as usual.
+func (r *appChatRunner) llmCache(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity) model_registry.ILLMCache {
+	config := applicationGenerateEntity.AppConfig.LLMCache
+
+	if config == nil {
+		return nil
+	}
+
+	if config.Mode != llm_cache.SEMANTIC_MODE {
+		return llm_cache.NewLLMCache(r.redis, applicationGenerateEntity.AppConfig.AppID, config, nil)
+	}
+
+	var (
+		embeddingModel *biz_entity_provider_config.ModelIntegratedInstance
+		err            error
+	)
+
+	if config.EmbeddingProvider != "" {
+		embeddingModel, err = r.ProviderDomain.GetModelInstance(ctx, applicationGenerateEntity.AppConfig.TenantID, config.EmbeddingProvider, config.EmbeddingModel, common.TEXT_EMBEDDING)
+	} else {
+		embeddingModel, err = r.ProviderDomain.GetDefaultModelInstance(ctx, applicationGenerateEntity.AppConfig.TenantID, common.TEXT_EMBEDDING)
+	}
+
+	if err != nil {
+		log.Warnf("llm cache of app %s is skipped, the embedding model is not available: %s", applicationGenerateEntity.AppConfig.AppID, err.Error())
+		return nil
+	}
+
+	embedding := cache_embedding.NewCacheEmbedding(embeddingModel, applicationGenerateEntity.UserID, r.DatasetDomain, nil, r.redis)
+
+	return llm_cache.NewLLMCache(r.redis, applicationGenerateEntity.AppConfig.AppID, config, embedding)
+}
+
+// modelParameters returns the parameters of the model, the schema of the structured output is passed through them
+// when the model takes it natively.
+func (r *appChatRunner) modelParameters(applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity) (map[string]interface{}, error) {
+	structuredOutput := applicationGenerateEntity.AppConfig.StructuredOutput
+
+	if structuredOutput == nil || !structured_output.SupportsJSONSchema(applicationGenerateEntity.ModelConf.ModelSchema) {
+		return applicationGenerateEntity.ModelConf.Parameters, nil
+	}
+
+	return structured_output.ModelParameters(applicationGenerateEntity.ModelConf.Parameters, structuredOutput)
 }
 
 func (r *appChatRunner) Run(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, message *po_entity_chat.Message, conversation *po_entity_chat.Conversation, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue) {
@@ -90,6 +154,18 @@ func (r *appChatRunner) Run(ctx context.Context, applicationGenerateEntity *biz_
 		return
 	}
 
+	appModeration, moderationResult, err := r.ModerationForInputs(ctx, applicationGenerateEntity)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	if moderationResult.Flagged {
+		r.DirectOutStream(applicationGenerateEntity, message, conversation, queueManager, moderationResult.PresetResponse, promptMessages)
+		return
+	}
+
 	if applicationGenerateEntity.Query != "" {
 		annotation, err := r.QueryAppAnnotationToReply(ctx, app, message, applicationGenerateEntity.Query, applicationGenerateEntity.UserID, string(applicationGenerateEntity.InvokeFrom))
 
@@ -110,9 +186,20 @@ func (r *appChatRunner) Run(ctx context.Context, applicationGenerateEntity *biz_
 		}
 	}
 
+	if appModeration != nil && appModeration.OutputsEnabled() {
+		queueManager = moderation.NewOutputModerationQueue(ctx, queueManager, appModeration)
+	}
+
+	modelParameters, err := r.modelParameters(applicationGenerateEntity)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
 	modelInstance.InvokeLLM(ctx, util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
 		return pm
-	}), queueManager, applicationGenerateEntity.ModelConf.Parameters, nil, stop, applicationGenerateEntity.UserID, nil)
+	}), queueManager, modelParameters, nil, stop, applicationGenerateEntity.UserID, nil)
 }
 
 func (r *appChatRunner) RunNonStream(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, message *po_entity_chat.Message, conversation *po_entity_chat.Conversation) (*biz_entity_base_stream_generator.LLMResult, error) {
@@ -123,6 +210,16 @@ func (r *appChatRunner) RunNonStream(ctx context.Context, applicationGenerateEnt
 		return nil, err
 	}
 
+	appModeration, moderationResult, err := r.ModerationForInputs(ctx, applicationGenerateEntity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if moderationResult.Flagged {
+		return r.directOutResult(applicationGenerateEntity, promptMessages, moderationResult.PresetResponse), nil
+	}
+
 	if applicationGenerateEntity.Query != "" {
 		annotation, err := r.QueryAppAnnotationToReply(ctx, app, message, applicationGenerateEntity.Query, applicationGenerateEntity.UserID, string(applicationGenerateEntity.InvokeFrom))
 
@@ -131,20 +228,77 @@ func (r *appChatRunner) RunNonStream(ctx context.Context, applicationGenerateEnt
 		}
 
 		if annotation != nil {
-			return &biz_entity_base_stream_generator.LLMResult{
-				Model: applicationGenerateEntity.ModelConf.Model,
-				PromptMessage: util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
-					return pm
-				}),
-				Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(annotation.Content),
-				Usage:   biz_entity_base_stream_generator.NewEmptyLLMUsage(),
-			}, nil
+			return r.directOutResult(applicationGenerateEntity, promptMessages, annotation.Content), nil
 		}
 	}
 
-	return modelInstance.InvokeLLMNonStream(ctx, util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
+	modelParameters, err := r.modelParameters(applicationGenerateEntity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	llmResult, err := modelInstance.InvokeLLMNonStream(ctx, util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
 		return pm
-	}), applicationGenerateEntity.ModelConf.Parameters, nil, stop, applicationGenerateEntity.UserID, nil)
+	}), modelParameters, nil, stop, applicationGenerateEntity.UserID, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if appModeration != nil && appModeration.OutputsEnabled() {
+		if answer, ok := llmResult.Message.Content.(string); ok {
+			moderationResult, err := appModeration.ModerationForOutputs(ctx, answer)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if moderationResult.Flagged {
+				llmResult.Message = biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(moderationResult.PresetResponse)
+			}
+		}
+	}
+
+	return llmResult, nil
+}
+
+// RepairNonStream asks the model to correct the answer of the result which doesn't conform to the schema of the
+// structured output, the answer and the validation error are appended to the prompt messages of the result.
+func (r *appChatRunner) RepairNonStream(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, llmResult *biz_entity_base_stream_generator.LLMResult, validateErr error) (*biz_entity_base_stream_generator.LLMResult, error) {
+	credentials, err := applicationGenerateEntity.ModelConf.ProviderModelBundle.Configuration.GetCurrentCredentials(applicationGenerateEntity.ModelConf.ProviderModelBundle.ModelTypeInstance.ModelType, applicationGenerateEntity.AppConfig.Model.Model)
+
+	if err != nil {
+		return nil, err
+	}
+
+	modelParameters, err := r.modelParameters(applicationGenerateEntity)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptMessages := make([]biz_entity_chat_prompt_message.IPromptMessage, 0, len(llmResult.PromptMessage)+2)
+	promptMessages = append(promptMessages, llmResult.PromptMessage...)
+	promptMessages = append(promptMessages, biz_entity_chat_prompt_message.NewAssistantMessage(llmResult.Message.Content), biz_entity_chat_prompt_message.NewUserMessage(structured_output.RepairInstruction(validateErr)))
+
+	// the repairs are not cached, the prompt messages contain the invalid answer
+	return r.modelCaller(applicationGenerateEntity, credentials, nil).InvokeLLMNonStream(ctx, promptMessages, modelParameters, nil, nil, applicationGenerateEntity.UserID, nil)
+}
+
+func (r *appChatRunner) directOutResult(applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity, promptMessages []*biz_entity_chat_prompt_message.PromptMessage, text string) *biz_entity_base_stream_generator.LLMResult {
+	return &biz_entity_base_stream_generator.LLMResult{
+		Model: applicationGenerateEntity.ModelConf.Model,
+		PromptMessage: util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
+			return pm
+		}),
+		Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(text),
+		Usage:   biz_entity_base_stream_generator.NewEmptyLLMUsage(),
+	}
+}
+
+func (r *appChatRunner) ModerationForInputs(ctx context.Context, applicationGenerateEntity *biz_entity_app_generate.ChatAppGenerateEntity) (moderation.IModeration, *moderation.ModerationResult, error) {
+	return app_feature.NewInputModerationFeature(r.ProviderDomain).Check(ctx, applicationGenerateEntity.AppConfig.AppConfig, applicationGenerateEntity.Inputs, applicationGenerateEntity.Query)
 }
 
 func (r *appChatRunner) QueryAppAnnotationToReply(ctx context.Context, appRecord *po_entity.App, message *po_entity_chat.Message, query, accountID, invokeFrom string) (*po_entity_chat.MessageAnnotation, error) {
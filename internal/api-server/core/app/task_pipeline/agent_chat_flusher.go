@@ -164,6 +164,31 @@ func (tpp *agentChatFlusher) AgentMessageToStreamResponse(answer string) error {
 	return nil
 }
 
+func (tpp *agentChatFlusher) MessageReplaceToStreamResponse(answer string) error {
+	messageReplaceResponse := &biz_entity_base_stream_generator.AgentMessageStreamResponse{
+		ID:     tpp.message.ID,
+		Answer: answer,
+		StreamResponse: &biz_entity_base_stream_generator.StreamResponse{
+			TaskID: tpp.GetTaskID(),
+			Event:  biz_entity_base_stream_generator.StreamEventMessageReplace,
+		},
+	}
+
+	chatBotResponse := biz_entity_base_stream_generator.NewAgentChatBotAppStreamResponse(tpp.GetConversationID(), tpp.message.ID, tpp.message.CreatedAt, messageReplaceResponse)
+
+	streamBytes, err := json.Marshal(chatBotResponse)
+
+	if err != nil {
+		return err
+	}
+
+	if err := tpp.flush(string(streamBytes)); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (tpp *agentChatFlusher) AgentThoughtToStreamResponse(ctx context.Context, agentThoughtID string) error {
 	agentThought, err := tpp.agentRepo.GetAgentThoughtByID(ctx, agentThoughtID)
 
@@ -200,3 +225,45 @@ func (tpp *agentChatFlusher) AgentThoughtToStreamResponse(ctx context.Context, a
 
 	return nil
 }
+
+func (tpp *agentChatFlusher) AgentApprovalRequiredToStreamResponse(ctx context.Context, agentThoughtID string) error {
+	agentThought, err := tpp.agentRepo.GetAgentThoughtByID(ctx, agentThoughtID)
+
+	if err != nil {
+		return err
+	}
+
+	approvalResp := &biz_entity_base_stream_generator.AgentApprovalRequiredStreamResponse{
+		StreamResponse: &biz_entity_base_stream_generator.StreamResponse{
+			TaskID: tpp.GetTaskID(),
+			Event:  biz_entity_base_stream_generator.StreamEventAgentApprovalRequired,
+		},
+		ID:               agentThoughtID,
+		Position:         agentThought.Position,
+		PendingToolCalls: make([]*biz_entity_base_stream_generator.ApprovalToolCallItem, 0),
+	}
+
+	for _, toolCallID := range agentThought.PendingApprovals() {
+		approval := agentThought.ToolApprovals[toolCallID]
+
+		approvalResp.PendingToolCalls = append(approvalResp.PendingToolCalls, &biz_entity_base_stream_generator.ApprovalToolCallItem{
+			ToolCallID: toolCallID,
+			ToolName:   approval.ToolName,
+			ToolInput:  approval.ToolInput,
+		})
+	}
+
+	chatBotResponse := biz_entity_base_stream_generator.NewAgentApprovalRequiredBotAppStreamResponse(tpp.GetConversationID(), tpp.message.ID, tpp.message.CreatedAt, approvalResp)
+
+	streamBytes, err := json.Marshal(chatBotResponse)
+
+	if err != nil {
+		return err
+	}
+
+	if err := tpp.flush(string(streamBytes)); err != nil {
+		return err
+	}
+
+	return nil
+}
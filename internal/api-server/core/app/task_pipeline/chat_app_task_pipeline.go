@@ -15,6 +15,7 @@ import (
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
 	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
 	"github.com/lunarianss/Luna/internal/api-server/domain/chat/repository"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
 	"github.com/lunarianss/Luna/internal/infrastructure/util"
@@ -27,17 +28,19 @@ type chatAppTaskPipeline struct {
 	Message                *po_entity.Message
 	MessageRepo            repository.MessageRepo
 	AnnotationRepo         repository.AnnotationRepo
+	ProviderDomain         *domain_service.ProviderDomain
 	flusher                http.Flusher
 	sender                 io.Writer
 	taskState              *biz_entity_base_stream_generator.ChatAppTaskState
 }
 
-func NewNonStreamTaskPipeline(applicationGenerateEntity biz_entity_app_generate.BasedAppGenerateEntity, messageRepo repository.MessageRepo, message *po_entity.Message, llmResult *biz_entity_base_stream_generator.LLMResult, annotationRepo repository.AnnotationRepo) *chatAppTaskPipeline {
+func NewNonStreamTaskPipeline(applicationGenerateEntity biz_entity_app_generate.BasedAppGenerateEntity, messageRepo repository.MessageRepo, message *po_entity.Message, llmResult *biz_entity_base_stream_generator.LLMResult, annotationRepo repository.AnnotationRepo, providerDomain *domain_service.ProviderDomain) *chatAppTaskPipeline {
 	return &chatAppTaskPipeline{
 		BasedAppGenerateEntity: applicationGenerateEntity,
 		Message:                message,
 		MessageRepo:            messageRepo,
 		AnnotationRepo:         annotationRepo,
+		ProviderDomain:         providerDomain,
 		taskState: &biz_entity_base_stream_generator.ChatAppTaskState{
 			LLMResult: llmResult,
 		},
@@ -48,7 +51,7 @@ func NewChatAppTaskPipeline(
 	applicationGenerateEntity biz_entity_app_generate.BasedAppGenerateEntity,
 	streamResultChunkQueue chan *biz_entity_base_stream_generator.MessageQueueMessage,
 	streamFinalChunkQueue chan *biz_entity_base_stream_generator.MessageQueueMessage,
-	messageRepo repository.MessageRepo, message *po_entity.Message, annotationRepo repository.AnnotationRepo) *chatAppTaskPipeline {
+	messageRepo repository.MessageRepo, message *po_entity.Message, annotationRepo repository.AnnotationRepo, providerDomain *domain_service.ProviderDomain) *chatAppTaskPipeline {
 	return &chatAppTaskPipeline{
 		BasedAppGenerateEntity: applicationGenerateEntity,
 		StreamResultChunkQueue: streamResultChunkQueue,
@@ -56,6 +59,7 @@ func NewChatAppTaskPipeline(
 		Message:                message,
 		MessageRepo:            messageRepo,
 		AnnotationRepo:         annotationRepo,
+		ProviderDomain:         providerDomain,
 		taskState: &biz_entity_base_stream_generator.ChatAppTaskState{
 			LLMResult: biz_entity_base_stream_generator.NewEmptyLLMResult(),
 		},
@@ -128,6 +132,13 @@ func (tpp *chatAppTaskPipeline) process_stream_chunk_queue(c context.Context) {
 				log.Errorf("failed to flush message to stream response: %v", err)
 				tpp.sendFallBackMessageEnd()
 			}
+		} else if replaceEvent, ok := v.Event.(*biz_entity_base_stream_generator.QueueMessageReplaceEvent); ok {
+			tpp.taskState.LLMResult.Message.Content = replaceEvent.Text
+
+			if err := tpp.messageReplaceToStreamResponse(replaceEvent.Text); err != nil {
+				log.Errorf("failed to flush message to stream response: %v", err)
+				tpp.sendFallBackMessageEnd()
+			}
 		} else if chunkEvent, ok := v.Event.(*biz_entity_base_stream_generator.QueueAnnotationReplyEvent); ok {
 
 			annotation, err := tpp.AnnotationRepo.GetAnnotationByID(c, chunkEvent.MessageAnnotationID)
@@ -209,6 +220,29 @@ func (tpp *chatAppTaskPipeline) messageChunkToStreamResponse(answer string) erro
 	return nil
 }
 
+// messageReplaceToStreamResponse tells the client to replace the answer it has received with the answer.
+func (tpp *chatAppTaskPipeline) messageReplaceToStreamResponse(answer string) error {
+	messageReplaceResponse := &biz_entity_base_stream_generator.MessageStreamResponse{
+		ID:                   tpp.Message.ID,
+		Answer:               answer,
+		FromVariableSelector: make([]string, 0),
+		StreamResponse: &biz_entity_base_stream_generator.StreamResponse{
+			TaskID: tpp.GetTaskID(),
+			Event:  biz_entity_base_stream_generator.StreamEventMessageReplace,
+		},
+	}
+
+	chatBotResponse := biz_entity_base_stream_generator.NewChatBotAppStreamResponse(tpp.GetConversationID(), tpp.Message.ID, tpp.Message.CreatedAt, messageReplaceResponse)
+
+	streamBytes, err := json.Marshal(chatBotResponse)
+
+	if err != nil {
+		return err
+	}
+
+	return tpp.flush(string(streamBytes))
+}
+
 func (tpp *chatAppTaskPipeline) messageErrToStreamResponse(ctx context.Context, err error) error {
 
 	var errStr = "Internal Server Error, please contact support."
@@ -217,6 +251,10 @@ func (tpp *chatAppTaskPipeline) messageErrToStreamResponse(ctx context.Context,
 		errStr = "Your quota for Luna Hosted Model Provider has been exhausted. Please go to Settings -> Model Provider to complete your own provider credentials."
 	}
 
+	if errors.IsCode(err, code.ErrProviderQuotaExceed) {
+		errStr = "Your quota of the model provider has been exhausted. Please contact the administrator of the workspace to reset the quota."
+	}
+
 	messageRecord, err := tpp.MessageRepo.GetMessageByID(ctx, tpp.Message.ID)
 
 	if err != nil {
@@ -310,11 +348,48 @@ func (tpp *chatAppTaskPipeline) saveMessage(c context.Context) error {
 	messageRecord.AnswerUnitPrice = tpp.taskState.LLMResult.Usage.CompletionUnitPrice
 	messageRecord.TotalPrice = tpp.taskState.LLMResult.Usage.TotalPrice
 	messageRecord.Currency = tpp.taskState.LLMResult.Usage.Currency
+
+	// the model actually used differs from the app model config when the fallback chain was gone down
+	if tpp.taskState.LLMResult.Provider != "" {
+		messageRecord.ModelProvider = tpp.taskState.LLMResult.Provider
+		messageRecord.ModelID = tpp.taskState.LLMResult.Model
+	}
+
+	if len(tpp.taskState.LLMResult.Fallbacks) > 0 {
+		if tpp.taskState.Metadata == nil {
+			tpp.taskState.Metadata = make(map[string]interface{})
+		}
+		tpp.taskState.Metadata["fallbacks"] = tpp.taskState.LLMResult.Fallbacks
+	}
+
+	if tpp.taskState.LLMResult.CacheHit {
+		if tpp.taskState.Metadata == nil {
+			tpp.taskState.Metadata = make(map[string]interface{})
+		}
+		tpp.taskState.Metadata["cache_hit"] = true
+	}
+
 	messageRecord.MessageMetadata = tpp.taskState.Metadata
 
 	if err := tpp.MessageRepo.UpdateMessage(c, messageRecord); err != nil {
 		return err
 	}
 
+	tpp.deductQuota(c)
+
 	return nil
 }
+
+// deductQuota deducts the usage from the quota of the provider which served the call, nothing is deducted for
+// the answers which didn't call the model.
+func (tpp *chatAppTaskPipeline) deductQuota(c context.Context) {
+	llmResult := tpp.taskState.LLMResult
+
+	if !llmResult.Generated() {
+		return
+	}
+
+	if err := tpp.ProviderDomain.DeductQuota(c, tpp.GetTenantID(), llmResult.Provider, llmResult.Model, llmResult.Usage.TotalTokens); err != nil {
+		log.Errorf("failed to deduct quota of provider %s: %v", llmResult.Provider, err)
+	}
+}
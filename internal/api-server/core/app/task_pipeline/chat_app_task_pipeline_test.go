@@ -0,0 +1,102 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package task_pipeline
+
+import (
+	"context"
+	"testing"
+
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/chat/repository"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	po_provider "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	repo_provider "github.com/lunarianss/Luna/internal/api-server/domain/provider/repository"
+)
+
+type fakeGenerateEntity struct {
+	biz_entity_app_generate.BasedAppGenerateEntity
+}
+
+func (e *fakeGenerateEntity) GetTenantID() string {
+	return "tenant-1"
+}
+
+type fakeMessageRepo struct {
+	repository.MessageRepo
+}
+
+func (mr *fakeMessageRepo) GetMessageByID(ctx context.Context, messageID string) (*po_entity.Message, error) {
+	return &po_entity.Message{ID: messageID}, nil
+}
+
+func (mr *fakeMessageRepo) UpdateMessage(ctx context.Context, message *po_entity.Message) error {
+	return nil
+}
+
+type fakeQuotaProviderRepo struct {
+	repo_provider.ProviderRepo
+	used []int64
+}
+
+func (pr *fakeQuotaProviderRepo) GetTenantProvider(ctx context.Context, tenant string, providerName string, providerType string) (*po_provider.Provider, error) {
+	quotaLimit := int64(10)
+
+	return &po_provider.Provider{
+		ProviderName: providerName,
+		ProviderType: string(po_provider.CUSTOM),
+		QuotaType:    string(po_provider.TRIAL),
+		QuotaUnit:    string(biz_entity_provider_config.TIMES),
+		QuotaLimit:   &quotaLimit,
+	}, nil
+}
+
+func (pr *fakeQuotaProviderRepo) IncreaseProviderQuotaUsed(ctx context.Context, tenantID string, providerName string, quotaType string, used int64) error {
+	pr.used = append(pr.used, used)
+	return nil
+}
+
+func TestProcessNonStreamDeductQuota(t *testing.T) {
+	answer := func(provider string, cacheHit bool) *biz_entity_base_stream_generator.LLMResult {
+		return &biz_entity_base_stream_generator.LLMResult{
+			Model:         "gpt-4o",
+			Provider:      provider,
+			CacheHit:      cacheHit,
+			Message:       biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("answer"),
+			PromptMessage: make([]biz_entity_chat_prompt_message.IPromptMessage, 0),
+			Usage:         biz_entity_base_stream_generator.NewEmptyLLMUsage(),
+		}
+	}
+
+	tests := []struct {
+		name      string
+		llmResult *biz_entity_base_stream_generator.LLMResult
+		want      int
+	}{
+		{name: "generated", llmResult: answer("openai", false), want: 1},
+		{name: "cache hit", llmResult: answer("openai", true)},
+		// the preset responses of the moderation and the annotation replies are answered without the model
+		{name: "direct answer", llmResult: answer("", false)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerRepo := &fakeQuotaProviderRepo{}
+
+			tpp := NewNonStreamTaskPipeline(&fakeGenerateEntity{}, &fakeMessageRepo{}, &po_entity.Message{ID: "message-1"}, tt.llmResult, nil, &domain_service.ProviderDomain{ProviderRepo: providerRepo})
+
+			if err := tpp.ProcessNonStream(context.Background()); err != nil {
+				t.Fatalf("ProcessNonStream() error = %v", err)
+			}
+
+			if len(providerRepo.used) != tt.want {
+				t.Errorf("deducted %d times, want %d", len(providerRepo.used), tt.want)
+			}
+		})
+	}
+}
@@ -52,7 +52,7 @@ func (s *tokenBufferMemory) GetHistoryPromptMessage(ctx context.Context, maxToke
 
 	messages = s.extractThreadMessage(messages)
 
-	if messages[0].Answer == "" {
+	if len(messages) > 0 && messages[0].Answer == "" {
 		messages = messages[1:]
 	}
 
@@ -62,7 +62,22 @@ func (s *tokenBufferMemory) GetHistoryPromptMessage(ctx context.Context, maxToke
 		promptMessages = append(promptMessages, biz_entity_chat_prompt_message.NewUserMessage(message.Query), biz_entity_chat_prompt_message.NewAssistantMessage(message.Answer))
 	}
 
-	return promptMessages, nil
+	return s.pruneHistoryPromptMessage(promptMessages, maxTokenLimit), nil
+}
+
+// pruneHistoryPromptMessage drops the oldest rounds of the conversation until the histories fit in maxTokenLimit,
+// a round of the user query and the assistant answer is dropped together.
+func (s *tokenBufferMemory) pruneHistoryPromptMessage(promptMessages []*biz_entity_chat_prompt_message.PromptMessage, maxTokenLimit int) []*biz_entity_chat_prompt_message.PromptMessage {
+	for len(promptMessages) > 0 && s.numTokens(promptMessages) > maxTokenLimit {
+		promptMessages = promptMessages[min(2, len(promptMessages)):]
+	}
+	return promptMessages
+}
+
+func (s *tokenBufferMemory) numTokens(promptMessages []*biz_entity_chat_prompt_message.PromptMessage) int {
+	return s.modelRegistryCaller.GetLLMNumTokens(util.ConvertToInterfaceSlice(promptMessages, func(pm *biz_entity_chat_prompt_message.PromptMessage) biz_entity_chat_prompt_message.IPromptMessage {
+		return pm
+	}), nil)
 }
 
 func (s *tokenBufferMemory) extractThreadMessage(messages []*po_entity.Message) []*po_entity.Message {
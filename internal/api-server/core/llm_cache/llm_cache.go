@@ -0,0 +1,331 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm_cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	EXACT_MODE    = "exact"
+	SEMANTIC_MODE = "semantic"
+
+	// DEFAULT_TTL and MAX_TTL are in seconds
+	DEFAULT_TTL                  = 24 * 60 * 60
+	MAX_TTL                      = 30 * 24 * 60 * 60
+	DEFAULT_SIMILARITY_THRESHOLD = 0.95
+
+	// MAX_SEMANTIC_ENTRIES bounds the number of the answers which a query is compared with, the oldest ones
+	// are evicted first.
+	MAX_SEMANTIC_ENTRIES = 200
+)
+
+// IQueryEmbedding embeds the query of the semantic cache, which is satisfied by cache_embedding.ICacheEmbedding.
+type IQueryEmbedding interface {
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+type cachedAnswer struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Answer    string    `json:"answer"`
+	Reason    string    `json:"reason"`
+	Embedding []float32 `json:"embedding,omitempty"`
+	ExpiredAt int64     `json:"expired_at"`
+}
+
+type llmCache struct {
+	redis     *redis.Client
+	appID     string
+	config    *biz_entity_app_config.LLMCacheEntity
+	embedding IQueryEmbedding
+}
+
+// NewLLMCache creates the llm cache of the app, the embedding is only used by the semantic mode.
+func NewLLMCache(redis *redis.Client, appID string, config *biz_entity_app_config.LLMCacheEntity, embedding IQueryEmbedding) model_registry.ILLMCache {
+	return &llmCache{
+		redis:     redis,
+		appID:     appID,
+		config:    config,
+		embedding: embedding,
+	}
+}
+
+func (c *llmCache) ttl() time.Duration {
+	if c.config.TTL <= 0 {
+		return DEFAULT_TTL * time.Second
+	}
+	return time.Duration(c.config.TTL) * time.Second
+}
+
+func (c *llmCache) Get(ctx context.Context, request *model_registry.LLMCacheRequest) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var (
+		answer *cachedAnswer
+		err    error
+	)
+
+	if c.config.Mode == SEMANTIC_MODE {
+		answer, err = c.getSemantic(ctx, request)
+	} else {
+		answer, err = c.getExact(ctx, request)
+	}
+
+	recordLookup(c.appID, c.config.Mode, answer, err)
+
+	if err != nil || answer == nil {
+		return nil, err
+	}
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		Model:    answer.Model,
+		Message:  biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(answer.Answer),
+		Reason:   answer.Reason,
+		Provider: answer.Provider,
+	}, nil
+}
+
+func (c *llmCache) Set(ctx context.Context, request *model_registry.LLMCacheRequest, llmResult *biz_entity_base_stream_generator.LLMResult) error {
+	answer := &cachedAnswer{
+		Provider:  llmResult.Provider,
+		Model:     llmResult.Model,
+		Answer:    llmResult.Message.GetContent(),
+		Reason:    llmResult.Reason,
+		ExpiredAt: time.Now().Add(c.ttl()).Unix(),
+	}
+
+	if c.config.Mode == SEMANTIC_MODE {
+		return c.setSemantic(ctx, request, answer)
+	}
+
+	return c.setExact(ctx, request, answer)
+}
+
+func (c *llmCache) exactKey(request *model_registry.LLMCacheRequest) (string, error) {
+	hash, err := hashRequest(request, request.PromptMessages)
+
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("llm_cache:exact:%s:%s", c.appID, hash), nil
+}
+
+func (c *llmCache) getExact(ctx context.Context, request *model_registry.LLMCacheRequest) (*cachedAnswer, error) {
+	key, err := c.exactKey(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	val, err := c.redis.Get(ctx, key).Bytes()
+
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrRedis, err.Error())
+	}
+
+	answer := &cachedAnswer{}
+
+	if err := json.Unmarshal(val, answer); err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	return answer, nil
+}
+
+func (c *llmCache) setExact(ctx context.Context, request *model_registry.LLMCacheRequest, answer *cachedAnswer) error {
+	key, err := c.exactKey(request)
+
+	if err != nil {
+		return err
+	}
+
+	val, err := json.Marshal(answer)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	if err := c.redis.SetEx(ctx, key, val, c.ttl()).Err(); err != nil {
+		return errors.WithSCode(code.ErrRedis, err.Error())
+	}
+
+	return nil
+}
+
+// semanticKey returns the key of the answers which share the messages before the query, the query is the final
+// user message. The key is empty when the invocation doesn't end with a user message.
+func (c *llmCache) semanticKey(request *model_registry.LLMCacheRequest) (string, string, error) {
+	messages := request.PromptMessages
+
+	if len(messages) == 0 || messages[len(messages)-1].GetRole() != string(biz_entity_chat_prompt_message.USER) {
+		return "", "", nil
+	}
+
+	query := normalizeText(messages[len(messages)-1].GetContent())
+
+	if query == "" {
+		return "", "", nil
+	}
+
+	hash, err := hashRequest(request, messages[:len(messages)-1])
+
+	if err != nil {
+		return "", "", err
+	}
+
+	return fmt.Sprintf("llm_cache:semantic:%s:%s", c.appID, hash), query, nil
+}
+
+func (c *llmCache) getSemantic(ctx context.Context, request *model_registry.LLMCacheRequest) (*cachedAnswer, error) {
+	key, query, err := c.semanticKey(request)
+
+	if err != nil || key == "" {
+		return nil, err
+	}
+
+	embedding, err := c.embedding.EmbedQuery(ctx, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Embedding = embedding
+
+	vals, err := c.redis.LRange(ctx, key, 0, MAX_SEMANTIC_ENTRIES-1).Result()
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRedis, err.Error())
+	}
+
+	var (
+		best      *cachedAnswer
+		bestScore = c.config.SimilarityThreshold
+		now       = time.Now().Unix()
+	)
+
+	if bestScore <= 0 {
+		bestScore = DEFAULT_SIMILARITY_THRESHOLD
+	}
+
+	for _, val := range vals {
+		answer := &cachedAnswer{}
+
+		if err := json.Unmarshal([]byte(val), answer); err != nil || answer.ExpiredAt < now {
+			continue
+		}
+
+		if score := cosineSimilarity(embedding, answer.Embedding); score >= bestScore {
+			best, bestScore = answer, score
+		}
+	}
+
+	return best, nil
+}
+
+func (c *llmCache) setSemantic(ctx context.Context, request *model_registry.LLMCacheRequest, answer *cachedAnswer) error {
+	key, _, err := c.semanticKey(request)
+
+	if err != nil || key == "" || request.Embedding == nil {
+		return err
+	}
+
+	answer.Embedding = request.Embedding
+
+	val, err := json.Marshal(answer)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	pipe := c.redis.TxPipeline()
+	pipe.LPush(ctx, key, val)
+	pipe.LTrim(ctx, key, 0, MAX_SEMANTIC_ENTRIES-1)
+	pipe.Expire(ctx, key, c.ttl())
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return errors.WithSCode(code.ErrRedis, err.Error())
+	}
+
+	return nil
+}
+
+// hashRequest hashes the provider, the model, the parameters, the stop words and the messages, whose text contents
+// are normalized so that the differences of the whitespaces don't miss the cache.
+func hashRequest(request *model_registry.LLMCacheRequest, messages []biz_entity_chat_prompt_message.IPromptMessage) (string, error) {
+	normalizedMessages := make([]map[string]interface{}, 0, len(messages))
+
+	for _, message := range messages {
+		data, err := message.ConvertToRequestData()
+
+		if err != nil {
+			return "", err
+		}
+
+		if content, ok := data["content"].(string); ok {
+			data["content"] = normalizeText(content)
+		}
+
+		normalizedMessages = append(normalizedMessages, data)
+	}
+
+	// the keys of the maps are sorted by json
+	payload, err := json.Marshal(map[string]interface{}{
+		"provider":   request.Provider,
+		"model":      request.Model,
+		"parameters": request.Parameters,
+		"stop":       request.Stop,
+		"messages":   normalizedMessages,
+	})
+
+	if err != nil {
+		return "", errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	sum := sha256.Sum256(payload)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func normalizeText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,34 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm_cache
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheLookups counts the lookups of the llm cache by the result (hit, miss or error), the hit rate of an app is
+// sum(rate(luna_llm_cache_lookups_total{result="hit"}[5m])) / sum(rate(luna_llm_cache_lookups_total[5m])).
+var cacheLookups = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "luna",
+	Subsystem: "llm_cache",
+	Name:      "lookups_total",
+	Help:      "The lookups of the llm response cache by app, mode and result.",
+}, []string{"app_id", "mode", "result"})
+
+func init() {
+	prometheus.MustRegister(cacheLookups)
+}
+
+func recordLookup(appID, mode string, answer *cachedAnswer, err error) {
+	result := "miss"
+
+	if err != nil {
+		result = "error"
+	} else if answer != nil {
+		result = "hit"
+	}
+
+	cacheLookups.WithLabelValues(appID, mode, result).Inc()
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm_cache
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+)
+
+func TestHashRequest(t *testing.T) {
+	request := func(query string, temperature float64) *model_registry.LLMCacheRequest {
+		return &model_registry.LLMCacheRequest{
+			Provider: "openai",
+			Model:    "gpt-4o",
+			PromptMessages: []biz_entity_chat_prompt_message.IPromptMessage{
+				biz_entity_chat_prompt_message.NewSystemMessage("You are Luna."),
+				biz_entity_chat_prompt_message.NewUserMessage(query),
+			},
+			Parameters: map[string]interface{}{"temperature": temperature, "max_tokens": 512},
+		}
+	}
+
+	hash := func(request *model_registry.LLMCacheRequest) string {
+		h, err := hashRequest(request, request.PromptMessages)
+
+		if err != nil {
+			t.Fatalf("hashRequest() error = %v", err)
+		}
+		return h
+	}
+
+	base := hash(request("How do I reset my password?", 0.7))
+
+	if hash(request("  How do I reset\n my password? ", 0.7)) != base {
+		t.Errorf("hashRequest() differs by whitespaces")
+	}
+
+	if hash(request("How do I reset my password?", 0.2)) == base {
+		t.Errorf("hashRequest() doesn't differ by parameters")
+	}
+
+	if hash(request("How do I delete my account?", 0.7)) == base {
+		t.Errorf("hashRequest() doesn't differ by query")
+	}
+
+	semantic := &llmCache{appID: "app"}
+	keyA, queryA, _ := semantic.semanticKey(request("How do I reset my password?", 0.7))
+	keyB, queryB, _ := semantic.semanticKey(request("How can I reset  my password?", 0.7))
+
+	if keyA == "" || keyA != keyB || queryB != "How can I reset my password?" || queryA == queryB {
+		t.Errorf("semanticKey() = %s %q, %s %q, want the same key of the queries", keyA, queryA, keyB, queryB)
+	}
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	if score := cosineSimilarity([]float32{1, 2, 3}, []float32{2, 4, 6}); math.Abs(score-1) > 1e-6 {
+		t.Errorf("cosineSimilarity() = %v, want 1", score)
+	}
+
+	if score := cosineSimilarity([]float32{1, 0}, []float32{0, 1}); score != 0 {
+		t.Errorf("cosineSimilarity() = %v, want 0", score)
+	}
+
+	if score := cosineSimilarity([]float32{1, 0}, []float32{1}); score != 0 {
+		t.Errorf("cosineSimilarity() of different dimensions = %v, want 0", score)
+	}
+}
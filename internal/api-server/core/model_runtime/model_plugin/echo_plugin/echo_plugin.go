@@ -0,0 +1,216 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package echo_plugin is a sample model plugin, its llm answers with the last user message, which
+// makes it handy for testing the plugin protocol end to end. The schema of the provider is in the
+// echo directory next to this file.
+package echo_plugin
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	PROVIDER = "echo"
+	API_KEY  = "echo"
+)
+
+type echoPlugin struct {
+	pb.UnimplementedModelPluginServer
+}
+
+var _ pb.ModelPluginServer = (*echoPlugin)(nil)
+
+func NewEchoPlugin() *echoPlugin {
+	return &echoPlugin{}
+}
+
+// Serve serves the echo plugin on the listener until it's closed.
+func Serve(lis net.Listener) error {
+	server := grpc.NewServer()
+	pb.RegisterModelPluginServer(server, NewEchoPlugin())
+	return server.Serve(lis)
+}
+
+func (p *echoPlugin) Describe(ctx context.Context, request *pb.DescribeRequest) (*pb.DescribeResponse, error) {
+	return &pb.DescribeResponse{
+		Provider:   PROVIDER,
+		ModelTypes: []string{"llm", "text-embedding", "rerank"},
+	}, nil
+}
+
+func (p *echoPlugin) ValidateCredentials(ctx context.Context, request *pb.ValidateCredentialsRequest) (*pb.ValidateCredentialsResponse, error) {
+	if err := checkCredentials(request.Credentials); err != nil {
+		return nil, err
+	}
+
+	return &pb.ValidateCredentialsResponse{}, nil
+}
+
+func (p *echoPlugin) Invoke(ctx context.Context, request *pb.InvokeRequest) (*pb.InvokeResponse, error) {
+	if err := checkCredentials(request.Credentials); err != nil {
+		return nil, err
+	}
+
+	answer, promptTokens := echo(request)
+
+	return &pb.InvokeResponse{
+		Content:      answer,
+		FinishReason: "stop",
+		Usage:        &pb.Usage{PromptTokens: promptTokens, CompletionTokens: countTokens(answer)},
+	}, nil
+}
+
+// InvokeStream sends the answer word by word.
+func (p *echoPlugin) InvokeStream(request *pb.InvokeRequest, stream pb.ModelPlugin_InvokeStreamServer) error {
+	if err := checkCredentials(request.Credentials); err != nil {
+		return err
+	}
+
+	answer, promptTokens := echo(request)
+
+	for _, word := range strings.SplitAfter(answer, " ") {
+		if err := stream.Send(&pb.InvokeChunk{Content: word}); err != nil {
+			return err
+		}
+	}
+
+	return stream.Send(&pb.InvokeChunk{
+		FinishReason: "stop",
+		Usage:        &pb.Usage{PromptTokens: promptTokens, CompletionTokens: countTokens(answer)},
+	})
+}
+
+// Embed returns a vector of the letter frequencies of each text.
+func (p *echoPlugin) Embed(ctx context.Context, request *pb.EmbedRequest) (*pb.EmbedResponse, error) {
+	if err := checkCredentials(request.Credentials); err != nil {
+		return nil, err
+	}
+
+	response := &pb.EmbedResponse{}
+
+	for _, text := range request.Texts {
+		values := make([]float32, 26)
+
+		for _, r := range strings.ToLower(text) {
+			if r >= 'a' && r <= 'z' {
+				values[r-'a'] += 1
+			}
+		}
+
+		response.Embeddings = append(response.Embeddings, &pb.Embedding{Values: values})
+		response.Tokens += countTokens(text)
+	}
+
+	return response, nil
+}
+
+// Rerank scores the docs by the ratio of the words of the query they contain.
+func (p *echoPlugin) Rerank(ctx context.Context, request *pb.RerankRequest) (*pb.RerankResponse, error) {
+	if err := checkCredentials(request.Credentials); err != nil {
+		return nil, err
+	}
+
+	queryWords := strings.Fields(strings.ToLower(request.Query))
+
+	if len(queryWords) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "query is empty")
+	}
+
+	response := &pb.RerankResponse{}
+
+	for i, doc := range request.Docs {
+		docWords := strings.Fields(strings.ToLower(doc))
+		matched := 0
+
+		for _, word := range queryWords {
+			for _, docWord := range docWords {
+				if word == docWord {
+					matched += 1
+					break
+				}
+			}
+		}
+
+		score := float64(matched) / float64(len(queryWords))
+
+		if score >= request.ScoreThreshold {
+			response.Docs = append(response.Docs, &pb.RerankDocument{Index: int32(i), Score: score})
+		}
+	}
+
+	sort.SliceStable(response.Docs, func(i, j int) bool {
+		return response.Docs[i].Score > response.Docs[j].Score
+	})
+
+	if request.TopN > 0 && int(request.TopN) < len(response.Docs) {
+		response.Docs = response.Docs[:request.TopN]
+	}
+
+	return response, nil
+}
+
+func checkCredentials(credentials *structpb.Struct) error {
+	if credentials.GetFields()["api_key"].GetStringValue() != API_KEY {
+		return status.Errorf(codes.Unauthenticated, "api_key of the echo plugin must be %s", API_KEY)
+	}
+
+	return nil
+}
+
+// echo returns the text of the last user message and the tokens of all the prompt messages.
+func echo(request *pb.InvokeRequest) (string, int64) {
+	var (
+		answer       string
+		promptTokens int64
+	)
+
+	for _, promptMessage := range request.PromptMessages {
+		content := messageText(promptMessage.GetFields()["content"])
+		promptTokens += countTokens(content)
+
+		if promptMessage.GetFields()["role"].GetStringValue() == "user" {
+			answer = content
+		}
+	}
+
+	for _, stop := range request.Stop {
+		if i := strings.Index(answer, stop); stop != "" && i >= 0 {
+			answer = answer[:i]
+		}
+	}
+
+	return answer, promptTokens
+}
+
+// messageText returns the content of the string message, or the text parts of the multimodal message.
+func messageText(content *structpb.Value) string {
+	if list := content.GetListValue(); list != nil {
+		var text string
+
+		for _, part := range list.Values {
+			if part.GetStructValue().GetFields()["type"].GetStringValue() == "text" {
+				text += part.GetStructValue().GetFields()["text"].GetStringValue()
+			}
+		}
+
+		return text
+	}
+
+	return content.GetStringValue()
+}
+
+func countTokens(text string) int64 {
+	return int64(len(strings.FieldsFunc(text, unicode.IsSpace)))
+}
@@ -0,0 +1,212 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_plugin
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/pb"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/options"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const DEFAULT_CONNECT_TIMEOUT = 10 * time.Second
+
+// ModelPlugin proxies the calls of a provider to an out-of-process plugin serving the model plugin protocol.
+type ModelPlugin struct {
+	Provider   string
+	ModelTypes []common.ModelType
+	client     pb.ModelPluginClient
+	conn       *grpc.ClientConn
+}
+
+func NewModelPlugin(provider string, conn grpc.ClientConnInterface) *ModelPlugin {
+	return &ModelPlugin{
+		Provider: provider,
+		client:   pb.NewModelPluginClient(conn),
+	}
+}
+
+// LoadModelPlugins connects to the plugins of the config, registers the model types they serve into the
+// model registries and their schema into the provider factory.
+func LoadModelPlugins(ctx context.Context, opt *options.GRPCOptions) ([]*ModelPlugin, error) {
+	plugins := make([]*ModelPlugin, 0, len(opt.Plugins))
+
+	for _, pluginOpt := range opt.Plugins {
+		plugin, err := dialModelPlugin(ctx, pluginOpt, opt.MaxMsgSize)
+
+		if err != nil {
+			CloseModelPlugins(plugins)
+			return nil, err
+		}
+
+		plugins = append(plugins, plugin)
+		model_providers.Factory.RegisterPluginProvider(pluginOpt.SchemaDir)
+
+		log.Infof("model plugin of provider %s at %s serves %v", plugin.Provider, pluginOpt.Address, plugin.ModelTypes)
+	}
+
+	return plugins, nil
+}
+
+func CloseModelPlugins(plugins []*ModelPlugin) error {
+	var lastErr error
+
+	for _, plugin := range plugins {
+		if err := plugin.Close(); err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func dialModelPlugin(ctx context.Context, opt *options.ModelPluginOptions, maxMsgSize int) (*ModelPlugin, error) {
+	conn, err := grpc.NewClient(opt.Address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMsgSize), grpc.MaxCallSendMsgSize(maxMsgSize)),
+	)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrModelPlugin, "failed to connect to model plugin %s at %s: %s", opt.Provider, opt.Address, err.Error())
+	}
+
+	timeout := opt.Timeout
+
+	if timeout <= 0 {
+		timeout = DEFAULT_CONNECT_TIMEOUT
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	plugin := NewModelPlugin(opt.Provider, conn)
+	plugin.conn = conn
+
+	if err := plugin.Register(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return plugin, nil
+}
+
+// Register asks the plugin for the model types it serves and registers a proxy of each of them.
+func (p *ModelPlugin) Register(ctx context.Context) error {
+	description, err := p.client.Describe(ctx, &pb.DescribeRequest{}, grpc.WaitForReady(true))
+
+	if err != nil {
+		return p.wrapError(err)
+	}
+
+	if description.Provider != p.Provider {
+		return errors.WithCode(code.ErrModelPlugin, "model plugin configured as provider %s serves provider %s", p.Provider, description.Provider)
+	}
+
+	for _, modelType := range description.ModelTypes {
+		switch common.ModelType(modelType) {
+		case common.LLM:
+			model_registry.ModelRuntimeRegistry.RegisterLargeModelInstance(&pluginLargeLanguageModel{ModelPlugin: p})
+		case common.TEXT_EMBEDDING:
+			model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(&pluginTextEmbedding{ModelPlugin: p})
+		case common.RERANK:
+			model_registry.RerankRegistry.RegisterLargeModelInstance(&pluginRerank{ModelPlugin: p})
+		default:
+			return errors.WithCode(code.ErrModelPlugin, "model type %s of model plugin %s is not supported", modelType, p.Provider)
+		}
+
+		p.ModelTypes = append(p.ModelTypes, common.ModelType(modelType))
+	}
+
+	return nil
+}
+
+func (p *ModelPlugin) Close() error {
+	if p.conn == nil {
+		return nil
+	}
+
+	return p.conn.Close()
+}
+
+func (p *ModelPlugin) validateCredentials(ctx context.Context, modelType common.ModelType, model string, credentials map[string]interface{}) error {
+	credentialStruct, err := toStruct(credentials)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.client.ValidateCredentials(ctx, &pb.ValidateCredentialsRequest{
+		Model:       model,
+		ModelType:   string(modelType),
+		Credentials: credentialStruct,
+	}); err != nil {
+		return p.wrapError(err)
+	}
+
+	return nil
+}
+
+// wrapError maps the grpc status of the plugin to the codes of the model services, so that the callers
+// e.g. the fallback chain handle the errors of the plugins as the errors of the built-in providers.
+func (p *ModelPlugin) wrapError(err error) error {
+	st, ok := status.FromError(err)
+
+	if !ok {
+		return errors.WithCode(code.ErrModelPlugin, "model plugin %s: %s", p.Provider, err.Error())
+	}
+
+	var errCode int
+
+	switch st.Code() {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		errCode = code.ErrInvalidCredentials
+	case codes.InvalidArgument:
+		errCode = code.ErrModelParameter
+	case codes.ResourceExhausted:
+		errCode = code.ErrModelRateLimited
+	case codes.Unavailable, codes.DeadlineExceeded:
+		errCode = code.ErrModelServiceUnavailable
+	default:
+		errCode = code.ErrModelPlugin
+	}
+
+	return errors.WithCode(errCode, "model plugin %s: %s", p.Provider, st.Message())
+}
+
+// toStruct converts the value through json, since structpb only accepts the types decoded from json.
+func toStruct(value interface{}) (*structpb.Struct, error) {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	fields := make(map[string]interface{})
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	valueStruct, err := structpb.NewStruct(fields)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	return valueStruct, nil
+}
@@ -0,0 +1,257 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/pb"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+type pluginLargeLanguageModel struct {
+	*ModelPlugin
+}
+
+var _ model_registry.IModelRegistry = (*pluginLargeLanguageModel)(nil)
+var _ model_registry.ICredentialValidator = (*pluginLargeLanguageModel)(nil)
+
+func (m *pluginLargeLanguageModel) RegisterName() string {
+	return fmt.Sprintf("%s/%s", m.Provider, common.LLM)
+}
+
+func (m *pluginLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return m.validateCredentials(ctx, common.LLM, model, credentials)
+}
+
+func (m *pluginLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	request, err := buildInvokeRequest(model, credentials, modelParameters, stop, user, promptMessages, tools)
+
+	if err != nil {
+		queueManager.PushErr(err)
+		return
+	}
+
+	start := time.Now()
+	stream, err := m.client.InvokeStream(ctx, request)
+
+	if err != nil {
+		queueManager.PushErr(m.wrapError(err))
+		return
+	}
+
+	var (
+		fullContent string
+		chunkIndex  int
+		toolCalls   []*biz_entity_openai_standard_response.ToolCall
+		agent       = len(tools) > 0
+	)
+
+	for {
+		chunk, err := stream.Recv()
+
+		if err == io.EOF {
+			queueManager.PushErr(errors.WithCode(code.ErrModelPlugin, "stream of model plugin %s closed before finish", m.Provider))
+			return
+		}
+
+		if err != nil {
+			queueManager.PushErr(m.wrapError(err))
+			return
+		}
+
+		toolCalls = append(toolCalls, convertToolCalls(chunk.ToolCalls)...)
+
+		if chunk.Content != "" {
+			chunkIndex += 1
+			fullContent += chunk.Content
+			pushStreamChunk(queueManager, agent, &biz_entity_base_stream_generator.LLMResultChunk{
+				Model:         model,
+				PromptMessage: promptMessages,
+				Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+					Index:   chunkIndex,
+					Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(chunk.Content),
+				},
+			})
+		}
+
+		if chunk.FinishReason != "" {
+			assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(fullContent)
+			assistantPromptMessage.ToolCalls = toolCalls
+
+			finishReason := chunk.FinishReason
+
+			if agent {
+				finishReason = biz_entity_base_stream_generator.AGENT_END
+			}
+
+			queueManager.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+				AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+				LLMResult: &biz_entity_base_stream_generator.LLMResult{
+					Model:         model,
+					PromptMessage: promptMessages,
+					Reason:        finishReason,
+					Message:       assistantPromptMessage,
+					Usage:         calcLLMUsage(modelRuntime, model, credentials, chunk.Usage, time.Since(start)),
+				},
+			})
+			return
+		}
+	}
+}
+
+func (m *pluginLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	request, err := buildInvokeRequest(model, credentials, modelParameters, stop, user, promptMessages, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	response, err := m.client.Invoke(ctx, request)
+
+	if err != nil {
+		return nil, m.wrapError(err)
+	}
+
+	assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(response.Content)
+	assistantPromptMessage.ToolCalls = convertToolCalls(response.ToolCalls)
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		Model:         model,
+		PromptMessage: promptMessages,
+		Message:       assistantPromptMessage,
+		Usage:         calcLLMUsage(modelRuntime, model, credentials, response.Usage, time.Since(start)),
+		Reason:        response.FinishReason,
+	}, nil
+}
+
+func buildInvokeRequest(model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, tools []*biz_entity_chat_prompt_message.PromptMessageTool) (*pb.InvokeRequest, error) {
+	credentialStruct, err := toStruct(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameterStruct, err := toStruct(modelParameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	request := &pb.InvokeRequest{
+		Model:           model,
+		Credentials:     credentialStruct,
+		ModelParameters: parameterStruct,
+		Stop:            stop,
+		User:            user,
+	}
+
+	for _, promptMessage := range promptMessages {
+		requestData, err := promptMessage.ConvertToRequestData()
+
+		if err != nil {
+			return nil, err
+		}
+
+		messageStruct, err := toStruct(requestData)
+
+		if err != nil {
+			return nil, err
+		}
+
+		request.PromptMessages = append(request.PromptMessages, messageStruct)
+	}
+
+	for _, tool := range tools {
+		toolStruct, err := toStruct(tool)
+
+		if err != nil {
+			return nil, err
+		}
+
+		request.Tools = append(request.Tools, toolStruct)
+	}
+
+	return request, nil
+}
+
+func convertToolCalls(toolCalls []*pb.ToolCall) []*biz_entity_openai_standard_response.ToolCall {
+	converted := make([]*biz_entity_openai_standard_response.ToolCall, 0, len(toolCalls))
+
+	for _, toolCall := range toolCalls {
+		converted = append(converted, &biz_entity_openai_standard_response.ToolCall{
+			ID:   toolCall.Id,
+			Type: "function",
+			Function: &biz_entity_openai_standard_response.ToolCallFunction{
+				Name:      toolCall.Name,
+				Arguments: toolCall.Arguments,
+			},
+		})
+	}
+
+	return converted
+}
+
+func pushStreamChunk(queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, agent bool, streamResultChunk *biz_entity_base_stream_generator.LLMResultChunk) {
+	if agent {
+		queueManager.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage),
+			Chunk:         streamResultChunk,
+		})
+	} else {
+		queueManager.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+			Chunk:         streamResultChunk,
+		})
+	}
+}
+
+// calcLLMUsage prices the tokens reported by the plugin with the pricing of the model schema,
+// models without price definition are free.
+func calcLLMUsage(modelRuntime biz_entity.IAIModelRuntime, model string, credentials map[string]interface{}, usage *pb.Usage, latency time.Duration) *biz_entity_base_stream_generator.LLMUsage {
+	promptTokens, completionTokens := usage.GetPromptTokens(), usage.GetCompletionTokens()
+
+	promptPriceInfo, completePriceInfo := biz_entity.NewFreePriceInfo(), biz_entity.NewFreePriceInfo()
+
+	if modelRuntime != nil {
+		if priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, promptTokens); err == nil {
+			promptPriceInfo = priceInfo
+		}
+
+		if priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.OUTPUT, completionTokens); err == nil {
+			completePriceInfo = priceInfo
+		}
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             latency.Seconds(),
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_plugin
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/pb"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type pluginRerank struct {
+	*ModelPlugin
+}
+
+var _ model_registry.IRerankRegistry = (*pluginRerank)(nil)
+var _ model_registry.ICredentialValidator = (*pluginRerank)(nil)
+
+func (m *pluginRerank) RegisterName() string {
+	return fmt.Sprintf("%s/%s", m.Provider, common.RERANK)
+}
+
+func (m *pluginRerank) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return m.validateCredentials(ctx, common.RERANK, model, credentials)
+}
+
+func (m *pluginRerank) Invoke(ctx context.Context, model string, credentials map[string]interface{}, query string, docs []string, scoreThreshold float64, topN int, user string, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.RerankResult, error) {
+	if len(docs) == 0 {
+		return &biz_entity_openai_standard_response.RerankResult{Model: model}, nil
+	}
+
+	credentialStruct, err := toStruct(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := m.client.Rerank(ctx, &pb.RerankRequest{
+		Model:          model,
+		Credentials:    credentialStruct,
+		Query:          query,
+		Docs:           docs,
+		ScoreThreshold: scoreThreshold,
+		TopN:           int32(topN),
+		User:           user,
+	})
+
+	if err != nil {
+		return nil, m.wrapError(err)
+	}
+
+	rerankDocs := make([]*biz_entity_openai_standard_response.RerankDocument, 0, len(response.Docs))
+
+	for _, doc := range response.Docs {
+		if doc.Index < 0 || int(doc.Index) >= len(docs) {
+			return nil, errors.WithCode(code.ErrModelPlugin, "model plugin %s returned index %d out of %d docs", m.Provider, doc.Index, len(docs))
+		}
+
+		rerankDocs = append(rerankDocs, &biz_entity_openai_standard_response.RerankDocument{
+			Index: int(doc.Index),
+			Text:  docs[doc.Index],
+			Score: doc.Score,
+		})
+	}
+
+	return &biz_entity_openai_standard_response.RerankResult{
+		Model: model,
+		Docs:  rerankDocs,
+	}, nil
+}
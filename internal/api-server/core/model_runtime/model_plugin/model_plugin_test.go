@@ -0,0 +1,168 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_plugin
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/echo_plugin"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+func dialEchoPlugin(t *testing.T) *grpc.ClientConn {
+	t.Helper()
+
+	lis := bufconn.Listen(1024 * 1024)
+	go echo_plugin.Serve(lis)
+
+	conn, err := grpc.NewClient("passthrough:///echo",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatalf("failed to dial echo plugin: %v", err)
+	}
+
+	t.Cleanup(func() {
+		conn.Close()
+		lis.Close()
+	})
+
+	return conn
+}
+
+func TestModelPlugin(t *testing.T) {
+	ctx := context.Background()
+	plugin := NewModelPlugin(echo_plugin.PROVIDER, dialEchoPlugin(t))
+
+	if err := plugin.Register(ctx); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	credentials := map[string]interface{}{"api_key": echo_plugin.API_KEY}
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a parrot."),
+		biz_entity_chat_prompt_message.NewUserMessage("hello plugin"),
+	}
+
+	llm, err := model_registry.ModelRuntimeRegistry.Acquire("echo/llm")
+
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	result, err := llm.InvokeNonStream(ctx, "echo-chat", credentials, map[string]interface{}{"max_tokens": 16}, nil, "", promptMessages, nil)
+
+	if err != nil {
+		t.Fatalf("InvokeNonStream() error = %v", err)
+	}
+
+	if result.Message.GetContent() != "hello plugin" || result.Usage.PromptTokens != 6 || result.Usage.CompletionTokens != 2 {
+		t.Errorf("InvokeNonStream() = %q, usage %+v", result.Message.GetContent(), result.Usage)
+	}
+
+	queue := &fakeQueue{}
+	llm.Invoke(ctx, queue, "echo-chat", credentials, nil, nil, "", promptMessages, nil, nil)
+
+	if queue.err != nil {
+		t.Fatalf("Invoke() error = %v", queue.err)
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil || queue.final.LLMResult.Message.GetContent() != "hello plugin" || queue.final.LLMResult.Reason != "stop" {
+		t.Errorf("Invoke() pushed %d chunks, final %+v", len(queue.chunks), queue.final)
+	}
+
+	embedding, err := model_registry.TextEmbeddingRegistry.Acquire("echo/text-embedding")
+
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	embeddingResult, err := embedding.Embedding(ctx, "echo-embedding", credentials, nil, "", nil, "document", []string{"abc", "b"})
+
+	if err != nil {
+		t.Fatalf("Embedding() error = %v", err)
+	}
+
+	if len(embeddingResult.Embeddings) != 2 || embeddingResult.Embeddings[1][1] != 1 || embeddingResult.Usage.Tokens != 2 {
+		t.Errorf("Embedding() = %+v", embeddingResult)
+	}
+
+	rerank, err := model_registry.RerankRegistry.Acquire("echo/rerank")
+
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	rerankResult, err := rerank.Invoke(ctx, "echo-rerank", credentials, "luna moon", []string{"the sun", "luna", "luna is the moon"}, 0.1, 5, "", nil)
+
+	if err != nil {
+		t.Fatalf("Rerank() error = %v", err)
+	}
+
+	if len(rerankResult.Docs) != 2 || rerankResult.Docs[0].Text != "luna is the moon" || rerankResult.Docs[1].Index != 1 {
+		t.Errorf("Rerank() = %+v", rerankResult.Docs)
+	}
+}
+
+func TestModelPluginErrors(t *testing.T) {
+	ctx := context.Background()
+	conn := dialEchoPlugin(t)
+
+	if err := NewModelPlugin("other", conn).Register(ctx); !errors.IsCode(err, code.ErrModelPlugin) {
+		t.Errorf("Register() error = %v, want ErrModelPlugin", err)
+	}
+
+	plugin := NewModelPlugin(echo_plugin.PROVIDER, conn)
+
+	if err := plugin.Register(ctx); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	if err := model_registry.ValidateModelCredentials(ctx, echo_plugin.PROVIDER, "llm", "echo-chat", map[string]interface{}{"api_key": "wrong"}); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("ValidateModelCredentials() error = %v, want ErrInvalidCredentials", err)
+	}
+
+	if err := model_registry.ValidateModelCredentials(ctx, echo_plugin.PROVIDER, "rerank", "echo-rerank", map[string]interface{}{"api_key": echo_plugin.API_KEY}); err != nil {
+		t.Errorf("ValidateModelCredentials() error = %v", err)
+	}
+
+	rerank, _ := model_registry.RerankRegistry.Acquire("echo/rerank")
+
+	if _, err := rerank.Invoke(ctx, "echo-rerank", map[string]interface{}{"api_key": echo_plugin.API_KEY}, " ", []string{"luna"}, 0, 1, "", nil); !errors.IsCode(err, code.ErrModelParameter) {
+		t.Errorf("Rerank() error = %v, want ErrModelParameter", err)
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin/pb"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type pluginTextEmbedding struct {
+	*ModelPlugin
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*pluginTextEmbedding)(nil)
+var _ model_registry.ICredentialValidator = (*pluginTextEmbedding)(nil)
+
+func (m *pluginTextEmbedding) RegisterName() string {
+	return fmt.Sprintf("%s/%s", m.Provider, common.TEXT_EMBEDDING)
+}
+
+func (m *pluginTextEmbedding) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return m.validateCredentials(ctx, common.TEXT_EMBEDDING, model, credentials)
+}
+
+func (m *pluginTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	credentialStruct, err := toStruct(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	parameterStruct, err := toStruct(modelParameters)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	response, err := m.client.Embed(ctx, &pb.EmbedRequest{
+		Model:           model,
+		Credentials:     credentialStruct,
+		ModelParameters: parameterStruct,
+		User:            user,
+		InputType:       inputType,
+		Texts:           texts,
+	})
+
+	if err != nil {
+		return nil, m.wrapError(err)
+	}
+
+	if len(response.Embeddings) != len(texts) {
+		return nil, errors.WithCode(code.ErrModelPlugin, "model plugin %s returned %d embeddings for %d texts", m.Provider, len(response.Embeddings), len(texts))
+	}
+
+	embeddings := make([][]float32, 0, len(response.Embeddings))
+
+	for _, embedding := range response.Embeddings {
+		embeddings = append(embeddings, embedding.Values)
+	}
+
+	priceInfo := biz_entity.NewFreePriceInfo()
+
+	if modelRuntime != nil {
+		if modelPriceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, response.Tokens); err == nil {
+			priceInfo = modelPriceInfo
+		}
+	}
+
+	return &biz_entity_openai_standard_response.TextEmbeddingResult{
+		Model:      model,
+		Embeddings: embeddings,
+		Usage: &biz_entity_openai_standard_response.EmbeddingUsage{
+			Tokens:      int(response.Tokens),
+			TotalTokens: int(response.Tokens),
+			UnitPrice:   priceInfo.UnitPrice,
+			PriceUnit:   priceInfo.Unit,
+			TotalPrice:  priceInfo.TotalAmount,
+			Currency:    priceInfo.Currency,
+			Latency:     time.Since(start).Seconds(),
+		},
+	}, nil
+}
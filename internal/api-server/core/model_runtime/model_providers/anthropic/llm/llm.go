@@ -0,0 +1,58 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const (
+	DEFAULT_API_URL    = "https://api.anthropic.com"
+	ANTHROPIC_VERSION  = "2023-06-01"
+	DEFAULT_MAX_TOKENS = 4096
+)
+
+type anthropicLargeLanguageModel struct {
+	IAnthropicLargeLanguage
+}
+
+func init() {
+	NewAnthropicLargeLanguageModel().Register()
+}
+
+func NewAnthropicLargeLanguageModel() *anthropicLargeLanguageModel {
+	return &anthropicLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*anthropicLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*anthropicLargeLanguageModel)(nil)
+
+func (m *anthropicLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IAnthropicLargeLanguage = NewAnthropicMessagesLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, tools)
+	m.IAnthropicLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *anthropicLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IAnthropicLargeLanguage = NewAnthropicMessagesLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, nil)
+	return m.IAnthropicLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *anthropicLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *anthropicLargeLanguageModel) RegisterName() string {
+	return "anthropic/llm"
+}
+
+// Tokenizer of claude is not published, the tokens are estimated by the char ratio.
+func (m *anthropicLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
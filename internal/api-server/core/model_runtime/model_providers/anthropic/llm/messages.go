@@ -0,0 +1,638 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+type IAnthropicLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type anthropicMessagesLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	User                 string
+	Stop                 []string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	toolUses             []*toolUseBlock
+	agent                bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+// toolUseBlock accumulates a streamed tool_use content block until its input json is complete.
+type toolUseBlock struct {
+	index int
+	id    string
+	name  string
+	input string
+}
+
+func NewAnthropicMessagesLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, stop []string, user string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *anthropicMessagesLargeLanguageModel {
+	return &anthropicMessagesLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		Stop:            stop,
+		User:            user,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+	}
+}
+
+func (m *anthropicMessagesLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+	m.IStreamGenerateQueue = queue
+	m.generate(ctx)
+}
+
+func (m *anthropicMessagesLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	return m.handleNoStreamResponse(response)
+}
+
+func (m *anthropicMessagesLargeLanguageModel) generate(ctx context.Context) {
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *anthropicMessagesLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	apiKey, ok := m.Credentials["anthropic_api_key"].(string)
+
+	if !ok || apiKey == "" {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "Model %s not have anthropic_api_key", m.Model)
+	}
+
+	endpointUrl := DEFAULT_API_URL
+
+	if apiUrl, ok := m.Credentials["anthropic_api_url"].(string); ok && apiUrl != "" {
+		endpointUrl = strings.TrimSuffix(apiUrl, "/")
+	}
+
+	endpointUrl, err := url.JoinPath(endpointUrl, "v1/messages")
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	requestData, err := m.buildRequestData(stream)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke anthropic llm request body %+v", requestData)
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", apiKey)
+	req.Header.Set("anthropic-version", ANTHROPIC_VERSION)
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+
+		// rate limit and server errors are retryable by the fallback models, rejected keys are cooled down by the load balancer
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			return nil, errors.WithCode(code.ErrModelRateLimited, "anthropic api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "anthropic api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode >= http.StatusInternalServerError:
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "anthropic api returned status %d: %s", response.StatusCode, string(errBody))
+		}
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "anthropic api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return response, nil
+}
+
+func (m *anthropicMessagesLargeLanguageModel) buildRequestData(stream bool) (map[string]interface{}, error) {
+	requestData := map[string]interface{}{
+		"model":      m.Model,
+		"stream":     stream,
+		"max_tokens": DEFAULT_MAX_TOKENS,
+	}
+
+	for k, v := range m.ModelParameters {
+		// response_format is an openai only parameter, the messages api rejects unknown fields
+		if k == "response_format" {
+			continue
+		}
+		requestData[k] = v
+	}
+
+	system, messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if system != "" {
+		requestData["system"] = system
+	}
+
+	requestData["messages"] = messages
+
+	if len(m.tools) > 0 {
+		anthropicTools := make([]map[string]interface{}, 0, len(m.tools))
+		for _, tool := range m.tools {
+			anthropicTools = append(anthropicTools, map[string]interface{}{
+				"name":         tool.Name,
+				"description":  tool.Description,
+				"input_schema": tool.Parameters,
+			})
+		}
+		requestData["tools"] = anthropicTools
+	}
+
+	if len(m.Stop) > 0 {
+		requestData["stop_sequences"] = m.Stop
+	}
+
+	if m.User != "" {
+		requestData["metadata"] = map[string]interface{}{
+			"user_id": m.User,
+		}
+	}
+
+	return requestData, nil
+}
+
+// convertPromptMessages extracts system prompts and converts the rest messages to the messages api format,
+// adjacent messages with the same role are merged because the api requires user and assistant to alternate.
+func (m *anthropicMessagesLargeLanguageModel) convertPromptMessages() (string, []*anthropicMessage, error) {
+	var (
+		systems  []string
+		messages []*anthropicMessage
+	)
+
+	appendMessage := func(role string, blocks ...map[string]interface{}) {
+		if len(messages) > 0 && messages[len(messages)-1].Role == role {
+			messages[len(messages)-1].Content = append(messages[len(messages)-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, &anthropicMessage{Role: role, Content: blocks})
+	}
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			appendMessage("user", map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": message.ToolCallID,
+				"content":     message.GetContent(),
+			})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			var blocks []map[string]interface{}
+
+			if content, ok := message.Content.(string); ok && content != "" {
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": content})
+			}
+
+			for _, toolCall := range message.ToolCalls {
+				input := make(map[string]interface{})
+
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+						return "", nil, errors.WithCode(code.ErrDecodingJSON, "tool call %s arguments %s could not be decoded", toolCall.Function.Name, toolCall.Function.Arguments)
+					}
+				}
+
+				blocks = append(blocks, map[string]interface{}{
+					"type":  "tool_use",
+					"id":    toolCall.ID,
+					"name":  toolCall.Function.Name,
+					"input": input,
+				})
+			}
+
+			if len(blocks) > 0 {
+				appendMessage("assistant", blocks...)
+			}
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				if content, ok := message.Content.(string); ok && content != "" {
+					systems = append(systems, content)
+				}
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				if content, ok := message.Content.(string); ok && content != "" {
+					appendMessage("assistant", map[string]interface{}{"type": "text", "text": content})
+				}
+			case biz_entity_chat_prompt_message.USER:
+				blocks, err := m.convertUserContent(message.Content)
+				if err != nil {
+					return "", nil, err
+				}
+				appendMessage("user", blocks...)
+			}
+		default:
+			return "", nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by anthropic", promptMessage)
+		}
+	}
+
+	return strings.Join(systems, "\n"), messages, nil
+}
+
+func (m *anthropicMessagesLargeLanguageModel) convertUserContent(content any) ([]map[string]interface{}, error) {
+	switch content := content.(type) {
+	case string:
+		return []map[string]interface{}{{"type": "text", "text": content}}, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var blocks []map[string]interface{}
+		for _, messageContent := range content {
+			data, _ := messageContent.Data.(string)
+			switch messageContent.Type {
+			case biz_entity_chat_prompt_message.TEXT:
+				blocks = append(blocks, map[string]interface{}{"type": "text", "text": data})
+			case biz_entity_chat_prompt_message.IMAGE:
+				blocks = append(blocks, map[string]interface{}{"type": "image", "source": convertImageSource(data)})
+			}
+		}
+		return blocks, nil
+	default:
+		return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+// convertImageSource accepts both data url (data:image/png;base64,xxx) and remote url images.
+func convertImageSource(data string) map[string]interface{} {
+	if strings.HasPrefix(data, "data:") {
+		if mediaType, payload, found := strings.Cut(strings.TrimPrefix(data, "data:"), ";base64,"); found {
+			return map[string]interface{}{
+				"type":       "base64",
+				"media_type": mediaType,
+				"data":       payload,
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"type": "url",
+		"url":  data,
+	}
+}
+
+func (m *anthropicMessagesLargeLanguageModel) handleNoStreamResponse(response *http.Response) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var responseJSON anthropicResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&responseJSON); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	if responseJSON.Error != nil {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "%s: %s", responseJSON.Error.Type, responseJSON.Error.Message)
+	}
+
+	var (
+		content   string
+		toolCalls []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	for _, block := range responseJSON.Content {
+		switch block.Type {
+		case "text":
+			content += block.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, &biz_entity_openai_standard_response.ToolCall{
+				ID:   block.ID,
+				Type: "function",
+				Function: &biz_entity_openai_standard_response.ToolCallFunction{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+
+	llmUsage, err := m.calcResponseUsage(responseJSON.Usage.InputTokens, responseJSON.Usage.OutputTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(content)
+	assistantMessage.ToolCalls = toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            responseJSON.ID,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        responseJSON.StopReason,
+	}, nil
+}
+
+func (m *anthropicMessagesLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	var (
+		messageID    string
+		finishReason string
+		usage        anthropicUsage
+	)
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		chunk := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event anthropicStreamEvent
+
+		if err := json.Unmarshal([]byte(chunk), &event); err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", chunk, err.Error()))
+			return
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				messageID = event.Message.ID
+				usage.InputTokens = event.Message.Usage.InputTokens
+				usage.OutputTokens = event.Message.Usage.OutputTokens
+			}
+		case "content_block_start":
+			if event.ContentBlock != nil && event.ContentBlock.Type == "tool_use" {
+				m.toolUses = append(m.toolUses, &toolUseBlock{
+					index: event.Index,
+					id:    event.ContentBlock.ID,
+					name:  event.ContentBlock.Name,
+				})
+			}
+		case "content_block_delta":
+			if event.Delta == nil {
+				continue
+			}
+
+			if event.Delta.Type == "input_json_delta" {
+				for _, toolUse := range m.toolUses {
+					if toolUse.index == event.Index {
+						toolUse.input += event.Delta.PartialJSON
+					}
+				}
+				continue
+			}
+
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				m.ChunkIndex += 1
+				m.FullAssistantContent += event.Delta.Text
+				m.sendStreamChunkToQueue(ctx, messageID, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(event.Delta.Text))
+			}
+		case "message_delta":
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				finishReason = event.Delta.StopReason
+			}
+
+			if event.Usage != nil {
+				usage.OutputTokens = event.Usage.OutputTokens
+				if event.Usage.InputTokens > 0 {
+					usage.InputTokens = event.Usage.InputTokens
+				}
+			}
+		case "error":
+			if event.Error != nil {
+				m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrCallLargeLanguageModel, "%s: %s", event.Error.Type, event.Error.Message))
+				return
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		m.sendErrorChunkToQueue(ctx, errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+		return
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.InputTokens, usage.OutputTokens)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+
+	for _, toolUse := range m.toolUses {
+		arguments := toolUse.input
+
+		if arguments == "" {
+			arguments = "{}"
+		}
+
+		assistantPromptMessage.ToolCalls = append(assistantPromptMessage.ToolCalls, &biz_entity_openai_standard_response.ToolCall{
+			ID:   toolUse.id,
+			Type: "function",
+			Function: &biz_entity_openai_standard_response.ToolCallFunction{
+				Name:      toolUse.name,
+				Arguments: arguments,
+			},
+		})
+	}
+
+	if m.agent {
+		finishReason = biz_entity_base_stream_generator.AGENT_END
+	}
+
+	m.sendStreamFinalChunkToQueue(ctx, messageID, finishReason, assistantPromptMessage, llmUsage)
+}
+
+func (m *anthropicMessagesLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *anthropicMessagesLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *anthropicMessagesLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, messageId string, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	llmResult := &biz_entity_base_stream_generator.LLMResult{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Reason:        finishReason,
+		Message:       assistantPromptMessage,
+		Usage:         llmUsage,
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *anthropicMessagesLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type anthropicMessage struct {
+	Role    string                   `json:"role"`
+	Content []map[string]interface{} `json:"content"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+type anthropicError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type anthropicContentBlock struct {
+	Type  string          `json:"type"`
+	Text  string          `json:"text"`
+	ID    string          `json:"id"`
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input"`
+}
+
+type anthropicResponse struct {
+	ID         string                   `json:"id"`
+	Type       string                   `json:"type"`
+	Role       string                   `json:"role"`
+	Content    []*anthropicContentBlock `json:"content"`
+	StopReason string                   `json:"stop_reason"`
+	Usage      anthropicUsage           `json:"usage"`
+	Error      *anthropicError          `json:"error"`
+}
+
+type anthropicStreamDelta struct {
+	Type        string `json:"type"`
+	Text        string `json:"text"`
+	PartialJSON string `json:"partial_json"`
+	StopReason  string `json:"stop_reason"`
+}
+
+type anthropicStreamEvent struct {
+	Type         string                 `json:"type"`
+	Index        int                    `json:"index"`
+	Message      *anthropicResponse     `json:"message"`
+	ContentBlock *anthropicContentBlock `json:"content_block"`
+	Delta        *anthropicStreamDelta  `json:"delta"`
+	Usage        *anthropicUsage        `json:"usage"`
+	Error        *anthropicError        `json:"error"`
+}
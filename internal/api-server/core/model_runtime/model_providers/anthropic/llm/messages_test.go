@@ -0,0 +1,220 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const recordedStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_01","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","stop_reason":null,"usage":{"input_tokens":25,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"the weather."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_01","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\": "}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":40}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+const recordedResponse = `{"id":"msg_02","type":"message","role":"assistant","content":[{"type":"text","text":"Hello!"}],"model":"claude-3-5-sonnet-20241022","stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":3}}`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "USD"}, nil
+}
+
+func newRecordedServer(t *testing.T, body string, contentType string, captured *map[string]interface{}) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") != ANTHROPIC_VERSION {
+			t.Errorf("missing anthropic headers %+v", r.Header)
+		}
+
+		requestBody, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(requestBody, captured); err != nil {
+			t.Errorf("request body is not json: %s", err.Error())
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+}
+
+func TestAnthropicMessagesStream(t *testing.T) {
+	var captured map[string]interface{}
+	server := newRecordedServer(t, recordedStream, "text/event-stream", &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a weather bot."),
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+	}
+
+	tools := []*biz_entity_chat_prompt_message.PromptMessageTool{
+		{
+			Name:        "get_weather",
+			Description: "Get the weather of a city",
+			Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{
+				Type:       "object",
+				Properties: biz_entity_chat_prompt_message.PromptMessageToolProperties{"city": {Type: "string"}},
+				Required:   []string{"city"},
+			},
+		},
+	}
+
+	credentials := map[string]interface{}{"anthropic_api_key": "test-key", "anthropic_api_url": server.URL}
+	queue := &fakeQueue{}
+
+	NewAnthropicMessagesLargeLanguageModel(promptMessages, map[string]interface{}{"temperature": 0.5}, credentials, "claude-3-5-sonnet-20241022", nil, "user-1", &fakeModelRuntime{}, tools).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	if captured["system"] != "You are a weather bot." {
+		t.Errorf("system prompt was not extracted, got %v", captured["system"])
+	}
+
+	if messages, _ := captured["messages"].([]interface{}); len(messages) != 1 {
+		t.Errorf("expected a single user message, got %v", captured["messages"])
+	}
+
+	if len(queue.chunks) != 2 {
+		t.Fatalf("expected 2 text chunks, got %d", len(queue.chunks))
+	}
+
+	if queue.final == nil {
+		t.Fatal("message end event was not sent")
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Let me check the weather." {
+		t.Errorf("unexpected content %v", result.Message.Content)
+	}
+
+	if len(result.Message.ToolCalls) != 1 || result.Message.ToolCalls[0].ID != "toolu_01" || result.Message.ToolCalls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("unexpected tool calls %+v", result.Message.ToolCalls)
+	}
+
+	if result.Usage.PromptTokens != 25 || result.Usage.CompletionTokens != 40 || result.Usage.TotalTokens != 65 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
+
+func TestAnthropicMessagesToolResult(t *testing.T) {
+	var captured map[string]interface{}
+	server := newRecordedServer(t, recordedResponse, "application/json", &captured)
+	defer server.Close()
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("")
+	assistantMessage.ToolCalls = []*biz_entity_openai_standard_response.ToolCall{
+		{ID: "toolu_01", Type: "function", Function: &biz_entity_openai_standard_response.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+	}
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+		assistantMessage,
+		&biz_entity_chat_prompt_message.ToolPromptMessage{
+			PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{Role: biz_entity_chat_prompt_message.TOOL, Content: "sunny"},
+			ToolCallID:    "toolu_01",
+		},
+	}
+
+	credentials := map[string]interface{}{"anthropic_api_key": "test-key", "anthropic_api_url": server.URL}
+
+	result, err := NewAnthropicMessagesLargeLanguageModel(promptMessages, nil, credentials, "claude-3-5-sonnet-20241022", []string{"\n\nHuman:"}, "", &fakeModelRuntime{}, nil).InvokeNonStream(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	messages, _ := captured["messages"].([]interface{})
+
+	if len(messages) != 3 {
+		t.Fatalf("expected user, assistant and tool_result messages, got %v", captured["messages"])
+	}
+
+	toolResult := messages[2].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})
+
+	if toolResult["type"] != "tool_result" || toolResult["tool_use_id"] != "toolu_01" {
+		t.Errorf("unexpected tool result block %+v", toolResult)
+	}
+
+	if result.Message.Content != "Hello!" || result.Reason != "end_turn" {
+		t.Errorf("unexpected result %+v", result.Message)
+	}
+
+	if result.Usage.PromptTokens != 10 || result.Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
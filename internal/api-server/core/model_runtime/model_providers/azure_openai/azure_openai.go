@@ -0,0 +1,74 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package azure_openai
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+// ToCompatibleCredentials maps the azure openai credential form to the openai api compatible one, azure
+// addresses a model by its deployment, so the model of the request is the deployment name and the url is
+// `{openai_api_base}/openai/deployments/{deployment}` with the `api-version` query and the `api-key` header.
+func ToCompatibleCredentials(model string, credentials map[string]interface{}) map[string]interface{} {
+	compatibleCredentials := map[string]interface{}{
+		"mode": "chat",
+	}
+
+	if apiBase, ok := credentials["openai_api_base"].(string); ok && apiBase != "" {
+		compatibleCredentials["endpoint_url"] = fmt.Sprintf("%s/openai/deployments/%s", strings.TrimSuffix(apiBase, "/"), url.PathEscape(model))
+	}
+
+	if apiVersion, ok := credentials["openai_api_version"].(string); ok && apiVersion != "" {
+		compatibleCredentials["extra_query"] = map[string]string{
+			"api-version": apiVersion,
+		}
+	}
+
+	if apiKey, ok := credentials["openai_api_key"].(string); ok && apiKey != "" {
+		compatibleCredentials["extra_headers"] = map[string]string{
+			"api-key": apiKey,
+		}
+	}
+
+	return compatibleCredentials
+}
+
+type azureModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+// NewModelRuntime wraps the runtime of the provider, azure deployments are customizable models which don't
+// have price definitions, so the usage falls back to free price instead of failing the invocation.
+func NewModelRuntime(modelRuntime biz_entity.IAIModelRuntime) biz_entity.IAIModelRuntime {
+	return &azureModelRuntime{
+		IAIModelRuntime: modelRuntime,
+	}
+}
+
+func (r *azureModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	if r.IAIModelRuntime == nil {
+		return biz_entity.NewFreePriceInfo(), nil
+	}
+
+	priceInfo, err := r.IAIModelRuntime.GetPrice(model, credentials, priceType, tokens)
+
+	if err != nil {
+		return biz_entity.NewFreePriceInfo(), nil
+	}
+
+	return priceInfo, nil
+}
+
+func (r *azureModelRuntime) GetModelSchema(modelName string, credentials any) (*biz_entity.AIModelStaticConfiguration, error) {
+	if r.IAIModelRuntime == nil {
+		return nil, nil
+	}
+
+	return r.IAIModelRuntime.GetModelSchema(modelName, credentials)
+}
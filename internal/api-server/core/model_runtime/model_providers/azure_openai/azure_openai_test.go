@@ -0,0 +1,53 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package azure_openai
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/text_embedding"
+)
+
+func TestDeploymentRequest(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/openai/deployments/my-embedding/embeddings" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		if r.URL.Query().Get("api-version") != "2024-06-01" {
+			t.Errorf("unexpected api-version %s", r.URL.Query().Get("api-version"))
+		}
+
+		if r.Header.Get("api-key") != "azure-key" || r.Header.Get("Authorization") != "" {
+			t.Errorf("unexpected auth headers %v", r.Header)
+		}
+
+		io.WriteString(w, `{"data":[{"embedding":[0.1,0.2]}],"usage":{"prompt_tokens":3,"total_tokens":3}}`)
+	}))
+	defer server.Close()
+
+	credentials := ToCompatibleCredentials("my-embedding", map[string]interface{}{
+		"openai_api_base":    server.URL + "/",
+		"openai_api_key":     "azure-key",
+		"openai_api_version": "2024-06-01",
+	})
+
+	result, err := text_embedding.NewOpenApiCompactLargeLanguageModel(context.Background(), "my-embedding", credentials, []string{"hello"}, NewModelRuntime(nil)).Invoke(context.Background())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Embeddings) != 1 || result.Usage.TotalTokens != 3 || result.Usage.TotalPrice != 0 {
+		t.Fatalf("unexpected result %+v %+v", result, result.Usage)
+	}
+}
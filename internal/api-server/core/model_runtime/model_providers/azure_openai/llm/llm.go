@@ -0,0 +1,59 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/llm"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type azureOpenaiLargeLanguageModel struct {
+	llm.IOpenApiCompactLargeLanguage
+}
+
+func init() {
+	NewAzureOpenaiLargeLanguageModel().Register()
+}
+
+func NewAzureOpenaiLargeLanguageModel() *azureOpenaiLargeLanguageModel {
+	return &azureOpenaiLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*azureOpenaiLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*azureOpenaiLargeLanguageModel)(nil)
+
+func (m *azureOpenaiLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	credentials = azure_openai.ToCompatibleCredentials(model, credentials)
+	modelRuntime = azure_openai.NewModelRuntime(modelRuntime)
+	m.IOpenApiCompactLargeLanguage = llm.NewOpenApiCompactLargeLanguageModel(promptMessages, modelParameters, credentials, model, modelRuntime, tools)
+	m.IOpenApiCompactLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *azureOpenaiLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	credentials = azure_openai.ToCompatibleCredentials(model, credentials)
+	modelRuntime = azure_openai.NewModelRuntime(modelRuntime)
+	m.IOpenApiCompactLargeLanguage = llm.NewOpenApiCompactLargeLanguageModel(promptMessages, modelParameters, credentials, model, modelRuntime, nil)
+	return m.IOpenApiCompactLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *azureOpenaiLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *azureOpenaiLargeLanguageModel) RegisterName() string {
+	return "azure_openai/llm"
+}
+
+// Tokenizer of the deployment, which is named after the openai model it serves.
+func (m *azureOpenaiLargeLanguageModel) Tokenizer(model string) string {
+	return openai.OpenAITokenizer(model)
+}
@@ -0,0 +1,44 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/text_embedding"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type azureOpenaiTextEmbedding struct {
+	text_embedding.IOpenApiCompactTextEmbeddingModel
+}
+
+func init() {
+	NewAzureOpenaiTextEmbedding().Register()
+}
+
+func NewAzureOpenaiTextEmbedding() *azureOpenaiTextEmbedding {
+	return &azureOpenaiTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*azureOpenaiTextEmbedding)(nil)
+
+func (m *azureOpenaiTextEmbedding) RegisterName() string {
+	return "azure_openai/text-embedding"
+}
+
+func (m *azureOpenaiTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *azureOpenaiTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	credentials = azure_openai.ToCompatibleCredentials(model, credentials)
+	modelRuntime = azure_openai.NewModelRuntime(modelRuntime)
+	m.IOpenApiCompactTextEmbeddingModel = text_embedding.NewOpenApiCompactLargeLanguageModel(ctx, model, credentials, texts, modelRuntime)
+	return m.IOpenApiCompactTextEmbeddingModel.Invoke(ctx)
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	DEFAULT_REGION           = "us-east-1"
+	DEFAULT_VALIDATION_MODEL = "amazon.titan-text-lite-v1"
+	// SIGNING_SERVICE is the service name in the credential scope of the bedrock runtime requests
+	SIGNING_SERVICE = "bedrock"
+)
+
+// Credentials of the aws account, the access keys are taken from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+// AWS_SESSION_TOKEN environment variables when they're not provided.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Region          string
+	// EndpointUrl is the bedrock runtime endpoint of the region unless the aws_endpoint_url credential is set,
+	// e.g. to a vpc endpoint
+	EndpointUrl string
+}
+
+func stringCredential(credentials map[string]interface{}, key string) string {
+	value, _ := credentials[key].(string)
+	return strings.TrimSpace(value)
+}
+
+// CredentialsFromMap resolves the aws credentials of the provider credential schema.
+func CredentialsFromMap(credentials map[string]interface{}) (*Credentials, error) {
+	awsCredentials := &Credentials{
+		AccessKeyID:     stringCredential(credentials, "aws_access_key_id"),
+		SecretAccessKey: stringCredential(credentials, "aws_secret_access_key"),
+		SessionToken:    stringCredential(credentials, "aws_session_token"),
+		Region:          stringCredential(credentials, "aws_region"),
+		EndpointUrl:     stringCredential(credentials, "aws_endpoint_url"),
+	}
+
+	if awsCredentials.AccessKeyID == "" && awsCredentials.SecretAccessKey == "" {
+		awsCredentials.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		awsCredentials.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		awsCredentials.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+
+	if awsCredentials.AccessKeyID == "" || awsCredentials.SecretAccessKey == "" {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "aws_access_key_id and aws_secret_access_key are required unless they are provided by the environment")
+	}
+
+	if awsCredentials.Region == "" {
+		awsCredentials.Region = DEFAULT_REGION
+	}
+
+	if awsCredentials.EndpointUrl == "" {
+		awsCredentials.EndpointUrl = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", awsCredentials.Region)
+	} else if _, err := url.ParseRequestURI(awsCredentials.EndpointUrl); err != nil {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "aws_endpoint_url %s is not a valid url", awsCredentials.EndpointUrl)
+	}
+
+	awsCredentials.EndpointUrl = strings.TrimSuffix(awsCredentials.EndpointUrl, "/")
+
+	return awsCredentials, nil
+}
+
+// InvokeModel posts the request data to the action of the model, e.g. converse, converse-stream and invoke. The
+// request is signed with signature version 4, the errors of the bedrock runtime are mapped to the error codes so
+// that the rate limited and unavailable models go down the fallback chain.
+func InvokeModel(ctx context.Context, credentials *Credentials, model, action string, requestData interface{}, accept string) (*http.Response, error) {
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	// the model ids contain colons, e.g. anthropic.claude-3-haiku-20240307-v1:0, which are escaped as the aws sdks do
+	rawPath := fmt.Sprintf("/model/%s/%s", uriEncode(model, true), action)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", credentials.EndpointUrl+rawPath, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", accept)
+
+	SignRequest(req, requestBodyData, credentials, SIGNING_SERVICE, time.Now())
+
+	log.Infof("Invoke bedrock %s of %s", action, model)
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+		return nil, responseError(response.StatusCode, response.Header.Get("X-Amzn-ErrorType"), errBody)
+	}
+
+	return response, nil
+}
+
+type bedrockError struct {
+	Message      string `json:"message"`
+	UpperMessage string `json:"Message"`
+}
+
+// responseError maps the status code and the error type of the bedrock runtime to the error codes, the type is
+// like ThrottlingException:http://internal.amazon.com/coral/com.amazon.bedrock/.
+func responseError(statusCode int, errorType string, errBody []byte) error {
+	errorType, _, _ = strings.Cut(errorType, ":")
+
+	var bedrockErr bedrockError
+	message := string(errBody)
+
+	if err := json.Unmarshal(errBody, &bedrockErr); err == nil {
+		if bedrockErr.Message != "" {
+			message = bedrockErr.Message
+		} else if bedrockErr.UpperMessage != "" {
+			message = bedrockErr.UpperMessage
+		}
+	}
+
+	return ExceptionError(statusCode, errorType, message)
+}
+
+// ExceptionError maps the exceptions of the bedrock runtime, which are returned as the status code of the response
+// or as the exception messages of the event stream (statusCode is 0).
+func ExceptionError(statusCode int, exceptionType, message string) error {
+	switch {
+	case statusCode == http.StatusTooManyRequests || exceptionType == "ThrottlingException" || exceptionType == "throttlingException":
+		return errors.WithCode(code.ErrModelRateLimited, "bedrock %s %d: %s", exceptionType, statusCode, message)
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return errors.WithCode(code.ErrInvalidCredentials, "bedrock %s %d: %s", exceptionType, statusCode, message)
+	case statusCode >= http.StatusInternalServerError || exceptionType == "serviceUnavailableException" || exceptionType == "internalServerException" || exceptionType == "modelTimeoutException":
+		return errors.WithCode(code.ErrModelServiceUnavailable, "bedrock %s %d: %s", exceptionType, statusCode, message)
+	}
+
+	return errors.WithCode(code.ErrCallLargeLanguageModel, "bedrock %s %d: %s", exceptionType, statusCode, message)
+}
+
+// ValidateCredentials sends a minimal converse request to the model, the model_for_validation credential or
+// the titan text lite model is used when the model is empty, e.g. on the provider credentials.
+func ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	awsCredentials, err := CredentialsFromMap(credentials)
+
+	if err != nil {
+		return err
+	}
+
+	if model == "" {
+		model = stringCredential(credentials, "model_for_validation")
+	}
+
+	if model == "" {
+		model = DEFAULT_VALIDATION_MODEL
+	}
+
+	requestData := map[string]interface{}{
+		"messages": []map[string]interface{}{
+			{"role": "user", "content": []map[string]interface{}{{"text": "ping"}}},
+		},
+		"inferenceConfig": map[string]interface{}{"maxTokens": 5},
+	}
+
+	response, err := InvokeModel(ctx, awsCredentials, model, "converse", requestData, "application/json")
+
+	if err != nil {
+		// a model without access is as useless as a rejected key when saving the credentials
+		if errors.IsCode(err, code.ErrCallLargeLanguageModel) {
+			return errors.WithCode(code.ErrInvalidCredentials, "credentials could not invoke model %s: %s", model, err.Error())
+		}
+		return err
+	}
+
+	response.Body.Close()
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package bedrock
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// TestSignRequest signs the get-vanilla request of the aws signature version 4 test suite.
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	credentials := &Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", Region: "us-east-1"}
+
+	SignRequest(req, nil, credentials, "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+
+	if authorization := req.Header.Get("Authorization"); authorization != expected {
+		t.Errorf("Authorization = %s, want %s", authorization, expected)
+	}
+}
+
+func TestInvokeModelSignature(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	const secretAccessKey = "stub-secret"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if err := VerifyRequest(r, body, secretAccessKey); err != nil {
+			w.Header().Set("X-Amzn-ErrorType", "InvalidSignatureException:http://internal.amazon.com/coral/com.amazon.coral.service/")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"message":"`+strings.ReplaceAll(err.Error(), "\n", " ")+`"}`)
+			return
+		}
+
+		if r.URL.EscapedPath() != "/model/anthropic.claude-3-haiku-20240307-v1%3A0/converse" || r.Header.Get("X-Amz-Security-Token") != "stub-token" {
+			t.Errorf("unexpected path %s or session token %s", r.URL.EscapedPath(), r.Header.Get("X-Amz-Security-Token"))
+		}
+
+		io.WriteString(w, `{}`)
+	}))
+	defer server.Close()
+
+	credentials, err := CredentialsFromMap(map[string]interface{}{
+		"aws_access_key_id":     "stub-key",
+		"aws_secret_access_key": secretAccessKey,
+		"aws_session_token":     "stub-token",
+		"aws_region":            "eu-west-1",
+		"aws_endpoint_url":      server.URL + "/",
+	})
+
+	if err != nil {
+		t.Fatalf("CredentialsFromMap() error = %v", err)
+	}
+
+	response, err := InvokeModel(context.Background(), credentials, "anthropic.claude-3-haiku-20240307-v1:0", "converse", map[string]interface{}{"messages": []string{}}, "application/json")
+
+	if err != nil {
+		t.Fatalf("InvokeModel() error = %v", err)
+	}
+
+	response.Body.Close()
+
+	credentials.SecretAccessKey = "wrong-secret"
+
+	if _, err := InvokeModel(context.Background(), credentials, "anthropic.claude-3-haiku-20240307-v1:0", "converse", map[string]interface{}{}, "application/json"); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("InvokeModel() with a wrong secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestCredentialsFromMap(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "env-key")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+
+	credentials, err := CredentialsFromMap(map[string]interface{}{"aws_region": "ap-northeast-1"})
+
+	if err != nil || credentials.AccessKeyID != "env-key" || credentials.EndpointUrl != "https://bedrock-runtime.ap-northeast-1.amazonaws.com" {
+		t.Errorf("CredentialsFromMap() = %+v, %v, want the environment keys", credentials, err)
+	}
+
+	if _, err := CredentialsFromMap(map[string]interface{}{"aws_access_key_id": "key-without-secret"}); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("CredentialsFromMap() without secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	var stream bytes.Buffer
+
+	stream.Write(EncodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "contentBlockDelta"}, []byte(`{"delta":{"text":"Hi"}}`)))
+	stream.Write(EncodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "messageStop"}, nil))
+
+	decoder := NewEventStreamDecoder(&stream)
+
+	message, err := decoder.Decode()
+
+	if err != nil || message.Headers[":event-type"] != "contentBlockDelta" || string(message.Payload) != `{"delta":{"text":"Hi"}}` {
+		t.Fatalf("Decode() = %+v, %v", message, err)
+	}
+
+	message, err = decoder.Decode()
+
+	if err != nil || message.Headers[":event-type"] != "messageStop" || len(message.Payload) != 0 {
+		t.Fatalf("Decode() = %+v, %v", message, err)
+	}
+
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("Decode() at the end error = %v, want io.EOF", err)
+	}
+
+	corrupted := EncodeEventStreamMessage(map[string]string{":event-type": "metadata"}, []byte(`{}`))
+	corrupted[len(corrupted)-6] ^= 0xff
+
+	if _, err := NewEventStreamDecoder(bytes.NewReader(corrupted)).Decode(); err == nil || !strings.Contains(err.Error(), "message crc") {
+		t.Errorf("Decode() of a corrupted payload error = %v, want a crc error", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package bedrock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// the messages of the application/vnd.amazon.eventstream content type are framed as
+//
+//	total length (4) | headers length (4) | prelude crc (4) | headers | payload | message crc (4)
+//
+// all the integers are big endian and the crc is crc32 ieee of all the preceding bytes.
+const (
+	EVENT_STREAM_CONTENT_TYPE = "application/vnd.amazon.eventstream"
+	preludeLength             = 12
+	messageCRCLength          = 4
+	// maxMessageLength guards the allocation of a corrupted prelude, the service limits the messages to 16MB
+	maxMessageLength = 16 * 1024 * 1024
+)
+
+// the types of the header values
+const (
+	headerBoolTrue byte = iota
+	headerBoolFalse
+	headerByte
+	headerShort
+	headerInteger
+	headerLong
+	headerByteArray
+	headerString
+	headerTimestamp
+	headerUUID
+)
+
+// EventStreamMessage is a decoded message of the event stream, only the string header values are kept, which
+// are :message-type, :event-type, :exception-type and :content-type.
+type EventStreamMessage struct {
+	Headers map[string]string
+	Payload []byte
+}
+
+type EventStreamDecoder struct {
+	reader io.Reader
+}
+
+func NewEventStreamDecoder(reader io.Reader) *EventStreamDecoder {
+	return &EventStreamDecoder{reader: reader}
+}
+
+// Decode reads the next message of the stream, it returns io.EOF at the end of the stream.
+func (d *EventStreamDecoder) Decode() (*EventStreamMessage, error) {
+	prelude := make([]byte, preludeLength)
+
+	if _, err := io.ReadFull(d.reader, prelude); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return nil, fmt.Errorf("event stream prelude is truncated")
+		}
+		return nil, err
+	}
+
+	totalLength := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+
+	if crc := crc32.ChecksumIEEE(prelude[0:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, fmt.Errorf("event stream prelude crc %08x doesn't match %08x", binary.BigEndian.Uint32(prelude[8:12]), crc)
+	}
+
+	if totalLength > maxMessageLength || uint64(totalLength) < preludeLength+messageCRCLength+uint64(headersLength) {
+		return nil, fmt.Errorf("event stream message length %d with headers length %d is invalid", totalLength, headersLength)
+	}
+
+	message := make([]byte, totalLength)
+	copy(message, prelude)
+
+	if _, err := io.ReadFull(d.reader, message[preludeLength:]); err != nil {
+		return nil, fmt.Errorf("event stream message is truncated: %w", err)
+	}
+
+	messageCRC := binary.BigEndian.Uint32(message[totalLength-messageCRCLength:])
+
+	if crc := crc32.ChecksumIEEE(message[:totalLength-messageCRCLength]); crc != messageCRC {
+		return nil, fmt.Errorf("event stream message crc %08x doesn't match %08x", messageCRC, crc)
+	}
+
+	headers, err := decodeHeaders(message[preludeLength : preludeLength+headersLength])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &EventStreamMessage{
+		Headers: headers,
+		Payload: message[preludeLength+headersLength : totalLength-messageCRCLength],
+	}, nil
+}
+
+func decodeHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	for len(data) > 0 {
+		nameLength := int(data[0])
+
+		if len(data) < 1+nameLength+1 {
+			return nil, fmt.Errorf("event stream header is truncated")
+		}
+
+		name := string(data[1 : 1+nameLength])
+		valueType := data[1+nameLength]
+		data = data[1+nameLength+1:]
+
+		var valueLength int
+
+		switch valueType {
+		case headerBoolTrue, headerBoolFalse:
+			valueLength = 0
+		case headerByte:
+			valueLength = 1
+		case headerShort:
+			valueLength = 2
+		case headerInteger:
+			valueLength = 4
+		case headerLong, headerTimestamp:
+			valueLength = 8
+		case headerUUID:
+			valueLength = 16
+		case headerByteArray, headerString:
+			if len(data) < 2 {
+				return nil, fmt.Errorf("event stream header %s is truncated", name)
+			}
+			valueLength = int(binary.BigEndian.Uint16(data[0:2]))
+			data = data[2:]
+		default:
+			return nil, fmt.Errorf("event stream header %s has unknown type %d", name, valueType)
+		}
+
+		if len(data) < valueLength {
+			return nil, fmt.Errorf("event stream header %s is truncated", name)
+		}
+
+		if valueType == headerString {
+			headers[name] = string(data[:valueLength])
+		}
+
+		data = data[valueLength:]
+	}
+
+	return headers, nil
+}
+
+// EncodeEventStreamMessage frames the payload with the string headers, it's the inverse of the decoder for the
+// local stubs of the bedrock runtime.
+func EncodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var headerData bytes.Buffer
+
+	for name, value := range headers {
+		headerData.WriteByte(byte(len(name)))
+		headerData.WriteString(name)
+		headerData.WriteByte(headerString)
+		binary.Write(&headerData, binary.BigEndian, uint16(len(value)))
+		headerData.WriteString(value)
+	}
+
+	totalLength := preludeLength + headerData.Len() + len(payload) + messageCRCLength
+	message := make([]byte, 0, totalLength)
+
+	message = binary.BigEndian.AppendUint32(message, uint32(totalLength))
+	message = binary.BigEndian.AppendUint32(message, uint32(headerData.Len()))
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+	message = append(message, headerData.Bytes()...)
+	message = append(message, payload...)
+	message = binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+
+	return message
+}
@@ -0,0 +1,624 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+// inferenceParameters maps the parameter rules of the model yamls, which follow the native request of each model
+// vendor, to the inference config of the converse api, the rest parameters are passed to the model as the
+// additional model request fields.
+var inferenceParameters = map[string]string{
+	"max_tokens":    "maxTokens",
+	"max_gen_len":   "maxTokens",
+	"maxTokenCount": "maxTokens",
+	"maxTokens":     "maxTokens",
+	"temperature":   "temperature",
+	"top_p":         "topP",
+	"topP":          "topP",
+	"p":             "topP",
+}
+
+type IBedrockLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type bedrockConverseLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	User                 string
+	Stop                 []string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	toolUses             []*toolUseBlock
+	agent                bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+// toolUseBlock accumulates a streamed toolUse content block until its input json is complete.
+type toolUseBlock struct {
+	index int
+	id    string
+	name  string
+	input string
+}
+
+func NewBedrockConverseLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, stop []string, user string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *bedrockConverseLargeLanguageModel {
+	return &bedrockConverseLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		Stop:            stop,
+		User:            user,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+	}
+}
+
+func (m *bedrockConverseLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+	m.IStreamGenerateQueue = queue
+	m.generate(ctx)
+}
+
+func (m *bedrockConverseLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	return m.handleNoStreamResponse(response)
+}
+
+func (m *bedrockConverseLargeLanguageModel) generate(ctx context.Context) {
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *bedrockConverseLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	awsCredentials, err := bedrock.CredentialsFromMap(m.Credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestData, err := m.buildRequestData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if stream {
+		return bedrock.InvokeModel(ctx, awsCredentials, m.Model, "converse-stream", requestData, bedrock.EVENT_STREAM_CONTENT_TYPE)
+	}
+
+	return bedrock.InvokeModel(ctx, awsCredentials, m.Model, "converse", requestData, "application/json")
+}
+
+func (m *bedrockConverseLargeLanguageModel) buildRequestData() (map[string]interface{}, error) {
+	inferenceConfig := make(map[string]interface{})
+	additionalFields := make(map[string]interface{})
+
+	for k, v := range m.ModelParameters {
+		// response_format is an openai only parameter, the converse api rejects unknown fields
+		if k == "response_format" {
+			continue
+		}
+
+		if name, ok := inferenceParameters[k]; ok {
+			inferenceConfig[name] = v
+			continue
+		}
+
+		additionalFields[k] = v
+	}
+
+	if len(m.Stop) > 0 {
+		inferenceConfig["stopSequences"] = m.Stop
+	}
+
+	system, messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestData := map[string]interface{}{
+		"messages": messages,
+	}
+
+	if len(system) > 0 {
+		requestData["system"] = system
+	}
+
+	if len(inferenceConfig) > 0 {
+		requestData["inferenceConfig"] = inferenceConfig
+	}
+
+	if len(additionalFields) > 0 {
+		requestData["additionalModelRequestFields"] = additionalFields
+	}
+
+	if len(m.tools) > 0 {
+		bedrockTools := make([]map[string]interface{}, 0, len(m.tools))
+		for _, tool := range m.tools {
+			bedrockTools = append(bedrockTools, map[string]interface{}{
+				"toolSpec": map[string]interface{}{
+					"name":        tool.Name,
+					"description": tool.Description,
+					"inputSchema": map[string]interface{}{"json": tool.Parameters},
+				},
+			})
+		}
+		requestData["toolConfig"] = map[string]interface{}{"tools": bedrockTools}
+	}
+
+	return requestData, nil
+}
+
+// convertPromptMessages extracts system prompts and converts the rest messages to the converse api format,
+// adjacent messages with the same role are merged because the api requires user and assistant to alternate.
+func (m *bedrockConverseLargeLanguageModel) convertPromptMessages() ([]map[string]interface{}, []*converseMessage, error) {
+	var (
+		system   []map[string]interface{}
+		messages []*converseMessage
+	)
+
+	appendMessage := func(role string, blocks ...map[string]interface{}) {
+		if len(messages) > 0 && messages[len(messages)-1].Role == role {
+			messages[len(messages)-1].Content = append(messages[len(messages)-1].Content, blocks...)
+			return
+		}
+		messages = append(messages, &converseMessage{Role: role, Content: blocks})
+	}
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			appendMessage("user", map[string]interface{}{
+				"toolResult": map[string]interface{}{
+					"toolUseId": message.ToolCallID,
+					"content":   []map[string]interface{}{{"text": message.GetContent()}},
+				},
+			})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			var blocks []map[string]interface{}
+
+			if content, ok := message.Content.(string); ok && content != "" {
+				blocks = append(blocks, map[string]interface{}{"text": content})
+			}
+
+			for _, toolCall := range message.ToolCalls {
+				input := make(map[string]interface{})
+
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &input); err != nil {
+						return nil, nil, errors.WithCode(code.ErrDecodingJSON, "tool call %s arguments %s could not be decoded", toolCall.Function.Name, toolCall.Function.Arguments)
+					}
+				}
+
+				blocks = append(blocks, map[string]interface{}{
+					"toolUse": map[string]interface{}{
+						"toolUseId": toolCall.ID,
+						"name":      toolCall.Function.Name,
+						"input":     input,
+					},
+				})
+			}
+
+			if len(blocks) > 0 {
+				appendMessage("assistant", blocks...)
+			}
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				if content, ok := message.Content.(string); ok && content != "" {
+					system = append(system, map[string]interface{}{"text": content})
+				}
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				if content, ok := message.Content.(string); ok && content != "" {
+					appendMessage("assistant", map[string]interface{}{"text": content})
+				}
+			case biz_entity_chat_prompt_message.USER:
+				blocks, err := m.convertUserContent(message.Content)
+				if err != nil {
+					return nil, nil, err
+				}
+				appendMessage("user", blocks...)
+			}
+		default:
+			return nil, nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by bedrock", promptMessage)
+		}
+	}
+
+	return system, messages, nil
+}
+
+func (m *bedrockConverseLargeLanguageModel) convertUserContent(content any) ([]map[string]interface{}, error) {
+	switch content := content.(type) {
+	case string:
+		return []map[string]interface{}{{"text": content}}, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var blocks []map[string]interface{}
+		for _, messageContent := range content {
+			data, _ := messageContent.Data.(string)
+			switch messageContent.Type {
+			case biz_entity_chat_prompt_message.TEXT:
+				blocks = append(blocks, map[string]interface{}{"text": data})
+			case biz_entity_chat_prompt_message.IMAGE:
+				image, err := convertImage(data)
+				if err != nil {
+					return nil, err
+				}
+				blocks = append(blocks, map[string]interface{}{"image": image})
+			}
+		}
+		return blocks, nil
+	default:
+		return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+// convertImage accepts data url (data:image/png;base64,xxx) images only, the converse api takes the image bytes,
+// which are base64 encoded in the json body, and doesn't fetch remote urls.
+func convertImage(data string) (map[string]interface{}, error) {
+	if mediaType, payload, found := strings.Cut(strings.TrimPrefix(data, "data:"), ";base64,"); found && strings.HasPrefix(data, "data:image/") {
+		return map[string]interface{}{
+			"format": strings.TrimPrefix(mediaType, "image/"),
+			"source": map[string]interface{}{"bytes": payload},
+		}, nil
+	}
+
+	return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "bedrock only accepts base64 data url images")
+}
+
+func (m *bedrockConverseLargeLanguageModel) handleNoStreamResponse(response *http.Response) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var responseJSON converseResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&responseJSON); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	var (
+		content   string
+		toolCalls []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	for _, block := range responseJSON.Output.Message.Content {
+		content += block.Text
+
+		if block.ToolUse != nil {
+			arguments := string(block.ToolUse.Input)
+
+			if arguments == "" {
+				arguments = "{}"
+			}
+
+			toolCalls = append(toolCalls, &biz_entity_openai_standard_response.ToolCall{
+				ID:   block.ToolUse.ToolUseID,
+				Type: "function",
+				Function: &biz_entity_openai_standard_response.ToolCallFunction{
+					Name:      block.ToolUse.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+	}
+
+	llmUsage, err := m.calcResponseUsage(responseJSON.Usage.InputTokens, responseJSON.Usage.OutputTokens, responseJSON.Metrics.LatencyMs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(content)
+	assistantMessage.ToolCalls = toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            response.Header.Get("X-Amzn-Requestid"),
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        responseJSON.StopReason,
+	}, nil
+}
+
+func (m *bedrockConverseLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	var (
+		messageID    = response.Header.Get("X-Amzn-Requestid")
+		finishReason string
+		usage        converseUsage
+		metrics      converseMetrics
+	)
+
+	decoder := bedrock.NewEventStreamDecoder(response.Body)
+
+	for {
+		message, err := decoder.Decode()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+			return
+		}
+
+		// exceptions are modeled errors with a json message, errors are carried by the :error-code and
+		// :error-message headers
+		switch message.Headers[":message-type"] {
+		case "exception":
+			var bedrockErr converseStreamError
+			_ = json.Unmarshal(message.Payload, &bedrockErr)
+			m.sendErrorChunkToQueue(ctx, bedrock.ExceptionError(0, message.Headers[":exception-type"], bedrockErr.Message))
+			return
+		case "error":
+			m.sendErrorChunkToQueue(ctx, bedrock.ExceptionError(0, message.Headers[":error-code"], message.Headers[":error-message"]))
+			return
+		}
+
+		var event converseStreamEvent
+
+		if err := json.Unmarshal(message.Payload, &event); err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", string(message.Payload), err.Error()))
+			return
+		}
+
+		switch message.Headers[":event-type"] {
+		case "contentBlockStart":
+			if event.Start != nil && event.Start.ToolUse != nil {
+				m.toolUses = append(m.toolUses, &toolUseBlock{
+					index: event.ContentBlockIndex,
+					id:    event.Start.ToolUse.ToolUseID,
+					name:  event.Start.ToolUse.Name,
+				})
+			}
+		case "contentBlockDelta":
+			if event.Delta == nil {
+				continue
+			}
+
+			if event.Delta.ToolUse != nil {
+				for _, toolUse := range m.toolUses {
+					if toolUse.index == event.ContentBlockIndex {
+						toolUse.input += event.Delta.ToolUse.Input
+					}
+				}
+				continue
+			}
+
+			if event.Delta.Text != "" {
+				m.ChunkIndex += 1
+				m.FullAssistantContent += event.Delta.Text
+				m.sendStreamChunkToQueue(ctx, messageID, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(event.Delta.Text))
+			}
+		case "messageStop":
+			finishReason = event.StopReason
+		case "metadata":
+			if event.Usage != nil {
+				usage = *event.Usage
+			}
+
+			if event.Metrics != nil {
+				metrics = *event.Metrics
+			}
+		default:
+			log.Debugf("bedrock converse stream event %s is skipped", message.Headers[":event-type"])
+		}
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.InputTokens, usage.OutputTokens, metrics.LatencyMs)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+
+	for _, toolUse := range m.toolUses {
+		arguments := toolUse.input
+
+		if arguments == "" {
+			arguments = "{}"
+		}
+
+		assistantPromptMessage.ToolCalls = append(assistantPromptMessage.ToolCalls, &biz_entity_openai_standard_response.ToolCall{
+			ID:   toolUse.id,
+			Type: "function",
+			Function: &biz_entity_openai_standard_response.ToolCallFunction{
+				Name:      toolUse.name,
+				Arguments: arguments,
+			},
+		})
+	}
+
+	if m.agent {
+		finishReason = biz_entity_base_stream_generator.AGENT_END
+	}
+
+	m.sendStreamFinalChunkToQueue(ctx, messageID, finishReason, assistantPromptMessage, llmUsage)
+}
+
+func (m *bedrockConverseLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64, latencyMs int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             float64(latencyMs) / 1000,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *bedrockConverseLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *bedrockConverseLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, messageId string, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	llmResult := &biz_entity_base_stream_generator.LLMResult{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Reason:        finishReason,
+		Message:       assistantPromptMessage,
+		Usage:         llmUsage,
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *bedrockConverseLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type converseMessage struct {
+	Role    string                   `json:"role"`
+	Content []map[string]interface{} `json:"content"`
+}
+
+type converseUsage struct {
+	InputTokens  int64 `json:"inputTokens"`
+	OutputTokens int64 `json:"outputTokens"`
+	TotalTokens  int64 `json:"totalTokens"`
+}
+
+type converseMetrics struct {
+	LatencyMs int64 `json:"latencyMs"`
+}
+
+type converseToolUse struct {
+	ToolUseID string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type converseContentBlock struct {
+	Text    string           `json:"text"`
+	ToolUse *converseToolUse `json:"toolUse"`
+}
+
+type converseResponse struct {
+	Output struct {
+		Message struct {
+			Role    string                  `json:"role"`
+			Content []*converseContentBlock `json:"content"`
+		} `json:"message"`
+	} `json:"output"`
+	StopReason string          `json:"stopReason"`
+	Usage      converseUsage   `json:"usage"`
+	Metrics    converseMetrics `json:"metrics"`
+}
+
+type converseStreamDelta struct {
+	Text    string `json:"text"`
+	ToolUse *struct {
+		Input string `json:"input"`
+	} `json:"toolUse"`
+}
+
+type converseStreamStart struct {
+	ToolUse *converseToolUse `json:"toolUse"`
+}
+
+// converseStreamEvent is the payload of the messageStart, contentBlockStart, contentBlockDelta,
+// contentBlockStop, messageStop and metadata events, the type is the :event-type header of the message.
+type converseStreamEvent struct {
+	Role              string               `json:"role"`
+	ContentBlockIndex int                  `json:"contentBlockIndex"`
+	Start             *converseStreamStart `json:"start"`
+	Delta             *converseStreamDelta `json:"delta"`
+	StopReason        string               `json:"stopReason"`
+	Usage             *converseUsage       `json:"usage"`
+	Metrics           *converseMetrics     `json:"metrics"`
+}
+
+type converseStreamError struct {
+	Message string `json:"message"`
+}
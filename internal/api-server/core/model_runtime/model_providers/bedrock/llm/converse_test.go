@@ -0,0 +1,245 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	stubSecretAccessKey = "stub-secret"
+	stubModel           = "anthropic.claude-3-haiku-20240307-v1:0"
+)
+
+var recordedEvents = []struct {
+	eventType string
+	payload   string
+}{
+	{"messageStart", `{"role":"assistant"}`},
+	{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Let me check "}}`},
+	{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"the weather."}}`},
+	{"contentBlockStop", `{"contentBlockIndex":0}`},
+	{"contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tooluse_01","name":"get_weather"}}}`},
+	{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"city\": "}}}`},
+	{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"\"Paris\"}"}}}`},
+	{"contentBlockStop", `{"contentBlockIndex":1}`},
+	{"messageStop", `{"stopReason":"tool_use"}`},
+	{"metadata", `{"usage":{"inputTokens":25,"outputTokens":40,"totalTokens":65},"metrics":{"latencyMs":420}}`},
+}
+
+const recordedResponse = `{"output":{"message":{"role":"assistant","content":[{"text":"Hello!"}]}},"stopReason":"end_turn","usage":{"inputTokens":10,"outputTokens":3,"totalTokens":13},"metrics":{"latencyMs":150}}`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "USD"}, nil
+}
+
+// newStubServer serves the recorded response of the action to the requests signed with the stub secret.
+func newStubServer(t *testing.T, action string, response []byte, captured *map[string]interface{}) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestBody, _ := io.ReadAll(r.Body)
+
+		if err := bedrock.VerifyRequest(r, requestBody, stubSecretAccessKey); err != nil {
+			t.Errorf("VerifyRequest() error = %v", err)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if r.URL.Path != "/model/"+stubModel+"/"+action {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		if err := json.Unmarshal(requestBody, captured); err != nil {
+			t.Errorf("request body is not json: %s", err.Error())
+		}
+
+		w.Header().Set("X-Amzn-Requestid", "request-1")
+		w.Write(response)
+	}))
+}
+
+func stubCredentials(server *httptest.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"aws_access_key_id":     "stub-key",
+		"aws_secret_access_key": stubSecretAccessKey,
+		"aws_region":            "us-west-2",
+		"aws_endpoint_url":      server.URL,
+	}
+}
+
+func TestBedrockConverseStream(t *testing.T) {
+	var (
+		captured map[string]interface{}
+		stream   bytes.Buffer
+	)
+
+	for _, event := range recordedEvents {
+		stream.Write(bedrock.EncodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": event.eventType, ":content-type": "application/json"}, []byte(event.payload)))
+	}
+
+	server := newStubServer(t, "converse-stream", stream.Bytes(), &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a weather bot."),
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+	}
+
+	tools := []*biz_entity_chat_prompt_message.PromptMessageTool{
+		{
+			Name:        "get_weather",
+			Description: "Get the weather of a city",
+			Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{
+				Type:       "object",
+				Properties: biz_entity_chat_prompt_message.PromptMessageToolProperties{"city": {Type: "string"}},
+				Required:   []string{"city"},
+			},
+		},
+	}
+
+	queue := &fakeQueue{}
+	modelParameters := map[string]interface{}{"max_tokens": 512, "temperature": 0.5, "top_k": 10, "response_format": "json_object"}
+
+	NewBedrockConverseLargeLanguageModel(promptMessages, modelParameters, stubCredentials(server), stubModel, nil, "user-1", &fakeModelRuntime{}, tools).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	inferenceConfig, _ := captured["inferenceConfig"].(map[string]interface{})
+	additionalFields, _ := captured["additionalModelRequestFields"].(map[string]interface{})
+
+	if inferenceConfig["maxTokens"] != float64(512) || inferenceConfig["temperature"] != 0.5 || len(additionalFields) != 1 || additionalFields["top_k"] != float64(10) {
+		t.Errorf("unexpected inference config %v and additional fields %v", inferenceConfig, additionalFields)
+	}
+
+	if system, _ := captured["system"].([]interface{}); len(system) != 1 {
+		t.Errorf("system prompt was not extracted, got %v", captured["system"])
+	}
+
+	if captured["toolConfig"] == nil {
+		t.Errorf("tool config was not sent")
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil {
+		t.Fatalf("expected 2 text chunks and a message end event, got %d chunks", len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Let me check the weather." || result.ID != "request-1" || result.Reason != biz_entity_base_stream_generator.AGENT_END {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(result.Message.ToolCalls) != 1 || result.Message.ToolCalls[0].ID != "tooluse_01" || result.Message.ToolCalls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("unexpected tool calls %+v", result.Message.ToolCalls)
+	}
+
+	if result.Usage.PromptTokens != 25 || result.Usage.CompletionTokens != 40 || result.Usage.Latency != 0.42 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
+
+func TestBedrockConverseStreamException(t *testing.T) {
+	var captured map[string]interface{}
+
+	stream := bedrock.EncodeEventStreamMessage(map[string]string{":message-type": "exception", ":exception-type": "throttlingException"}, []byte(`{"message":"Too many requests"}`))
+	server := newStubServer(t, "converse-stream", stream, &captured)
+	defer server.Close()
+
+	queue := &fakeQueue{}
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("Hi")}
+
+	NewBedrockConverseLargeLanguageModel(promptMessages, nil, stubCredentials(server), stubModel, nil, "", &fakeModelRuntime{}, nil).Invoke(context.Background(), queue)
+
+	if !errors.IsCode(queue.err, code.ErrModelRateLimited) {
+		t.Errorf("error = %v, want ErrModelRateLimited", queue.err)
+	}
+}
+
+func TestBedrockConverseToolResult(t *testing.T) {
+	var captured map[string]interface{}
+	server := newStubServer(t, "converse", []byte(recordedResponse), &captured)
+	defer server.Close()
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("")
+	assistantMessage.ToolCalls = []*biz_entity_openai_standard_response.ToolCall{
+		{ID: "tooluse_01", Type: "function", Function: &biz_entity_openai_standard_response.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+	}
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+		assistantMessage,
+		&biz_entity_chat_prompt_message.ToolPromptMessage{
+			PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{Role: biz_entity_chat_prompt_message.TOOL, Content: "sunny"},
+			ToolCallID:    "tooluse_01",
+		},
+	}
+
+	result, err := NewBedrockConverseLargeLanguageModel(promptMessages, nil, stubCredentials(server), stubModel, []string{"\n\nHuman:"}, "", &fakeModelRuntime{}, nil).InvokeNonStream(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	messages, _ := captured["messages"].([]interface{})
+
+	if len(messages) != 3 {
+		t.Fatalf("expected user, assistant and toolResult messages, got %v", captured["messages"])
+	}
+
+	toolResult := messages[2].(map[string]interface{})["content"].([]interface{})[0].(map[string]interface{})["toolResult"].(map[string]interface{})
+
+	if toolResult["toolUseId"] != "tooluse_01" {
+		t.Errorf("unexpected tool result block %+v", toolResult)
+	}
+
+	if inferenceConfig, _ := captured["inferenceConfig"].(map[string]interface{}); inferenceConfig["stopSequences"] == nil {
+		t.Errorf("stop sequences were not sent, got %v", captured["inferenceConfig"])
+	}
+
+	if result.Message.Content != "Hello!" || result.Reason != "end_turn" || result.Usage.PromptTokens != 10 || result.Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected result %+v %+v", result.Message, result.Usage)
+	}
+}
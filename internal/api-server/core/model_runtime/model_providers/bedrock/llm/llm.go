@@ -0,0 +1,63 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type bedrockLargeLanguageModel struct {
+	IBedrockLargeLanguage
+}
+
+func init() {
+	NewBedrockLargeLanguageModel().Register()
+}
+
+func NewBedrockLargeLanguageModel() *bedrockLargeLanguageModel {
+	return &bedrockLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*bedrockLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*bedrockLargeLanguageModel)(nil)
+var _ provider_register.ICredentialValidator = (*bedrockLargeLanguageModel)(nil)
+var _ provider_register.IProviderCredentialValidator = (*bedrockLargeLanguageModel)(nil)
+
+func (m *bedrockLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IBedrockLargeLanguage = NewBedrockConverseLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, tools)
+	m.IBedrockLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *bedrockLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IBedrockLargeLanguage = NewBedrockConverseLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, nil)
+	return m.IBedrockLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *bedrockLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return bedrock.ValidateCredentials(ctx, model, credentials)
+}
+
+func (m *bedrockLargeLanguageModel) ValidateProviderCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	return bedrock.ValidateCredentials(ctx, "", credentials)
+}
+
+func (m *bedrockLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *bedrockLargeLanguageModel) RegisterName() string {
+	return "bedrock/llm"
+}
+
+// Tokenizer of the bedrock models differs by the model vendor, the tokens are estimated by the char ratio.
+func (m *bedrockLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
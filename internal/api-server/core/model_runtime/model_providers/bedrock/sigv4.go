@@ -0,0 +1,225 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package bedrock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	SIGNING_ALGORITHM = "AWS4-HMAC-SHA256"
+	AMZ_DATE_FORMAT   = "20060102T150405Z"
+	SHORT_DATE_FORMAT = "20060102"
+)
+
+// SignRequest signs the request with aws signature version 4, the X-Amz-Date, X-Amz-Security-Token and
+// Authorization headers are set on the request.
+func SignRequest(req *http.Request, body []byte, credentials *Credentials, service string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(AMZ_DATE_FORMAT)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	canonicalRequest, signedHeaders := CanonicalRequest(req, body, nil)
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", now.Format(SHORT_DATE_FORMAT), credentials.Region, service)
+	signature := Signature(credentials.SecretAccessKey, credentials.Region, service, amzDate, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", SIGNING_ALGORITHM, credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// Signature derives the signing key of the date, region and service, then signs the string to sign of the
+// canonical request.
+func Signature(secretAccessKey, region, service, amzDate, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{SIGNING_ALGORITHM, amzDate, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), amzDate[:len(SHORT_DATE_FORMAT)])
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// CanonicalRequest builds the canonical request and the signed headers of the request, the host and all the
+// headers except authorization and user-agent are signed unless the signed headers are given.
+func CanonicalRequest(req *http.Request, body []byte, signedHeaders []string) (string, string) {
+	host := req.Host
+
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string][]string{"host": {host}}
+
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+
+		if name == "authorization" || name == "user-agent" || name == "host" {
+			continue
+		}
+
+		if signedHeaders != nil && !slices.Contains(signedHeaders, name) {
+			continue
+		}
+
+		for _, value := range values {
+			headers[name] = append(headers[name], strings.Join(strings.Fields(value), " "))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.Join(headers[name], ",") + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		hashHex(body),
+	}, "\n")
+
+	return canonicalRequest, strings.Join(names, ";")
+}
+
+// VerifyRequest checks the signature of a received request against the secret access key, it's meant for the
+// local stubs of the bedrock runtime, the headers added by the transport after signing are not part of the
+// signed headers and so are ignored.
+func VerifyRequest(req *http.Request, body []byte, secretAccessKey string) error {
+	authorization := req.Header.Get("Authorization")
+
+	algorithm, fields, found := strings.Cut(authorization, " ")
+
+	if !found || algorithm != SIGNING_ALGORITHM {
+		return fmt.Errorf("authorization %q is not signed by %s", authorization, SIGNING_ALGORITHM)
+	}
+
+	values := make(map[string]string)
+
+	for _, field := range strings.Split(fields, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		values[key] = value
+	}
+
+	// credential is <access key>/<date>/<region>/<service>/aws4_request
+	credential := strings.SplitN(values["Credential"], "/", 2)
+
+	if len(credential) != 2 {
+		return fmt.Errorf("credential %q of the authorization is malformed", values["Credential"])
+	}
+
+	scope := credential[1]
+	scopes := strings.Split(scope, "/")
+	amzDate := req.Header.Get("X-Amz-Date")
+
+	if len(scopes) != 4 || len(amzDate) != len(AMZ_DATE_FORMAT) || !strings.HasPrefix(amzDate, scopes[0]) {
+		return fmt.Errorf("scope %q doesn't match the date %q", scope, amzDate)
+	}
+
+	canonicalRequest, _ := CanonicalRequest(req, body, strings.Split(values["SignedHeaders"], ";"))
+
+	if expected := Signature(secretAccessKey, scopes[1], scopes[2], amzDate, scope, canonicalRequest); !hmac.Equal([]byte(expected), []byte(values["Signature"])) {
+		return fmt.Errorf("signature %s doesn't match the canonical request:\n%s", values["Signature"], canonicalRequest)
+	}
+
+	return nil
+}
+
+// canonicalURI encodes each segment of the escaped path again, services other than s3 sign the double encoded path.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment, true)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	keys := make([]string, 0, len(query))
+
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var pairs []string
+
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes every byte except the unreserved characters A-Z, a-z, 0-9, '-', '.', '_' and '~', the slashes
+// are kept when encodeSlash is false.
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+
+	return encoded.String()
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	// COHERE_MAX_BATCH is the max number of texts of a cohere embed request
+	COHERE_MAX_BATCH = 96
+)
+
+type bedrockTextEmbedding struct{}
+
+func init() {
+	NewBedrockTextEmbedding().Register()
+}
+
+func NewBedrockTextEmbedding() *bedrockTextEmbedding {
+	return &bedrockTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*bedrockTextEmbedding)(nil)
+var _ model_registry.ICredentialValidator = (*bedrockTextEmbedding)(nil)
+
+func (m *bedrockTextEmbedding) RegisterName() string {
+	return "bedrock/text-embedding"
+}
+
+func (m *bedrockTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *bedrockTextEmbedding) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	awsCredentials, err := bedrock.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return err
+	}
+
+	if _, _, err := m.embed(ctx, awsCredentials, model, "document", []string{"ping"}); err != nil {
+		if errors.IsCode(err, code.ErrCallLargeLanguageModel) {
+			return errors.WithCode(code.ErrInvalidCredentials, "credentials could not invoke model %s: %s", model, err.Error())
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (m *bedrockTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	awsCredentials, err := bedrock.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	embeddings, tokens, err := m.embed(ctx, awsCredentials, model, inputType, texts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &biz_entity_openai_standard_response.TextEmbeddingResult{
+		Model:      model,
+		Embeddings: embeddings,
+		Usage:      m.calcResponseUsage(model, credentials, modelRuntime, tokens, time.Since(start).Seconds()),
+	}, nil
+}
+
+// embed dispatches the texts by the model vendor, the invoke api takes the native request of each vendor.
+func (m *bedrockTextEmbedding) embed(ctx context.Context, awsCredentials *bedrock.Credentials, model, inputType string, texts []string) ([][]float32, int, error) {
+	switch {
+	case strings.HasPrefix(model, "amazon.titan-embed"):
+		return m.embedTitan(ctx, awsCredentials, model, texts)
+	case strings.HasPrefix(model, "cohere.embed"):
+		return m.embedCohere(ctx, awsCredentials, model, inputType, texts)
+	}
+
+	return nil, 0, errors.WithCode(code.ErrCallLargeLanguageModel, "bedrock text embedding model %s is not supported", model)
+}
+
+// embedTitan invokes the model for each text because titan embeds a single input text per request.
+func (m *bedrockTextEmbedding) embedTitan(ctx context.Context, awsCredentials *bedrock.Credentials, model string, texts []string) ([][]float32, int, error) {
+	var (
+		embeddings = make([][]float32, 0, len(texts))
+		tokens     int
+	)
+
+	for _, text := range texts {
+		response, err := bedrock.InvokeModel(ctx, awsCredentials, model, "invoke", map[string]interface{}{"inputText": text}, "application/json")
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var titanResponse titanEmbeddingResponse
+
+		err = json.NewDecoder(response.Body).Decode(&titanResponse)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, 0, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+		}
+
+		embeddings = append(embeddings, titanResponse.Embedding)
+		tokens += titanResponse.InputTextTokenCount
+	}
+
+	return embeddings, tokens, nil
+}
+
+// embedCohere invokes the model in batches, cohere doesn't report the token usage on bedrock so that the tokens
+// are estimated by the char ratio.
+func (m *bedrockTextEmbedding) embedCohere(ctx context.Context, awsCredentials *bedrock.Credentials, model, inputType string, texts []string) ([][]float32, int, error) {
+	var (
+		embeddings = make([][]float32, 0, len(texts))
+		tokens     int
+		tokenizer  = model_registry.GetTokenizer(model_registry.CHAR_RATIO)
+	)
+
+	cohereInputType := "search_document"
+
+	if inputType == "query" {
+		cohereInputType = "search_query"
+	}
+
+	for i := 0; i < len(texts); i += COHERE_MAX_BATCH {
+		batch := texts[i:min(i+COHERE_MAX_BATCH, len(texts))]
+
+		requestData := map[string]interface{}{
+			"texts":      batch,
+			"input_type": cohereInputType,
+			"truncate":   "END",
+		}
+
+		response, err := bedrock.InvokeModel(ctx, awsCredentials, model, "invoke", requestData, "application/json")
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var cohereResponse cohereEmbeddingResponse
+
+		err = json.NewDecoder(response.Body).Decode(&cohereResponse)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, 0, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+		}
+
+		embeddings = append(embeddings, cohereResponse.Embeddings...)
+
+		for _, text := range batch {
+			tokens += tokenizer.CountTokens(text)
+		}
+	}
+
+	return embeddings, tokens, nil
+}
+
+func (m *bedrockTextEmbedding) calcResponseUsage(model string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime, tokens int, latency float64) *biz_entity_openai_standard_response.EmbeddingUsage {
+	priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, int64(tokens))
+
+	if err != nil {
+		priceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	return &biz_entity_openai_standard_response.EmbeddingUsage{
+		Tokens:      tokens,
+		TotalTokens: tokens,
+		UnitPrice:   priceInfo.UnitPrice,
+		PriceUnit:   priceInfo.Unit,
+		TotalPrice:  priceInfo.TotalAmount,
+		Currency:    priceInfo.Currency,
+		Latency:     latency,
+	}
+}
+
+type titanEmbeddingResponse struct {
+	Embedding           []float32 `json:"embedding"`
+	InputTextTokenCount int       `json:"inputTextTokenCount"`
+}
+
+type cohereEmbeddingResponse struct {
+	ID         string      `json:"id"`
+	Embeddings [][]float32 `json:"embeddings"`
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/jina/rerank"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const (
+	DEFAULT_BASE_URL = "https://api.cohere.ai/v1"
+)
+
+type cohereRerank struct {
+	rerank.IJinaCompatibleRerankModel
+}
+
+func init() {
+	NewCohereRerank().Register()
+}
+
+func NewCohereRerank() *cohereRerank {
+	return &cohereRerank{}
+}
+
+var _ model_registry.IRerankRegistry = (*cohereRerank)(nil)
+
+func (m *cohereRerank) RegisterName() string {
+	return "cohere/rerank"
+}
+
+func (m *cohereRerank) Register() {
+	model_registry.RerankRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *cohereRerank) Invoke(ctx context.Context, model string, credentials map[string]interface{}, query string, docs []string, scoreThreshold float64, topN int, user string, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.RerankResult, error) {
+	m.IJinaCompatibleRerankModel = rerank.NewJinaCompatibleRerankModel(model, credentials, DEFAULT_BASE_URL, query, docs, scoreThreshold, topN)
+	return m.IJinaCompatibleRerankModel.Invoke(ctx)
+}
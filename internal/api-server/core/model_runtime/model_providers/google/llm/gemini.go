@@ -0,0 +1,716 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+// blockedFinishReasons are the finish reasons which mean the candidate is stopped by the safety settings,
+// the content of such candidate is empty or truncated so they are reported as errors instead of normal ends.
+var blockedFinishReasons = map[string]struct{}{
+	"SAFETY":             {},
+	"RECITATION":         {},
+	"BLOCKLIST":          {},
+	"PROHIBITED_CONTENT": {},
+	"SPII":               {},
+	"IMAGE_SAFETY":       {},
+}
+
+type IGeminiLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type geminiLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	User                 string
+	Stop                 []string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	agent                bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+func NewGeminiLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, stop []string, user string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *geminiLargeLanguageModel {
+	return &geminiLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		Stop:            stop,
+		User:            user,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+	}
+}
+
+func (m *geminiLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+	m.IStreamGenerateQueue = queue
+	m.generate(ctx)
+}
+
+func (m *geminiLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	return m.handleNoStreamResponse(response)
+}
+
+func (m *geminiLargeLanguageModel) generate(ctx context.Context) {
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *geminiLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	apiKey, ok := m.Credentials["google_api_key"].(string)
+
+	if !ok || apiKey == "" {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "Model %s not have google_api_key", m.Model)
+	}
+
+	endpointUrl := DEFAULT_API_URL
+
+	if apiUrl, ok := m.Credentials["google_api_url"].(string); ok && apiUrl != "" {
+		endpointUrl = strings.TrimSuffix(apiUrl, "/")
+	}
+
+	method := "generateContent"
+
+	if stream {
+		method = "streamGenerateContent"
+	}
+
+	endpointUrl, err := url.JoinPath(endpointUrl, API_VERSION, "models", fmt.Sprintf("%s:%s", m.Model, method))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	if stream {
+		endpointUrl += "?alt=sse"
+	}
+
+	requestData, err := m.buildRequestData(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke gemini llm request body %+v", requestData)
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-goog-api-key", apiKey)
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+
+		// rate limit and server errors are retryable by the fallback models, rejected keys are cooled down by the load balancer
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			return nil, errors.WithCode(code.ErrModelRateLimited, "gemini api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "gemini api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode >= http.StatusInternalServerError:
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "gemini api returned status %d: %s", response.StatusCode, string(errBody))
+		}
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "gemini api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return response, nil
+}
+
+func (m *geminiLargeLanguageModel) buildRequestData(ctx context.Context) (map[string]interface{}, error) {
+	generationConfig := make(map[string]interface{})
+
+	for k, v := range m.ModelParameters {
+		switch k {
+		case "temperature":
+			generationConfig["temperature"] = v
+		case "top_p":
+			generationConfig["topP"] = v
+		case "top_k":
+			generationConfig["topK"] = v
+		case "max_tokens_to_sample", "max_tokens", "max_output_tokens":
+			generationConfig["maxOutputTokens"] = v
+		case "response_format":
+			if format, ok := v.(string); ok && strings.EqualFold(format, "JSON") {
+				generationConfig["responseMimeType"] = "application/json"
+			}
+		}
+	}
+
+	if len(m.Stop) > 0 {
+		generationConfig["stopSequences"] = m.Stop
+	}
+
+	system, contents, err := m.convertPromptMessages(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestData := map[string]interface{}{
+		"contents":         contents,
+		"generationConfig": generationConfig,
+	}
+
+	if system != "" {
+		requestData["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]interface{}{{"text": system}},
+		}
+	}
+
+	if len(m.tools) > 0 {
+		functionDeclarations := make([]map[string]interface{}, 0, len(m.tools))
+		for _, tool := range m.tools {
+			functionDeclaration := map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+			}
+			// gemini rejects object schemas without properties, so tools without parameters omit the schema
+			if tool.Parameters != nil && len(tool.Parameters.Properties) > 0 {
+				functionDeclaration["parameters"] = tool.Parameters
+			}
+			functionDeclarations = append(functionDeclarations, functionDeclaration)
+		}
+		requestData["tools"] = []map[string]interface{}{{"functionDeclarations": functionDeclarations}}
+	}
+
+	return requestData, nil
+}
+
+// convertPromptMessages extracts system prompts to the system instruction and converts the rest messages to gemini contents,
+// assistant messages are sent with the `model` role and tool results are sent as functionResponse parts of a user turn.
+// Adjacent contents with the same role are merged because gemini requires user and model turns to alternate.
+func (m *geminiLargeLanguageModel) convertPromptMessages(ctx context.Context) (string, []*geminiContent, error) {
+	var (
+		systems  []string
+		contents []*geminiContent
+	)
+
+	// gemini matches function responses to calls by name instead of id
+	toolCallNames := make(map[string]string)
+
+	appendContent := func(role string, parts ...map[string]interface{}) {
+		if len(contents) > 0 && contents[len(contents)-1].Role == role {
+			contents[len(contents)-1].Parts = append(contents[len(contents)-1].Parts, parts...)
+			return
+		}
+		contents = append(contents, &geminiContent{Role: role, Parts: parts})
+	}
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			name := toolCallNames[message.ToolCallID]
+
+			if name == "" {
+				name = message.Name
+			}
+
+			appendContent("user", map[string]interface{}{
+				"functionResponse": map[string]interface{}{
+					"name": name,
+					"response": map[string]interface{}{
+						"content": message.GetContent(),
+					},
+				},
+			})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			var parts []map[string]interface{}
+
+			if content, ok := message.Content.(string); ok && content != "" {
+				parts = append(parts, map[string]interface{}{"text": content})
+			}
+
+			for _, toolCall := range message.ToolCalls {
+				args := make(map[string]interface{})
+
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err != nil {
+						return "", nil, errors.WithCode(code.ErrDecodingJSON, "tool call %s arguments %s could not be decoded", toolCall.Function.Name, toolCall.Function.Arguments)
+					}
+				}
+
+				toolCallNames[toolCall.ID] = toolCall.Function.Name
+
+				parts = append(parts, map[string]interface{}{
+					"functionCall": map[string]interface{}{
+						"name": toolCall.Function.Name,
+						"args": args,
+					},
+				})
+			}
+
+			if len(parts) > 0 {
+				appendContent("model", parts...)
+			}
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				if content, ok := message.Content.(string); ok && content != "" {
+					systems = append(systems, content)
+				}
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				if content, ok := message.Content.(string); ok && content != "" {
+					appendContent("model", map[string]interface{}{"text": content})
+				}
+			case biz_entity_chat_prompt_message.USER:
+				parts, err := m.convertUserContent(ctx, message.Content)
+				if err != nil {
+					return "", nil, err
+				}
+				appendContent("user", parts...)
+			}
+		default:
+			return "", nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by gemini", promptMessage)
+		}
+	}
+
+	return strings.Join(systems, "\n"), contents, nil
+}
+
+func (m *geminiLargeLanguageModel) convertUserContent(ctx context.Context, content any) ([]map[string]interface{}, error) {
+	switch content := content.(type) {
+	case string:
+		return []map[string]interface{}{{"text": content}}, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var parts []map[string]interface{}
+		for _, messageContent := range content {
+			data, _ := messageContent.Data.(string)
+			switch messageContent.Type {
+			case biz_entity_chat_prompt_message.TEXT:
+				parts = append(parts, map[string]interface{}{"text": data})
+			case biz_entity_chat_prompt_message.IMAGE:
+				inlineData, err := convertInlineData(ctx, data)
+				if err != nil {
+					return nil, err
+				}
+				parts = append(parts, map[string]interface{}{"inlineData": inlineData})
+			}
+		}
+		return parts, nil
+	default:
+		return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+// convertInlineData accepts both data url (data:image/png;base64,xxx) and remote url images, remote images are
+// downloaded and encoded because gemini only reads fileData uris uploaded through its own file api.
+func convertInlineData(ctx context.Context, data string) (map[string]interface{}, error) {
+	if strings.HasPrefix(data, "data:") {
+		if mimeType, payload, found := strings.Cut(strings.TrimPrefix(data, "data:"), ";base64,"); found {
+			return map[string]interface{}{
+				"mimeType": mimeType,
+				"data":     payload,
+			}, nil
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", data, nil)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(60) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.WithCode(code.ErrRunTimeCaller, "download image %s returned status %d", data, response.StatusCode)
+	}
+
+	imageData, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	mimeType := response.Header.Get("Content-Type")
+
+	if mimeType == "" || !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(imageData)
+	}
+
+	return map[string]interface{}{
+		"mimeType": mimeType,
+		"data":     base64.StdEncoding.EncodeToString(imageData),
+	}, nil
+}
+
+// checkBlocked returns an error when the prompt or the first candidate is blocked by the safety settings.
+func checkBlocked(response *geminiResponse) error {
+	if response.PromptFeedback != nil && response.PromptFeedback.BlockReason != "" {
+		return errors.WithCode(code.ErrModelContentBlocked, "gemini blocked the prompt, reason: %s", response.PromptFeedback.BlockReason)
+	}
+
+	if len(response.Candidates) == 0 {
+		return nil
+	}
+
+	if _, ok := blockedFinishReasons[response.Candidates[0].FinishReason]; ok {
+		return errors.WithCode(code.ErrModelContentBlocked, "gemini blocked the response, reason: %s", response.Candidates[0].FinishReason)
+	}
+
+	return nil
+}
+
+// convertParts splits the parts of a candidate to the text content and the function calls,
+// gemini doesn't return ids for function calls so they are numbered by offset.
+func convertParts(parts []*geminiPart, offset int) (string, []*biz_entity_openai_standard_response.ToolCall) {
+	var (
+		content   string
+		toolCalls []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	for _, part := range parts {
+		if part.FunctionCall != nil {
+			arguments := string(part.FunctionCall.Args)
+
+			if arguments == "" || arguments == "null" {
+				arguments = "{}"
+			}
+
+			toolCalls = append(toolCalls, &biz_entity_openai_standard_response.ToolCall{
+				ID:   fmt.Sprintf("call_%d", offset+len(toolCalls)),
+				Type: "function",
+				Function: &biz_entity_openai_standard_response.ToolCallFunction{
+					Name:      part.FunctionCall.Name,
+					Arguments: arguments,
+				},
+			})
+			continue
+		}
+		content += part.Text
+	}
+
+	return content, toolCalls
+}
+
+func (m *geminiLargeLanguageModel) handleNoStreamResponse(response *http.Response) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var responseJSON geminiResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&responseJSON); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	if err := checkBlocked(&responseJSON); err != nil {
+		return nil, err
+	}
+
+	var (
+		content      string
+		finishReason string
+		toolCalls    []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	if len(responseJSON.Candidates) > 0 {
+		candidate := responseJSON.Candidates[0]
+		finishReason = strings.ToLower(candidate.FinishReason)
+		if candidate.Content != nil {
+			content, toolCalls = convertParts(candidate.Content.Parts, 0)
+		}
+	}
+
+	llmUsage, err := m.calcResponseUsage(responseJSON.UsageMetadata.PromptTokenCount, responseJSON.UsageMetadata.CandidatesTokenCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(content)
+	assistantMessage.ToolCalls = toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            responseJSON.ResponseID,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        finishReason,
+	}, nil
+}
+
+func (m *geminiLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	var (
+		messageID    string
+		finishReason string
+		usage        geminiUsageMetadata
+		toolCalls    []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		chunk := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event geminiResponse
+
+		if err := json.Unmarshal([]byte(chunk), &event); err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", chunk, err.Error()))
+			return
+		}
+
+		if err := checkBlocked(&event); err != nil {
+			m.sendErrorChunkToQueue(ctx, err)
+			return
+		}
+
+		if event.ResponseID != "" {
+			messageID = event.ResponseID
+		}
+
+		// every chunk carries the accumulated usage, the last one is the usage of the whole response
+		if event.UsageMetadata.PromptTokenCount > 0 || event.UsageMetadata.CandidatesTokenCount > 0 {
+			usage = event.UsageMetadata
+		}
+
+		if len(event.Candidates) == 0 {
+			continue
+		}
+
+		candidate := event.Candidates[0]
+
+		if candidate.FinishReason != "" {
+			finishReason = strings.ToLower(candidate.FinishReason)
+		}
+
+		if candidate.Content == nil {
+			continue
+		}
+
+		text, chunkToolCalls := convertParts(candidate.Content.Parts, len(toolCalls))
+		toolCalls = append(toolCalls, chunkToolCalls...)
+
+		if text != "" {
+			m.ChunkIndex += 1
+			m.FullAssistantContent += text
+			m.sendStreamChunkToQueue(ctx, messageID, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(text))
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		m.sendErrorChunkToQueue(ctx, errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+		return
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.PromptTokenCount, usage.CandidatesTokenCount)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+	assistantPromptMessage.ToolCalls = toolCalls
+
+	if m.agent {
+		finishReason = biz_entity_base_stream_generator.AGENT_END
+	}
+
+	m.sendStreamFinalChunkToQueue(ctx, messageID, finishReason, assistantPromptMessage, llmUsage)
+}
+
+func (m *geminiLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *geminiLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *geminiLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, messageId string, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	llmResult := &biz_entity_base_stream_generator.LLMResult{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Reason:        finishReason,
+		Message:       assistantPromptMessage,
+		Usage:         llmUsage,
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *geminiLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type geminiContent struct {
+	Role  string                   `json:"role"`
+	Parts []map[string]interface{} `json:"parts"`
+}
+
+type geminiFunctionCall struct {
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args"`
+}
+
+type geminiPart struct {
+	Text         string              `json:"text"`
+	FunctionCall *geminiFunctionCall `json:"functionCall"`
+}
+
+type geminiCandidateContent struct {
+	Role  string        `json:"role"`
+	Parts []*geminiPart `json:"parts"`
+}
+
+type geminiCandidate struct {
+	Content      *geminiCandidateContent `json:"content"`
+	FinishReason string                  `json:"finishReason"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason string `json:"blockReason"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount     int64 `json:"promptTokenCount"`
+	CandidatesTokenCount int64 `json:"candidatesTokenCount"`
+	TotalTokenCount      int64 `json:"totalTokenCount"`
+}
+
+type geminiResponse struct {
+	Candidates     []*geminiCandidate    `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback"`
+	UsageMetadata  geminiUsageMetadata   `json:"usageMetadata"`
+	ResponseID     string                `json:"responseId"`
+}
@@ -0,0 +1,249 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const recordedStream = `data: {"candidates": [{"content": {"parts": [{"text": "Let me check "}],"role": "model"},"index": 0}],"usageMetadata": {"promptTokenCount": 25,"candidatesTokenCount": 3,"totalTokenCount": 28},"responseId": "resp_01"}
+
+data: {"candidates": [{"content": {"parts": [{"text": "the weather."},{"functionCall": {"name": "get_weather","args": {"city": "Paris"}}}],"role": "model"},"finishReason": "STOP","index": 0}],"usageMetadata": {"promptTokenCount": 25,"candidatesTokenCount": 40,"totalTokenCount": 65},"responseId": "resp_01"}
+
+`
+
+const recordedBlockedStream = `data: {"candidates": [{"content": {"parts": [{"text": "Sure, "}],"role": "model"},"index": 0}],"usageMetadata": {"promptTokenCount": 8,"candidatesTokenCount": 2}}
+
+data: {"candidates": [{"finishReason": "SAFETY","index": 0,"safetyRatings": [{"category": "HARM_CATEGORY_DANGEROUS_CONTENT","probability": "HIGH","blocked": true}]}],"usageMetadata": {"promptTokenCount": 8,"candidatesTokenCount": 2}}
+
+`
+
+const recordedResponse = `{"candidates": [{"content": {"parts": [{"text": "Hello!"}],"role": "model"},"finishReason": "STOP","index": 0}],"usageMetadata": {"promptTokenCount": 10,"candidatesTokenCount": 3,"totalTokenCount": 13},"responseId": "resp_02"}`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "USD"}, nil
+}
+
+func newRecordedServer(t *testing.T, path string, body string, contentType string, captured *map[string]interface{}) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			t.Errorf("missing api key header %+v", r.Header)
+		}
+
+		requestBody, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(requestBody, captured); err != nil {
+			t.Errorf("request body is not json: %s", err.Error())
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+}
+
+func TestGeminiStream(t *testing.T) {
+	var captured map[string]interface{}
+	server := newRecordedServer(t, "/v1beta/models/gemini-1.5-pro:streamGenerateContent", recordedStream, "text/event-stream", &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a weather bot."),
+		biz_entity_chat_prompt_message.NewUserMessage([]*biz_entity_chat_prompt_message.PromptMessageContent{
+			{Type: biz_entity_chat_prompt_message.TEXT, Data: "What's the weather in this city?"},
+			{Type: biz_entity_chat_prompt_message.IMAGE, Data: "data:image/png;base64,iVBORw0KGgo="},
+		}),
+	}
+
+	tools := []*biz_entity_chat_prompt_message.PromptMessageTool{
+		{
+			Name:        "get_weather",
+			Description: "Get the weather of a city",
+			Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{
+				Type:       "object",
+				Properties: biz_entity_chat_prompt_message.PromptMessageToolProperties{"city": {Type: "string"}},
+				Required:   []string{"city"},
+			},
+		},
+	}
+
+	credentials := map[string]interface{}{"google_api_key": "test-key", "google_api_url": server.URL}
+	queue := &fakeQueue{}
+
+	NewGeminiLargeLanguageModel(promptMessages, map[string]interface{}{"temperature": 0.5, "max_tokens_to_sample": 1024}, credentials, "gemini-1.5-pro", nil, "user-1", &fakeModelRuntime{}, tools).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	systemInstruction, _ := captured["systemInstruction"].(map[string]interface{})
+
+	if systemInstruction == nil || systemInstruction["parts"].([]interface{})[0].(map[string]interface{})["text"] != "You are a weather bot." {
+		t.Errorf("system prompt was not extracted, got %v", captured["systemInstruction"])
+	}
+
+	contents, _ := captured["contents"].([]interface{})
+
+	if len(contents) != 1 {
+		t.Fatalf("expected a single user content, got %v", captured["contents"])
+	}
+
+	parts := contents[0].(map[string]interface{})["parts"].([]interface{})
+	inlineData, _ := parts[1].(map[string]interface{})["inlineData"].(map[string]interface{})
+
+	if inlineData["mimeType"] != "image/png" || inlineData["data"] != "iVBORw0KGgo=" {
+		t.Errorf("image was not sent as inline data, got %v", parts[1])
+	}
+
+	generationConfig := captured["generationConfig"].(map[string]interface{})
+
+	if generationConfig["maxOutputTokens"] != float64(1024) || generationConfig["temperature"] != 0.5 {
+		t.Errorf("unexpected generation config %v", generationConfig)
+	}
+
+	declarations := captured["tools"].([]interface{})[0].(map[string]interface{})["functionDeclarations"].([]interface{})
+
+	if len(declarations) != 1 || declarations[0].(map[string]interface{})["name"] != "get_weather" {
+		t.Errorf("unexpected function declarations %v", declarations)
+	}
+
+	if len(queue.chunks) != 2 {
+		t.Fatalf("expected 2 text chunks, got %d", len(queue.chunks))
+	}
+
+	if queue.final == nil {
+		t.Fatal("message end event was not sent")
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Let me check the weather." || result.ID != "resp_01" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(result.Message.ToolCalls) != 1 || result.Message.ToolCalls[0].Function.Name != "get_weather" || result.Message.ToolCalls[0].Function.Arguments != `{"city": "Paris"}` {
+		t.Errorf("unexpected tool calls %+v", result.Message.ToolCalls)
+	}
+
+	if result.Usage.PromptTokens != 25 || result.Usage.CompletionTokens != 40 || result.Usage.TotalTokens != 65 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
+
+func TestGeminiStreamSafetyBlocked(t *testing.T) {
+	var captured map[string]interface{}
+	server := newRecordedServer(t, "/v1beta/models/gemini-1.5-flash:streamGenerateContent", recordedBlockedStream, "text/event-stream", &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewUserMessage("How to make something dangerous?"),
+	}
+
+	credentials := map[string]interface{}{"google_api_key": "test-key", "google_api_url": server.URL}
+	queue := &fakeQueue{}
+
+	NewGeminiLargeLanguageModel(promptMessages, nil, credentials, "gemini-1.5-flash", nil, "", &fakeModelRuntime{}, nil).Invoke(context.Background(), queue)
+
+	if queue.err == nil || !errors.IsCode(queue.err, code.ErrModelContentBlocked) {
+		t.Fatalf("expected a content blocked error, got %v", queue.err)
+	}
+
+	if queue.final != nil {
+		t.Error("blocked response should not send message end event")
+	}
+}
+
+func TestGeminiFunctionResponse(t *testing.T) {
+	var captured map[string]interface{}
+	server := newRecordedServer(t, "/v1beta/models/gemini-1.5-pro:generateContent", recordedResponse, "application/json", &captured)
+	defer server.Close()
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("")
+	assistantMessage.ToolCalls = []*biz_entity_openai_standard_response.ToolCall{
+		{ID: "call_0", Type: "function", Function: &biz_entity_openai_standard_response.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+	}
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+		assistantMessage,
+		&biz_entity_chat_prompt_message.ToolPromptMessage{
+			PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{Role: biz_entity_chat_prompt_message.TOOL, Content: "sunny"},
+			ToolCallID:    "call_0",
+		},
+	}
+
+	credentials := map[string]interface{}{"google_api_key": "test-key", "google_api_url": server.URL}
+
+	result, err := NewGeminiLargeLanguageModel(promptMessages, nil, credentials, "gemini-1.5-pro", []string{"\n\nHuman:"}, "", &fakeModelRuntime{}, nil).InvokeNonStream(context.Background())
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	contents, _ := captured["contents"].([]interface{})
+
+	if len(contents) != 3 {
+		t.Fatalf("expected user, model and function response contents, got %v", captured["contents"])
+	}
+
+	if contents[1].(map[string]interface{})["role"] != "model" {
+		t.Errorf("assistant message should use the model role, got %v", contents[1])
+	}
+
+	functionResponse := contents[2].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})["functionResponse"].(map[string]interface{})
+
+	if functionResponse["name"] != "get_weather" {
+		t.Errorf("unexpected function response %+v", functionResponse)
+	}
+
+	if result.Message.Content != "Hello!" || result.Reason != "stop" {
+		t.Errorf("unexpected result %+v", result.Message)
+	}
+
+	if result.Usage.PromptTokens != 10 || result.Usage.CompletionTokens != 3 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const (
+	DEFAULT_API_URL = "https://generativelanguage.googleapis.com"
+	API_VERSION     = "v1beta"
+)
+
+type googleLargeLanguageModel struct {
+	IGeminiLargeLanguage
+}
+
+func init() {
+	NewGoogleLargeLanguageModel().Register()
+}
+
+func NewGoogleLargeLanguageModel() *googleLargeLanguageModel {
+	return &googleLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*googleLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*googleLargeLanguageModel)(nil)
+
+func (m *googleLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IGeminiLargeLanguage = NewGeminiLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, tools)
+	m.IGeminiLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *googleLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IGeminiLargeLanguage = NewGeminiLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime, nil)
+	return m.IGeminiLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *googleLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *googleLargeLanguageModel) RegisterName() string {
+	return "google/llm"
+}
+
+// Tokenizer of gemini is not published, the tokens are estimated by the char ratio.
+func (m *googleLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
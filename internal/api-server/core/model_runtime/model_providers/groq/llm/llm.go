@@ -27,6 +27,7 @@ func NewGroqLargeLanguageModel() *groqLargeLanguageModel {
 }
 
 var _ provider_register.IModelRegistry = (*groqLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*groqLargeLanguageModel)(nil)
 
 func (m *groqLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
 	credentials = m.addCustomParameters(credentials)
@@ -48,6 +49,11 @@ func (m *groqLargeLanguageModel) RegisterName() string {
 	return "groq/llm"
 }
 
+// Tokenizer of the open models served by groq is not bundled, the tokens are estimated by the char ratio.
+func (m *groqLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
+
 func (m *groqLargeLanguageModel) addCustomParameters(credentials map[string]interface{}) map[string]interface{} {
 	credentials["mode"] = "chat"
 	credentials["endpoint_url"] = "https://api.groq.com/openai/v1"
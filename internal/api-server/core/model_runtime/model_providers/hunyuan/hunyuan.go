@@ -0,0 +1,47 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package hunyuan
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tencent"
+)
+
+const (
+	DEFAULT_BASE_URL = "https://hunyuan.tencentcloudapi.com"
+	SERVICE          = "hunyuan"
+	VERSION          = "2023-09-01"
+)
+
+// Invoke posts the action to the hunyuan endpoint with the TC3 signature of the credentials.
+func Invoke(ctx context.Context, credentials map[string]interface{}, action string, requestData interface{}) (*http.Response, error) {
+	tencentCredentials, err := tencent.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	baseUrl, _ := credentials["base_url"].(string)
+
+	if baseUrl == "" {
+		baseUrl = DEFAULT_BASE_URL
+	}
+
+	return tencent.Invoke(ctx, tencentCredentials, baseUrl, SERVICE, VERSION, action, requestData)
+}
+
+// ValidateCredentials counts the tokens of a prompt, the action is free of charge and checks the signature of
+// the credentials.
+func ValidateCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	response, err := Invoke(ctx, credentials, "GetTokenCount", map[string]interface{}{"Prompt": "ping"})
+
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
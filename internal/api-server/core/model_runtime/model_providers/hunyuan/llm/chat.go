@@ -0,0 +1,516 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/hunyuan"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	CHAT_ACTION = "ChatCompletions"
+)
+
+// parameterNames maps the model parameters to the fields of the chat action, the parameters absent here such
+// as max_tokens are not accepted by hunyuan and dropped.
+var parameterNames = map[string]string{
+	"temperature":    "Temperature",
+	"top_p":          "TopP",
+	"enable_enhance": "EnableEnhancement",
+}
+
+type IHunyuanLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type hunyuanChatLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	toolCalls            []*hunyuanToolCall
+	agent                bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+func NewHunyuanChatLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *hunyuanChatLargeLanguageModel {
+	return &hunyuanChatLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+		agent:           len(tools) > 0,
+	}
+}
+
+func (m *hunyuanChatLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	m.IStreamGenerateQueue = queue
+	m.generate(ctx)
+}
+
+func (m *hunyuanChatLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	return m.handleNoStreamResponse(response)
+}
+
+func (m *hunyuanChatLargeLanguageModel) generate(ctx context.Context) {
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *hunyuanChatLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	requestData, err := m.buildRequestData(stream)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke hunyuan llm request body %+v", requestData)
+
+	return hunyuan.Invoke(ctx, m.Credentials, CHAT_ACTION, requestData)
+}
+
+func (m *hunyuanChatLargeLanguageModel) buildRequestData(stream bool) (map[string]interface{}, error) {
+	requestData := map[string]interface{}{
+		"Model":  m.Model,
+		"Stream": stream,
+	}
+
+	for k, v := range m.ModelParameters {
+		if name, ok := parameterNames[k]; ok {
+			requestData[name] = v
+		}
+	}
+
+	messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestData["Messages"] = messages
+
+	if len(m.tools) > 0 {
+		hunyuanTools := make([]map[string]interface{}, 0, len(m.tools))
+
+		for _, tool := range m.tools {
+			// the parameters of a function are a json schema string
+			parameters, err := json.Marshal(tool.Parameters)
+
+			if err != nil {
+				return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+			}
+
+			hunyuanTools = append(hunyuanTools, map[string]interface{}{
+				"Type": "function",
+				"Function": map[string]interface{}{
+					"Name":        tool.Name,
+					"Description": tool.Description,
+					"Parameters":  string(parameters),
+				},
+			})
+		}
+
+		requestData["Tools"] = hunyuanTools
+		requestData["ToolChoice"] = "auto"
+	}
+
+	return requestData, nil
+}
+
+func (m *hunyuanChatLargeLanguageModel) convertPromptMessages() ([]*hunyuanMessage, error) {
+	var messages []*hunyuanMessage
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			messages = append(messages, &hunyuanMessage{Role: "tool", Content: message.GetContent(), ToolCallId: message.ToolCallID})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			content, _ := message.Content.(string)
+			assistantMessage := &hunyuanMessage{Role: "assistant", Content: content}
+
+			for _, toolCall := range message.ToolCalls {
+				assistantMessage.ToolCalls = append(assistantMessage.ToolCalls, &hunyuanToolCall{
+					Id:   toolCall.ID,
+					Type: "function",
+					Function: &hunyuanToolCallFunction{
+						Name:      toolCall.Function.Name,
+						Arguments: toolCall.Function.Arguments,
+					},
+				})
+			}
+
+			messages = append(messages, assistantMessage)
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				content, _ := message.Content.(string)
+				messages = append(messages, &hunyuanMessage{Role: "system", Content: content})
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				content, _ := message.Content.(string)
+				messages = append(messages, &hunyuanMessage{Role: "assistant", Content: content})
+			case biz_entity_chat_prompt_message.USER:
+				userMessage, err := convertUserMessage(message.Content)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, userMessage)
+			}
+		default:
+			return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by hunyuan", promptMessage)
+		}
+	}
+
+	return messages, nil
+}
+
+// convertUserMessage sends the multi modal contents as Contents, which is accepted by the vision models only.
+func convertUserMessage(content any) (*hunyuanMessage, error) {
+	switch content := content.(type) {
+	case string:
+		return &hunyuanMessage{Role: "user", Content: content}, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var contents []map[string]interface{}
+		for _, messageContent := range content {
+			data, _ := messageContent.Data.(string)
+			switch messageContent.Type {
+			case biz_entity_chat_prompt_message.TEXT:
+				contents = append(contents, map[string]interface{}{"Type": "text", "Text": data})
+			case biz_entity_chat_prompt_message.IMAGE:
+				contents = append(contents, map[string]interface{}{"Type": "image_url", "ImageUrl": map[string]interface{}{"Url": data}})
+			}
+		}
+		return &hunyuanMessage{Role: "user", Contents: contents}, nil
+	default:
+		return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+func (m *hunyuanChatLargeLanguageModel) handleNoStreamResponse(response *http.Response) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var responseJSON struct {
+		Response hunyuanResponse `json:"Response"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&responseJSON); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	var (
+		content      string
+		finishReason string
+		toolCalls    []*biz_entity_openai_standard_response.ToolCall
+	)
+
+	for _, choice := range responseJSON.Response.Choices {
+		if choice.Message == nil {
+			continue
+		}
+
+		content += choice.Message.Content
+		finishReason = choice.FinishReason
+
+		for _, toolCall := range choice.Message.ToolCalls {
+			toolCalls = append(toolCalls, toolCall.toToolCall())
+		}
+	}
+
+	llmUsage, err := m.calcResponseUsage(responseJSON.Response.Usage.PromptTokens, responseJSON.Response.Usage.CompletionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(content)
+	assistantMessage.ToolCalls = toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            responseJSON.Response.Id,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        finishReason,
+	}, nil
+}
+
+func (m *hunyuanChatLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	var (
+		messageID    string
+		finishReason string
+		usage        hunyuanUsage
+	)
+
+	start := time.Now()
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		chunk := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event hunyuanResponse
+
+		if err := json.Unmarshal([]byte(chunk), &event); err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", chunk, err.Error()))
+			return
+		}
+
+		// the errors raised after the stream is started, such as a moderation failure, are sent in the chunk
+		if event.ErrorMsg != nil && event.ErrorMsg.Code != 0 {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrCallLargeLanguageModel, "hunyuan stream error %d: %s", event.ErrorMsg.Code, event.ErrorMsg.Msg))
+			return
+		}
+
+		messageID = event.Id
+
+		if event.Usage.TotalTokens > 0 {
+			usage = event.Usage
+		}
+
+		for _, choice := range event.Choices {
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+
+			if choice.Delta == nil {
+				continue
+			}
+
+			for _, toolCall := range choice.Delta.ToolCalls {
+				m.accumulateToolCall(toolCall)
+			}
+
+			if choice.Delta.Content != "" {
+				m.ChunkIndex += 1
+				m.FullAssistantContent += choice.Delta.Content
+				m.sendStreamChunkToQueue(ctx, messageID, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(choice.Delta.Content))
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		m.sendErrorChunkToQueue(ctx, errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+		return
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.PromptTokens, usage.CompletionTokens)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	llmUsage.Latency = time.Since(start).Seconds()
+
+	assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+
+	for _, toolCall := range m.toolCalls {
+		assistantPromptMessage.ToolCalls = append(assistantPromptMessage.ToolCalls, toolCall.toToolCall())
+	}
+
+	if m.agent {
+		finishReason = biz_entity_base_stream_generator.AGENT_END
+	}
+
+	m.sendStreamFinalChunkToQueue(ctx, messageID, finishReason, assistantPromptMessage, llmUsage)
+}
+
+// accumulateToolCall merges the streamed tool call deltas by index, the id and name come with the first delta
+// of a call and the arguments are split across the deltas.
+func (m *hunyuanChatLargeLanguageModel) accumulateToolCall(delta *hunyuanToolCall) {
+	for _, toolCall := range m.toolCalls {
+		if toolCall.Index == delta.Index && (delta.Id == "" || delta.Id == toolCall.Id) {
+			if delta.Function != nil {
+				toolCall.Function.Arguments += delta.Function.Arguments
+			}
+			return
+		}
+	}
+
+	toolCall := &hunyuanToolCall{Id: delta.Id, Type: delta.Type, Index: delta.Index, Function: &hunyuanToolCallFunction{}}
+
+	if delta.Function != nil {
+		toolCall.Function.Name = delta.Function.Name
+		toolCall.Function.Arguments = delta.Function.Arguments
+	}
+
+	m.toolCalls = append(m.toolCalls, toolCall)
+}
+
+func (m *hunyuanChatLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *hunyuanChatLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *hunyuanChatLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, messageId string, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	llmResult := &biz_entity_base_stream_generator.LLMResult{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Reason:        finishReason,
+		Message:       assistantPromptMessage,
+		Usage:         llmUsage,
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *hunyuanChatLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type hunyuanToolCallFunction struct {
+	Name      string `json:"Name"`
+	Arguments string `json:"Arguments"`
+}
+
+type hunyuanToolCall struct {
+	Id       string                   `json:"Id"`
+	Type     string                   `json:"Type"`
+	Index    int                      `json:"Index"`
+	Function *hunyuanToolCallFunction `json:"Function"`
+}
+
+func (c *hunyuanToolCall) toToolCall() *biz_entity_openai_standard_response.ToolCall {
+	arguments := c.Function.Arguments
+
+	if arguments == "" {
+		arguments = "{}"
+	}
+
+	return &biz_entity_openai_standard_response.ToolCall{
+		ID:   c.Id,
+		Type: "function",
+		Function: &biz_entity_openai_standard_response.ToolCallFunction{
+			Name:      c.Function.Name,
+			Arguments: arguments,
+		},
+	}
+}
+
+type hunyuanMessage struct {
+	Role       string                   `json:"Role"`
+	Content    string                   `json:"Content,omitempty"`
+	Contents   []map[string]interface{} `json:"Contents,omitempty"`
+	ToolCallId string                   `json:"ToolCallId,omitempty"`
+	ToolCalls  []*hunyuanToolCall       `json:"ToolCalls,omitempty"`
+}
+
+type hunyuanUsage struct {
+	PromptTokens     int64 `json:"PromptTokens"`
+	CompletionTokens int64 `json:"CompletionTokens"`
+	TotalTokens      int64 `json:"TotalTokens"`
+}
+
+type hunyuanChoice struct {
+	FinishReason string          `json:"FinishReason"`
+	Message      *hunyuanMessage `json:"Message"`
+	Delta        *hunyuanMessage `json:"Delta"`
+}
+
+type hunyuanResponse struct {
+	Id       string           `json:"Id"`
+	Choices  []*hunyuanChoice `json:"Choices"`
+	Usage    hunyuanUsage     `json:"Usage"`
+	ErrorMsg *struct {
+		Code int    `json:"Code"`
+		Msg  string `json:"Msg"`
+	} `json:"ErrorMsg"`
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tencent"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const recordedStream = `data: {"Id":"chat-1","Choices":[{"Delta":{"Role":"assistant","Content":"Let me check "}}],"Usage":{"PromptTokens":25,"CompletionTokens":3,"TotalTokens":28}}
+
+data: {"Id":"chat-1","Choices":[{"Delta":{"Role":"assistant","Content":"the weather.","ToolCalls":[{"Id":"call_01","Type":"function","Index":0,"Function":{"Name":"get_weather","Arguments":"{\"city\":"}}]}}],"Usage":{"PromptTokens":25,"CompletionTokens":20,"TotalTokens":45}}
+
+data: {"Id":"chat-1","Choices":[{"FinishReason":"tool_calls","Delta":{"Role":"assistant","Content":"","ToolCalls":[{"Index":0,"Function":{"Arguments":"\"Paris\"}"}}]}}],"Usage":{"PromptTokens":25,"CompletionTokens":40,"TotalTokens":65}}
+
+`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "RMB"}, nil
+}
+
+func TestHunyuanChatStream(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	var captured map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		if err := tencent.VerifyRequest(r, body, "stub-secret"); err != nil || r.Header.Get("X-TC-Action") != CHAT_ACTION {
+			t.Errorf("unexpected action %s or signature error %v", r.Header.Get("X-TC-Action"), err)
+		}
+
+		json.Unmarshal(body, &captured)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, recordedStream)
+	}))
+	defer server.Close()
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("")
+	assistantMessage.ToolCalls = []*biz_entity_openai_standard_response.ToolCall{
+		{ID: "call_00", Type: "function", Function: &biz_entity_openai_standard_response.ToolCallFunction{Name: "get_time", Arguments: `{}`}},
+	}
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a weather bot."),
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris now?"),
+		assistantMessage,
+		&biz_entity_chat_prompt_message.ToolPromptMessage{
+			PromptMessage: &biz_entity_chat_prompt_message.PromptMessage{Role: biz_entity_chat_prompt_message.TOOL, Content: "10:00"},
+			ToolCallID:    "call_00",
+		},
+	}
+
+	tools := []*biz_entity_chat_prompt_message.PromptMessageTool{
+		{Name: "get_weather", Description: "Get the weather of a city", Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{Type: "object"}},
+	}
+
+	credentials := map[string]interface{}{"secret_id": "stub-id", "secret_key": "stub-secret", "base_url": server.URL}
+	modelParameters := map[string]interface{}{"temperature": 0.5, "max_tokens": 512, "enable_enhance": false}
+	queue := &fakeQueue{}
+
+	NewHunyuanChatLargeLanguageModel(promptMessages, modelParameters, credentials, "hunyuan-pro", &fakeModelRuntime{}, tools).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	if captured["Temperature"] != 0.5 || captured["EnableEnhancement"] != false || captured["max_tokens"] != nil || captured["MaxTokens"] != nil {
+		t.Errorf("unexpected request %v", captured)
+	}
+
+	messages, _ := captured["Messages"].([]interface{})
+
+	if len(messages) != 4 || messages[3].(map[string]interface{})["ToolCallId"] != "call_00" || messages[2].(map[string]interface{})["ToolCalls"] == nil {
+		t.Errorf("unexpected messages %v", captured["Messages"])
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil {
+		t.Fatalf("expected 2 agent chunks and a message end event, got %d chunks", len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Let me check the weather." || result.ID != "chat-1" || result.Reason != biz_entity_base_stream_generator.AGENT_END {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(result.Message.ToolCalls) != 1 || result.Message.ToolCalls[0].ID != "call_01" || result.Message.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls %+v", result.Message.ToolCalls)
+	}
+
+	if result.Usage.PromptTokens != 25 || result.Usage.CompletionTokens != 40 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
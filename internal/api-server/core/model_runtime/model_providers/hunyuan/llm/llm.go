@@ -0,0 +1,63 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/hunyuan"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type hunyuanLargeLanguageModel struct {
+	IHunyuanLargeLanguage
+}
+
+func init() {
+	NewHunyuanLargeLanguageModel().Register()
+}
+
+func NewHunyuanLargeLanguageModel() *hunyuanLargeLanguageModel {
+	return &hunyuanLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*hunyuanLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*hunyuanLargeLanguageModel)(nil)
+var _ provider_register.ICredentialValidator = (*hunyuanLargeLanguageModel)(nil)
+var _ provider_register.IProviderCredentialValidator = (*hunyuanLargeLanguageModel)(nil)
+
+func (m *hunyuanLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IHunyuanLargeLanguage = NewHunyuanChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, modelRuntime, tools)
+	m.IHunyuanLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *hunyuanLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IHunyuanLargeLanguage = NewHunyuanChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, modelRuntime, nil)
+	return m.IHunyuanLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *hunyuanLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return hunyuan.ValidateCredentials(ctx, credentials)
+}
+
+func (m *hunyuanLargeLanguageModel) ValidateProviderCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	return hunyuan.ValidateCredentials(ctx, credentials)
+}
+
+func (m *hunyuanLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *hunyuanLargeLanguageModel) RegisterName() string {
+	return "hunyuan/llm"
+}
+
+// Tokenizer of hunyuan is not published, the tokens are estimated by the char ratio.
+func (m *hunyuanLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/hunyuan"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	EMBEDDING_ACTION = "GetEmbedding"
+	// MAX_BATCH is the max number of texts of the InputList of an embedding request
+	MAX_BATCH = 200
+)
+
+type hunyuanTextEmbedding struct{}
+
+func init() {
+	NewHunyuanTextEmbedding().Register()
+}
+
+func NewHunyuanTextEmbedding() *hunyuanTextEmbedding {
+	return &hunyuanTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*hunyuanTextEmbedding)(nil)
+var _ model_registry.ICredentialValidator = (*hunyuanTextEmbedding)(nil)
+
+func (m *hunyuanTextEmbedding) RegisterName() string {
+	return "hunyuan/text-embedding"
+}
+
+func (m *hunyuanTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *hunyuanTextEmbedding) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return hunyuan.ValidateCredentials(ctx, credentials)
+}
+
+func (m *hunyuanTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	var (
+		embeddings = make([][]float32, 0, len(texts))
+		tokens     int
+		start      = time.Now()
+	)
+
+	for i := 0; i < len(texts); i += MAX_BATCH {
+		batch := texts[i:min(i+MAX_BATCH, len(texts))]
+
+		response, err := hunyuan.Invoke(ctx, credentials, EMBEDDING_ACTION, map[string]interface{}{"InputList": batch})
+
+		if err != nil {
+			return nil, err
+		}
+
+		var embeddingResponse struct {
+			Response hunyuanEmbeddingResponse `json:"Response"`
+		}
+
+		err = json.NewDecoder(response.Body).Decode(&embeddingResponse)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+		}
+
+		data := embeddingResponse.Response.Data
+
+		if len(data) != len(batch) {
+			return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "hunyuan returned %d embeddings for %d texts", len(data), len(batch))
+		}
+
+		sort.Slice(data, func(i, j int) bool {
+			return data[i].Index < data[j].Index
+		})
+
+		for _, embedding := range data {
+			embeddings = append(embeddings, embedding.Embedding)
+		}
+
+		tokens += embeddingResponse.Response.Usage.TotalTokens
+	}
+
+	return &biz_entity_openai_standard_response.TextEmbeddingResult{
+		Model:      model,
+		Embeddings: embeddings,
+		Usage:      m.calcResponseUsage(model, credentials, modelRuntime, tokens, time.Since(start).Seconds()),
+	}, nil
+}
+
+func (m *hunyuanTextEmbedding) calcResponseUsage(model string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime, tokens int, latency float64) *biz_entity_openai_standard_response.EmbeddingUsage {
+	priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, int64(tokens))
+
+	if err != nil {
+		priceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	return &biz_entity_openai_standard_response.EmbeddingUsage{
+		Tokens:      tokens,
+		TotalTokens: tokens,
+		UnitPrice:   priceInfo.UnitPrice,
+		PriceUnit:   priceInfo.Unit,
+		TotalPrice:  priceInfo.TotalAmount,
+		Currency:    priceInfo.Currency,
+		Latency:     latency,
+	}
+}
+
+type hunyuanEmbeddingData struct {
+	Embedding []float32 `json:"Embedding"`
+	Index     int       `json:"Index"`
+	Object    string    `json:"Object"`
+}
+
+type hunyuanEmbeddingResponse struct {
+	Data  []*hunyuanEmbeddingData `json:"Data"`
+	Usage struct {
+		PromptTokens int `json:"PromptTokens"`
+		TotalTokens  int `json:"TotalTokens"`
+	} `json:"Usage"`
+	RequestId string `json:"RequestId"`
+}
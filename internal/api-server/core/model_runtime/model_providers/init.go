@@ -6,24 +6,74 @@ package model_providers
 
 import (
 	// llm
+	// anthropic/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/anthropic/llm"
+	// azure_openai/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai/llm"
+	// bedrock/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock/llm"
+	// google/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/google/llm"
 	// groq/llm
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/groq/llm"
+	// hunyuan/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/hunyuan/llm"
+	// ollama/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/ollama/llm"
+	// openai/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai/llm"
+	// spark/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/spark/llm"
 	// tongyi/llm
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tongyi/llm"
+	// wenxin/llm
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin/llm"
 	// zhipuai/llm
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/zhipuai/llm"
 
 	// speech2text
+	// azure_openai/speech2text
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai/speech2text"
 	// groq/speech2text
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/groq/speech2text"
+	// openai/speech2text
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai/speech2text"
 	// tenant/speech2text
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tencent/speech2text"
 
 	// tts
+	// azure_openai/tts
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai/tts"
+	// openai/tts
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai/tts"
 	// tongyi/tts
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tongyi/tts"
 
 	// embedding
+	// azure_openai/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/azure_openai/text_embedding"
+	// bedrock/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/bedrock/text_embedding"
+	// hunyuan/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/hunyuan/text_embedding"
+	// ollama/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/ollama/text_embedding"
+	// openai/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai/text_embedding"
 	// tongyi/embedding
 	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tongyi/text_embedding"
+	// wenxin/embedding
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin/text_embedding"
+
+	// rerank
+	// cohere/rerank
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/cohere/rerank"
+	// jina/rerank
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/jina/rerank"
+	// wenxin/rerank
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin/rerank"
+
+	// moderation
+	// openai/moderation
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai/moderation"
 )
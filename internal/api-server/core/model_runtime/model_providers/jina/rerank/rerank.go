@@ -0,0 +1,182 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	DEFAULT_BASE_URL = "https://api.jina.ai/v1"
+)
+
+type jinaRerank struct {
+	IJinaCompatibleRerankModel
+}
+
+func init() {
+	NewJinaRerank().Register()
+}
+
+func NewJinaRerank() *jinaRerank {
+	return &jinaRerank{}
+}
+
+var _ model_registry.IRerankRegistry = (*jinaRerank)(nil)
+
+func (m *jinaRerank) RegisterName() string {
+	return "jina/rerank"
+}
+
+func (m *jinaRerank) Register() {
+	model_registry.RerankRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *jinaRerank) Invoke(ctx context.Context, model string, credentials map[string]interface{}, query string, docs []string, scoreThreshold float64, topN int, user string, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.RerankResult, error) {
+	m.IJinaCompatibleRerankModel = NewJinaCompatibleRerankModel(model, credentials, DEFAULT_BASE_URL, query, docs, scoreThreshold, topN)
+	return m.IJinaCompatibleRerankModel.Invoke(ctx)
+}
+
+// IJinaCompatibleRerankModel is the caller of the `POST {base_url}/rerank` api shared by jina and cohere.
+type IJinaCompatibleRerankModel interface {
+	Invoke(ctx context.Context) (*biz_entity_openai_standard_response.RerankResult, error)
+}
+
+type jinaCompatibleRerankModel struct {
+	model          string
+	credentials    map[string]interface{}
+	defaultBaseUrl string
+	query          string
+	docs           []string
+	scoreThreshold float64
+	topN           int
+}
+
+func NewJinaCompatibleRerankModel(model string, credentials map[string]interface{}, defaultBaseUrl string, query string, docs []string, scoreThreshold float64, topN int) *jinaCompatibleRerankModel {
+	return &jinaCompatibleRerankModel{
+		model:          model,
+		credentials:    credentials,
+		defaultBaseUrl: defaultBaseUrl,
+		query:          query,
+		docs:           docs,
+		scoreThreshold: scoreThreshold,
+		topN:           topN,
+	}
+}
+
+func (r *jinaCompatibleRerankModel) Invoke(ctx context.Context) (*biz_entity_openai_standard_response.RerankResult, error) {
+	if len(r.docs) == 0 {
+		return &biz_entity_openai_standard_response.RerankResult{Model: r.model}, nil
+	}
+
+	apiKey, ok := r.credentials["api_key"].(string)
+
+	if !ok || apiKey == "" {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "Model %s not have api_key", r.model)
+	}
+
+	baseUrl, ok := r.credentials["base_url"].(string)
+
+	if !ok || baseUrl == "" {
+		baseUrl = r.defaultBaseUrl
+	}
+
+	endpointUrl, err := url.JoinPath(baseUrl, "rerank")
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	topN := r.topN
+
+	if topN <= 0 || topN > len(r.docs) {
+		topN = len(r.docs)
+	}
+
+	requestData := map[string]interface{}{
+		"model":            r.model,
+		"query":            r.query,
+		"documents":        r.docs,
+		"top_n":            topN,
+		"return_documents": false,
+	}
+
+	log.Infof("Invoke rerank request model %s, query %s, %d documents", r.model, r.query, len(r.docs))
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+
+	client := http.Client{
+		Timeout: time.Duration(60) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(response.Body)
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "rerank api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	var rerankResult biz_entity_openai_standard_response.RerankLargeModelResult
+
+	if err := json.NewDecoder(response.Body).Decode(&rerankResult); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	rerankDocs := make([]*biz_entity_openai_standard_response.RerankDocument, 0, len(rerankResult.Results))
+
+	for _, result := range rerankResult.Results {
+		if result.Index < 0 || result.Index >= len(r.docs) {
+			continue
+		}
+
+		if result.RelevanceScore < r.scoreThreshold {
+			continue
+		}
+
+		rerankDocs = append(rerankDocs, &biz_entity_openai_standard_response.RerankDocument{
+			Index: result.Index,
+			Text:  r.docs[result.Index],
+			Score: result.RelevanceScore,
+		})
+	}
+
+	return &biz_entity_openai_standard_response.RerankResult{
+		Model: r.model,
+		Docs:  rerankDocs,
+	}, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+func TestJinaCompatibleRerank(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	var (
+		captured      map[string]interface{}
+		authorization string
+		path          string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &captured)
+		authorization = r.Header.Get("Authorization")
+		path = r.URL.Path
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"model":"jina-reranker-v2-base-multilingual","results":[{"index":2,"relevance_score":0.91},{"index":0,"relevance_score":0.42},{"index":7,"relevance_score":0.40},{"index":1,"relevance_score":0.05}]}`)
+	}))
+	defer server.Close()
+
+	credentials := map[string]interface{}{"api_key": "stub-key", "base_url": server.URL + "/v1"}
+	docs := []string{"Paris is in France.", "Bananas are yellow.", "Paris is the capital of France."}
+
+	result, err := NewJinaCompatibleRerankModel("jina-reranker-v2-base-multilingual", credentials, DEFAULT_BASE_URL, "capital of France", docs, 0.1, 10).Invoke(context.Background())
+
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+
+	if path != "/v1/rerank" || authorization != "Bearer stub-key" {
+		t.Errorf("unexpected path %s or authorization %s", path, authorization)
+	}
+
+	// top_n is clamped to the count of the documents
+	if captured["query"] != "capital of France" || captured["top_n"] != float64(3) || captured["return_documents"] != false || len(captured["documents"].([]interface{})) != 3 {
+		t.Errorf("unexpected request %v", captured)
+	}
+
+	// the unknown index and the score below the threshold are dropped
+	if len(result.Docs) != 2 || result.Docs[0].Index != 2 || result.Docs[0].Text != docs[2] || result.Docs[0].Score != 0.91 || result.Docs[1].Index != 0 {
+		t.Errorf("unexpected result %+v", result.Docs)
+	}
+}
+
+func TestJinaCompatibleRerankErrors(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"detail":"invalid api key"}`)
+	}))
+	defer server.Close()
+
+	credentials := map[string]interface{}{"api_key": "stub-key", "base_url": server.URL}
+
+	if _, err := NewJinaCompatibleRerankModel("rerank-english-v3.0", credentials, DEFAULT_BASE_URL, "query", []string{"doc"}, 0, 1).Invoke(context.Background()); !errors.IsCode(err, code.ErrCallLargeLanguageModel) {
+		t.Errorf("expected ErrCallLargeLanguageModel on the rejected request, got %v", err)
+	}
+
+	if _, err := NewJinaCompatibleRerankModel("rerank-english-v3.0", map[string]interface{}{"base_url": server.URL}, DEFAULT_BASE_URL, "query", []string{"doc"}, 0, 1).Invoke(context.Background()); err == nil {
+		t.Error("expected error without api_key")
+	}
+
+	result, err := NewJinaCompatibleRerankModel("rerank-english-v3.0", credentials, DEFAULT_BASE_URL, "query", nil, 0, 1).Invoke(context.Background())
+
+	if err != nil || len(result.Docs) != 0 {
+		t.Errorf("expected an empty result without documents, got %+v, %v", result, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("expected 1 call of the rerank api, got %d", calls)
+	}
+}
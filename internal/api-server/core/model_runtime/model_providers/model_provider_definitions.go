@@ -0,0 +1,295 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_providers
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
+	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"gopkg.in/yaml.v3"
+)
+
+// RELOAD_DELAY debounces the bursts of file events, e.g. an editor saving a file or a whole dir being synced.
+const RELOAD_DELAY = 500 * time.Millisecond
+
+// LoadDefinitions loads the external provider definitions of dir, which has the same layout as this dir, e.g.
+// openai/openai.yaml, openai/llm/_position.yaml and openai/llm/gpt-4o.yaml. The mappings of a file are merged over
+// the embedded file of the same path, so that a file only carrying pricing changes the price of a model, and the
+// files which don't exist in the embedded definitions add new models. Invalid files are logged and skipped, a file
+// which becomes invalid keeps its previous definition.
+func (f *ModelProviderFactory) LoadDefinitions(dir string) error {
+	defer f.loadMu.Unlock()
+	f.loadMu.Lock()
+
+	dirEntries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	resolved, err := f.resolveProviders()
+
+	if err != nil {
+		return err
+	}
+
+	providerPaths := make(map[string]string, len(resolved.paths))
+
+	for _, path := range resolved.paths {
+		providerPaths[filepath.Base(path)] = path
+	}
+
+	f.mu.RLock()
+	previous := f.overlays
+	f.mu.RUnlock()
+
+	overlays := make(map[string]biz_entity_model.SchemaOverlay)
+
+	for _, dirEntry := range dirEntries {
+		provider := dirEntry.Name()
+
+		if !dirEntry.IsDir() || strings.HasPrefix(provider, "_") || strings.HasPrefix(provider, ".") {
+			continue
+		}
+
+		providerPath, ok := providerPaths[provider]
+
+		if !ok {
+			log.Warnf("provider definitions of %s are ignored, the provider is neither built-in nor served by a plugin", provider)
+			continue
+		}
+
+		overlays[provider] = f.loadProviderDefinitions(filepath.Join(dir, provider), providerPath, provider, previous[provider])
+	}
+
+	f.mu.Lock()
+	f.overlays = overlays
+	f.mu.Unlock()
+
+	log.Infof("provider definitions of %d providers are loaded from %s", len(overlays), dir)
+
+	return nil
+}
+
+func (f *ModelProviderFactory) loadProviderDefinitions(dir, providerPath, provider string, previous biz_entity_model.SchemaOverlay) biz_entity_model.SchemaOverlay {
+	overlay := make(biz_entity_model.SchemaOverlay)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".yaml") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		content, err := loadDefinition(path, providerPath, provider, relPath)
+
+		if err == nil {
+			overlay[relPath] = content
+			return nil
+		}
+
+		if content, ok := previous[relPath]; ok {
+			overlay[relPath] = content
+			log.Errorf("invalid provider definition %s, the previous definition is kept: %s", path, err.Error())
+		} else {
+			log.Errorf("invalid provider definition %s is ignored: %s", path, err.Error())
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		log.Errorf("failed to load provider definitions of %s: %s", dir, err.Error())
+	}
+
+	return overlay
+}
+
+// loadDefinition returns the definition at path merged over the embedded one, after validating it.
+func loadDefinition(path, providerPath, provider, relPath string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	base, err := os.ReadFile(filepath.Join(providerPath, relPath))
+	added := os.IsNotExist(err)
+
+	if err != nil && !added {
+		return nil, err
+	}
+
+	if !added {
+		if content, err = biz_entity_model.MergeYAML(base, content); err != nil {
+			return nil, err
+		}
+	}
+
+	modelTypeDir, fileName := filepath.Split(relPath)
+
+	if modelTypeDir == "" {
+		return content, validateProviderDefinition(content, provider, fileName)
+	}
+
+	modelTypeDir = strings.TrimSuffix(modelTypeDir, "/")
+	modelType := common.ModelType(strings.ReplaceAll(modelTypeDir, "_", "-"))
+
+	if strings.Contains(modelTypeDir, "/") || !slices.Contains(common.ModelTypeEnums, string(modelType)) {
+		return nil, fmt.Errorf("%s is not a dir of model type", modelTypeDir)
+	}
+
+	if fileName == POSITION_FILE {
+		var positions []string
+		return content, yaml.Unmarshal(content, &positions)
+	}
+
+	return content, validateModelDefinition(content, filepath.Join(providerPath, modelTypeDir), modelType, fileName, added)
+}
+
+func validateProviderDefinition(content []byte, provider, fileName string) error {
+	if fileName != fmt.Sprintf("%s.yaml", provider) {
+		return fmt.Errorf("only %s.yaml is allowed in the dir of the provider", provider)
+	}
+
+	providerSchema := &biz_entity.ProviderStaticConfiguration{}
+
+	if err := yaml.Unmarshal(content, providerSchema); err != nil {
+		return err
+	}
+
+	if providerSchema.Provider != provider {
+		return fmt.Errorf("provider %s doesn't match the dir %s", providerSchema.Provider, provider)
+	}
+
+	return nil
+}
+
+func validateModelDefinition(content []byte, modelTypePath string, modelType common.ModelType, fileName string, added bool) error {
+	modelSchema := &biz_entity_model.AIModelStaticConfiguration{ProviderModel: &common.ProviderModel{}}
+
+	if err := yaml.Unmarshal(content, modelSchema); err != nil {
+		return err
+	}
+
+	if modelSchema.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+
+	if modelSchema.ModelType != modelType {
+		return fmt.Errorf("model_type %s doesn't match the dir %s", modelSchema.ModelType, modelType)
+	}
+
+	if !added {
+		return nil
+	}
+
+	// a model added under another file name would be listed twice
+	embeddedModels, err := (&biz_entity_model.AIModelRuntime{ModelType: modelType, ModelConfPath: modelTypePath}).PredefinedModels()
+
+	if err != nil {
+		return err
+	}
+
+	for _, embeddedModel := range embeddedModels {
+		if embeddedModel.Model == modelSchema.Model {
+			return fmt.Errorf("model %s is already defined, override it with a file of the same name as the embedded one instead of %s", modelSchema.Model, fileName)
+		}
+	}
+
+	return nil
+}
+
+// WatchDefinitions reloads the external provider definitions of dir whenever its files change,
+// until the returned watcher is closed.
+func (f *ModelProviderFactory) WatchDefinitions(dir string) (io.Closer, error) {
+	watcher, err := fsnotify.NewWatcher()
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	if err := watchDirs(watcher, dir); err != nil {
+		watcher.Close()
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	reloadTimer := time.AfterFunc(RELOAD_DELAY, func() {
+		if err := f.LoadDefinitions(dir); err != nil {
+			log.Errorf("failed to reload provider definitions of %s: %s", dir, err.Error())
+		}
+	})
+	// the timer only fires after file events
+	reloadTimer.Stop()
+
+	go func() {
+		defer reloadTimer.Stop()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				// the dirs created after the watch started, e.g. a new provider or model type, are watched as well
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						if err := watchDirs(watcher, event.Name); err != nil {
+							log.Errorf("failed to watch provider definitions of %s: %s", event.Name, err.Error())
+						}
+					}
+				}
+
+				reloadTimer.Reset(RELOAD_DELAY)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				log.Errorf("failed to watch provider definitions of %s: %s", dir, err.Error())
+			}
+		}
+	}()
+
+	return watcher, nil
+}
+
+// watchDirs adds dir and its sub dirs to the watcher, since fsnotify doesn't watch recursively.
+func watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+
+		return nil
+	})
+}
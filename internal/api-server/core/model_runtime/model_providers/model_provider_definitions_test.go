@@ -0,0 +1,143 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_providers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+)
+
+func writeDefinition(t *testing.T, dir, path, content string) {
+	t.Helper()
+
+	path = filepath.Join(dir, path)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func modelSchemaPrice(t *testing.T, mf *ModelProviderFactory, provider, model string) float64 {
+	t.Helper()
+
+	providerInstance, err := mf.GetProviderInstance(provider)
+
+	if err != nil {
+		t.Fatalf("GetProviderInstance() error = %v", err)
+	}
+
+	modelSchema, err := providerInstance.GetModelInstance(common.LLM).GetModelSchema(model, nil)
+
+	if err != nil {
+		t.Fatalf("GetModelSchema(%s) error = %v", model, err)
+	}
+
+	return float64(modelSchema.Pricing.Input)
+}
+
+func TestLoadDefinitions(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	dir := t.TempDir()
+	mf := &ModelProviderFactory{}
+
+	writeDefinition(t, dir, "openai/openai.yaml", "label:\n  en_US: OpenAI Proxy\n")
+	writeDefinition(t, dir, "openai/llm/gpt-4o.yaml", "pricing:\n  input: '2.50'\n")
+	writeDefinition(t, dir, "openai/llm/gpt-4o-next.yaml", "model: gpt-4o-next\nmodel_type: llm\nmodel_properties:\n  mode: chat\n  context_size: 256000\n")
+	writeDefinition(t, dir, "openai/llm/gpt-4o-copy.yaml", "model: gpt-4o\nmodel_type: llm\n")
+	writeDefinition(t, dir, "openai/llm/broken.yaml", "model: [broken\n")
+	writeDefinition(t, dir, "openai/text_embedding/gpt-4o-mini.yaml", "model: gpt-4o-mini\nmodel_type: llm\n")
+	writeDefinition(t, dir, "unknown/unknown.yaml", "provider: unknown\n")
+
+	if err := mf.LoadDefinitions(dir); err != nil {
+		t.Fatalf("LoadDefinitions() error = %v", err)
+	}
+
+	if price := modelSchemaPrice(t, mf, "openai", "gpt-4o"); price != 2.5 {
+		t.Errorf("price of gpt-4o = %v, want 2.5", price)
+	}
+
+	providerInstance, _ := mf.GetProviderInstance("openai")
+	provider, err := providerInstance.GetProviderSchema()
+
+	if err != nil || provider.Label.En_US != "OpenAI Proxy" || provider.Provider != "openai" {
+		t.Errorf("GetProviderSchema() = %+v, %v", provider, err)
+	}
+
+	models, err := providerInstance.GetModelInstance(common.LLM).PredefinedModels()
+
+	if err != nil {
+		t.Fatalf("PredefinedModels() error = %v", err)
+	}
+
+	count := make(map[string]int)
+
+	for _, model := range models {
+		count[model.Model] += 1
+	}
+
+	if count["gpt-4o-next"] != 1 || count["gpt-4o"] != 1 || count["broken"] != 0 {
+		t.Errorf("PredefinedModels() counts = %v", count)
+	}
+
+	// an invalid change keeps the previous definition, the deleted definition falls back to the embedded one
+	writeDefinition(t, dir, "openai/llm/gpt-4o.yaml", "pricing: [\n")
+	os.Remove(filepath.Join(dir, "openai/openai.yaml"))
+
+	if err := mf.LoadDefinitions(dir); err != nil {
+		t.Fatalf("LoadDefinitions() error = %v", err)
+	}
+
+	if price := modelSchemaPrice(t, mf, "openai", "gpt-4o"); price != 2.5 {
+		t.Errorf("price of gpt-4o = %v, want the previous 2.5", price)
+	}
+
+	providerInstance, _ = mf.GetProviderInstance("openai")
+
+	if provider, _ := providerInstance.GetProviderSchema(); provider.Label.En_US == "OpenAI Proxy" {
+		t.Errorf("GetProviderSchema() label = %s, want the embedded one", provider.Label.En_US)
+	}
+}
+
+func TestWatchDefinitions(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	dir := t.TempDir()
+	mf := &ModelProviderFactory{}
+
+	writeDefinition(t, dir, "openai/llm/gpt-4o.yaml", "pricing:\n  input: '2.50'\n")
+
+	if err := mf.LoadDefinitions(dir); err != nil {
+		t.Fatalf("LoadDefinitions() error = %v", err)
+	}
+
+	watcher, err := mf.WatchDefinitions(dir)
+
+	if err != nil {
+		t.Fatalf("WatchDefinitions() error = %v", err)
+	}
+
+	defer watcher.Close()
+
+	writeDefinition(t, dir, "deepseek/llm/deepseek-chat.yaml", "pricing:\n  input: '9'\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for modelSchemaPrice(t, mf, "deepseek", "deepseek-chat") != 9 {
+		if time.Now().After(deadline) {
+			t.Fatalf("definitions are not reloaded after the change")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
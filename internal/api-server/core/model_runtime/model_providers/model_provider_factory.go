@@ -10,9 +10,12 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
+	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
 	"gopkg.in/yaml.v3"
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
@@ -27,7 +30,22 @@ const (
 
 var Factory = ModelProviderFactory{}
 
-type ModelProviderFactory struct{}
+type ModelProviderFactory struct {
+	// schema dirs of the providers served by model plugins, they're listed after the built-in providers
+	pluginProviderPaths []string
+	// overlays of the external provider definitions keyed by provider name
+	overlays map[string]biz_entity_model.SchemaOverlay
+	// cache of the provider dirs and positions, it's dropped when a provider is added
+	resolved *resolvedProviders
+	mu       sync.RWMutex
+	loadMu   sync.Mutex
+}
+
+type resolvedProviders struct {
+	paths           []string
+	positionMap     map[string]int
+	orderedProvider []string
+}
 
 type ModelProviderExtension struct {
 	ProviderInstance *biz_entity.ProviderRuntime
@@ -122,13 +140,14 @@ func (f *ModelProviderFactory) extensionsConvertProviderEntity(
 func (f *ModelProviderFactory) resolveProviderExtensions(
 	modelProviderResolvePaths []string,
 	positionMap map[string]int,
+	overlays map[string]biz_entity_model.SchemaOverlay,
 ) []*ModelProviderExtension {
 	modelProviderExtensions := make([]*ModelProviderExtension, 0, PROVIDER_COUNT)
 	for _, path := range modelProviderResolvePaths {
 		modelProviderName := filepath.Base(path)
 		modelProviderExtension := &ModelProviderExtension{
 			Name:             modelProviderName,
-			ProviderInstance: &biz_entity.ProviderRuntime{ModelConfPath: path},
+			ProviderInstance: &biz_entity.ProviderRuntime{ModelConfPath: path, Overlay: overlays[modelProviderName]},
 			Position:         positionMap[modelProviderName],
 		}
 
@@ -138,6 +157,38 @@ func (f *ModelProviderFactory) resolveProviderExtensions(
 	return modelProviderExtensions
 }
 
+// RegisterPluginProvider adds the provider served by a model plugin, the base of schemaDir is the name of the provider.
+func (f *ModelProviderFactory) RegisterPluginProvider(schemaDir string) {
+	defer f.mu.Unlock()
+	f.mu.Lock()
+
+	for _, path := range f.pluginProviderPaths {
+		if filepath.Base(path) == filepath.Base(schemaDir) {
+			return
+		}
+	}
+
+	f.pluginProviderPaths = append(f.pluginProviderPaths, schemaDir)
+	f.resolved = nil
+}
+
+// GetProviderPath returns the schema dir of the provider, built-in or served by a model plugin.
+func (f *ModelProviderFactory) GetProviderPath(provider string) (string, error) {
+	providerMap, _, err := f.getMapProvidersExtensions()
+
+	if err != nil {
+		return "", err
+	}
+
+	providerExtension, ok := providerMap[provider]
+
+	if !ok {
+		return "", errors.WithCode(code.ErrProviderMapModel, "invalid provider: %v", provider)
+	}
+
+	return providerExtension.ProviderInstance.ModelConfPath, nil
+}
+
 func (f *ModelProviderFactory) ResolveProviderDirPath() (string, error) {
 	_, fullFilePath, _, ok := runtime.Caller(0)
 	if !ok {
@@ -181,25 +232,73 @@ func (f *ModelProviderFactory) resolveProviderDir(dirEntries []fs.DirEntry, full
 }
 
 func (f *ModelProviderFactory) getMapProvidersExtensions() (map[string]*ModelProviderExtension, []string, error) {
-	dirEntries, fullFilePath, fileDir, err := f.resolveProviderDirInfo()
+	resolved, err := f.resolveProviders()
 
 	if err != nil {
 		return nil, nil, err
 	}
 
+	f.mu.RLock()
+	overlays := f.overlays
+	f.mu.RUnlock()
+
+	resolveProviderExtensions := f.resolveProviderExtensions(resolved.paths, resolved.positionMap, overlays)
+
+	return f.resolveMapProviderExtensions(resolveProviderExtensions), slices.Clone(resolved.orderedProvider), nil
+}
+
+// resolveProviders scans the provider dirs once, the extensions are still created for each call since the
+// provider runtime caches its model instances.
+func (f *ModelProviderFactory) resolveProviders() (*resolvedProviders, error) {
+	f.mu.RLock()
+	resolved := f.resolved
+	pluginProviderPaths := f.pluginProviderPaths
+	f.mu.RUnlock()
+
+	if resolved != nil {
+		return resolved, nil
+	}
+
+	dirEntries, fullFilePath, fileDir, err := f.resolveProviderDirInfo()
+
+	if err != nil {
+		return nil, err
+	}
+
 	modelProviderResolvePaths, err := f.resolveProviderDir(dirEntries, fullFilePath)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	positionMap, orderedProvider, err := f.GetPositionMap(fileDir)
 
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	resolveProviderExtensions := f.resolveProviderExtensions(modelProviderResolvePaths, positionMap)
+	for _, path := range pluginProviderPaths {
+		providerName := filepath.Base(path)
+
+		if _, ok := positionMap[providerName]; !ok {
+			positionMap[providerName] = len(orderedProvider)
+			orderedProvider = append(orderedProvider, providerName)
+		}
+
+		modelProviderResolvePaths = append(modelProviderResolvePaths, path)
+	}
+
+	resolved = &resolvedProviders{
+		paths:           modelProviderResolvePaths,
+		positionMap:     positionMap,
+		orderedProvider: orderedProvider,
+	}
+
+	f.mu.Lock()
+	if len(f.pluginProviderPaths) == len(pluginProviderPaths) {
+		f.resolved = resolved
+	}
+	f.mu.Unlock()
 
-	return f.resolveMapProviderExtensions(resolveProviderExtensions), orderedProvider, nil
+	return resolved, nil
 }
 
 func (f *ModelProviderFactory) resolveMapProviderExtensions(
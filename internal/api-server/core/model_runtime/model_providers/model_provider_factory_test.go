@@ -35,3 +35,30 @@ func TestModelProviderFactory(t *testing.T) {
 		providers[2].Provider,
 	)
 }
+
+func TestPluginProvider(t *testing.T) {
+	mf := ModelProviderFactory{}
+	mf.RegisterPluginProvider("../model_plugin/echo_plugin/echo")
+
+	_, orderedProviders, err := mf.GetProvidersFromDir()
+
+	if err != nil {
+		t.Fatalf("GetProvidersFromDir() error = %v", err)
+	}
+
+	if orderedProviders[len(orderedProviders)-1] != "echo" {
+		t.Errorf("GetProvidersFromDir() ordered providers = %v, want echo at last", orderedProviders)
+	}
+
+	providerInstance, err := mf.GetProviderInstance("echo")
+
+	if err != nil {
+		t.Fatalf("GetProviderInstance() error = %v", err)
+	}
+
+	provider, err := providerInstance.GetProviderSchema()
+
+	if err != nil || provider.Provider != "echo" || len(provider.SupportedModelTypes) != 3 {
+		t.Errorf("GetProviderSchema() = %+v, %v", provider, err)
+	}
+}
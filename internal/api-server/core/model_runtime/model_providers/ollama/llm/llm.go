@@ -0,0 +1,514 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/ollama"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+type ollamaLargeLanguageModel struct {
+	IOllamaLargeLanguage
+}
+
+func init() {
+	NewOllamaLargeLanguageModel().Register()
+}
+
+func NewOllamaLargeLanguageModel() *ollamaLargeLanguageModel {
+	return &ollamaLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*ollamaLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*ollamaLargeLanguageModel)(nil)
+var _ provider_register.ICredentialValidator = (*ollamaLargeLanguageModel)(nil)
+
+func (m *ollamaLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IOllamaLargeLanguage = NewOllamaChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, modelRuntime, tools)
+	m.IOllamaLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *ollamaLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IOllamaLargeLanguage = NewOllamaChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, modelRuntime, nil)
+	return m.IOllamaLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *ollamaLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return ollama.ValidateCredentials(ctx, model, credentials)
+}
+
+func (m *ollamaLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *ollamaLargeLanguageModel) RegisterName() string {
+	return "ollama/llm"
+}
+
+// Tokenizer of the local models is unknown, the tokens are estimated by the char ratio.
+func (m *ollamaLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
+
+type IOllamaLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type ollamaChatLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	Stop                 []string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	toolCalls            []*biz_entity_openai_standard_response.ToolCall
+	agent                bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+func NewOllamaChatLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, stop []string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *ollamaChatLargeLanguageModel {
+	return &ollamaChatLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		Stop:            stop,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+	}
+}
+
+func (m *ollamaChatLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	if len(m.tools) > 0 {
+		m.agent = true
+	}
+
+	m.IStreamGenerateQueue = queue
+
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *ollamaChatLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	var chatResponse ollamaChatResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&chatResponse); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	if chatResponse.Error != "" {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, chatResponse.Error)
+	}
+
+	llmUsage, err := m.calcResponseUsage(chatResponse.PromptEvalCount, chatResponse.EvalCount)
+
+	if err != nil {
+		return nil, err
+	}
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(chatResponse.Message.Content)
+	m.appendToolCalls(chatResponse.Message.ToolCalls)
+	assistantMessage.ToolCalls = m.toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        chatResponse.DoneReason,
+	}, nil
+}
+
+func (m *ollamaChatLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	baseUrl, err := ollama.BaseUrl(m.Model, m.Credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpointUrl, err := url.JoinPath(baseUrl, "api/chat")
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	requestData, err := m.buildRequestData(stream)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke ollama llm request body %+v", requestData)
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	// local models may take a long time to be loaded into memory at the first call
+	client := http.Client{
+		Timeout: time.Duration(600) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+
+		// rate limit and server errors are retryable by the fallback models
+		if response.StatusCode == http.StatusTooManyRequests {
+			return nil, errors.WithCode(code.ErrModelRateLimited, "ollama returned status %d: %s", response.StatusCode, string(errBody))
+		}
+
+		if response.StatusCode >= http.StatusInternalServerError {
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "ollama returned status %d: %s", response.StatusCode, string(errBody))
+		}
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "ollama returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return response, nil
+}
+
+func (m *ollamaChatLargeLanguageModel) buildRequestData(stream bool) (map[string]interface{}, error) {
+	options := make(map[string]interface{})
+
+	for k, v := range m.ModelParameters {
+		switch k {
+		case "max_tokens":
+			options["num_predict"] = v
+		case "response_format", "format":
+			continue
+		default:
+			options[k] = v
+		}
+	}
+
+	if contextSize, ok := m.Credentials["context_size"].(string); ok && contextSize != "" {
+		numCtx, err := strconv.Atoi(contextSize)
+		if err != nil {
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "context_size %s is not a number", contextSize)
+		}
+		options["num_ctx"] = numCtx
+	}
+
+	if len(m.Stop) > 0 {
+		options["stop"] = m.Stop
+	}
+
+	messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	requestData := map[string]interface{}{
+		"model":    m.Model,
+		"stream":   stream,
+		"messages": messages,
+		"options":  options,
+	}
+
+	if format, ok := m.ModelParameters["response_format"].(string); ok && format == "json_object" {
+		requestData["format"] = "json"
+	}
+
+	if len(m.tools) > 0 {
+		functionTools := make([]*biz_entity_chat_prompt_message.PromptMessageFunction, 0, len(m.tools))
+		for _, tool := range m.tools {
+			functionTools = append(functionTools, biz_entity_chat_prompt_message.NewFunctionTools(tool))
+		}
+		requestData["tools"] = functionTools
+	}
+
+	return requestData, nil
+}
+
+func (m *ollamaChatLargeLanguageModel) convertPromptMessages() ([]*ollamaMessage, error) {
+	messages := make([]*ollamaMessage, 0, len(m.PromptMessages))
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			messages = append(messages, &ollamaMessage{Role: "tool", Content: message.GetContent()})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			content, _ := message.Content.(string)
+			ollamaMsg := &ollamaMessage{Role: "assistant", Content: content}
+
+			for _, toolCall := range message.ToolCalls {
+				arguments := make(map[string]interface{})
+
+				if toolCall.Function.Arguments != "" {
+					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &arguments); err != nil {
+						return nil, errors.WithCode(code.ErrDecodingJSON, "tool call %s arguments %s could not be decoded", toolCall.Function.Name, toolCall.Function.Arguments)
+					}
+				}
+
+				ollamaMsg.ToolCalls = append(ollamaMsg.ToolCalls, &ollamaToolCall{
+					Function: &ollamaToolCallFunction{Name: toolCall.Function.Name, Arguments: arguments},
+				})
+			}
+			messages = append(messages, ollamaMsg)
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			ollamaMsg := &ollamaMessage{Role: string(message.Role)}
+
+			switch content := message.Content.(type) {
+			case string:
+				ollamaMsg.Content = content
+			case []*biz_entity_chat_prompt_message.PromptMessageContent:
+				for _, messageContent := range content {
+					data, _ := messageContent.Data.(string)
+					switch messageContent.Type {
+					case biz_entity_chat_prompt_message.TEXT:
+						ollamaMsg.Content += data
+					case biz_entity_chat_prompt_message.IMAGE:
+						// ollama only accepts raw base64 images, the data url prefix should be trimmed
+						if _, payload, found := strings.Cut(data, ";base64,"); found {
+							data = payload
+						}
+						ollamaMsg.Images = append(ollamaMsg.Images, data)
+					}
+				}
+			default:
+				return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+			}
+			messages = append(messages, ollamaMsg)
+		default:
+			return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by ollama", promptMessage)
+		}
+	}
+
+	return messages, nil
+}
+
+// appendToolCalls converts ollama tool calls which don't carry an id, so an id is generated by the position of the call.
+func (m *ollamaChatLargeLanguageModel) appendToolCalls(toolCalls []*ollamaToolCall) {
+	for _, toolCall := range toolCalls {
+		if toolCall.Function == nil {
+			continue
+		}
+
+		arguments, _ := json.Marshal(toolCall.Function.Arguments)
+
+		m.toolCalls = append(m.toolCalls, &biz_entity_openai_standard_response.ToolCall{
+			ID:   fmt.Sprintf("call_%d", len(m.toolCalls)),
+			Type: "function",
+			Function: &biz_entity_openai_standard_response.ToolCallFunction{
+				Name:      toolCall.Function.Name,
+				Arguments: string(arguments),
+			},
+		})
+	}
+}
+
+func (m *ollamaChatLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" {
+			continue
+		}
+
+		var chunk ollamaChatResponse
+
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			m.PushErr(errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", line, err.Error()))
+			return
+		}
+
+		if chunk.Error != "" {
+			m.PushErr(errors.WithCode(code.ErrCallLargeLanguageModel, chunk.Error))
+			return
+		}
+
+		m.appendToolCalls(chunk.Message.ToolCalls)
+
+		if chunk.Message.Content != "" {
+			m.ChunkIndex += 1
+			m.FullAssistantContent += chunk.Message.Content
+			m.sendStreamChunkToQueue(ctx, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(chunk.Message.Content))
+		}
+
+		if chunk.Done {
+			llmUsage, err := m.calcResponseUsage(chunk.PromptEvalCount, chunk.EvalCount)
+
+			if err != nil {
+				m.PushErr(err)
+				return
+			}
+
+			assistantPromptMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+			assistantPromptMessage.ToolCalls = m.toolCalls
+
+			finishReason := chunk.DoneReason
+
+			if m.agent {
+				finishReason = biz_entity_base_stream_generator.AGENT_END
+			}
+
+			m.sendStreamFinalChunkToQueue(ctx, finishReason, assistantPromptMessage, llmUsage)
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		m.PushErr(errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+		return
+	}
+
+	m.PushErr(errors.WithCode(code.ErrCallLargeLanguageModel, "ollama stream of model %s closed before done", m.Model))
+}
+
+// calcResponseUsage falls back to free price because local models usually don't have price definition.
+func (m *ollamaChatLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		promptPriceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		completePriceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *ollamaChatLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *ollamaChatLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult: &biz_entity_base_stream_generator.LLMResult{
+			Model:         m.Model,
+			PromptMessage: m.PromptMessages,
+			Reason:        finishReason,
+			Message:       assistantPromptMessage,
+			Usage:         llmUsage,
+		},
+	})
+}
+
+type ollamaToolCallFunction struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+type ollamaToolCall struct {
+	Function *ollamaToolCallFunction `json:"function"`
+}
+
+type ollamaMessage struct {
+	Role      string            `json:"role"`
+	Content   string            `json:"content"`
+	Images    []string          `json:"images,omitempty"`
+	ToolCalls []*ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         ollamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int64         `json:"prompt_eval_count"`
+	EvalCount       int64         `json:"eval_count"`
+	Error           string        `json:"error"`
+}
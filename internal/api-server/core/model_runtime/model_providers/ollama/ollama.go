@@ -0,0 +1,117 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package ollama
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type localModel struct {
+	Name  string `json:"name"`
+	Model string `json:"model"`
+}
+
+type tagsResponse struct {
+	Models []*localModel `json:"models"`
+}
+
+// BaseUrl returns the ollama server url configured in the base_url credential.
+func BaseUrl(model string, credentials map[string]interface{}) (string, error) {
+	baseUrl, ok := credentials["base_url"].(string)
+
+	if !ok || baseUrl == "" {
+		return "", errors.WithCode(code.ErrModelNotHaveEndPoint, "Model %s not have base_url", model)
+	}
+
+	if _, err := url.ParseRequestURI(baseUrl); err != nil {
+		return "", errors.WithCode(code.ErrInvalidCredentials, "base_url %s is not a valid url", baseUrl)
+	}
+
+	return strings.TrimSuffix(baseUrl, "/"), nil
+}
+
+// ListModels lists the models which have been pulled to the ollama server.
+func ListModels(ctx context.Context, baseUrl string) ([]string, error) {
+	endpointUrl, err := url.JoinPath(baseUrl, "api/tags")
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpointUrl, nil)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(10) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "ollama server %s is unreachable: %s", baseUrl, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(response.Body)
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "ollama server %s returned status %d: %s", baseUrl, response.StatusCode, string(errBody))
+	}
+
+	var tags tagsResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&tags); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	models := make([]string, 0, len(tags.Models))
+
+	for _, m := range tags.Models {
+		models = append(models, m.Name)
+	}
+
+	return models, nil
+}
+
+// ValidateCredentials checks that the ollama server is reachable and the model has been pulled,
+// a model name without tag matches the "latest" tag like the ollama cli does.
+func ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	baseUrl, err := BaseUrl(model, credentials)
+
+	if err != nil {
+		return err
+	}
+
+	models, err := ListModels(ctx, baseUrl)
+
+	if err != nil {
+		return err
+	}
+
+	candidate := model
+
+	if !strings.Contains(candidate, ":") {
+		candidate = candidate + ":latest"
+	}
+
+	if slices.Contains(models, model) || slices.Contains(models, candidate) {
+		return nil
+	}
+
+	return errors.WithCode(code.ErrInvalidCredentials, "model %s has not been pulled to ollama server %s, available models: %s", model, baseUrl, strings.Join(models, ", "))
+}
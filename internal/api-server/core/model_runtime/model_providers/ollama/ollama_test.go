@@ -0,0 +1,40 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package ollama
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		io.WriteString(w, `{"models":[{"name":"llama3.1:latest","model":"llama3.1:latest"},{"name":"nomic-embed-text:v1.5","model":"nomic-embed-text:v1.5"}]}`)
+	}))
+	defer server.Close()
+
+	credentials := map[string]interface{}{"base_url": server.URL}
+
+	for _, model := range []string{"llama3.1", "llama3.1:latest", "nomic-embed-text:v1.5"} {
+		if err := ValidateCredentials(context.Background(), model, credentials); err != nil {
+			t.Errorf("model %s should be valid, got %s", model, err.Error())
+		}
+	}
+
+	if err := ValidateCredentials(context.Background(), "qwen2", credentials); err == nil {
+		t.Error("model qwen2 has not been pulled and should be invalid")
+	}
+
+	if err := ValidateCredentials(context.Background(), "llama3.1", map[string]interface{}{"base_url": "http://127.0.0.1:1"}); err == nil {
+		t.Error("unreachable base url should be invalid")
+	}
+}
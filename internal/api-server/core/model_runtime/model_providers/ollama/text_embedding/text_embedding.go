@@ -0,0 +1,137 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/ollama"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type ollamaTextEmbedding struct{}
+
+func init() {
+	NewOllamaTextEmbedding().Register()
+}
+
+func NewOllamaTextEmbedding() *ollamaTextEmbedding {
+	return &ollamaTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*ollamaTextEmbedding)(nil)
+var _ model_registry.ICredentialValidator = (*ollamaTextEmbedding)(nil)
+
+func (m *ollamaTextEmbedding) RegisterName() string {
+	return "ollama/text-embedding"
+}
+
+func (m *ollamaTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *ollamaTextEmbedding) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return ollama.ValidateCredentials(ctx, model, credentials)
+}
+
+func (m *ollamaTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	baseUrl, err := ollama.BaseUrl(model, credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpointUrl, err := url.JoinPath(baseUrl, "api/embed")
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	requestData := map[string]interface{}{
+		"model": model,
+		"input": texts,
+	}
+
+	log.Infof("Invoke ollama text-embedding request body %+v", requestData)
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(response.Body)
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "ollama returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	var embedResponse ollamaEmbedResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&embedResponse); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	return &biz_entity_openai_standard_response.TextEmbeddingResult{
+		Model:      model,
+		Embeddings: embedResponse.Embeddings,
+		Usage:      m.calcResponseUsage(model, credentials, modelRuntime, embedResponse.PromptEvalCount),
+	}, nil
+}
+
+// calcResponseUsage falls back to free price because local models usually don't have price definition.
+func (m *ollamaTextEmbedding) calcResponseUsage(model string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime, tokens int) *biz_entity_openai_standard_response.EmbeddingUsage {
+	priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, int64(tokens))
+
+	if err != nil {
+		priceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	return &biz_entity_openai_standard_response.EmbeddingUsage{
+		Tokens:      tokens,
+		TotalTokens: tokens,
+		UnitPrice:   priceInfo.UnitPrice,
+		PriceUnit:   priceInfo.Unit,
+		TotalPrice:  priceInfo.TotalAmount,
+		Currency:    priceInfo.Currency,
+		Latency:     0.3,
+	}
+}
+
+type ollamaEmbedResponse struct {
+	Model           string      `json:"model"`
+	Embeddings      [][]float32 `json:"embeddings"`
+	PromptEvalCount int         `json:"prompt_eval_count"`
+}
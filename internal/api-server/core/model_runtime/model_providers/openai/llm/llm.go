@@ -0,0 +1,71 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/llm"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type openaiLargeLanguageModel struct {
+	llm.IOpenApiCompactLargeLanguage
+}
+
+func init() {
+	NewOpenaiLargeLanguageModel().Register()
+}
+
+func NewOpenaiLargeLanguageModel() *openaiLargeLanguageModel {
+	return &openaiLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*openaiLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*openaiLargeLanguageModel)(nil)
+
+func (m *openaiLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	credentials = openai.ToCompatibleCredentials(credentials)
+	m.IOpenApiCompactLargeLanguage = llm.NewOpenApiCompactLargeLanguageModel(promptMessages, m.withStreamUsage(modelParameters), credentials, model, modelRuntime, tools)
+	m.IOpenApiCompactLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *openaiLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	credentials = openai.ToCompatibleCredentials(credentials)
+	m.IOpenApiCompactLargeLanguage = llm.NewOpenApiCompactLargeLanguageModel(promptMessages, modelParameters, credentials, model, modelRuntime, nil)
+	return m.IOpenApiCompactLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *openaiLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *openaiLargeLanguageModel) RegisterName() string {
+	return "openai/llm"
+}
+
+func (m *openaiLargeLanguageModel) Tokenizer(model string) string {
+	return openai.OpenAITokenizer(model)
+}
+
+// withStreamUsage asks openai to send the token usage in the last chunk of the stream,
+// otherwise the stream response doesn't carry usage at all.
+func (m *openaiLargeLanguageModel) withStreamUsage(modelParameters map[string]interface{}) map[string]interface{} {
+	parameters := make(map[string]interface{}, len(modelParameters)+1)
+
+	for k, v := range modelParameters {
+		parameters[k] = v
+	}
+
+	parameters["stream_options"] = map[string]interface{}{
+		"include_usage": true,
+	}
+
+	return parameters
+}
@@ -0,0 +1,104 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const recordedStream = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"},"finish_reason":null}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":" there."},"finish_reason":null}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"stop"}],"usage":null}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}
+
+data: [DONE]
+
+`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "USD"}, nil
+}
+
+func TestOpenaiChatStreamUsage(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	var captured map[string]interface{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &captured)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, recordedStream)
+	}))
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewUserMessage("Say hello."),
+	}
+
+	credentials := map[string]interface{}{"openai_api_key": "stub-key", "openai_api_base": server.URL}
+	queue := &fakeQueue{}
+
+	NewOpenaiLargeLanguageModel().Invoke(context.Background(), queue, "gpt-4o-mini", credentials, map[string]interface{}{}, nil, "", promptMessages, &fakeModelRuntime{}, nil)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	if streamOptions, _ := captured["stream_options"].(map[string]interface{}); streamOptions["include_usage"] != true {
+		t.Errorf("unexpected request %v", captured)
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil {
+		t.Fatalf("expected 2 llm chunks and a message end event, got %d chunks", len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Hello there." || result.Reason != "stop" {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if result.Usage.PromptTokens != 12 || result.Usage.CompletionTokens != 3 || result.Usage.TotalTokens != 15 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/moderation"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type openaiModerationModel struct {
+	moderation.IOpenApiCompactModerationModel
+}
+
+func init() {
+	NewOpenaiModerationModel().Register()
+}
+
+func NewOpenaiModerationModel() *openaiModerationModel {
+	return &openaiModerationModel{}
+}
+
+var _ provider_register.IModerationRegistry = (*openaiModerationModel)(nil)
+
+func (m *openaiModerationModel) Invoke(ctx context.Context, model string, credentials map[string]interface{}, text string, user string, modelRuntime biz_entity.IAIModelRuntime) (bool, error) {
+	credentials = openai.ToCompatibleCredentials(credentials)
+	m.IOpenApiCompactModerationModel = moderation.NewOpenApiCompactModerationModel(text, credentials, model, modelRuntime)
+	return m.IOpenApiCompactModerationModel.Invoke(ctx)
+}
+
+func (m *openaiModerationModel) Register() {
+	provider_register.ModerationRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *openaiModerationModel) RegisterName() string {
+	return "openai/moderation"
+}
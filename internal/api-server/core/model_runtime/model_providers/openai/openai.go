@@ -0,0 +1,55 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package openai
+
+import (
+	"strings"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+)
+
+const (
+	DEFAULT_API_BASE = "https://api.openai.com"
+)
+
+// ToCompatibleCredentials maps the openai provider credential form to the openai api compatible one,
+// openai_api_base is the host with or without the `/v1` path.
+func ToCompatibleCredentials(credentials map[string]interface{}) map[string]interface{} {
+	apiBase, ok := credentials["openai_api_base"].(string)
+
+	if !ok || apiBase == "" {
+		apiBase = DEFAULT_API_BASE
+	}
+
+	compatibleCredentials := map[string]interface{}{
+		"mode":         "chat",
+		"endpoint_url": strings.TrimSuffix(strings.TrimSuffix(apiBase, "/"), "/v1") + "/v1",
+	}
+
+	if apiKey, ok := credentials["openai_api_key"]; ok {
+		compatibleCredentials["api_key"] = apiKey
+	}
+
+	if organization, ok := credentials["openai_organization"].(string); ok && organization != "" {
+		compatibleCredentials["extra_headers"] = map[string]string{
+			"OpenAI-Organization": organization,
+		}
+	}
+
+	return compatibleCredentials
+}
+
+// o200kModelPrefixes are the models encoded by o200k_base, the earlier models are encoded by cl100k_base.
+var o200kModelPrefixes = []string{"gpt-4o", "chatgpt-4o", "gpt-4.1", "gpt-4.5", "o1", "o3", "o4"}
+
+// OpenAITokenizer returns the tokenizer of the openai model.
+func OpenAITokenizer(model string) string {
+	for _, prefix := range o200kModelPrefixes {
+		if strings.HasPrefix(model, prefix) {
+			return model_registry.O200K_BASE
+		}
+	}
+	return model_registry.CL100K_BASE
+}
@@ -0,0 +1,43 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/text_embedding"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type openaiTextEmbedding struct {
+	text_embedding.IOpenApiCompactTextEmbeddingModel
+}
+
+func init() {
+	NewOpenaiTextEmbedding().Register()
+}
+
+func NewOpenaiTextEmbedding() *openaiTextEmbedding {
+	return &openaiTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*openaiTextEmbedding)(nil)
+
+func (m *openaiTextEmbedding) RegisterName() string {
+	return "openai/text-embedding"
+}
+
+func (m *openaiTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *openaiTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	credentials = openai.ToCompatibleCredentials(credentials)
+	m.IOpenApiCompactTextEmbeddingModel = text_embedding.NewOpenApiCompactLargeLanguageModel(ctx, model, credentials, texts, modelRuntime)
+	return m.IOpenApiCompactTextEmbeddingModel.Invoke(ctx)
+}
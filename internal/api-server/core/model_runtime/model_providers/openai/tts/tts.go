@@ -0,0 +1,42 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tts
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible/tts"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type openaiTTSModel struct {
+	tts.IOpenApiCompactTTSModel
+}
+
+func init() {
+	NewOpenaiTTSModel().Register()
+}
+
+func NewOpenaiTTSModel() *openaiTTSModel {
+	return &openaiTTSModel{}
+}
+
+var _ provider_register.ITTSModelRegistry = (*openaiTTSModel)(nil)
+
+func (m *openaiTTSModel) RegisterName() string {
+	return "openai/tts"
+}
+
+func (m *openaiTTSModel) Register() {
+	provider_register.TTSModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *openaiTTSModel) Invoke(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user, tenantID string, voice string, modelRuntime biz_entity.IAIModelRuntime, format string, texts []string) error {
+	credentials = openai.ToCompatibleCredentials(credentials)
+	m.IOpenApiCompactTTSModel = tts.NewOpenApiCompactTTSModel(texts, voice, format, credentials, model, modelRuntime)
+	return m.IOpenApiCompactTTSModel.Invoke(ctx)
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -18,6 +19,7 @@ import (
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible"
 	"github.com/lunarianss/Luna/internal/api-server/domain/app/entity/po_entity"
 	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
 	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
@@ -124,6 +126,11 @@ func (m *openApiCompactLargeLanguageModel) generateNonStream(ctx context.Context
 	for k, v := range m.ModelParameters {
 		requestData[k] = v
 	}
+
+	if err := convertResponseFormat(requestData); err != nil {
+		return nil, err
+	}
+
 	messageItems := make([]map[string]interface{}, 0)
 
 	completionType := m.Credentials["mode"]
@@ -133,7 +140,12 @@ func (m *openApiCompactLargeLanguageModel) generateNonStream(ctx context.Context
 		if err != nil {
 			return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
 		}
-		endpointUrlStr = endpointJoinUrl
+
+		endpointUrlStr, err = openai_api_compatible.EndpointWithExtraQuery(endpointJoinUrl, m.Credentials)
+
+		if err != nil {
+			return nil, err
+		}
 
 		for _, promptMessage := range m.PromptMessages {
 			messageItem, err := promptMessage.ConvertToRequestData()
@@ -180,10 +192,15 @@ func (m *openApiCompactLargeLanguageModel) generateNonStream(ctx context.Context
 
 	response, err := client.Do(req)
 	if err != nil {
-		return nil, errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
 	}
 
 	defer response.Body.Close()
+
+	if err := checkResponseStatus(response); err != nil {
+		return nil, err
+	}
+
 	return m.handleNoStreamResponse(ctx, response)
 }
 
@@ -329,6 +346,12 @@ func (m *openApiCompactLargeLanguageModel) generate(ctx context.Context) {
 	for k, v := range m.ModelParameters {
 		requestData[k] = v
 	}
+
+	if err := convertResponseFormat(requestData); err != nil {
+		m.PushErr(err)
+		return
+	}
+
 	messageItems := make([]map[string]interface{}, 0)
 
 	completionType := m.Credentials["mode"]
@@ -339,7 +362,13 @@ func (m *openApiCompactLargeLanguageModel) generate(ctx context.Context) {
 			m.PushErr(errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
 			return
 		}
-		endpointUrlStr = endpointJoinUrl
+
+		endpointUrlStr, err = openai_api_compatible.EndpointWithExtraQuery(endpointJoinUrl, m.Credentials)
+
+		if err != nil {
+			m.PushErr(err)
+			return
+		}
 
 		for _, promptMessage := range m.PromptMessages {
 			messageItem, err := promptMessage.ConvertToRequestData()
@@ -403,15 +432,42 @@ func (m *openApiCompactLargeLanguageModel) generate(ctx context.Context) {
 
 	response, err := client.Do(req)
 	if err != nil {
-		m.PushErr(errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error()))
+		m.PushErr(errors.WithSCode(code.ErrModelServiceUnavailable, err.Error()))
 		return
 	}
 
 	defer response.Body.Close()
 
+	if err := checkResponseStatus(response); err != nil {
+		m.PushErr(err)
+		return
+	}
+
 	m.handleStreamResponse(ctx, response)
 }
 
+// checkResponseStatus converts the non 2xx response to error, rate limit and server errors are reported as
+// ErrModelRateLimited and ErrModelServiceUnavailable so that the caller can go down the fallback chain, rejected
+// keys as ErrInvalidCredentials so that the load balancer can cool them down.
+func checkResponseStatus(response *http.Response) error {
+	if response.StatusCode >= http.StatusOK && response.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+
+	errBody, _ := io.ReadAll(response.Body)
+
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return errors.WithCode(code.ErrModelRateLimited, "llm api returned status %d: %s", response.StatusCode, string(errBody))
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return errors.WithCode(code.ErrInvalidCredentials, "llm api returned status %d: %s", response.StatusCode, string(errBody))
+	case response.StatusCode >= http.StatusInternalServerError:
+		return errors.WithCode(code.ErrModelServiceUnavailable, "llm api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return errors.WithCode(code.ErrCallLargeLanguageModel, "llm api returned status %d: %s", response.StatusCode, string(errBody))
+}
+
 func (m *openApiCompactLargeLanguageModel) sendStreamChunkToQueue(ctx context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
 	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
 		ID:            messageId,
@@ -692,12 +748,15 @@ func (m *openApiCompactLargeLanguageModel) handleStreamResponse(ctx context.Cont
 			}
 		}
 
-		if chunkChoices, ok := chunkJson["choices"]; ok {
-			if v, ok := chunkChoices.([]interface{}); ok {
-				if vv, ok := v[0].(map[string]interface{}); ok {
-					chunkChoice = vv
-				}
-			}
+		chunkChoices, _ := chunkJson["choices"].([]interface{})
+
+		// the last chunk of stream_options.include_usage only carries the usage with empty choices
+		if len(chunkChoices) == 0 {
+			continue
+		}
+
+		if v, ok := chunkChoices[0].(map[string]interface{}); ok {
+			chunkChoice = v
 		}
 
 		messageID, ok = chunkChoice["id"].(string)
@@ -875,3 +934,37 @@ func (m *openApiCompactLargeLanguageModel) increaseToolCall(newToolCalls []*biz_
 		}
 	}
 }
+
+// convertResponseFormat converts the response_format parameter to the object of the chat completions api, the
+// json_schema parameter is the json text of {"name", "schema"} which is only sent within the json_schema format.
+// The other formats such as JSON and XML are prompt level formats and not sent to the api.
+func convertResponseFormat(requestData map[string]interface{}) error {
+	jsonSchema, _ := requestData["json_schema"].(string)
+	delete(requestData, "json_schema")
+
+	format, ok := requestData["response_format"].(string)
+
+	if !ok {
+		return nil
+	}
+
+	switch format {
+	case "text", "json_object":
+		requestData["response_format"] = map[string]interface{}{"type": format}
+	case "json_schema":
+		var schema map[string]interface{}
+
+		if err := json.Unmarshal([]byte(jsonSchema), &schema); err != nil {
+			return errors.WithCode(code.ErrModelParameter, "json_schema is not a valid json: %s", err.Error())
+		}
+
+		requestData["response_format"] = map[string]interface{}{
+			"type":        "json_schema",
+			"json_schema": schema,
+		}
+	default:
+		delete(requestData, "response_format")
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	DEFAULT_MAX_CHUNKS               = 32
+	DEFAULT_MAX_CHARACTERS_PER_CHUNK = 2000
+)
+
+type IOpenApiCompactModerationModel interface {
+	Invoke(ctx context.Context) (bool, error)
+}
+
+type OpenApiCompactModerationModel struct {
+	biz_entity.IAIModelRuntime
+	model       string
+	credentials map[string]interface{}
+	text        string
+}
+
+func NewOpenApiCompactModerationModel(text string, credentials map[string]interface{}, model string, modelRuntime biz_entity.IAIModelRuntime) *OpenApiCompactModerationModel {
+	return &OpenApiCompactModerationModel{
+		text:            text,
+		credentials:     credentials,
+		model:           model,
+		IAIModelRuntime: modelRuntime,
+	}
+}
+
+// Invoke reports whether the text is flagged by the `POST {endpoint_url}/moderations` api, the text is split
+// into chunks of max_characters_per_chunk and sent in batches of max_chunks.
+func (m *OpenApiCompactModerationModel) Invoke(ctx context.Context) (bool, error) {
+	if m.text == "" {
+		return false, nil
+	}
+
+	endpointUrl, ok := m.credentials["endpoint_url"].(string)
+
+	if !ok || endpointUrl == "" {
+		return false, errors.WithCode(code.ErrModelNotHaveEndPoint, "Model %s not have endpoint url", m.model)
+	}
+
+	endpointJoinUrl, err := url.JoinPath(endpointUrl, "moderations")
+
+	if err != nil {
+		return false, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	endpointJoinUrl, err = openai_api_compatible.EndpointWithExtraQuery(endpointJoinUrl, m.credentials)
+
+	if err != nil {
+		return false, err
+	}
+
+	maxChunks, maxCharactersPerChunk := m.modelProperties()
+
+	chunks := splitText(m.text, maxCharactersPerChunk)
+
+	for start := 0; start < len(chunks); start += maxChunks {
+		end := start + maxChunks
+
+		if end > len(chunks) {
+			end = len(chunks)
+		}
+
+		flagged, err := m.moderate(ctx, endpointJoinUrl, chunks[start:end])
+
+		if err != nil {
+			return false, err
+		}
+
+		if flagged {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *OpenApiCompactModerationModel) moderate(ctx context.Context, endpointUrl string, inputs []string) (bool, error) {
+	requestData := map[string]interface{}{
+		"model": m.model,
+		"input": inputs,
+	}
+
+	log.Infof("Invoke moderation request model %s, %d chunks", m.model, len(inputs))
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return false, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return false, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range openai_api_compatible.ExtraHeaders(m.credentials) {
+		req.Header.Set(k, v)
+	}
+
+	if apiKey, ok := m.credentials["api_key"]; ok {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(60) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return false, errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(response.Body)
+		return false, errors.WithCode(code.ErrCallLargeLanguageModel, "moderation api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	var moderationResponse moderationResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&moderationResponse); err != nil {
+		return false, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	for _, result := range moderationResponse.Results {
+		if result.Flagged {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *OpenApiCompactModerationModel) modelProperties() (int, int) {
+	maxChunks, maxCharactersPerChunk := DEFAULT_MAX_CHUNKS, DEFAULT_MAX_CHARACTERS_PER_CHUNK
+
+	if m.IAIModelRuntime == nil {
+		return maxChunks, maxCharactersPerChunk
+	}
+
+	modelSchema, err := m.GetModelSchema(m.model, m.credentials)
+
+	if err != nil || modelSchema == nil {
+		return maxChunks, maxCharactersPerChunk
+	}
+
+	if v, ok := modelSchema.ModelProperties[common.MAX_CHUNKS].(int); ok && v > 0 {
+		maxChunks = v
+	}
+
+	if v, ok := modelSchema.ModelProperties[common.MAX_CHARACTERS_PER_CHUNK].(int); ok && v > 0 {
+		maxCharactersPerChunk = v
+	}
+
+	return maxChunks, maxCharactersPerChunk
+}
+
+func splitText(text string, size int) []string {
+	var chunks []string
+
+	runes := []rune(text)
+
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[start:end]))
+	}
+
+	return chunks
+}
+
+type moderationResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Results []struct {
+		Flagged bool `json:"flagged"`
+	} `json:"results"`
+}
@@ -0,0 +1,50 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package openai_api_compatible
+
+import (
+	"net/url"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// EndpointWithExtraQuery appends the `extra_query` of the credentials to the endpoint url,
+// providers like azure openai require query parameters such as `api-version` on every request.
+func EndpointWithExtraQuery(endpointUrl string, credentials map[string]interface{}) (string, error) {
+	extraQuery, ok := credentials["extra_query"].(map[string]string)
+
+	if !ok || len(extraQuery) == 0 {
+		return endpointUrl, nil
+	}
+
+	endpoint, err := url.Parse(endpointUrl)
+
+	if err != nil {
+		return "", errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	query := endpoint.Query()
+
+	for k, v := range extraQuery {
+		query.Set(k, v)
+	}
+
+	endpoint.RawQuery = query.Encode()
+
+	return endpoint.String(), nil
+}
+
+// ExtraHeaders returns the `extra_headers` of the credentials, providers use it to send
+// authentication or organization headers which are not `Authorization: Bearer`.
+func ExtraHeaders(credentials map[string]interface{}) map[string]string {
+	extraHeaders, ok := credentials["extra_headers"].(map[string]string)
+
+	if !ok {
+		return nil
+	}
+
+	return extraHeaders
+}
@@ -12,6 +12,7 @@ import (
 	"strings"
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible"
 
 	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
@@ -85,6 +86,12 @@ func (m *OpenAudioApiCompactLargeLanguage) Invoke(ctx context.Context) (*biz_ent
 		return nil, err
 	}
 
+	endpointJoinUrl, err = openai_api_compatible.EndpointWithExtraQuery(endpointJoinUrl, m.credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
 	var requestBody bytes.Buffer
 
 	writer := multipart.NewWriter(&requestBody)
@@ -14,6 +14,7 @@ import (
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible"
 
 	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
@@ -49,6 +50,12 @@ func (o *openApiCompactLargeLanguageModel) Invoke(ctx context.Context) (*biz_ent
 		"Accept-Charset": "utf-8",
 	}
 
+	for k, v := range openai_api_compatible.ExtraHeaders(o.credentials) {
+		if _, ok := headers[k]; !ok {
+			headers[k] = v
+		}
+	}
+
 	if apiKey, ok := o.credentials["api_key"]; ok {
 		headers["Authorization"] = fmt.Sprintf("Bearer %s", apiKey)
 	}
@@ -74,6 +81,12 @@ func (o *openApiCompactLargeLanguageModel) Invoke(ctx context.Context) (*biz_ent
 		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
 	}
 
+	endpointUrl, err = openai_api_compatible.EndpointWithExtraQuery(endpointUrl, o.credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
 	requestData["input"] = o.texts
 
 	client := http.Client{
@@ -0,0 +1,245 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/openai_api_compatible"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	DEFAULT_VOICE      = "alloy"
+	DEFAULT_FORMAT     = "mp3"
+	DEFAULT_WORD_LIMIT = 3500
+)
+
+var audioContentTypes = map[string]string{
+	"mp3":  "audio/mpeg",
+	"opus": "audio/ogg",
+	"aac":  "audio/aac",
+	"flac": "audio/flac",
+	"wav":  "audio/wav",
+	"pcm":  "audio/pcm",
+}
+
+type IOpenApiCompactTTSModel interface {
+	Invoke(ctx context.Context) error
+}
+
+type OpenApiCompactTTSModel struct {
+	biz_entity.IAIModelRuntime
+	model       string
+	credentials map[string]interface{}
+	voice       string
+	format      string
+	texts       []string
+}
+
+func NewOpenApiCompactTTSModel(texts []string, voice, format string, credentials map[string]interface{}, model string, modelRuntime biz_entity.IAIModelRuntime) *OpenApiCompactTTSModel {
+	return &OpenApiCompactTTSModel{
+		texts:           texts,
+		voice:           voice,
+		format:          format,
+		credentials:     credentials,
+		model:           model,
+		IAIModelRuntime: modelRuntime,
+	}
+}
+
+// Invoke calls `POST {endpoint_url}/audio/speech` for every text and streams the audio to the response writer
+// of the gin context, texts longer than the word_limit of the model are split into several requests.
+func (m *OpenApiCompactTTSModel) Invoke(ctx context.Context) error {
+	ginContext, ok := ctx.(*gin.Context)
+
+	if !ok {
+		return errors.WithCode(code.ErrRunTimeCaller, "tts model %s must be invoked with a gin context", m.model)
+	}
+
+	endpointUrl, ok := m.credentials["endpoint_url"].(string)
+
+	if !ok || endpointUrl == "" {
+		return errors.WithCode(code.ErrModelNotHaveEndPoint, "Model %s not have endpoint url", m.model)
+	}
+
+	endpointJoinUrl, err := url.JoinPath(endpointUrl, "audio/speech")
+
+	if err != nil {
+		return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	endpointJoinUrl, err = openai_api_compatible.EndpointWithExtraQuery(endpointJoinUrl, m.credentials)
+
+	if err != nil {
+		return err
+	}
+
+	voice, wordLimit := m.modelProperties()
+
+	if m.voice != "" {
+		voice = m.voice
+	}
+
+	format := m.format
+
+	if _, ok := audioContentTypes[format]; !ok {
+		format = DEFAULT_FORMAT
+	}
+
+	headerWritten := false
+
+	for _, text := range m.texts {
+		for _, sentence := range splitText(text, wordLimit) {
+			response, err := m.speech(ctx, endpointJoinUrl, sentence, voice, format)
+
+			if err != nil {
+				return err
+			}
+
+			if !headerWritten {
+				ginContext.Writer.Header().Set("Content-Type", audioContentTypes[format])
+				ginContext.Writer.WriteHeader(http.StatusOK)
+				headerWritten = true
+			}
+
+			err = m.writeAudio(ginContext, response.Body)
+			response.Body.Close()
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *OpenApiCompactTTSModel) speech(ctx context.Context, endpointUrl, text, voice, format string) (*http.Response, error) {
+	requestData := map[string]interface{}{
+		"model":           m.model,
+		"input":           text,
+		"voice":           voice,
+		"response_format": format,
+	}
+
+	log.Infof("Invoke tts request model %s, voice %s, %d characters", m.model, voice, len([]rune(text)))
+
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range openai_api_compatible.ExtraHeaders(m.credentials) {
+		req.Header.Set(k, v)
+	}
+
+	if apiKey, ok := m.credentials["api_key"]; ok {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", apiKey))
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "tts api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return response, nil
+}
+
+func (m *OpenApiCompactTTSModel) writeAudio(ginContext *gin.Context, body io.Reader) error {
+	buffer := make([]byte, 32*1024)
+
+	for {
+		n, err := body.Read(buffer)
+
+		if n > 0 {
+			if _, writeErr := ginContext.Writer.Write(buffer[:n]); writeErr != nil {
+				return errors.WithSCode(code.ErrRunTimeCaller, writeErr.Error())
+			}
+			ginContext.Writer.Flush()
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return errors.WithSCode(code.ErrCallLargeLanguageModel, err.Error())
+		}
+	}
+}
+
+func (m *OpenApiCompactTTSModel) modelProperties() (string, int) {
+	voice, wordLimit := DEFAULT_VOICE, DEFAULT_WORD_LIMIT
+
+	if m.IAIModelRuntime == nil {
+		return voice, wordLimit
+	}
+
+	modelSchema, err := m.GetModelSchema(m.model, m.credentials)
+
+	if err != nil || modelSchema == nil {
+		return voice, wordLimit
+	}
+
+	if v, ok := modelSchema.ModelProperties[common.DEFAULT_VOICE].(string); ok && v != "" {
+		voice = v
+	}
+
+	if v, ok := modelSchema.ModelProperties[common.WORD_LIMIT].(int); ok && v > 0 {
+		wordLimit = v
+	}
+
+	return voice, wordLimit
+}
+
+func splitText(text string, size int) []string {
+	var chunks []string
+
+	runes := []rune(text)
+
+	for start := 0; start < len(runes); start += size {
+		end := start + size
+
+		if end > len(runes) {
+			end = len(runes)
+		}
+		chunks = append(chunks, string(runes[start:end]))
+	}
+
+	return chunks
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// SignURL appends the hmac-sha256 authorization to the websocket url, the signature covers the host, the date and
+// the request line of the handshake, the server rejects the dates skewed more than 5 minutes.
+func SignURL(chatUrl string, apiKey, apiSecret string, now time.Time) (string, error) {
+	u, err := url.Parse(chatUrl)
+
+	if err != nil {
+		return "", err
+	}
+
+	date := now.UTC().Format(time.RFC1123)
+	// time.RFC1123 formats the zone of utc as UTC, the api expects GMT
+	date = date[:len(date)-3] + "GMT"
+
+	signature := Signature(apiSecret, u.Host, date, u.EscapedPath())
+	authorization := fmt.Sprintf(`api_key="%s", algorithm="hmac-sha256", headers="host date request-line", signature="%s"`, apiKey, signature)
+
+	query := u.Query()
+	query.Set("authorization", base64.StdEncoding.EncodeToString([]byte(authorization)))
+	query.Set("date", date)
+	query.Set("host", u.Host)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Signature signs the handshake request of the path with the api secret.
+func Signature(apiSecret, host, date, path string) string {
+	origin := fmt.Sprintf("host: %s\ndate: %s\nGET %s HTTP/1.1", host, date, path)
+
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write([]byte(origin))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
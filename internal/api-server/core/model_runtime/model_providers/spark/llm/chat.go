@@ -0,0 +1,572 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	DEFAULT_BASE_URL = "wss://spark-api.xf-yun.com"
+	// MAX_UID_LENGTH is the max length of the uid of the request header
+	MAX_UID_LENGTH = 32
+	READ_TIMEOUT   = 300 * time.Second
+)
+
+// STATUS_END is the status of the last response frame, which carries the usage
+const STATUS_END = 2
+
+// the error codes of the response header
+const (
+	ERROR_CODE_USER_FLOW_LIMIT   = 10007
+	ERROR_CODE_SERVICE_BUSY      = 10110
+	ERROR_CODE_NETWORK           = 10222
+	ERROR_CODE_AUTHORIZATION     = 11200
+	ERROR_CODE_DAILY_LIMIT       = 11201
+	ERROR_CODE_QPS_LIMIT         = 11202
+	ERROR_CODE_CONCURRENCY_LIMIT = 11203
+)
+
+type chatEndpoint struct {
+	path   string
+	domain string
+}
+
+// chatEndpoints maps the models to the websocket path and the domain parameter of the chat api.
+var chatEndpoints = map[string]chatEndpoint{
+	"spark-1.5":       {path: "v1.1/chat", domain: "lite"},
+	"spark-lite":      {path: "v1.1/chat", domain: "lite"},
+	"spark-2":         {path: "v2.1/chat", domain: "generalv2"},
+	"spark-3":         {path: "v3.1/chat", domain: "generalv3"},
+	"spark-pro":       {path: "v3.1/chat", domain: "generalv3"},
+	"spark-pro-128k":  {path: "chat/pro-128k", domain: "pro-128k"},
+	"spark-3.5":       {path: "v3.5/chat", domain: "generalv3.5"},
+	"spark-max":       {path: "v3.5/chat", domain: "generalv3.5"},
+	"spark-max-32k":   {path: "chat/max-32k", domain: "max-32k"},
+	"spark-4":         {path: "v4.0/chat", domain: "4.0Ultra"},
+	"spark-4.0-ultra": {path: "v4.0/chat", domain: "4.0Ultra"},
+}
+
+// chatParameters are the model parameters accepted by the chat api, the rest are dropped.
+var chatParameters = map[string]bool{
+	"temperature":    true,
+	"max_tokens":     true,
+	"top_k":          true,
+	"show_ref_label": true,
+}
+
+type ISparkLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type sparkChatLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	User                 string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+	toolCalls            []*biz_entity_openai_standard_response.ToolCall
+	agent                bool
+	stream               bool
+	tools                []*biz_entity_chat_prompt_message.PromptMessageTool
+}
+
+func NewSparkChatLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, user string, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) *sparkChatLargeLanguageModel {
+	return &sparkChatLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		User:            user,
+		IAIModelRuntime: modelRuntime,
+		tools:           tools,
+		agent:           len(tools) > 0,
+	}
+}
+
+func (m *sparkChatLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	m.IStreamGenerateQueue = queue
+	m.stream = true
+
+	llmResult, err := m.generate(ctx)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	m.sendStreamFinalChunkToQueue(ctx, llmResult)
+}
+
+// InvokeNonStream collects the frames into a result, the chat api is streamed only.
+func (m *sparkChatLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	return m.generate(ctx)
+}
+
+func (m *sparkChatLargeLanguageModel) generate(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	conn, err := m.connect(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	// the blocked read is released by closing the connection when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	requestData, err := m.buildRequestData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke spark llm request body %+v", requestData)
+
+	if err := conn.WriteJSON(requestData); err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	return m.handleFrames(ctx, conn)
+}
+
+func (m *sparkChatLargeLanguageModel) connect(ctx context.Context) (*websocket.Conn, error) {
+	appID, _ := m.Credentials["app_id"].(string)
+	apiKey, _ := m.Credentials["api_key"].(string)
+	apiSecret, _ := m.Credentials["api_secret"].(string)
+
+	if appID == "" || apiKey == "" || apiSecret == "" {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "app_id, api_key and api_secret of spark are required")
+	}
+
+	endpoint, ok := chatEndpoints[m.Model]
+
+	if !ok {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "spark model %s is not supported", m.Model)
+	}
+
+	baseUrl, _ := m.Credentials["base_url"].(string)
+
+	if baseUrl == "" {
+		baseUrl = DEFAULT_BASE_URL
+	}
+
+	chatUrl, err := url.JoinPath(baseUrl, endpoint.path)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	signedUrl, err := SignURL(chatUrl, apiKey, apiSecret, time.Now())
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: 10 * time.Second,
+	}
+
+	conn, response, err := dialer.DialContext(ctx, signedUrl, nil)
+
+	if err != nil {
+		if response == nil {
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "failed to connect spark websocket: %s", err.Error())
+		}
+
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			return nil, errors.WithCode(code.ErrModelRateLimited, "spark handshake returned status %d", response.StatusCode)
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "spark handshake returned status %d", response.StatusCode)
+		}
+		return nil, errors.WithCode(code.ErrModelServiceUnavailable, "spark handshake returned status %d", response.StatusCode)
+	}
+
+	return conn, nil
+}
+
+func (m *sparkChatLargeLanguageModel) buildRequestData() (map[string]interface{}, error) {
+	appID, _ := m.Credentials["app_id"].(string)
+
+	chatParameter := map[string]interface{}{
+		"domain": chatEndpoints[m.Model].domain,
+	}
+
+	for k, v := range m.ModelParameters {
+		if chatParameters[k] {
+			chatParameter[k] = v
+		}
+	}
+
+	messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := map[string]interface{}{
+		"app_id": appID,
+	}
+
+	if m.User != "" {
+		header["uid"] = m.User[:min(len(m.User), MAX_UID_LENGTH)]
+	}
+
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"text": messages,
+		},
+	}
+
+	if len(m.tools) > 0 {
+		functions := make([]map[string]interface{}, 0, len(m.tools))
+		for _, tool := range m.tools {
+			functions = append(functions, map[string]interface{}{
+				"name":        tool.Name,
+				"description": tool.Description,
+				"parameters":  tool.Parameters,
+			})
+		}
+		payload["functions"] = map[string]interface{}{
+			"text": functions,
+		}
+	}
+
+	return map[string]interface{}{
+		"header": header,
+		"parameter": map[string]interface{}{
+			"chat": chatParameter,
+		},
+		"payload": payload,
+	}, nil
+}
+
+// convertPromptMessages converts the prompt messages to the text of the chat api, spark calls a function per
+// response so that each tool call of the assistant is sent as a function_call message.
+func (m *sparkChatLargeLanguageModel) convertPromptMessages() ([]*sparkMessage, error) {
+	var messages []*sparkMessage
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.ToolPromptMessage:
+			messages = append(messages, &sparkMessage{Role: "tool", Content: message.GetContent()})
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			content, _ := message.Content.(string)
+
+			if len(message.ToolCalls) == 0 {
+				messages = append(messages, &sparkMessage{Role: "assistant", Content: content})
+				continue
+			}
+
+			for _, toolCall := range message.ToolCalls {
+				messages = append(messages, &sparkMessage{
+					Role:    "assistant",
+					Content: content,
+					FunctionCall: &sparkFunctionCall{
+						Name:      toolCall.Function.Name,
+						Arguments: toolCall.Function.Arguments,
+					},
+				})
+				content = ""
+			}
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				content, _ := message.Content.(string)
+				messages = append(messages, &sparkMessage{Role: "system", Content: content})
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				content, _ := message.Content.(string)
+				messages = append(messages, &sparkMessage{Role: "assistant", Content: content})
+			case biz_entity_chat_prompt_message.USER:
+				content, err := convertUserContent(message.Content)
+				if err != nil {
+					return nil, err
+				}
+				messages = append(messages, &sparkMessage{Role: "user", Content: content})
+			}
+		default:
+			return nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by spark", promptMessage)
+		}
+	}
+
+	return messages, nil
+}
+
+// convertUserContent joins the text contents, spark chat models accept plain text only.
+func convertUserContent(content any) (string, error) {
+	switch content := content.(type) {
+	case string:
+		return content, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var texts []string
+		for _, messageContent := range content {
+			if data, ok := messageContent.Data.(string); ok && messageContent.Type == biz_entity_chat_prompt_message.TEXT {
+				texts = append(texts, data)
+			}
+		}
+		return strings.Join(texts, "\n"), nil
+	default:
+		return "", errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+// handleFrames reads the frames until the end status, the text is pushed to the queue in stream mode and the
+// function calls are collected as the tool calls of the result.
+func (m *sparkChatLargeLanguageModel) handleFrames(ctx context.Context, conn *websocket.Conn) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var (
+		sid          string
+		finishReason string
+		usage        sparkUsage
+	)
+
+	start := time.Now()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(READ_TIMEOUT))
+
+		_, message, err := conn.ReadMessage()
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, errors.WithSCode(code.ErrContextTimeout, ctx.Err().Error())
+			}
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "spark websocket closed before the end frame: %s", err.Error())
+		}
+
+		var frame sparkFrame
+
+		if err := json.Unmarshal(message, &frame); err != nil {
+			return nil, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", string(message), err.Error())
+		}
+
+		if frame.Header.Code != 0 {
+			return nil, frameError(frame.Header.Code, frame.Header.Message, frame.Header.Sid)
+		}
+
+		sid = frame.Header.Sid
+
+		for _, text := range frame.Payload.Choices.Text {
+			if text.FunctionCall != nil {
+				m.toolCalls = append(m.toolCalls, &biz_entity_openai_standard_response.ToolCall{
+					ID:   fmt.Sprintf("call_%s_%d", sid, len(m.toolCalls)),
+					Type: "function",
+					Function: &biz_entity_openai_standard_response.ToolCallFunction{
+						Name:      text.FunctionCall.Name,
+						Arguments: text.FunctionCall.Arguments,
+					},
+				})
+				continue
+			}
+
+			if text.Content == "" {
+				continue
+			}
+
+			m.ChunkIndex += 1
+			m.FullAssistantContent += text.Content
+
+			if m.stream {
+				m.sendStreamChunkToQueue(ctx, sid, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(text.Content))
+			}
+		}
+
+		if frame.Payload.Usage != nil {
+			usage = frame.Payload.Usage.Text
+		}
+
+		if frame.Header.Status == STATUS_END {
+			finishReason = "stop"
+			break
+		}
+	}
+
+	if len(m.toolCalls) > 0 {
+		finishReason = "tool_calls"
+	}
+
+	if m.agent {
+		finishReason = biz_entity_base_stream_generator.AGENT_END
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.PromptTokens, usage.CompletionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	llmUsage.Latency = time.Since(start).Seconds()
+
+	assistantMessage := biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent)
+	assistantMessage.ToolCalls = m.toolCalls
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            sid,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       assistantMessage,
+		Usage:         llmUsage,
+		Reason:        finishReason,
+	}, nil
+}
+
+// frameError maps the error code of the header so that the rate limited models go down the fallback chain.
+func frameError(errorCode int, message, sid string) error {
+	switch errorCode {
+	case ERROR_CODE_USER_FLOW_LIMIT, ERROR_CODE_DAILY_LIMIT, ERROR_CODE_QPS_LIMIT, ERROR_CODE_CONCURRENCY_LIMIT:
+		return errors.WithCode(code.ErrModelRateLimited, "spark error %d: %s, sid %s", errorCode, message, sid)
+	case ERROR_CODE_AUTHORIZATION:
+		return errors.WithCode(code.ErrInvalidCredentials, "spark error %d: %s, sid %s", errorCode, message, sid)
+	case ERROR_CODE_SERVICE_BUSY, ERROR_CODE_NETWORK:
+		return errors.WithCode(code.ErrModelServiceUnavailable, "spark error %d: %s, sid %s", errorCode, message, sid)
+	}
+
+	return errors.WithCode(code.ErrCallLargeLanguageModel, "spark error %d: %s, sid %s", errorCode, message, sid)
+}
+
+func (m *sparkChatLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *sparkChatLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	if m.agent {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage)
+		m.Push(&biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	} else {
+		event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+		m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: event,
+			Chunk:         streamResultChunk})
+	}
+}
+
+func (m *sparkChatLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, llmResult *biz_entity_base_stream_generator.LLMResult) {
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *sparkChatLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type sparkFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type sparkMessage struct {
+	Role         string             `json:"role"`
+	Content      string             `json:"content"`
+	FunctionCall *sparkFunctionCall `json:"function_call,omitempty"`
+}
+
+type sparkUsage struct {
+	QuestionTokens   int64 `json:"question_tokens"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type sparkFrameText struct {
+	Content      string             `json:"content"`
+	Role         string             `json:"role"`
+	ContentType  string             `json:"content_type"`
+	Index        int                `json:"index"`
+	FunctionCall *sparkFunctionCall `json:"function_call"`
+}
+
+type sparkFrame struct {
+	Header struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Sid     string `json:"sid"`
+		Status  int    `json:"status"`
+	} `json:"header"`
+	Payload struct {
+		Choices struct {
+			Status int               `json:"status"`
+			Seq    int               `json:"seq"`
+			Text   []*sparkFrameText `json:"text"`
+		} `json:"choices"`
+		Usage *struct {
+			Text sparkUsage `json:"text"`
+		} `json:"usage"`
+	} `json:"payload"`
+}
@@ -0,0 +1,189 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const stubApiSecret = "stub-secret"
+
+var recordedFrames = []string{
+	`{"header":{"code":0,"message":"Success","sid":"cht000b1","status":0},"payload":{"choices":{"status":0,"seq":0,"text":[{"content":"Let me check ","role":"assistant","index":0}]}}}`,
+	`{"header":{"code":0,"message":"Success","sid":"cht000b1","status":1},"payload":{"choices":{"status":1,"seq":1,"text":[{"content":"the weather.","role":"assistant","index":0}]}}}`,
+	`{"header":{"code":0,"message":"Success","sid":"cht000b1","status":2},"payload":{"choices":{"status":2,"seq":2,"text":[{"content":"","role":"assistant","index":0,"content_type":"text","function_call":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},"usage":{"text":{"question_tokens":8,"prompt_tokens":30,"completion_tokens":20,"total_tokens":50}}}}`,
+}
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "RMB"}, nil
+}
+
+// newStubServer verifies the signed url of the handshake and answers the request with the frames.
+func newStubServer(t *testing.T, frames []string, captured *map[string]interface{}) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		authorization, _ := base64.StdEncoding.DecodeString(query.Get("authorization"))
+		signature := Signature(stubApiSecret, query.Get("host"), query.Get("date"), r.URL.EscapedPath())
+
+		if !strings.Contains(string(authorization), fmt.Sprintf(`signature="%s"`, signature)) || query.Get("host") != r.Host {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path != "/v3.5/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+
+		if err != nil {
+			t.Errorf("Upgrade() error = %v", err)
+			return
+		}
+
+		defer conn.Close()
+
+		if err := conn.ReadJSON(captured); err != nil {
+			t.Errorf("request frame is not json: %s", err.Error())
+			return
+		}
+
+		for _, frame := range frames {
+			conn.WriteMessage(websocket.TextMessage, []byte(frame))
+		}
+	}))
+}
+
+func stubCredentials(server *httptest.Server, apiSecret string) map[string]interface{} {
+	return map[string]interface{}{
+		"app_id":     "stub-app",
+		"api_key":    "stub-key",
+		"api_secret": apiSecret,
+		"base_url":   "ws" + strings.TrimPrefix(server.URL, "http"),
+	}
+}
+
+func TestSparkChatStream(t *testing.T) {
+	var captured map[string]interface{}
+
+	server := newStubServer(t, recordedFrames, &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a weather bot."),
+		biz_entity_chat_prompt_message.NewUserMessage("What's the weather in Paris?"),
+	}
+
+	tools := []*biz_entity_chat_prompt_message.PromptMessageTool{
+		{
+			Name:        "get_weather",
+			Description: "Get the weather of a city",
+			Parameters: &biz_entity_chat_prompt_message.PromptMessageToolParameter{
+				Type:       "object",
+				Properties: biz_entity_chat_prompt_message.PromptMessageToolProperties{"city": {Type: "string"}},
+				Required:   []string{"city"},
+			},
+		},
+	}
+
+	queue := &fakeQueue{}
+	modelParameters := map[string]interface{}{"max_tokens": 512, "top_k": 4, "response_format": "json_object"}
+
+	NewSparkChatLargeLanguageModel(promptMessages, modelParameters, stubCredentials(server, stubApiSecret), "spark-max", "user-1", &fakeModelRuntime{}, tools).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	chat := captured["parameter"].(map[string]interface{})["chat"].(map[string]interface{})
+
+	if chat["domain"] != "generalv3.5" || chat["max_tokens"] != float64(512) || chat["response_format"] != nil {
+		t.Errorf("unexpected chat parameter %v", chat)
+	}
+
+	if header := captured["header"].(map[string]interface{}); header["app_id"] != "stub-app" || header["uid"] != "user-1" {
+		t.Errorf("unexpected header %v", header)
+	}
+
+	if functions := captured["payload"].(map[string]interface{})["functions"]; functions == nil {
+		t.Errorf("functions were not sent")
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil {
+		t.Fatalf("expected 2 agent chunks and a message end event, got %d chunks", len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Let me check the weather." || result.ID != "cht000b1" || result.Reason != biz_entity_base_stream_generator.AGENT_END {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	if len(result.Message.ToolCalls) != 1 || result.Message.ToolCalls[0].Function.Name != "get_weather" || result.Message.ToolCalls[0].Function.Arguments != `{"city":"Paris"}` {
+		t.Errorf("unexpected tool calls %+v", result.Message.ToolCalls)
+	}
+
+	if result.Usage.PromptTokens != 30 || result.Usage.CompletionTokens != 20 {
+		t.Errorf("unexpected usage %+v", result.Usage)
+	}
+}
+
+func TestSparkChatErrors(t *testing.T) {
+	var captured map[string]interface{}
+
+	server := newStubServer(t, []string{`{"header":{"code":11202,"message":"licc failed","sid":"cht000b2","status":2}}`}, &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("Hi")}
+
+	if _, err := NewSparkChatLargeLanguageModel(promptMessages, nil, stubCredentials(server, stubApiSecret), "spark-max", "", &fakeModelRuntime{}, nil).InvokeNonStream(context.Background()); !errors.IsCode(err, code.ErrModelRateLimited) {
+		t.Errorf("InvokeNonStream() error = %v, want ErrModelRateLimited", err)
+	}
+
+	if _, err := NewSparkChatLargeLanguageModel(promptMessages, nil, stubCredentials(server, "wrong-secret"), "spark-max", "", &fakeModelRuntime{}, nil).InvokeNonStream(context.Background()); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("InvokeNonStream() with a wrong secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const (
+	// DEFAULT_VALIDATION_MODEL is free of charge, the provider credentials are validated by a chat with it
+	DEFAULT_VALIDATION_MODEL = "spark-lite"
+)
+
+type sparkLargeLanguageModel struct {
+	ISparkLargeLanguage
+}
+
+func init() {
+	NewSparkLargeLanguageModel().Register()
+}
+
+func NewSparkLargeLanguageModel() *sparkLargeLanguageModel {
+	return &sparkLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*sparkLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*sparkLargeLanguageModel)(nil)
+var _ provider_register.ICredentialValidator = (*sparkLargeLanguageModel)(nil)
+var _ provider_register.IProviderCredentialValidator = (*sparkLargeLanguageModel)(nil)
+
+func (m *sparkLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.ISparkLargeLanguage = NewSparkChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, user, modelRuntime, tools)
+	m.ISparkLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *sparkLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.ISparkLargeLanguage = NewSparkChatLargeLanguageModel(promptMessages, modelParameters, credentials, model, user, modelRuntime, nil)
+	return m.ISparkLargeLanguage.InvokeNonStream(ctx)
+}
+
+// ValidateCredentials chats with the model for a token, the credentials are rejected in the handshake or the
+// header of the first frame.
+func (m *sparkLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("ping")}
+
+	_, err := NewSparkChatLargeLanguageModel(promptMessages, map[string]interface{}{"max_tokens": 1}, credentials, model, "", &freeModelRuntime{}, nil).InvokeNonStream(ctx)
+	return err
+}
+
+func (m *sparkLargeLanguageModel) ValidateProviderCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	return m.ValidateCredentials(ctx, DEFAULT_VALIDATION_MODEL, credentials)
+}
+
+func (m *sparkLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *sparkLargeLanguageModel) RegisterName() string {
+	return "spark/llm"
+}
+
+// Tokenizer of spark is not published, the tokens are estimated by the char ratio.
+func (m *sparkLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
+
+// freeModelRuntime prices the validation chat, which is not billed to any app.
+type freeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *freeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return biz_entity.NewFreePriceInfo(), nil
+}
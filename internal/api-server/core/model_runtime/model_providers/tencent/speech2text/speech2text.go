@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/tencent"
 	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
@@ -28,9 +29,21 @@ var _ provider_register.IAudioModelRegistry = (*tencentAudioLargeLanguageModel)(
 
 func (m *tencentAudioLargeLanguageModel) Invoke(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user, filename string, fileContent []byte, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.Speech2TextResp, error) {
 
-	credential := common.NewCredential(credentials["secret_id"].(string), credentials["secret_key"].(string))
+	tencentCredentials, err := tencent.CredentialsFromMap(credentials)
 
-	recognizer := asr.NewFlashRecognizer(credentials["app_id"].(string), credential)
+	if err != nil {
+		return nil, err
+	}
+
+	appID, ok := credentials["app_id"].(string)
+
+	if !ok || appID == "" {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "app_id of tencent asr is required")
+	}
+
+	credential := common.NewCredential(tencentCredentials.SecretID, tencentCredentials.SecretKey)
+
+	recognizer := asr.NewFlashRecognizer(appID, credential)
 
 	req := new(asr.FlashRecognitionRequest)
 	req.EngineType = "16k_zh"
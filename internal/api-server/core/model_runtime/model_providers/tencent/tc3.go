@@ -0,0 +1,151 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tencent
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SIGNING_ALGORITHM = "TC3-HMAC-SHA256"
+	DATE_FORMAT       = "2006-01-02"
+)
+
+// SIGNED_HEADERS are the headers covered by the signature, the action is signed so that a signed request
+// can't be replayed for another action.
+var SIGNED_HEADERS = []string{"content-type", "host", "x-tc-action"}
+
+// SignRequest signs the request with the tencent cloud api 3.0 signature TC3-HMAC-SHA256, the X-TC-Timestamp
+// and Authorization headers are set on the request.
+func SignRequest(req *http.Request, body []byte, credentials *Credentials, service string, now time.Time) {
+	now = now.UTC()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	req.Header.Set("X-TC-Timestamp", timestamp)
+
+	if credentials.Token != "" {
+		req.Header.Set("X-TC-Token", credentials.Token)
+	}
+
+	date := now.Format(DATE_FORMAT)
+	scope := fmt.Sprintf("%s/%s/tc3_request", date, service)
+	signature := Signature(credentials.SecretKey, date, service, timestamp, scope, CanonicalRequest(req, body))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", SIGNING_ALGORITHM, credentials.SecretID, scope, strings.Join(SIGNED_HEADERS, ";"), signature))
+}
+
+// Signature derives the signing key of the date and service, then signs the string to sign of the canonical
+// request.
+func Signature(secretKey, date, service, timestamp, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{SIGNING_ALGORITHM, timestamp, scope, hashHex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSHA256([]byte("TC3"+secretKey), date)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "tc3_request")
+
+	return hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+}
+
+// CanonicalRequest builds the canonical request of the signed headers, the names and values of the headers are
+// lower cased.
+func CanonicalRequest(req *http.Request, body []byte) string {
+	host := req.Host
+
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var canonicalHeaders strings.Builder
+
+	for _, name := range SIGNED_HEADERS {
+		value := req.Header.Get(name)
+
+		if name == "host" {
+			value = host
+		}
+
+		canonicalHeaders.WriteString(name + ":" + strings.ToLower(strings.TrimSpace(value)) + "\n")
+	}
+
+	canonicalURI := req.URL.EscapedPath()
+
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+
+	return strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(SIGNED_HEADERS, ";"),
+		hashHex(body),
+	}, "\n")
+}
+
+// VerifyRequest checks the signature of a request signed by SignRequest, it's the server side of the signer
+// for the stub servers of the tests.
+func VerifyRequest(req *http.Request, body []byte, secretKey string) error {
+	authorization := req.Header.Get("Authorization")
+
+	algorithm, fields, found := strings.Cut(authorization, " ")
+
+	if !found || algorithm != SIGNING_ALGORITHM {
+		return fmt.Errorf("authorization %q is not signed by %s", authorization, SIGNING_ALGORITHM)
+	}
+
+	values := make(map[string]string)
+
+	for _, field := range strings.Split(fields, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		values[key] = value
+	}
+
+	// credential is <secret id>/<date>/<service>/tc3_request
+	credential := strings.SplitN(values["Credential"], "/", 2)
+
+	if len(credential) != 2 {
+		return fmt.Errorf("credential %q of the authorization is malformed", values["Credential"])
+	}
+
+	scope := credential[1]
+	scopes := strings.Split(scope, "/")
+	timestamp := req.Header.Get("X-TC-Timestamp")
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil || len(scopes) != 3 || time.Unix(unix, 0).UTC().Format(DATE_FORMAT) != scopes[0] {
+		return fmt.Errorf("scope %q doesn't match the timestamp %q", scope, timestamp)
+	}
+
+	if values["SignedHeaders"] != strings.Join(SIGNED_HEADERS, ";") {
+		return fmt.Errorf("signed headers %q are not supported", values["SignedHeaders"])
+	}
+
+	canonicalRequest := CanonicalRequest(req, body)
+
+	if expected := Signature(secretKey, scopes[0], scopes[1], timestamp, scope, canonicalRequest); !hmac.Equal([]byte(expected), []byte(values["Signature"])) {
+		return fmt.Errorf("signature %s doesn't match the canonical request:\n%s", values["Signature"], canonicalRequest)
+	}
+
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hashHex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,146 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tencent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// Credentials of the tencent cloud api 3.0, the token is required by the temporary credentials only.
+type Credentials struct {
+	SecretID  string
+	SecretKey string
+	Token     string
+	Region    string
+}
+
+func CredentialsFromMap(credentials map[string]interface{}) (*Credentials, error) {
+	secretID, _ := credentials["secret_id"].(string)
+	secretKey, _ := credentials["secret_key"].(string)
+
+	if secretID == "" || secretKey == "" {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "secret_id and secret_key of tencent cloud are required")
+	}
+
+	token, _ := credentials["token"].(string)
+	region, _ := credentials["region"].(string)
+
+	return &Credentials{
+		SecretID:  secretID,
+		SecretKey: secretKey,
+		Token:     token,
+		Region:    region,
+	}, nil
+}
+
+// APIError is the error of the api 3.0 response, which is returned with http status 200.
+type APIError struct {
+	Code      string `json:"Code"`
+	Message   string `json:"Message"`
+	RequestID string `json:"-"`
+}
+
+// Err maps the error code so that the rate limited models go down the fallback chain.
+func (e *APIError) Err() error {
+	switch {
+	case strings.HasPrefix(e.Code, "AuthFailure"), strings.HasPrefix(e.Code, "UnauthorizedOperation"):
+		return errors.WithCode(code.ErrInvalidCredentials, "tencent cloud error %s: %s, request id %s", e.Code, e.Message, e.RequestID)
+	case strings.HasPrefix(e.Code, "RequestLimitExceeded"), strings.HasPrefix(e.Code, "LimitExceeded"):
+		return errors.WithCode(code.ErrModelRateLimited, "tencent cloud error %s: %s, request id %s", e.Code, e.Message, e.RequestID)
+	case strings.HasPrefix(e.Code, "InternalError"), e.Code == "FailedOperation.EngineServerError", e.Code == "FailedOperation.EngineRequestTimeout":
+		return errors.WithCode(code.ErrModelServiceUnavailable, "tencent cloud error %s: %s, request id %s", e.Code, e.Message, e.RequestID)
+	}
+
+	return errors.WithCode(code.ErrCallLargeLanguageModel, "tencent cloud error %s: %s, request id %s", e.Code, e.Message, e.RequestID)
+}
+
+// Invoke posts the signed action to the endpoint of the service. The json responses are buffered to check the
+// error of the response, the event streams are returned as they are.
+func Invoke(ctx context.Context, credentials *Credentials, endpointUrl, service, version, action string, requestData interface{}) (*http.Response, error) {
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", version)
+
+	if credentials.Region != "" {
+		req.Header.Set("X-TC-Region", credentials.Region)
+	}
+
+	SignRequest(req, requestBodyData, credentials, service, time.Now())
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			return nil, errors.WithCode(code.ErrModelRateLimited, "tencent cloud api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "tencent cloud api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode >= http.StatusInternalServerError:
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "tencent cloud api returned status %d: %s", response.StatusCode, string(errBody))
+		}
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "tencent cloud api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	if !strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	var errorResponse struct {
+		Response struct {
+			Error     *APIError `json:"Error"`
+			RequestID string    `json:"RequestId"`
+		} `json:"Response"`
+	}
+
+	if err := json.Unmarshal(body, &errorResponse); err == nil && errorResponse.Response.Error != nil {
+		apiErr := errorResponse.Response.Error
+		apiErr.RequestID = errorResponse.Response.RequestID
+		log.Errorf("tencent cloud action %s failed: %s %s", action, apiErr.Code, apiErr.Message)
+		return nil, apiErr.Err()
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package tencent
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+func TestSignRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "https://hunyuan.tencentcloudapi.com/", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", "ChatCompletions")
+
+	SignRequest(req, []byte(`{}`), &Credentials{SecretID: "stub-id", SecretKey: "stub-secret"}, "hunyuan", time.Date(2024, 10, 1, 8, 0, 0, 0, time.UTC))
+
+	canonicalRequest := CanonicalRequest(req, []byte(`{}`))
+	expectedCanonicalRequest := "POST\n/\n\ncontent-type:application/json; charset=utf-8\nhost:hunyuan.tencentcloudapi.com\nx-tc-action:chatcompletions\n\ncontent-type;host;x-tc-action\n44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+
+	if canonicalRequest != expectedCanonicalRequest {
+		t.Errorf("CanonicalRequest() = %q, want %q", canonicalRequest, expectedCanonicalRequest)
+	}
+
+	if authorization := req.Header.Get("Authorization"); !strings.HasPrefix(authorization, "TC3-HMAC-SHA256 Credential=stub-id/2024-10-01/hunyuan/tc3_request, SignedHeaders=content-type;host;x-tc-action, Signature=") || req.Header.Get("X-TC-Timestamp") != "1727769600" {
+		t.Errorf("unexpected authorization %s", authorization)
+	}
+
+	if err := VerifyRequest(req, []byte(`{}`), "stub-secret"); err != nil {
+		t.Errorf("VerifyRequest() error = %v", err)
+	}
+
+	if err := VerifyRequest(req, []byte(`{"Model":"hunyuan-pro"}`), "stub-secret"); err == nil {
+		t.Errorf("VerifyRequest() of a tampered body succeeded")
+	}
+}
+
+func TestInvokeErrors(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+
+		if err := VerifyRequest(r, body, "stub-secret"); err != nil {
+			io.WriteString(w, `{"Response":{"Error":{"Code":"AuthFailure.SignatureFailure","Message":"The provided credentials could not be validated."},"RequestId":"request-1"}}`)
+			return
+		}
+
+		if r.Header.Get("X-TC-Version") != "2023-09-01" || r.Header.Get("X-TC-Region") != "ap-guangzhou" {
+			t.Errorf("unexpected version %s or region %s", r.Header.Get("X-TC-Version"), r.Header.Get("X-TC-Region"))
+		}
+
+		io.WriteString(w, `{"Response":{"Error":{"Code":"LimitExceeded","Message":"Concurrency limit exceeded."},"RequestId":"request-2"}}`)
+	}))
+	defer server.Close()
+
+	credentials := &Credentials{SecretID: "stub-id", SecretKey: "stub-secret", Region: "ap-guangzhou"}
+
+	if _, err := Invoke(context.Background(), credentials, server.URL, "hunyuan", "2023-09-01", "ChatCompletions", map[string]interface{}{}); !errors.IsCode(err, code.ErrModelRateLimited) {
+		t.Errorf("Invoke() error = %v, want ErrModelRateLimited", err)
+	}
+
+	credentials.SecretKey = "wrong-secret"
+
+	if _, err := Invoke(context.Background(), credentials, server.URL, "hunyuan", "2023-09-01", "ChatCompletions", map[string]interface{}{}); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("Invoke() with a wrong secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
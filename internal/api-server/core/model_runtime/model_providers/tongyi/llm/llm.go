@@ -27,6 +27,7 @@ func NewTongyiLargeLanguageModel() *tongyiLargeLanguageModel {
 }
 
 var _ provider_register.IModelRegistry = (*tongyiLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*tongyiLargeLanguageModel)(nil)
 
 func (m *tongyiLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
 	credentials = m.addCustomParameters(credentials)
@@ -48,6 +49,11 @@ func (m *tongyiLargeLanguageModel) RegisterName() string {
 	return "tongyi/llm"
 }
 
+// Tokenizer of qwen is not bundled, the tokens are estimated by the char ratio.
+func (m *tongyiLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
+
 func (m *tongyiLargeLanguageModel) addCustomParameters(credentials map[string]interface{}) map[string]interface{} {
 	credentials["mode"] = "chat"
 	credentials["endpoint_url"] = "https://dashscope.aliyuncs.com/compatible-mode/v1"
@@ -0,0 +1,248 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package wenxin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/redis"
+	go_redis "github.com/redis/go-redis/v9"
+)
+
+const (
+	ACCESS_TOKEN_KEY = "wenxin_access_token"
+	// ACCESS_TOKEN_REFRESH_AHEAD is how long before the expiry the cached access token is dropped, so that the
+	// token is refreshed before the requests are rejected
+	ACCESS_TOKEN_REFRESH_AHEAD = 24 * time.Hour
+)
+
+// ITokenStore caches the access tokens, the tokens are stored in redis so that the api servers share a token of
+// the credentials, and in memory when redis is not initialized.
+type ITokenStore interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, token string, expiration time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+var (
+	// TokenStore is resolved on the first use because the model runtimes are registered before redis is initialized
+	TokenStore ITokenStore
+	storeOnce  sync.Once
+	// refreshMu serializes the token exchanges of an api server, the api key and secret key are exchanged once
+	// even if the cached token expires under concurrent requests
+	refreshMu sync.Mutex
+)
+
+func tokenStore() ITokenStore {
+	storeOnce.Do(func() {
+		if TokenStore != nil {
+			return
+		}
+
+		redisIns, err := redis.GetRedisIns(nil)
+
+		if err != nil {
+			log.Warnf("wenxin access tokens are cached in memory, redis is not available: %s", err.Error())
+			TokenStore = NewMemoryTokenStore()
+			return
+		}
+
+		TokenStore = &redisTokenStore{redis: redisIns}
+	})
+
+	return TokenStore
+}
+
+// AccessToken returns the cached access token of the credentials, a new token is exchanged when the cached one
+// is absent or refresh is true, e.g. the cached one is rejected as expired.
+func AccessToken(ctx context.Context, credentials *Credentials, refresh bool) (string, error) {
+	store := tokenStore()
+	key := accessTokenKey(credentials)
+
+	if !refresh {
+		if token, err := store.Get(ctx, key); err != nil {
+			log.Errorf("occurred error when get wenxin access token from cache: %s", err.Error())
+		} else if token != "" {
+			return token, nil
+		}
+	}
+
+	refreshMu.Lock()
+	defer refreshMu.Unlock()
+
+	// another request may have exchanged the token while waiting for the lock
+	if !refresh {
+		if token, err := store.Get(ctx, key); err == nil && token != "" {
+			return token, nil
+		}
+	}
+
+	token, expiresIn, err := exchangeAccessToken(ctx, credentials)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := store.Set(ctx, key, token, tokenExpiration(expiresIn)); err != nil {
+		log.Errorf("occurred error when cache wenxin access token: %s", err.Error())
+	}
+
+	return token, nil
+}
+
+// InvalidateAccessToken drops the cached access token of the credentials.
+func InvalidateAccessToken(ctx context.Context, credentials *Credentials) {
+	if err := tokenStore().Del(ctx, accessTokenKey(credentials)); err != nil {
+		log.Errorf("occurred error when invalidate wenxin access token: %s", err.Error())
+	}
+}
+
+// accessTokenKey hashes the credentials, the secret key shouldn't be readable from the cache keys.
+func accessTokenKey(credentials *Credentials) string {
+	sum := sha256.Sum256([]byte(credentials.BaseUrl + ":" + credentials.APIKey + ":" + credentials.SecretKey))
+	return fmt.Sprintf("%s:%s", ACCESS_TOKEN_KEY, hex.EncodeToString(sum[:]))
+}
+
+func tokenExpiration(expiresIn int64) time.Duration {
+	expiration := time.Duration(expiresIn) * time.Second
+
+	if expiration > 2*ACCESS_TOKEN_REFRESH_AHEAD {
+		return expiration - ACCESS_TOKEN_REFRESH_AHEAD
+	}
+
+	if expiration <= 0 {
+		return time.Minute
+	}
+
+	return expiration / 2
+}
+
+type accessTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	ExpiresIn        int64  `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func exchangeAccessToken(ctx context.Context, credentials *Credentials) (string, int64, error) {
+	endpointUrl, err := url.JoinPath(credentials.BaseUrl, "oauth/2.0/token")
+
+	if err != nil {
+		return "", 0, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	query := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {credentials.APIKey},
+		"client_secret": {credentials.SecretKey},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl+"?"+query.Encode(), nil)
+
+	if err != nil {
+		return "", 0, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(10) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return "", 0, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	var tokenResponse accessTokenResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&tokenResponse); err != nil {
+		return "", 0, errors.WithCode(code.ErrModelServiceUnavailable, "wenxin token api returned status %d: %s", response.StatusCode, err.Error())
+	}
+
+	if tokenResponse.Error != "" || tokenResponse.AccessToken == "" {
+		return "", 0, errors.WithCode(code.ErrInvalidCredentials, "wenxin access token could not be exchanged: %s %s", tokenResponse.Error, tokenResponse.ErrorDescription)
+	}
+
+	return tokenResponse.AccessToken, tokenResponse.ExpiresIn, nil
+}
+
+type redisTokenStore struct {
+	redis *go_redis.Client
+}
+
+func (s *redisTokenStore) Get(ctx context.Context, key string) (string, error) {
+	token, err := s.redis.Get(ctx, key).Result()
+
+	if err == go_redis.Nil {
+		return "", nil
+	}
+
+	return token, err
+}
+
+func (s *redisTokenStore) Set(ctx context.Context, key string, token string, expiration time.Duration) error {
+	return s.redis.Set(ctx, key, token, expiration).Err()
+}
+
+func (s *redisTokenStore) Del(ctx context.Context, key string) error {
+	return s.redis.Del(ctx, key).Err()
+}
+
+type memoryToken struct {
+	token     string
+	expiredAt time.Time
+}
+
+type memoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*memoryToken
+}
+
+func NewMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{tokens: make(map[string]*memoryToken)}
+}
+
+func (s *memoryTokenStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[key]
+
+	if !ok || time.Now().After(token.expiredAt) {
+		delete(s.tokens, key)
+		return "", nil
+	}
+
+	return token.token, nil
+}
+
+func (s *memoryTokenStore) Set(ctx context.Context, key string, token string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = &memoryToken{token: token, expiredAt: time.Now().Add(expiration)}
+	return nil
+}
+
+func (s *memoryTokenStore) Del(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.tokens, key)
+	return nil
+}
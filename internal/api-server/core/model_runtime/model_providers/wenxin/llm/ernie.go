@@ -0,0 +1,430 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/shopspring/decimal"
+)
+
+// chatEndpoints maps the models to the chat endpoints of the workshop, several models share an endpoint.
+var chatEndpoints = map[string]string{
+	"ernie-bot":                  "completions",
+	"ernie-bot-8k":               "ernie_bot_8k",
+	"ernie-bot-4":                "completions_pro",
+	"ernie-bot-turbo":            "eb-instant",
+	"ernie-3.5-8k":               "completions",
+	"ernie-3.5-8k-0205":          "ernie-3.5-8k-0205",
+	"ernie-3.5-8k-1222":          "ernie-3.5-8k-1222",
+	"ernie-3.5-4k-0205":          "ernie-3.5-4k-0205",
+	"ernie-3.5-128k":             "ernie-3.5-128k",
+	"ernie-4.0-8k":               "completions_pro",
+	"ernie-4.0-8k-latest":        "completions_pro",
+	"ernie-4.0-turbo-8k":         "ernie-4.0-turbo-8k",
+	"ernie-4.0-turbo-8k-preview": "ernie-4.0-turbo-8k-preview",
+	"ernie-speed-8k":             "ernie_speed",
+	"ernie-speed-128k":           "ernie-speed-128k",
+	"ernie-speed-appbuilder":     "ai_apaas",
+	"ernie-lite-8k-0922":         "eb-instant",
+	"ernie-lite-8k-0308":         "ernie-lite-8k",
+	"ernie-character-8k":         "ernie-char-8k",
+	"ernie-character-8k-0321":    "ernie-char-8k",
+	"yi_34b_chat":                "yi_34b_chat",
+}
+
+// parameterNames maps the model parameters to the fields of the chat api, the parameters absent here such as
+// frequency_penalty are not accepted by ernie and dropped.
+var parameterNames = map[string]string{
+	"temperature":      "temperature",
+	"top_p":            "top_p",
+	"presence_penalty": "penalty_score",
+	"max_tokens":       "max_output_tokens",
+	"disable_search":   "disable_search",
+	"response_format":  "response_format",
+}
+
+type IWenxinLargeLanguage interface {
+	Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue)
+	InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error)
+}
+
+type ernieLargeLanguageModel struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	biz_entity.IAIModelRuntime
+	FullAssistantContent string
+	ChunkIndex           int
+	Model                string
+	User                 string
+	Stop                 []string
+	Credentials          map[string]interface{}
+	PromptMessages       []biz_entity_chat_prompt_message.IPromptMessage
+	ModelParameters      map[string]interface{}
+}
+
+func NewErnieLargeLanguageModel(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, credentials map[string]interface{}, model string, stop []string, user string, modelRuntime biz_entity.IAIModelRuntime) *ernieLargeLanguageModel {
+	return &ernieLargeLanguageModel{
+		PromptMessages:  promptMessages,
+		Credentials:     credentials,
+		ModelParameters: modelParameters,
+		Model:           model,
+		Stop:            stop,
+		User:            user,
+		IAIModelRuntime: modelRuntime,
+	}
+}
+
+func (m *ernieLargeLanguageModel) Invoke(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue) {
+	m.IStreamGenerateQueue = queue
+	m.generate(ctx)
+}
+
+func (m *ernieLargeLanguageModel) InvokeNonStream(ctx context.Context) (*biz_entity_base_stream_generator.LLMResult, error) {
+	response, err := m.doRequest(ctx, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+	return m.handleNoStreamResponse(response)
+}
+
+func (m *ernieLargeLanguageModel) generate(ctx context.Context) {
+	response, err := m.doRequest(ctx, true)
+
+	if err != nil {
+		m.PushErr(err)
+		return
+	}
+
+	defer response.Body.Close()
+	m.handleStreamResponse(ctx, response)
+}
+
+func (m *ernieLargeLanguageModel) doRequest(ctx context.Context, stream bool) (*http.Response, error) {
+	credentials, err := wenxin.CredentialsFromMap(m.Credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := chatEndpoints[m.Model]
+
+	if !ok {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin model %s is not supported", m.Model)
+	}
+
+	requestData, err := m.buildRequestData(stream)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("Invoke wenxin llm request body %+v", requestData)
+
+	return wenxin.Invoke(ctx, credentials, "chat/"+endpoint, requestData)
+}
+
+func (m *ernieLargeLanguageModel) buildRequestData(stream bool) (map[string]interface{}, error) {
+	requestData := map[string]interface{}{
+		"stream": stream,
+	}
+
+	for k, v := range m.ModelParameters {
+		if name, ok := parameterNames[k]; ok {
+			requestData[name] = v
+		}
+	}
+
+	system, messages, err := m.convertPromptMessages()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if system != "" {
+		requestData["system"] = system
+	}
+
+	requestData["messages"] = messages
+
+	if len(m.Stop) > 0 {
+		requestData["stop"] = m.Stop
+	}
+
+	if m.User != "" {
+		requestData["user_id"] = m.User
+	}
+
+	return requestData, nil
+}
+
+// convertPromptMessages extracts system prompts and merges the adjacent messages with the same role, ernie
+// requires an odd number of messages alternating between user and assistant.
+func (m *ernieLargeLanguageModel) convertPromptMessages() (string, []*ernieMessage, error) {
+	var (
+		systems  []string
+		messages []*ernieMessage
+	)
+
+	appendMessage := func(role string, content string) {
+		if content == "" {
+			return
+		}
+		if len(messages) > 0 && messages[len(messages)-1].Role == role {
+			messages[len(messages)-1].Content += "\n" + content
+			return
+		}
+		messages = append(messages, &ernieMessage{Role: role, Content: content})
+	}
+
+	for _, promptMessage := range m.PromptMessages {
+		switch message := promptMessage.(type) {
+		case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+			content, _ := message.Content.(string)
+			appendMessage("assistant", content)
+		case *biz_entity_chat_prompt_message.PromptMessage:
+			switch message.Role {
+			case biz_entity_chat_prompt_message.SYSTEM:
+				if content, ok := message.Content.(string); ok && content != "" {
+					systems = append(systems, content)
+				}
+			case biz_entity_chat_prompt_message.ASSISTANT:
+				content, _ := message.Content.(string)
+				appendMessage("assistant", content)
+			case biz_entity_chat_prompt_message.USER:
+				content, err := convertUserContent(message.Content)
+				if err != nil {
+					return "", nil, err
+				}
+				appendMessage("user", content)
+			}
+		default:
+			return "", nil, errors.WithCode(code.ErrTypeOfPromptMessage, "prompt message type %T is not supported by wenxin", promptMessage)
+		}
+	}
+
+	if len(messages) > 0 && messages[0].Role == "assistant" {
+		messages = messages[1:]
+	}
+
+	return strings.Join(systems, "\n"), messages, nil
+}
+
+// convertUserContent joins the text contents, ernie chat models accept plain text only.
+func convertUserContent(content any) (string, error) {
+	switch content := content.(type) {
+	case string:
+		return content, nil
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var texts []string
+		for _, messageContent := range content {
+			if data, ok := messageContent.Data.(string); ok && messageContent.Type == biz_entity_chat_prompt_message.TEXT {
+				texts = append(texts, data)
+			}
+		}
+		return strings.Join(texts, "\n"), nil
+	default:
+		return "", errors.WithCode(code.ErrTypeOfPromptMessage, "value %T is not string or []*promptMessageContent type", content)
+	}
+}
+
+func (m *ernieLargeLanguageModel) handleNoStreamResponse(response *http.Response) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var responseJSON ernieResponse
+
+	if err := json.NewDecoder(response.Body).Decode(&responseJSON); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	llmUsage, err := m.calcResponseUsage(responseJSON.Usage.PromptTokens, responseJSON.Usage.CompletionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		ID:            responseJSON.ID,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Message:       biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(responseJSON.Result),
+		Usage:         llmUsage,
+		Reason:        responseJSON.FinishReason,
+	}, nil
+}
+
+func (m *ernieLargeLanguageModel) handleStreamResponse(ctx context.Context, response *http.Response) {
+	var (
+		messageID    string
+		finishReason string
+		usage        ernieUsage
+	)
+
+	start := time.Now()
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+
+		chunk := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+
+		var event ernieResponse
+
+		if err := json.Unmarshal([]byte(chunk), &event); err != nil {
+			m.sendErrorChunkToQueue(ctx, errors.WithCode(code.ErrDecodingJSON, "JSON data %+v could not be decoded, failed: %+v", chunk, err.Error()))
+			return
+		}
+
+		// the errors raised after the stream is started are sent as a data line
+		if event.ErrorCode != 0 {
+			apiErr := &wenxin.APIError{ErrorCode: event.ErrorCode, ErrorMsg: event.ErrorMsg}
+			m.sendErrorChunkToQueue(ctx, apiErr.Err())
+			return
+		}
+
+		messageID = event.ID
+
+		if event.Result != "" {
+			m.ChunkIndex += 1
+			m.FullAssistantContent += event.Result
+			m.sendStreamChunkToQueue(ctx, messageID, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(event.Result))
+		}
+
+		// the usage of each chunk is accumulated up to the chunk, the last one covers the whole response
+		if event.Usage.TotalTokens > 0 {
+			usage = event.Usage
+		}
+
+		if event.IsEnd {
+			finishReason = event.FinishReason
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		m.sendErrorChunkToQueue(ctx, errors.WithSCode(code.ErrRunTimeCaller, err.Error()))
+		return
+	}
+
+	llmUsage, err := m.calcResponseUsage(usage.PromptTokens, usage.CompletionTokens)
+
+	if err != nil {
+		m.sendErrorChunkToQueue(ctx, err)
+		return
+	}
+
+	llmUsage.Latency = time.Since(start).Seconds()
+
+	m.sendStreamFinalChunkToQueue(ctx, messageID, finishReason, biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(m.FullAssistantContent), llmUsage)
+}
+
+func (m *ernieLargeLanguageModel) calcResponseUsage(promptTokens, completionTokens int64) (*biz_entity_base_stream_generator.LLMUsage, error) {
+	promptPriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.INPUT, promptTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	completePriceInfo, err := m.GetPrice(m.Model, m.Credentials, biz_entity.OUTPUT, completionTokens)
+
+	if err != nil {
+		return nil, err
+	}
+
+	promptTotal := decimal.NewFromFloat(promptPriceInfo.TotalAmount)
+	completeTotal := decimal.NewFromFloat(completePriceInfo.TotalAmount)
+
+	return &biz_entity_base_stream_generator.LLMUsage{
+		PromptTokens:        promptTokens,
+		PromptUnitPrice:     promptPriceInfo.UnitPrice,
+		PromptPriceUnit:     promptPriceInfo.Unit,
+		PromptPrice:         promptPriceInfo.TotalAmount,
+		CompletionTokens:    completionTokens,
+		CompletionUnitPrice: completePriceInfo.UnitPrice,
+		CompletionPriceUnit: completePriceInfo.Unit,
+		CompletionPrice:     completePriceInfo.TotalAmount,
+		Currency:            promptPriceInfo.Currency,
+		Latency:             1.0,
+		TotalTokens:         promptTokens + completionTokens,
+		TotalPrice:          promptTotal.Add(completeTotal).InexactFloat64(),
+	}, nil
+}
+
+func (m *ernieLargeLanguageModel) sendStreamChunkToQueue(_ context.Context, messageId string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage) {
+	streamResultChunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   m.ChunkIndex,
+			Message: assistantPromptMessage,
+		},
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk)
+	m.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+		AppQueueEvent: event,
+		Chunk:         streamResultChunk})
+}
+
+func (m *ernieLargeLanguageModel) sendStreamFinalChunkToQueue(_ context.Context, messageId string, finishReason string, assistantPromptMessage *biz_entity_chat_prompt_message.AssistantPromptMessage, llmUsage *biz_entity_base_stream_generator.LLMUsage) {
+	llmResult := &biz_entity_base_stream_generator.LLMResult{
+		ID:            messageId,
+		Model:         m.Model,
+		PromptMessage: m.PromptMessages,
+		Reason:        finishReason,
+		Message:       assistantPromptMessage,
+		Usage:         llmUsage,
+	}
+
+	event := biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd)
+
+	m.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: event,
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *ernieLargeLanguageModel) sendErrorChunkToQueue(_ context.Context, err error) {
+	m.PushErr(err)
+}
+
+type ernieMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ernieUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+type ernieResponse struct {
+	ID           string     `json:"id"`
+	Result       string     `json:"result"`
+	IsEnd        bool       `json:"is_end"`
+	FinishReason string     `json:"finish_reason"`
+	Usage        ernieUsage `json:"usage"`
+	ErrorCode    int        `json:"error_code"`
+	ErrorMsg     string     `json:"error_msg"`
+}
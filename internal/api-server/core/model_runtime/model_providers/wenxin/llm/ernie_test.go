@@ -0,0 +1,145 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const recordedStream = `data: {"id":"as-1","object":"chat.completion","result":"Hello","is_end":false,"usage":{"prompt_tokens":12,"completion_tokens":0,"total_tokens":12}}
+
+data: {"id":"as-1","object":"chat.completion","result":" there!","is_end":true,"finish_reason":"normal","usage":{"prompt_tokens":12,"completion_tokens":4,"total_tokens":16}}
+
+`
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+type fakeModelRuntime struct {
+	biz_entity.IAIModelRuntime
+}
+
+func (r *fakeModelRuntime) GetPrice(model string, credentials any, priceType biz_entity.PriceType, tokens int64) (*biz_entity.PriceInfo, error) {
+	return &biz_entity.PriceInfo{UnitPrice: 0.001, Unit: 0.001, TotalAmount: float64(tokens) * 0.000001, Currency: "RMB"}, nil
+}
+
+func newStubServer(t *testing.T, contentType string, response string, captured *map[string]interface{}) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+	wenxin.TokenStore = wenxin.NewMemoryTokenStore()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/2.0/token" {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"access_token":"stub-token","expires_in":2592000}`)
+			return
+		}
+
+		if r.URL.Path != "/"+wenxin.WORKSHOP_PATH+"/chat/completions_pro" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		requestBody, _ := io.ReadAll(r.Body)
+
+		if err := json.Unmarshal(requestBody, captured); err != nil {
+			t.Errorf("request body is not json: %s", err.Error())
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, response)
+	}))
+}
+
+func stubCredentials(server *httptest.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"api_key":    "stub-key",
+		"secret_key": "stub-secret",
+		"base_url":   server.URL,
+	}
+}
+
+func TestErnieStream(t *testing.T) {
+	var captured map[string]interface{}
+
+	server := newStubServer(t, "text/event-stream", recordedStream, &captured)
+	defer server.Close()
+
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a greeter."),
+		biz_entity_chat_prompt_message.NewUserMessage("Hi"),
+		biz_entity_chat_prompt_message.NewUserMessage("Who are you?"),
+	}
+
+	queue := &fakeQueue{}
+	modelParameters := map[string]interface{}{"max_tokens": 512, "presence_penalty": 1.2, "frequency_penalty": 0.5}
+
+	NewErnieLargeLanguageModel(promptMessages, modelParameters, stubCredentials(server), "ernie-4.0-8k", nil, "user-1", &fakeModelRuntime{}).Invoke(context.Background(), queue)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	if captured["system"] != "You are a greeter." || captured["max_output_tokens"] != float64(512) || captured["penalty_score"] != 1.2 || captured["frequency_penalty"] != nil || captured["user_id"] != "user-1" {
+		t.Errorf("unexpected request %v", captured)
+	}
+
+	if messages, _ := captured["messages"].([]interface{}); len(messages) != 1 || messages[0].(map[string]interface{})["content"] != "Hi\nWho are you?" {
+		t.Errorf("adjacent user messages were not merged, got %v", captured["messages"])
+	}
+
+	if len(queue.chunks) != 2 || queue.final == nil {
+		t.Fatalf("expected 2 chunks and a message end event, got %d chunks", len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Message.Content != "Hello there!" || result.ID != "as-1" || result.Reason != "normal" || result.Usage.PromptTokens != 12 || result.Usage.CompletionTokens != 4 {
+		t.Errorf("unexpected result %+v %+v", result.Message, result.Usage)
+	}
+}
+
+func TestErnieStreamError(t *testing.T) {
+	var captured map[string]interface{}
+
+	server := newStubServer(t, "application/json", `{"error_code":18,"error_msg":"Open api qps request limit reached"}`, &captured)
+	defer server.Close()
+
+	queue := &fakeQueue{}
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("Hi")}
+
+	NewErnieLargeLanguageModel(promptMessages, nil, stubCredentials(server), "ernie-4.0-8k", nil, "", &fakeModelRuntime{}).Invoke(context.Background(), queue)
+
+	if !errors.IsCode(queue.err, code.ErrModelRateLimited) {
+		t.Errorf("error = %v, want ErrModelRateLimited", queue.err)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package llm
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin"
+	provider_register "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+type wenxinLargeLanguageModel struct {
+	IWenxinLargeLanguage
+}
+
+func init() {
+	NewWenxinLargeLanguageModel().Register()
+}
+
+func NewWenxinLargeLanguageModel() *wenxinLargeLanguageModel {
+	return &wenxinLargeLanguageModel{}
+}
+
+var _ provider_register.IModelRegistry = (*wenxinLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*wenxinLargeLanguageModel)(nil)
+var _ provider_register.ICredentialValidator = (*wenxinLargeLanguageModel)(nil)
+var _ provider_register.IProviderCredentialValidator = (*wenxinLargeLanguageModel)(nil)
+
+func (m *wenxinLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.IWenxinLargeLanguage = NewErnieLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime)
+	m.IWenxinLargeLanguage.Invoke(ctx, queueManager)
+}
+
+func (m *wenxinLargeLanguageModel) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.IWenxinLargeLanguage = NewErnieLargeLanguageModel(promptMessages, modelParameters, credentials, model, stop, user, modelRuntime)
+	return m.IWenxinLargeLanguage.InvokeNonStream(ctx)
+}
+
+func (m *wenxinLargeLanguageModel) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	return wenxin.ValidateCredentials(ctx, credentials)
+}
+
+func (m *wenxinLargeLanguageModel) ValidateProviderCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	return wenxin.ValidateCredentials(ctx, credentials)
+}
+
+func (m *wenxinLargeLanguageModel) Register() {
+	provider_register.ModelRuntimeRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *wenxinLargeLanguageModel) RegisterName() string {
+	return "wenxin/llm"
+}
+
+// Tokenizer of ernie is not published, the tokens are estimated by the char ratio.
+func (m *wenxinLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
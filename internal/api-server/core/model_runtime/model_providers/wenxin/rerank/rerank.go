@@ -0,0 +1,114 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// rerankEndpoints maps the models to the reranker endpoints of the workshop.
+var rerankEndpoints = map[string]string{
+	"bce-reranker-base_v1": "bce_reranker_base",
+}
+
+type wenxinRerank struct{}
+
+func init() {
+	NewWenxinRerank().Register()
+}
+
+func NewWenxinRerank() *wenxinRerank {
+	return &wenxinRerank{}
+}
+
+var _ model_registry.IRerankRegistry = (*wenxinRerank)(nil)
+
+func (m *wenxinRerank) RegisterName() string {
+	return "wenxin/rerank"
+}
+
+func (m *wenxinRerank) Register() {
+	model_registry.RerankRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *wenxinRerank) Invoke(ctx context.Context, model string, credentials map[string]interface{}, query string, docs []string, scoreThreshold float64, topN int, user string, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.RerankResult, error) {
+	if len(docs) == 0 {
+		return &biz_entity_openai_standard_response.RerankResult{Model: model}, nil
+	}
+
+	wenxinCredentials, err := wenxin.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, ok := rerankEndpoints[model]
+
+	if !ok {
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin rerank model %s is not supported", model)
+	}
+
+	if topN <= 0 || topN > len(docs) {
+		topN = len(docs)
+	}
+
+	requestData := map[string]interface{}{
+		"query":     query,
+		"documents": docs,
+		"top_n":     topN,
+	}
+
+	if user != "" {
+		requestData["user_id"] = user
+	}
+
+	log.Infof("Invoke wenxin rerank request model %s, query %s, %d documents", model, query, len(docs))
+
+	response, err := wenxin.Invoke(ctx, wenxinCredentials, "reranker/"+endpoint, requestData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	var rerankResult biz_entity_openai_standard_response.RerankLargeModelResult
+
+	if err := json.NewDecoder(response.Body).Decode(&rerankResult); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	rerankDocs := make([]*biz_entity_openai_standard_response.RerankDocument, 0, len(rerankResult.Results))
+
+	for _, result := range rerankResult.Results {
+		if result.Index < 0 || result.Index >= len(docs) {
+			continue
+		}
+
+		if result.RelevanceScore < scoreThreshold {
+			continue
+		}
+
+		rerankDocs = append(rerankDocs, &biz_entity_openai_standard_response.RerankDocument{
+			Index: result.Index,
+			Text:  docs[result.Index],
+			Score: result.RelevanceScore,
+		})
+	}
+
+	return &biz_entity_openai_standard_response.RerankResult{
+		Model: model,
+		Docs:  rerankDocs,
+	}, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package text_embedding
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/wenxin"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	// MAX_BATCH is the max number of texts of an embeddings request
+	MAX_BATCH = 16
+)
+
+// embeddingEndpoints maps the models to the embeddings endpoints of the workshop.
+var embeddingEndpoints = map[string]string{
+	"embedding-v1": "embedding-v1",
+	"bge-large-zh": "bge_large_zh",
+	"bge-large-en": "bge_large_en",
+	"tao-8k":       "tao_8k",
+}
+
+type wenxinTextEmbedding struct{}
+
+func init() {
+	NewWenxinTextEmbedding().Register()
+}
+
+func NewWenxinTextEmbedding() *wenxinTextEmbedding {
+	return &wenxinTextEmbedding{}
+}
+
+var _ model_registry.ITextEmbeddingRegistry = (*wenxinTextEmbedding)(nil)
+var _ model_registry.ICredentialValidator = (*wenxinTextEmbedding)(nil)
+
+func (m *wenxinTextEmbedding) RegisterName() string {
+	return "wenxin/text-embedding"
+}
+
+func (m *wenxinTextEmbedding) Register() {
+	model_registry.TextEmbeddingRegistry.RegisterLargeModelInstance(m)
+}
+
+func (m *wenxinTextEmbedding) ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error {
+	wenxinCredentials, err := wenxin.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return err
+	}
+
+	_, _, err = m.embed(ctx, wenxinCredentials, model, "", []string{"ping"})
+	return err
+}
+
+func (m *wenxinTextEmbedding) Embedding(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, user string, modelRuntime biz_entity.IAIModelRuntime, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error) {
+	wenxinCredentials, err := wenxin.CredentialsFromMap(credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	embeddings, tokens, err := m.embed(ctx, wenxinCredentials, model, user, texts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &biz_entity_openai_standard_response.TextEmbeddingResult{
+		Model:      model,
+		Embeddings: embeddings,
+		Usage:      m.calcResponseUsage(model, credentials, modelRuntime, tokens, time.Since(start).Seconds()),
+	}, nil
+}
+
+// embed posts the texts in batches, the embeddings of a batch are ordered by the index of the response.
+func (m *wenxinTextEmbedding) embed(ctx context.Context, wenxinCredentials *wenxin.Credentials, model, user string, texts []string) ([][]float32, int, error) {
+	endpoint, ok := embeddingEndpoints[model]
+
+	if !ok {
+		return nil, 0, errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin text embedding model %s is not supported", model)
+	}
+
+	var (
+		embeddings = make([][]float32, 0, len(texts))
+		tokens     int
+	)
+
+	for i := 0; i < len(texts); i += MAX_BATCH {
+		batch := texts[i:min(i+MAX_BATCH, len(texts))]
+
+		requestData := map[string]interface{}{
+			"input": batch,
+		}
+
+		if user != "" {
+			requestData["user_id"] = user
+		}
+
+		response, err := wenxin.Invoke(ctx, wenxinCredentials, "embeddings/"+endpoint, requestData)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var embeddingResponse wenxinEmbeddingResponse
+
+		err = json.NewDecoder(response.Body).Decode(&embeddingResponse)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, 0, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+		}
+
+		if len(embeddingResponse.Data) != len(batch) {
+			return nil, 0, errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin returned %d embeddings for %d texts", len(embeddingResponse.Data), len(batch))
+		}
+
+		sort.Slice(embeddingResponse.Data, func(i, j int) bool {
+			return embeddingResponse.Data[i].Index < embeddingResponse.Data[j].Index
+		})
+
+		for _, data := range embeddingResponse.Data {
+			embeddings = append(embeddings, data.Embedding)
+		}
+
+		tokens += embeddingResponse.Usage.TotalTokens
+	}
+
+	return embeddings, tokens, nil
+}
+
+func (m *wenxinTextEmbedding) calcResponseUsage(model string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime, tokens int, latency float64) *biz_entity_openai_standard_response.EmbeddingUsage {
+	priceInfo, err := modelRuntime.GetPrice(model, credentials, biz_entity.INPUT, int64(tokens))
+
+	if err != nil {
+		priceInfo = biz_entity.NewFreePriceInfo()
+	}
+
+	return &biz_entity_openai_standard_response.EmbeddingUsage{
+		Tokens:      tokens,
+		TotalTokens: tokens,
+		UnitPrice:   priceInfo.UnitPrice,
+		PriceUnit:   priceInfo.Unit,
+		TotalPrice:  priceInfo.TotalAmount,
+		Currency:    priceInfo.Currency,
+		Latency:     latency,
+	}
+}
+
+type wenxinEmbeddingData struct {
+	Embedding []float32 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+type wenxinEmbeddingResponse struct {
+	ID    string                 `json:"id"`
+	Data  []*wenxinEmbeddingData `json:"data"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+		TotalTokens  int `json:"total_tokens"`
+	} `json:"usage"`
+}
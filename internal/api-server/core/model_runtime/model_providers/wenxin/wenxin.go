@@ -0,0 +1,203 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package wenxin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	DEFAULT_BASE_URL = "https://aip.baidubce.com"
+	// WORKSHOP_PATH is the prefix of the chat, embeddings and reranker apis of the qianfan model services
+	WORKSHOP_PATH = "rpc/2.0/ai_custom/v1/wenxinworkshop"
+)
+
+// the error codes of the qianfan apis, which are returned with http status 200
+const (
+	ERROR_CODE_QPS_LIMIT            = 4
+	ERROR_CODE_NO_PERMISSION        = 6
+	ERROR_CODE_API_KEY_INVALID      = 13
+	ERROR_CODE_API_KEY_FAILED       = 14
+	ERROR_CODE_DAILY_LIMIT          = 17
+	ERROR_CODE_QPS_LIMIT_REACHED    = 18
+	ERROR_CODE_TOTAL_LIMIT          = 19
+	ERROR_CODE_ACCESS_TOKEN_INVALID = 110
+	ERROR_CODE_ACCESS_TOKEN_EXPIRED = 111
+	ERROR_CODE_INTERNAL             = 336000
+	ERROR_CODE_RPM_LIMIT            = 336501
+	ERROR_CODE_TPM_LIMIT            = 336502
+)
+
+// APIError is the error body of the qianfan apis.
+type APIError struct {
+	ErrorCode int    `json:"error_code"`
+	ErrorMsg  string `json:"error_msg"`
+}
+
+// TokenExpired reports whether the access token of the request is rejected, the request is retried with a new token.
+func (e *APIError) TokenExpired() bool {
+	return e.ErrorCode == ERROR_CODE_ACCESS_TOKEN_INVALID || e.ErrorCode == ERROR_CODE_ACCESS_TOKEN_EXPIRED
+}
+
+// Err maps the error code so that the rate limited models go down the fallback chain.
+func (e *APIError) Err() error {
+	switch e.ErrorCode {
+	case ERROR_CODE_QPS_LIMIT, ERROR_CODE_DAILY_LIMIT, ERROR_CODE_QPS_LIMIT_REACHED, ERROR_CODE_TOTAL_LIMIT, ERROR_CODE_RPM_LIMIT, ERROR_CODE_TPM_LIMIT:
+		return errors.WithCode(code.ErrModelRateLimited, "wenxin error %d: %s", e.ErrorCode, e.ErrorMsg)
+	case ERROR_CODE_NO_PERMISSION, ERROR_CODE_API_KEY_INVALID, ERROR_CODE_API_KEY_FAILED, ERROR_CODE_ACCESS_TOKEN_INVALID, ERROR_CODE_ACCESS_TOKEN_EXPIRED:
+		return errors.WithCode(code.ErrInvalidCredentials, "wenxin error %d: %s", e.ErrorCode, e.ErrorMsg)
+	case ERROR_CODE_INTERNAL:
+		return errors.WithCode(code.ErrModelServiceUnavailable, "wenxin error %d: %s", e.ErrorCode, e.ErrorMsg)
+	}
+
+	return errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin error %d: %s", e.ErrorCode, e.ErrorMsg)
+}
+
+// Credentials of the qianfan application.
+type Credentials struct {
+	APIKey    string
+	SecretKey string
+	BaseUrl   string
+}
+
+func CredentialsFromMap(credentials map[string]interface{}) (*Credentials, error) {
+	apiKey, _ := credentials["api_key"].(string)
+	secretKey, _ := credentials["secret_key"].(string)
+
+	if apiKey == "" || secretKey == "" {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "api_key and secret_key of wenxin are required")
+	}
+
+	baseUrl, _ := credentials["base_url"].(string)
+
+	if baseUrl == "" {
+		baseUrl = DEFAULT_BASE_URL
+	} else if _, err := url.ParseRequestURI(baseUrl); err != nil {
+		return nil, errors.WithCode(code.ErrInvalidCredentials, "base_url %s is not a valid url", baseUrl)
+	}
+
+	return &Credentials{
+		APIKey:    apiKey,
+		SecretKey: secretKey,
+		BaseUrl:   strings.TrimSuffix(baseUrl, "/"),
+	}, nil
+}
+
+// Invoke posts the request data to the api of the workshop path with the cached access token. The errors are
+// returned in json even for the stream requests, the request is retried once with a new token when the cached
+// one is rejected as expired.
+func Invoke(ctx context.Context, credentials *Credentials, path string, requestData interface{}) (*http.Response, error) {
+	requestBodyData, err := json.Marshal(requestData)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	endpointUrl, err := url.JoinPath(credentials.BaseUrl, WORKSHOP_PATH, path)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	for attempt := 0; ; attempt++ {
+		accessToken, err := AccessToken(ctx, credentials, attempt > 0)
+
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := post(ctx, endpointUrl+"?access_token="+url.QueryEscape(accessToken), requestBodyData)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !strings.HasPrefix(response.Header.Get("Content-Type"), "application/json") {
+			return response, nil
+		}
+
+		// the json responses are buffered to peek the error code, the body is restored for the callers
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+
+		if err != nil {
+			return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+
+		var apiErr APIError
+
+		if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.ErrorCode != 0 {
+			if apiErr.TokenExpired() && attempt == 0 {
+				log.Infof("wenxin access token is rejected with %d, retry with a new token", apiErr.ErrorCode)
+				InvalidateAccessToken(ctx, credentials)
+				continue
+			}
+			return nil, apiErr.Err()
+		}
+
+		response.Body = io.NopCloser(bytes.NewReader(body))
+		return response, nil
+	}
+}
+
+func post(ctx context.Context, endpointUrl string, requestBodyData []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	client := http.Client{
+		Timeout: time.Duration(300) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrModelServiceUnavailable, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		errBody, _ := io.ReadAll(response.Body)
+
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			return nil, errors.WithCode(code.ErrModelRateLimited, "wenxin api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+			return nil, errors.WithCode(code.ErrInvalidCredentials, "wenxin api returned status %d: %s", response.StatusCode, string(errBody))
+		case response.StatusCode >= http.StatusInternalServerError:
+			return nil, errors.WithCode(code.ErrModelServiceUnavailable, "wenxin api returned status %d: %s", response.StatusCode, string(errBody))
+		}
+		return nil, errors.WithCode(code.ErrCallLargeLanguageModel, "wenxin api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	return response, nil
+}
+
+// ValidateCredentials checks that the api key and secret key could be exchanged for an access token.
+func ValidateCredentials(ctx context.Context, credentials map[string]interface{}) error {
+	wenxinCredentials, err := CredentialsFromMap(credentials)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = AccessToken(ctx, wenxinCredentials, true)
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package wenxin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// newStubServer exchanges numbered access tokens and answers the chat requests with the response of the token.
+func newStubServer(t *testing.T, exchanges *int32, respond func(w http.ResponseWriter, accessToken string)) *httptest.Server {
+	log.NewWithOptions(log.WithDebugMode())
+	TokenStore = NewMemoryTokenStore()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		if r.URL.Path == "/oauth/2.0/token" {
+			if r.URL.Query().Get("client_secret") != "stub-secret" {
+				io.WriteString(w, `{"error":"invalid_client","error_description":"unknown client id"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token":"token-%d","expires_in":2592000}`, atomic.AddInt32(exchanges, 1))
+			return
+		}
+
+		if r.URL.Path != "/"+WORKSHOP_PATH+"/chat/completions" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+
+		respond(w, r.URL.Query().Get("access_token"))
+	}))
+}
+
+func stubCredentials(server *httptest.Server) *Credentials {
+	return &Credentials{APIKey: "stub-key", SecretKey: "stub-secret", BaseUrl: server.URL}
+}
+
+func TestAccessTokenCached(t *testing.T) {
+	var exchanges int32
+
+	server := newStubServer(t, &exchanges, func(w http.ResponseWriter, accessToken string) {
+		io.WriteString(w, `{"id":"as-1","result":"Hi"}`)
+	})
+	defer server.Close()
+
+	for i := 0; i < 3; i++ {
+		response, err := Invoke(context.Background(), stubCredentials(server), "chat/completions", map[string]interface{}{})
+
+		if err != nil {
+			t.Fatalf("Invoke() error = %v", err)
+		}
+
+		response.Body.Close()
+	}
+
+	if exchanges != 1 {
+		t.Errorf("access token was exchanged %d times, want 1", exchanges)
+	}
+
+	credentials := stubCredentials(server)
+	credentials.SecretKey = "wrong-secret"
+
+	if _, err := AccessToken(context.Background(), credentials, false); !errors.IsCode(err, code.ErrInvalidCredentials) {
+		t.Errorf("AccessToken() with a wrong secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestInvokeRetriesExpiredToken(t *testing.T) {
+	var exchanges int32
+
+	server := newStubServer(t, &exchanges, func(w http.ResponseWriter, accessToken string) {
+		if accessToken == "token-1" {
+			io.WriteString(w, `{"error_code":111,"error_msg":"Access token expired"}`)
+			return
+		}
+		io.WriteString(w, `{"id":"as-1","result":"Hi"}`)
+	})
+	defer server.Close()
+
+	response, err := Invoke(context.Background(), stubCredentials(server), "chat/completions", map[string]interface{}{})
+
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+
+	if string(body) != `{"id":"as-1","result":"Hi"}` || exchanges != 2 {
+		t.Errorf("Invoke() = %s after %d exchanges, want the response of the refreshed token", body, exchanges)
+	}
+
+	if token, _ := TokenStore.Get(context.Background(), accessTokenKey(stubCredentials(server))); token != "token-2" {
+		t.Errorf("cached token = %s, want token-2", token)
+	}
+}
+
+func TestInvokeErrorCodes(t *testing.T) {
+	var exchanges int32
+
+	server := newStubServer(t, &exchanges, func(w http.ResponseWriter, accessToken string) {
+		if accessToken == "token-1" || accessToken == "token-2" {
+			io.WriteString(w, `{"error_code":110,"error_msg":"Access token invalid or no longer valid"}`)
+			return
+		}
+		io.WriteString(w, `{"error_code":336501,"error_msg":"Rate limit reached for RPM"}`)
+	})
+	defer server.Close()
+
+	if _, err := Invoke(context.Background(), stubCredentials(server), "chat/completions", map[string]interface{}{}); !errors.IsCode(err, code.ErrInvalidCredentials) || exchanges != 2 {
+		t.Errorf("Invoke() error = %v after %d exchanges, want ErrInvalidCredentials after a single retry", err, exchanges)
+	}
+
+	if _, err := Invoke(context.Background(), stubCredentials(server), "chat/completions", map[string]interface{}{}); !errors.IsCode(err, code.ErrModelRateLimited) {
+		t.Errorf("Invoke() error = %v, want ErrModelRateLimited", err)
+	}
+}
+
+func TestTokenExpiration(t *testing.T) {
+	if expiration := tokenExpiration(2592000); expiration != 29*24*time.Hour {
+		t.Errorf("tokenExpiration() = %s, want a day ahead of 30 days", expiration)
+	}
+
+	if expiration := tokenExpiration(3600); expiration != 30*time.Minute {
+		t.Errorf("tokenExpiration() = %s, want half of an hour", expiration)
+	}
+}
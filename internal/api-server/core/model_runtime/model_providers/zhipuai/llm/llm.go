@@ -27,6 +27,7 @@ func NewZhipuLargeLanguageModel() *zhipuLargeLanguageModel {
 }
 
 var _ provider_register.IModelRegistry = (*zhipuLargeLanguageModel)(nil)
+var _ provider_register.ITokenizerDeclarer = (*zhipuLargeLanguageModel)(nil)
 
 func (m *zhipuLargeLanguageModel) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
 	credentials = m.addCustomParameters(credentials)
@@ -47,6 +48,11 @@ func (m *zhipuLargeLanguageModel) RegisterName() string {
 	return "zhipuai/llm"
 }
 
+// Tokenizer of glm is not bundled, the tokens are estimated by the char ratio.
+func (m *zhipuLargeLanguageModel) Tokenizer(model string) string {
+	return provider_register.CHAR_RATIO
+}
+
 func (m *zhipuLargeLanguageModel) addCustomParameters(credentials map[string]interface{}) map[string]interface{} {
 	credentials["mode"] = "chat"
 	credentials["endpoint_url"] = "https://open.bigmodel.cn/api/paas/v4"
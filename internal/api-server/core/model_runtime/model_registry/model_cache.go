@@ -0,0 +1,149 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+)
+
+// CACHE_REPLAY_CHUNK_SIZE is the number of runes of each chunk when a cached answer is replayed as a stream.
+const CACHE_REPLAY_CHUNK_SIZE = 8
+
+// LLMCacheRequest is the llm invocation which the answer is cached for.
+type LLMCacheRequest struct {
+	Provider       string
+	Model          string
+	PromptMessages []biz_entity_chat_prompt_message.IPromptMessage
+	Parameters     map[string]interface{}
+	Stop           []string
+	// Embedding is the embedding of the query, the semantic cache fills it on lookup so that the answer can be
+	// stored without embedding the query again
+	Embedding []float32
+}
+
+// ILLMCache caches the answers of the llm, the cache is looked up before the model is invoked.
+type ILLMCache interface {
+	// Get returns nil when there is no cached answer for the request.
+	Get(ctx context.Context, request *LLMCacheRequest) (*biz_entity_base_stream_generator.LLMResult, error)
+	Set(ctx context.Context, request *LLMCacheRequest, llmResult *biz_entity_base_stream_generator.LLMResult) error
+}
+
+// lookupCache returns the cached answer of the invocation, the request is nil when the invocation is not cacheable.
+// The invocations with tools are never cached since their answers are tool calls depending on the tool results.
+// The cache is best effort, its errors are logged and taken as misses.
+func (ac *modelRegistryCall) lookupCache(ctx context.Context, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, tools []*biz_entity_chat_prompt_message.PromptMessageTool, stop []string) (*LLMCacheRequest, *biz_entity_base_stream_generator.LLMResult) {
+	if ac.Cache == nil || len(tools) > 0 {
+		return nil, nil
+	}
+
+	request := &LLMCacheRequest{
+		Provider:       ac.Provider,
+		Model:          ac.Model,
+		PromptMessages: promptMessages,
+		Parameters:     modelParameters,
+		Stop:           stop,
+	}
+
+	llmResult, err := ac.Cache.Get(ctx, request)
+
+	if err != nil {
+		log.Warnf("failed to look up the llm cache of %s/%s: %s", ac.Provider, ac.Model, err.Error())
+		return request, nil
+	}
+
+	if llmResult != nil {
+		llmResult.PromptMessage = promptMessages
+		llmResult.Usage = biz_entity_base_stream_generator.NewEmptyLLMUsage()
+		llmResult.CacheHit = true
+	}
+
+	return request, llmResult
+}
+
+func (ac *modelRegistryCall) storeCache(ctx context.Context, request *LLMCacheRequest, llmResult *biz_entity_base_stream_generator.LLMResult) {
+	if request == nil || !isCacheable(llmResult) {
+		return
+	}
+
+	if err := ac.Cache.Set(context.WithoutCancel(ctx), request, llmResult); err != nil {
+		log.Warnf("failed to store the llm cache of %s/%s: %s", request.Provider, request.Model, err.Error())
+	}
+}
+
+// isCacheable reports whether the result is a complete text answer of the primary model. The answers of the
+// fallbacks are not cached, the cache is keyed by the primary model and would serve them once it recovers.
+func isCacheable(llmResult *biz_entity_base_stream_generator.LLMResult) bool {
+	if llmResult == nil || llmResult.Message == nil || llmResult.CacheHit || len(llmResult.Message.ToolCalls) > 0 {
+		return false
+	}
+
+	if len(llmResult.Fallbacks) > 0 {
+		return false
+	}
+
+	if llmResult.Reason == "length" {
+		return false
+	}
+
+	answer, ok := llmResult.Message.Content.(string)
+
+	return ok && answer != ""
+}
+
+// replayLLMResult streams the cached answer to the queue as if it is generated by the model.
+func replayLLMResult(queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, llmResult *biz_entity_base_stream_generator.LLMResult) {
+	answer := []rune(llmResult.Message.GetContent())
+
+	for index, start := 0, 0; start < len(answer); index, start = index+1, start+CACHE_REPLAY_CHUNK_SIZE {
+		end := min(start+CACHE_REPLAY_CHUNK_SIZE, len(answer))
+
+		queueManager.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+			Chunk: &biz_entity_base_stream_generator.LLMResultChunk{
+				Model:         llmResult.Model,
+				PromptMessage: llmResult.PromptMessage,
+				Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+					Index:   index,
+					Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(string(answer[start:end])),
+				},
+			},
+		})
+	}
+
+	queueManager.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+		LLMResult:     llmResult,
+	})
+}
+
+// cacheQueue stores the answer of the final event to the cache, before the event is passed on to the queue
+// whose consumers may change the result, e.g. the output moderation.
+type cacheQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	ctx     context.Context
+	caller  *modelRegistryCall
+	request *LLMCacheRequest
+}
+
+func newCacheQueue(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue, caller *modelRegistryCall, request *LLMCacheRequest) *cacheQueue {
+	return &cacheQueue{
+		IStreamGenerateQueue: queue,
+		ctx:                  ctx,
+		caller:               caller,
+		request:              request,
+	}
+}
+
+func (q *cacheQueue) Final(event biz_entity_base_stream_generator.IQueueEvent) {
+	if endEvent, ok := event.(*biz_entity_base_stream_generator.QueueMessageEndEvent); ok {
+		q.caller.storeCache(q.ctx, q.request, endEvent.LLMResult)
+	}
+
+	q.IStreamGenerateQueue.Final(event)
+}
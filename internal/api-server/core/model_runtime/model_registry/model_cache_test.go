@@ -0,0 +1,148 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// memoryCache caches the answers by the content of the last prompt message.
+type memoryCache struct {
+	answers map[string]*biz_entity_base_stream_generator.LLMResult
+}
+
+func (c *memoryCache) Get(ctx context.Context, request *LLMCacheRequest) (*biz_entity_base_stream_generator.LLMResult, error) {
+	cached, ok := c.answers[request.PromptMessages[len(request.PromptMessages)-1].GetContent()]
+
+	if !ok {
+		return nil, nil
+	}
+
+	return &biz_entity_base_stream_generator.LLMResult{Model: cached.Model, Provider: cached.Provider, Message: cached.Message}, nil
+}
+
+func (c *memoryCache) Set(ctx context.Context, request *LLMCacheRequest, llmResult *biz_entity_base_stream_generator.LLMResult) error {
+	c.answers[request.PromptMessages[len(request.PromptMessages)-1].GetContent()] = llmResult
+	return nil
+}
+
+// answerLLM answers with the content of the last prompt message.
+type answerLLM struct {
+	invoked int
+}
+
+func (m *answerLLM) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	llmResult, _ := m.InvokeNonStream(ctx, model, credentials, modelParameters, stop, user, promptMessages, modelRuntime)
+
+	queueManager.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+		LLMResult:     llmResult,
+	})
+}
+
+func (m *answerLLM) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.invoked++
+
+	return &biz_entity_base_stream_generator.LLMResult{
+		Model:   model,
+		Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(promptMessages[len(promptMessages)-1].GetContent()),
+		Usage:   &biz_entity_base_stream_generator.LLMUsage{TotalTokens: 10},
+		Reason:  "stop",
+	}, nil
+}
+
+func (m *answerLLM) RegisterName() string {
+	return "fake_answer/llm"
+}
+
+func TestInvokeLLMCache(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	llm := &answerLLM{}
+	ModelRuntimeRegistry.RegisterLargeModelInstance(llm)
+
+	cache := &memoryCache{answers: make(map[string]*biz_entity_base_stream_generator.LLMResult)}
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_answer", nil, nil, nil, nil, cache)
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("what is the cache of luna")}
+
+	llmResult, err := caller.InvokeLLMNonStream(context.Background(), promptMessages, nil, nil, nil, "", nil)
+
+	if err != nil || llmResult.CacheHit || llm.invoked != 1 {
+		t.Fatalf("InvokeLLMNonStream() = %+v, %v, invoked %d, want a miss", llmResult, err, llm.invoked)
+	}
+
+	llmResult, err = caller.InvokeLLMNonStream(context.Background(), promptMessages, nil, nil, nil, "", nil)
+
+	if err != nil || !llmResult.CacheHit || llm.invoked != 1 || llmResult.Usage.TotalTokens != 0 || llmResult.Provider != "fake_answer" {
+		t.Fatalf("InvokeLLMNonStream() = %+v, %v, invoked %d, want a free hit", llmResult, err, llm.invoked)
+	}
+
+	queue := &fakeQueue{}
+	caller.InvokeLLM(context.Background(), promptMessages, queue, nil, nil, nil, "", nil)
+
+	if queue.err != nil || llm.invoked != 1 || queue.final == nil || !queue.final.LLMResult.CacheHit {
+		t.Fatalf("InvokeLLM() final %+v, err %v, invoked %d, want a replayed hit", queue.final, queue.err, llm.invoked)
+	}
+
+	replayed := ""
+
+	for _, chunk := range queue.chunks {
+		replayed += chunk.(*biz_entity_base_stream_generator.QueueLLMChunkEvent).Chunk.Delta.Message.GetContent()
+	}
+
+	if len(queue.chunks) != 4 || replayed != "what is the cache of luna" {
+		t.Errorf("InvokeLLM() replayed %d chunks %q", len(queue.chunks), replayed)
+	}
+
+	// the streamed answers are cached as well, the invocations with tools are not
+	queue = &fakeQueue{}
+	promptMessages = []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("stream")}
+	caller.InvokeLLM(context.Background(), promptMessages, queue, nil, []*biz_entity_chat_prompt_message.PromptMessageTool{{Name: "tool"}}, nil, "", nil)
+	caller.InvokeLLM(context.Background(), promptMessages, queue, nil, nil, nil, "", nil)
+	caller.InvokeLLM(context.Background(), promptMessages, queue, nil, nil, nil, "", nil)
+
+	if llm.invoked != 3 || !queue.final.LLMResult.CacheHit {
+		t.Errorf("InvokeLLM() invoked %d, want the third invocation to hit", llm.invoked)
+	}
+}
+
+func TestInvokeLLMCacheFallback(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	ModelRuntimeRegistry.RegisterLargeModelInstance(&answerLLM{})
+	registerFakeLLM("fake_unavailable_cached", 0, errors.WithCode(code.ErrModelRateLimited, "status 429"))
+
+	cache := &memoryCache{answers: make(map[string]*biz_entity_base_stream_generator.LLMResult)}
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_unavailable_cached", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_answer"},
+	}, cache)
+	promptMessages := []biz_entity_chat_prompt_message.IPromptMessage{biz_entity_chat_prompt_message.NewUserMessage("answered by the fallback")}
+
+	llmResult, err := caller.InvokeLLMNonStream(context.Background(), promptMessages, nil, nil, nil, "", nil)
+
+	if err != nil || llmResult.Provider != "fake_answer" {
+		t.Fatalf("InvokeLLMNonStream() = %+v, %v, want the answer of the fallback", llmResult, err)
+	}
+
+	queue := &fakeQueue{}
+	caller.InvokeLLM(context.Background(), promptMessages, queue, nil, nil, nil, "", nil)
+
+	if queue.err != nil || queue.final == nil || queue.final.LLMResult.CacheHit {
+		t.Fatalf("InvokeLLM() final %+v, err %v, want the answer of the fallback", queue.final, queue.err)
+	}
+
+	// the cache is keyed by the primary model, which didn't answer
+	if len(cache.answers) != 0 {
+		t.Errorf("cached %d answers of the fallback", len(cache.answers))
+	}
+}
@@ -25,6 +25,12 @@ type IModelRegistryCall interface {
 	InvokeTextToSpeech(ctx context.Context, modelParameters map[string]interface{}, user string, voice string, format string, texts []string) error
 
 	InvokeTextEmbedding(ctx context.Context, modelParameters map[string]interface{}, user string, inputType string, texts []string) (*biz_entity_openai_standard_response.TextEmbeddingResult, error)
+
+	InvokeRerank(ctx context.Context, query string, docs []string, scoreThreshold float64, topN int, user string) (*biz_entity_openai_standard_response.RerankResult, error)
+
+	InvokeModeration(ctx context.Context, text string, user string) (bool, error)
+
+	GetLLMNumTokens(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, tools []*biz_entity_chat_prompt_message.PromptMessageTool) int
 }
 
 type modelRegistryCall struct {
@@ -33,6 +39,9 @@ type modelRegistryCall struct {
 	Credentials  map[string]interface{}
 	ModelType    string
 	ModelRuntime biz_entity.IAIModelRuntime
+	LoadBalancer *LoadBalancer
+	Fallbacks    []*FallbackModel
+	Cache        ILLMCache
 }
 
 func NewModelRegisterCaller(model, modelType, provider string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime) IModelRegistryCall {
@@ -45,35 +54,164 @@ func NewModelRegisterCaller(model, modelType, provider string, credentials map[s
 	}
 }
 
+// NewModelRegisterCallerWithFallbacks creates a caller whose llm invocations are balanced across the credentials of
+// the load balancer (nil to use the credentials) and go down the fallback chain when the model is rate limited or
+// unavailable. The answers are looked up in the cache before the models are invoked when the cache is not nil.
+func NewModelRegisterCallerWithFallbacks(model, modelType, provider string, credentials map[string]interface{}, modelRuntime biz_entity.IAIModelRuntime, loadBalancer *LoadBalancer, fallbacks []*FallbackModel, cache ILLMCache) IModelRegistryCall {
+	return &modelRegistryCall{
+		Model:        model,
+		ModelType:    modelType,
+		Provider:     provider,
+		Credentials:  credentials,
+		ModelRuntime: modelRuntime,
+		LoadBalancer: loadBalancer,
+		Fallbacks:    fallbacks,
+		Cache:        cache,
+	}
+}
+
+// InvokeLLM invokes the models of the chain in order, the next model is only tried when the previous one is
+// rate limited or unavailable before it streamed any chunk.
 func (ac *modelRegistryCall) InvokeLLM(ctx context.Context, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, modelParameters map[string]interface{}, tools []*biz_entity_chat_prompt_message.PromptMessageTool, stop []string, user string, callbacks interface{}) {
+	var (
+		lastErr   error
+		fallbacks []*biz_entity_base_stream_generator.LLMFallback
+	)
 
-	modelKeyMapInvoke := fmt.Sprintf("%s/%s", ac.Provider, ac.ModelType)
+	cacheRequest, cachedResult := ac.lookupCache(ctx, promptMessage, modelParameters, tools, stop)
 
-	log.Infof("invoke %s", modelKeyMapInvoke)
+	if cachedResult != nil {
+		replayLLMResult(queueManager, cachedResult)
+		return
+	}
 
-	AIModelIns, err := ModelRuntimeRegistry.Acquire(modelKeyMapInvoke)
+	if cacheRequest != nil {
+		queueManager = newCacheQueue(ctx, queueManager, ac, cacheRequest)
+	}
 
-	if err != nil {
-		queueManager.PushErr(err)
-		return
+	for attempt, chainModel := range ac.llmChain() {
+		if attempt > 0 {
+			if err := waitBackoff(ctx, fallbackBackoff(attempt)); err != nil {
+				break
+			}
+			log.Warnf("fallback to %s/%s after %s", chainModel.Provider, chainModel.Model, lastErr.Error())
+		}
+
+		modelKeyMapInvoke := fmt.Sprintf("%s/%s", chainModel.Provider, ac.ModelType)
+
+		log.Infof("invoke %s", modelKeyMapInvoke)
+
+		AIModelIns, err := ModelRuntimeRegistry.Acquire(modelKeyMapInvoke)
+
+		// the provider of the model may not be registered in this build, the rest of the chain is still tried
+		if err != nil {
+			log.Warnf("skip %s/%s of the chain: %s", chainModel.Provider, chainModel.Model, err.Error())
+			lastErr = err
+			fallbacks = append(fallbacks, &biz_entity_base_stream_generator.LLMFallback{
+				Provider: chainModel.Provider,
+				Model:    chainModel.Model,
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		parameters, err := chainModel.validateParameters(modelParameters)
+
+		if err != nil {
+			queueManager.PushErr(err)
+			return
+		}
+
+		fallbackQueue := newFallbackQueue(queueManager, chainModel.Provider, fallbacks, chainModel.LoadBalancer != nil)
+
+		invokeBalancedLLM(ctx, AIModelIns, chainModel, fallbackQueue, parameters, stop, user, promptMessage, tools)
+
+		if fallbackQueue.err == nil {
+			return
+		}
+
+		if !IsFallbackable(fallbackQueue.err) {
+			queueManager.PushErr(fallbackQueue.err)
+			return
+		}
+
+		lastErr = fallbackQueue.err
+		fallbacks = append(fallbacks, &biz_entity_base_stream_generator.LLMFallback{
+			Provider: chainModel.Provider,
+			Model:    chainModel.Model,
+			Error:    lastErr.Error(),
+		})
 	}
 
-	AIModelIns.Invoke(ctx, queueManager, ac.Model, ac.Credentials, modelParameters, stop, user, promptMessage, ac.ModelRuntime, tools)
+	queueManager.PushErr(lastErr)
 }
 
 func (ac *modelRegistryCall) InvokeLLMNonStream(ctx context.Context, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, modelParameters map[string]interface{}, tools interface{}, stop []string, user string, callbacks interface{}) (*biz_entity_base_stream_generator.LLMResult, error) {
+	var (
+		lastErr   error
+		fallbacks []*biz_entity_base_stream_generator.LLMFallback
+	)
 
-	modelKeyMapInvoke := fmt.Sprintf("%s/%s", ac.Provider, ac.ModelType)
-
-	log.Infof("invoke %s", modelKeyMapInvoke)
+	cacheRequest, cachedResult := ac.lookupCache(ctx, promptMessage, modelParameters, nil, stop)
 
-	AIModelIns, err := ModelRuntimeRegistry.Acquire(modelKeyMapInvoke)
+	if cachedResult != nil {
+		return cachedResult, nil
+	}
 
-	if err != nil {
-		return nil, err
+	for attempt, chainModel := range ac.llmChain() {
+		if attempt > 0 {
+			if err := waitBackoff(ctx, fallbackBackoff(attempt)); err != nil {
+				break
+			}
+			log.Warnf("fallback to %s/%s after %s", chainModel.Provider, chainModel.Model, lastErr.Error())
+		}
+
+		modelKeyMapInvoke := fmt.Sprintf("%s/%s", chainModel.Provider, ac.ModelType)
+
+		log.Infof("invoke %s", modelKeyMapInvoke)
+
+		AIModelIns, err := ModelRuntimeRegistry.Acquire(modelKeyMapInvoke)
+
+		// the provider of the model may not be registered in this build, the rest of the chain is still tried
+		if err != nil {
+			log.Warnf("skip %s/%s of the chain: %s", chainModel.Provider, chainModel.Model, err.Error())
+			lastErr = err
+			fallbacks = append(fallbacks, &biz_entity_base_stream_generator.LLMFallback{
+				Provider: chainModel.Provider,
+				Model:    chainModel.Model,
+				Error:    err.Error(),
+			})
+			continue
+		}
+
+		parameters, err := chainModel.validateParameters(modelParameters)
+
+		if err != nil {
+			return nil, err
+		}
+
+		llmResult, err := invokeBalancedLLMNonStream(ctx, AIModelIns, chainModel, parameters, stop, user, promptMessage)
+
+		if err == nil {
+			llmResult.Provider = chainModel.Provider
+			llmResult.Fallbacks = fallbacks
+			ac.storeCache(ctx, cacheRequest, llmResult)
+			return llmResult, nil
+		}
+
+		if !IsFallbackable(err) {
+			return nil, err
+		}
+
+		lastErr = err
+		fallbacks = append(fallbacks, &biz_entity_base_stream_generator.LLMFallback{
+			Provider: chainModel.Provider,
+			Model:    chainModel.Model,
+			Error:    err.Error(),
+		})
 	}
 
-	return AIModelIns.InvokeNonStream(ctx, ac.Model, ac.Credentials, modelParameters, stop, user, promptMessage, ac.ModelRuntime)
+	return nil, lastErr
 }
 
 func (ac *modelRegistryCall) InvokeSpeechToText(ctx context.Context, audioFileContent []byte, user string, filename string) (string, error) {
@@ -130,3 +268,36 @@ func (ac *modelRegistryCall) InvokeTextEmbedding(ctx context.Context, modelParam
 	}
 	return AIModelIns.Embedding(ctx, ac.Model, ac.Credentials, modelParameters, user, ac.ModelRuntime, inputType, texts)
 }
+
+func (ac *modelRegistryCall) InvokeRerank(ctx context.Context, query string, docs []string, scoreThreshold float64, topN int, user string) (*biz_entity_openai_standard_response.RerankResult, error) {
+
+	modelKeyMapInvoke := fmt.Sprintf("%s/%s", ac.Provider, ac.ModelType)
+
+	log.Infof("invoke %s", modelKeyMapInvoke)
+
+	AIModelIns, err := RerankRegistry.Acquire(modelKeyMapInvoke)
+
+	if err != nil {
+		return nil, err
+	}
+	return AIModelIns.Invoke(ctx, ac.Model, ac.Credentials, query, docs, scoreThreshold, topN, user, ac.ModelRuntime)
+}
+
+func (ac *modelRegistryCall) InvokeModeration(ctx context.Context, text string, user string) (bool, error) {
+
+	modelKeyMapInvoke := fmt.Sprintf("%s/%s", ac.Provider, ac.ModelType)
+
+	log.Infof("invoke %s", modelKeyMapInvoke)
+
+	AIModelIns, err := ModerationRegistry.Acquire(modelKeyMapInvoke)
+
+	if err != nil {
+		return false, err
+	}
+	return AIModelIns.Invoke(ctx, ac.Model, ac.Credentials, text, user, ac.ModelRuntime)
+}
+
+// GetLLMNumTokens counts the tokens of the prompt messages by the tokenizer which the llm runtime declares.
+func (ac *modelRegistryCall) GetLLMNumTokens(promptMessages []biz_entity_chat_prompt_message.IPromptMessage, tools []*biz_entity_chat_prompt_message.PromptMessageTool) int {
+	return GetNumTokens(GetLLMTokenizer(ac.Provider, ac.Model), promptMessages, tools)
+}
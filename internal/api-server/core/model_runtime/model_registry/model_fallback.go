@@ -0,0 +1,125 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"context"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	FALLBACK_BASE_BACKOFF = 500 * time.Millisecond
+	FALLBACK_MAX_BACKOFF  = 4 * time.Second
+)
+
+// FallbackModel is a model invoked when the models before it in the chain are rate limited or unavailable.
+type FallbackModel struct {
+	Model        string
+	Provider     string
+	Credentials  map[string]interface{}
+	ModelRuntime biz_entity.IAIModelRuntime
+	// LoadBalancer balances the requests across the credentials of the model, Credentials is used when it is nil
+	LoadBalancer *LoadBalancer
+}
+
+// validateParameters validates the parameters against the parameter rules of the model, each model of the chain
+// has its own rules, and the out of range values are clamped and the unsupported options dropped since the app model
+// config is validated on save.
+func (fm *FallbackModel) validateParameters(parameters map[string]interface{}) (map[string]interface{}, error) {
+	if fm.ModelRuntime == nil {
+		return parameters, nil
+	}
+
+	return fm.ModelRuntime.ValidateParameters(fm.Model, fm.Credentials, parameters, false)
+}
+
+// IsFallbackable reports whether the next model of the chain should be tried, only errors which mean the
+// model service is rate limited or unavailable (429/5xx, unreachable) are retried.
+func IsFallbackable(err error) bool {
+	return errors.IsCode(err, code.ErrModelServiceUnavailable) || errors.IsCode(err, code.ErrModelRateLimited)
+}
+
+// fallbackBackoff returns the wait time before the attempt-th (1-based) fallback model is invoked.
+func fallbackBackoff(attempt int) time.Duration {
+	backoff := FALLBACK_BASE_BACKOFF << (attempt - 1)
+
+	if backoff <= 0 || backoff > FALLBACK_MAX_BACKOFF {
+		return FALLBACK_MAX_BACKOFF
+	}
+	return backoff
+}
+
+func waitBackoff(ctx context.Context, backoff time.Duration) error {
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// fallbackQueue holds back the fallbackable error of a model until the first chunk has been streamed, after that
+// the answer can't be taken back so errors are passed through to the queue as usual. The rejected credentials error
+// is held back as well when the model is load balanced, so that the other credentials can be tried.
+type fallbackQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	provider  string
+	fallbacks []*biz_entity_base_stream_generator.LLMFallback
+	balanced  bool
+	streamed  bool
+	err       error
+}
+
+func newFallbackQueue(queue biz_entity_base_stream_generator.IStreamGenerateQueue, provider string, fallbacks []*biz_entity_base_stream_generator.LLMFallback, balanced bool) *fallbackQueue {
+	return &fallbackQueue{
+		IStreamGenerateQueue: queue,
+		provider:             provider,
+		fallbacks:            fallbacks,
+		balanced:             balanced,
+	}
+}
+
+func (q *fallbackQueue) Push(event biz_entity_base_stream_generator.IQueueEvent) {
+	q.streamed = true
+	q.IStreamGenerateQueue.Push(event)
+}
+
+func (q *fallbackQueue) PushErr(err error) {
+	if !q.streamed && (IsFallbackable(err) || q.balanced && errors.IsCode(err, code.ErrInvalidCredentials)) {
+		q.err = err
+		return
+	}
+	q.IStreamGenerateQueue.PushErr(err)
+}
+
+func (q *fallbackQueue) Final(event biz_entity_base_stream_generator.IQueueEvent) {
+	if endEvent, ok := event.(*biz_entity_base_stream_generator.QueueMessageEndEvent); ok && endEvent.LLMResult != nil {
+		endEvent.LLMResult.Provider = q.provider
+		endEvent.LLMResult.Fallbacks = q.fallbacks
+	}
+	q.IStreamGenerateQueue.Final(event)
+}
+
+func (ac *modelRegistryCall) llmChain() []*FallbackModel {
+	chain := make([]*FallbackModel, 0, len(ac.Fallbacks)+1)
+
+	chain = append(chain, &FallbackModel{
+		Model:        ac.Model,
+		Provider:     ac.Provider,
+		Credentials:  ac.Credentials,
+		ModelRuntime: ac.ModelRuntime,
+		LoadBalancer: ac.LoadBalancer,
+	})
+
+	return append(chain, ac.Fallbacks...)
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []biz_entity_base_stream_generator.IQueueEvent
+	final  *biz_entity_base_stream_generator.QueueMessageEndEvent
+	err    error
+}
+
+func (q *fakeQueue) Push(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.chunks = append(q.chunks, chunk)
+}
+
+func (q *fakeQueue) Final(chunk biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = chunk.(*biz_entity_base_stream_generator.QueueMessageEndEvent)
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+// fakeLLM streams streamedChunks chunks and then fails with err, or ends normally when err is nil.
+type fakeLLM struct {
+	name           string
+	streamedChunks int
+	err            error
+	invoked        int
+}
+
+func (m *fakeLLM) Invoke(ctx context.Context, queueManager biz_entity_base_stream_generator.IStreamGenerateQueue, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	m.invoked++
+
+	for i := 0; i < m.streamedChunks; i++ {
+		queueManager.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+			Chunk: &biz_entity_base_stream_generator.LLMResultChunk{
+				Model: model,
+				Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage("hi")},
+			},
+		})
+	}
+
+	if m.err != nil {
+		queueManager.PushErr(m.err)
+		return
+	}
+
+	queueManager.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+		LLMResult:     &biz_entity_base_stream_generator.LLMResult{Model: model},
+	})
+}
+
+func (m *fakeLLM) InvokeNonStream(ctx context.Context, model string, credentials map[string]interface{}, modelParameters map[string]interface{}, stop []string, user string, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_base_stream_generator.LLMResult, error) {
+	m.invoked++
+
+	if m.err != nil {
+		return nil, m.err
+	}
+	return &biz_entity_base_stream_generator.LLMResult{Model: model}, nil
+}
+
+func (m *fakeLLM) RegisterName() string {
+	return m.name + "/llm"
+}
+
+func registerFakeLLM(name string, streamedChunks int, err error) *fakeLLM {
+	fake := &fakeLLM{name: name, streamedChunks: streamedChunks, err: err}
+	ModelRuntimeRegistry.RegisterLargeModelInstance(fake)
+	return fake
+}
+
+func TestInvokeLLMFallback(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	unavailable := registerFakeLLM("fake_unavailable", 0, errors.WithCode(code.ErrModelRateLimited, "status 429"))
+	healthy := registerFakeLLM("fake_healthy", 1, nil)
+
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_unavailable", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_healthy"},
+	}, nil)
+
+	queue := &fakeQueue{}
+	caller.InvokeLLM(context.Background(), nil, queue, nil, nil, nil, "", nil)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	if unavailable.invoked != 1 || healthy.invoked != 1 || len(queue.chunks) != 1 {
+		t.Fatalf("expected both models invoked once and one chunk, got %d, %d, %d", unavailable.invoked, healthy.invoked, len(queue.chunks))
+	}
+
+	result := queue.final.LLMResult
+
+	if result.Provider != "fake_healthy" || result.Model != "model-y" {
+		t.Errorf("unexpected model used %s/%s", result.Provider, result.Model)
+	}
+
+	if len(result.Fallbacks) != 1 || result.Fallbacks[0].Provider != "fake_unavailable" || result.Fallbacks[0].Model != "model-x" {
+		t.Errorf("unexpected fallbacks %+v", result.Fallbacks)
+	}
+}
+
+func TestInvokeLLMNoFallbackAfterStreamed(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	registerFakeLLM("fake_broken_stream", 1, errors.WithCode(code.ErrModelServiceUnavailable, "connection reset"))
+	healthy := registerFakeLLM("fake_healthy_backup", 1, nil)
+
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_broken_stream", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_healthy_backup"},
+	}, nil)
+
+	queue := &fakeQueue{}
+	caller.InvokeLLM(context.Background(), nil, queue, nil, nil, nil, "", nil)
+
+	if !errors.IsCode(queue.err, code.ErrModelServiceUnavailable) {
+		t.Fatalf("error after the first chunk should be passed through, got %v", queue.err)
+	}
+
+	if healthy.invoked != 0 || queue.final != nil {
+		t.Error("fallback model should not be invoked after the first chunk")
+	}
+}
+
+func TestInvokeLLMNonStreamFallback(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	registerFakeLLM("fake_unavailable_sync", 0, errors.WithCode(code.ErrModelServiceUnavailable, "status 503"))
+	registerFakeLLM("fake_rejected_sync", 0, errors.WithCode(code.ErrCallLargeLanguageModel, "status 400"))
+	registerFakeLLM("fake_healthy_sync", 0, nil)
+
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_unavailable_sync", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_healthy_sync"},
+	}, nil)
+
+	result, err := caller.InvokeLLMNonStream(context.Background(), nil, nil, nil, nil, "", nil)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if result.Provider != "fake_healthy_sync" || len(result.Fallbacks) != 1 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	caller = NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_rejected_sync", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_healthy_sync"},
+	}, nil)
+
+	if _, err := caller.InvokeLLMNonStream(context.Background(), nil, nil, nil, nil, "", nil); !errors.IsCode(err, code.ErrCallLargeLanguageModel) {
+		t.Errorf("non fallbackable error should be returned directly, got %v", err)
+	}
+}
+
+func TestInvokeLLMFallbackUnregistered(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	registerFakeLLM("fake_unavailable_primary", 0, errors.WithCode(code.ErrModelRateLimited, "status 429"))
+	healthy := registerFakeLLM("fake_healthy_last", 1, nil)
+
+	caller := NewModelRegisterCallerWithFallbacks("model-x", "llm", "fake_unavailable_primary", nil, nil, nil, []*FallbackModel{
+		{Model: "model-y", Provider: "fake_unregistered"},
+		{Model: "model-z", Provider: "fake_healthy_last"},
+	}, nil)
+
+	queue := &fakeQueue{}
+	caller.InvokeLLM(context.Background(), nil, queue, nil, nil, nil, "", nil)
+
+	if queue.err != nil {
+		t.Fatalf("unexpected error: %s", queue.err.Error())
+	}
+
+	result := queue.final.LLMResult
+
+	if healthy.invoked != 1 || result.Provider != "fake_healthy_last" || result.Model != "model-z" {
+		t.Fatalf("expected the unregistered fallback skipped, got %s/%s", result.Provider, result.Model)
+	}
+
+	if len(result.Fallbacks) != 2 || result.Fallbacks[1].Provider != "fake_unregistered" {
+		t.Errorf("unexpected fallbacks %+v", result.Fallbacks)
+	}
+
+	syncResult, err := caller.InvokeLLMNonStream(context.Background(), nil, nil, nil, nil, "", nil)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if healthy.invoked != 2 || syncResult.Provider != "fake_healthy_last" || len(syncResult.Fallbacks) != 2 {
+		t.Errorf("unexpected result %+v", syncResult)
+	}
+}
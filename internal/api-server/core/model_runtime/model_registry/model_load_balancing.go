@@ -0,0 +1,211 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	LOAD_BALANCING_INDEX_PREFIX = "model_lb_index"
+	LOAD_BALANCING_INDEX_EXPIRE = time.Hour
+
+	// LOAD_BALANCING_RATE_LIMIT_COOLDOWN is how long the rate limited credentials are put aside
+	LOAD_BALANCING_RATE_LIMIT_COOLDOWN = time.Minute
+	// LOAD_BALANCING_AUTH_COOLDOWN is how long the rejected credentials are put aside
+	LOAD_BALANCING_AUTH_COOLDOWN = 10 * time.Minute
+)
+
+// LoadBalancingConfig is one of the named credentials which the requests of a model are balanced across.
+type LoadBalancingConfig struct {
+	ID          string
+	Name        string
+	Credentials map[string]interface{}
+	Weight      int
+}
+
+// LoadBalancer picks the credentials of a model by round robin or weighted round robin, the index is shared by all
+// the api servers through redis, credentials which are rate limited or rejected are cooled down for a while.
+type LoadBalancer struct {
+	redis     *redis.Client
+	TenantID  string
+	Provider  string
+	ModelType string
+	Model     string
+	Strategy  po_entity.LoadBalancingStrategy
+	Configs   []*LoadBalancingConfig
+}
+
+func NewLoadBalancer(redis *redis.Client, tenantID, provider, modelType, model string, strategy po_entity.LoadBalancingStrategy, configs []*LoadBalancingConfig) *LoadBalancer {
+	return &LoadBalancer{
+		redis:     redis,
+		TenantID:  tenantID,
+		Provider:  provider,
+		ModelType: modelType,
+		Model:     model,
+		Strategy:  strategy,
+		Configs:   configs,
+	}
+}
+
+func (lb *LoadBalancer) indexKey() string {
+	return fmt.Sprintf("%s:%s:%s:%s:%s", LOAD_BALANCING_INDEX_PREFIX, lb.TenantID, lb.Provider, lb.ModelType, lb.Model)
+}
+
+func (lb *LoadBalancer) cooldownKey(configID string) string {
+	return fmt.Sprintf("%s:cooldown:%s:%s:%s:%s:%s", LOAD_BALANCING_INDEX_PREFIX, lb.TenantID, lb.Provider, lb.ModelType, lb.Model, configID)
+}
+
+// Fetch returns the next credentials which are not cooling down, ErrModelServiceUnavailable is returned when all of
+// them are so that the caller can go down the fallback chain.
+func (lb *LoadBalancer) Fetch(ctx context.Context) (*LoadBalancingConfig, error) {
+	availableConfigs := make([]*LoadBalancingConfig, 0, len(lb.Configs))
+
+	for _, config := range lb.Configs {
+		if !lb.inCooldown(ctx, config) {
+			availableConfigs = append(availableConfigs, config)
+		}
+	}
+
+	if len(availableConfigs) == 0 {
+		return nil, errors.WithCode(code.ErrModelServiceUnavailable, "all the load balancing configs of %s/%s are cooling down", lb.Provider, lb.Model)
+	}
+
+	index, err := lb.redis.Incr(ctx, lb.indexKey()).Result()
+
+	if err != nil {
+		log.Errorf("redis occurred error when incr load balancing index of %s/%s: %s", lb.Provider, lb.Model, err.Error())
+		index = 1
+	}
+
+	if index == 1 {
+		lb.redis.Expire(ctx, lb.indexKey(), LOAD_BALANCING_INDEX_EXPIRE)
+	}
+
+	return pickLoadBalancingConfig(availableConfigs, lb.Strategy, index-1), nil
+}
+
+// Cooldown puts the credentials aside when the error means they are rate limited or rejected, it reports whether
+// the request should be retried with other credentials.
+func (lb *LoadBalancer) Cooldown(ctx context.Context, config *LoadBalancingConfig, err error) bool {
+	var expire time.Duration
+
+	switch {
+	case errors.IsCode(err, code.ErrModelRateLimited):
+		expire = LOAD_BALANCING_RATE_LIMIT_COOLDOWN
+	case errors.IsCode(err, code.ErrInvalidCredentials):
+		expire = LOAD_BALANCING_AUTH_COOLDOWN
+	default:
+		return false
+	}
+
+	log.Warnf("load balancing config %s of %s/%s is cooled down for %s: %s", config.Name, lb.Provider, lb.Model, expire, err.Error())
+
+	if err := lb.redis.Set(ctx, lb.cooldownKey(config.ID), 1, expire).Err(); err != nil {
+		log.Errorf("redis occurred error when cool down load balancing config %s: %s", config.ID, err.Error())
+	}
+	return true
+}
+
+func (lb *LoadBalancer) inCooldown(ctx context.Context, config *LoadBalancingConfig) bool {
+	exists, err := lb.redis.Exists(ctx, lb.cooldownKey(config.ID)).Result()
+
+	if err != nil {
+		log.Errorf("redis occurred error when check cooldown of load balancing config %s: %s", config.ID, err.Error())
+		return false
+	}
+	return exists > 0
+}
+
+// pickLoadBalancingConfig picks the config of the index, every config is picked once per round by round robin,
+// and weight times per round by weighted round robin.
+func pickLoadBalancingConfig(configs []*LoadBalancingConfig, strategy po_entity.LoadBalancingStrategy, index int64) *LoadBalancingConfig {
+	if strategy == po_entity.WEIGHTED {
+		var totalWeight int64
+
+		for _, config := range configs {
+			totalWeight += loadBalancingWeight(config)
+		}
+
+		offset := index % totalWeight
+
+		for _, config := range configs {
+			if offset < loadBalancingWeight(config) {
+				return config
+			}
+			offset -= loadBalancingWeight(config)
+		}
+	}
+
+	return configs[index%int64(len(configs))]
+}
+
+func loadBalancingWeight(config *LoadBalancingConfig) int64 {
+	if config.Weight <= 0 {
+		return 1
+	}
+	return int64(config.Weight)
+}
+
+// invokeBalancedLLM invokes the chain model, the credentials of its load balancer are tried in turn until the model
+// isn't rate limited or rejected, the last error is left in the queue.
+func invokeBalancedLLM(ctx context.Context, AIModelIns IModelRegistry, chainModel *FallbackModel, queue *fallbackQueue, modelParameters map[string]interface{}, stop []string, user string, promptMessage []biz_entity_chat_prompt_message.IPromptMessage, tools []*biz_entity_chat_prompt_message.PromptMessageTool) {
+	if chainModel.LoadBalancer == nil {
+		AIModelIns.Invoke(ctx, queue, chainModel.Model, chainModel.Credentials, modelParameters, stop, user, promptMessage, chainModel.ModelRuntime, tools)
+		return
+	}
+
+	for range chainModel.LoadBalancer.Configs {
+		config, err := chainModel.LoadBalancer.Fetch(ctx)
+
+		if err != nil {
+			queue.PushErr(err)
+			return
+		}
+
+		queue.err = nil
+
+		AIModelIns.Invoke(ctx, queue, chainModel.Model, config.Credentials, modelParameters, stop, user, promptMessage, chainModel.ModelRuntime, tools)
+
+		if queue.err == nil || !chainModel.LoadBalancer.Cooldown(ctx, config, queue.err) {
+			return
+		}
+	}
+}
+
+func invokeBalancedLLMNonStream(ctx context.Context, AIModelIns IModelRegistry, chainModel *FallbackModel, modelParameters map[string]interface{}, stop []string, user string, promptMessage []biz_entity_chat_prompt_message.IPromptMessage) (*biz_entity_base_stream_generator.LLMResult, error) {
+	if chainModel.LoadBalancer == nil {
+		return AIModelIns.InvokeNonStream(ctx, chainModel.Model, chainModel.Credentials, modelParameters, stop, user, promptMessage, chainModel.ModelRuntime)
+	}
+
+	var lastErr error
+
+	for range chainModel.LoadBalancer.Configs {
+		config, err := chainModel.LoadBalancer.Fetch(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+
+		llmResult, err := AIModelIns.InvokeNonStream(ctx, chainModel.Model, config.Credentials, modelParameters, stop, user, promptMessage, chainModel.ModelRuntime)
+
+		if err == nil || !chainModel.LoadBalancer.Cooldown(ctx, config, err) {
+			return llmResult, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
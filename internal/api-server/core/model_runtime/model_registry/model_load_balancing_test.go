@@ -0,0 +1,39 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"testing"
+
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+)
+
+func TestPickLoadBalancingConfig(t *testing.T) {
+	configs := []*LoadBalancingConfig{
+		{ID: "a", Weight: 3},
+		{ID: "b", Weight: 1},
+		{ID: "c"},
+	}
+
+	cases := []struct {
+		strategy po_entity.LoadBalancingStrategy
+		expected string
+	}{
+		{po_entity.ROUND_ROBIN, "abcabc"},
+		{po_entity.WEIGHTED, "aaabcaaabc"},
+	}
+
+	for _, c := range cases {
+		picked := ""
+
+		for index := range len(c.expected) {
+			picked += pickLoadBalancingConfig(configs, c.strategy, int64(index)).ID
+		}
+
+		if picked != c.expected {
+			t.Errorf("%s: expected %s, got %s", c.strategy, c.expected, picked)
+		}
+	}
+}
@@ -6,12 +6,14 @@ package model_registry
 
 import (
 	"context"
+	"fmt"
 	"sync"
 
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
 	biz_entity_openai_standard_response "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/openai_standard_response"
 	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
 	"github.com/lunarianss/Luna/internal/infrastructure/code"
 )
@@ -41,6 +43,28 @@ type ITextEmbeddingRegistry interface {
 	RegisterName() string
 }
 
+type IRerankRegistry interface {
+	Invoke(ctx context.Context, model string, credentials map[string]interface{}, query string, docs []string, scoreThreshold float64, topN int, user string, modelRuntime biz_entity.IAIModelRuntime) (*biz_entity_openai_standard_response.RerankResult, error)
+	RegisterName() string
+}
+
+type IModerationRegistry interface {
+	Invoke(ctx context.Context, model string, credentials map[string]interface{}, text string, user string, modelRuntime biz_entity.IAIModelRuntime) (bool, error)
+	RegisterName() string
+}
+
+// ICredentialValidator is optionally implemented by a registry which is able to verify
+// credentials against the model service before they are saved.
+type ICredentialValidator interface {
+	ValidateCredentials(ctx context.Context, model string, credentials map[string]interface{}) error
+}
+
+// IProviderCredentialValidator is optionally implemented by a registry which is able to verify the provider
+// credentials, which aren't bound to a model, against the model service before they are saved.
+type IProviderCredentialValidator interface {
+	ValidateProviderCredentials(ctx context.Context, credentials map[string]interface{}) error
+}
+
 var (
 	ModelRuntimeRegistry = &ModelRegistries[IModelRegistry]{
 		ModelRegistry: make(map[string]IModelRegistry, PROVIDER_NUMBER),
@@ -61,6 +85,16 @@ var (
 		ModelRegistry: make(map[string]ITextEmbeddingRegistry, 5),
 		RWMutex:       &sync.RWMutex{},
 	}
+
+	RerankRegistry = &ModelRegistries[IRerankRegistry]{
+		ModelRegistry: make(map[string]IRerankRegistry, 5),
+		RWMutex:       &sync.RWMutex{},
+	}
+
+	ModerationRegistry = &ModelRegistries[IModerationRegistry]{
+		ModelRegistry: make(map[string]IModerationRegistry, 2),
+		RWMutex:       &sync.RWMutex{},
+	}
 )
 
 type ModelRegistries[T any] struct {
@@ -81,11 +115,75 @@ func (mr *ModelRegistries[T]) RegisterLargeModelInstance(modelRegistry T) {
 		mr.ModelRegistry[v.RegisterName()] = modelRegistry
 	case ITextEmbeddingRegistry:
 		mr.ModelRegistry[v.RegisterName()] = modelRegistry
+	case IRerankRegistry:
+		mr.ModelRegistry[v.RegisterName()] = modelRegistry
+	case IModerationRegistry:
+		mr.ModelRegistry[v.RegisterName()] = modelRegistry
 	default:
 		panic("AI mulit model registry error: ")
 	}
 }
 
+// ValidateModelCredentials runs the remote credential check of the registry registered as provider/modelType,
+// registries which don't implement ICredentialValidator are treated as valid.
+func ValidateModelCredentials(ctx context.Context, provider, modelType, model string, credentials map[string]interface{}) error {
+	var (
+		registry any
+		err      error
+	)
+
+	name := fmt.Sprintf("%s/%s", provider, modelType)
+
+	switch common.ModelType(modelType) {
+	case common.LLM:
+		registry, err = ModelRuntimeRegistry.Acquire(name)
+	case common.TEXT_EMBEDDING:
+		registry, err = TextEmbeddingRegistry.Acquire(name)
+	case common.SPEECH2TEXT:
+		registry, err = AudioModelRuntimeRegistry.Acquire(name)
+	case common.TTS:
+		registry, err = TTSModelRuntimeRegistry.Acquire(name)
+	case common.RERANK:
+		registry, err = RerankRegistry.Acquire(name)
+	case common.MODERATION:
+		registry, err = ModerationRegistry.Acquire(name)
+	default:
+		return nil
+	}
+
+	if err != nil {
+		return nil
+	}
+
+	if validator, ok := registry.(ICredentialValidator); ok {
+		return validator.ValidateCredentials(ctx, model, credentials)
+	}
+
+	return nil
+}
+
+// ValidateProviderCredentials runs the remote credential check of the first llm or text embedding registry of the
+// provider which implements IProviderCredentialValidator, providers without one are treated as valid.
+func ValidateProviderCredentials(ctx context.Context, provider string, credentials map[string]interface{}) error {
+	var registries []any
+
+	if registry, err := ModelRuntimeRegistry.Acquire(fmt.Sprintf("%s/%s", provider, common.LLM)); err == nil {
+		registries = append(registries, registry)
+	}
+
+	if registry, err := TextEmbeddingRegistry.Acquire(fmt.Sprintf("%s/%s", provider, common.TEXT_EMBEDDING)); err == nil {
+		registries = append(registries, registry)
+	}
+
+	for _, registry := range registries {
+		if validator, ok := registry.(IProviderCredentialValidator); ok {
+			return validator.ValidateProviderCredentials(ctx, credentials)
+		}
+	}
+
+	return nil
+}
+
 func (mr *ModelRegistries[T]) Acquire(name string) (T, error) {
 	defer mr.RUnlock()
 	mr.RLock()
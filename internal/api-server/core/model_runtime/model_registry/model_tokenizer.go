@@ -0,0 +1,183 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+)
+
+const (
+	CL100K_BASE = "cl100k_base"
+	O200K_BASE  = "o200k_base"
+	CHAR_RATIO  = "char_ratio"
+
+	// CHARS_PER_TOKEN is how many non cjk characters are counted as one token by the char ratio tokenizer
+	CHARS_PER_TOKEN = 4
+	// TOKENS_PER_MESSAGE is the tokens taken by the role and the separators of every prompt message
+	TOKENS_PER_MESSAGE = 3
+	// TOKENS_PER_REPLY is the tokens which prime the reply of the assistant
+	TOKENS_PER_REPLY = 3
+)
+
+// ITokenizer counts the tokens of the text the way the model does.
+type ITokenizer interface {
+	CountTokens(text string) int
+}
+
+// ITokenizerDeclarer is implemented by the llm runtimes to declare the tokenizer of the model, the char ratio
+// tokenizer is used for the runtimes which don't implement it.
+type ITokenizerDeclarer interface {
+	Tokenizer(model string) string
+}
+
+var (
+	tokenizerMu sync.RWMutex
+	tokenizers  = map[string]ITokenizer{
+		CL100K_BASE: newBPETokenizer(CL100K_BASE, cl100kPattern),
+		O200K_BASE:  newBPETokenizer(O200K_BASE, o200kPattern),
+		CHAR_RATIO:  &charRatioTokenizer{},
+	}
+)
+
+// RegisterTokenizer registers the tokenizer with the name, the registered one of the same name is replaced.
+func RegisterTokenizer(name string, tokenizer ITokenizer) {
+	tokenizerMu.Lock()
+	defer tokenizerMu.Unlock()
+	tokenizers[name] = tokenizer
+}
+
+// LoadTokenizers loads the vocabularies of the registered tokenizers, a tokenizer whose vocabulary is missing
+// warns and falls back to the char ratio tokenizer.
+func LoadTokenizers() {
+	tokenizerMu.RLock()
+	defer tokenizerMu.RUnlock()
+
+	for _, tokenizer := range tokenizers {
+		if preloader, ok := tokenizer.(interface{ preload() }); ok {
+			preloader.preload()
+		}
+	}
+}
+
+// GetTokenizer returns the tokenizer of the name, the char ratio tokenizer is returned when it is not registered.
+func GetTokenizer(name string) ITokenizer {
+	tokenizerMu.RLock()
+	defer tokenizerMu.RUnlock()
+
+	if tokenizer, ok := tokenizers[name]; ok {
+		return tokenizer
+	}
+
+	log.Warnf("tokenizer %s is not registered, fallback to %s", name, CHAR_RATIO)
+	return tokenizers[CHAR_RATIO]
+}
+
+// GetLLMTokenizer returns the tokenizer declared by the llm runtime of the provider for the model.
+func GetLLMTokenizer(provider, model string) ITokenizer {
+	AIModelIns, err := ModelRuntimeRegistry.Acquire(fmt.Sprintf("%s/llm", provider))
+
+	if err != nil {
+		return GetTokenizer(CHAR_RATIO)
+	}
+
+	if declarer, ok := AIModelIns.(ITokenizerDeclarer); ok {
+		return GetTokenizer(declarer.Tokenizer(model))
+	}
+	return GetTokenizer(CHAR_RATIO)
+}
+
+// GetNumTokens counts the tokens of the prompt messages and the tools sent to the model.
+func GetNumTokens(tokenizer ITokenizer, promptMessages []biz_entity_chat_prompt_message.IPromptMessage, tools []*biz_entity_chat_prompt_message.PromptMessageTool) int {
+	numTokens := 0
+
+	for _, promptMessage := range promptMessages {
+		numTokens += TOKENS_PER_MESSAGE
+		numTokens += tokenizer.CountTokens(promptMessage.GetRole())
+		numTokens += tokenizer.CountTokens(promptMessageText(promptMessage))
+
+		if name := promptMessage.GetName(); name != "" {
+			numTokens += tokenizer.CountTokens(name)
+		}
+	}
+
+	for _, tool := range tools {
+		toolDefinition, err := json.Marshal(tool)
+
+		if err != nil {
+			continue
+		}
+		numTokens += tokenizer.CountTokens(string(toolDefinition))
+	}
+
+	if len(promptMessages) > 0 {
+		numTokens += TOKENS_PER_REPLY
+	}
+
+	return numTokens
+}
+
+func promptMessageText(promptMessage biz_entity_chat_prompt_message.IPromptMessage) string {
+	var (
+		content   any
+		toolCalls string
+	)
+
+	switch message := promptMessage.(type) {
+	case *biz_entity_chat_prompt_message.PromptMessage:
+		content = message.Content
+	case *biz_entity_chat_prompt_message.ToolPromptMessage:
+		content = message.Content
+	case *biz_entity_chat_prompt_message.AssistantPromptMessage:
+		content = message.Content
+
+		for _, toolCall := range message.ToolCalls {
+			if toolCall.Function != nil {
+				toolCalls += toolCall.Function.Name + toolCall.Function.Arguments
+			}
+		}
+	default:
+		return promptMessage.GetContent()
+	}
+
+	switch content := content.(type) {
+	case string:
+		return content + toolCalls
+	case []*biz_entity_chat_prompt_message.PromptMessageContent:
+		var text strings.Builder
+
+		for _, messageContent := range content {
+			if data, ok := messageContent.Data.(string); ok && messageContent.Type == biz_entity_chat_prompt_message.TEXT {
+				text.WriteString(data)
+			}
+		}
+		return text.String() + toolCalls
+	}
+	return toolCalls
+}
+
+// charRatioTokenizer estimates the tokens for the models whose tokenizer isn't published, every cjk character is
+// counted as one token and every CHARS_PER_TOKEN other characters as one token.
+type charRatioTokenizer struct{}
+
+func (t *charRatioTokenizer) CountTokens(text string) int {
+	var cjk, others int
+
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			cjk++
+		} else {
+			others++
+		}
+	}
+
+	return cjk + (others+CHARS_PER_TOKEN-1)/CHARS_PER_TOKEN
+}
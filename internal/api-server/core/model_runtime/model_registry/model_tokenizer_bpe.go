@@ -0,0 +1,259 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+)
+
+//go:embed tokenizer_assets
+var tokenizerAssets embed.FS
+
+const (
+	// whitespace is the unicode \s of the original patterns, \s of go regexp only matches the ascii spaces
+	whitespace = `\t\n\v\f\r \x{85}\p{Z}`
+
+	contractions = `(?i:'s|'t|'re|'ve|'m|'ll|'d)`
+)
+
+// The pre tokenize patterns of tiktoken split into their alternatives, the `\s+(?!\S)` alternative can't be
+// expressed by go regexp so it is matched by trailingWhitespace.
+var (
+	cl100kPattern = []string{
+		contractions,
+		`[^\r\n\p{L}\p{N}]?\p{L}+`,
+		`\p{N}{1,3}`,
+		` ?[^` + whitespace + `\p{L}\p{N}]+[\r\n]*`,
+		`[` + whitespace + `]*[\r\n]+`,
+		"",
+		`[` + whitespace + `]+`,
+	}
+
+	o200kPattern = []string{
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]*[\p{Ll}\p{Lm}\p{Lo}\p{M}]+` + contractions + `?`,
+		`[^\r\n\p{L}\p{N}]?[\p{Lu}\p{Lt}\p{Lm}\p{Lo}\p{M}]+[\p{Ll}\p{Lm}\p{Lo}\p{M}]*` + contractions + `?`,
+		`\p{N}{1,3}`,
+		` ?[^` + whitespace + `\p{L}\p{N}]+[\r\n/]*`,
+		`[` + whitespace + `]*[\r\n]+`,
+		"",
+		`[` + whitespace + `]+`,
+	}
+
+	whitespaceRegexp = regexp.MustCompile(`^[` + whitespace + `]+`)
+)
+
+// bpeTokenizer is the byte pair encoding tokenizer of the openai models, the ranks are loaded from the gzipped tiktoken
+// vocabulary of tokenizer_assets at the first time, the char ratio tokenizer is used when the vocabulary is missing.
+type bpeTokenizer struct {
+	name     string
+	pattern  []string
+	once     sync.Once
+	regexps  []*regexp.Regexp
+	ranks    map[string]int
+	fallback ITokenizer
+}
+
+func newBPETokenizer(name string, pattern []string) *bpeTokenizer {
+	return &bpeTokenizer{name: name, pattern: pattern}
+}
+
+func (t *bpeTokenizer) load() {
+	compressed, err := tokenizerAssets.ReadFile(fmt.Sprintf("tokenizer_assets/%s.tiktoken.gz", t.name))
+
+	if err == nil {
+		t.ranks, err = parseCompressedTiktokenRanks(compressed)
+	}
+
+	if err != nil {
+		log.Warnf("vocabulary of tokenizer %s is not loaded, the tokens are estimated by %s, run make gen.tokenizer to embed it: %s", t.name, CHAR_RATIO, err.Error())
+		t.fallback = &charRatioTokenizer{}
+		return
+	}
+
+	t.compile()
+}
+
+func (t *bpeTokenizer) preload() {
+	t.once.Do(t.load)
+}
+
+func (t *bpeTokenizer) compile() {
+	t.regexps = make([]*regexp.Regexp, len(t.pattern))
+
+	for i, alternative := range t.pattern {
+		if alternative != "" {
+			t.regexps[i] = regexp.MustCompile("^(?:" + alternative + ")")
+		}
+	}
+}
+
+func (t *bpeTokenizer) CountTokens(text string) int {
+	t.preload()
+
+	if t.fallback != nil {
+		return t.fallback.CountTokens(text)
+	}
+
+	numTokens := 0
+
+	for _, piece := range t.preTokenize(text) {
+		if _, ok := t.ranks[piece]; ok {
+			numTokens++
+			continue
+		}
+		numTokens += bytePairCount([]byte(piece), t.ranks)
+	}
+
+	return numTokens
+}
+
+// preTokenize splits the text the same way as the pattern does, the alternatives are tried in order at every
+// position which is the leftmost first semantics of the original pattern.
+func (t *bpeTokenizer) preTokenize(text string) []string {
+	var pieces []string
+
+	for len(text) > 0 {
+		end := 0
+
+		for _, re := range t.regexps {
+			if re == nil {
+				end = trailingWhitespace(text)
+			} else if loc := re.FindStringIndex(text); loc != nil {
+				end = loc[1]
+			}
+
+			if end > 0 {
+				break
+			}
+		}
+
+		// unreachable as the last alternative matches any whitespace and the others match the rest
+		if end == 0 {
+			_, end = utf8.DecodeRuneInString(text)
+		}
+
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+
+	return pieces
+}
+
+// trailingWhitespace matches `\s+(?!\S)`, the whitespaces which are not followed by a non whitespace character,
+// the last whitespace is left to the next piece otherwise.
+func trailingWhitespace(text string) int {
+	loc := whitespaceRegexp.FindStringIndex(text)
+
+	if loc == nil {
+		return 0
+	}
+
+	if loc[1] == len(text) {
+		return loc[1]
+	}
+
+	_, lastSize := utf8.DecodeLastRuneInString(text[:loc[1]])
+	return loc[1] - lastSize
+}
+
+// bytePairCount merges the adjacent parts of the piece with the lowest rank until none of them can be merged,
+// and returns the count of the parts left.
+func bytePairCount(piece []byte, ranks map[string]int) int {
+	if len(piece) <= 1 {
+		return len(piece)
+	}
+
+	// boundaries of the parts, the part i is piece[boundaries[i]:boundaries[i+1]]
+	boundaries := make([]int, len(piece)+1)
+
+	for i := range boundaries {
+		boundaries[i] = i
+	}
+
+	for len(boundaries) > 2 {
+		minRank, minIndex := math.MaxInt, -1
+
+		for i := 0; i < len(boundaries)-2; i++ {
+			if rank, ok := ranks[string(piece[boundaries[i]:boundaries[i+2]])]; ok && rank < minRank {
+				minRank, minIndex = rank, i
+			}
+		}
+
+		if minIndex < 0 {
+			break
+		}
+
+		boundaries = append(boundaries[:minIndex+1], boundaries[minIndex+2:]...)
+	}
+
+	return len(boundaries) - 1
+}
+
+// parseCompressedTiktokenRanks parses the gzipped tiktoken vocabulary.
+func parseCompressedTiktokenRanks(compressed []byte) (map[string]int, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer reader.Close()
+
+	vocabulary, err := io.ReadAll(reader)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTiktokenRanks(vocabulary)
+}
+
+// parseTiktokenRanks parses the tiktoken vocabulary, every line of which is a base64 encoded token and its rank.
+func parseTiktokenRanks(vocabulary []byte) (map[string]int, error) {
+	ranks := make(map[string]int, 200000)
+	scanner := bufio.NewScanner(bytes.NewReader(vocabulary))
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+
+		if len(line) == 0 {
+			continue
+		}
+
+		fields := bytes.Fields(line)
+
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid vocabulary line %q", line)
+		}
+
+		token, err := base64.StdEncoding.DecodeString(string(fields[0]))
+
+		if err != nil {
+			return nil, err
+		}
+
+		rank, err := strconv.Atoi(string(fields[1]))
+
+		if err != nil {
+			return nil, err
+		}
+		ranks[string(token)] = rank
+	}
+
+	return ranks, scanner.Err()
+}
@@ -0,0 +1,97 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package model_registry
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPreTokenize(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected []string
+	}{
+		{"Hello world", []string{"Hello", " world"}},
+		{"I'll pay 12345!", []string{"I", "'ll", " pay", " ", "123", "45", "!"}},
+		{"a  b\n\nc  ", []string{"a", " ", " b", "\n\n", "c", "  "}},
+	}
+
+	tokenizer := newBPETokenizer(CL100K_BASE, cl100kPattern)
+	tokenizer.compile()
+
+	for _, c := range cases {
+		if pieces := tokenizer.preTokenize(c.text); !slices.Equal(pieces, c.expected) {
+			t.Errorf("%q: expected %q, got %q", c.text, c.expected, pieces)
+		}
+	}
+}
+
+func TestBPETokenizer(t *testing.T) {
+	var vocabulary strings.Builder
+
+	for rank, token := range []string{"a", "b", "c", " ", "ab", "abc", " a"} {
+		fmt.Fprintf(&vocabulary, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(token)), rank)
+	}
+
+	var compressed bytes.Buffer
+
+	writer := gzip.NewWriter(&compressed)
+	writer.Write([]byte(vocabulary.String()))
+	writer.Close()
+
+	ranks, err := parseCompressedTiktokenRanks(compressed.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokenizer := newBPETokenizer(CL100K_BASE, cl100kPattern)
+	tokenizer.compile()
+	tokenizer.ranks = ranks
+	tokenizer.once.Do(func() {})
+
+	// "abcab" is merged to "abc" + "ab", " abc" is merged to " " + "abc" as "ab" ranks lower than " a"
+	if numTokens := tokenizer.CountTokens("abcab abc"); numTokens != 4 {
+		t.Errorf("expected 4 tokens, got %d", numTokens)
+	}
+}
+
+func TestTiktokenCounts(t *testing.T) {
+	cases := []struct {
+		tokenizer string
+		text      string
+		expected  int
+	}{
+		{CL100K_BASE, "hello world", 2},
+		{CL100K_BASE, "Hello, world!", 4},
+		{CL100K_BASE, "tiktoken is great!", 6},
+		{O200K_BASE, "hello world", 2},
+		{O200K_BASE, "Hello, world!", 4},
+	}
+
+	for _, c := range cases {
+		if _, err := tokenizerAssets.Open(fmt.Sprintf("tokenizer_assets/%s.tiktoken.gz", c.tokenizer)); err != nil {
+			t.Fatalf("vocabulary of %s is not embedded, run make gen.tokenizer: %s", c.tokenizer, err.Error())
+		}
+
+		if numTokens := GetTokenizer(c.tokenizer).CountTokens(c.text); numTokens != c.expected {
+			t.Errorf("%s %q: expected %d tokens, got %d", c.tokenizer, c.text, c.expected, numTokens)
+		}
+	}
+}
+
+func TestCharRatioTokenizer(t *testing.T) {
+	tokenizer := &charRatioTokenizer{}
+
+	if numTokens := tokenizer.CountTokens("你好, world"); numTokens != 4 {
+		t.Errorf("expected 4 tokens, got %d", numTokens)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// apiModerator delegates the check to an external api, the request is `POST {api_url}` with body
+// `{"point": "app.moderation.input", "params": {...}}` and the response is `{"flagged": bool, "preset_response": string}`.
+type apiModerator struct {
+	apiUrl string
+	apiKey string
+}
+
+func newApiModerator(apiUrl, apiKey string) *apiModerator {
+	return &apiModerator{
+		apiUrl: apiUrl,
+		apiKey: apiKey,
+	}
+}
+
+func (am *apiModerator) moderate(ctx context.Context, point ModerationPoint, inputs map[string]interface{}, text string) (bool, string, error) {
+	params := make(map[string]interface{})
+
+	if point == INPUT {
+		params["inputs"] = inputs
+		params["query"] = text
+	} else {
+		params["text"] = text
+	}
+
+	requestBodyData, err := json.Marshal(map[string]interface{}{
+		"point":  point,
+		"params": params,
+	})
+
+	if err != nil {
+		return false, "", errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", am.apiUrl, bytes.NewReader(requestBodyData))
+
+	if err != nil {
+		return false, "", errors.WithSCode(code.ErrModerationConfig, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if am.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", am.apiKey))
+	}
+
+	client := http.Client{
+		Timeout: time.Duration(10) * time.Second,
+	}
+
+	response, err := client.Do(req)
+
+	if err != nil {
+		return false, "", errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		errBody, _ := io.ReadAll(response.Body)
+		return false, "", errors.WithCode(code.ErrRunTimeCaller, "moderation api returned status %d: %s", response.StatusCode, string(errBody))
+	}
+
+	var result ModerationResult
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return false, "", errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	return result.Flagged, result.PresetResponse, nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type keywordsModerator struct {
+	keywords []string
+}
+
+func newKeywordsModerator(keywords string) *keywordsModerator {
+	return &keywordsModerator{
+		keywords: splitKeywords(keywords),
+	}
+}
+
+func (km *keywordsModerator) moderate(ctx context.Context, point ModerationPoint, inputs map[string]interface{}, text string) (bool, string, error) {
+	if km.hit(text) {
+		return true, "", nil
+	}
+
+	for _, value := range inputs {
+		if km.hit(fmt.Sprint(value)) {
+			return true, "", nil
+		}
+	}
+
+	return false, "", nil
+}
+
+func (km *keywordsModerator) hit(text string) bool {
+	text = strings.ToLower(text)
+
+	for _, keyword := range km.keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitKeywords splits the newline separated keywords, blank lines are ignored and keywords are matched
+// case-insensitively.
+func splitKeywords(keywords string) []string {
+	var result []string
+
+	for _, keyword := range strings.Split(keywords, "\n") {
+		keyword = strings.TrimSpace(keyword)
+
+		if keyword == "" {
+			continue
+		}
+		result = append(result, strings.ToLower(keyword))
+	}
+
+	return result
+}
@@ -0,0 +1,58 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+)
+
+// modelModerator checks the text by the moderation model which the tenant has configured credentials for.
+type modelModerator struct {
+	providerDomain *providerDomain.ProviderDomain
+	tenantID       string
+	provider       string
+	model          string
+}
+
+func newModelModerator(providerDomain *providerDomain.ProviderDomain, tenantID, provider, model string) *modelModerator {
+	return &modelModerator{
+		providerDomain: providerDomain,
+		tenantID:       tenantID,
+		provider:       provider,
+		model:          model,
+	}
+}
+
+func (mm *modelModerator) moderate(ctx context.Context, point ModerationPoint, inputs map[string]interface{}, text string) (bool, string, error) {
+	texts := make([]string, 0, len(inputs)+1)
+
+	for _, value := range inputs {
+		texts = append(texts, fmt.Sprint(value))
+	}
+
+	texts = append(texts, text)
+
+	modelInstance, err := mm.providerDomain.GetModelInstance(ctx, mm.tenantID, mm.provider, mm.model, common.MODERATION)
+
+	if err != nil {
+		return false, "", err
+	}
+
+	caller := model_registry.NewModelRegisterCaller(modelInstance.Model, string(common.MODERATION), modelInstance.Provider, modelInstance.Credentials, modelInstance.ModelTypeInstance)
+
+	flagged, err := caller.InvokeModeration(ctx, strings.Join(texts, "\n"), "")
+
+	if err != nil {
+		return false, "", err
+	}
+
+	return flagged, "", nil
+}
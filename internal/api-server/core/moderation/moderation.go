@@ -0,0 +1,208 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	providerDomain "github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type ModerationType string
+
+const (
+	KEYWORDS          ModerationType = "keywords"
+	OPENAI_MODERATION ModerationType = "openai_moderation"
+	API               ModerationType = "api"
+)
+
+type ModerationPoint string
+
+const (
+	INPUT  ModerationPoint = "app.moderation.input"
+	OUTPUT ModerationPoint = "app.moderation.output"
+)
+
+const (
+	DEFAULT_BUFFER_SIZE     = 300
+	DEFAULT_PROVIDER        = "openai"
+	DEFAULT_MODEL           = "text-moderation-stable"
+	DEFAULT_PRESET_RESPONSE = "Your content violates our usage policy. Please revise and try again."
+)
+
+type ModerationPresetConfig struct {
+	Enabled        bool   `json:"enabled"`
+	PresetResponse string `json:"preset_response"`
+}
+
+// ModerationConfig is the `config` of the sensitive word avoidance feature, fields which are not used by
+// the moderation type are ignored.
+type ModerationConfig struct {
+	Keywords      string                  `json:"keywords"`
+	InputsConfig  *ModerationPresetConfig `json:"inputs_config"`
+	OutputsConfig *ModerationPresetConfig `json:"outputs_config"`
+	BufferSize    int                     `json:"buffer_size"`
+	Provider      string                  `json:"provider"`
+	Model         string                  `json:"model"`
+	ApiUrl        string                  `json:"api_url"`
+	ApiKey        string                  `json:"api_key"`
+}
+
+type ModerationResult struct {
+	Flagged        bool   `json:"flagged"`
+	PresetResponse string `json:"preset_response"`
+}
+
+type IModeration interface {
+	ModerationForInputs(ctx context.Context, inputs map[string]interface{}, query string) (*ModerationResult, error)
+	ModerationForOutputs(ctx context.Context, text string) (*ModerationResult, error)
+	InputsEnabled() bool
+	OutputsEnabled() bool
+	BufferSize() int
+}
+
+// moderator is the check performed by a moderation type, the returned preset response overrides the
+// configured one when it is not empty.
+type moderator interface {
+	moderate(ctx context.Context, point ModerationPoint, inputs map[string]interface{}, text string) (bool, string, error)
+}
+
+type moderation struct {
+	config    *ModerationConfig
+	moderator moderator
+}
+
+// NewModeration builds the moderation of the app from its sensitive word avoidance config, nil is returned
+// when the feature is disabled.
+func NewModeration(ctx context.Context, providerDomain *providerDomain.ProviderDomain, tenantID string, sensitiveWordAvoidance *biz_entity_app_config.SensitiveWordAvoidanceEntity) (IModeration, error) {
+	if sensitiveWordAvoidance == nil || sensitiveWordAvoidance.Type == "" {
+		return nil, nil
+	}
+
+	config, err := ParseModerationConfig(sensitiveWordAvoidance)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var moderator moderator
+
+	switch ModerationType(sensitiveWordAvoidance.Type) {
+	case KEYWORDS:
+		moderator = newKeywordsModerator(config.Keywords)
+	case OPENAI_MODERATION:
+		moderator = newModelModerator(providerDomain, tenantID, config.Provider, config.Model)
+	case API:
+		moderator = newApiModerator(config.ApiUrl, config.ApiKey)
+	}
+
+	return &moderation{
+		config:    config,
+		moderator: moderator,
+	}, nil
+}
+
+// ParseModerationConfig decodes and validates the config of the sensitive word avoidance entity.
+func ParseModerationConfig(sensitiveWordAvoidance *biz_entity_app_config.SensitiveWordAvoidanceEntity) (*ModerationConfig, error) {
+	var config ModerationConfig
+
+	configByte, err := json.Marshal(sensitiveWordAvoidance.Config)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	if err := json.Unmarshal(configByte, &config); err != nil {
+		return nil, errors.WithSCode(code.ErrModerationConfig, err.Error())
+	}
+
+	if (config.InputsConfig == nil || !config.InputsConfig.Enabled) && (config.OutputsConfig == nil || !config.OutputsConfig.Enabled) {
+		return nil, errors.WithCode(code.ErrModerationConfig, "At least one of inputs_config or outputs_config must be enabled")
+	}
+
+	switch ModerationType(sensitiveWordAvoidance.Type) {
+	case KEYWORDS:
+		if len(splitKeywords(config.Keywords)) == 0 {
+			return nil, errors.WithCode(code.ErrModerationConfig, "keywords is required")
+		}
+	case OPENAI_MODERATION:
+		if config.Provider == "" {
+			config.Provider = DEFAULT_PROVIDER
+		}
+
+		if config.Model == "" {
+			config.Model = DEFAULT_MODEL
+		}
+	case API:
+		if config.ApiUrl == "" {
+			return nil, errors.WithCode(code.ErrModerationConfig, "api_url is required")
+		}
+	default:
+		return nil, errors.WithCode(code.ErrModerationConfig, "moderation type %s is not supported", sensitiveWordAvoidance.Type)
+	}
+
+	if config.BufferSize <= 0 {
+		config.BufferSize = DEFAULT_BUFFER_SIZE
+	}
+
+	return &config, nil
+}
+
+func (m *moderation) InputsEnabled() bool {
+	return m.config.InputsConfig != nil && m.config.InputsConfig.Enabled
+}
+
+func (m *moderation) OutputsEnabled() bool {
+	return m.config.OutputsConfig != nil && m.config.OutputsConfig.Enabled
+}
+
+func (m *moderation) BufferSize() int {
+	return m.config.BufferSize
+}
+
+func (m *moderation) ModerationForInputs(ctx context.Context, inputs map[string]interface{}, query string) (*ModerationResult, error) {
+	if !m.InputsEnabled() {
+		return &ModerationResult{}, nil
+	}
+
+	return m.moderate(ctx, INPUT, m.config.InputsConfig, inputs, query)
+}
+
+func (m *moderation) ModerationForOutputs(ctx context.Context, text string) (*ModerationResult, error) {
+	if !m.OutputsEnabled() {
+		return &ModerationResult{}, nil
+	}
+
+	return m.moderate(ctx, OUTPUT, m.config.OutputsConfig, nil, text)
+}
+
+func (m *moderation) moderate(ctx context.Context, point ModerationPoint, presetConfig *ModerationPresetConfig, inputs map[string]interface{}, text string) (*ModerationResult, error) {
+	flagged, presetResponse, err := m.moderator.moderate(ctx, point, inputs, text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !flagged {
+		return &ModerationResult{}, nil
+	}
+
+	if presetResponse == "" {
+		presetResponse = presetConfig.PresetResponse
+	}
+
+	if presetResponse == "" {
+		presetResponse = DEFAULT_PRESET_RESPONSE
+	}
+
+	return &ModerationResult{
+		Flagged:        true,
+		PresetResponse: presetResponse,
+	}, nil
+}
@@ -0,0 +1,188 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+)
+
+type fakeQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	chunks []string
+	final  *biz_entity_base_stream_generator.LLMResult
+	err    error
+}
+
+func (q *fakeQueue) Push(event biz_entity_base_stream_generator.IQueueEvent) {
+	if chunk := chunkOfEvent(event); chunk != nil {
+		q.chunks = append(q.chunks, chunk.Delta.Message.Content.(string))
+	}
+
+	if replaceEvent, ok := event.(*biz_entity_base_stream_generator.QueueMessageReplaceEvent); ok {
+		q.chunks = append(q.chunks, "replace:"+replaceEvent.Text)
+	}
+}
+
+func (q *fakeQueue) Final(event biz_entity_base_stream_generator.IQueueEvent) {
+	q.final = event.(*biz_entity_base_stream_generator.QueueMessageEndEvent).LLMResult
+}
+
+func (q *fakeQueue) PushErr(err error) {
+	q.err = err
+}
+
+func (q *fakeQueue) Fork() biz_entity_base_stream_generator.IStreamGenerateQueue {
+	return q
+}
+
+type failingModeration struct {
+	IModeration
+}
+
+func (m *failingModeration) ModerationForOutputs(ctx context.Context, text string) (*ModerationResult, error) {
+	return nil, errors.New("moderation api unavailable")
+}
+
+func (m *failingModeration) BufferSize() int {
+	return 4
+}
+
+func newKeywordsModeration(t *testing.T, bufferSize int) IModeration {
+	t.Helper()
+
+	appModeration, err := NewModeration(context.Background(), nil, "", &biz_entity_app_config.SensitiveWordAvoidanceEntity{
+		Type: string(KEYWORDS),
+		Config: map[string]interface{}{
+			"keywords":       "Forbidden\n\n  secret ",
+			"inputs_config":  map[string]interface{}{"enabled": true, "preset_response": "input blocked"},
+			"outputs_config": map[string]interface{}{"enabled": true, "preset_response": "output blocked"},
+			"buffer_size":    bufferSize,
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return appModeration
+}
+
+func streamAnswer(queue biz_entity_base_stream_generator.IStreamGenerateQueue, deltas []string) {
+	for i, delta := range deltas {
+		queue.Push(&biz_entity_base_stream_generator.QueueLLMChunkEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+			Chunk: &biz_entity_base_stream_generator.LLMResultChunk{
+				Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+					Index:   i,
+					Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(delta),
+				},
+			},
+		})
+	}
+
+	queue.Final(&biz_entity_base_stream_generator.QueueMessageEndEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageEnd),
+		LLMResult: &biz_entity_base_stream_generator.LLMResult{
+			Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(strings.Join(deltas, "")),
+		},
+	})
+}
+
+func TestKeywordsModerationForInputs(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+	appModeration := newKeywordsModeration(t, 0)
+
+	result, err := appModeration.ModerationForInputs(context.Background(), map[string]interface{}{"name": "Luna"}, "hello")
+
+	if err != nil || result.Flagged {
+		t.Fatalf("expected pass, got %+v %v", result, err)
+	}
+
+	result, err = appModeration.ModerationForInputs(context.Background(), map[string]interface{}{"topic": "the SECRET plan"}, "hello")
+
+	if err != nil || !result.Flagged || result.PresetResponse != "input blocked" {
+		t.Fatalf("expected input blocked, got %+v %v", result, err)
+	}
+}
+
+func TestOutputModerationQueue(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	inner := &fakeQueue{}
+	streamAnswer(NewOutputModerationQueue(context.Background(), inner, newKeywordsModeration(t, 4)), []string{"all ", "good ", "here"})
+
+	if strings.Join(inner.chunks, "") != "all good here" || inner.final.Message.Content != "all good here" {
+		t.Fatalf("unexpected passed answer %q %q", inner.chunks, inner.final.Message.Content)
+	}
+
+	inner = &fakeQueue{}
+	streamAnswer(NewOutputModerationQueue(context.Background(), inner, newKeywordsModeration(t, 4)), []string{"fine ", "forb", "idden ", "words ", "more"})
+
+	// chunks of the windows which have passed are streamed, then the whole answer is replaced
+	if strings.Join(inner.chunks, "|") != "fine |forb|replace:output blocked" {
+		t.Fatalf("unexpected flagged chunks %q", inner.chunks)
+	}
+
+	if inner.final.Message.Content != "output blocked" {
+		t.Fatalf("unexpected flagged final answer %q", inner.final.Message.Content)
+	}
+
+	inner = &fakeQueue{}
+	streamAnswer(NewOutputModerationQueue(context.Background(), inner, newKeywordsModeration(t, 4)), []string{"secret ", "words"})
+
+	// nothing has been streamed when the first window is flagged, so the preset is streamed as the answer
+	if strings.Join(inner.chunks, "|") != "output blocked" {
+		t.Fatalf("unexpected chunks %q flagged in the first window", inner.chunks)
+	}
+
+	// the forked queue of the next agent round replaces the answer streamed by the previous round
+	inner = &fakeQueue{}
+	queue := NewOutputModerationQueue(context.Background(), inner, newKeywordsModeration(t, 4))
+	streamAnswer(queue, []string{"fine ", "answer"})
+	streamAnswer(queue.Fork(), []string{"secret ", "words"})
+
+	if strings.Join(inner.chunks, "|") != "fine |answer|replace:output blocked" {
+		t.Fatalf("unexpected chunks %q flagged in the forked queue", inner.chunks)
+	}
+}
+
+func TestOutputModerationQueueFailure(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	inner := &fakeQueue{}
+	streamAnswer(NewOutputModerationQueue(context.Background(), inner, &failingModeration{}), []string{"all ", "good ", "here"})
+
+	if inner.err != nil {
+		t.Fatalf("the answer is stopped by the failed moderation: %v", inner.err)
+	}
+
+	if strings.Join(inner.chunks, "") != "all good here" || inner.final.Message.Content != "all good here" {
+		t.Fatalf("unexpected answer %q %q without the moderation", inner.chunks, inner.final.Message.Content)
+	}
+}
+
+func TestParseModerationConfig(t *testing.T) {
+	if _, err := ParseModerationConfig(&biz_entity_app_config.SensitiveWordAvoidanceEntity{
+		Type:   string(KEYWORDS),
+		Config: map[string]interface{}{"keywords": "a", "inputs_config": map[string]interface{}{"enabled": false}},
+	}); err == nil {
+		t.Error("config without enabled inputs or outputs should be invalid")
+	}
+
+	if _, err := ParseModerationConfig(&biz_entity_app_config.SensitiveWordAvoidanceEntity{
+		Type:   "unknown",
+		Config: map[string]interface{}{"inputs_config": map[string]interface{}{"enabled": true}},
+	}); err == nil {
+		t.Error("unknown moderation type should be invalid")
+	}
+}
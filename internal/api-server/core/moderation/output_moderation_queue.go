@@ -0,0 +1,171 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package moderation
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_base_stream_generator "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/stream_base_generator"
+)
+
+// outputModerationQueue holds back the streamed llm chunks until every buffer_size characters of the answer
+// have passed the output moderation, once the answer is flagged the remaining chunks are dropped and
+// the preset response replaces the streamed answer and is saved instead.
+type outputModerationQueue struct {
+	biz_entity_base_stream_generator.IStreamGenerateQueue
+	ctx            context.Context
+	moderation     IModeration
+	buffer         []biz_entity_base_stream_generator.IQueueEvent
+	bufferRunes    int
+	text           strings.Builder
+	flagged        bool
+	presetResponse string
+	// streamed is shared by the forked queues, it tells whether any chunk of the answer has reached the client
+	streamed *bool
+}
+
+func NewOutputModerationQueue(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue, moderation IModeration) biz_entity_base_stream_generator.IStreamGenerateQueue {
+	return newOutputModerationQueue(ctx, queue, moderation, new(bool))
+}
+
+func newOutputModerationQueue(ctx context.Context, queue biz_entity_base_stream_generator.IStreamGenerateQueue, moderation IModeration, streamed *bool) *outputModerationQueue {
+	return &outputModerationQueue{
+		IStreamGenerateQueue: queue,
+		ctx:                  ctx,
+		moderation:           moderation,
+		streamed:             streamed,
+	}
+}
+
+func (q *outputModerationQueue) Push(event biz_entity_base_stream_generator.IQueueEvent) {
+	chunk := chunkOfEvent(event)
+
+	if chunk == nil {
+		q.IStreamGenerateQueue.Push(event)
+		return
+	}
+
+	if q.flagged {
+		return
+	}
+
+	if content, ok := chunk.Delta.Message.Content.(string); ok {
+		q.text.WriteString(content)
+		q.bufferRunes += utf8.RuneCountInString(content)
+	}
+
+	q.buffer = append(q.buffer, event)
+
+	if q.bufferRunes >= q.moderation.BufferSize() {
+		q.moderate()
+	}
+}
+
+func (q *outputModerationQueue) Final(event biz_entity_base_stream_generator.IQueueEvent) {
+	if !q.flagged && len(q.buffer) > 0 {
+		q.moderate()
+	}
+
+	if endEvent, ok := event.(*biz_entity_base_stream_generator.QueueMessageEndEvent); ok && q.flagged && endEvent.LLMResult != nil {
+		endEvent.LLMResult.Message = biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(q.presetResponse)
+	}
+
+	q.IStreamGenerateQueue.Final(event)
+}
+
+func (q *outputModerationQueue) Fork() biz_entity_base_stream_generator.IStreamGenerateQueue {
+	return newOutputModerationQueue(q.ctx, q.IStreamGenerateQueue.Fork(), q.moderation, q.streamed)
+}
+
+func (q *outputModerationQueue) moderate() {
+	result, err := q.moderation.ModerationForOutputs(q.ctx, q.text.String())
+
+	// the answer is let through when the moderation isn't available, as the inputs are
+	if err != nil {
+		log.Errorf("output moderation failed, the answer is streamed without it: %s", err.Error())
+		q.flush()
+		return
+	}
+
+	if result.Flagged {
+		q.flagged = true
+		q.presetResponse = result.PresetResponse
+
+		if *q.streamed {
+			q.IStreamGenerateQueue.Push(&biz_entity_base_stream_generator.QueueMessageReplaceEvent{
+				AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.MessageReplace),
+				Text:          q.presetResponse,
+			})
+		} else {
+			q.IStreamGenerateQueue.Push(q.presetEvent(q.buffer[0]))
+			*q.streamed = true
+		}
+		q.buffer = nil
+		return
+	}
+
+	q.flush()
+}
+
+func (q *outputModerationQueue) flush() {
+	for _, event := range q.buffer {
+		q.IStreamGenerateQueue.Push(event)
+	}
+
+	if len(q.buffer) > 0 {
+		*q.streamed = true
+	}
+
+	q.buffer = nil
+	q.bufferRunes = 0
+}
+
+// presetEvent streams the preset response as a single chunk of the same event type as the held back ones.
+func (q *outputModerationQueue) presetEvent(template biz_entity_base_stream_generator.IQueueEvent) biz_entity_base_stream_generator.IQueueEvent {
+	templateChunk := chunkOfEvent(template)
+
+	chunk := &biz_entity_base_stream_generator.LLMResultChunk{
+		ID:            templateChunk.ID,
+		Model:         templateChunk.Model,
+		PromptMessage: templateChunk.PromptMessage,
+		Delta: &biz_entity_base_stream_generator.LLMResultChunkDelta{
+			Index:   templateChunk.Delta.Index,
+			Message: biz_entity_chat_prompt_message.NewAssistantToolPromptMessage(q.presetResponse),
+		},
+	}
+
+	if _, ok := template.(*biz_entity_base_stream_generator.QueueAgentMessageEvent); ok {
+		return &biz_entity_base_stream_generator.QueueAgentMessageEvent{
+			AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.AgentMessage),
+			Chunk:         chunk,
+		}
+	}
+
+	return &biz_entity_base_stream_generator.QueueLLMChunkEvent{
+		AppQueueEvent: biz_entity_base_stream_generator.NewAppQueueEvent(biz_entity_base_stream_generator.LLMChunk),
+		Chunk:         chunk,
+	}
+}
+
+func chunkOfEvent(event biz_entity_base_stream_generator.IQueueEvent) *biz_entity_base_stream_generator.LLMResultChunk {
+	var chunk *biz_entity_base_stream_generator.LLMResultChunk
+
+	switch e := event.(type) {
+	case *biz_entity_base_stream_generator.QueueLLMChunkEvent:
+		chunk = e.Chunk
+	case *biz_entity_base_stream_generator.QueueAgentMessageEvent:
+		chunk = e.Chunk
+	}
+
+	if chunk == nil || chunk.Delta == nil || chunk.Delta.Message == nil {
+		return nil
+	}
+
+	return chunk
+}
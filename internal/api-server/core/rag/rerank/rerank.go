@@ -0,0 +1,97 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/dataset/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type IRerankRunner interface {
+	Run(ctx context.Context, query string, documents []*biz_entity.Document, scoreThreshold float64, topN int) ([]*biz_entity.Document, error)
+}
+
+type rerankModelRunner struct {
+	rerankModelInstance *biz_entity_provider_config.ModelIntegratedInstance
+	user                string
+}
+
+func NewRerankModelRunner(rerankModelInstance *biz_entity_provider_config.ModelIntegratedInstance, user string) IRerankRunner {
+	return &rerankModelRunner{
+		rerankModelInstance: rerankModelInstance,
+		user:                user,
+	}
+}
+
+// NewTenantRerankRunner returns the runner of the default rerank model of the tenant, nil is returned when the tenant
+// has no rerank model and the vector hits are used in their own order.
+func NewTenantRerankRunner(ctx context.Context, providerDomain *domain_service.ProviderDomain, tenantID string, user string) (IRerankRunner, error) {
+	rerankModelInstance, err := providerDomain.GetDefaultModelInstance(ctx, tenantID, common.RERANK)
+
+	if errors.IsCode(err, code.ErrDefaultModelNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRerankModelRunner(rerankModelInstance, user), nil
+}
+
+// Run reorders the documents hit by vector search by the relevance to query, documents with duplicated
+// content are merged. The relevance is set as the rerank score of the returned documents, whose score of the vector
+// search is kept, and scoreThreshold is compared with the relevance.
+func (r *rerankModelRunner) Run(ctx context.Context, query string, documents []*biz_entity.Document, scoreThreshold float64, topN int) ([]*biz_entity.Document, error) {
+	var (
+		docs            []string
+		uniqueDocuments []*biz_entity.Document
+	)
+
+	seen := make(map[string]struct{}, len(documents))
+
+	for _, document := range documents {
+		if _, ok := seen[document.PageContent]; ok {
+			continue
+		}
+		seen[document.PageContent] = struct{}{}
+		docs = append(docs, document.PageContent)
+		uniqueDocuments = append(uniqueDocuments, document)
+	}
+
+	caller := model_registry.NewModelRegisterCaller(r.rerankModelInstance.Model, string(common.RERANK), r.rerankModelInstance.Provider, r.rerankModelInstance.Credentials, r.rerankModelInstance.ModelTypeInstance)
+
+	rerankResult, err := caller.InvokeRerank(ctx, query, docs, scoreThreshold, topN, r.user)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rerankDocuments := make([]*biz_entity.Document, 0, len(rerankResult.Docs))
+
+	for _, rerankDoc := range rerankResult.Docs {
+		if rerankDoc.Index < 0 || rerankDoc.Index >= len(uniqueDocuments) {
+			continue
+		}
+		origin := uniqueDocuments[rerankDoc.Index]
+		rerankDocuments = append(rerankDocuments, &biz_entity.Document{
+			PageContent: origin.PageContent,
+			Vector:      origin.Vector,
+			Metadata:    origin.Metadata,
+			Provider:    origin.Provider,
+			Score:       origin.Score,
+			RerankScore: float32(rerankDoc.Score),
+		})
+	}
+
+	return rerankDocuments, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package rerank
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers/jina/rerank"
+	"github.com/lunarianss/Luna/internal/api-server/domain/dataset/entity/biz_entity"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+)
+
+func TestRerankModelRunner(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	var captured struct {
+		Documents []string `json:"documents"`
+		TopN      int      `json:"top_n"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &captured)
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"results":[{"index":1,"relevance_score":0.88},{"index":0,"relevance_score":0.31}]}`)
+	}))
+	defer server.Close()
+
+	rerankModelInstance := &biz_entity_provider_config.ModelIntegratedInstance{
+		Model:       "jina-reranker-v2-base-multilingual",
+		Provider:    "jina",
+		Credentials: map[string]interface{}{"api_key": "stub-key", "base_url": server.URL},
+	}
+
+	documents := []*biz_entity.Document{
+		{PageContent: "How do I reset my password?", Metadata: map[string]string{"annotation_id": "a-1"}, Score: 0.83},
+		{PageContent: "How do I reset my password?", Metadata: map[string]string{"annotation_id": "a-1"}, Score: 0.83},
+		{PageContent: "I forgot my password", Metadata: map[string]string{"annotation_id": "a-2"}, Score: 0.79},
+	}
+
+	rerankDocuments, err := NewRerankModelRunner(rerankModelInstance, "account-1").Run(context.Background(), "forgot password", documents, 0, 2)
+
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// the duplicated content is sent once
+	if len(captured.Documents) != 2 || captured.TopN != 2 {
+		t.Errorf("unexpected request %+v", captured)
+	}
+
+	if len(rerankDocuments) != 2 {
+		t.Fatalf("expected 2 documents, got %d", len(rerankDocuments))
+	}
+
+	if rerankDocuments[0].Metadata["annotation_id"] != "a-2" || rerankDocuments[0].RerankScore != float32(0.88) || rerankDocuments[0].Score != float32(0.79) || rerankDocuments[1].Metadata["annotation_id"] != "a-1" {
+		t.Errorf("unexpected documents %+v, %+v", rerankDocuments[0], rerankDocuments[1])
+	}
+}
@@ -0,0 +1,536 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package structured_output
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// MAX_REF_DEPTH limits the $ref resolution of recursive schemas.
+const MAX_REF_DEPTH = 32
+
+var schemaTypes = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// SchemaError is the error of a json value which doesn't conform to the schema, Path is the json path of the value.
+type SchemaError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// SchemaErrors are the errors of all the values which don't conform to the schema.
+type SchemaErrors []*SchemaError
+
+func (e SchemaErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, schemaError := range e {
+		messages = append(messages, fmt.Sprintf("%s %s", schemaError.Path, schemaError.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Details returns the errors of every value to the client.
+func (e SchemaErrors) Details() interface{} {
+	return e
+}
+
+type validator struct {
+	root   map[string]interface{}
+	errors SchemaErrors
+}
+
+// Validate validates the value decoded by encoding/json against the schema. The subset of json schema accepted by
+// the structured outputs of the model services is supported: type, enum, const, properties, required,
+// additionalProperties, items, allOf/anyOf/oneOf, $ref to $defs or definitions and the length, range and pattern
+// keywords, the other keywords such as format are ignored.
+func Validate(schema map[string]interface{}, value interface{}) error {
+	v := &validator{root: schema}
+
+	v.validate(schema, value, "$", 0)
+
+	if len(v.errors) > 0 {
+		return v.errors
+	}
+
+	return nil
+}
+
+func (v *validator) addError(path string, format string, args ...interface{}) {
+	v.errors = append(v.errors, &SchemaError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// valid reports whether the value conforms to the schema without recording the errors.
+func (v *validator) valid(schema map[string]interface{}, value interface{}, path string, depth int) bool {
+	sub := &validator{root: v.root}
+	sub.validate(schema, value, path, depth)
+	return len(sub.errors) == 0
+}
+
+func (v *validator) validate(schema map[string]interface{}, value interface{}, path string, depth int) {
+	if ref, ok := schema["$ref"].(string); ok {
+		if depth >= MAX_REF_DEPTH {
+			v.addError(path, "exceeds the max depth of $ref")
+			return
+		}
+
+		refSchema, err := resolveRef(v.root, ref)
+
+		if err != nil {
+			v.addError(path, "%s", err.Error())
+			return
+		}
+
+		v.validate(refSchema, value, path, depth+1)
+		return
+	}
+
+	if types, ok := schemaTypeNames(schema["type"]); ok && len(types) > 0 {
+		matched := false
+
+		for _, t := range types {
+			if matchType(t, value) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			v.addError(path, "must be %s", strings.Join(types, " or "))
+			return
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		if !containsValue(enum, value) {
+			v.addError(path, "must be one of %s", formatValues(enum))
+		}
+	}
+
+	if constValue, ok := schema["const"]; ok {
+		if !reflect.DeepEqual(normalize(constValue), value) {
+			v.addError(path, "must be %v", constValue)
+		}
+	}
+
+	for _, subSchema := range schemaList(schema["allOf"]) {
+		v.validate(subSchema, value, path, depth+1)
+	}
+
+	if anyOf := schemaList(schema["anyOf"]); len(anyOf) > 0 {
+		matched := false
+
+		for _, subSchema := range anyOf {
+			if v.valid(subSchema, value, path, depth+1) {
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			v.addError(path, "must match any schema of anyOf")
+		}
+	}
+
+	if oneOf := schemaList(schema["oneOf"]); len(oneOf) > 0 {
+		matched := 0
+
+		for _, subSchema := range oneOf {
+			if v.valid(subSchema, value, path, depth+1) {
+				matched++
+			}
+		}
+
+		if matched != 1 {
+			v.addError(path, "must match exactly one schema of oneOf, %d matched", matched)
+		}
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		v.validateObject(schema, value, path, depth)
+	case []interface{}:
+		v.validateArray(schema, value, path, depth)
+	case string:
+		v.validateString(schema, value, path)
+	case float64:
+		v.validateNumber(schema, value, path)
+	}
+}
+
+func (v *validator) validateObject(schema map[string]interface{}, value map[string]interface{}, path string, depth int) {
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := value[name]; !ok {
+			v.addError(joinPath(path, name), "is required")
+		}
+	}
+
+	for name, propertyValue := range value {
+		if propertySchema, ok := properties[name].(map[string]interface{}); ok {
+			v.validate(propertySchema, propertyValue, joinPath(path, name), depth+1)
+			continue
+		}
+
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.addError(joinPath(path, name), "is not allowed")
+			}
+		case map[string]interface{}:
+			v.validate(additional, propertyValue, joinPath(path, name), depth+1)
+		}
+	}
+
+	if minProperties, ok := toFloat(schema["minProperties"]); ok && float64(len(value)) < minProperties {
+		v.addError(path, "must have at least %v properties", minProperties)
+	}
+
+	if maxProperties, ok := toFloat(schema["maxProperties"]); ok && float64(len(value)) > maxProperties {
+		v.addError(path, "must have at most %v properties", maxProperties)
+	}
+}
+
+func (v *validator) validateArray(schema map[string]interface{}, value []interface{}, path string, depth int) {
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range value {
+			v.validate(items, item, fmt.Sprintf("%s[%d]", path, i), depth+1)
+		}
+	}
+
+	if minItems, ok := toFloat(schema["minItems"]); ok && float64(len(value)) < minItems {
+		v.addError(path, "must have at least %v items", minItems)
+	}
+
+	if maxItems, ok := toFloat(schema["maxItems"]); ok && float64(len(value)) > maxItems {
+		v.addError(path, "must have at most %v items", maxItems)
+	}
+
+	if unique, ok := schema["uniqueItems"].(bool); ok && unique {
+		for i := range value {
+			if containsValue(value[:i], value[i]) {
+				v.addError(fmt.Sprintf("%s[%d]", path, i), "is duplicated")
+			}
+		}
+	}
+}
+
+func (v *validator) validateString(schema map[string]interface{}, value string, path string) {
+	length := float64(utf8.RuneCountInString(value))
+
+	if minLength, ok := toFloat(schema["minLength"]); ok && length < minLength {
+		v.addError(path, "must be at least %v characters", minLength)
+	}
+
+	if maxLength, ok := toFloat(schema["maxLength"]); ok && length > maxLength {
+		v.addError(path, "must be at most %v characters", maxLength)
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := regexp.Compile(pattern)
+
+		if err != nil {
+			v.addError(path, "has an invalid pattern %s", pattern)
+			return
+		}
+
+		if !re.MatchString(value) {
+			v.addError(path, "must match the pattern %s", pattern)
+		}
+	}
+}
+
+func (v *validator) validateNumber(schema map[string]interface{}, value float64, path string) {
+	if minimum, ok := toFloat(schema["minimum"]); ok && value < minimum {
+		v.addError(path, "must be greater than or equal to %v", minimum)
+	}
+
+	if maximum, ok := toFloat(schema["maximum"]); ok && value > maximum {
+		v.addError(path, "must be less than or equal to %v", maximum)
+	}
+
+	if minimum, ok := toFloat(schema["exclusiveMinimum"]); ok && value <= minimum {
+		v.addError(path, "must be greater than %v", minimum)
+	}
+
+	if maximum, ok := toFloat(schema["exclusiveMaximum"]); ok && value >= maximum {
+		v.addError(path, "must be less than %v", maximum)
+	}
+
+	if multipleOf, ok := toFloat(schema["multipleOf"]); ok && multipleOf > 0 {
+		if quotient := value / multipleOf; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			v.addError(path, "must be a multiple of %v", multipleOf)
+		}
+	}
+}
+
+// CheckSchema checks the schema is a json schema of an object which is supported by Validate.
+func CheckSchema(schema map[string]interface{}) error {
+	if len(schema) == 0 {
+		return fmt.Errorf("schema is empty")
+	}
+
+	if types, _ := schemaTypeNames(schema["type"]); len(types) != 1 || types[0] != "object" {
+		return fmt.Errorf("type of the root schema must be object")
+	}
+
+	return checkSchema(schema, schema, "$", 0)
+}
+
+func checkSchema(root, schema map[string]interface{}, path string, depth int) error {
+	if depth >= MAX_REF_DEPTH {
+		return fmt.Errorf("%s exceeds the max depth of schema", path)
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		if _, err := resolveRef(root, ref); err != nil {
+			return fmt.Errorf("%s %s", path, err.Error())
+		}
+	}
+
+	if schemaType, ok := schema["type"]; ok {
+		types, ok := schemaTypeNames(schemaType)
+
+		if !ok {
+			return fmt.Errorf("%s has an invalid type %v", path, schemaType)
+		}
+
+		for _, t := range types {
+			if !containsString(schemaTypes, t) {
+				return fmt.Errorf("%s has an unknown type %s", path, t)
+			}
+		}
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s has an invalid pattern %s", path, pattern)
+		}
+	}
+
+	if required, ok := schema["required"]; ok {
+		if list, ok := required.([]interface{}); !ok || len(stringList(list)) != len(list) {
+			return fmt.Errorf("%s required must be an array of strings", path)
+		}
+	}
+
+	if properties, ok := schema["properties"]; ok {
+		propertiesMap, ok := properties.(map[string]interface{})
+
+		if !ok {
+			return fmt.Errorf("%s properties must be an object", path)
+		}
+
+		for name, property := range propertiesMap {
+			propertySchema, ok := property.(map[string]interface{})
+
+			if !ok {
+				return fmt.Errorf("%s schema must be an object", joinPath(path, name))
+			}
+
+			if err := checkSchema(root, propertySchema, joinPath(path, name), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	subSchemas := map[string]interface{}{
+		"items":                schema["items"],
+		"additionalProperties": schema["additionalProperties"],
+	}
+
+	for keyword, subSchema := range subSchemas {
+		if subSchemaMap, ok := subSchema.(map[string]interface{}); ok {
+			if err := checkSchema(root, subSchemaMap, fmt.Sprintf("%s.%s", path, keyword), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range []string{"allOf", "anyOf", "oneOf"} {
+		for i, subSchema := range schemaList(schema[keyword]) {
+			if err := checkSchema(root, subSchema, fmt.Sprintf("%s.%s[%d]", path, keyword, i), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, keyword := range []string{"$defs", "definitions"} {
+		definitions, _ := schema[keyword].(map[string]interface{})
+
+		for name, definition := range definitions {
+			definitionSchema, ok := definition.(map[string]interface{})
+
+			if !ok {
+				return fmt.Errorf("%s.%s.%s schema must be an object", path, keyword, name)
+			}
+
+			if err := checkSchema(root, definitionSchema, fmt.Sprintf("%s.%s.%s", path, keyword, name), depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveRef resolves the local reference such as #/$defs/address against the root schema.
+func resolveRef(root map[string]interface{}, ref string) (map[string]interface{}, error) {
+	if ref == "#" {
+		return root, nil
+	}
+
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only supports local $ref, got %s", ref)
+	}
+
+	var current interface{} = root
+
+	for _, token := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		currentMap, ok := current.(map[string]interface{})
+
+		if !ok {
+			return nil, fmt.Errorf("$ref %s is not found", ref)
+		}
+
+		if current, ok = currentMap[token]; !ok {
+			return nil, fmt.Errorf("$ref %s is not found", ref)
+		}
+	}
+
+	schema, ok := current.(map[string]interface{})
+
+	if !ok {
+		return nil, fmt.Errorf("$ref %s is not a schema", ref)
+	}
+
+	return schema, nil
+}
+
+func matchType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	case "integer":
+		number, ok := value.(float64)
+		return ok && number == math.Trunc(number)
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "null":
+		return value == nil
+	default:
+		return false
+	}
+}
+
+func schemaTypeNames(schemaType interface{}) ([]string, bool) {
+	switch t := schemaType.(type) {
+	case nil:
+		return nil, true
+	case string:
+		return []string{t}, true
+	case []interface{}:
+		types := stringList(t)
+		return types, len(types) == len(t)
+	default:
+		return nil, false
+	}
+}
+
+func schemaList(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	schemas := make([]map[string]interface{}, 0, len(list))
+
+	for _, item := range list {
+		if schema, ok := item.(map[string]interface{}); ok {
+			schemas = append(schemas, schema)
+		}
+	}
+	return schemas
+}
+
+func stringList(value interface{}) []string {
+	list, _ := value.([]interface{})
+	strs := make([]string, 0, len(list))
+
+	for _, item := range list {
+		if str, ok := item.(string); ok {
+			strs = append(strs, str)
+		}
+	}
+	return strs
+}
+
+func containsString(list []string, str string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if reflect.DeepEqual(normalize(item), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize converts the numbers of the schema built in go to float64 as the values decoded by encoding/json.
+func normalize(value interface{}) interface{} {
+	if number, ok := toFloat(value); ok {
+		return number
+	}
+	return value
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func formatValues(values []interface{}) string {
+	strs := make([]string, 0, len(values))
+
+	for _, value := range values {
+		strs = append(strs, fmt.Sprintf("%v", value))
+	}
+	return strings.Join(strs, ", ")
+}
+
+func joinPath(path, name string) string {
+	return fmt.Sprintf("%s.%s", path, name)
+}
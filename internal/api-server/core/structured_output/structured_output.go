@@ -0,0 +1,142 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package structured_output
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const (
+	// MAX_REPAIR_ATTEMPTS is how many times the model is asked to repair an answer which doesn't conform to the schema
+	MAX_REPAIR_ATTEMPTS = 2
+
+	RESPONSE_FORMAT_PARAMETER = "response_format"
+	JSON_SCHEMA_PARAMETER     = "json_schema"
+	JSON_SCHEMA_FORMAT        = "json_schema"
+)
+
+// SupportsJSONSchema reports whether the model takes the schema natively, that is the response_format parameter
+// of the model has the json_schema option and there is a json_schema parameter to carry the schema.
+func SupportsJSONSchema(modelSchema *biz_entity_model.AIModelStaticConfiguration) bool {
+	if modelSchema == nil {
+		return false
+	}
+
+	var responseFormat, jsonSchema bool
+
+	for _, rule := range modelSchema.ParameterRules {
+		switch rule.Name {
+		case RESPONSE_FORMAT_PARAMETER:
+			responseFormat = slices.Contains(rule.Options, JSON_SCHEMA_FORMAT)
+		case JSON_SCHEMA_PARAMETER:
+			jsonSchema = true
+		}
+	}
+
+	return responseFormat && jsonSchema
+}
+
+// ModelParameters returns a copy of the parameters which passes the schema natively through response_format.
+func ModelParameters(parameters map[string]interface{}, structuredOutput *biz_entity_app_config.StructuredOutputEntity) (map[string]interface{}, error) {
+	jsonSchema, err := json.Marshal(map[string]interface{}{
+		"name":   structuredOutput.Name,
+		"schema": structuredOutput.Schema,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	modelParameters := make(map[string]interface{}, len(parameters)+2)
+
+	for k, v := range parameters {
+		modelParameters[k] = v
+	}
+
+	modelParameters[RESPONSE_FORMAT_PARAMETER] = JSON_SCHEMA_FORMAT
+	modelParameters[JSON_SCHEMA_PARAMETER] = string(jsonSchema)
+
+	return modelParameters, nil
+}
+
+// WithInstruction appends the instruction of answering with the schema to the system prompt, a system prompt is
+// prepended when there isn't one.
+func WithInstruction(promptMessages []*biz_entity_chat_prompt_message.PromptMessage, structuredOutput *biz_entity_app_config.StructuredOutputEntity) ([]*biz_entity_chat_prompt_message.PromptMessage, error) {
+	schema, err := json.Marshal(structuredOutput.Schema)
+
+	if err != nil {
+		return nil, err
+	}
+
+	instruction := fmt.Sprintf("You must answer with only a JSON value which conforms to the following JSON Schema, without any explanation or markdown code block.\nJSON Schema:\n%s", schema)
+
+	if len(promptMessages) > 0 && promptMessages[0].Role == biz_entity_chat_prompt_message.SYSTEM {
+		if content, ok := promptMessages[0].Content.(string); ok {
+			systemMessage := biz_entity_chat_prompt_message.NewSystemMessage(fmt.Sprintf("%s\n\n%s", content, instruction))
+			return append([]*biz_entity_chat_prompt_message.PromptMessage{systemMessage}, promptMessages[1:]...), nil
+		}
+	}
+
+	return append([]*biz_entity_chat_prompt_message.PromptMessage{biz_entity_chat_prompt_message.NewSystemMessage(instruction)}, promptMessages...), nil
+}
+
+// RepairInstruction is the user message which asks the model to correct the answer failed to validate.
+func RepairInstruction(validateErr error) string {
+	return fmt.Sprintf("Your previous answer doesn't conform to the JSON Schema: %s. Answer again with only the corrected JSON value, without any explanation or markdown code block.", validateErr.Error())
+}
+
+// Parse extracts the json of the answer and validates it against the schema, the answer of the models following
+// the prompt instruction may be wrapped in a markdown code block or surrounded by some explanation.
+func Parse(answer string, schema map[string]interface{}) (interface{}, error) {
+	var value interface{}
+
+	if err := json.Unmarshal([]byte(extractJSON(answer)), &value); err != nil {
+		return nil, SchemaErrors{{Path: "$", Message: fmt.Sprintf("is not valid json: %s", err.Error())}}
+	}
+
+	if err := Validate(schema, value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
+
+func extractJSON(answer string) string {
+	answer = strings.TrimSpace(answer)
+
+	if start := strings.Index(answer, "```"); start >= 0 {
+		block := answer[start+3:]
+
+		// skip the language of the code block, e.g. ```json
+		if newline := strings.Index(block, "\n"); newline >= 0 {
+			block = block[newline+1:]
+		}
+
+		if end := strings.LastIndex(block, "```"); end >= 0 {
+			block = block[:end]
+		}
+
+		return strings.TrimSpace(block)
+	}
+
+	if strings.HasPrefix(answer, "{") || strings.HasPrefix(answer, "[") {
+		return answer
+	}
+
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+
+	if start >= 0 && end > start {
+		return answer[start : end+1]
+	}
+
+	return answer
+}
@@ -0,0 +1,155 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package structured_output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	biz_entity_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	biz_entity_model "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider/model_provider"
+)
+
+const personSchema = `{
+	"type": "object",
+	"properties": {
+		"name": {"type": "string", "minLength": 1},
+		"age": {"type": "integer", "minimum": 0},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"role": {"enum": ["admin", "user"]},
+		"address": {"$ref": "#/$defs/address"}
+	},
+	"required": ["name", "age"],
+	"additionalProperties": false,
+	"$defs": {
+		"address": {"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}
+	}
+}`
+
+func decodeSchema(t *testing.T, schema string) map[string]interface{} {
+	t.Helper()
+
+	var decoded map[string]interface{}
+
+	if err := json.Unmarshal([]byte(schema), &decoded); err != nil {
+		t.Fatalf("failed to decode schema: %v", err)
+	}
+	return decoded
+}
+
+func TestParse(t *testing.T) {
+	schema := decodeSchema(t, personSchema)
+
+	if err := CheckSchema(schema); err != nil {
+		t.Fatalf("CheckSchema() error = %v", err)
+	}
+
+	answer := "Here you are:\n```json\n{\"name\": \"luna\", \"age\": 3, \"tags\": [\"a\"], \"address\": {\"city\": \"sh\"}}\n```"
+
+	value, err := Parse(answer, schema)
+
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if value.(map[string]interface{})["name"] != "luna" {
+		t.Errorf("Parse() = %v, want name luna", value)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	schema := decodeSchema(t, personSchema)
+
+	_, err := Parse(`{"age": 1.5, "tags": ["a", "b", "c"], "role": "root", "address": {}, "extra": true}`, schema)
+
+	schemaErrors, ok := err.(SchemaErrors)
+
+	if !ok {
+		t.Fatalf("Parse() error = %v, want SchemaErrors", err)
+	}
+
+	for _, want := range []string{"$.name is required", "$.age must be integer", "$.tags must have at most 2 items", "$.role must be one of admin, user", "$.address.city is required", "$.extra is not allowed"} {
+		if !strings.Contains(schemaErrors.Error(), want) {
+			t.Errorf("Parse() error = %v, want %q", schemaErrors, want)
+		}
+	}
+
+	if _, err := Parse("not a json", schema); err == nil || !strings.Contains(err.Error(), "is not valid json") {
+		t.Errorf("Parse() error = %v, want invalid json", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	for _, schema := range []string{
+		`{}`,
+		`{"type": "array"}`,
+		`{"type": "object", "properties": {"name": {"type": "text"}}}`,
+		`{"type": "object", "properties": {"name": {"type": "string", "pattern": "("}}}`,
+		`{"type": "object", "properties": {"name": {"$ref": "#/$defs/missing"}}}`,
+	} {
+		if err := CheckSchema(decodeSchema(t, schema)); err == nil {
+			t.Errorf("CheckSchema(%s) error = nil, want error", schema)
+		}
+	}
+}
+
+func TestModelParameters(t *testing.T) {
+	modelSchema := &biz_entity_model.AIModelStaticConfiguration{
+		ParameterRules: []*biz_entity_model.ParameterRule{
+			{Name: RESPONSE_FORMAT_PARAMETER, Options: []string{"text", "json_object", "json_schema"}},
+			{Name: JSON_SCHEMA_PARAMETER},
+		},
+	}
+
+	if !SupportsJSONSchema(modelSchema) {
+		t.Fatalf("SupportsJSONSchema() = false, want true")
+	}
+
+	if SupportsJSONSchema(&biz_entity_model.AIModelStaticConfiguration{}) {
+		t.Errorf("SupportsJSONSchema() = true, want false")
+	}
+
+	structuredOutput := &biz_entity_app_config.StructuredOutputEntity{Name: "person", Schema: decodeSchema(t, personSchema)}
+	parameters := map[string]interface{}{"temperature": 0.5}
+
+	modelParameters, err := ModelParameters(parameters, structuredOutput)
+
+	if err != nil {
+		t.Fatalf("ModelParameters() error = %v", err)
+	}
+
+	if modelParameters[RESPONSE_FORMAT_PARAMETER] != JSON_SCHEMA_FORMAT || !strings.Contains(modelParameters[JSON_SCHEMA_PARAMETER].(string), `"name":"person"`) {
+		t.Errorf("ModelParameters() = %v", modelParameters)
+	}
+
+	if _, ok := parameters[RESPONSE_FORMAT_PARAMETER]; ok {
+		t.Errorf("ModelParameters() modified the parameters of the app")
+	}
+}
+
+func TestWithInstruction(t *testing.T) {
+	structuredOutput := &biz_entity_app_config.StructuredOutputEntity{Name: "person", Schema: decodeSchema(t, personSchema)}
+
+	promptMessages, err := WithInstruction([]*biz_entity_chat_prompt_message.PromptMessage{
+		biz_entity_chat_prompt_message.NewSystemMessage("You are a helpful assistant."),
+		biz_entity_chat_prompt_message.NewUserMessage("who are you"),
+	}, structuredOutput)
+
+	if err != nil {
+		t.Fatalf("WithInstruction() error = %v", err)
+	}
+
+	if len(promptMessages) != 2 || !strings.HasPrefix(promptMessages[0].Content.(string), "You are a helpful assistant.\n\n") || !strings.Contains(promptMessages[0].Content.(string), "JSON Schema") {
+		t.Errorf("WithInstruction() = %v", promptMessages)
+	}
+
+	promptMessages, _ = WithInstruction([]*biz_entity_chat_prompt_message.PromptMessage{biz_entity_chat_prompt_message.NewUserMessage("who are you")}, structuredOutput)
+
+	if len(promptMessages) != 2 || promptMessages[0].Role != biz_entity_chat_prompt_message.SYSTEM {
+		t.Errorf("WithInstruction() = %v, want a system message prepended", promptMessages)
+	}
+}
@@ -0,0 +1,293 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	// MAX_RESPONSE_SIZE is the max size of the api response, the larger responses are truncated
+	MAX_RESPONSE_SIZE = 1 << 20
+	// DEFAULT_API_KEY_HEADER is the header of the api key when the provider doesn't name one
+	DEFAULT_API_KEY_HEADER = "Authorization"
+	// DEFAULT_API_KEY_QUERY is the query parameter of the api key when the provider doesn't name one
+	DEFAULT_API_KEY_QUERY = "key"
+)
+
+func init() {
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(NewApiTool())
+}
+
+// ApiTool invokes the operations of the api providers, the operation is described by the api bundle of the tool
+// runtime so that one registry serves the tools of all the providers.
+type ApiTool struct {
+	client *http.Client
+}
+
+var _ tool_registry.IToolCallRegistry = (*ApiTool)(nil)
+
+func NewApiTool() *ApiTool {
+	return &ApiTool{
+		client: newClient(time.Duration(60) * time.Second),
+	}
+}
+
+func (at *ApiTool) Register() string {
+	return tool_registry.API_TOOL_REGISTRY
+}
+
+func (at *ApiTool) Invoke(ctx context.Context, userID string, toolParameters []byte, toolRuntime *biz_entity.ToolRuntimeConfiguration) ([]*biz_entity.ToolInvokeMessage, error) {
+	bundle := toolRuntime.ApiBundle
+
+	if bundle == nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "api bundle of tool %s is not found", toolRuntime.Identity.Name)
+	}
+
+	parameters := make(map[string]any)
+
+	if len(bytes.TrimSpace(toolParameters)) != 0 {
+		if err := json.Unmarshal(toolParameters, &parameters); err != nil {
+			return nil, errors.WithCode(code.ErrToolParameter, "parse parameter: %s, error %+v", string(toolParameters), err.Error())
+		}
+	}
+
+	req, err := at.buildRequest(ctx, bundle, parameters, toolRuntime.Credentials)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("invoke api tool %s: %s %s", bundle.OperationID, req.Method, req.URL.Redacted())
+
+	response, err := at.client.Do(req)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "api tool %s request failed: %s", bundle.OperationID, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, MAX_RESPONSE_SIZE))
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "api tool %s response read failed: %s", bundle.OperationID, err.Error())
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, errors.WithCode(code.ErrInvokeTool, "api tool %s returned status %d: %s", bundle.OperationID, response.StatusCode, string(body))
+	}
+
+	return at.convertResponse(toolRuntime, response, body), nil
+}
+
+func (at *ApiTool) buildRequest(ctx context.Context, bundle *biz_entity.ApiToolBundle, parameters map[string]any, credentials map[string]any) (*http.Request, error) {
+	var (
+		path    = bundle.Path
+		query   = url.Values{}
+		headers = http.Header{}
+		cookies []*http.Cookie
+		body    = make(map[string]any)
+	)
+
+	for _, parameter := range bundle.Parameters {
+		value, ok := parameters[parameter.Name]
+
+		if !ok || value == nil {
+			value = parameter.Default
+		}
+
+		if value == nil {
+			if parameter.Required {
+				return nil, errors.WithCode(code.ErrToolParameter, "parameter %s of tool %s is required", parameter.Name, bundle.OperationID)
+			}
+			continue
+		}
+
+		switch bundle.Locations[parameter.Name] {
+		case biz_entity.PathLocation:
+			path = strings.ReplaceAll(path, "{"+parameter.Name+"}", url.PathEscape(stringify(value)))
+		case biz_entity.QueryLocation:
+			query.Set(parameter.Name, stringify(value))
+		case biz_entity.HeaderLocation:
+			headers.Set(parameter.Name, stringify(value))
+		case biz_entity.CookieLocation:
+			cookies = append(cookies, &http.Cookie{Name: parameter.Name, Value: stringify(value)})
+		case biz_entity.BodyLocation:
+			body[parameter.Name] = value
+		}
+	}
+
+	if err := authorize(biz_entity.ApiProviderCredentialsFromMap(credentials), headers, query); err != nil {
+		return nil, err
+	}
+
+	requestBody, contentType, err := encodeBody(bundle.BodyContentType, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endpointUrl := bundle.ServerURL + path
+
+	if len(query) != 0 {
+		endpointUrl += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, bundle.Method, endpointUrl, requestBody)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "api tool %s request is invalid: %s", bundle.OperationID, err.Error())
+	}
+
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	return req, nil
+}
+
+func authorize(credentials *biz_entity.ApiProviderCredentials, headers http.Header, query url.Values) error {
+	switch credentials.AuthType {
+	case biz_entity.ApiAuthNone:
+		return nil
+	case biz_entity.ApiAuthKeyInHeader:
+		name := credentials.APIKeyName
+
+		if name == "" {
+			name = DEFAULT_API_KEY_HEADER
+		}
+
+		headers.Set(name, strings.TrimSpace(credentials.APIKeyPrefix+" "+credentials.APIKeyValue))
+	case biz_entity.ApiAuthKeyInQuery:
+		name := credentials.APIKeyName
+
+		if name == "" {
+			name = DEFAULT_API_KEY_QUERY
+		}
+
+		query.Set(name, credentials.APIKeyValue)
+	case biz_entity.ApiAuthBearerHeader:
+		headers.Set("Authorization", "Bearer "+credentials.APIKeyValue)
+	default:
+		return errors.WithCode(code.ErrInvokeTool, "auth type %s of the api provider is not supported", credentials.AuthType)
+	}
+
+	return nil
+}
+
+// encodeBody encodes the body parameters in the content type of the operation.
+func encodeBody(contentType string, body map[string]any) (io.Reader, string, error) {
+	if contentType == "" || len(body) == 0 {
+		return nil, "", nil
+	}
+
+	switch contentType {
+	case "application/x-www-form-urlencoded":
+		form := url.Values{}
+
+		for name, value := range body {
+			form.Set(name, stringify(value))
+		}
+
+		return strings.NewReader(form.Encode()), contentType, nil
+	case "multipart/form-data":
+		var buffer bytes.Buffer
+		writer := multipart.NewWriter(&buffer)
+
+		for name, value := range body {
+			if err := writer.WriteField(name, stringify(value)); err != nil {
+				return nil, "", errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+			}
+		}
+
+		if err := writer.Close(); err != nil {
+			return nil, "", errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+
+		return &buffer, writer.FormDataContentType(), nil
+	}
+
+	// the arrays and objects are described as strings to the llm, they are sent as json when the string is json
+	for name, value := range body {
+		if text, ok := value.(string); ok && (strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[")) {
+			var decoded any
+
+			if err := json.Unmarshal([]byte(text), &decoded); err == nil {
+				body[name] = decoded
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(body)
+
+	if err != nil {
+		return nil, "", errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	return bytes.NewReader(encoded), contentType, nil
+}
+
+// convertResponse converts the json responses into json messages and the images into blob messages which are
+// saved as tool files, the other responses are passed to the llm as text.
+func (at *ApiTool) convertResponse(toolRuntime *biz_entity.ToolRuntimeConfiguration, response *http.Response, body []byte) []*biz_entity.ToolInvokeMessage {
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+
+	switch {
+	case len(body) == 0:
+		return []*biz_entity.ToolInvokeMessage{{Type: biz_entity.TEXT, Message: fmt.Sprintf("the request succeeded with status %d and an empty response", response.StatusCode)}}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return []*biz_entity.ToolInvokeMessage{{Type: biz_entity.JSON, Message: body}}
+	case strings.HasPrefix(mediaType, "image/"):
+		return []*biz_entity.ToolInvokeMessage{toolRuntime.CreateBlobMessage(body, map[string]any{"mime_type": mediaType}, "image")}
+	}
+
+	return []*biz_entity.ToolInvokeMessage{{Type: biz_entity.TEXT, Message: string(body)}}
+}
+
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		// json numbers are decoded as float64, the integers shouldn't be formatted in the exponent form
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool, int, int64:
+		return fmt.Sprint(v)
+	}
+
+	encoded, err := json.Marshal(value)
+
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+
+	return string(encoded)
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+func newToolRuntime(t *testing.T, serverURL string, operationID string, credentials *biz_entity.ApiProviderCredentials) *biz_entity.ToolRuntimeConfiguration {
+	_, bundles, err := ParseSchema([]byte(petStoreOpenAPI))
+
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	for _, bundle := range bundles {
+		if bundle.OperationID == operationID {
+			bundle.ServerURL = serverURL
+
+			return &biz_entity.ToolRuntimeConfiguration{
+				ToolStaticConfiguration: &biz_entity.ToolStaticConfiguration{
+					Identity:   &biz_entity.ToolIdentity{Name: operationID, Provider: "petstore"},
+					Parameters: bundle.Parameters,
+				},
+				Credentials:  credentials.ToMap(),
+				ProviderType: biz_entity.ToolProviderTypeAPI,
+				ApiBundle:    bundle,
+			}
+		}
+	}
+
+	t.Fatalf("operation %s is not found", operationID)
+	return nil
+}
+
+// allowLoopback lets the tools reach the test servers listening on the loopback address.
+func allowLoopback(t *testing.T) {
+	AllowPrivateNetwork(true)
+	t.Cleanup(func() { AllowPrivateNetwork(false) })
+}
+
+func TestApiToolInvoke(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+	allowLoopback(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			if r.URL.Path != "/pets/1000000" || r.URL.Query().Get("verbose") != "true" || r.Header.Get("X-Api-Key") != "Token secret" {
+				t.Errorf("unexpected request %s %s with key %s", r.Method, r.URL.String(), r.Header.Get("X-Api-Key"))
+			}
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			io.WriteString(w, `{"id":1000000,"name":"Tom"}`)
+		case "POST":
+			var body map[string]any
+
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || r.URL.Query().Get("key") != "secret" {
+				t.Errorf("unexpected request body %v or query %s", err, r.URL.RawQuery)
+			}
+
+			if tags, _ := body["tags"].([]any); len(tags) != 2 || body["name"] != "Tom" || body["kind"] != "cat" {
+				t.Errorf("unexpected request body %v", body)
+			}
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, "created")
+		}
+	}))
+	defer server.Close()
+
+	headerCredentials := &biz_entity.ApiProviderCredentials{AuthType: biz_entity.ApiAuthKeyInHeader, APIKeyName: "X-Api-Key", APIKeyPrefix: "Token", APIKeyValue: "secret"}
+	toolRuntime := newToolRuntime(t, server.URL, "getPet", headerCredentials)
+
+	toolIns, err := tool_registry.ToolRuntimeRegistry.Acquire(tool_registry.API_TOOL_REGISTRY)
+
+	if err != nil {
+		t.Fatalf("api tool is not registered: %v", err)
+	}
+
+	messages, err := toolIns.Invoke(context.Background(), "user-1", []byte(`{"petId": 1000000, "verbose": true}`), toolRuntime)
+
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+
+	if len(messages) != 1 || messages[0].Type != biz_entity.JSON || string(messages[0].Message.([]byte)) != `{"id":1000000,"name":"Tom"}` {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+
+	queryCredentials := &biz_entity.ApiProviderCredentials{AuthType: biz_entity.ApiAuthKeyInQuery, APIKeyValue: "secret"}
+	toolRuntime = newToolRuntime(t, server.URL, "post_pets", queryCredentials)
+
+	messages, err = toolIns.Invoke(context.Background(), "user-1", []byte(`{"name": "Tom", "kind": "cat", "tags": "[\"small\", \"black\"]"}`), toolRuntime)
+
+	if err != nil {
+		t.Fatalf("Invoke() error = %v", err)
+	}
+
+	if len(messages) != 1 || messages[0].Type != biz_entity.TEXT || messages[0].Message != "created" {
+		t.Errorf("unexpected messages %+v", messages)
+	}
+}
+
+func TestApiToolInvokeError(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+	allowLoopback(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("unexpected authorization %s", r.Header.Get("Authorization"))
+		}
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"message":"pet not found"}`)
+	}))
+	defer server.Close()
+
+	bearerCredentials := &biz_entity.ApiProviderCredentials{AuthType: biz_entity.ApiAuthBearerHeader, APIKeyValue: "secret"}
+	toolRuntime := newToolRuntime(t, server.URL, "getPet", bearerCredentials)
+
+	if _, err := NewApiTool().Invoke(context.Background(), "user-1", []byte(`{"petId": 1}`), toolRuntime); !errors.IsCode(err, code.ErrInvokeTool) {
+		t.Errorf("Invoke() error = %v, want ErrInvokeTool", err)
+	}
+
+	if _, err := NewApiTool().Invoke(context.Background(), "user-1", []byte(`{}`), toolRuntime); !errors.IsCode(err, code.ErrToolParameter) {
+		t.Errorf("Invoke() without the path parameter error = %v, want ErrToolParameter", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	allowPrivateNetwork atomic.Bool
+
+	// nonPublicPrefixes are the special purpose ranges besides the loopback, private, link local, multicast and
+	// unspecified addresses
+	nonPublicPrefixes = []netip.Prefix{
+		netip.MustParsePrefix("0.0.0.0/8"),
+		netip.MustParsePrefix("100.64.0.0/10"),
+		netip.MustParsePrefix("192.0.0.0/24"),
+		netip.MustParsePrefix("198.18.0.0/15"),
+		netip.MustParsePrefix("240.0.0.0/4"),
+		netip.MustParsePrefix("64:ff9b::/96"),
+	}
+)
+
+// AllowPrivateNetwork lets the api tools reach the non public addresses, the schema urls and the servers are set by
+// the tenants so that they are kept off the network of the server unless it is allowed.
+func AllowPrivateNetwork(allow bool) {
+	allowPrivateNetwork.Store(allow)
+}
+
+// newClient returns the client of the schema urls and the api servers, the addresses are checked after the host is
+// resolved, so the redirects and the hosts resolved to a non public address are rejected as well. The proxies of
+// the environment are not used, the address checked would be the proxy's otherwise.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   controlAddress,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+	}
+}
+
+func controlAddress(network, address string, c syscall.RawConn) error {
+	if allowPrivateNetwork.Load() {
+		return nil
+	}
+
+	addrPort, err := netip.ParseAddrPort(address)
+
+	if err != nil {
+		return fmt.Errorf("address %s is not an ip address: %w", address, err)
+	}
+
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("address %s is not public", addrPort.Addr())
+	}
+
+	return nil
+}
+
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,75 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{addr: "93.184.216.34", want: true},
+		{addr: "2606:2800:220:1:248:1893:25c8:1946", want: true},
+		{addr: "127.0.0.1"},
+		{addr: "::1"},
+		{addr: "10.1.2.3"},
+		{addr: "172.16.0.1"},
+		{addr: "192.168.1.1"},
+		{addr: "169.254.169.254"},
+		{addr: "100.64.0.1"},
+		{addr: "0.0.0.0"},
+		{addr: "::"},
+		{addr: "fc00::1"},
+		{addr: "fe80::1"},
+		{addr: "::ffff:127.0.0.1"},
+		{addr: "::ffff:10.0.0.1"},
+		{addr: "224.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t.Errorf("isPublicAddr() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrivateNetworkRejected(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	requested := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested++
+	}))
+	defer server.Close()
+
+	if _, err := FetchSchema(context.Background(), server.URL+"/openapi.json"); !errors.IsCode(err, code.ErrApiToolSchema) {
+		t.Errorf("FetchSchema() error = %v of the loopback url, want ErrApiToolSchema", err)
+	}
+
+	toolRuntime := newToolRuntime(t, server.URL, "getPet", &biz_entity.ApiProviderCredentials{AuthType: biz_entity.ApiAuthNone})
+
+	if _, err := NewApiTool().Invoke(context.Background(), "user-1", []byte(`{"petId": 1}`), toolRuntime); !errors.IsCode(err, code.ErrInvokeTool) {
+		t.Errorf("Invoke() error = %v of the loopback server, want ErrInvokeTool", err)
+	}
+
+	if requested != 0 {
+		t.Errorf("the loopback server is requested %d times", requested)
+	}
+}
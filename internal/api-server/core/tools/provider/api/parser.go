@@ -0,0 +1,541 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// MAX_REF_DEPTH bounds the chained $ref lookups, so that a self referencing schema doesn't loop forever
+	MAX_REF_DEPTH = 16
+	// MAX_SCHEMA_SIZE is the max size of the schema fetched from an url
+	MAX_SCHEMA_SIZE = 2 << 20
+	// MAX_TOOL_NAME_LENGTH is the max length of the function names accepted by the llm providers
+	MAX_TOOL_NAME_LENGTH = 64
+)
+
+var (
+	methods          = []string{"get", "post", "put", "patch", "delete", "head", "options"}
+	bodyContentTypes = []string{"application/json", "application/x-www-form-urlencoded", "multipart/form-data"}
+	invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+)
+
+type document struct {
+	root map[string]any
+}
+
+// ParseSchema parses the openapi 3 or swagger 2 schema in json or yaml, each operation of the schema is converted
+// into a tool bundle whose parameters are the path, query, header and body parameters of the operation.
+func ParseSchema(schema []byte) (biz_entity.ApiSchemaType, []*biz_entity.ApiToolBundle, error) {
+	root, err := decodeSchema(schema)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc := &document{root: root}
+
+	var (
+		schemaType biz_entity.ApiSchemaType
+		serverURL  string
+	)
+
+	if version, _ := root["openapi"].(string); strings.HasPrefix(version, "3.") {
+		schemaType = biz_entity.OpenAPISchema
+		serverURL = doc.openAPIServerURL(root)
+	} else if version := fmt.Sprint(root["swagger"]); version == "2.0" || version == "2" {
+		schemaType = biz_entity.SwaggerSchema
+		serverURL = doc.swaggerServerURL()
+	} else {
+		return "", nil, errors.WithCode(code.ErrApiToolSchema, "only openapi 3 and swagger 2 schemas are supported")
+	}
+
+	paths := asMap(root["paths"])
+	pathNames := make([]string, 0, len(paths))
+
+	for pathName := range paths {
+		pathNames = append(pathNames, pathName)
+	}
+
+	sort.Strings(pathNames)
+
+	var (
+		bundles   []*biz_entity.ApiToolBundle
+		toolNames = make(map[string]bool)
+	)
+
+	for _, pathName := range pathNames {
+		pathItem := doc.resolve(paths[pathName])
+
+		for _, method := range methods {
+			operation := asMap(pathItem[method])
+
+			if operation == nil {
+				continue
+			}
+
+			operationServerURL := serverURL
+
+			if schemaType == biz_entity.OpenAPISchema {
+				if overrideURL := doc.openAPIServerURL(operation); overrideURL != "" {
+					operationServerURL = overrideURL
+				} else if overrideURL := doc.openAPIServerURL(pathItem); overrideURL != "" {
+					operationServerURL = overrideURL
+				}
+			}
+
+			if operationServerURL == "" {
+				return "", nil, errors.WithCode(code.ErrApiToolSchema, "server url of the operation %s %s is not found", strings.ToUpper(method), pathName)
+			}
+
+			if parsedURL, err := url.Parse(operationServerURL); err != nil || !parsedURL.IsAbs() {
+				return "", nil, errors.WithCode(code.ErrApiToolSchema, "server url %s is not an absolute url", operationServerURL)
+			}
+
+			bundle := doc.parseOperation(schemaType, method, pathName, pathItem, operation)
+			bundle.ServerURL = strings.TrimSuffix(operationServerURL, "/")
+			bundle.OperationID = uniqueToolName(toolNames, toolName(operation, method, pathName))
+
+			bundles = append(bundles, bundle)
+		}
+	}
+
+	if len(bundles) == 0 {
+		return "", nil, errors.WithCode(code.ErrApiToolSchema, "no operation is found in the schema")
+	}
+
+	return schemaType, bundles, nil
+}
+
+// FetchSchema downloads the schema of the url.
+func FetchSchema(ctx context.Context, schemaURL string) ([]byte, error) {
+	if parsedURL, err := url.Parse(schemaURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "schema url %s is not a http url", schemaURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", schemaURL, nil)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+	}
+
+	req.Header.Set("Accept", "application/json, application/yaml, text/yaml, */*")
+
+	response, err := newClient(time.Duration(10) * time.Second).Do(req)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "failed to fetch schema from %s: %s", schemaURL, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "failed to fetch schema from %s, status %d", schemaURL, response.StatusCode)
+	}
+
+	schema, err := io.ReadAll(io.LimitReader(response.Body, MAX_SCHEMA_SIZE+1))
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "failed to fetch schema from %s: %s", schemaURL, err.Error())
+	}
+
+	if len(schema) > MAX_SCHEMA_SIZE {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "schema of %s exceeds %d bytes", schemaURL, MAX_SCHEMA_SIZE)
+	}
+
+	return schema, nil
+}
+
+func decodeSchema(schema []byte) (map[string]any, error) {
+	trimmed := bytes.TrimSpace(schema)
+
+	if len(trimmed) == 0 {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "schema is empty")
+	}
+
+	var root map[string]any
+
+	if trimmed[0] == '{' {
+		if err := json.Unmarshal(trimmed, &root); err != nil {
+			return nil, errors.WithCode(code.ErrApiToolSchema, "schema is not valid json: %s", err.Error())
+		}
+		return root, nil
+	}
+
+	if err := yaml.Unmarshal(trimmed, &root); err != nil {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "schema is not valid yaml: %s", err.Error())
+	}
+
+	if root == nil {
+		return nil, errors.WithCode(code.ErrApiToolSchema, "schema is not an object")
+	}
+
+	return root, nil
+}
+
+// openAPIServerURL returns the first server url of the node, the server variables are replaced by their defaults.
+func (d *document) openAPIServerURL(node map[string]any) string {
+	servers := asSlice(node["servers"])
+
+	if len(servers) == 0 {
+		return ""
+	}
+
+	server := asMap(servers[0])
+	serverURL, _ := server["url"].(string)
+
+	for name, variable := range asMap(server["variables"]) {
+		serverURL = strings.ReplaceAll(serverURL, "{"+name+"}", fmt.Sprint(asMap(variable)["default"]))
+	}
+
+	return serverURL
+}
+
+func (d *document) swaggerServerURL() string {
+	host, _ := d.root["host"].(string)
+
+	if host == "" {
+		return ""
+	}
+
+	scheme := "https"
+
+	if schemes := asSlice(d.root["schemes"]); len(schemes) > 0 && !containsValue(schemes, "https") {
+		scheme = fmt.Sprint(schemes[0])
+	}
+
+	basePath, _ := d.root["basePath"].(string)
+
+	return scheme + "://" + host + basePath
+}
+
+func (d *document) parseOperation(schemaType biz_entity.ApiSchemaType, method, pathName string, pathItem, operation map[string]any) *biz_entity.ApiToolBundle {
+	bundle := &biz_entity.ApiToolBundle{
+		Method:    strings.ToUpper(method),
+		Path:      pathName,
+		Summary:   operationSummary(operation),
+		Locations: make(map[string]biz_entity.ApiParameterLocation),
+	}
+
+	for _, parameter := range d.operationParameters(pathItem, operation) {
+		name, _ := parameter["name"].(string)
+		in, _ := parameter["in"].(string)
+		description, _ := parameter["description"].(string)
+		required, _ := parameter["required"].(bool)
+
+		switch in {
+		case "body":
+			bundle.BodyContentType = "application/json"
+			d.addBodyParameters(bundle, d.resolve(parameter["schema"]), required)
+		case "formData":
+			bundle.BodyContentType = d.swaggerFormContentType(operation, parameter)
+			addParameter(bundle, biz_entity.BodyLocation, name, description, required, parameter)
+		case "path":
+			addParameter(bundle, biz_entity.PathLocation, name, description, true, d.parameterSchema(schemaType, parameter))
+		case "query", "header", "cookie":
+			addParameter(bundle, biz_entity.ApiParameterLocation(in), name, description, required, d.parameterSchema(schemaType, parameter))
+		}
+	}
+
+	if requestBody := d.resolve(operation["requestBody"]); requestBody != nil {
+		content := asMap(requestBody["content"])
+		contentType := pickContentType(content)
+
+		if contentType != "" {
+			required, _ := requestBody["required"].(bool)
+			bundle.BodyContentType = contentType
+			d.addBodyParameters(bundle, d.resolve(asMap(content[contentType])["schema"]), required)
+		}
+	}
+
+	return bundle
+}
+
+// operationParameters merges the parameters of the path item and the operation, the operation parameters override
+// the path item parameters of the same name and location.
+func (d *document) operationParameters(pathItem, operation map[string]any) []map[string]any {
+	var (
+		parameters []map[string]any
+		indexes    = make(map[string]int)
+	)
+
+	for _, node := range append(asSlice(pathItem["parameters"]), asSlice(operation["parameters"])...) {
+		parameter := d.resolve(node)
+
+		if parameter == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%v:%v", parameter["in"], parameter["name"])
+
+		if index, ok := indexes[key]; ok {
+			parameters[index] = parameter
+			continue
+		}
+
+		indexes[key] = len(parameters)
+		parameters = append(parameters, parameter)
+	}
+
+	return parameters
+}
+
+// parameterSchema returns the schema of a non body parameter, swagger puts the type on the parameter itself.
+func (d *document) parameterSchema(schemaType biz_entity.ApiSchemaType, parameter map[string]any) map[string]any {
+	if schema := d.resolve(parameter["schema"]); schema != nil {
+		return schema
+	}
+
+	if schemaType == biz_entity.SwaggerSchema {
+		return parameter
+	}
+
+	return nil
+}
+
+func (d *document) swaggerFormContentType(operation, parameter map[string]any) string {
+	consumes := asSlice(operation["consumes"])
+
+	if len(consumes) == 0 {
+		consumes = asSlice(d.root["consumes"])
+	}
+
+	if parameter["type"] == "file" || containsValue(consumes, "multipart/form-data") {
+		return "multipart/form-data"
+	}
+
+	return "application/x-www-form-urlencoded"
+}
+
+// addBodyParameters adds the properties of the object schema as the body parameters, the properties of allOf are
+// merged. Bodies which aren't objects can't be described as tool parameters and are skipped.
+func (d *document) addBodyParameters(bundle *biz_entity.ApiToolBundle, schema map[string]any, bodyRequired bool) {
+	if schema == nil {
+		return
+	}
+
+	properties := make(map[string]any)
+	var required []any
+
+	for _, node := range append([]any{schema}, asSlice(schema["allOf"])...) {
+		part := d.resolve(node)
+
+		for name, property := range asMap(part["properties"]) {
+			properties[name] = property
+		}
+
+		required = append(required, asSlice(part["required"])...)
+	}
+
+	names := make([]string, 0, len(properties))
+
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		property := d.resolve(properties[name])
+		description, _ := property["description"].(string)
+
+		addParameter(bundle, biz_entity.BodyLocation, name, description, bodyRequired && containsValue(required, name), property)
+	}
+}
+
+// addParameter adds the parameter of the location to the bundle, the first one wins when the names collide.
+func addParameter(bundle *biz_entity.ApiToolBundle, location biz_entity.ApiParameterLocation, name, description string, required bool, schema map[string]any) {
+	if name == "" {
+		return
+	}
+
+	if _, ok := bundle.Locations[name]; ok {
+		return
+	}
+
+	if description == "" {
+		description, _ = schema["description"].(string)
+	}
+
+	parameter := &biz_entity.ToolParameter{
+		Name:             name,
+		Label:            &common.I18nObject{En_US: name, Zh_Hans: name},
+		HumanDescription: &common.I18nObject{En_US: description, Zh_Hans: description},
+		Type:             parameterType(schema),
+		Form:             biz_entity.LLMForm,
+		LLMDescription:   description,
+		Required:         required,
+		Default:          schema["default"],
+	}
+
+	if parameter.Type == biz_entity.SelectType {
+		for _, value := range asSlice(schema["enum"]) {
+			option := fmt.Sprint(value)
+			parameter.Options = append(parameter.Options, &biz_entity.ToolParameterOption{
+				Value: option,
+				Label: &common.I18nObject{En_US: option, Zh_Hans: option},
+			})
+		}
+	}
+
+	bundle.Parameters = append(bundle.Parameters, parameter)
+	bundle.Locations[name] = location
+}
+
+// parameterType maps the schema type to the tool parameter type, the arrays and objects are passed as json strings.
+func parameterType(schema map[string]any) biz_entity.ToolParameterType {
+	if len(asSlice(schema["enum"])) > 0 {
+		return biz_entity.SelectType
+	}
+
+	schemaType := schema["type"]
+
+	// openapi 3.1 allows a list of types, e.g. ["integer", "null"]
+	if types := asSlice(schemaType); len(types) > 0 {
+		schemaType = types[0]
+	}
+
+	switch schemaType {
+	case "integer", "number":
+		return biz_entity.NumberType
+	case "boolean":
+		return biz_entity.BooleanType
+	}
+
+	return biz_entity.StringType
+}
+
+func pickContentType(content map[string]any) string {
+	for _, contentType := range bodyContentTypes {
+		if _, ok := content[contentType]; ok {
+			return contentType
+		}
+	}
+
+	for contentType := range content {
+		if strings.HasSuffix(contentType, "+json") {
+			return contentType
+		}
+	}
+
+	return ""
+}
+
+func operationSummary(operation map[string]any) string {
+	if summary, _ := operation["summary"].(string); summary != "" {
+		return summary
+	}
+
+	description, _ := operation["description"].(string)
+	return description
+}
+
+// toolName returns the operation id, or a name derived from the method and path, as a valid function name.
+func toolName(operation map[string]any, method, pathName string) string {
+	name, _ := operation["operationId"].(string)
+
+	if name == "" {
+		name = method + " " + pathName
+	}
+
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+
+	if name == "" {
+		name = method
+	}
+
+	if len(name) > MAX_TOOL_NAME_LENGTH {
+		name = name[:MAX_TOOL_NAME_LENGTH]
+	}
+
+	return name
+}
+
+func uniqueToolName(toolNames map[string]bool, name string) string {
+	uniqueName := name
+
+	for i := 2; toolNames[uniqueName]; i++ {
+		suffix := fmt.Sprintf("_%d", i)
+		uniqueName = name[:min(len(name), MAX_TOOL_NAME_LENGTH-len(suffix))] + suffix
+	}
+
+	toolNames[uniqueName] = true
+	return uniqueName
+}
+
+// resolve follows the local $ref of the node, nil is returned when the reference is not found or is too deep.
+func (d *document) resolve(node any) map[string]any {
+	object := asMap(node)
+
+	for depth := 0; object != nil; depth++ {
+		ref, ok := object["$ref"].(string)
+
+		if !ok {
+			return object
+		}
+
+		if depth == MAX_REF_DEPTH || !strings.HasPrefix(ref, "#/") {
+			return nil
+		}
+
+		var target any = d.root
+
+		for _, token := range strings.Split(ref[2:], "/") {
+			token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+			target = asMap(target)[token]
+		}
+
+		object = asMap(target)
+	}
+
+	return nil
+}
+
+// asMap converts the decoded object, yaml decodes the mappings with non string keys into map[any]any.
+func asMap(node any) map[string]any {
+	switch object := node.(type) {
+	case map[string]any:
+		return object
+	case map[any]any:
+		result := make(map[string]any, len(object))
+		for k, v := range object {
+			result[fmt.Sprint(k)] = v
+		}
+		return result
+	}
+
+	return nil
+}
+
+func asSlice(node any) []any {
+	slice, _ := node.([]any)
+	return slice
+}
+
+func containsValue(values []any, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
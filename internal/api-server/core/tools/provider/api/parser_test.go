@@ -0,0 +1,212 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package api
+
+import (
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const petStoreOpenAPI = `
+openapi: 3.0.1
+info:
+  title: Pet store
+  version: 1.0.0
+servers:
+  - url: https://{region}.petstore.example.com/v1
+    variables:
+      region:
+        default: eu
+paths:
+  /pets/{petId}:
+    parameters:
+      - name: petId
+        in: path
+        description: The id of the pet
+        schema:
+          type: integer
+    get:
+      operationId: getPet
+      summary: Get a pet by id
+      parameters:
+        - $ref: '#/components/parameters/Verbose'
+      responses:
+        200:
+          description: ok
+  /pets:
+    post:
+      summary: Create a pet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewPet'
+      responses:
+        201:
+          description: created
+components:
+  parameters:
+    Verbose:
+      name: verbose
+      in: query
+      schema:
+        type: boolean
+        default: false
+  schemas:
+    NewPet:
+      allOf:
+        - $ref: '#/components/schemas/Pet'
+        - required: [kind]
+          properties:
+            kind:
+              type: string
+              enum: [cat, dog]
+    Pet:
+      required: [name]
+      properties:
+        name:
+          type: string
+          description: Name of the pet
+        tags:
+          type: array
+          items:
+            type: string
+`
+
+const petStoreSwagger = `{
+  "swagger": "2.0",
+  "host": "petstore.example.com",
+  "basePath": "/v2",
+  "schemes": ["http"],
+  "paths": {
+    "/pets": {
+      "get": {
+        "operationId": "list pets",
+        "parameters": [{"name": "limit", "in": "query", "type": "integer", "required": true}]
+      },
+      "post": {
+        "operationId": "addPet",
+        "consumes": ["application/json"],
+        "parameters": [{"name": "pet", "in": "body", "required": true, "schema": {"$ref": "#/definitions/Pet"}}]
+      }
+    }
+  },
+  "definitions": {
+    "Pet": {"required": ["name"], "properties": {"name": {"type": "string"}, "age": {"type": "number"}}}
+  }
+}`
+
+func findParameter(bundle *biz_entity.ApiToolBundle, name string) *biz_entity.ToolParameter {
+	for _, parameter := range bundle.Parameters {
+		if parameter.Name == name {
+			return parameter
+		}
+	}
+	return nil
+}
+
+func TestParseOpenAPISchema(t *testing.T) {
+	schemaType, bundles, err := ParseSchema([]byte(petStoreOpenAPI))
+
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	if schemaType != biz_entity.OpenAPISchema || len(bundles) != 2 {
+		t.Fatalf("ParseSchema() = %s with %d bundles, want openapi with 2 bundles", schemaType, len(bundles))
+	}
+
+	createPet, getPet := bundles[0], bundles[1]
+
+	if getPet.OperationID != "getPet" || getPet.Method != "GET" || getPet.ServerURL != "https://eu.petstore.example.com/v1" || getPet.Summary != "Get a pet by id" {
+		t.Errorf("unexpected bundle %+v", getPet)
+	}
+
+	if petID := findParameter(getPet, "petId"); petID == nil || !petID.Required || petID.Type != biz_entity.NumberType || getPet.Locations["petId"] != biz_entity.PathLocation {
+		t.Errorf("unexpected path parameter %+v", petID)
+	}
+
+	if verbose := findParameter(getPet, "verbose"); verbose == nil || verbose.Type != biz_entity.BooleanType || verbose.Default != false || getPet.Locations["verbose"] != biz_entity.QueryLocation {
+		t.Errorf("unexpected query parameter %+v", verbose)
+	}
+
+	if createPet.OperationID != "post_pets" || createPet.BodyContentType != "application/json" || len(createPet.Parameters) != 3 {
+		t.Fatalf("unexpected bundle %+v", createPet)
+	}
+
+	if kind := findParameter(createPet, "kind"); kind == nil || !kind.Required || kind.Type != biz_entity.SelectType || len(kind.Options) != 2 {
+		t.Errorf("unexpected enum body parameter %+v", kind)
+	}
+
+	if name := findParameter(createPet, "name"); name == nil || !name.Required || name.LLMDescription != "Name of the pet" || createPet.Locations["name"] != biz_entity.BodyLocation {
+		t.Errorf("unexpected body parameter %+v", name)
+	}
+
+	if tags := findParameter(createPet, "tags"); tags == nil || tags.Required || tags.Type != biz_entity.StringType {
+		t.Errorf("unexpected array body parameter %+v", tags)
+	}
+}
+
+func TestParseSwaggerSchema(t *testing.T) {
+	schemaType, bundles, err := ParseSchema([]byte(petStoreSwagger))
+
+	if err != nil {
+		t.Fatalf("ParseSchema() error = %v", err)
+	}
+
+	if schemaType != biz_entity.SwaggerSchema || len(bundles) != 2 {
+		t.Fatalf("ParseSchema() = %s with %d bundles, want swagger with 2 bundles", schemaType, len(bundles))
+	}
+
+	listPets, addPet := bundles[0], bundles[1]
+
+	if listPets.OperationID != "list_pets" || listPets.ServerURL != "http://petstore.example.com/v2" {
+		t.Errorf("unexpected bundle %+v", listPets)
+	}
+
+	if limit := findParameter(listPets, "limit"); limit == nil || !limit.Required || limit.Type != biz_entity.NumberType {
+		t.Errorf("unexpected query parameter %+v", limit)
+	}
+
+	if name := findParameter(addPet, "name"); name == nil || !name.Required || addPet.BodyContentType != "application/json" {
+		t.Errorf("unexpected body parameter %+v of bundle %+v", name, addPet)
+	}
+
+	if age := findParameter(addPet, "age"); age == nil || age.Required || age.Type != biz_entity.NumberType {
+		t.Errorf("unexpected body parameter %+v", age)
+	}
+}
+
+func TestParseInvalidSchema(t *testing.T) {
+	schemas := map[string]string{
+		"empty":           "",
+		"not openapi":     `{"info": {}}`,
+		"relative server": "openapi: 3.0.0\nservers:\n  - url: /v1\npaths:\n  /pets:\n    get: {}\n",
+		"no operation":    "openapi: 3.0.0\nservers:\n  - url: https://example.com\npaths: {}\n",
+		"self reference":  "openapi: 3.0.0\nservers:\n  - url: https://example.com\npaths:\n  /pets:\n    $ref: '#/paths/~1pets'\n",
+	}
+
+	for name, schema := range schemas {
+		if _, _, err := ParseSchema([]byte(schema)); !errors.IsCode(err, code.ErrApiToolSchema) {
+			t.Errorf("ParseSchema() of %s error = %v, want ErrApiToolSchema", name, err)
+		}
+	}
+}
+
+func TestUniqueToolName(t *testing.T) {
+	toolNames := make(map[string]bool)
+
+	if first, second := uniqueToolName(toolNames, "getPet"), uniqueToolName(toolNames, "getPet"); first != "getPet" || second != "getPet_2" {
+		t.Errorf("uniqueToolName() = %s, %s, want getPet, getPet_2", first, second)
+	}
+
+	if name := toolName(map[string]any{}, "delete", "/pets/{petId}/tags"); name != "delete_pets_petId_tags" {
+		t.Errorf("toolName() = %s, want delete_pets_petId_tags", name)
+	}
+}
@@ -1,5 +1,7 @@
 package provider
 
 import (
+	_ "github.com/lunarianss/Luna/internal/api-server/core/tools/provider/api"
 	_ "github.com/lunarianss/Luna/internal/api-server/core/tools/provider/builtin/stability/tools"
+	_ "github.com/lunarianss/Luna/internal/api-server/core/tools/provider/mcp"
 )
@@ -0,0 +1,314 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	// CONNECT_TIMEOUT bounds the spawn or the connection and the initialize handshake
+	CONNECT_TIMEOUT = 30 * time.Second
+	// MAX_TOOL_PAGES bounds the pagination of tools/list against the servers which never end the cursor
+	MAX_TOOL_PAGES = 100
+)
+
+var httpClient = &http.Client{
+	Timeout: time.Duration(120) * time.Second,
+}
+
+type initializeResult struct {
+	ProtocolVersion string `json:"protocolVersion"`
+	ServerInfo      struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"serverInfo"`
+}
+
+type listToolsResult struct {
+	Tools []struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		InputSchema map[string]any `json:"inputSchema"`
+	} `json:"tools"`
+	NextCursor string `json:"nextCursor"`
+}
+
+// ResourceContents is the resource embedded in the result of a tool call, either Text or the base64 Blob is set.
+type ResourceContents struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Blob     string `json:"blob"`
+}
+
+// Content is an item of the result of a tool call, the type is text, image, audio, resource or resource_link.
+type Content struct {
+	Type     string            `json:"type"`
+	Text     string            `json:"text"`
+	Data     string            `json:"data"`
+	MimeType string            `json:"mimeType"`
+	URI      string            `json:"uri"`
+	Resource *ResourceContents `json:"resource"`
+}
+
+type CallToolResult struct {
+	Content           []*Content      `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent"`
+	IsError           bool            `json:"isError"`
+}
+
+// Client is a connection to a mcp server, the requests are multiplexed over the transport and matched to the
+// responses by id.
+type Client struct {
+	transport transport
+	nextID    atomic.Int64
+
+	mu      sync.Mutex
+	pending map[string]chan *message
+	done    chan struct{}
+	err     error
+
+	closeOnce sync.Once
+
+	ServerName      string
+	ProtocolVersion string
+}
+
+// Connect starts the transport of the server and completes the initialize handshake.
+func Connect(ctx context.Context, config *biz_entity.McpServerConfig) (*Client, error) {
+	transport, err := newTransport(config, httpClient)
+
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		transport: transport,
+		pending:   make(map[string]chan *message),
+		done:      make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, CONNECT_TIMEOUT)
+	defer cancel()
+
+	if err := transport.start(ctx, client.handle, client.fail); err != nil {
+		return nil, err
+	}
+
+	var result initializeResult
+
+	params := map[string]any{
+		"protocolVersion": PROTOCOL_VERSION,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]any{"name": CLIENT_NAME, "version": CLIENT_VERSION},
+	}
+
+	if err := client.call(ctx, "initialize", params, &result); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	if err := client.notify(ctx, "notifications/initialized", nil); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	client.ServerName = result.ServerInfo.Name
+	client.ProtocolVersion = result.ProtocolVersion
+
+	log.Infof("connected to mcp server %s with protocol %s", client.ServerName, client.ProtocolVersion)
+	return client, nil
+}
+
+// ListTools lists the tools of the server through all the pages.
+func (c *Client) ListTools(ctx context.Context) ([]*biz_entity.McpToolBundle, error) {
+	var (
+		cursor string
+		tools  = make([]*biz_entity.McpToolBundle, 0)
+	)
+
+	for page := 0; page < MAX_TOOL_PAGES; page++ {
+		var (
+			result listToolsResult
+			params map[string]any
+		)
+
+		if cursor != "" {
+			params = map[string]any{"cursor": cursor}
+		}
+
+		if err := c.call(ctx, "tools/list", params, &result); err != nil {
+			return nil, err
+		}
+
+		for _, tool := range result.Tools {
+			tools = append(tools, &biz_entity.McpToolBundle{
+				Name:        tool.Name,
+				Description: tool.Description,
+				InputSchema: tool.InputSchema,
+			})
+		}
+
+		if result.NextCursor == "" {
+			return tools, nil
+		}
+		cursor = result.NextCursor
+	}
+
+	return nil, errors.WithCode(code.ErrMcpConnection, "tools of mcp server %s exceed %d pages", c.ServerName, MAX_TOOL_PAGES)
+}
+
+// CallTool calls the tool with the arguments, the errors reported by the tool are in the result rather than err.
+func (c *Client) CallTool(ctx context.Context, name string, arguments map[string]any) (*CallToolResult, error) {
+	var result CallToolResult
+
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": arguments}, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Alive reports whether the transport is still usable.
+func (c *Client) Alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+func (c *Client) Close() error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		c.fail(errors.New("connection is closed"))
+		err = c.transport.close()
+	})
+
+	return err
+}
+
+func (c *Client) call(ctx context.Context, method string, params any, result any) error {
+	id := json.RawMessage(strconv.FormatInt(c.nextID.Add(1), 10))
+	responseChan := make(chan *message, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return errors.WithCode(code.ErrMcpConnection, "mcp %s: %s", method, c.err.Error())
+	}
+	c.pending[string(id)] = responseChan
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, string(id))
+		c.mu.Unlock()
+	}()
+
+	request, err := newMessage(id, method, params)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	if err := c.transport.send(ctx, request); err != nil {
+		return err
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.Error != nil {
+			return errors.WithCode(code.ErrMcpConnection, "mcp %s: %s", method, response.Error.Error())
+		}
+
+		if result == nil || len(response.Result) == 0 {
+			return nil
+		}
+
+		if err := json.Unmarshal(response.Result, result); err != nil {
+			return errors.WithSCode(code.ErrDecodingJSON, err.Error())
+		}
+		return nil
+	case <-c.done:
+		return errors.WithCode(code.ErrMcpConnection, "mcp %s: %s", method, c.err.Error())
+	case <-ctx.Done():
+		// the server is told to stop the work of the request, which is best-effort
+		c.notify(context.Background(), "notifications/cancelled", map[string]any{"requestId": id, "reason": ctx.Err().Error()})
+		return errors.WithCode(code.ErrMcpConnection, "mcp %s: %s", method, ctx.Err().Error())
+	}
+}
+
+func (c *Client) notify(ctx context.Context, method string, params any) error {
+	notification, err := newMessage(nil, method, params)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	return c.transport.send(ctx, notification)
+}
+
+func (c *Client) handle(msg *message) {
+	switch {
+	case msg.isResponse():
+		c.mu.Lock()
+		responseChan, ok := c.pending[string(msg.ID)]
+		c.mu.Unlock()
+
+		if ok {
+			select {
+			case responseChan <- msg:
+			default:
+			}
+		}
+	case msg.isRequest():
+		go c.reply(msg)
+	case msg.isNotification():
+		log.Debugf("mcp server %s notified %s", c.ServerName, msg.Method)
+	}
+}
+
+// reply answers the requests of the server, only ping is supported since the client declares no capabilities.
+func (c *Client) reply(request *message) {
+	response := &message{JSONRPC: JSONRPC_VERSION, ID: request.ID}
+
+	if request.Method == "ping" {
+		response.Result = json.RawMessage("{}")
+	} else {
+		response.Error = &rpcError{Code: METHOD_NOT_FOUND, Message: "method " + request.Method + " is not supported"}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), CLOSE_TIMEOUT)
+	defer cancel()
+
+	if err := c.transport.send(ctx, response); err != nil {
+		log.Warnf("reply %s to mcp server %s: %s", request.Method, c.ServerName, err.Error())
+	}
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
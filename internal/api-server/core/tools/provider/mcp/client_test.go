@@ -0,0 +1,376 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// fakeServer answers the requests like a mcp server with two pages of tools, the echo tool returns its arguments.
+type fakeServer struct {
+	initialized atomic.Int32
+}
+
+func (s *fakeServer) respond(msg *message) *message {
+	if !msg.isRequest() {
+		return nil
+	}
+
+	var (
+		params map[string]any
+		result any
+	)
+
+	json.Unmarshal(msg.Params, &params)
+
+	switch msg.Method {
+	case "initialize":
+		s.initialized.Add(1)
+		result = map[string]any{"protocolVersion": PROTOCOL_VERSION, "serverInfo": map[string]any{"name": "fake", "version": "1"}, "capabilities": map[string]any{}}
+	case "tools/list":
+		if params["cursor"] == nil {
+			result = map[string]any{"tools": []any{map[string]any{"name": "echo", "description": "Echo the text", "inputSchema": map[string]any{"type": "object", "properties": map[string]any{"text": map[string]any{"type": "string"}}}}}, "nextCursor": "2"}
+		} else {
+			result = map[string]any{"tools": []any{map[string]any{"name": "fail", "inputSchema": map[string]any{"type": "object"}}}}
+		}
+	case "tools/call":
+		arguments, _ := json.Marshal(params["arguments"])
+
+		if params["name"] == "fail" {
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": "boom"}}, "isError": true}
+		} else {
+			result = map[string]any{"content": []any{map[string]any{"type": "text", "text": string(arguments)}}}
+		}
+	default:
+		return &message{JSONRPC: JSONRPC_VERSION, ID: msg.ID, Error: &rpcError{Code: METHOD_NOT_FOUND, Message: "not found"}}
+	}
+
+	encoded, _ := json.Marshal(result)
+	return &message{JSONRPC: JSONRPC_VERSION, ID: msg.ID, Result: encoded}
+}
+
+// streamableHandler answers initialize with json and the other requests with event streams, the session assigned
+// by initialize is required afterwards.
+func (s *fakeServer) streamableHandler(t *testing.T) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		var msg message
+
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Errorf("invalid message: %v", err)
+		}
+
+		if msg.Method != "initialize" && r.Header.Get(SESSION_HEADER) != "session-1" {
+			t.Errorf("%s is sent without the session", msg.Method)
+		}
+
+		response := s.respond(&msg)
+
+		if response == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+
+		encoded, _ := json.Marshal(response)
+
+		if msg.Method == "initialize" {
+			w.Header().Set(SESSION_HEADER, "session-1")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(encoded)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, ": keep alive\n\nevent: message\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+		fmt.Fprintf(w, "event: message\ndata: %s\n\n", encoded)
+	}
+}
+
+func testClient(t *testing.T, client *Client) {
+	tools, err := client.ListTools(context.Background())
+
+	if err != nil {
+		t.Fatalf("ListTools() error = %v", err)
+	}
+
+	if len(tools) != 2 || tools[0].Name != "echo" || tools[1].Name != "fail" || tools[0].InputSchema["type"] != "object" {
+		t.Fatalf("ListTools() = %+v", tools)
+	}
+
+	result, err := client.CallTool(context.Background(), "echo", map[string]any{"text": "hi"})
+
+	if err != nil {
+		t.Fatalf("CallTool() error = %v", err)
+	}
+
+	if result.IsError || len(result.Content) != 1 || result.Content[0].Text != `{"text":"hi"}` {
+		t.Errorf("CallTool() = %+v", result)
+	}
+}
+
+func TestStreamableHTTPClient(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	server := httptest.NewServer((&fakeServer{}).streamableHandler(t))
+	defer server.Close()
+
+	client, err := Connect(context.Background(), &biz_entity.McpServerConfig{Transport: biz_entity.McpStreamableHTTPTransport, URL: server.URL})
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	if client.ServerName != "fake" {
+		t.Errorf("ServerName = %s, want fake", client.ServerName)
+	}
+
+	testClient(t, client)
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := client.ListTools(context.Background()); !errors.IsCode(err, code.ErrMcpConnection) {
+		t.Errorf("ListTools() after Close() error = %v, want ErrMcpConnection", err)
+	}
+}
+
+func TestSSEClient(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	var (
+		fake    = &fakeServer{}
+		events  = make(chan []byte, 8)
+		started sync.Once
+	)
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/sse", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "event: endpoint\ndata: /messages?session=1\n\n")
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case event := <-events:
+				fmt.Fprintf(w, "event: message\ndata: %s\n\n", event)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	mux.HandleFunc("/messages", func(w http.ResponseWriter, r *http.Request) {
+		started.Do(func() {
+			// the server pings the client which has to answer
+			events <- []byte(`{"jsonrpc":"2.0","id":"ping-1","method":"ping"}`)
+		})
+
+		var msg message
+
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || r.URL.Query().Get("session") != "1" {
+			t.Errorf("invalid message %v to %s", err, r.URL.String())
+		}
+
+		if response := fake.respond(&msg); response != nil {
+			encoded, _ := json.Marshal(response)
+			events <- encoded
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client, err := Connect(context.Background(), &biz_entity.McpServerConfig{Transport: biz_entity.McpSSETransport, URL: server.URL + "/sse"})
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	defer client.Close()
+
+	testClient(t, client)
+}
+
+// TestMcpStdioHelperProcess is the stdio mcp server spawned by TestStdioClient.
+func TestMcpStdioHelperProcess(t *testing.T) {
+	if os.Getenv("LUNA_MCP_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	fake := &fakeServer{}
+	scanner := bufio.NewScanner(os.Stdin)
+
+	for scanner.Scan() {
+		var msg message
+
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			os.Exit(2)
+		}
+
+		if response := fake.respond(&msg); response != nil {
+			encoded, _ := json.Marshal(response)
+			fmt.Fprintf(os.Stdout, "%s\n", encoded)
+		}
+	}
+
+	os.Exit(0)
+}
+
+func TestStdioClient(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	config := &biz_entity.McpServerConfig{
+		Transport: biz_entity.McpStdioTransport,
+		Command:   os.Args[0],
+		Args:      []string{"-test.run=^TestMcpStdioHelperProcess$"},
+		Env:       map[string]string{"LUNA_MCP_HELPER_PROCESS": "1"},
+	}
+
+	if _, err := Connect(context.Background(), config); !errors.IsCode(err, code.ErrForbidden) {
+		t.Fatalf("Connect() with the command not allowed error = %v, want ErrForbidden", err)
+	}
+
+	// the arguments are allowed along with the command
+	AllowStdioCommands([]string{os.Args[0]})
+
+	if _, err := Connect(context.Background(), config); !errors.IsCode(err, code.ErrForbidden) {
+		t.Fatalf("Connect() with the arguments not allowed error = %v, want ErrForbidden", err)
+	}
+
+	AllowStdioCommands([]string{os.Args[0] + "  -test.run=^TestMcpStdioHelperProcess$"})
+	defer AllowStdioCommands(nil)
+
+	injectedConfig := *config
+	injectedConfig.Env = map[string]string{"LUNA_MCP_HELPER_PROCESS": "1", "ld_preload": "/tmp/inject.so"}
+
+	if _, err := Connect(context.Background(), &injectedConfig); !errors.IsCode(err, code.ErrForbidden) {
+		t.Fatalf("Connect() with LD_PRELOAD error = %v, want ErrForbidden", err)
+	}
+
+	client, err := Connect(context.Background(), config)
+
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	testClient(t, client)
+
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if client.Alive() {
+		t.Errorf("client is alive after Close()")
+	}
+}
+
+func TestMcpToolInvoke(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	fake := &fakeServer{}
+	server := httptest.NewServer(fake.streamableHandler(t))
+	defer server.Close()
+
+	connections := NewConnectionPool()
+	defer connections.CloseAll()
+
+	toolRuntime := func(toolName string, version int64) *biz_entity.ToolRuntimeConfiguration {
+		return &biz_entity.ToolRuntimeConfiguration{
+			ToolStaticConfiguration: &biz_entity.ToolStaticConfiguration{
+				Identity: &biz_entity.ToolIdentity{Name: toolName, Provider: "fake"},
+			},
+			ProviderType: biz_entity.ToolProviderTypeMCP,
+			Mcp: &biz_entity.McpToolRuntime{
+				ProviderID: "provider-1",
+				Version:    version,
+				Server:     &biz_entity.McpServerConfig{Transport: biz_entity.McpStreamableHTTPTransport, URL: server.URL},
+				Bundle:     &biz_entity.McpToolBundle{Name: toolName},
+			},
+		}
+	}
+
+	if toolIns, err := tool_registry.ToolRuntimeRegistry.Acquire(tool_registry.MCP_TOOL_REGISTRY); err != nil || toolIns == nil {
+		t.Fatalf("mcp tool is not registered: %v", err)
+	}
+
+	tool := NewMcpTool(connections)
+
+	for i := 0; i < 2; i++ {
+		messages, err := tool.Invoke(context.Background(), "user-1", []byte(`{"text": "hi"}`), toolRuntime("echo", 1))
+
+		if err != nil {
+			t.Fatalf("Invoke() error = %v", err)
+		}
+
+		if len(messages) != 1 || messages[0].Type != biz_entity.TEXT || messages[0].Message != `{"text":"hi"}` {
+			t.Errorf("Invoke() = %+v", messages)
+		}
+	}
+
+	if initialized := fake.initialized.Load(); initialized != 1 {
+		t.Errorf("the connection is initialized %d times, want it pooled", initialized)
+	}
+
+	if _, err := tool.Invoke(context.Background(), "user-1", nil, toolRuntime("fail", 2)); !errors.IsCode(err, code.ErrInvokeTool) {
+		t.Errorf("Invoke() of the failing tool error = %v, want ErrInvokeTool", err)
+	}
+
+	if initialized := fake.initialized.Load(); initialized != 2 {
+		t.Errorf("the connection is initialized %d times, want it reopened for the new version", initialized)
+	}
+}
+
+func TestStdioEnv(t *testing.T) {
+	t.Setenv("LUNA_SECRET_KEY", "secret")
+	t.Setenv("PATH", "/usr/bin")
+
+	env := stdioEnv(map[string]string{"API_KEY": "key"})
+
+	if !slices.Contains(env, "PATH=/usr/bin") || !slices.Contains(env, "API_KEY=key") {
+		t.Errorf("stdioEnv() = %v, want PATH and the configured env", env)
+	}
+
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "LUNA_SECRET_KEY=") {
+			t.Errorf("stdioEnv() passes the env %s of the server", variable)
+		}
+	}
+
+	for _, name := range []string{"LD_PRELOAD", "DYLD_INSERT_LIBRARIES", "NODE_OPTIONS", "npm_config_registry", "PYTHONPATH", "PATH", "JAVA_TOOL_OPTIONS"} {
+		if err := checkStdioEnv(map[string]string{name: "x"}); !errors.IsCode(err, code.ErrForbidden) {
+			t.Errorf("checkStdioEnv() of %s error = %v, want ErrForbidden", name, err)
+		}
+	}
+
+	if err := checkStdioEnv(map[string]string{"GITHUB_TOKEN": "x"}); err != nil {
+		t.Errorf("checkStdioEnv() error = %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	JSONRPC_VERSION = "2.0"
+	// PROTOCOL_VERSION is the mcp revision requested in the initialize handshake, the servers answer with the
+	// revision they support
+	PROTOCOL_VERSION = "2025-03-26"
+	CLIENT_NAME      = "luna"
+	CLIENT_VERSION   = "1.0.0"
+
+	// METHOD_NOT_FOUND is the json-rpc error code returned to the server requests which are not supported
+	METHOD_NOT_FOUND = -32601
+)
+
+// message is a json-rpc request, notification or response, the kind is told by the presence of the id and the
+// method.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("json-rpc error %d: %s", e.Code, e.Message)
+}
+
+func (m *message) isRequest() bool {
+	return m.Method != "" && len(m.ID) != 0
+}
+
+func (m *message) isNotification() bool {
+	return m.Method != "" && len(m.ID) == 0
+}
+
+func (m *message) isResponse() bool {
+	return m.Method == "" && len(m.ID) != 0
+}
+
+func newMessage(id json.RawMessage, method string, params any) (*message, error) {
+	msg := &message{JSONRPC: JSONRPC_VERSION, ID: id, Method: method}
+
+	if params != nil {
+		encoded, err := json.Marshal(params)
+
+		if err != nil {
+			return nil, err
+		}
+		msg.Params = encoded
+	}
+
+	return msg, nil
+}
+
+// decodeMessages decodes a message or a batch of messages.
+func decodeMessages(data []byte) ([]*message, error) {
+	data = bytes.TrimSpace(data)
+
+	if len(data) != 0 && data[0] == '[' {
+		var batch []*message
+
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+
+	msg := &message{}
+
+	if err := json.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+
+	return []*message{msg}, nil
+}
+
+// readEvents reads the server-sent events of the stream, onEvent is called for each dispatched event until it
+// returns false or the stream ends.
+func readEvents(r io.Reader, onEvent func(event, data string) bool) error {
+	var (
+		reader = bufio.NewReader(r)
+		event  string
+		data   []string
+	)
+
+	for {
+		line, err := reader.ReadString('\n')
+
+		if line != "" {
+			line = strings.TrimRight(line, "\r\n")
+
+			switch {
+			case line == "":
+				if len(data) != 0 {
+					if event == "" {
+						event = "message"
+					}
+
+					if !onEvent(event, strings.Join(data, "\n")) {
+						return nil
+					}
+				}
+				event, data = "", nil
+			case strings.HasPrefix(line, ":"):
+				// comments keep the connection alive
+			case strings.HasPrefix(line, "event:"):
+				event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+			case strings.HasPrefix(line, "data:"):
+				data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+			}
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
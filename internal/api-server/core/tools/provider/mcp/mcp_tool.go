@@ -0,0 +1,149 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/tool_registry"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+func init() {
+	tool_registry.ToolRuntimeRegistry.RegisterAgentToolInstance(NewMcpTool(Connections))
+}
+
+// McpTool calls the tools of the mcp providers through the pooled connections, the provider and tool are described
+// by the mcp runtime of the tool runtime so that one registry serves the tools of all the providers.
+type McpTool struct {
+	connections *ConnectionPool
+}
+
+var _ tool_registry.IToolCallRegistry = (*McpTool)(nil)
+
+func NewMcpTool(connections *ConnectionPool) *McpTool {
+	return &McpTool{
+		connections: connections,
+	}
+}
+
+func (mt *McpTool) Register() string {
+	return tool_registry.MCP_TOOL_REGISTRY
+}
+
+func (mt *McpTool) Invoke(ctx context.Context, userID string, toolParameters []byte, toolRuntime *biz_entity.ToolRuntimeConfiguration) ([]*biz_entity.ToolInvokeMessage, error) {
+	mcpRuntime := toolRuntime.Mcp
+
+	if mcpRuntime == nil || mcpRuntime.Bundle == nil || mcpRuntime.Server == nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "mcp runtime of tool %s is not found", toolRuntime.Identity.Name)
+	}
+
+	arguments := make(map[string]any)
+
+	if len(bytes.TrimSpace(toolParameters)) != 0 {
+		if err := json.Unmarshal(toolParameters, &arguments); err != nil {
+			return nil, errors.WithCode(code.ErrToolParameter, "parse parameter: %s, error %+v", string(toolParameters), err.Error())
+		}
+	}
+
+	client, err := mt.connections.Acquire(ctx, mcpRuntime.ProviderID, mcpRuntime.Version, mcpRuntime.Server)
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("invoke mcp tool %s of server %s", mcpRuntime.Bundle.Name, client.ServerName)
+
+	result, err := client.CallTool(ctx, mcpRuntime.Bundle.Name, arguments)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "mcp tool %s failed: %s", mcpRuntime.Bundle.Name, err.Error())
+	}
+
+	return convertResult(toolRuntime, mcpRuntime.Bundle.Name, result)
+}
+
+// convertResult converts the text contents into text messages and the binary contents into blob messages which are
+// saved as tool files, the structured content is passed as json when there are no contents.
+func convertResult(toolRuntime *biz_entity.ToolRuntimeConfiguration, toolName string, result *CallToolResult) ([]*biz_entity.ToolInvokeMessage, error) {
+	if result.IsError {
+		var texts []string
+
+		for _, content := range result.Content {
+			if content.Type == "text" {
+				texts = append(texts, content.Text)
+			}
+		}
+
+		return nil, errors.WithCode(code.ErrInvokeTool, "mcp tool %s returned an error: %s", toolName, strings.Join(texts, "\n"))
+	}
+
+	messages := make([]*biz_entity.ToolInvokeMessage, 0, len(result.Content))
+
+	for _, content := range result.Content {
+		switch content.Type {
+		case "text":
+			messages = append(messages, &biz_entity.ToolInvokeMessage{Type: biz_entity.TEXT, Message: content.Text})
+		case "image", "audio":
+			message, err := blobMessage(toolRuntime, content.Data, content.MimeType, content.Type)
+
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		case "resource":
+			if content.Resource == nil {
+				continue
+			}
+
+			if content.Resource.Blob == "" {
+				messages = append(messages, &biz_entity.ToolInvokeMessage{Type: biz_entity.TEXT, Message: content.Resource.Text})
+				continue
+			}
+
+			message, err := blobMessage(toolRuntime, content.Resource.Blob, content.Resource.MimeType, "file")
+
+			if err != nil {
+				return nil, err
+			}
+			messages = append(messages, message)
+		case "resource_link":
+			messages = append(messages, &biz_entity.ToolInvokeMessage{Type: biz_entity.LINK, Message: content.URI})
+		default:
+			log.Warnf("mcp tool %s returned the content of unknown type %s", toolName, content.Type)
+		}
+	}
+
+	if len(messages) == 0 && len(result.StructuredContent) != 0 {
+		messages = append(messages, &biz_entity.ToolInvokeMessage{Type: biz_entity.JSON, Message: []byte(result.StructuredContent)})
+	}
+
+	if len(messages) == 0 {
+		messages = append(messages, &biz_entity.ToolInvokeMessage{Type: biz_entity.TEXT, Message: "the tool succeeded without output"})
+	}
+
+	return messages, nil
+}
+
+func blobMessage(toolRuntime *biz_entity.ToolRuntimeConfiguration, data, mimeType, saveAs string) (*biz_entity.ToolInvokeMessage, error) {
+	blob, err := base64.StdEncoding.DecodeString(data)
+
+	if err != nil {
+		return nil, errors.WithCode(code.ErrInvokeTool, "decode %s content of the mcp tool: %s", saveAs, err.Error())
+	}
+
+	if mimeType == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	return toolRuntime.CreateBlobMessage(blob, map[string]any{"mime_type": mimeType}, saveAs), nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+)
+
+// Connections keeps a connection per mcp provider, which is shared by the tool calls of all the agents of the
+// tenant.
+var Connections = NewConnectionPool()
+
+type pooledConnection struct {
+	version int64
+	client  *Client
+}
+
+// ConnectionPool opens the connections of the providers lazily, the connection is reopened when the version of the
+// provider changes, e.g. the provider is updated, or the connection breaks.
+type ConnectionPool struct {
+	mu          sync.Mutex
+	connections map[string]*pooledConnection
+}
+
+func NewConnectionPool() *ConnectionPool {
+	return &ConnectionPool{
+		connections: make(map[string]*pooledConnection),
+	}
+}
+
+func (cp *ConnectionPool) Acquire(ctx context.Context, providerID string, version int64, config *biz_entity.McpServerConfig) (*Client, error) {
+	cp.mu.Lock()
+	connection, ok := cp.connections[providerID]
+	cp.mu.Unlock()
+
+	if ok && connection.version == version && connection.client.Alive() {
+		return connection.client, nil
+	}
+
+	// the handshake may take a while, the pool isn't locked meanwhile so that other providers aren't blocked
+	client, err := Connect(ctx, config)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if current, ok := cp.connections[providerID]; ok {
+		if current.version == version && current.client.Alive() {
+			// another call connected first
+			go client.Close()
+			return current.client, nil
+		}
+		go current.client.Close()
+	}
+
+	cp.connections[providerID] = &pooledConnection{version: version, client: client}
+	return client, nil
+}
+
+// Remove closes the connection of the provider, which is called when the provider is updated or deleted.
+func (cp *ConnectionPool) Remove(providerID string) {
+	cp.mu.Lock()
+	connection, ok := cp.connections[providerID]
+	delete(cp.connections, providerID)
+	cp.mu.Unlock()
+
+	if ok {
+		go func() {
+			if err := connection.client.Close(); err != nil {
+				log.Warnf("close mcp connection of provider %s: %s", providerID, err.Error())
+			}
+		}()
+	}
+}
+
+// CloseAll closes all the connections, which is registered to the shutdown manager.
+func (cp *ConnectionPool) CloseAll() error {
+	cp.mu.Lock()
+	connections := cp.connections
+	cp.connections = make(map[string]*pooledConnection)
+	cp.mu.Unlock()
+
+	var (
+		wg      sync.WaitGroup
+		errMu   sync.Mutex
+		lastErr error
+	)
+
+	for providerID, connection := range connections {
+		wg.Add(1)
+
+		go func(providerID string, client *Client) {
+			defer wg.Done()
+
+			if err := client.Close(); err != nil {
+				log.Warnf("close mcp connection of provider %s: %s", providerID, err.Error())
+
+				errMu.Lock()
+				lastErr = err
+				errMu.Unlock()
+			}
+		}(providerID, connection.client)
+	}
+
+	wg.Wait()
+	return lastErr
+}
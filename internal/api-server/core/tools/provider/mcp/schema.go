@@ -0,0 +1,192 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	biz_entity_chat_prompt_message "github.com/lunarianss/Luna/internal/api-server/domain/chat/entity/biz_entity/chat_prompt_message"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+)
+
+// MAX_SCHEMA_DEPTH bounds the nesting of the converted schemas, the deeper properties are left untyped
+const MAX_SCHEMA_DEPTH = 8
+
+// PromptMessageToolParameter converts the input schema of the tool, the nested arrays and objects are kept so that
+// the llm sees the same schema as the server.
+func PromptMessageToolParameter(inputSchema map[string]any) *biz_entity_chat_prompt_message.PromptMessageToolParameter {
+	parameter := biz_entity_chat_prompt_message.NewPromptMessageToolParameter()
+
+	for name, propertySchema := range asMap(inputSchema["properties"]) {
+		parameter.Properties[name] = promptMessageToolProperty(asMap(propertySchema), 1)
+	}
+
+	parameter.Required = append(parameter.Required, asStrings(inputSchema["required"])...)
+	return parameter
+}
+
+func promptMessageToolProperty(schema map[string]any, depth int) *biz_entity_chat_prompt_message.PromptMessageToolProperty {
+	schema = pickAlternative(schema)
+	description, _ := schema["description"].(string)
+
+	property := &biz_entity_chat_prompt_message.PromptMessageToolProperty{
+		Type:        schemaType(schema),
+		Description: description,
+		Enum:        asStrings(schema["enum"]),
+	}
+
+	if depth >= MAX_SCHEMA_DEPTH {
+		return property
+	}
+
+	if items := asMap(schema["items"]); items != nil {
+		property.Items = promptMessageToolProperty(items, depth+1)
+	}
+
+	if properties := asMap(schema["properties"]); len(properties) != 0 {
+		property.Properties = make(biz_entity_chat_prompt_message.PromptMessageToolProperties, len(properties))
+
+		for name, propertySchema := range properties {
+			property.Properties[name] = promptMessageToolProperty(asMap(propertySchema), depth+1)
+		}
+		property.Required = asStrings(schema["required"])
+	}
+
+	return property
+}
+
+// ToolParameters converts the top level properties of the input schema into the tool parameters presented in the
+// console, the arrays and objects are presented as strings.
+func ToolParameters(inputSchema map[string]any) []*biz_entity.ToolParameter {
+	var (
+		properties = asMap(inputSchema["properties"])
+		required   = asStrings(inputSchema["required"])
+		names      = make([]string, 0, len(properties))
+		parameters = make([]*biz_entity.ToolParameter, 0, len(properties))
+	)
+
+	for name := range properties {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := pickAlternative(asMap(properties[name]))
+		description, _ := schema["description"].(string)
+
+		parameter := &biz_entity.ToolParameter{
+			Name:             name,
+			Label:            &common.I18nObject{En_US: name, Zh_Hans: name},
+			HumanDescription: &common.I18nObject{En_US: description, Zh_Hans: description},
+			Type:             biz_entity.StringType,
+			Form:             biz_entity.LLMForm,
+			LLMDescription:   description,
+			Required:         containsString(required, name),
+			Default:          schema["default"],
+		}
+
+		switch enum := asStrings(schema["enum"]); {
+		case len(enum) != 0:
+			parameter.Type = biz_entity.SelectType
+
+			for _, option := range enum {
+				parameter.Options = append(parameter.Options, &biz_entity.ToolParameterOption{
+					Value: option,
+					Label: &common.I18nObject{En_US: option, Zh_Hans: option},
+				})
+			}
+		case schemaType(schema) == "integer" || schemaType(schema) == "number":
+			parameter.Type = biz_entity.NumberType
+		case schemaType(schema) == "boolean":
+			parameter.Type = biz_entity.BooleanType
+		}
+
+		parameters = append(parameters, parameter)
+	}
+
+	return parameters
+}
+
+// schemaType returns the type of the schema, the first non-null one of a list of types, e.g. ["string", "null"].
+func schemaType(schema map[string]any) string {
+	switch t := schema["type"].(type) {
+	case string:
+		return t
+	case []any:
+		for _, v := range t {
+			if s, _ := v.(string); s != "" && s != "null" {
+				return s
+			}
+		}
+	}
+
+	return ""
+}
+
+// pickAlternative returns the first typed alternative of the anyOf or oneOf schema, which commonly makes a property
+// nullable, the description of the outer schema is kept.
+func pickAlternative(schema map[string]any) map[string]any {
+	if schema == nil || schemaType(schema) != "" {
+		return schema
+	}
+
+	for _, keyword := range []string{"anyOf", "oneOf"} {
+		alternatives, _ := schema[keyword].([]any)
+
+		for _, alternative := range alternatives {
+			alternativeSchema := asMap(alternative)
+
+			if t := schemaType(alternativeSchema); t == "" || t == "null" {
+				continue
+			}
+
+			picked := make(map[string]any, len(alternativeSchema)+1)
+
+			for k, v := range alternativeSchema {
+				picked[k] = v
+			}
+
+			if description, ok := schema["description"]; ok {
+				picked["description"] = description
+			}
+			return picked
+		}
+	}
+
+	return schema
+}
+
+func asMap(node any) map[string]any {
+	object, _ := node.(map[string]any)
+	return object
+}
+
+func asStrings(node any) []string {
+	values, _ := node.([]any)
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	result := make([]string, 0, len(values))
+
+	for _, v := range values {
+		result = append(result, fmt.Sprint(v))
+	}
+
+	return result
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
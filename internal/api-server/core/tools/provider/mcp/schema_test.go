@@ -0,0 +1,123 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+)
+
+const searchInputSchema = `{
+  "type": "object",
+  "properties": {
+    "query": {"type": "string", "description": "The keywords"},
+    "limit": {"type": ["integer", "null"]},
+    "order": {"anyOf": [{"type": "null"}, {"type": "string", "enum": ["asc", "desc"]}], "description": "The order"},
+    "filters": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {"field": {"type": "string"}, "value": {"type": "string"}},
+        "required": ["field"]
+      }
+    }
+  },
+  "required": ["query"]
+}`
+
+func TestPromptMessageToolParameter(t *testing.T) {
+	var inputSchema map[string]any
+
+	if err := json.Unmarshal([]byte(searchInputSchema), &inputSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	parameter := PromptMessageToolParameter(inputSchema)
+
+	if parameter.Type != "object" || len(parameter.Properties) != 4 || len(parameter.Required) != 1 || parameter.Required[0] != "query" {
+		t.Fatalf("PromptMessageToolParameter() = %+v", parameter)
+	}
+
+	if limit := parameter.Properties["limit"]; limit.Type != "integer" {
+		t.Errorf("nullable property = %+v, want integer", limit)
+	}
+
+	if order := parameter.Properties["order"]; order.Type != "string" || len(order.Enum) != 2 || order.Description != "The order" {
+		t.Errorf("anyOf property = %+v", order)
+	}
+
+	filters := parameter.Properties["filters"]
+
+	if filters.Type != "array" || filters.Items == nil || filters.Items.Type != "object" || filters.Items.Properties["field"].Type != "string" || filters.Items.Required[0] != "field" {
+		t.Errorf("nested property = %+v", filters)
+	}
+
+	encoded, _ := json.Marshal(parameter)
+	var decoded map[string]any
+	json.Unmarshal(encoded, &decoded)
+
+	if query := decoded["properties"].(map[string]any)["query"].(map[string]any); query["type"] != "string" {
+		t.Errorf("encoded property = %v, want the type", query)
+	}
+}
+
+func TestToolParameters(t *testing.T) {
+	var inputSchema map[string]any
+
+	if err := json.Unmarshal([]byte(searchInputSchema), &inputSchema); err != nil {
+		t.Fatal(err)
+	}
+
+	parameters := ToolParameters(inputSchema)
+
+	if len(parameters) != 4 {
+		t.Fatalf("ToolParameters() = %d parameters, want 4", len(parameters))
+	}
+
+	// the parameters are sorted by name
+	filters, limit, order, query := parameters[0], parameters[1], parameters[2], parameters[3]
+
+	if filters.Type != biz_entity.StringType || limit.Type != biz_entity.NumberType || order.Type != biz_entity.SelectType || len(order.Options) != 2 {
+		t.Errorf("unexpected parameter types %s, %s, %s", filters.Type, limit.Type, order.Type)
+	}
+
+	if !query.Required || limit.Required || query.LLMDescription != "The keywords" {
+		t.Errorf("unexpected query parameter %+v", query)
+	}
+}
+
+func TestConvertResult(t *testing.T) {
+	toolRuntime := &biz_entity.ToolRuntimeConfiguration{}
+
+	messages, err := convertResult(toolRuntime, "draw", &CallToolResult{
+		Content: []*Content{
+			{Type: "text", Text: "done"},
+			{Type: "image", Data: base64.StdEncoding.EncodeToString([]byte("png")), MimeType: "image/png"},
+			{Type: "resource", Resource: &ResourceContents{URI: "file:///a.txt", Text: "content"}},
+			{Type: "resource_link", URI: "https://example.com/a.png"},
+		},
+	})
+
+	if err != nil {
+		t.Fatalf("convertResult() error = %v", err)
+	}
+
+	if len(messages) != 4 || messages[1].Type != biz_entity.BLOB || string(messages[1].Message.([]byte)) != "png" || messages[1].Meta["mime_type"] != "image/png" {
+		t.Fatalf("convertResult() = %+v", messages)
+	}
+
+	if messages[2].Message != "content" || messages[3].Type != biz_entity.LINK {
+		t.Errorf("convertResult() = %+v, %+v", messages[2], messages[3])
+	}
+
+	messages, err = convertResult(toolRuntime, "search", &CallToolResult{StructuredContent: json.RawMessage(`{"total":1}`)})
+
+	if err != nil || len(messages) != 1 || messages[0].Type != biz_entity.JSON {
+		t.Errorf("convertResult() of the structured content = %+v, %v", messages, err)
+	}
+}
@@ -0,0 +1,553 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	// SESSION_HEADER carries the session assigned by the streamable http servers in the initialize response
+	SESSION_HEADER = "Mcp-Session-Id"
+	// CLOSE_TIMEOUT is how long the subprocess is given to exit before it is killed, and the session to be deleted
+	CLOSE_TIMEOUT = 5 * time.Second
+	// MAX_MESSAGE_SIZE is the max size of a message read from the server
+	MAX_MESSAGE_SIZE = 16 << 20
+)
+
+var (
+	stdioCommandsMu sync.RWMutex
+	stdioCommands   = make(map[string]bool)
+
+	// stdioBaseEnv are the variables of the server passed on to the stdio servers, the others may carry its secrets
+	stdioBaseEnv = []string{"PATH", "HOME", "TMPDIR", "LANG"}
+	// stdioDeniedEnv are the variables which load the code or change the command run by the stdio servers
+	stdioDeniedEnv = []string{
+		"PATH", "GCONV_PATH", "BASH_ENV", "ENV", "SHELLOPTS", "IFS", "PERLLIB", "CLASSPATH",
+		"JAVA_TOOL_OPTIONS", "_JAVA_OPTIONS", "JDK_JAVA_OPTIONS",
+	}
+	// stdioDeniedEnvPrefixes are the prefixes of the variables of the loaders, interpreters and package managers
+	stdioDeniedEnvPrefixes = []string{"LD_", "DYLD_", "NODE_", "NPM_CONFIG_", "PYTHON", "PIP_", "UV_", "PERL5", "RUBY"}
+)
+
+// AllowStdioCommands sets the command lines which the stdio servers may be spawned with, the providers are
+// configured by the tenants so that the stdio transport is disabled unless the command lines are allowed. A command
+// line is the command followed by its arguments separated by spaces, the server is spawned only when its command
+// and arguments are exactly one of them.
+func AllowStdioCommands(commandLines []string) {
+	stdioCommandsMu.Lock()
+	defer stdioCommandsMu.Unlock()
+
+	stdioCommands = make(map[string]bool, len(commandLines))
+
+	for _, commandLine := range commandLines {
+		stdioCommands[strings.Join(strings.Fields(commandLine), " ")] = true
+	}
+}
+
+func isStdioCommandAllowed(command string, args []string) bool {
+	stdioCommandsMu.RLock()
+	defer stdioCommandsMu.RUnlock()
+
+	return stdioCommands[strings.Join(append([]string{command}, args...), " ")]
+}
+
+// checkStdioEnv rejects the variables of the stdio server which may inject the code into the subprocess.
+func checkStdioEnv(env map[string]string) error {
+	for name := range env {
+		upperName := strings.ToUpper(name)
+
+		denied := slices.Contains(stdioDeniedEnv, upperName) || slices.ContainsFunc(stdioDeniedEnvPrefixes, func(prefix string) bool {
+			return strings.HasPrefix(upperName, prefix)
+		})
+
+		if denied {
+			return errors.WithCode(code.ErrForbidden, "env %s of the stdio mcp server is not allowed", name)
+		}
+	}
+	return nil
+}
+
+// stdioEnv is the environment of the stdio server, the base variables of the server and the ones configured.
+func stdioEnv(env map[string]string) []string {
+	cmdEnv := make([]string, 0, len(stdioBaseEnv)+len(env))
+
+	for _, name := range stdioBaseEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			cmdEnv = append(cmdEnv, name+"="+value)
+		}
+	}
+
+	for name, value := range env {
+		cmdEnv = append(cmdEnv, name+"="+value)
+	}
+
+	return cmdEnv
+}
+
+// transport exchanges the json-rpc messages with the mcp server, the messages from the server are passed to
+// handle, fail is called once when the transport breaks.
+type transport interface {
+	start(ctx context.Context, handle func(*message), fail func(error)) error
+	send(ctx context.Context, msg *message) error
+	close() error
+}
+
+func newTransport(config *biz_entity.McpServerConfig, client *http.Client) (transport, error) {
+	switch config.Transport {
+	case biz_entity.McpStdioTransport:
+		if config.Command == "" {
+			return nil, errors.WithCode(code.ErrToolParameter, "command of the stdio mcp server is required")
+		}
+
+		if !isStdioCommandAllowed(config.Command, config.Args) {
+			return nil, errors.WithCode(code.ErrForbidden, "command %s of the stdio mcp server is not allowed with its arguments", config.Command)
+		}
+
+		if err := checkStdioEnv(config.Env); err != nil {
+			return nil, err
+		}
+		return &stdioTransport{config: config}, nil
+	case biz_entity.McpStreamableHTTPTransport, biz_entity.McpSSETransport:
+		if _, err := url.ParseRequestURI(config.URL); err != nil {
+			return nil, errors.WithCode(code.ErrToolParameter, "url %s of the mcp server is invalid", config.URL)
+		}
+
+		if config.Transport == biz_entity.McpSSETransport {
+			return &sseTransport{config: config, client: client}, nil
+		}
+		return &streamableHTTPTransport{config: config, client: client}, nil
+	}
+
+	return nil, errors.WithCode(code.ErrToolParameter, "transport %s of the mcp server is not supported", config.Transport)
+}
+
+// stdioTransport spawns the server and exchanges newline delimited messages over its stdin and stdout.
+type stdioTransport struct {
+	config *biz_entity.McpServerConfig
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	mu     sync.Mutex
+	exited chan struct{}
+}
+
+func (t *stdioTransport) start(ctx context.Context, handle func(*message), fail func(error)) error {
+	// the subprocess outlives the context of the request which opens the connection
+	t.cmd = exec.Command(t.config.Command, t.config.Args...)
+	t.cmd.Env = stdioEnv(t.config.Env)
+
+	stdin, err := t.cmd.StdinPipe()
+
+	if err != nil {
+		return errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	stdout, err := t.cmd.StdoutPipe()
+
+	if err != nil {
+		return errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	stderr, err := t.cmd.StderrPipe()
+
+	if err != nil {
+		return errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	if err := t.cmd.Start(); err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "start mcp server %s: %s", t.config.Command, err.Error())
+	}
+
+	t.stdin = stdin
+	t.exited = make(chan struct{})
+	stderrDone := make(chan struct{})
+
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+
+		for scanner.Scan() {
+			log.Debugf("mcp server %s: %s", t.config.Command, scanner.Text())
+		}
+	}()
+
+	go func() {
+		reader := bufio.NewReaderSize(stdout, 64<<10)
+
+		for {
+			line, err := reader.ReadBytes('\n')
+
+			if len(bytes.TrimSpace(line)) != 0 {
+				messages, decodeErr := decodeMessages(line)
+
+				if decodeErr != nil {
+					log.Warnf("mcp server %s wrote an invalid message: %s", t.config.Command, decodeErr.Error())
+				}
+
+				for _, msg := range messages {
+					handle(msg)
+				}
+			}
+
+			if err != nil {
+				// the pipes are closed by wait, so the reads have to be done first
+				<-stderrDone
+				waitErr := t.cmd.Wait()
+				close(t.exited)
+				fail(fmt.Errorf("mcp server %s exited: %v", t.config.Command, waitErr))
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (t *stdioTransport) send(ctx context.Context, msg *message) error {
+	encoded, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, err := t.stdin.Write(append(encoded, '\n')); err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "write to mcp server %s: %s", t.config.Command, err.Error())
+	}
+
+	return nil
+}
+
+func (t *stdioTransport) close() error {
+	if t.cmd == nil || t.cmd.Process == nil {
+		return nil
+	}
+
+	// closing stdin asks the server to exit, which is killed when it doesn't
+	t.stdin.Close()
+
+	select {
+	case <-t.exited:
+		return nil
+	case <-time.After(CLOSE_TIMEOUT):
+	}
+
+	if err := t.cmd.Process.Kill(); err != nil {
+		return err
+	}
+
+	<-t.exited
+	return nil
+}
+
+// streamableHTTPTransport posts each message to the endpoint, the server answers the requests with a json response
+// or an event stream which ends with the response.
+type streamableHTTPTransport struct {
+	config    *biz_entity.McpServerConfig
+	client    *http.Client
+	mu        sync.RWMutex
+	sessionID string
+	handle    func(*message)
+}
+
+func (t *streamableHTTPTransport) start(ctx context.Context, handle func(*message), fail func(error)) error {
+	t.handle = handle
+	return nil
+}
+
+func (t *streamableHTTPTransport) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.config.URL, body)
+
+	if err != nil {
+		return nil, errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	for k, v := range t.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	t.mu.RLock()
+	if t.sessionID != "" {
+		req.Header.Set(SESSION_HEADER, t.sessionID)
+	}
+	t.mu.RUnlock()
+
+	return req, nil
+}
+
+func (t *streamableHTTPTransport) send(ctx context.Context, msg *message) error {
+	encoded, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := t.newRequest(ctx, http.MethodPost, bytes.NewReader(encoded))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+
+	response, err := t.client.Do(req)
+
+	if err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "post to mcp server %s: %s", t.config.URL, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4<<10))
+		return errors.WithCode(code.ErrMcpConnection, "mcp server %s returned status %d: %s", t.config.URL, response.StatusCode, string(body))
+	}
+
+	if sessionID := response.Header.Get(SESSION_HEADER); sessionID != "" {
+		t.mu.Lock()
+		t.sessionID = sessionID
+		t.mu.Unlock()
+	}
+
+	// the notifications and responses are accepted without a body
+	if response.StatusCode == http.StatusAccepted || !msg.isRequest() {
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+
+	if mediaType == "text/event-stream" {
+		return readEvents(response.Body, func(event, data string) bool {
+			if event != "message" {
+				return true
+			}
+
+			messages, err := decodeMessages([]byte(data))
+
+			if err != nil {
+				log.Warnf("mcp server %s sent an invalid message: %s", t.config.URL, err.Error())
+				return true
+			}
+
+			for _, m := range messages {
+				t.handle(m)
+
+				// the stream is done once the response of the request arrives
+				if m.isResponse() && bytes.Equal(m.ID, msg.ID) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, MAX_MESSAGE_SIZE))
+
+	if err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "read from mcp server %s: %s", t.config.URL, err.Error())
+	}
+
+	messages, err := decodeMessages(body)
+
+	if err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "mcp server %s sent an invalid message: %s", t.config.URL, err.Error())
+	}
+
+	for _, m := range messages {
+		t.handle(m)
+	}
+
+	return nil
+}
+
+func (t *streamableHTTPTransport) close() error {
+	t.mu.RLock()
+	sessionID := t.sessionID
+	t.mu.RUnlock()
+
+	if sessionID == "" {
+		return nil
+	}
+
+	// the session is terminated explicitly, the servers which don't support it answer 405
+	ctx, cancel := context.WithTimeout(context.Background(), CLOSE_TIMEOUT)
+	defer cancel()
+
+	req, err := t.newRequest(ctx, http.MethodDelete, nil)
+
+	if err != nil {
+		return err
+	}
+
+	response, err := t.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	return response.Body.Close()
+}
+
+// sseTransport keeps an event stream open to receive the messages, and posts the messages to the endpoint which
+// the server announces in the first event of the stream.
+type sseTransport struct {
+	config   *biz_entity.McpServerConfig
+	client   *http.Client
+	endpoint string
+	cancel   context.CancelFunc
+}
+
+func (t *sseTransport) start(ctx context.Context, handle func(*message), fail func(error)) error {
+	streamCtx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequestWithContext(streamCtx, http.MethodGet, t.config.URL, nil)
+
+	if err != nil {
+		cancel()
+		return errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	for k, v := range t.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	// the stream is long-lived so the timeout of the client doesn't apply
+	streamClient := *t.client
+	streamClient.Timeout = 0
+
+	response, err := streamClient.Do(req)
+
+	if err != nil {
+		cancel()
+		return errors.WithCode(code.ErrMcpConnection, "connect to mcp server %s: %s", t.config.URL, err.Error())
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		cancel()
+		return errors.WithCode(code.ErrMcpConnection, "mcp server %s returned status %d", t.config.URL, response.StatusCode)
+	}
+
+	t.cancel = cancel
+	endpoint := make(chan string, 1)
+	streamDone := make(chan struct{})
+
+	go func() {
+		defer close(streamDone)
+		defer response.Body.Close()
+
+		err := readEvents(response.Body, func(event, data string) bool {
+			switch event {
+			case "endpoint":
+				select {
+				case endpoint <- data:
+				default:
+				}
+			case "message":
+				messages, err := decodeMessages([]byte(data))
+
+				if err != nil {
+					log.Warnf("mcp server %s sent an invalid message: %s", t.config.URL, err.Error())
+					return true
+				}
+
+				for _, msg := range messages {
+					handle(msg)
+				}
+			}
+			return true
+		})
+
+		if err == nil {
+			err = io.EOF
+		}
+		fail(fmt.Errorf("event stream of mcp server %s closed: %v", t.config.URL, err))
+	}()
+
+	select {
+	case data := <-endpoint:
+		base, _ := url.Parse(t.config.URL)
+		endpointURL, err := base.Parse(data)
+
+		if err != nil {
+			cancel()
+			return errors.WithCode(code.ErrMcpConnection, "endpoint %s of mcp server %s is invalid", data, t.config.URL)
+		}
+
+		t.endpoint = endpointURL.String()
+		return nil
+	case <-streamDone:
+		return errors.WithCode(code.ErrMcpConnection, "event stream of mcp server %s closed before the endpoint is announced", t.config.URL)
+	case <-ctx.Done():
+		cancel()
+		return errors.WithCode(code.ErrMcpConnection, "mcp server %s didn't announce the endpoint: %s", t.config.URL, ctx.Err().Error())
+	}
+}
+
+func (t *sseTransport) send(ctx context.Context, msg *message) error {
+	encoded, err := json.Marshal(msg)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(encoded))
+
+	if err != nil {
+		return errors.WithSCode(code.ErrMcpConnection, err.Error())
+	}
+
+	for k, v := range t.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	response, err := t.client.Do(req)
+
+	if err != nil {
+		return errors.WithCode(code.ErrMcpConnection, "post to mcp server %s: %s", t.endpoint, err.Error())
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4<<10))
+		return errors.WithCode(code.ErrMcpConnection, "mcp server %s returned status %d: %s", t.endpoint, response.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+func (t *sseTransport) close() error {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	return nil
+}
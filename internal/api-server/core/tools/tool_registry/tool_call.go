@@ -33,6 +33,13 @@ func (ac *modelRegistryCall) Invoke(ctx context.Context, toolParameters []byte)
 
 	toolKeyMapInvoke := fmt.Sprintf("%s/%s", ac.toolRuntime.Identity.Provider, ac.toolRuntime.Identity.Name)
 
+	switch ac.toolRuntime.ProviderType {
+	case biz_entity.ToolProviderTypeAPI:
+		toolKeyMapInvoke = API_TOOL_REGISTRY
+	case biz_entity.ToolProviderTypeMCP:
+		toolKeyMapInvoke = MCP_TOOL_REGISTRY
+	}
+
 	log.Infof("invoke %s", toolKeyMapInvoke)
 
 	toolIns, err := ToolRuntimeRegistry.Acquire(toolKeyMapInvoke)
@@ -17,6 +17,11 @@ import (
 
 const (
 	TOOL_NUMBER = 100
+	// API_TOOL_REGISTRY is the registry of the tools of the api providers, which are dispatched by the provider type
+	// rather than the provider and tool name
+	API_TOOL_REGISTRY = "api"
+	// MCP_TOOL_REGISTRY is the registry of the tools of the mcp providers, which are dispatched like the api tools
+	MCP_TOOL_REGISTRY = "mcp"
 )
 
 type IToolCallRegistry interface {
@@ -0,0 +1,253 @@
+package domain_service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/api"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	biz_app_config "github.com/lunarianss/Luna/internal/api-server/domain/app/entity/biz_entity/provider_app_config"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+// HIDDEN_API_KEY is returned in place of the api key of the api providers, and is sent back by the console when the
+// key is unchanged
+const HIDDEN_API_KEY = "[__HIDDEN__]"
+
+// ParseApiSchema parses the openapi or swagger schema into the tool bundles.
+func (ad *AgentDomain) ParseApiSchema(ctx context.Context, schema string) (biz_entity.ApiSchemaType, []*biz_entity.ApiToolBundle, error) {
+	return api.ParseSchema([]byte(schema))
+}
+
+// SaveApiToolProvider parses the schema and encrypts the api key of the provider, the provider is created when it
+// has no id.
+func (ad *AgentDomain) SaveApiToolProvider(ctx context.Context, provider *po_entity.ToolApiProvider, credentials *biz_entity.ApiProviderCredentials, encryptPublicKey string) error {
+	// the stored api key which is encrypted already is kept when the console sends back the hidden key
+	keepApiKey := credentials.APIKeyValue == HIDDEN_API_KEY
+
+	if keepApiKey {
+		credentials.APIKeyValue, _ = provider.Credentials["api_key_value"].(string)
+	}
+
+	if err := ad.validateApiCredentials(credentials); err != nil {
+		return err
+	}
+
+	sameNameProvider, err := ad.AgentRepo.GetApiToolProviderByName(ctx, provider.TenantID, provider.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if sameNameProvider != nil && sameNameProvider.ID != provider.ID {
+		return errors.WithCode(code.ErrApiToolProviderExist, "api tool provider %s already exists", provider.Name)
+	}
+
+	schemaType, bundles, err := ad.ParseApiSchema(ctx, provider.Schema)
+
+	if err != nil {
+		return err
+	}
+
+	toolsStr, err := json.Marshal(bundles)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	provider.SchemaType = string(schemaType)
+	provider.ToolsStr = string(toolsStr)
+
+	if !keepApiKey && credentials.APIKeyValue != "" {
+		encryptedKey, err := util.Encrypt(credentials.APIKeyValue, encryptPublicKey)
+
+		if err != nil {
+			return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+		credentials.APIKeyValue = encryptedKey
+	}
+
+	provider.Credentials = credentials.ToMap()
+
+	if provider.ID == "" {
+		return ad.AgentRepo.CreateApiToolProvider(ctx, provider)
+	}
+	return ad.AgentRepo.UpdateApiToolProvider(ctx, provider)
+}
+
+func (ad *AgentDomain) validateApiCredentials(credentials *biz_entity.ApiProviderCredentials) error {
+	switch credentials.AuthType {
+	case biz_entity.ApiAuthNone:
+		credentials.APIKeyName, credentials.APIKeyPrefix, credentials.APIKeyValue = "", "", ""
+		return nil
+	case biz_entity.ApiAuthKeyInHeader, biz_entity.ApiAuthKeyInQuery, biz_entity.ApiAuthBearerHeader:
+		if credentials.APIKeyValue == "" {
+			return errors.WithCode(code.ErrToolParameter, "api key is required for the auth type %s", credentials.AuthType)
+		}
+		return nil
+	}
+
+	return errors.WithCode(code.ErrToolParameter, "auth type %s is not supported", credentials.AuthType)
+}
+
+// ListApiToolProviders converts the api tool providers of the tenant into the user providers with masked api keys.
+func (ad *AgentDomain) ListApiToolProviders(ctx context.Context, tenantID string) ([]*biz_entity.UserToolProvider, error) {
+	providers, err := ad.AgentRepo.GetApiToolProvidersByTenant(ctx, tenantID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz_entity.UserToolProvider, 0, len(providers))
+
+	for _, provider := range providers {
+		userProvider, err := ad.ApiProviderToUserProvider(provider)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, userProvider)
+	}
+
+	return result, nil
+}
+
+// ApiProviderToUserProvider converts the api tool provider, each bundle is a tool of the provider.
+func (ad *AgentDomain) ApiProviderToUserProvider(provider *po_entity.ToolApiProvider) (*biz_entity.UserToolProvider, error) {
+	bundles, err := ad.ApiToolBundles(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider := &biz_entity.UserToolProvider{
+		ID:                  provider.ID,
+		Author:              provider.UserID,
+		Name:                provider.Name,
+		Description:         &common.I18nObject{En_US: provider.Description, Zh_Hans: provider.Description},
+		Icon:                provider.Icon,
+		Label:               &common.I18nObject{En_US: provider.Name, Zh_Hans: provider.Name},
+		Type:                biz_entity.ToolProviderTypeAPI,
+		MaskedCredentials:   ad.MaskApiCredentials(provider.Credentials),
+		IsTeamAuthorization: true,
+		AllowDelete:         true,
+		Tools:               make([]*biz_entity.UserTool, 0, len(bundles)),
+		Labels:              make([]string, 0),
+	}
+
+	for _, bundle := range bundles {
+		userProvider.Tools = append(userProvider.Tools, &biz_entity.UserTool{
+			Author:      provider.UserID,
+			Name:        bundle.OperationID,
+			Label:       &common.I18nObject{En_US: bundle.OperationID, Zh_Hans: bundle.OperationID},
+			Description: &common.I18nObject{En_US: bundle.Summary, Zh_Hans: bundle.Summary},
+			Parameters:  bundle.Parameters,
+			Labels:      make([]string, 0),
+		})
+	}
+
+	return userProvider, nil
+}
+
+// MaskApiCredentials replaces the api key of the credentials with HIDDEN_API_KEY.
+func (ad *AgentDomain) MaskApiCredentials(credentials map[string]any) map[string]any {
+	masked := make(map[string]any, len(credentials))
+
+	for k, v := range credentials {
+		masked[k] = v
+	}
+
+	if apiKey, _ := masked["api_key_value"].(string); apiKey != "" {
+		masked["api_key_value"] = HIDDEN_API_KEY
+	}
+
+	return masked
+}
+
+func (ad *AgentDomain) ApiToolBundles(provider *po_entity.ToolApiProvider) ([]*biz_entity.ApiToolBundle, error) {
+	var bundles []*biz_entity.ApiToolBundle
+
+	if err := json.Unmarshal([]byte(provider.ToolsStr), &bundles); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	return bundles, nil
+}
+
+// GetApiToolRuntime builds the runtime of the tool of the api provider with the decrypted api key.
+func (ad *AgentDomain) GetApiToolRuntime(ctx context.Context, tenantID, providerID, toolName string) (*biz_entity.ToolRuntimeConfiguration, error) {
+	provider, err := ad.AgentRepo.GetApiToolProviderByID(ctx, tenantID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == nil {
+		return nil, errors.WithCode(code.ErrResourceNotFound, "api tool provider %s is not found", providerID)
+	}
+
+	bundles, err := ad.ApiToolBundles(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bundle := range bundles {
+		if bundle.OperationID != toolName {
+			continue
+		}
+
+		credentials := biz_entity.ApiProviderCredentialsFromMap(provider.Credentials)
+
+		if credentials.APIKeyValue != "" {
+			credentials.APIKeyValue, err = util.Decrypt(credentials.APIKeyValue, tenantID, &util.FileStorage{})
+
+			if err != nil {
+				return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+			}
+		}
+
+		return &biz_entity.ToolRuntimeConfiguration{
+			ToolStaticConfiguration: &biz_entity.ToolStaticConfiguration{
+				Identity: &biz_entity.ToolIdentity{
+					Author:   provider.UserID,
+					Name:     bundle.OperationID,
+					Label:    &common.I18nObject{En_US: bundle.OperationID, Zh_Hans: bundle.OperationID},
+					Provider: provider.Name,
+					Icon:     provider.Icon,
+				},
+				Parameters: bundle.Parameters,
+				Description: &biz_entity.ToolDescription{
+					Human: &common.I18nObject{En_US: bundle.Summary, Zh_Hans: bundle.Summary},
+					LLM:   bundle.Summary,
+				},
+				IsTeamAuthorization: true,
+			},
+			TenantID:       tenantID,
+			ToolInvokeFrom: biz_entity.AgentInvoke,
+			Credentials:    credentials.ToMap(),
+			ProviderType:   biz_entity.ToolProviderTypeAPI,
+			ApiBundle:      bundle,
+		}, nil
+	}
+
+	return nil, errors.WithCode(code.ErrResourceNotFound, "tool %s of api tool provider %s is not found", toolName, provider.Name)
+}
+
+// GetAgentToolRuntime resolves the runtime of the agent tool, the api and mcp tools are loaded from the providers of
+// the tenant and the others are resolved by the tool manager.
+func (ad *AgentDomain) GetAgentToolRuntime(ctx context.Context, tenantID, appID string, agentTool *biz_app_config.AgentToolEntity, invokeFrom string) (*biz_entity.ToolRuntimeConfiguration, error) {
+	switch agentTool.ProviderType {
+	case biz_app_config.API:
+		return ad.GetApiToolRuntime(ctx, tenantID, agentTool.ProviderID, agentTool.ToolName)
+	case biz_app_config.MCP:
+		return ad.GetMcpToolRuntime(ctx, tenantID, agentTool.ProviderID, agentTool.ToolName)
+	}
+
+	return ad.ToolManager.GetAgentToolRuntime(tenantID, appID, agentTool, invokeFrom)
+}
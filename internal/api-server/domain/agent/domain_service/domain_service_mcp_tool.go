@@ -0,0 +1,324 @@
+package domain_service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/mcp"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/po_entity"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+// SaveMcpToolProvider connects to the mcp server to list its tools and encrypts the env and headers of the provider,
+// the provider is created when it has no id. The secrets sent back hidden keep the stored values.
+func (ad *AgentDomain) SaveMcpToolProvider(ctx context.Context, provider *po_entity.ToolMcpProvider, server *biz_entity.McpServerConfig, encryptPublicKey string) error {
+	sameNameProvider, err := ad.AgentRepo.GetMcpToolProviderByName(ctx, provider.TenantID, provider.Name)
+
+	if err != nil {
+		return err
+	}
+
+	if sameNameProvider != nil && sameNameProvider.ID != provider.ID {
+		return errors.WithCode(code.ErrMcpToolProviderExist, "mcp tool provider %s already exists", provider.Name)
+	}
+
+	env, err := ad.mergeMcpSecrets(provider.TenantID, server.Env, provider.Env)
+
+	if err != nil {
+		return err
+	}
+
+	headers, err := ad.mergeMcpSecrets(provider.TenantID, server.Headers, provider.Headers)
+
+	if err != nil {
+		return err
+	}
+
+	server.Env, server.Headers = env, headers
+
+	tools, err := ad.ListMcpServerTools(ctx, server)
+
+	if err != nil {
+		return err
+	}
+
+	toolsStr, err := json.Marshal(tools)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	if provider.Env, err = ad.encryptMcpSecrets(env, encryptPublicKey); err != nil {
+		return err
+	}
+
+	if provider.Headers, err = ad.encryptMcpSecrets(headers, encryptPublicKey); err != nil {
+		return err
+	}
+
+	provider.Transport = string(server.Transport)
+	provider.Command = server.Command
+	provider.Args = server.Args
+	provider.URL = server.URL
+	provider.ToolsStr = string(toolsStr)
+
+	if provider.ID == "" {
+		return ad.AgentRepo.CreateMcpToolProvider(ctx, provider)
+	}
+
+	if err := ad.AgentRepo.UpdateMcpToolProvider(ctx, provider); err != nil {
+		return err
+	}
+
+	// the connection with the former config is closed, the next call reconnects with the new one
+	mcp.Connections.Remove(provider.ID)
+	return nil
+}
+
+// ListMcpServerTools connects to the mcp server with a short-lived connection to list its tools.
+func (ad *AgentDomain) ListMcpServerTools(ctx context.Context, server *biz_entity.McpServerConfig) ([]*biz_entity.McpToolBundle, error) {
+	client, err := mcp.Connect(ctx, server)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer client.Close()
+
+	return client.ListTools(ctx)
+}
+
+// DeleteMcpToolProvider deletes the provider and closes its connection.
+func (ad *AgentDomain) DeleteMcpToolProvider(ctx context.Context, tenantID, providerID string) error {
+	if err := ad.AgentRepo.DeleteMcpToolProvider(ctx, tenantID, providerID); err != nil {
+		return err
+	}
+
+	mcp.Connections.Remove(providerID)
+	return nil
+}
+
+// mergeMcpSecrets replaces the hidden values with the decrypted stored values.
+func (ad *AgentDomain) mergeMcpSecrets(tenantID string, secrets map[string]string, stored map[string]string) (map[string]string, error) {
+	merged := make(map[string]string, len(secrets))
+
+	for k, v := range secrets {
+		if v == HIDDEN_API_KEY {
+			encrypted, ok := stored[k]
+
+			if !ok {
+				return nil, errors.WithCode(code.ErrToolParameter, "value of %s is required", k)
+			}
+
+			decrypted, err := util.Decrypt(encrypted, tenantID, &util.FileStorage{})
+
+			if err != nil {
+				return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+			}
+			v = decrypted
+		}
+		merged[k] = v
+	}
+
+	return merged, nil
+}
+
+func (ad *AgentDomain) encryptMcpSecrets(secrets map[string]string, encryptPublicKey string) (map[string]string, error) {
+	encrypted := make(map[string]string, len(secrets))
+
+	for k, v := range secrets {
+		encryptedValue, err := util.Encrypt(v, encryptPublicKey)
+
+		if err != nil {
+			return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+		encrypted[k] = encryptedValue
+	}
+
+	return encrypted, nil
+}
+
+func (ad *AgentDomain) decryptMcpSecrets(tenantID string, secrets map[string]string) (map[string]string, error) {
+	decrypted := make(map[string]string, len(secrets))
+
+	for k, v := range secrets {
+		decryptedValue, err := util.Decrypt(v, tenantID, &util.FileStorage{})
+
+		if err != nil {
+			return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+		decrypted[k] = decryptedValue
+	}
+
+	return decrypted, nil
+}
+
+// ListMcpToolProviders converts the mcp tool providers of the tenant into the user providers with masked secrets.
+func (ad *AgentDomain) ListMcpToolProviders(ctx context.Context, tenantID string) ([]*biz_entity.UserToolProvider, error) {
+	providers, err := ad.AgentRepo.GetMcpToolProvidersByTenant(ctx, tenantID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*biz_entity.UserToolProvider, 0, len(providers))
+
+	for _, provider := range providers {
+		userProvider, err := ad.McpProviderToUserProvider(provider)
+
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, userProvider)
+	}
+
+	return result, nil
+}
+
+// McpProviderToUserProvider converts the mcp tool provider, the server config is presented as the credentials.
+func (ad *AgentDomain) McpProviderToUserProvider(provider *po_entity.ToolMcpProvider) (*biz_entity.UserToolProvider, error) {
+	bundles, err := ad.McpToolBundles(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	userProvider := &biz_entity.UserToolProvider{
+		ID:                  provider.ID,
+		Author:              provider.UserID,
+		Name:                provider.Name,
+		Description:         &common.I18nObject{En_US: provider.Description, Zh_Hans: provider.Description},
+		Icon:                provider.Icon,
+		Label:               &common.I18nObject{En_US: provider.Name, Zh_Hans: provider.Name},
+		Type:                biz_entity.ToolProviderTypeMCP,
+		MaskedCredentials:   ad.MaskMcpServerConfig(provider),
+		IsTeamAuthorization: true,
+		AllowDelete:         true,
+		Tools:               make([]*biz_entity.UserTool, 0, len(bundles)),
+		Labels:              make([]string, 0),
+	}
+
+	for _, bundle := range bundles {
+		userProvider.Tools = append(userProvider.Tools, &biz_entity.UserTool{
+			Author:      provider.UserID,
+			Name:        bundle.Name,
+			Label:       &common.I18nObject{En_US: bundle.Name, Zh_Hans: bundle.Name},
+			Description: &common.I18nObject{En_US: bundle.Description, Zh_Hans: bundle.Description},
+			Parameters:  mcp.ToolParameters(bundle.InputSchema),
+			Labels:      make([]string, 0),
+		})
+	}
+
+	return userProvider, nil
+}
+
+// MaskMcpServerConfig returns the server config of the provider with the env and header values replaced by
+// HIDDEN_API_KEY.
+func (ad *AgentDomain) MaskMcpServerConfig(provider *po_entity.ToolMcpProvider) map[string]any {
+	mask := func(secrets map[string]string) map[string]string {
+		masked := make(map[string]string, len(secrets))
+
+		for k := range secrets {
+			masked[k] = HIDDEN_API_KEY
+		}
+		return masked
+	}
+
+	return map[string]any{
+		"transport": provider.Transport,
+		"command":   provider.Command,
+		"args":      provider.Args,
+		"env":       mask(provider.Env),
+		"url":       provider.URL,
+		"headers":   mask(provider.Headers),
+	}
+}
+
+func (ad *AgentDomain) McpToolBundles(provider *po_entity.ToolMcpProvider) ([]*biz_entity.McpToolBundle, error) {
+	var bundles []*biz_entity.McpToolBundle
+
+	if err := json.Unmarshal([]byte(provider.ToolsStr), &bundles); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	return bundles, nil
+}
+
+// GetMcpToolRuntime builds the runtime of the tool of the mcp provider with the decrypted server config, the
+// connection is versioned by the update time of the provider.
+func (ad *AgentDomain) GetMcpToolRuntime(ctx context.Context, tenantID, providerID, toolName string) (*biz_entity.ToolRuntimeConfiguration, error) {
+	provider, err := ad.AgentRepo.GetMcpToolProviderByID(ctx, tenantID, providerID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if provider == nil {
+		return nil, errors.WithCode(code.ErrResourceNotFound, "mcp tool provider %s is not found", providerID)
+	}
+
+	bundles, err := ad.McpToolBundles(provider)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, bundle := range bundles {
+		if bundle.Name != toolName {
+			continue
+		}
+
+		env, err := ad.decryptMcpSecrets(tenantID, provider.Env)
+
+		if err != nil {
+			return nil, err
+		}
+
+		headers, err := ad.decryptMcpSecrets(tenantID, provider.Headers)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &biz_entity.ToolRuntimeConfiguration{
+			ToolStaticConfiguration: &biz_entity.ToolStaticConfiguration{
+				Identity: &biz_entity.ToolIdentity{
+					Author:   provider.UserID,
+					Name:     bundle.Name,
+					Label:    &common.I18nObject{En_US: bundle.Name, Zh_Hans: bundle.Name},
+					Provider: provider.Name,
+					Icon:     provider.Icon,
+				},
+				Parameters: mcp.ToolParameters(bundle.InputSchema),
+				Description: &biz_entity.ToolDescription{
+					Human: &common.I18nObject{En_US: bundle.Description, Zh_Hans: bundle.Description},
+					LLM:   bundle.Description,
+				},
+				IsTeamAuthorization: true,
+			},
+			TenantID:       tenantID,
+			ToolInvokeFrom: biz_entity.AgentInvoke,
+			ProviderType:   biz_entity.ToolProviderTypeMCP,
+			Mcp: &biz_entity.McpToolRuntime{
+				ProviderID: provider.ID,
+				Version:    provider.UpdatedAt,
+				Server: &biz_entity.McpServerConfig{
+					Transport: biz_entity.McpTransportType(provider.Transport),
+					Command:   provider.Command,
+					Args:      provider.Args,
+					Env:       env,
+					URL:       provider.URL,
+					Headers:   headers,
+				},
+				Bundle: bundle,
+			},
+		}, nil
+	}
+
+	return nil, errors.WithCode(code.ErrResourceNotFound, "tool %s of mcp tool provider %s is not found", toolName, provider.Name)
+}
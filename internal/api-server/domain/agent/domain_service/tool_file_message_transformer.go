@@ -32,7 +32,9 @@ func (ttr *ToolFileMessageTransformer) TransformToolInvokeMessages(ctx context.C
 			if err := ttr.handleBlobMessage(ctx, toolMessage, userID, tenantID, conversationID); err != nil {
 				return nil, err
 			}
+			continue
 		}
+		ttr.toolMessages = append(ttr.toolMessages, toolMessage)
 	}
 
 	return ttr.toolMessages, nil
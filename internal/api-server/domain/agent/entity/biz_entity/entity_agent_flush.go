@@ -5,6 +5,8 @@ import "context"
 type AgentFlusher interface {
 	AgentThoughtToStreamResponse(ctx context.Context, agentThoughtID string) error
 	AgentMessageToStreamResponse(answer string) error
+	MessageReplaceToStreamResponse(answer string) error
 	AgentMessageFileToStreamResponse(ctx context.Context, messageFileID string, secretKey string, baseUrl string) error
+	AgentApprovalRequiredToStreamResponse(ctx context.Context, agentThoughtID string) error
 	InitFlusher(ctx context.Context)
 }
@@ -0,0 +1,72 @@
+package biz_entity
+
+type ApiSchemaType string
+
+const (
+	OpenAPISchema ApiSchemaType = "openapi"
+	SwaggerSchema ApiSchemaType = "swagger"
+)
+
+type ApiParameterLocation string
+
+const (
+	PathLocation   ApiParameterLocation = "path"
+	QueryLocation  ApiParameterLocation = "query"
+	HeaderLocation ApiParameterLocation = "header"
+	CookieLocation ApiParameterLocation = "cookie"
+	BodyLocation   ApiParameterLocation = "body"
+)
+
+type ApiAuthType string
+
+const (
+	ApiAuthNone         ApiAuthType = "none"
+	ApiAuthKeyInHeader  ApiAuthType = "api_key_header"
+	ApiAuthKeyInQuery   ApiAuthType = "api_key_query"
+	ApiAuthBearerHeader ApiAuthType = "bearer"
+)
+
+// ApiToolBundle is an operation of the openapi or swagger schema, which is invoked as a tool of the api provider.
+type ApiToolBundle struct {
+	OperationID     string                          `json:"operation_id"`
+	Summary         string                          `json:"summary"`
+	ServerURL       string                          `json:"server_url"`
+	Method          string                          `json:"method"`
+	Path            string                          `json:"path"`
+	Parameters      []*ToolParameter                `json:"parameters"`
+	Locations       map[string]ApiParameterLocation `json:"locations"`
+	BodyContentType string                          `json:"body_content_type,omitempty"`
+}
+
+// ApiProviderCredentials configures how the requests of the api tools are authorized.
+type ApiProviderCredentials struct {
+	AuthType ApiAuthType `json:"auth_type"`
+	// APIKeyName is the header name for api_key_header and the query parameter name for api_key_query
+	APIKeyName string `json:"api_key_name,omitempty"`
+	// APIKeyPrefix is prepended to the key in the header, e.g. "Basic"
+	APIKeyPrefix string `json:"api_key_prefix,omitempty"`
+	APIKeyValue  string `json:"api_key_value,omitempty"`
+}
+
+func (c *ApiProviderCredentials) ToMap() map[string]any {
+	return map[string]any{
+		"auth_type":      string(c.AuthType),
+		"api_key_name":   c.APIKeyName,
+		"api_key_prefix": c.APIKeyPrefix,
+		"api_key_value":  c.APIKeyValue,
+	}
+}
+
+func ApiProviderCredentialsFromMap(credentials map[string]any) *ApiProviderCredentials {
+	result := &ApiProviderCredentials{AuthType: ApiAuthNone}
+
+	if authType, ok := credentials["auth_type"].(string); ok && authType != "" {
+		result.AuthType = ApiAuthType(authType)
+	}
+
+	result.APIKeyName, _ = credentials["api_key_name"].(string)
+	result.APIKeyPrefix, _ = credentials["api_key_prefix"].(string)
+	result.APIKeyValue, _ = credentials["api_key_value"].(string)
+
+	return result
+}
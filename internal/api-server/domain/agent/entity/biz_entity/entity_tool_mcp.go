@@ -0,0 +1,39 @@
+package biz_entity
+
+type McpTransportType string
+
+const (
+	// McpStdioTransport spawns the server as a subprocess and exchanges newline delimited messages over stdio
+	McpStdioTransport McpTransportType = "stdio"
+	// McpStreamableHTTPTransport posts the messages to the endpoint which responds with json or an event stream
+	McpStreamableHTTPTransport McpTransportType = "streamable_http"
+	// McpSSETransport receives the messages from a long-lived event stream and posts to the endpoint it announces
+	McpSSETransport McpTransportType = "sse"
+)
+
+// McpServerConfig describes how to connect to the mcp server of the provider, the values of Env and Headers are
+// secrets which are stored encrypted.
+type McpServerConfig struct {
+	Transport McpTransportType  `json:"transport"`
+	Command   string            `json:"command,omitempty"`
+	Args      []string          `json:"args,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// McpToolBundle is a tool listed by the mcp server, the input schema is the json schema of the arguments.
+type McpToolBundle struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+// McpToolRuntime locates the connection of the mcp provider, the connection is reopened when the version of the
+// provider changes.
+type McpToolRuntime struct {
+	ProviderID string           `json:"provider_id"`
+	Version    int64            `json:"version"`
+	Server     *McpServerConfig `json:"-"`
+	Bundle     *McpToolBundle   `json:"bundle"`
+}
@@ -11,6 +11,11 @@ type ToolRuntimeConfiguration struct {
 	Credentials       map[string]any `json:"credentials" `
 	RuntimeParameters map[string]any `json:"runtime_parameters"`
 	ConfPath          string         `json:"conf_path"`
+	// ProviderType and ApiBundle are set for the tools of the api providers, which are invoked by the generic api tool
+	ProviderType ToolProviderType `json:"provider_type,omitempty"`
+	ApiBundle    *ApiToolBundle   `json:"api_bundle,omitempty"`
+	// Mcp is set for the tools of the mcp providers, which are invoked through the connection of the provider
+	Mcp *McpToolRuntime `json:"mcp,omitempty"`
 }
 
 func (tc *ToolRuntimeConfiguration) GetAllRuntimeParameters() []*ToolParameter {
@@ -7,6 +7,7 @@ type UserToolProviderTypeLiteral string
 const (
 	Builtin  UserToolProviderTypeLiteral = "builtin"
 	API      UserToolProviderTypeLiteral = "api"
+	MCP      UserToolProviderTypeLiteral = "mcp"
 	Workflow UserToolProviderTypeLiteral = "workflow"
 )
 
@@ -20,6 +21,8 @@ const (
 	ToolProviderTypeWorkflow ToolProviderType = "workflow"
 	// API tool provider type
 	ToolProviderTypeAPI ToolProviderType = "api"
+	// MCP tool provider type
+	ToolProviderTypeMCP ToolProviderType = "mcp"
 	// App tool provider type
 	ToolProviderTypeApp ToolProviderType = "app"
 	// Dataset retrieval tool provider type
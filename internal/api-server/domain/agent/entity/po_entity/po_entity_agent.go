@@ -1,6 +1,8 @@
 package po_entity
 
 import (
+	"slices"
+
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -11,6 +13,21 @@ type ToolEngineInvokeMeta struct {
 	ToolConfig map[string]any `json:"tool_config"`
 }
 
+const (
+	TOOL_APPROVAL_PENDING  = "pending"
+	TOOL_APPROVAL_APPROVED = "approved"
+	TOOL_APPROVAL_REJECTED = "rejected"
+)
+
+// ToolApproval is the decision of a person on a tool call of the agent requiring approval.
+type ToolApproval struct {
+	ToolName  string `json:"tool_name"`
+	ToolInput string `json:"tool_input"`
+	Status    string `json:"status"`
+	DecidedBy string `json:"decided_by"`
+	DecidedAt int64  `json:"decided_at"`
+}
+
 type MessageAgentThought struct {
 	ID               string                           `json:"id" gorm:"column:id"`
 	MessageID        string                           `json:"message_id" gorm:"column:message_id"`
@@ -39,6 +56,24 @@ type MessageAgentThought struct {
 	CreatedByRole    string                           `json:"created_by_role" gorm:"column:created_by_role"`
 	CreatedBy        string                           `json:"created_by" gorm:"column:created_by"`
 	CreatedAt        int64                            `json:"created_at" gorm:"column:created_at"`
+	// ToolApprovals is keyed by the id of the tool call, ApprovalState is the state of the runner paused until the
+	// approvals are decided.
+	ToolApprovals map[string]*ToolApproval `json:"tool_approvals" gorm:"column:tool_approvals;serializer:json"`
+	ApprovalState string                   `json:"-" gorm:"column:approval_state"`
+}
+
+// PendingApprovals returns the ids of the tool calls still waiting for approval.
+func (m *MessageAgentThought) PendingApprovals() []string {
+	var toolCallIDs []string
+
+	for toolCallID, approval := range m.ToolApprovals {
+		if approval.Status == TOOL_APPROVAL_PENDING {
+			toolCallIDs = append(toolCallIDs, toolCallID)
+		}
+	}
+
+	slices.Sort(toolCallIDs)
+	return toolCallIDs
 }
 
 func (*MessageAgentThought) TableName() string {
@@ -109,3 +144,58 @@ func (m *MessageFile) BeforeCreate(tx *gorm.DB) (err error) {
 	m.ID = uuid.NewString()
 	return
 }
+
+// ToolApiProvider is a tool provider imported from an openapi or swagger schema by the tenant, each operation of
+// the schema is a tool of the provider.
+type ToolApiProvider struct {
+	ID          string         `json:"id" gorm:"column:id"`
+	TenantID    string         `json:"tenant_id" gorm:"column:tenant_id"`
+	UserID      string         `json:"user_id" gorm:"column:user_id"`
+	Name        string         `json:"name" gorm:"column:name"`
+	Icon        string         `json:"icon" gorm:"column:icon"`
+	Description string         `json:"description" gorm:"column:description"`
+	SchemaType  string         `json:"schema_type" gorm:"column:schema_type"`
+	Schema      string         `json:"schema" gorm:"column:schema"`
+	ToolsStr    string         `json:"tools_str" gorm:"column:tools_str"`
+	Credentials map[string]any `json:"credentials" gorm:"column:credentials;serializer:json"`
+	CreatedAt   int64          `json:"created_at" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   int64          `json:"updated_at" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (*ToolApiProvider) TableName() string {
+	return "tool_api_providers"
+}
+
+func (t *ToolApiProvider) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.NewString()
+	return
+}
+
+// ToolMcpProvider is a tool provider backed by a mcp server of the tenant, the tools listed by the server are the
+// tools of the provider, the values of Env and Headers are encrypted.
+type ToolMcpProvider struct {
+	ID          string            `json:"id" gorm:"column:id"`
+	TenantID    string            `json:"tenant_id" gorm:"column:tenant_id"`
+	UserID      string            `json:"user_id" gorm:"column:user_id"`
+	Name        string            `json:"name" gorm:"column:name"`
+	Icon        string            `json:"icon" gorm:"column:icon"`
+	Description string            `json:"description" gorm:"column:description"`
+	Transport   string            `json:"transport" gorm:"column:transport"`
+	Command     string            `json:"command" gorm:"column:command"`
+	Args        []string          `json:"args" gorm:"column:args;serializer:json"`
+	Env         map[string]string `json:"env" gorm:"column:env;serializer:json"`
+	URL         string            `json:"url" gorm:"column:url"`
+	Headers     map[string]string `json:"headers" gorm:"column:headers;serializer:json"`
+	ToolsStr    string            `json:"tools_str" gorm:"column:tools_str"`
+	CreatedAt   int64             `json:"created_at" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   int64             `json:"updated_at" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (*ToolMcpProvider) TableName() string {
+	return "tool_mcp_providers"
+}
+
+func (t *ToolMcpProvider) BeforeCreate(tx *gorm.DB) (err error) {
+	t.ID = uuid.NewString()
+	return
+}
@@ -17,4 +17,21 @@ type AgentRepo interface {
 	GetToolFileByTenant(ctx context.Context, toolFileID string, accountID, tenantID string) (*po_entity.ToolFile, error)
 	UpdateAgentThought(ctx context.Context, agentThought *po_entity.MessageAgentThought) error
 	GetAgentThoughtByID(ctx context.Context, id string) (*po_entity.MessageAgentThought, error)
+
+	CreateApiToolProvider(ctx context.Context, provider *po_entity.ToolApiProvider) error
+	UpdateApiToolProvider(ctx context.Context, provider *po_entity.ToolApiProvider) error
+	DeleteApiToolProvider(ctx context.Context, tenantID, name string) error
+	// GetApiToolProviderByName get the api tool provider of the tenant, nil is returned when the provider is not found
+	GetApiToolProviderByName(ctx context.Context, tenantID, name string) (*po_entity.ToolApiProvider, error)
+	// GetApiToolProviderByID get the api tool provider of the tenant, nil is returned when the provider is not found
+	GetApiToolProviderByID(ctx context.Context, tenantID, providerID string) (*po_entity.ToolApiProvider, error)
+	GetApiToolProvidersByTenant(ctx context.Context, tenantID string) ([]*po_entity.ToolApiProvider, error)
+	CreateMcpToolProvider(ctx context.Context, provider *po_entity.ToolMcpProvider) error
+	UpdateMcpToolProvider(ctx context.Context, provider *po_entity.ToolMcpProvider) error
+	DeleteMcpToolProvider(ctx context.Context, tenantID, providerID string) error
+	// GetMcpToolProviderByName get the mcp tool provider of the tenant, nil is returned when the provider is not found
+	GetMcpToolProviderByName(ctx context.Context, tenantID, name string) (*po_entity.ToolMcpProvider, error)
+	// GetMcpToolProviderByID get the mcp tool provider of the tenant, nil is returned when the provider is not found
+	GetMcpToolProviderByID(ctx context.Context, tenantID, providerID string) (*po_entity.ToolMcpProvider, error)
+	GetMcpToolProvidersByTenant(ctx context.Context, tenantID string) ([]*po_entity.ToolMcpProvider, error)
 }
@@ -5,11 +5,32 @@
 package biz_entity
 
 type ModelConfigEntity struct {
-	Provider   string                 `json:"provider"`
-	Model      string                 `json:"model"`
-	Mode       string                 `json:"mode"`
-	Parameters map[string]interface{} `json:"parameters"`
-	Stop       []string               `json:"stop"`
+	Provider   string                       `json:"provider"`
+	Model      string                       `json:"model"`
+	Mode       string                       `json:"mode"`
+	Parameters map[string]interface{}       `json:"parameters"`
+	Stop       []string                     `json:"stop"`
+	Fallbacks  []*FallbackModelConfigEntity `json:"fallbacks"`
+}
+
+type FallbackModelConfigEntity struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+// StructuredOutputEntity is the json schema of an object which the answers of the app must conform to.
+type StructuredOutputEntity struct {
+	Name   string                 `json:"name"`
+	Schema map[string]interface{} `json:"schema"`
+}
+
+// LLMCacheEntity configures the cache of the answers of the model, the ttl is in seconds.
+type LLMCacheEntity struct {
+	Mode                string  `json:"mode"`
+	TTL                 int64   `json:"ttl"`
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+	EmbeddingProvider   string  `json:"embedding_provider"`
+	EmbeddingModel      string  `json:"embedding_model"`
 }
 
 type RolePrefixEntity struct {
@@ -88,6 +109,10 @@ type EasyUIBasedAppConfig struct {
 	Model                 *ModelConfigEntity            `json:"model"`
 	PromptTemplate        *PromptTemplateEntity         `json:"prompt_template"`
 	ExternalDataVariables []ExternalDataVariableEntity  `json:"external_data_variables"`
+	// StructuredOutput is nil when the structured output feature is disabled
+	StructuredOutput *StructuredOutputEntity `json:"structured_output"`
+	// LLMCache is nil when the llm response cache is disabled
+	LLMCache *LLMCacheEntity `json:"llm_cache"`
 }
 
 type WorkflowUIBasedAppConfig struct {
@@ -13,6 +13,7 @@ var (
 	BUILTIN                AgentToolProviderType = "builtin"
 	API                    AgentToolProviderType = "api"
 	WORKFLOW_PROVIDER_TYPE AgentToolProviderType = "workflow"
+	MCP                    AgentToolProviderType = "mcp"
 )
 
 type AgentPromptEntity struct {
@@ -20,9 +21,12 @@ type AgentPromptEntity struct {
 	NextIteration string
 }
 
+// AgentToolEntity is a tool of the agent, the calls of the tool requiring approval wait for a person to approve
+// them before they are invoked.
 type AgentToolEntity struct {
-	ProviderType   AgentToolProviderType
-	ProviderID     string
-	ToolName       string
-	ToolParameters map[string]any
+	ProviderType     AgentToolProviderType
+	ProviderID       string
+	ToolName         string
+	ToolParameters   map[string]any
+	RequiresApproval bool
 }
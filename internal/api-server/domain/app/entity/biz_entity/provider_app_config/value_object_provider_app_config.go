@@ -61,6 +61,30 @@ type ModelInfo struct {
 	Name             string                 `json:"name"`
 	Mode             string                 `json:"mode"`
 	CompletionParams map[string]interface{} `json:"completion_params"`
+	Fallbacks        []*FallbackModelInfo   `json:"fallbacks,omitempty"`
+}
+
+// FallbackModelInfo is a model of the fallback chain, it's invoked in order when the previous model is rate limited or unavailable.
+type FallbackModelInfo struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
+}
+
+// StructuredOutput holds the json schema which the answers of the app must conform to.
+type StructuredOutput struct {
+	Enabled bool                   `json:"enabled"`
+	Name    string                 `json:"name"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+// LLMCache configures the cache of the answers of the model.
+type LLMCache struct {
+	Enabled             bool    `json:"enabled"`
+	Mode                string  `json:"mode"`
+	TTL                 int64   `json:"ttl"`
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+	EmbeddingProvider   string  `json:"embedding_provider"`
+	EmbeddingModel      string  `json:"embedding_model"`
 }
 
 type UserInput struct {
@@ -73,13 +97,14 @@ type UserInput struct {
 }
 
 type AgentTools struct {
-	Enabled        bool           `json:"enabled"`
-	ProviderID     string         `json:"provider_id"`
-	ProviderName   string         `json:"provider_name"`
-	ProviderType   string         `json:"provider_type"`
-	ToolLabel      string         `json:"tool_label"`
-	ToolName       string         `json:"tool_name"`
-	ToolParameters map[string]any `json:"tool_parameters"`
+	Enabled          bool           `json:"enabled"`
+	ProviderID       string         `json:"provider_id"`
+	ProviderName     string         `json:"provider_name"`
+	ProviderType     string         `json:"provider_type"`
+	ToolLabel        string         `json:"tool_label"`
+	ToolName         string         `json:"tool_name"`
+	ToolParameters   map[string]any `json:"tool_parameters"`
+	RequiresApproval bool           `json:"requires_approval"`
 }
 
 type AgentMode struct {
@@ -118,6 +143,8 @@ type AppModelConfig struct {
 	ExternalDataTools             []string               `json:"external_data_tools" gorm:"column:external_data_tools;serializer:json"`
 	FileUpload                    map[string]interface{} `json:"file_upload" gorm:"column:file_upload;serializer:json"`
 	TextToSpeech                  AppModelConfigEnable   `json:"text_to_speech" gorm:"column:text_to_speech;serializer:json"`
+	StructuredOutput              *StructuredOutput      `json:"structured_output" gorm:"column:structured_output;serializer:json"`
+	LLMCache                      *LLMCache              `json:"llm_cache" gorm:"column:llm_cache;serializer:json"`
 	AppAnnotationReply            *AppAnnotationReply    `json:"annotation_reply"`
 }
 
@@ -148,6 +175,8 @@ func (a *AppModelConfig) ConvertToAppConfigPoEntity() *po_entity.AppModelConfig
 		ExternalDataTools:             a.ExternalDataTools,
 		FileUpload:                    a.FileUpload,
 		TextToSpeech:                  po_entity.AppModelConfigEnable(a.TextToSpeech),
+		StructuredOutput:              (*po_entity.StructuredOutput)(a.StructuredOutput),
+		LLMCache:                      (*po_entity.LLMCache)(a.LLMCache),
 	}
 }
 
@@ -158,6 +187,7 @@ func ConvertToModelPoEntity(entityModel ModelInfo) po_entity.ModelInfo {
 		Name:             entityModel.Name,
 		Mode:             entityModel.Mode,
 		CompletionParams: entityModel.CompletionParams,
+		Fallbacks:        ConvertToFallbackModelsPoEntity(entityModel.Fallbacks),
 	}
 }
 
@@ -168,7 +198,32 @@ func ConvertToModelBizEntity(entityModel po_entity.ModelInfo) ModelInfo {
 		Name:             entityModel.Name,
 		Mode:             entityModel.Mode,
 		CompletionParams: entityModel.CompletionParams,
+		Fallbacks:        ConvertToFallbackModelsBizEntity(entityModel.Fallbacks),
+	}
+}
+
+func ConvertToFallbackModelsPoEntity(fallbacks []*FallbackModelInfo) []*po_entity.FallbackModelInfo {
+	poFallbacks := make([]*po_entity.FallbackModelInfo, 0, len(fallbacks))
+
+	for _, fallback := range fallbacks {
+		poFallbacks = append(poFallbacks, &po_entity.FallbackModelInfo{
+			Provider: fallback.Provider,
+			Name:     fallback.Name,
+		})
+	}
+	return poFallbacks
+}
+
+func ConvertToFallbackModelsBizEntity(fallbacks []*po_entity.FallbackModelInfo) []*FallbackModelInfo {
+	bizFallbacks := make([]*FallbackModelInfo, 0, len(fallbacks))
+
+	for _, fallback := range fallbacks {
+		bizFallbacks = append(bizFallbacks, &FallbackModelInfo{
+			Provider: fallback.Provider,
+			Name:     fallback.Name,
+		})
 	}
+	return bizFallbacks
 }
 
 func ConvertToAgentTools(agentTools []*AgentTools) []*po_entity.AgentTools {
@@ -176,13 +231,14 @@ func ConvertToAgentTools(agentTools []*AgentTools) []*po_entity.AgentTools {
 
 	for _, agentTool := range agentTools {
 		poAgentTools = append(poAgentTools, &po_entity.AgentTools{
-			Enabled:        agentTool.Enabled,
-			ProviderID:     agentTool.ProviderID,
-			ProviderName:   agentTool.ProviderName,
-			ProviderType:   agentTool.ProviderType,
-			ToolLabel:      agentTool.ToolLabel,
-			ToolName:       agentTool.ToolName,
-			ToolParameters: agentTool.ToolParameters,
+			Enabled:          agentTool.Enabled,
+			ProviderID:       agentTool.ProviderID,
+			ProviderName:     agentTool.ProviderName,
+			ProviderType:     agentTool.ProviderType,
+			ToolLabel:        agentTool.ToolLabel,
+			ToolName:         agentTool.ToolName,
+			ToolParameters:   agentTool.ToolParameters,
+			RequiresApproval: agentTool.RequiresApproval,
 		})
 	}
 	return poAgentTools
@@ -210,13 +266,14 @@ func ConvertToBizAgentTools(agentTools []*po_entity.AgentTools) []*AgentTools {
 
 	for _, agentTool := range agentTools {
 		poAgentTools = append(poAgentTools, &AgentTools{
-			Enabled:        agentTool.Enabled,
-			ProviderID:     agentTool.ProviderID,
-			ProviderName:   agentTool.ProviderName,
-			ProviderType:   agentTool.ProviderType,
-			ToolLabel:      agentTool.ToolLabel,
-			ToolName:       agentTool.ToolName,
-			ToolParameters: agentTool.ToolParameters,
+			Enabled:          agentTool.Enabled,
+			ProviderID:       agentTool.ProviderID,
+			ProviderName:     agentTool.ProviderName,
+			ProviderType:     agentTool.ProviderType,
+			ToolLabel:        agentTool.ToolLabel,
+			ToolName:         agentTool.ToolName,
+			ToolParameters:   agentTool.ToolParameters,
+			RequiresApproval: agentTool.RequiresApproval,
 		})
 	}
 	return poAgentTools
@@ -319,6 +376,8 @@ func ConvertToAppConfigBizEntity(a *po_entity.AppModelConfig, annotation *AppAnn
 		ExternalDataTools:             a.ExternalDataTools,
 		FileUpload:                    a.FileUpload,
 		TextToSpeech:                  AppModelConfigEnable(a.TextToSpeech), // 注意类型转换
+		StructuredOutput:              (*StructuredOutput)(a.StructuredOutput),
+		LLMCache:                      (*LLMCache)(a.LLMCache),
 		AppAnnotationReply:            annotation,
 	}
 }
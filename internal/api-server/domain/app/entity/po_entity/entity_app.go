@@ -22,13 +22,14 @@ const (
 )
 
 type AgentTools struct {
-	Enabled        bool           `json:"enabled"`
-	ProviderID     string         `json:"provider_id"`
-	ProviderName   string         `json:"provider_name"`
-	ProviderType   string         `json:"provider_type"`
-	ToolLabel      string         `json:"tool_label"`
-	ToolName       string         `json:"tool_name"`
-	ToolParameters map[string]any `json:"tool_parameters"`
+	Enabled          bool           `json:"enabled"`
+	ProviderID       string         `json:"provider_id"`
+	ProviderName     string         `json:"provider_name"`
+	ProviderType     string         `json:"provider_type"`
+	ToolLabel        string         `json:"tool_label"`
+	ToolName         string         `json:"tool_name"`
+	ToolParameters   map[string]any `json:"tool_parameters"`
+	RequiresApproval bool           `json:"requires_approval"`
 }
 
 type AgentMode struct {
@@ -86,6 +87,12 @@ type ModelInfo struct {
 	Name             string                 `json:"name"`
 	Mode             string                 `json:"mode"`
 	CompletionParams map[string]interface{} `json:"completion_params"`
+	Fallbacks        []*FallbackModelInfo   `json:"fallbacks,omitempty"`
+}
+
+type FallbackModelInfo struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
 }
 
 type UserInput struct {
@@ -99,6 +106,23 @@ type UserInput struct {
 
 type UserInputForm map[string]*UserInput
 
+// StructuredOutput holds the json schema which the answers of the app must conform to.
+type StructuredOutput struct {
+	Enabled bool                   `json:"enabled"`
+	Name    string                 `json:"name"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+// LLMCache configures the cache of the answers of the model.
+type LLMCache struct {
+	Enabled             bool    `json:"enabled"`
+	Mode                string  `json:"mode"`
+	TTL                 int64   `json:"ttl"`
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+	EmbeddingProvider   string  `json:"embedding_provider"`
+	EmbeddingModel      string  `json:"embedding_model"`
+}
+
 type AppModelConfig struct {
 	ID                            string                 `json:"id" gorm:"column:id"`
 	AppID                         string                 `json:"app_id" gorm:"column:app_id"`
@@ -126,6 +150,8 @@ type AppModelConfig struct {
 	ExternalDataTools             []string               `json:"external_data_tools" gorm:"column:external_data_tools;serializer:json"`
 	FileUpload                    map[string]interface{} `json:"file_upload" gorm:"column:file_upload;serializer:json"`
 	TextToSpeech                  AppModelConfigEnable   `json:"text_to_speech" gorm:"column:text_to_speech;serializer:json"`
+	StructuredOutput              *StructuredOutput      `json:"structured_output" gorm:"column:structured_output;serializer:json"`
+	LLMCache                      *LLMCache              `json:"llm_cache" gorm:"column:llm_cache;serializer:json"`
 	CreatedBy                     string                 `json:"created_by" gorm:"column:created_by"`
 	UpdatedBy                     string                 `json:"updated_by" gorm:"column:updated_by"`
 }
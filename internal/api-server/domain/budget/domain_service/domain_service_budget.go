@@ -0,0 +1,215 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package domain_service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	_email "github.com/lunarianss/Luna/infrastructure/email"
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/config"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+const (
+	BUDGET_SPEND_PREFIX      = "budget_spend"
+	BUDGET_RECONCILED_PREFIX = "budget_reconciled"
+	BUDGET_WARNED_PREFIX     = "budget_warned"
+
+	// RECONCILE_INTERVAL is how long the spend tracked in redis is trusted before it is summed from the messages again
+	RECONCILE_INTERVAL = 10 * time.Minute
+
+	BUDGET_WARNING_TEMPLATE = "budget_warning_mail_template_en-US.html"
+)
+
+type BudgetDomain struct {
+	BudgetRepo repository.BudgetRepo
+	redis      *redis.Client
+	email      *_email.Mail
+	config     *config.Config
+}
+
+func NewBudgetDomain(budgetRepo repository.BudgetRepo, redis *redis.Client, email *_email.Mail, config *config.Config) *BudgetDomain {
+	return &BudgetDomain{
+		BudgetRepo: budgetRepo,
+		redis:      redis,
+		email:      email,
+		config:     config,
+	}
+}
+
+func (bd *BudgetDomain) getSpendKey(budget *po_entity.Budget, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", BUDGET_SPEND_PREFIX, budget.ID, budget.PeriodKey(now))
+}
+
+func (bd *BudgetDomain) getReconciledKey(budget *po_entity.Budget, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", BUDGET_RECONCILED_PREFIX, budget.ID, budget.PeriodKey(now))
+}
+
+func (bd *BudgetDomain) getWarnedKey(budget *po_entity.Budget, now time.Time) string {
+	return fmt.Sprintf("%s:%s:%s", BUDGET_WARNED_PREFIX, budget.ID, budget.PeriodKey(now))
+}
+
+// CheckBudget rejects the new messages of the app when the hard limit of the app budget or the tenant budget is hit.
+func (bd *BudgetDomain) CheckBudget(ctx context.Context, tenantID, appID string) error {
+	budgets, err := bd.BudgetRepo.GetEnabledBudgetsOfApp(ctx, tenantID, appID)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, budget := range budgets {
+		if budget.HardLimit <= 0 {
+			continue
+		}
+
+		spend, err := bd.GetSpend(ctx, budget, now)
+
+		if err != nil {
+			return err
+		}
+
+		if spend >= budget.HardLimit {
+			return errors.WithCode(code.ErrBudgetExceed, "%s budget %s is exhausted, %.4f of %.4f %s is spent", budget.Period, budget.ID, spend, budget.HardLimit, budget.Currency)
+		}
+	}
+
+	return nil
+}
+
+// GetSpend returns the spend of the budget in the current period, which is summed from the messages when it is
+// not tracked in redis or it hasn't been reconciled for RECONCILE_INTERVAL.
+func (bd *BudgetDomain) GetSpend(ctx context.Context, budget *po_entity.Budget, now time.Time) (float64, error) {
+	reconcile, err := bd.redis.SetNX(ctx, bd.getReconciledKey(budget, now), now.Unix(), RECONCILE_INTERVAL).Result()
+
+	if err != nil {
+		return 0, errors.WithCode(code.ErrRedisRuntime, "redis occurred error when set reconciled key: %s", err.Error())
+	}
+
+	if !reconcile {
+		spend, err := bd.redis.Get(ctx, bd.getSpendKey(budget, now)).Float64()
+
+		if err == nil {
+			return spend, nil
+		}
+
+		if !errors.Is(err, redis.Nil) {
+			return 0, errors.WithCode(code.ErrRedisRuntime, "redis occurred error when get spend key: %s", err.Error())
+		}
+	}
+
+	return bd.reconcile(ctx, budget, now)
+}
+
+// reconcile sums the spend of the current period from the messages and overwrites the one tracked in redis, the
+// spend recorded while summing may be lost and is recovered by the next reconciliation.
+func (bd *BudgetDomain) reconcile(ctx context.Context, budget *po_entity.Budget, now time.Time) (float64, error) {
+	spend, err := bd.BudgetRepo.SumSpend(ctx, budget, budget.PeriodStart(now).Unix())
+
+	if err != nil {
+		return 0, err
+	}
+
+	if err := bd.redis.Set(ctx, bd.getSpendKey(budget, now), spend, time.Until(budget.PeriodEnd(now))+time.Hour).Err(); err != nil {
+		return 0, errors.WithCode(code.ErrRedisRuntime, "redis occurred error when set spend key: %s", err.Error())
+	}
+
+	return spend, nil
+}
+
+// RecordSpend adds the price of a saved message to the budgets applied to the app, and warns the budget
+// owners once per period when the soft limit is hit.
+func (bd *BudgetDomain) RecordSpend(ctx context.Context, tenantID, appID string, price float64, currency string) error {
+	if price <= 0 {
+		return nil
+	}
+
+	budgets, err := bd.BudgetRepo.GetEnabledBudgetsOfApp(ctx, tenantID, appID)
+
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	for _, budget := range budgets {
+		if budget.Currency != currency {
+			continue
+		}
+
+		spendKey := bd.getSpendKey(budget, now)
+
+		exists, err := bd.redis.Exists(ctx, spendKey).Result()
+
+		if err != nil {
+			return errors.WithCode(code.ErrRedisRuntime, "redis occurred error when check spend key: %s", err.Error())
+		}
+
+		var spend float64
+
+		// the message is saved already, so it is summed when the spend is not tracked yet
+		if exists == 0 {
+			spend, err = bd.reconcile(ctx, budget, now)
+		} else {
+			spend, err = bd.redis.IncrByFloat(ctx, spendKey, price).Result()
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if budget.SoftLimit > 0 && spend >= budget.SoftLimit {
+			bd.warn(ctx, budget, spend, now)
+		}
+	}
+
+	return nil
+}
+
+func (bd *BudgetDomain) warn(ctx context.Context, budget *po_entity.Budget, spend float64, now time.Time) {
+	first, err := bd.redis.SetNX(ctx, bd.getWarnedKey(budget, now), now.Unix(), time.Until(budget.PeriodEnd(now))+time.Hour).Result()
+
+	if err != nil {
+		log.Errorf("redis occurred error when set warned key: %v", err)
+		return
+	}
+
+	if !first || bd.email == nil || len(budget.NotifyEmails) == 0 {
+		return
+	}
+
+	scope := "workspace"
+
+	if !budget.IsTenantBudget() {
+		scope = "app " + budget.AppID
+	}
+
+	templatePath := fmt.Sprintf("%s/%s", bd.config.EmailOptions.TemplateDir, BUDGET_WARNING_TEMPLATE)
+	data := map[string]interface{}{
+		"Scope":     scope,
+		"Period":    budget.Period,
+		"Spend":     fmt.Sprintf("%.4f", spend),
+		"SoftLimit": fmt.Sprintf("%.4f", budget.SoftLimit),
+		"HardLimit": fmt.Sprintf("%.4f", budget.HardLimit),
+		"Currency":  budget.Currency,
+	}
+
+	go func() {
+		for _, email := range budget.NotifyEmails {
+			if err := bd.email.Send(email, "Budget Warning", templatePath, data, ""); err != nil {
+				log.Errorf("Send budget warning email failed: %v", err)
+			}
+		}
+	}()
+}
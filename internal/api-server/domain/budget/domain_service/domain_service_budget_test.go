@@ -0,0 +1,325 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package domain_service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// fakeRedis serves the few string commands used by the budget domain over RESP.
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]string
+	// nxSets counts the SET NX commands which set the key
+	nxSets map[string]int
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	fr := &fakeRedis{values: make(map[string]string), nxSets: make(map[string]int)}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fr.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), Protocol: 2, DisableIndentity: true})
+
+	t.Cleanup(func() {
+		client.Close()
+		listener.Close()
+	})
+
+	return fr, client
+}
+
+func (fr *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		args, err := readCommand(reader)
+
+		if err != nil {
+			return
+		}
+
+		if _, err := io.WriteString(conn, fr.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+
+	for i := 0; i < count; i++ {
+		line, err := reader.ReadString('\n')
+
+		if err != nil {
+			return nil, err
+		}
+
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+
+		if err != nil {
+			return nil, err
+		}
+
+		arg := make([]byte, size+2)
+
+		if _, err := io.ReadFull(reader, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, string(arg[:size]))
+	}
+
+	return args, nil
+}
+
+func bulk(value string) string {
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func (fr *fakeRedis) exec(args []string) string {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		if value, ok := fr.values[args[1]]; ok {
+			return bulk(value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		for _, option := range args[3:] {
+			if strings.ToUpper(option) != "NX" {
+				continue
+			}
+
+			if _, ok := fr.values[args[1]]; ok {
+				return "$-1\r\n"
+			}
+			fr.nxSets[args[1]]++
+		}
+		fr.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "EXISTS":
+		if _, ok := fr.values[args[1]]; ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "DEL":
+		delete(fr.values, args[1])
+		return ":1\r\n"
+	case "INCRBYFLOAT":
+		value, _ := strconv.ParseFloat(fr.values[args[1]], 64)
+		increment, _ := strconv.ParseFloat(args[2], 64)
+		fr.values[args[1]] = strconv.FormatFloat(value+increment, 'f', -1, 64)
+		return bulk(fr.values[args[1]])
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+func (fr *fakeRedis) nxSetsOf(prefix string) int {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+
+	count := 0
+
+	for key, sets := range fr.nxSets {
+		if strings.HasPrefix(key, prefix) {
+			count += sets
+		}
+	}
+	return count
+}
+
+type fakeBudgetRepo struct {
+	repository.BudgetRepo
+	budgets  []*po_entity.Budget
+	spend    map[string]float64
+	sumCalls int
+}
+
+func (br *fakeBudgetRepo) GetEnabledBudgetsOfApp(ctx context.Context, tenantID, appID string) ([]*po_entity.Budget, error) {
+	return br.budgets, nil
+}
+
+func (br *fakeBudgetRepo) SumSpend(ctx context.Context, budget *po_entity.Budget, start int64) (float64, error) {
+	br.sumCalls++
+	return br.spend[budget.ID], nil
+}
+
+func TestCheckBudget(t *testing.T) {
+	_, client := newFakeRedis(t)
+
+	budgetRepo := &fakeBudgetRepo{
+		budgets: []*po_entity.Budget{
+			{ID: "tenant-budget", TenantID: "tenant-1", Period: string(po_entity.MONTHLY), Currency: "USD", HardLimit: 10},
+			{ID: "soft-budget", TenantID: "tenant-1", AppID: "app-1", Period: string(po_entity.DAILY), Currency: "USD", SoftLimit: 1},
+		},
+		spend: map[string]float64{"tenant-budget": 4, "soft-budget": 100},
+	}
+	bd := NewBudgetDomain(budgetRepo, client, nil, nil)
+
+	if err := bd.CheckBudget(context.Background(), "tenant-1", "app-1"); err != nil {
+		t.Fatalf("CheckBudget() error = %v below the hard limit", err)
+	}
+
+	// the budget without a hard limit never rejects the messages
+	if budgetRepo.sumCalls != 1 {
+		t.Errorf("spend summed %d times, want 1", budgetRepo.sumCalls)
+	}
+
+	budgetRepo.budgets = append(budgetRepo.budgets, &po_entity.Budget{ID: "app-budget", TenantID: "tenant-1", AppID: "app-1", Period: string(po_entity.DAILY), Currency: "USD", HardLimit: 5})
+	budgetRepo.spend["app-budget"] = 5
+
+	err := bd.CheckBudget(context.Background(), "tenant-1", "app-1")
+
+	if !errors.IsCode(err, code.ErrBudgetExceed) {
+		t.Fatalf("expected ErrBudgetExceed at the hard limit, got %v", err)
+	}
+
+	if status := errors.ParseCode(err).HTTPStatus(); status != http.StatusForbidden {
+		t.Errorf("HTTP status = %d, want %d", status, http.StatusForbidden)
+	}
+}
+
+func TestGetSpendReconcile(t *testing.T) {
+	fr, client := newFakeRedis(t)
+
+	budget := &po_entity.Budget{ID: "budget-1", TenantID: "tenant-1", Period: string(po_entity.MONTHLY), Currency: "USD"}
+	budgetRepo := &fakeBudgetRepo{budgets: []*po_entity.Budget{budget}, spend: map[string]float64{"budget-1": 3}}
+	bd := NewBudgetDomain(budgetRepo, client, nil, nil)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	spend, err := bd.GetSpend(ctx, budget, now)
+
+	if err != nil || spend != 3 || budgetRepo.sumCalls != 1 {
+		t.Fatalf("GetSpend() = %v, %v with %d sums, want the summed spend", spend, err, budgetRepo.sumCalls)
+	}
+
+	if err := bd.RecordSpend(ctx, "tenant-1", "", 1.5, "USD"); err != nil {
+		t.Fatalf("RecordSpend() error = %v", err)
+	}
+
+	// the spend of another currency isn't tracked by the budget
+	if err := bd.RecordSpend(ctx, "tenant-1", "", 100, "RMB"); err != nil {
+		t.Fatalf("RecordSpend() error = %v", err)
+	}
+
+	spend, err = bd.GetSpend(ctx, budget, now)
+
+	if err != nil || spend != 4.5 || budgetRepo.sumCalls != 1 {
+		t.Fatalf("GetSpend() = %v, %v with %d sums, want the tracked spend", spend, err, budgetRepo.sumCalls)
+	}
+
+	// the spend is summed again when its key is missing though it was reconciled in the interval
+	fr.exec([]string{"DEL", bd.getSpendKey(budget, now)})
+	budgetRepo.spend["budget-1"] = 6
+
+	spend, err = bd.GetSpend(ctx, budget, now)
+
+	if err != nil || spend != 6 || budgetRepo.sumCalls != 2 {
+		t.Fatalf("GetSpend() = %v, %v with %d sums, want the summed spend", spend, err, budgetRepo.sumCalls)
+	}
+
+	if value := fr.values[bd.getSpendKey(budget, now)]; value != "6" {
+		t.Errorf("tracked spend = %s, want 6", value)
+	}
+}
+
+func TestRecordSpendSoftLimit(t *testing.T) {
+	log.NewWithOptions(log.WithDebugMode())
+
+	fr, client := newFakeRedis(t)
+
+	budget := &po_entity.Budget{ID: "budget-1", TenantID: "tenant-1", AppID: "app-1", Period: string(po_entity.DAILY), Currency: "USD", SoftLimit: 5}
+	budgetRepo := &fakeBudgetRepo{budgets: []*po_entity.Budget{budget}, spend: map[string]float64{"budget-1": 2}}
+	bd := NewBudgetDomain(budgetRepo, client, nil, nil)
+
+	ctx := context.Background()
+	warnedPrefix := BUDGET_WARNED_PREFIX + ":budget-1:"
+
+	// the spend isn't tracked yet, so the saved message is summed
+	if err := bd.RecordSpend(ctx, "tenant-1", "app-1", 2, "USD"); err != nil {
+		t.Fatalf("RecordSpend() error = %v", err)
+	}
+
+	if err := bd.RecordSpend(ctx, "tenant-1", "app-1", 2, "USD"); err != nil {
+		t.Fatalf("RecordSpend() error = %v", err)
+	}
+
+	if warned := fr.nxSetsOf(warnedPrefix); warned != 0 {
+		t.Fatalf("warned %d times below the soft limit", warned)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := bd.RecordSpend(ctx, "tenant-1", "app-1", 2, "USD"); err != nil {
+			t.Fatalf("RecordSpend() error = %v", err)
+		}
+	}
+
+	if warned := fr.nxSetsOf(warnedPrefix); warned != 1 {
+		t.Errorf("warned %d times over the soft limit, want 1 in the period", warned)
+	}
+
+	if spend, _ := strconv.ParseFloat(fr.values[bd.getSpendKey(budget, time.Now())], 64); spend != 10 {
+		t.Errorf("tracked spend = %v, want 10", spend)
+	}
+
+	// the budget is warned again in the next period
+	bd.warn(ctx, budget, 10, time.Now().AddDate(0, 0, 1))
+
+	if warned := fr.nxSetsOf(warnedPrefix); warned != 2 {
+		t.Errorf("warned %d times in two periods, want 2", warned)
+	}
+}
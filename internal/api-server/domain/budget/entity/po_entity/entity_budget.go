@@ -0,0 +1,79 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package po_entity
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lunarianss/Luna/internal/infrastructure/field"
+	"gorm.io/gorm"
+)
+
+type BudgetPeriod string
+
+const (
+	DAILY   BudgetPeriod = "daily"
+	MONTHLY BudgetPeriod = "monthly"
+)
+
+// Budget limits the spend of the tenant, or of one app of the tenant when AppID is set, in a period.
+// A zero limit is not enforced.
+type Budget struct {
+	ID           string        `gorm:"column:id"                              json:"id"`
+	TenantID     string        `gorm:"column:tenant_id"                       json:"tenant_id"`
+	AppID        string        `gorm:"column:app_id"                          json:"app_id"`
+	Period       string        `gorm:"column:period"                          json:"period"`
+	Currency     string        `gorm:"column:currency"                        json:"currency"`
+	SoftLimit    float64       `gorm:"column:soft_limit"                      json:"soft_limit"`
+	HardLimit    float64       `gorm:"column:hard_limit"                      json:"hard_limit"`
+	NotifyEmails []string      `gorm:"column:notify_emails;serializer:json"   json:"notify_emails"`
+	Enabled      field.BitBool `gorm:"column:enabled"                         json:"enabled"`
+	CreatedBy    string        `gorm:"column:created_by"                      json:"created_by"`
+	CreatedAt    int64         `gorm:"column:created_at;autoCreateTime"       json:"created_at"`
+	UpdatedAt    int64         `gorm:"column:updated_at;autoUpdateTime"       json:"updated_at"`
+}
+
+func (*Budget) TableName() string {
+	return "budgets"
+}
+
+func (b *Budget) BeforeCreate(tx *gorm.DB) (err error) {
+	b.ID = uuid.NewString()
+	return
+}
+
+// IsTenantBudget reports whether the budget limits all the apps of the tenant.
+func (b *Budget) IsTenantBudget() bool {
+	return b.AppID == ""
+}
+
+// PeriodStart returns the start of the period which t is in, the periods are in UTC.
+func (b *Budget) PeriodStart(t time.Time) time.Time {
+	t = t.UTC()
+
+	if BudgetPeriod(b.Period) == DAILY {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// PeriodEnd returns the start of the period next to the one which t is in.
+func (b *Budget) PeriodEnd(t time.Time) time.Time {
+	start := b.PeriodStart(t)
+
+	if BudgetPeriod(b.Period) == DAILY {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.AddDate(0, 1, 0)
+}
+
+// PeriodKey identifies the period which t is in, e.g. 20241018 for a daily budget and 202410 for a monthly one.
+func (b *Budget) PeriodKey(t time.Time) string {
+	if BudgetPeriod(b.Period) == DAILY {
+		return b.PeriodStart(t).Format("20060102")
+	}
+	return b.PeriodStart(t).Format("200601")
+}
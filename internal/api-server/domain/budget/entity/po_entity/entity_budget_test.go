@@ -0,0 +1,98 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package po_entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBudgetPeriod(t *testing.T) {
+	shanghai := time.FixedZone("Asia/Shanghai", 8*60*60)
+
+	tests := []struct {
+		name      string
+		period    BudgetPeriod
+		now       time.Time
+		wantStart time.Time
+		wantEnd   time.Time
+		wantKey   string
+	}{
+		{
+			name:      "daily",
+			period:    DAILY,
+			now:       time.Date(2024, 3, 15, 13, 4, 5, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+			wantKey:   "20240315",
+		},
+		{
+			name:      "daily at the start of the day",
+			period:    DAILY,
+			now:       time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+			wantKey:   "20240315",
+		},
+		{
+			name:      "daily at the end of the month",
+			period:    DAILY,
+			now:       time.Date(2024, 2, 29, 23, 59, 59, 999, time.UTC),
+			wantStart: time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantKey:   "20240229",
+		},
+		{
+			name:      "daily in utc",
+			period:    DAILY,
+			now:       time.Date(2024, 3, 16, 7, 0, 0, 0, shanghai),
+			wantStart: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC),
+			wantKey:   "20240315",
+		},
+		{
+			name:      "monthly",
+			period:    MONTHLY,
+			now:       time.Date(2024, 3, 15, 13, 4, 5, 0, time.UTC),
+			wantStart: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantKey:   "202403",
+		},
+		{
+			name:      "monthly at the end of the year",
+			period:    MONTHLY,
+			now:       time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			wantStart: time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantKey:   "202412",
+		},
+		{
+			name:      "monthly in utc",
+			period:    MONTHLY,
+			now:       time.Date(2024, 4, 1, 6, 0, 0, 0, shanghai),
+			wantStart: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantKey:   "202403",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := &Budget{Period: string(tt.period)}
+
+			if start := budget.PeriodStart(tt.now); !start.Equal(tt.wantStart) {
+				t.Errorf("PeriodStart() = %v, want %v", start, tt.wantStart)
+			}
+
+			if end := budget.PeriodEnd(tt.now); !end.Equal(tt.wantEnd) {
+				t.Errorf("PeriodEnd() = %v, want %v", end, tt.wantEnd)
+			}
+
+			if key := budget.PeriodKey(tt.now); key != tt.wantKey {
+				t.Errorf("PeriodKey() = %s, want %s", key, tt.wantKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package repository
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+)
+
+type BudgetRepo interface {
+	CreateBudget(ctx context.Context, budget *po_entity.Budget) error
+
+	UpdateBudget(ctx context.Context, budget *po_entity.Budget) error
+
+	DeleteBudget(ctx context.Context, tenantID, budgetID string) error
+
+	// GetBudgetByID get the budget of the tenant, nil is returned when the budget is not found
+	GetBudgetByID(ctx context.Context, tenantID, budgetID string) (*po_entity.Budget, error)
+
+	// GetBudgetByScope get the budget of the tenant or the app, nil is returned when the budget is not found
+	GetBudgetByScope(ctx context.Context, tenantID, appID string) (*po_entity.Budget, error)
+
+	// ListTenantBudgets get all budgets of the tenant and its apps
+	ListTenantBudgets(ctx context.Context, tenantID string) ([]*po_entity.Budget, error)
+
+	// GetEnabledBudgetsOfApp get the enabled budgets applied to the app, which are the tenant budget and the app budget
+	GetEnabledBudgetsOfApp(ctx context.Context, tenantID, appID string) ([]*po_entity.Budget, error)
+
+	// SumSpend sums the total price of the messages in the currency since start (unix seconds), messages of all the
+	// apps of the tenant are summed for the tenant budget
+	SumSpend(ctx context.Context, budget *po_entity.Budget, start int64) (float64, error)
+}
@@ -1,9 +1,13 @@
 package biz_entity
 
 type PromptMessageToolProperty struct {
-	Type        string   `json:"string,omitempty"`
+	Type        string   `json:"type,omitempty"`
 	Description string   `json:"description,omitempty"`
 	Enum        []string `json:"enum,omitempty"`
+	// Items, Properties and Required describe the nested arrays and objects
+	Items      *PromptMessageToolProperty  `json:"items,omitempty"`
+	Properties PromptMessageToolProperties `json:"properties,omitempty"`
+	Required   []string                    `json:"required,omitempty"`
 }
 
 type PromptMessageToolProperties map[string]*PromptMessageToolProperty
@@ -0,0 +1,20 @@
+package biz_entity
+
+type RerankDocument struct {
+	Index int     `json:"index"`
+	Text  string  `json:"text"`
+	Score float64 `json:"score"`
+}
+
+type RerankResult struct {
+	Model string            `json:"model"`
+	Docs  []*RerankDocument `json:"docs"`
+}
+
+type RerankLargeModelResult struct {
+	Model   string `json:"model"`
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
@@ -38,6 +38,32 @@ func NewEmptyLLMUsage() *LLMUsage {
 	}
 }
 
+// Plus returns the usage of both calls, the unit prices of the other call are kept as they're of the latest call.
+func (u *LLMUsage) Plus(other *LLMUsage) *LLMUsage {
+	if u == nil {
+		return other
+	}
+
+	if other == nil {
+		return u
+	}
+
+	return &LLMUsage{
+		PromptTokens:        u.PromptTokens + other.PromptTokens,
+		PromptUnitPrice:     other.PromptUnitPrice,
+		PromptPriceUnit:     other.PromptPriceUnit,
+		PromptPrice:         u.PromptPrice + other.PromptPrice,
+		CompletionTokens:    u.CompletionTokens + other.CompletionTokens,
+		CompletionUnitPrice: other.CompletionUnitPrice,
+		CompletionPriceUnit: other.CompletionPriceUnit,
+		CompletionPrice:     u.CompletionPrice + other.CompletionPrice,
+		TotalTokens:         u.TotalTokens + other.TotalTokens,
+		TotalPrice:          u.TotalPrice + other.TotalPrice,
+		Currency:            other.Currency,
+		Latency:             u.Latency + other.Latency,
+	}
+}
+
 type LLMResultChunkDelta struct {
 	Index        int                                `json:"index"`
 	Message      *biz_entity.AssistantPromptMessage `json:"message"`
@@ -61,6 +87,23 @@ type LLMResult struct {
 	Usage             *LLMUsage                          `json:"usage"`
 	SystemFingerprint string                             `json:"system_fingerprint"`
 	Reason            string                             `json:"reason"`
+	Provider          string                             `json:"provider"`
+	Fallbacks         []*LLMFallback                     `json:"fallbacks,omitempty"`
+	// CacheHit is true when the answer is replayed from the llm cache instead of being generated by the model
+	CacheHit bool `json:"cache_hit,omitempty"`
+}
+
+// Generated tells whether a model was called for the result, the cached answers and the answers replied
+// without the model, such as the preset responses and the annotation replies, carry no provider.
+func (r *LLMResult) Generated() bool {
+	return !r.CacheHit && r.Provider != ""
+}
+
+// LLMFallback records a model of the fallback chain which failed before the result was generated.
+type LLMFallback struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Error    string `json:"error"`
 }
 
 func NewEmptyLLMResult() *LLMResult {
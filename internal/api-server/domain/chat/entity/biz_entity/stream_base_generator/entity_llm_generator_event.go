@@ -85,6 +85,12 @@ type QueueMessageEndEvent struct {
 	LLMResult *LLMResult `json:"llm_result"`
 }
 
+// QueueMessageReplaceEvent replaces the answer which has been streamed with the text.
+type QueueMessageReplaceEvent struct {
+	*AppQueueEvent
+	Text string `json:"text"`
+}
+
 type QueueAnnotationReplyEvent struct {
 	*AppQueueEvent
 	MessageAnnotationID string `json:"message_annotation_id"`
@@ -28,6 +28,7 @@ const (
 	StreamEventIterationCompleted     StreamEvent = "iteration_completed"
 	StreamEventTextChunk              StreamEvent = "text_chunk"
 	StreamEventTextReplace            StreamEvent = "text_replace"
+	StreamEventAgentApprovalRequired  StreamEvent = "agent_approval_required"
 )
 
 type ChatAppTaskState struct {
@@ -199,6 +200,37 @@ type AgentThoughtStreamResponse struct {
 	MessageFiles []string                  `json:"message_files"`
 }
 
+// AgentApprovalRequiredStreamResponse ends the stream of the agent paused until the tool calls are approved or
+// rejected, the id is the id of the agent thought.
+type AgentApprovalRequiredStreamResponse struct {
+	*StreamResponse
+	ID               string                  `json:"id"`
+	Position         int                     `json:"position"`
+	PendingToolCalls []*ApprovalToolCallItem `json:"pending_tool_calls"`
+}
+
+type ApprovalToolCallItem struct {
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	ToolInput  string `json:"tool_input"`
+}
+
+type AgentApprovalRequiredBotAppStreamResponse struct {
+	*AgentApprovalRequiredStreamResponse
+	ConversationID string `json:"conversation_id"`
+	MessageID      string `json:"message_id"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+func NewAgentApprovalRequiredBotAppStreamResponse(cID, mID string, createAt int64, streamResp *AgentApprovalRequiredStreamResponse) *AgentApprovalRequiredBotAppStreamResponse {
+	return &AgentApprovalRequiredBotAppStreamResponse{
+		ConversationID:                      cID,
+		MessageID:                           mID,
+		CreatedAt:                           createAt,
+		AgentApprovalRequiredStreamResponse: streamResp,
+	}
+}
+
 func NewAgentThoughtBotAppStreamResponse(cID, mID string, createAt int64, streamResp *AgentThoughtStreamResponse) *AgentThoughtBotAppStreamResponse {
 	return &AgentThoughtBotAppStreamResponse{
 		ConversationID:             cID,
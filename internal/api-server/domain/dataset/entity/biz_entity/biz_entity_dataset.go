@@ -25,7 +25,10 @@ type Document struct {
 	Vector      []float32
 	Metadata    map[string]string
 	Provider    string
-	Score       float32
+	// Score is the similarity of the vector search, which the score threshold of the dataset is compared with
+	Score float32
+	// RerankScore is the relevance given by the rerank model, it is zero when the document is not reranked
+	RerankScore float32
 }
 
 type SimilaritySearchAdditional struct {
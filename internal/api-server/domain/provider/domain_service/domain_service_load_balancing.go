@@ -0,0 +1,214 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package domain_service
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/infrastructure/log"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"github.com/lunarianss/Luna/internal/infrastructure/field"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+// HIDDEN_VALUE replaces the secret credentials returned to the console, it is kept as the stored value when sent back.
+const HIDDEN_VALUE = "[__HIDDEN__]"
+
+// toModelSettings builds the model settings of the provider, only the enabled load balancing configs are loaded.
+func (mpd *ProviderDomain) toModelSettings(
+	tenantID string,
+	providerEntity *biz_entity.ProviderStaticConfiguration,
+	settingRecords []*po_entity.ProviderModelSetting,
+	configRecords []*po_entity.LoadBalancingModelConfig,
+) []*biz_entity_provider_config.ModelSettings {
+
+	modelSettings := make([]*biz_entity_provider_config.ModelSettings, 0, len(settingRecords))
+
+	for _, settingRecord := range settingRecords {
+		modelSetting := &biz_entity_provider_config.ModelSettings{
+			Model:                 settingRecord.ModelName,
+			ModelType:             common.ModelType(settingRecord.ModelType),
+			Enabled:               settingRecord.Enabled == 1,
+			LoadBalancingEnabled:  settingRecord.LoadBalancingEnabled == 1,
+			LoadBalancingStrategy: po_entity.LoadBalancingStrategy(settingRecord.LoadBalancingStrategy),
+		}
+
+		if modelSetting.LoadBalancingEnabled {
+			for _, configRecord := range configRecords {
+				if configRecord.Enabled != 1 || configRecord.ModelName != settingRecord.ModelName || configRecord.ModelType != settingRecord.ModelType {
+					continue
+				}
+
+				credentials, err := mpd.DecryptLoadBalancingCredentials(tenantID, providerEntity, configRecord)
+
+				if err != nil {
+					log.Errorf("load balancing config %s of %s/%s is skipped: %s", configRecord.ID, providerEntity.Provider, configRecord.ModelName, err.Error())
+					continue
+				}
+
+				modelSetting.LoadBalancingConfigs = append(modelSetting.LoadBalancingConfigs, &biz_entity_provider_config.ModelLoadBalancingConfiguration{
+					ID:          configRecord.ID,
+					Name:        configRecord.Name,
+					Credentials: credentials,
+					Weight:      configRecord.Weight,
+				})
+			}
+		}
+
+		modelSettings = append(modelSettings, modelSetting)
+	}
+
+	return modelSettings
+}
+
+// loadBalancingSecretVariables returns the secret variables of the load balancing credentials, which are the model
+// credentials for the customizable model providers and the provider credentials for the others.
+func (mpd *ProviderDomain) loadBalancingSecretVariables(providerEntity *biz_entity.ProviderStaticConfiguration) []string {
+	if providerEntity.ModelCredentialSchema != nil {
+		return mpd.extractSecretVariables(providerEntity.ModelCredentialSchema.CredentialFormSchemas)
+	}
+
+	if providerEntity.ProviderCredentialSchema != nil {
+		return mpd.extractSecretVariables(providerEntity.ProviderCredentialSchema.CredentialFormSchemas)
+	}
+	return nil
+}
+
+func (mpd *ProviderDomain) DecryptLoadBalancingCredentials(tenantID string, providerEntity *biz_entity.ProviderStaticConfiguration, configRecord *po_entity.LoadBalancingModelConfig) (map[string]interface{}, error) {
+	var credentials map[string]interface{}
+
+	if err := json.Unmarshal([]byte(configRecord.EncryptedConfig), &credentials); err != nil {
+		return nil, errors.WithSCode(code.ErrDecodingJSON, err.Error())
+	}
+
+	secretVariables := mpd.loadBalancingSecretVariables(providerEntity)
+
+	for k, v := range credentials {
+		encryptedData, ok := v.(string)
+
+		if !ok || !slices.Contains(secretVariables, k) {
+			continue
+		}
+
+		decryptedData, err := util.Decrypt(encryptedData, tenantID, &util.FileStorage{})
+
+		if err != nil {
+			return nil, err
+		}
+		credentials[k] = decryptedData
+	}
+
+	return credentials, nil
+}
+
+// ObfuscateLoadBalancingCredentials replaces the secret credentials with HIDDEN_VALUE.
+func (mpd *ProviderDomain) ObfuscateLoadBalancingCredentials(providerEntity *biz_entity.ProviderStaticConfiguration, credentials map[string]interface{}) map[string]interface{} {
+	secretVariables := mpd.loadBalancingSecretVariables(providerEntity)
+	obfuscated := make(map[string]interface{}, len(credentials))
+
+	for k, v := range credentials {
+		if slices.Contains(secretVariables, k) {
+			obfuscated[k] = HIDDEN_VALUE
+			continue
+		}
+		obfuscated[k] = v
+	}
+
+	return obfuscated
+}
+
+// MergeHiddenLoadBalancingCredentials restores the secret credentials which are sent back as HIDDEN_VALUE by the console.
+func (mpd *ProviderDomain) MergeHiddenLoadBalancingCredentials(credentials map[string]interface{}, originCredentials map[string]interface{}) map[string]interface{} {
+	for k, v := range credentials {
+		if v == HIDDEN_VALUE {
+			credentials[k] = originCredentials[k]
+		}
+	}
+	return credentials
+}
+
+// SaveLoadBalancingConfig encrypts the secret credentials and creates the config, or updates it when it has an id.
+func (mpd *ProviderDomain) SaveLoadBalancingConfig(ctx context.Context, tenantID string, providerEntity *biz_entity.ProviderStaticConfiguration, configRecord *po_entity.LoadBalancingModelConfig, credentials map[string]interface{}) error {
+	tenantRecord, err := mpd.TenantRepo.GetTenantByID(ctx, tenantID)
+
+	if err != nil {
+		return err
+	}
+
+	secretVariables := mpd.loadBalancingSecretVariables(providerEntity)
+	encryptedCredentials := make(map[string]interface{}, len(credentials))
+
+	for k, v := range credentials {
+		value, ok := v.(string)
+
+		if !ok || !slices.Contains(secretVariables, k) {
+			encryptedCredentials[k] = v
+			continue
+		}
+
+		encryptedData, err := util.Encrypt(value, tenantRecord.EncryptPublicKey)
+
+		if err != nil {
+			return errors.WithSCode(code.ErrRunTimeCaller, err.Error())
+		}
+		encryptedCredentials[k] = encryptedData
+	}
+
+	byteCredentials, err := json.Marshal(encryptedCredentials)
+
+	if err != nil {
+		return errors.WithSCode(code.ErrEncodingJSON, err.Error())
+	}
+
+	configRecord.EncryptedConfig = string(byteCredentials)
+
+	if configRecord.ID == "" {
+		return mpd.ModelRepo.CreateLoadBalancingConfig(ctx, configRecord)
+	}
+	return mpd.ModelRepo.UpdateLoadBalancingConfig(ctx, configRecord)
+}
+
+// UpdateModelLoadBalancing enables or disables load balancing of the model, the setting is created at the first time.
+func (mpd *ProviderDomain) UpdateModelLoadBalancing(ctx context.Context, tenantID, provider, model, modelType string, enabled bool, strategy po_entity.LoadBalancingStrategy) error {
+	modelSetting, err := mpd.ModelRepo.GetTenantModelSetting(ctx, tenantID, provider, model, modelType)
+
+	if err != nil {
+		return err
+	}
+
+	if strategy == "" {
+		strategy = po_entity.ROUND_ROBIN
+	}
+
+	var loadBalancingEnabled field.BitBool
+
+	if enabled {
+		loadBalancingEnabled = 1
+	}
+
+	if modelSetting == nil {
+		return mpd.ModelRepo.CreateModelSetting(ctx, &po_entity.ProviderModelSetting{
+			TenantID:              tenantID,
+			ProviderName:          provider,
+			ModelName:             model,
+			ModelType:             modelType,
+			Enabled:               1,
+			LoadBalancingEnabled:  loadBalancingEnabled,
+			LoadBalancingStrategy: string(strategy),
+		})
+	}
+
+	modelSetting.LoadBalancingEnabled = loadBalancingEnabled
+	modelSetting.LoadBalancingStrategy = string(strategy)
+
+	return mpd.ModelRepo.UpdateModelSetting(ctx, modelSetting)
+}
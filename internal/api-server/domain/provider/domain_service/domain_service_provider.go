@@ -13,6 +13,7 @@ import (
 	"github.com/lunarianss/Luna/infrastructure/errors"
 	"github.com/lunarianss/Luna/infrastructure/log"
 	model_providers "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
 	ac "github.com/lunarianss/Luna/internal/api-server/domain/account/repository"
 	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
 	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
@@ -55,6 +56,18 @@ func (mpd *ProviderDomain) GetConfigurations(ctx context.Context, tenantId strin
 		return nil, nil, err
 	}
 
+	modelSettingRecords, err := mpd.ModelRepo.GetTenantModelSettings(ctx, tenantId)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	loadBalancingConfigRecords, err := mpd.ModelRepo.GetTenantLoadBalancingConfigs(ctx, tenantId)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
 	providerConfigurations := NewProviderConfigurationsManager(mpd.ProviderRepo, mpd.ModelRepo, tenantId, make(map[string]*biz_entity_provider_config.ProviderConfiguration, model_providers.PROVIDER_COUNT))
 
 	for _, providerEntity := range providerNameMapEntities {
@@ -66,12 +79,20 @@ func (mpd *ProviderDomain) GetConfigurations(ctx context.Context, tenantId strin
 			return nil, nil, err
 		}
 
+		modelSettings := mpd.toModelSettings(tenantId, providerEntity, util.SliceFilter(modelSettingRecords, func(record *po_entity.ProviderModelSetting) bool {
+			return record.ProviderName == providerName
+		}), util.SliceFilter(loadBalancingConfigRecords, func(record *po_entity.LoadBalancingModelConfig) bool {
+			return record.ProviderName == providerName
+		}))
+
 		providerConfiguration := &biz_entity_provider_config.ProviderConfiguration{
 			TenantId:              tenantId,
 			Provider:              providerEntity,
 			UsingProviderType:     po_entity.CUSTOM,
 			PreferredProviderType: po_entity.SYSTEM,
+			SystemConfiguration:   mpd.toSystemConfiguration(providerRecords),
 			CustomConfiguration:   customConfiguration,
+			ModelSettings:         modelSettings,
 		}
 
 		providerConfiguration.SetManager(providerConfigurations)
@@ -123,6 +144,18 @@ func (mpd *ProviderDomain) GetProviderModelBundle(ctx context.Context, tenantId,
 	}, nil
 }
 
+// ValidateModelParameters validates the parameters of the llm against its parameter rules and returns the coerced
+// parameters, the out of range values are rejected in strict mode and clamped otherwise.
+func (mpd *ProviderDomain) ValidateModelParameters(ctx context.Context, tenantID, provider, model string, parameters map[string]interface{}, strict bool) (map[string]interface{}, error) {
+	providerModelBundle, err := mpd.GetProviderModelBundle(ctx, tenantID, provider, common.LLM)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return providerModelBundle.ModelTypeInstance.ValidateParameters(model, nil, parameters, strict)
+}
+
 func (mpd *ProviderDomain) GetFirstProviderFirstModel(ctx context.Context, tenantID, modelType string) (string, string, error) {
 
 	providerConfigurations, orderedProviders, err := mpd.GetConfigurations(ctx, tenantID)
@@ -276,6 +309,17 @@ func (mpd *ProviderDomain) SaveProviderCredentials(ctx context.Context, tenantID
 		return errors.WithCode(code.ErrProviderMapModel, "when create %s provider credential for provider", provider)
 	}
 
+	// the credentials are validated before saving, which encrypts the secret variables in place
+	if schema := providerConfiguration.Provider.ProviderCredentialSchema; schema != nil {
+		if err := schema.ValidateCredentials(credentials); err != nil {
+			return err
+		}
+	}
+
+	if err := model_registry.ValidateProviderCredentials(ctx, provider, credentials); err != nil {
+		return err
+	}
+
 	tenantRecord, err := mpd.TenantRepo.GetTenantByID(ctx, tenantID)
 	if err != nil {
 		return err
@@ -0,0 +1,168 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package domain_service
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+// CREDITS_PER_CALL is the credits deducted by a call of the model without credits_per_call in its pricing
+const CREDITS_PER_CALL = 1
+
+// toSystemConfiguration builds the quota of the provider from the tenant's custom provider record which has a quota
+// type and a quota limit, the quota is disabled when there is no such record.
+func (mpd *ProviderDomain) toSystemConfiguration(providerRecords []*po_entity.Provider) *biz_entity_provider_config.SystemConfiguration {
+	systemConfiguration := &biz_entity_provider_config.SystemConfiguration{
+		QuotaConfigurations: make([]*biz_entity_provider_config.QuotaConfiguration, 0),
+	}
+
+	for _, providerRecord := range providerRecords {
+		if providerRecord.ProviderType != string(po_entity.CUSTOM) || providerRecord.QuotaType == "" || providerRecord.QuotaLimit == nil {
+			continue
+		}
+
+		systemConfiguration.Enabled = true
+		systemConfiguration.CurrentQuotaType = po_entity.ProviderQuotaType(providerRecord.QuotaType)
+		systemConfiguration.QuotaConfigurations = append(systemConfiguration.QuotaConfigurations, toQuotaConfiguration(providerRecord))
+	}
+
+	return systemConfiguration
+}
+
+func toQuotaConfiguration(providerRecord *po_entity.Provider) *biz_entity_provider_config.QuotaConfiguration {
+	quotaConfiguration := &biz_entity_provider_config.QuotaConfiguration{
+		QuotaType:      po_entity.ProviderQuotaType(providerRecord.QuotaType),
+		QuotaUnit:      biz_entity_provider_config.QuotaUnit(providerRecord.QuotaUnit),
+		QuotaLimit:     biz_entity_provider_config.UNLIMITED_QUOTA,
+		QuotaUsed:      providerRecord.QuotaUsed,
+		RestrictModels: make([]*biz_entity_provider_config.RestrictModels, 0),
+	}
+
+	if quotaConfiguration.QuotaUnit == "" {
+		quotaConfiguration.QuotaUnit = biz_entity_provider_config.TIMES
+	}
+
+	if providerRecord.QuotaLimit != nil {
+		quotaConfiguration.QuotaLimit = *providerRecord.QuotaLimit
+	}
+
+	quotaConfiguration.IsValid = !quotaConfiguration.Exceeded()
+	return quotaConfiguration
+}
+
+// CheckQuota rejects the calls of the provider when its quota is used up.
+func (mpd *ProviderDomain) CheckQuota(providerConfiguration *biz_entity_provider_config.ProviderConfiguration) error {
+	if providerConfiguration.QuotaExceeded() {
+		return errors.WithCode(code.ErrProviderQuotaExceed, "quota of provider %s is exhausted", providerConfiguration.Provider.Provider)
+	}
+	return nil
+}
+
+// DeductQuota deducts the usage of a model call from the quota of the provider in the unit of the quota,
+// nothing is deducted when the provider has no quota or its quota is unlimited.
+func (mpd *ProviderDomain) DeductQuota(ctx context.Context, tenantID, provider, model string, totalTokens int64) error {
+	providerRecord, err := mpd.ProviderRepo.GetTenantProvider(ctx, tenantID, provider, string(po_entity.CUSTOM))
+
+	if err != nil {
+		return err
+	}
+
+	if providerRecord == nil || providerRecord.QuotaType == "" || providerRecord.QuotaLimit == nil {
+		return nil
+	}
+
+	quotaConfiguration := toQuotaConfiguration(providerRecord)
+
+	if quotaConfiguration.QuotaLimit == biz_entity_provider_config.UNLIMITED_QUOTA {
+		return nil
+	}
+
+	var used int64
+
+	switch quotaConfiguration.QuotaUnit {
+	case biz_entity_provider_config.TOKENS:
+		used = totalTokens
+	case biz_entity_provider_config.CREDITS:
+		used, err = mpd.creditsPerCall(ctx, provider, model)
+
+		if err != nil {
+			return err
+		}
+	default:
+		used = 1
+	}
+
+	if used <= 0 {
+		return nil
+	}
+
+	return mpd.ProviderRepo.IncreaseProviderQuotaUsed(ctx, tenantID, provider, providerRecord.QuotaType, used)
+}
+
+// creditsPerCall returns the credits_per_call in the pricing of the model schema, CREDITS_PER_CALL is returned
+// for the models without it.
+func (mpd *ProviderDomain) creditsPerCall(ctx context.Context, provider, model string) (int64, error) {
+	providerRuntime, err := mpd.ProviderRepo.GetProviderInstance(ctx, provider)
+
+	if err != nil {
+		return 0, err
+	}
+
+	modelSchema, err := providerRuntime.GetModelInstance(common.LLM).GetModelSchema(model, nil)
+
+	if err != nil {
+		if errors.IsCode(err, code.ErrModelSchemaNotFound) {
+			return CREDITS_PER_CALL, nil
+		}
+		return 0, err
+	}
+
+	if modelSchema.Pricing == nil || modelSchema.Pricing.CreditsPerCall <= 0 {
+		return CREDITS_PER_CALL, nil
+	}
+
+	return modelSchema.Pricing.CreditsPerCall, nil
+}
+
+// GetQuota returns the quota of the tenant's provider, nil is returned when the provider has no quota.
+func (mpd *ProviderDomain) GetQuota(ctx context.Context, tenantID, provider string) (*biz_entity_provider_config.QuotaConfiguration, error) {
+	providerRecord, err := mpd.ProviderRepo.GetTenantProvider(ctx, tenantID, provider, string(po_entity.CUSTOM))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if providerRecord == nil || providerRecord.QuotaType == "" || providerRecord.QuotaLimit == nil {
+		return nil, nil
+	}
+
+	return toQuotaConfiguration(providerRecord), nil
+}
+
+// ResetQuota sets the quota of the tenant's provider and clears its used quota.
+func (mpd *ProviderDomain) ResetQuota(ctx context.Context, tenantID, provider string, quotaType po_entity.ProviderQuotaType, quotaUnit biz_entity_provider_config.QuotaUnit, quotaLimit int64) error {
+	providerRecord, err := mpd.ProviderRepo.GetTenantProvider(ctx, tenantID, provider, string(po_entity.CUSTOM))
+
+	if err != nil {
+		return err
+	}
+
+	if providerRecord == nil {
+		return errors.WithCode(code.ErrResourceNotFound, "provider %s of tenant %s is not configured", provider, tenantID)
+	}
+
+	providerRecord.QuotaType = string(quotaType)
+	providerRecord.QuotaUnit = string(quotaUnit)
+	providerRecord.QuotaLimit = &quotaLimit
+	providerRecord.QuotaUsed = 0
+
+	return mpd.ProviderRepo.UpdateProviderQuota(ctx, providerRecord)
+}
@@ -0,0 +1,138 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package domain_service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	biz_entity "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider"
+	biz_entity_provider_config "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_configuration"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/provider/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
+
+type fakeQuotaProviderRepo struct {
+	repository.ProviderRepo
+	record *po_entity.Provider
+	used   []int64
+}
+
+func (pr *fakeQuotaProviderRepo) GetTenantProvider(ctx context.Context, tenant string, providerName string, providerType string) (*po_entity.Provider, error) {
+	return pr.record, nil
+}
+
+func (pr *fakeQuotaProviderRepo) GetProviderInstance(ctx context.Context, provider string) (*biz_entity.ProviderRuntime, error) {
+	return &biz_entity.ProviderRuntime{ModelConfPath: "../../../core/model_runtime/model_providers/" + provider}, nil
+}
+
+func (pr *fakeQuotaProviderRepo) IncreaseProviderQuotaUsed(ctx context.Context, tenantID string, providerName string, quotaType string, used int64) error {
+	pr.used = append(pr.used, used)
+	return nil
+}
+
+func quotaRecord(quotaUnit biz_entity_provider_config.QuotaUnit, quotaLimit, quotaUsed int64) *po_entity.Provider {
+	return &po_entity.Provider{
+		ProviderName: "openai",
+		ProviderType: string(po_entity.CUSTOM),
+		QuotaType:    string(po_entity.TRIAL),
+		QuotaUnit:    string(quotaUnit),
+		QuotaLimit:   &quotaLimit,
+		QuotaUsed:    quotaUsed,
+	}
+}
+
+func TestDeductQuota(t *testing.T) {
+	tests := []struct {
+		name   string
+		record *po_entity.Provider
+		model  string
+		want   []int64
+	}{
+		{name: "times", record: quotaRecord(biz_entity_provider_config.TIMES, 10, 0), model: "gpt-4", want: []int64{1}},
+		{name: "default unit is times", record: quotaRecord("", 10, 0), model: "gpt-4o", want: []int64{1}},
+		{name: "tokens", record: quotaRecord(biz_entity_provider_config.TOKENS, 1000, 0), model: "gpt-4o", want: []int64{120}},
+		{name: "credits of the pricing", record: quotaRecord(biz_entity_provider_config.CREDITS, 100, 0), model: "gpt-4", want: []int64{20}},
+		{name: "credits of gpt-4o", record: quotaRecord(biz_entity_provider_config.CREDITS, 100, 0), model: "gpt-4o", want: []int64{1}},
+		{name: "credits of gpt-4o-mini", record: quotaRecord(biz_entity_provider_config.CREDITS, 100, 0), model: "gpt-4o-mini", want: []int64{1}},
+		{name: "credits of unknown model", record: quotaRecord(biz_entity_provider_config.CREDITS, 100, 0), model: "my-finetuned-model", want: []int64{1}},
+		{name: "unlimited", record: quotaRecord(biz_entity_provider_config.CREDITS, biz_entity_provider_config.UNLIMITED_QUOTA, 0), model: "gpt-4"},
+		{name: "no quota", record: &po_entity.Provider{ProviderName: "openai", ProviderType: string(po_entity.CUSTOM)}, model: "gpt-4"},
+		{name: "no record", model: "gpt-4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			providerRepo := &fakeQuotaProviderRepo{record: tt.record}
+			mpd := &ProviderDomain{ProviderRepo: providerRepo}
+
+			if err := mpd.DeductQuota(context.Background(), "tenant-1", "openai", tt.model, 120); err != nil {
+				t.Fatalf("DeductQuota() error = %v", err)
+			}
+
+			if len(providerRepo.used) != len(tt.want) || (len(tt.want) > 0 && providerRepo.used[0] != tt.want[0]) {
+				t.Errorf("deducted %v, want %v", providerRepo.used, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuotaExceeded(t *testing.T) {
+	tests := []struct {
+		name       string
+		quotaLimit int64
+		quotaUsed  int64
+		want       bool
+	}{
+		{name: "below the limit", quotaLimit: 10, quotaUsed: 9, want: false},
+		{name: "at the limit", quotaLimit: 10, quotaUsed: 10, want: true},
+		{name: "over the limit", quotaLimit: 10, quotaUsed: 30, want: true},
+		{name: "zero limit", quotaLimit: 0, quotaUsed: 0, want: true},
+		{name: "unlimited", quotaLimit: biz_entity_provider_config.UNLIMITED_QUOTA, quotaUsed: 1 << 40, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotaConfiguration := toQuotaConfiguration(quotaRecord(biz_entity_provider_config.TIMES, tt.quotaLimit, tt.quotaUsed))
+
+			if got := quotaConfiguration.Exceeded(); got != tt.want {
+				t.Errorf("Exceeded() = %v, want %v", got, tt.want)
+			}
+
+			if quotaConfiguration.IsValid == tt.want {
+				t.Errorf("IsValid = %v, want %v", quotaConfiguration.IsValid, !tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	mpd := &ProviderDomain{}
+
+	providerConfiguration := &biz_entity_provider_config.ProviderConfiguration{
+		Provider:            &biz_entity.ProviderStaticConfiguration{Provider: "openai"},
+		SystemConfiguration: mpd.toSystemConfiguration([]*po_entity.Provider{quotaRecord(biz_entity_provider_config.TIMES, 10, 10)}),
+	}
+
+	err := mpd.CheckQuota(providerConfiguration)
+
+	if !errors.IsCode(err, code.ErrProviderQuotaExceed) {
+		t.Fatalf("expected ErrProviderQuotaExceed, got %v", err)
+	}
+
+	if status := errors.ParseCode(err).HTTPStatus(); status != http.StatusForbidden {
+		t.Errorf("HTTP status = %d, want %d", status, http.StatusForbidden)
+	}
+
+	// the system provider records don't carry the quota of the tenant
+	providerConfiguration.SystemConfiguration = mpd.toSystemConfiguration([]*po_entity.Provider{{ProviderType: string(po_entity.SYSTEM), QuotaType: string(po_entity.TRIAL)}})
+
+	if err := mpd.CheckQuota(providerConfiguration); err != nil {
+		t.Errorf("CheckQuota() error = %v without quota", err)
+	}
+}
@@ -22,6 +22,8 @@ type ProviderRuntime struct {
 	ProviderSchema   *ProviderStaticConfiguration
 	ModelConfPath    string
 	ModelInstanceMap map[string]*biz_entity.AIModelRuntime
+	// Overlay of the external definitions, keyed by the path relative to ModelConfPath
+	Overlay biz_entity.SchemaOverlay
 }
 
 func (mp *ProviderRuntime) Models(modelType common.ModelType) ([]*biz_entity.AIModelStaticConfiguration, error) {
@@ -44,7 +46,13 @@ func (mp *ProviderRuntime) Models(modelType common.ModelType) ([]*biz_entity.AIM
 func (mp *ProviderRuntime) GetProviderSchema() (*ProviderStaticConfiguration, error) {
 	providerName := filepath.Base(mp.ModelConfPath)
 	providerSchemaPath := fmt.Sprintf("%s/%s.yaml", mp.ModelConfPath, providerName)
-	providerContent, err := os.ReadFile(providerSchemaPath)
+	providerContent, ok := mp.Overlay[fmt.Sprintf("%s.yaml", providerName)]
+
+	var err error
+
+	if !ok {
+		providerContent, err = os.ReadFile(providerSchemaPath)
+	}
 
 	if err != nil {
 		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
@@ -98,6 +106,7 @@ func (mp *ProviderRuntime) GetModelInstance(modelType common.ModelType) *biz_ent
 	AIModel := &biz_entity.AIModelRuntime{
 		ModelType:     modelType,
 		ModelConfPath: modelSchemaPath,
+		Overlay:       mp.Overlay.Sub(modelTypeStr),
 	}
 
 	mp.ModelInstanceMap[fmt.Sprintf("%s.%s", providerName, modelType)] = AIModel
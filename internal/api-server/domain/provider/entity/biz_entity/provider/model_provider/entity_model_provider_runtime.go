@@ -7,6 +7,7 @@ package biz_entity
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"slices"
 	"sort"
 	"strings"
@@ -24,6 +25,7 @@ type IAIModelRuntime interface {
 	GetModelSchema(modelName string, credentials any) (*AIModelStaticConfiguration, error)
 	PredefinedModels() ([]*AIModelStaticConfiguration, error)
 	GetModelPositionMap() (map[string]int, error)
+	ValidateParameters(model string, credentials any, parameters map[string]interface{}, strict bool) (map[string]interface{}, error)
 }
 
 type AIModelRuntime struct {
@@ -31,6 +33,8 @@ type AIModelRuntime struct {
 	ModelSchemas  []*AIModelStaticConfiguration `json:"model_schemas" yaml:"model_schemas"`
 	StartedAt     float64                       `json:"started_at" yaml:"started_at"`
 	ModelConfPath string                        `json:"model_conf_path" yaml:"model_conf_path"`
+	// Overlay of the external definitions, keyed by the file name within ModelConfPath
+	Overlay SchemaOverlay `json:"-" yaml:"-"`
 }
 
 func (a *AIModelRuntime) GetModelPositionMap() (map[string]int, error) {
@@ -40,7 +44,7 @@ func (a *AIModelRuntime) GetModelPositionMap() (map[string]int, error) {
 	modelConfDir := a.ModelConfPath
 	positionFilePath := fmt.Sprintf("%s/_position.yaml", modelConfDir)
 
-	positionContext, err := os.ReadFile(positionFilePath)
+	positionContext, err := a.readFile("_position.yaml", positionFilePath)
 
 	if os.IsNotExist(err) {
 		return positionMap, nil
@@ -71,11 +75,11 @@ func (a *AIModelRuntime) PredefinedModels() ([]*AIModelStaticConfiguration, erro
 
 	dirEntries, err := os.ReadDir(modelConfDir)
 
-	if os.IsNotExist(err) {
+	if os.IsNotExist(err) && len(a.Overlay) == 0 {
 		return nil, nil
 	}
 
-	if err != nil {
+	if err != nil && !os.IsNotExist(err) {
 		return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
 	}
 	modelPosition, err := a.GetModelPositionMap()
@@ -91,10 +95,26 @@ func (a *AIModelRuntime) PredefinedModels() ([]*AIModelStaticConfiguration, erro
 		}
 	}
 
+	// models added by the external definitions
+	overlayFileNames := make([]string, 0, len(a.Overlay))
+
+	for fileName := range a.Overlay {
+		overlayFileNames = append(overlayFileNames, fileName)
+	}
+
+	sort.Strings(overlayFileNames)
+
+	for _, fileName := range overlayFileNames {
+		modelPath := fmt.Sprintf("%s/%s", modelConfDir, fileName)
+		if !strings.HasPrefix(fileName, "_") && !slices.Contains(modelSchemaYamlPath, modelPath) {
+			modelSchemaYamlPath = append(modelSchemaYamlPath, modelPath)
+		}
+	}
+
 	for _, modelSchemaYamlPath := range modelSchemaYamlPath {
 		AIModelEntity := &AIModelStaticConfiguration{ProviderModel: &common.ProviderModel{}}
 		AIModelEntity.FetchFrom = common.PREDEFINED_MODEL_FROM
-		AIModelEntityContent, err := os.ReadFile(modelSchemaYamlPath)
+		AIModelEntityContent, err := a.readFile(filepath.Base(modelSchemaYamlPath), modelSchemaYamlPath)
 		if err != nil {
 			return nil, errors.WithSCode(code.ErrRunTimeCaller, err.Error())
 		}
@@ -145,6 +165,15 @@ func (a *AIModelRuntime) PredefinedModels() ([]*AIModelStaticConfiguration, erro
 	return AIModelEntities, nil
 }
 
+// readFile reads the file of the overlay if there is one, or the embedded file at path.
+func (a *AIModelRuntime) readFile(fileName, path string) ([]byte, error) {
+	if content, ok := a.Overlay[fileName]; ok {
+		return content, nil
+	}
+
+	return os.ReadFile(path)
+}
+
 func (a *AIModelRuntime) GetModelSchema(modelName string, credentials any) (*AIModelStaticConfiguration, error) {
 
 	models, err := a.PredefinedModels()
@@ -162,6 +191,27 @@ func (a *AIModelRuntime) GetModelSchema(modelName string, credentials any) (*AIM
 	return nil, errors.WithCode(code.ErrModelSchemaNotFound, "model schema %s is not found", modelName)
 }
 
+// ValidateParameters validates the parameters against the parameter rules of the model, the parameters of a
+// customizable model which isn't predefined are returned as they are.
+func (a *AIModelRuntime) ValidateParameters(model string, credentials any, parameters map[string]interface{}, strict bool) (map[string]interface{}, error) {
+	modelSchema, err := a.GetModelSchema(model, credentials)
+
+	if err != nil {
+		if errors.IsCode(err, code.ErrModelSchemaNotFound) {
+			return parameters, nil
+		}
+		return nil, err
+	}
+
+	validated, err := ValidateParameterRules(modelSchema.ParameterRules, parameters, strict)
+
+	if err != nil {
+		return nil, errors.WrapC(err, code.ErrModelParameter, "invalid parameters of model %s: %s", model, err.Error())
+	}
+
+	return validated, nil
+}
+
 func (a *AIModelRuntime) GetPrice(model string, credentials any, priceType PriceType, tokens int64) (*PriceInfo, error) {
 	var (
 		priceConfig *PriceConfig
@@ -24,6 +24,8 @@ type PriceConfig struct {
 	Output   field.Float64 `json:"output" yaml:"output"`
 	Unit     field.Float64 `json:"unit" yaml:"unit"`
 	Currency string        `json:"currency" yaml:"currency"`
+	// CreditsPerCall is the credits deducted from the provider quota by a call of the model, 1 when it's not set
+	CreditsPerCall int64 `json:"credits_per_call,omitempty" yaml:"credits_per_call"`
 }
 
 type ParameterRule struct {
@@ -0,0 +1,199 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package biz_entity
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParameterError is the error of a model parameter which doesn't satisfy its parameter rule.
+type ParameterError struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+}
+
+// ParameterErrors are the errors of all the invalid model parameters.
+type ParameterErrors []*ParameterError
+
+func (e ParameterErrors) Error() string {
+	messages := make([]string, 0, len(e))
+
+	for _, parameterError := range e {
+		messages = append(messages, fmt.Sprintf("%s: %s", parameterError.Name, parameterError.Message))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Details returns the errors of every parameter to the client.
+func (e ParameterErrors) Details() interface{} {
+	return e
+}
+
+// ValidateParameterRules coerces the parameters to the types of their rules, fills the defaults of the required
+// parameters and drops the parameters which have no rule. The out of range values are rejected in strict mode and
+// clamped otherwise, and so are the values out of the options, which are replaced by the default of the rule or
+// dropped when there is none. The parameters are returned as they are when there is no rule.
+func ValidateParameterRules(rules []*ParameterRule, parameters map[string]interface{}, strict bool) (map[string]interface{}, error) {
+	if len(rules) == 0 {
+		return parameters, nil
+	}
+
+	var parameterErrors ParameterErrors
+
+	validated := make(map[string]interface{}, len(rules))
+
+	for _, rule := range rules {
+		value, ok := parameters[rule.Name]
+
+		if !ok || value == nil {
+			if !rule.Required {
+				continue
+			}
+
+			if rule.Default == nil {
+				parameterErrors = append(parameterErrors, &ParameterError{Name: rule.Name, Message: "is required"})
+				continue
+			}
+
+			value = rule.Default
+		}
+
+		value, err := rule.coerce(value, strict)
+
+		if err != nil {
+			parameterErrors = append(parameterErrors, &ParameterError{Name: rule.Name, Message: err.Error()})
+			continue
+		}
+
+		// the value out of the options without a default is dropped in non-strict mode
+		if value == nil {
+			if rule.Required {
+				parameterErrors = append(parameterErrors, &ParameterError{Name: rule.Name, Message: fmt.Sprintf("must be one of %s", strings.Join(rule.Options, ", "))})
+			}
+			continue
+		}
+
+		validated[rule.Name] = value
+	}
+
+	if len(parameterErrors) > 0 {
+		return nil, parameterErrors
+	}
+
+	return validated, nil
+}
+
+func (rule *ParameterRule) coerce(value interface{}, strict bool) (interface{}, error) {
+	switch rule.Type {
+	case INT:
+		number, err := toNumber(value)
+
+		if err != nil || number != math.Trunc(number) {
+			return nil, fmt.Errorf("must be an integer")
+		}
+
+		number, err = rule.checkRange(number, strict)
+
+		if err != nil {
+			return nil, err
+		}
+		return int(number), nil
+	case FLOAT:
+		number, err := toNumber(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+
+		number, err = rule.checkRange(number, strict)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rule.Precision > 0 {
+			scale := math.Pow10(rule.Precision)
+			number = math.Round(number*scale) / scale
+		}
+		return number, nil
+	case BOOLEAN:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("must be a boolean")
+	case STRING, TEXT:
+		str, ok := value.(string)
+
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+
+		if len(rule.Options) > 0 && !slices.Contains(rule.Options, str) {
+			if strict {
+				return nil, fmt.Errorf("must be one of %s", strings.Join(rule.Options, ", "))
+			}
+
+			if defaultStr, ok := rule.Default.(string); ok && slices.Contains(rule.Options, defaultStr) {
+				return defaultStr, nil
+			}
+			return nil, nil
+		}
+		return str, nil
+	default:
+		return value, nil
+	}
+}
+
+// checkRange checks the number against min and max of the rule, both of them being zero means there is no range and
+// a max not greater than min means there is no upper bound.
+func (rule *ParameterRule) checkRange(number float64, strict bool) (float64, error) {
+	if rule.Min == 0 && rule.Max == 0 {
+		return number, nil
+	}
+
+	if number < rule.Min {
+		if strict {
+			return 0, fmt.Errorf("must be greater than or equal to %v", rule.Min)
+		}
+		return rule.Min, nil
+	}
+
+	if rule.Max > rule.Min && number > rule.Max {
+		if strict {
+			return 0, fmt.Errorf("must be less than or equal to %v", rule.Max)
+		}
+		return rule.Max, nil
+	}
+
+	return number, nil
+}
+
+func toNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("%v is not a number", value)
+	}
+}
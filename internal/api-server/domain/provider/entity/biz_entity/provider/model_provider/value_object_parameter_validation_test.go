@@ -0,0 +1,134 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package biz_entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateParameterRules(t *testing.T) {
+	rules := []*ParameterRule{
+		{Name: "temperature", Type: FLOAT, Min: 0, Max: 2, Precision: 1},
+		{Name: "max_tokens", Type: INT, Required: true, Default: 512, Min: 1, Max: 4096},
+		{Name: "top_k", Type: INT, Min: 1},
+		{Name: "response_format", Type: STRING, Options: []string{"text", "json_object"}},
+		{Name: "mode", Type: STRING, Default: "chat", Options: []string{"chat", "completion"}},
+		{Name: "stream", Type: BOOLEAN},
+	}
+
+	tests := []struct {
+		name       string
+		parameters map[string]interface{}
+		strict     bool
+		want       map[string]interface{}
+		wantErrors []string
+	}{
+		{
+			name:       "required default",
+			parameters: map[string]interface{}{},
+			want:       map[string]interface{}{"max_tokens": 512},
+		},
+		{
+			name:       "coerced types",
+			parameters: map[string]interface{}{"temperature": "0.7", "max_tokens": float64(100), "stream": "true"},
+			strict:     true,
+			want:       map[string]interface{}{"temperature": 0.7, "max_tokens": 100, "stream": true},
+		},
+		{
+			name:       "precision",
+			parameters: map[string]interface{}{"temperature": 0.66},
+			strict:     true,
+			want:       map[string]interface{}{"temperature": 0.7, "max_tokens": 512},
+		},
+		{
+			name:       "unknown keys are dropped",
+			parameters: map[string]interface{}{"max_tokens": 8, "frequency_penalty": 0.5, "seed": 42},
+			strict:     true,
+			want:       map[string]interface{}{"max_tokens": 8},
+		},
+		{
+			name:       "clamped in non-strict mode",
+			parameters: map[string]interface{}{"temperature": 3.5, "max_tokens": 0, "top_k": -3},
+			want:       map[string]interface{}{"temperature": float64(2), "max_tokens": 1, "top_k": 1},
+		},
+		{
+			name:       "clamped to max",
+			parameters: map[string]interface{}{"max_tokens": 10000, "top_k": 10000},
+			want:       map[string]interface{}{"max_tokens": 4096, "top_k": 10000},
+		},
+		{
+			name:       "rejected in strict mode",
+			parameters: map[string]interface{}{"temperature": 3.5, "max_tokens": 0},
+			strict:     true,
+			wantErrors: []string{"temperature", "max_tokens"},
+		},
+		{
+			name:       "invalid types",
+			parameters: map[string]interface{}{"temperature": "hot", "max_tokens": 1.5, "stream": "maybe"},
+			wantErrors: []string{"temperature", "max_tokens", "stream"},
+		},
+		{
+			name:       "options",
+			parameters: map[string]interface{}{"response_format": "json_object", "mode": "completion"},
+			strict:     true,
+			want:       map[string]interface{}{"response_format": "json_object", "mode": "completion", "max_tokens": 512},
+		},
+		{
+			name:       "options rejected in strict mode",
+			parameters: map[string]interface{}{"response_format": "json_schema"},
+			strict:     true,
+			wantErrors: []string{"response_format"},
+		},
+		{
+			name:       "options dropped or defaulted in non-strict mode",
+			parameters: map[string]interface{}{"response_format": "json_schema", "mode": "agent"},
+			want:       map[string]interface{}{"mode": "chat", "max_tokens": 512},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateParameterRules(rules, tt.parameters, tt.strict)
+
+			if len(tt.wantErrors) > 0 {
+				parameterErrors, ok := err.(ParameterErrors)
+
+				if !ok {
+					t.Fatalf("expected ParameterErrors, got %v", err)
+				}
+
+				names := make([]string, 0, len(parameterErrors))
+
+				for _, parameterError := range parameterErrors {
+					names = append(names, parameterError.Name)
+				}
+
+				if !reflect.DeepEqual(names, tt.wantErrors) {
+					t.Errorf("errors of %v, want %v", names, tt.wantErrors)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ValidateParameterRules() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ValidateParameterRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateParameterRulesWithoutRules(t *testing.T) {
+	parameters := map[string]interface{}{"temperature": 9}
+
+	got, err := ValidateParameterRules(nil, parameters, true)
+
+	if err != nil || !reflect.DeepEqual(got, parameters) {
+		t.Errorf("ValidateParameterRules() = %v, %v, want the parameters as they are", got, err)
+	}
+}
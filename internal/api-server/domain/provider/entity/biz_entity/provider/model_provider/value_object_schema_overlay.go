@@ -0,0 +1,69 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package biz_entity
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaOverlay holds the yaml files of the external provider definitions which are read instead of the embedded
+// ones, keyed by the path relative to the dir of the provider, e.g. openai.yaml and llm/gpt-4o.yaml.
+type SchemaOverlay map[string][]byte
+
+// Sub returns the files of the overlay under dir, keyed by the path relative to dir.
+func (o SchemaOverlay) Sub(dir string) SchemaOverlay {
+	sub := make(SchemaOverlay)
+	prefix := dir + "/"
+
+	for path, content := range o {
+		if strings.HasPrefix(path, prefix) {
+			sub[strings.TrimPrefix(path, prefix)] = content
+		}
+	}
+
+	return sub
+}
+
+// MergeYAML merges the override yaml over the base yaml, mappings are merged recursively while
+// the other values including sequences are replaced.
+func MergeYAML(base, override []byte) ([]byte, error) {
+	var baseValue, overrideValue interface{}
+
+	if err := yaml.Unmarshal(base, &baseValue); err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(override, &overrideValue); err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(mergeValue(baseValue, overrideValue))
+}
+
+func mergeValue(base, override interface{}) interface{} {
+	baseMap, baseOk := base.(map[string]interface{})
+	overrideMap, overrideOk := override.(map[string]interface{})
+
+	if !baseOk || !overrideOk {
+		if override == nil {
+			return base
+		}
+		return override
+	}
+
+	merged := make(map[string]interface{}, len(baseMap)+len(overrideMap))
+
+	for k, v := range baseMap {
+		merged[k] = v
+	}
+
+	for k, v := range overrideMap {
+		merged[k] = mergeValue(baseMap[k], v)
+	}
+
+	return merged
+}
@@ -4,7 +4,14 @@
 
 package biz_entity
 
-import common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	common "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/common_relation"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+)
 
 const (
 	PATCH_FUNCTION_NAME = "PatchZh"
@@ -67,3 +74,82 @@ type ModelCredentialSchema struct {
 type ProviderCredentialSchema struct {
 	CredentialFormSchemas []*CredentialFormSchema `json:"credential_form_schemas" yaml:"credential_form_schemas"`
 }
+
+// ValidateCredentials checks the credentials against the form schemas and fills the default values of the absent
+// fields in place, the fields hidden by show_on are skipped and the variables which aren't declared are kept.
+func (s *ProviderCredentialSchema) ValidateCredentials(credentials map[string]interface{}) error {
+	for _, schema := range s.CredentialFormSchemas {
+		if !schema.shown(credentials) {
+			continue
+		}
+
+		value, err := schema.stringValue(credentials[schema.Variable])
+
+		if err != nil {
+			return err
+		}
+
+		if value == "" {
+			if schema.DefaultValue != "" {
+				credentials[schema.Variable] = schema.DefaultValue
+				continue
+			}
+
+			if schema.Required {
+				return errors.WithCode(code.ErrCredentialSchema, "credential %s is required", schema.Variable)
+			}
+
+			continue
+		}
+
+		if schema.MaxLength > 0 && utf8.RuneCountInString(value) > schema.MaxLength {
+			return errors.WithCode(code.ErrCredentialSchema, "credential %s exceeds the max length %d", schema.Variable, schema.MaxLength)
+		}
+
+		if (schema.Type == SELECT || schema.Type == RADIO) && !schema.hasOption(value, credentials) {
+			return errors.WithCode(code.ErrCredentialSchema, "credential %s doesn't have the option %s", schema.Variable, value)
+		}
+	}
+
+	return nil
+}
+
+// stringValue accepts the booleans of the switch fields besides the strings.
+func (s *CredentialFormSchema) stringValue(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case bool:
+		if s.Type == SWITCH {
+			return fmt.Sprint(value), nil
+		}
+	}
+
+	return "", errors.WithCode(code.ErrCredentialSchema, "credential %s must be a string, got %T", s.Variable, value)
+}
+
+func (s *CredentialFormSchema) shown(credentials map[string]interface{}) bool {
+	return showOn(s.ShowOn, credentials)
+}
+
+func (s *CredentialFormSchema) hasOption(value string, credentials map[string]interface{}) bool {
+	for _, option := range s.Options {
+		if option.Value == value && showOn(option.ShowOn, credentials) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func showOn(conditions []*FormShowOnObject, credentials map[string]interface{}) bool {
+	for _, condition := range conditions {
+		if value, _ := credentials[condition.Variable].(string); value != condition.Value {
+			return false
+		}
+	}
+
+	return true
+}
@@ -11,6 +11,8 @@ import (
 
 type BasedAppGenerateEntity interface {
 	GetModel() string
+	GetProvider() string
+	GetTenantID() string
 	GetTaskID() string
 	GetConversationID() string
 	GetQuery() string
@@ -92,6 +94,14 @@ func (cag *ChatAppGenerateEntity) GetModel() string {
 	return cag.ModelConf.Model
 }
 
+func (cag *ChatAppGenerateEntity) GetProvider() string {
+	return cag.ModelConf.Provider
+}
+
+func (cag *ChatAppGenerateEntity) GetTenantID() string {
+	return cag.EasyUIBasedAppGenerateEntity.AppConfig.TenantID
+}
+
 func (cag *ChatAppGenerateEntity) GetTaskID() string {
 	return cag.EasyUIBasedAppGenerateEntity.TaskID
 }
@@ -115,6 +125,14 @@ func (cag *AgentChatAppGenerateEntity) GetModel() string {
 	return cag.EasyUIBasedAppGenerateEntity.ModelConf.Model
 }
 
+func (cag *AgentChatAppGenerateEntity) GetProvider() string {
+	return cag.EasyUIBasedAppGenerateEntity.ModelConf.Provider
+}
+
+func (cag *AgentChatAppGenerateEntity) GetTenantID() string {
+	return cag.EasyUIBasedAppGenerateEntity.AppConfig.TenantID
+}
+
 func (cag *AgentChatAppGenerateEntity) GetTaskID() string {
 	return cag.EasyUIBasedAppGenerateEntity.TaskID
 }
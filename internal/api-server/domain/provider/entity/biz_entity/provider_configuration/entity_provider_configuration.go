@@ -33,6 +33,7 @@ type ModelConfigWithCredentialsEntity struct {
 	Credentials         interface{}                                  `json:"credentials"`
 	Parameters          map[string]interface{}                       `json:"parameters"`
 	Stop                []string                                     `json:"stop"`
+	Fallbacks           []*ModelIntegratedInstance                   `json:"fallbacks"`
 }
 
 type ModelWithProvider struct {
@@ -131,6 +132,12 @@ func (c *ProviderConfiguration) SetManager(manager ProviderReposGetter) {
 	c.ProviderReposGetter = manager
 }
 
+// QuotaExceeded reports whether the current quota of the provider is used up.
+func (c *ProviderConfiguration) QuotaExceeded() bool {
+	quotaConfiguration := c.SystemConfiguration.CurrentQuotaConfiguration()
+	return quotaConfiguration != nil && quotaConfiguration.Exceeded()
+}
+
 func (c *ProviderConfiguration) GetCurrentCredentials(modelType common.ModelType, model string) (map[string]interface{}, error) {
 	var credentials map[string]interface{}
 	if c.CustomConfiguration.Models != nil {
@@ -150,6 +157,22 @@ func (c *ProviderConfiguration) GetCurrentCredentials(modelType common.ModelType
 
 }
 
+// GetLoadBalancingSettings returns the settings of the model when load balancing is enabled and it has enabled
+// configs, otherwise nil is returned and the current credentials should be used.
+func (c *ProviderConfiguration) GetLoadBalancingSettings(modelType common.ModelType, model string) *ModelSettings {
+	for _, modelSetting := range c.ModelSettings {
+		if modelSetting.ModelType != modelType || modelSetting.Model != model {
+			continue
+		}
+
+		if modelSetting.LoadBalancingEnabled && len(modelSetting.LoadBalancingConfigs) > 0 {
+			return modelSetting
+		}
+		return nil
+	}
+	return nil
+}
+
 func (pc *ProviderConfiguration) GetProviderModels(ctx context.Context, modelType common.ModelType, onlyActive bool) ([]*ModelWithProvider, error) {
 
 	if err := pc.ensureManager(); err != nil {
@@ -181,7 +204,10 @@ func (pc *ProviderConfiguration) GetProviderModels(ctx context.Context, modelTyp
 	modelSettingMap := make(map[string]map[string]ModelSettings)
 
 	for _, modelSetting := range pc.ModelSettings {
-		modelSettingMap[string(modelSetting.Model)][modelSetting.Model] = *modelSetting
+		if _, ok := modelSettingMap[string(modelSetting.ModelType)]; !ok {
+			modelSettingMap[string(modelSetting.ModelType)] = make(map[string]ModelSettings)
+		}
+		modelSettingMap[string(modelSetting.ModelType)][modelSetting.Model] = *modelSetting
 	}
 
 	if pc.UsingProviderType == po_entity.CUSTOM {
@@ -233,6 +259,10 @@ func (pc *ProviderConfiguration) getCustomProviderModels(modelTypes []common.Mod
 				status = NO_CONFIGURE
 			}
 
+			if status == ACTIVE && pc.QuotaExceeded() {
+				status = QUOTA_EXCEEDED
+			}
+
 			if _, ok := modelSettingMap[string(modelType)]; ok {
 				if modelSetting, ok := modelSettingMap[string(modelType)][AIModelEntity.Model]; ok {
 					if !modelSetting.Enabled {
@@ -28,9 +28,20 @@ const (
 )
 
 type ModelSettings struct {
-	Model     string
-	ModelType common.ModelType
-	Enabled   bool
+	Model                 string
+	ModelType             common.ModelType
+	Enabled               bool
+	LoadBalancingEnabled  bool
+	LoadBalancingStrategy po_entity.LoadBalancingStrategy
+	// LoadBalancingConfigs are the enabled credentials which the requests of the model are balanced across
+	LoadBalancingConfigs []*ModelLoadBalancingConfiguration
+}
+
+type ModelLoadBalancingConfiguration struct {
+	ID          string
+	Name        string
+	Credentials map[string]interface{}
+	Weight      int
 }
 
 type SystemConfiguration struct {
@@ -40,19 +51,41 @@ type SystemConfiguration struct {
 	Credentials         interface{}
 }
 
+// CurrentQuotaConfiguration returns the quota of the current quota type, nil is returned when the provider has no quota.
+func (sc *SystemConfiguration) CurrentQuotaConfiguration() *QuotaConfiguration {
+	if sc == nil || !sc.Enabled {
+		return nil
+	}
+
+	for _, quotaConfiguration := range sc.QuotaConfigurations {
+		if quotaConfiguration.QuotaType == sc.CurrentQuotaType {
+			return quotaConfiguration
+		}
+	}
+	return nil
+}
+
 type RestrictModels struct {
-	Model         string
-	BaseModelName string
-	ModelType     string
+	Model         string `json:"model"`
+	BaseModelName string `json:"base_model_name"`
+	ModelType     string `json:"model_type"`
 }
 
+// UNLIMITED_QUOTA is the quota limit which is never exceeded
+const UNLIMITED_QUOTA = -1
+
 type QuotaConfiguration struct {
-	QuotaType      po_entity.ProviderQuotaType
-	QuotaUnit      QuotaUnit
-	QuotaLimit     int
-	QuotaUsed      int
-	IsValid        bool
-	RestrictModels []*RestrictModels
+	QuotaType      po_entity.ProviderQuotaType `json:"quota_type"`
+	QuotaUnit      QuotaUnit                   `json:"quota_unit"`
+	QuotaLimit     int64                       `json:"quota_limit"`
+	QuotaUsed      int64                       `json:"quota_used"`
+	IsValid        bool                        `json:"is_valid"`
+	RestrictModels []*RestrictModels           `json:"restrict_models"`
+}
+
+// Exceeded reports whether the quota is used up, the calls are rejected before generation then.
+func (qc *QuotaConfiguration) Exceeded() bool {
+	return qc.QuotaLimit != UNLIMITED_QUOTA && qc.QuotaUsed >= qc.QuotaLimit
 }
 
 type CustomConfiguration struct {
@@ -49,3 +49,48 @@ func (u *TenantDefaultModel) BeforeCreate(tx *gorm.DB) (err error) {
 	u.ID = uuid.NewString()
 	return
 }
+
+type ProviderModelSetting struct {
+	ID                    string        `gorm:"column:id"                        json:"id"`
+	TenantID              string        `gorm:"column:tenant_id"                 json:"tenant_id"`
+	ProviderName          string        `gorm:"column:provider_name"             json:"provider_name"`
+	ModelName             string        `gorm:"column:model_name"                json:"model_name"`
+	ModelType             string        `gorm:"column:model_type"                json:"model_type"`
+	Enabled               field.BitBool `gorm:"column:enabled"                   json:"enabled"`
+	LoadBalancingEnabled  field.BitBool `gorm:"column:load_balancing_enabled"    json:"load_balancing_enabled"`
+	LoadBalancingStrategy string        `gorm:"column:load_balancing_strategy"   json:"load_balancing_strategy"`
+	CreatedAt             int64         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt             int64         `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (*ProviderModelSetting) TableName() string {
+	return "provider_model_settings"
+}
+
+func (u *ProviderModelSetting) BeforeCreate(tx *gorm.DB) (err error) {
+	u.ID = uuid.NewString()
+	return
+}
+
+type LoadBalancingModelConfig struct {
+	ID              string        `gorm:"column:id"                        json:"id"`
+	TenantID        string        `gorm:"column:tenant_id"                 json:"tenant_id"`
+	ProviderName    string        `gorm:"column:provider_name"             json:"provider_name"`
+	ModelName       string        `gorm:"column:model_name"                json:"model_name"`
+	ModelType       string        `gorm:"column:model_type"                json:"model_type"`
+	Name            string        `gorm:"column:name"                      json:"name"`
+	EncryptedConfig string        `gorm:"column:encrypted_config"          json:"encrypted_config,omitempty"`
+	Weight          int           `gorm:"column:weight"                    json:"weight"`
+	Enabled         field.BitBool `gorm:"column:enabled"                   json:"enabled"`
+	CreatedAt       int64         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
+	UpdatedAt       int64         `gorm:"column:updated_at;autoUpdateTime" json:"updated_at"`
+}
+
+func (*LoadBalancingModelConfig) TableName() string {
+	return "load_balancing_model_configs"
+}
+
+func (u *LoadBalancingModelConfig) BeforeCreate(tx *gorm.DB) (err error) {
+	u.ID = uuid.NewString()
+	return
+}
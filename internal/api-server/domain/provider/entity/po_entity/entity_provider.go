@@ -21,6 +21,7 @@ type Provider struct {
 	IsValid         field.BitBool `gorm:"column:is_valid"                  json:"is_valid"`
 	LastUsed        *time.Time    `gorm:"column:last_used"                 json:"last_used,omitempty"`
 	QuotaType       string        `gorm:"column:quota_type"                json:"quota_type,omitempty"`
+	QuotaUnit       string        `gorm:"column:quota_unit"                json:"quota_unit,omitempty"`
 	QuotaLimit      *int64        `gorm:"column:quota_limit"               json:"quota_limit,omitempty"`
 	QuotaUsed       int64         `gorm:"column:quota_used"                json:"quota_used"`
 	CreatedAt       int64         `gorm:"column:created_at;autoCreateTime" json:"created_at"`
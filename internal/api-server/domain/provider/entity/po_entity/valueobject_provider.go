@@ -20,3 +20,11 @@ const (
 
 	TRIAL ProviderQuotaType = "trial"
 )
+
+type LoadBalancingStrategy string
+
+const (
+	ROUND_ROBIN LoadBalancingStrategy = "round_robin"
+
+	WEIGHTED LoadBalancingStrategy = "weighted"
+)
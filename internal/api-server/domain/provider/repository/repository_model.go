@@ -26,4 +26,26 @@ type ModelRepo interface {
 	GetTenantDefaultModel(ctx context.Context, tenantId, modelType string) (*po_entity.TenantDefaultModel, error)
 
 	UpdateTenantDefaultModel(ctx context.Context, tenantDefaultModel *po_entity.TenantDefaultModel) error
+
+	// GetTenantModelSettings get all model settings of the tenant
+	GetTenantModelSettings(ctx context.Context, tenantId string) ([]*po_entity.ProviderModelSetting, error)
+	// GetTenantModelSetting get the setting of the model, nil is returned when the model has never been set
+	GetTenantModelSetting(ctx context.Context, tenantId, providerName, modelName, modelType string) (*po_entity.ProviderModelSetting, error)
+
+	CreateModelSetting(ctx context.Context, modelSetting *po_entity.ProviderModelSetting) error
+
+	UpdateModelSetting(ctx context.Context, modelSetting *po_entity.ProviderModelSetting) error
+
+	// GetTenantLoadBalancingConfigs get all load balancing configs of the tenant
+	GetTenantLoadBalancingConfigs(ctx context.Context, tenantId string) ([]*po_entity.LoadBalancingModelConfig, error)
+	// GetModelLoadBalancingConfigs get the load balancing configs of the model
+	GetModelLoadBalancingConfigs(ctx context.Context, tenantId, providerName, modelName, modelType string) ([]*po_entity.LoadBalancingModelConfig, error)
+	// GetLoadBalancingConfigByID get the load balancing config, nil is returned when it doesn't exist
+	GetLoadBalancingConfigByID(ctx context.Context, tenantId, configID string) (*po_entity.LoadBalancingModelConfig, error)
+
+	CreateLoadBalancingConfig(ctx context.Context, config *po_entity.LoadBalancingModelConfig) error
+
+	UpdateLoadBalancingConfig(ctx context.Context, config *po_entity.LoadBalancingModelConfig) error
+
+	DeleteLoadBalancingConfig(ctx context.Context, tenantId, configID string) error
 }
@@ -32,4 +32,8 @@ type ProviderRepo interface {
 	GetProviderInstance(ctx context.Context, provider string) (*biz_entity.ProviderRuntime, error)
 	// GetProviders get all provider by searchProvider
 	GetTenantProvider(ctx context.Context, tenant string, providerName string, providerType string) (*po_entity.Provider, error)
+	// UpdateProviderQuota updates the quota columns of the provider, zero values included
+	UpdateProviderQuota(ctx context.Context, provider *po_entity.Provider) error
+	// IncreaseProviderQuotaUsed adds used to the used quota of the tenant's provider which has the quota type
+	IncreaseProviderQuotaUsed(ctx context.Context, tenantID string, providerName string, quotaType string, used int64) error
 }
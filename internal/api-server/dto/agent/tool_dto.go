@@ -1,5 +1,7 @@
 package dto
 
+import "github.com/lunarianss/Luna/internal/api-server/domain/agent/entity/biz_entity"
+
 type ListIconUri struct {
 	Provider string `json:"provider" uri:"provider"`
 }
@@ -44,3 +46,101 @@ type BuildFile struct {
 	BelongsTo      string      `json:"belongs_to"`
 	Url            string      `json:"url"`
 }
+
+// --
+// --- Api tool provider
+// --
+type ApiToolProviderCredentials struct {
+	AuthType     string `json:"auth_type"  validate:"required,oneof=none api_key_header api_key_query bearer"`
+	APIKeyName   string `json:"api_key_name"`
+	APIKeyPrefix string `json:"api_key_prefix"`
+	APIKeyValue  string `json:"api_key_value"`
+}
+
+// AddApiToolProviderBody takes the uploaded schema, or the url of the schema which is fetched when the schema is empty.
+type AddApiToolProviderBody struct {
+	Provider    string                      `json:"provider"  validate:"required,max=255"`
+	Icon        string                      `json:"icon"`
+	Description string                      `json:"description"`
+	Schema      string                      `json:"schema"`
+	SchemaURL   string                      `json:"schema_url"  validate:"omitempty,url"`
+	Credentials *ApiToolProviderCredentials `json:"credentials"  validate:"required"`
+}
+
+type UpdateApiToolProviderBody struct {
+	AddApiToolProviderBody
+	OriginalProvider string `json:"original_provider"  validate:"required"`
+}
+
+type DeleteApiToolProviderBody struct {
+	Provider string `json:"provider"  validate:"required"`
+}
+
+type ApiToolProviderQuery struct {
+	Provider string `form:"provider"  validate:"required"`
+}
+
+type RemoteApiSchemaQuery struct {
+	URL string `form:"url"  validate:"required,url"`
+}
+
+type ParseApiSchemaBody struct {
+	Schema string `json:"schema"  validate:"required"`
+}
+
+type ParseApiSchemaResponse struct {
+	SchemaType       string                      `json:"schema_type"`
+	ParametersSchema []*biz_entity.ApiToolBundle `json:"parameters_schema"`
+}
+
+type ApiToolProviderDetail struct {
+	ID          string                 `json:"id"`
+	Provider    string                 `json:"provider"`
+	Icon        string                 `json:"icon"`
+	Description string                 `json:"description"`
+	SchemaType  string                 `json:"schema_type"`
+	Schema      string                 `json:"schema"`
+	Credentials map[string]any         `json:"credentials"`
+	Tools       []*biz_entity.UserTool `json:"tools"`
+}
+
+// --- Mcp tool provider
+// --
+type McpServerConfigBody struct {
+	Transport string   `json:"transport"  validate:"required,oneof=stdio streamable_http sse"`
+	Command   string   `json:"command"  validate:"required_if=Transport stdio"`
+	Args      []string `json:"args"`
+	// Env and Headers are the secrets of the server, the values which are unchanged are sent back hidden
+	Env     map[string]string `json:"env"`
+	URL     string            `json:"url"  validate:"required_unless=Transport stdio"`
+	Headers map[string]string `json:"headers"`
+}
+
+type AddMcpToolProviderBody struct {
+	Provider    string               `json:"provider"  validate:"required,max=255"`
+	Icon        string               `json:"icon"`
+	Description string               `json:"description"`
+	Server      *McpServerConfigBody `json:"server"  validate:"required"`
+}
+
+type UpdateMcpToolProviderBody struct {
+	AddMcpToolProviderBody
+	ProviderID string `json:"provider_id"  validate:"required"`
+}
+
+type McpToolProviderBody struct {
+	ProviderID string `json:"provider_id"  validate:"required"`
+}
+
+type McpToolProviderQuery struct {
+	ProviderID string `form:"provider_id"  validate:"required"`
+}
+
+type McpToolProviderDetail struct {
+	ID          string                 `json:"id"`
+	Provider    string                 `json:"provider"`
+	Icon        string                 `json:"icon"`
+	Description string                 `json:"description"`
+	Server      map[string]any         `json:"server"`
+	Tools       []*biz_entity.UserTool `json:"tools"`
+}
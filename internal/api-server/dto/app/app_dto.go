@@ -126,6 +126,13 @@ type ModelDto struct {
 	Name             string                 `json:"name"`
 	Mode             string                 `json:"mode"`
 	CompletionParams map[string]interface{} `json:"completion_params"`
+	Fallbacks        []*FallbackModelDto    `json:"fallbacks,omitempty"`
+}
+
+// FallbackModelDto is a model invoked when the previous model of the chain is rate limited or unavailable.
+type FallbackModelDto struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
 }
 
 type UserInput struct {
@@ -138,13 +145,14 @@ type UserInput struct {
 }
 
 type AgentTools struct {
-	Enabled        bool           `json:"enabled"`
-	ProviderID     string         `json:"provider_id"`
-	ProviderName   string         `json:"provider_name"`
-	ProviderType   string         `json:"provider_type"`
-	ToolLabel      string         `json:"tool_label"`
-	ToolName       string         `json:"tool_name"`
-	ToolParameters map[string]any `json:"tool_parameters"`
+	Enabled          bool           `json:"enabled"`
+	ProviderID       string         `json:"provider_id"`
+	ProviderName     string         `json:"provider_name"`
+	ProviderType     string         `json:"provider_type"`
+	ToolLabel        string         `json:"tool_label"`
+	ToolName         string         `json:"tool_name"`
+	ToolParameters   map[string]any `json:"tool_parameters"`
+	RequiresApproval bool           `json:"requires_approval"`
 }
 
 type AgentMode struct {
@@ -228,6 +236,16 @@ func AppRecordToDetail(app *po_entity.App, config *config.Config, modelConfig *b
 		appDetail.ModelConfig.ExternalDataTools = []string{}
 	}
 
+	if appDetail.ModelConfig.StructuredOutput == nil {
+		appDetail.ModelConfig.StructuredOutput = &biz_entity.StructuredOutput{
+			Schema: map[string]any{},
+		}
+	}
+
+	if appDetail.ModelConfig.LLMCache == nil {
+		appDetail.ModelConfig.LLMCache = &biz_entity.LLMCache{}
+	}
+
 	if appDetail.ModelConfig.DatasetConfigs == nil {
 		appDetail.ModelConfig.DatasetConfigs = map[string]any{
 			"retrieval_model": "multiple",
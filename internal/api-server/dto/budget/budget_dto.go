@@ -0,0 +1,43 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package dto
+
+import (
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+)
+
+// --
+// --- Save budget
+// --
+type SaveBudgetBody struct {
+	AppID        string   `json:"app_id"` // empty for the workspace budget
+	Period       string   `json:"period"  validate:"required,oneof=daily monthly"`
+	Currency     string   `json:"currency"`
+	SoftLimit    float64  `json:"soft_limit"  validate:"min=0"`
+	HardLimit    float64  `json:"hard_limit"  validate:"min=0"`
+	NotifyEmails []string `json:"notify_emails"  validate:"omitempty,dive,email"`
+	Enabled      *bool    `json:"enabled"`
+}
+
+// --
+// --- Delete budget
+// --
+type BudgetUri struct {
+	BudgetID string `uri:"budgetID"  validate:"required"`
+}
+
+// --
+// --- List budgets
+// --
+type BudgetItem struct {
+	*po_entity.Budget
+	Spend       float64 `json:"spend"`
+	PeriodStart int64   `json:"period_start"`
+	PeriodEnd   int64   `json:"period_end"`
+}
+
+type ListBudgetsResponse struct {
+	Data []*BudgetItem `json:"data"`
+}
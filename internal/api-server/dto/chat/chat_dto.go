@@ -44,6 +44,17 @@ type DetailConversationUri struct {
 	ConversationID string `uri:"conversationID" validate:"required"`
 }
 
+// AgentApproval Dto
+type AgentApprovalUri struct {
+	AppID     string `uri:"appID" validate:"required"`
+	MessageID string `uri:"messageID" validate:"required"`
+}
+
+type AgentApprovalBody struct {
+	ToolCallID string `json:"tool_call_id" validate:"required"`
+	Action     string `json:"action" validate:"required,oneof=approve reject"`
+}
+
 type PromptMessage struct {
 	Role    string `json:"role"`
 	Content string `json:"text"`
@@ -198,6 +209,13 @@ type ModelDto struct {
 	Name             string                 `json:"name"`
 	Mode             string                 `json:"mode"`
 	CompletionParams map[string]interface{} `json:"completion_params"`
+	Fallbacks        []*FallbackModelDto    `json:"fallbacks,omitempty"`
+}
+
+// FallbackModelDto is a model invoked when the previous model of the chain is rate limited or unavailable.
+type FallbackModelDto struct {
+	Provider string `json:"provider"`
+	Name     string `json:"name"`
 }
 
 type UserInput struct {
@@ -212,13 +230,14 @@ type UserInput struct {
 type UserInputForm map[string]*UserInput
 
 type AgentTools struct {
-	Enabled        bool           `json:"enabled"`
-	ProviderID     string         `json:"provider_id"`
-	ProviderName   string         `json:"provider_name"`
-	ProviderType   string         `json:"provider_type"`
-	ToolLabel      string         `json:"tool_label"`
-	ToolName       string         `json:"tool_name"`
-	ToolParameters map[string]any `json:"tool_parameters"`
+	Enabled          bool           `json:"enabled"`
+	ProviderID       string         `json:"provider_id"`
+	ProviderName     string         `json:"provider_name"`
+	ProviderType     string         `json:"provider_type"`
+	ToolLabel        string         `json:"tool_label"`
+	ToolName         string         `json:"tool_name"`
+	ToolParameters   map[string]any `json:"tool_parameters"`
+	RequiresApproval bool           `json:"requires_approval"`
 }
 
 type AgentMode struct {
@@ -252,6 +271,26 @@ type AppModelConfigDto struct {
 	TextToSpeech                  AppModelConfigDtoEnable `json:"text_to_speech"`
 	ExternalDataTools             []string                `json:"external_data_tools" `
 	Configs                       map[string]interface{}  `json:"configs"`
+	StructuredOutput              *StructuredOutputDto    `json:"structured_output"`
+	LLMCache                      *LLMCacheDto            `json:"llm_cache"`
+}
+
+// StructuredOutputDto holds the json schema of an object which the answers of the app must conform to.
+type StructuredOutputDto struct {
+	Enabled bool                   `json:"enabled"`
+	Name    string                 `json:"name"`
+	Schema  map[string]interface{} `json:"schema"`
+}
+
+// LLMCacheDto configures the cache of the answers of the model, the semantic mode embeds the query by the embedding
+// model, which is the default text embedding model of the workspace when it is not specified.
+type LLMCacheDto struct {
+	Enabled             bool    `json:"enabled"`
+	Mode                string  `json:"mode"`
+	TTL                 int64   `json:"ttl"`
+	SimilarityThreshold float64 `json:"similarity_threshold"`
+	EmbeddingProvider   string  `json:"embedding_provider"`
+	EmbeddingModel      string  `json:"embedding_model"`
 }
 
 type CreateChatMessageBody struct {
@@ -304,6 +343,8 @@ type ServiceChatCompletionResponse struct {
 	Answer         string                                 `json:"answer"`
 	Metadata       *ServiceChatCompletionMetaDataResponse `json:"metadata"`
 	CreatedAt      int64                                  `json:"created_at"`
+	// StructuredOutput is the answer decoded and validated against the json schema of the structured output
+	StructuredOutput interface{} `json:"structured_output,omitempty"`
 }
 
 type InsertAnnotationFormMessage struct {
@@ -96,6 +96,24 @@ type CreateProviderCredentialBody struct {
 	Credentials map[string]interface{} `json:"credentials"  validate:"required"`
 }
 
+// --
+// --- Quota of the provider
+// --
+type ProviderQuotaUri struct {
+	Provider string `uri:"provider"  validate:"required"`
+}
+
+type ResetProviderQuotaBody struct {
+	QuotaType  string `json:"quota_type"  validate:"required,oneof=paid free trial"`
+	QuotaUnit  string `json:"quota_unit"  validate:"required,oneof=times tokens credits"`
+	QuotaLimit int64  `json:"quota_limit"  validate:"min=-1"` // -1 is unlimited
+}
+
+type ProviderQuotaResponse struct {
+	Enabled bool                                           `json:"enabled"`
+	Quota   *biz_entity_provider_config.QuotaConfiguration `json:"quota"`
+}
+
 // --
 // --- Create  model credentials
 // --
@@ -149,6 +167,55 @@ type DefaultModelByTypeQuery struct {
 	ModelType string `form:"model_type" validate:"required"`
 }
 
+// --- Load balancing configs of the model
+// --
+type LoadBalancingConfigUri struct {
+	Provider string `uri:"provider"  validate:"required"`
+	ConfigID string `uri:"configID"  validate:"required"`
+}
+
+type LoadBalancingConfigsQuery struct {
+	Model     string `form:"model"  validate:"required"`
+	ModelType string `form:"model_type"  validate:"required,valid_model_type"`
+}
+
+type CreateLoadBalancingConfigBody struct {
+	Model       string                 `json:"model"  validate:"required"`
+	ModelType   string                 `json:"model_type"  validate:"required,valid_model_type"`
+	Name        string                 `json:"name"  validate:"required"`
+	Credentials map[string]interface{} `json:"credentials"  validate:"required"`
+	Weight      int                    `json:"weight"  validate:"min=0"`
+	Enabled     *bool                  `json:"enabled"`
+}
+
+type UpdateLoadBalancingConfigBody struct {
+	Name        string                 `json:"name"  validate:"required"`
+	Credentials map[string]interface{} `json:"credentials"  validate:"required"`
+	Weight      int                    `json:"weight"  validate:"min=0"`
+	Enabled     *bool                  `json:"enabled"`
+}
+
+type UpdateModelLoadBalancingBody struct {
+	Model     string `json:"model"  validate:"required"`
+	ModelType string `json:"model_type"  validate:"required,valid_model_type"`
+	Enabled   bool   `json:"enabled"`
+	Strategy  string `json:"strategy"  validate:"omitempty,oneof=round_robin weighted"`
+}
+
+type LoadBalancingConfigItem struct {
+	ID          string                 `json:"id"`
+	Name        string                 `json:"name"`
+	Credentials map[string]interface{} `json:"credentials"`
+	Weight      int                    `json:"weight"`
+	Enabled     bool                   `json:"enabled"`
+}
+
+type LoadBalancingConfigsResponse struct {
+	Enabled  bool                       `json:"enabled"`
+	Strategy string                     `json:"strategy"`
+	Configs  []*LoadBalancingConfigItem `json:"configs"`
+}
+
 type DataWrapperResponse[T interface{}] struct {
 	Data T `json:"data"`
 }
@@ -59,7 +59,7 @@ func (a *AppRoutes) Register(g *gin.Engine) error {
 
 	// service
 	appService := service.NewAppService(appDomain, providerDomain, accountDomain, chatDomain, gormIns, config)
-	chatService := service.NewChatService(appDomain, providerDomain, accountDomain, chatDomain, datasetDomain, nil, redisIns, config)
+	chatService := service.NewChatService(appDomain, providerDomain, accountDomain, chatDomain, datasetDomain, nil, nil, redisIns, config)
 
 	appController := controller.NewAppController(appService, chatService)
 
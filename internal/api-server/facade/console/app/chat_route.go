@@ -12,12 +12,14 @@ import (
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	agentDomain "github.com/lunarianss/Luna/internal/api-server/domain/agent/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	controller "github.com/lunarianss/Luna/internal/api-server/interface/gin/v1/chat"
 	"github.com/lunarianss/Luna/internal/api-server/middleware"
 	repo_impl "github.com/lunarianss/Luna/internal/api-server/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/email"
 	"github.com/lunarianss/Luna/internal/infrastructure/mq"
 	"github.com/lunarianss/Luna/internal/infrastructure/mysql"
 	"github.com/lunarianss/Luna/internal/infrastructure/redis"
@@ -44,6 +46,12 @@ func (a *ChatRoutes) Register(g *gin.Engine) error {
 		return err
 	}
 
+	email, err := email.GetEmailSMTPIns(nil)
+
+	if err != nil {
+		return err
+	}
+
 	// config
 	config, err := config.GetLunaRuntimeConfig()
 
@@ -70,8 +78,9 @@ func (a *ChatRoutes) Register(g *gin.Engine) error {
 	chatDomain := chatDomain.NewChatDomain(messageRepo, annotationRepo)
 	datasetDomain := datasetDomain.NewDatasetDomain(datasetRepo)
 	agentDomain := agentDomain.NewAgentDomain(agentDomain.NewToolTransformService(config), tools.NewToolManager(), agentRepo, appRepo)
+	budgetDomain := budgetDomain.NewBudgetDomain(repo_impl.NewBudgetRepoImpl(gormIns), redisIns, email, config)
 	// service
-	chatService := service.NewChatService(appDomain, providerDomain, accountDomain, chatDomain, datasetDomain, agentDomain, redisIns, config)
+	chatService := service.NewChatService(appDomain, providerDomain, accountDomain, chatDomain, datasetDomain, agentDomain, budgetDomain, redisIns, config)
 	annotationService := service.NewAnnotationService(appDomain, providerDomain, accountDomain, chatDomain, redisIns, mqProducer, datasetDomain)
 	chatController := controller.NewChatController(chatService, annotationService)
 
@@ -82,6 +91,7 @@ func (a *ChatRoutes) Register(g *gin.Engine) error {
 	modelProviderV1.POST("/apps/:appID/audio-to-text", chatController.AudioToChatMessage)
 	modelProviderV1.POST("/apps/:appID/text-to-audio", chatController.TextToAudio)
 	modelProviderV1.POST("/apps/:appID/annotations", chatController.InsertAnnotationFormMessage)
+	modelProviderV1.POST("/apps/:appID/chat-messages/:messageID/agent-approval", chatController.AgentApproval)
 
 	modelProviderV1.GET("/apps/:appID/chat-messages", chatController.ChatMessageList)
 	modelProviderV1.GET("/apps/:appID/chat-conversations", chatController.ChatConversationList)
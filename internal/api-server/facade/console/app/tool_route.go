@@ -56,8 +56,22 @@ func (a *ToolRoutes) Register(g *gin.Engine) error {
 	toolV1.Use(middleware.TokenAuthMiddleware())
 	toolV1.GET("/tools/builtin", toolController.List)
 	toolV1.GET("/tools/api", toolController.ListAPI)
-	toolV1.GET("/tools/workflow", toolController.ListAPI)
+	toolV1.GET("/tools/workflow", toolController.ListWorkflow)
+	toolV1.GET("/tools/mcp", toolController.ListMcp)
 	toolV1.GET("/tool-labels", toolController.ListLabels)
+	toolV1.POST("/tool-provider/api/add", toolController.AddApiProvider)
+	toolV1.GET("/tool-provider/api/get", toolController.GetApiProvider)
+	toolV1.POST("/tool-provider/api/update", toolController.UpdateApiProvider)
+	toolV1.POST("/tool-provider/api/delete", toolController.DeleteApiProvider)
+	toolV1.GET("/tool-provider/api/tools", toolController.ListApiProviderTools)
+	toolV1.POST("/tool-provider/api/schema", toolController.ParseApiSchema)
+	toolV1.GET("/tool-provider/api/remote", toolController.GetRemoteApiSchema)
+	toolV1.POST("/tool-provider/mcp/add", toolController.AddMcpProvider)
+	toolV1.GET("/tool-provider/mcp/get", toolController.GetMcpProvider)
+	toolV1.POST("/tool-provider/mcp/update", toolController.UpdateMcpProvider)
+	toolV1.POST("/tool-provider/mcp/refresh", toolController.RefreshMcpProvider)
+	toolV1.POST("/tool-provider/mcp/delete", toolController.DeleteMcpProvider)
+	toolV1.GET("/tool-provider/mcp/tools", toolController.ListMcpProviderTools)
 	unAuthV1.GET("/tool-provider/builtin/:provider/icon", toolController.GetIcon)
 	return nil
 }
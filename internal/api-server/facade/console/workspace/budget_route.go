@@ -0,0 +1,81 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package route
+
+import (
+	"github.com/gin-gonic/gin"
+
+	service "github.com/lunarianss/Luna/internal/api-server/application"
+	"github.com/lunarianss/Luna/internal/api-server/config"
+	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
+	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
+	controller "github.com/lunarianss/Luna/internal/api-server/interface/gin/v1/budget"
+	"github.com/lunarianss/Luna/internal/api-server/middleware"
+	repo_impl "github.com/lunarianss/Luna/internal/api-server/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/email"
+	"github.com/lunarianss/Luna/internal/infrastructure/mysql"
+	"github.com/lunarianss/Luna/internal/infrastructure/redis"
+)
+
+type BudgetRoutes struct{}
+
+func (r *BudgetRoutes) Register(g *gin.Engine) error {
+	gormIns, err := mysql.GetMySQLIns(nil)
+
+	if err != nil {
+		return err
+	}
+
+	redisIns, err := redis.GetRedisIns(nil)
+
+	if err != nil {
+		return err
+	}
+
+	email, err := email.GetEmailSMTPIns(nil)
+
+	if err != nil {
+		return err
+	}
+
+	// config
+	config, err := config.GetLunaRuntimeConfig()
+
+	if err != nil {
+		return err
+	}
+
+	// repos
+	accountRepo := repo_impl.NewAccountRepoImpl(gormIns)
+	tenantRepo := repo_impl.NewTenantRepoImpl(gormIns)
+	appRepo := repo_impl.NewAppRepoImpl(gormIns)
+	webAppRepo := repo_impl.NewWebAppRepoImpl(gormIns)
+	budgetRepo := repo_impl.NewBudgetRepoImpl(gormIns)
+
+	// domain
+	accountDomain := accountDomain.NewAccountDomain(accountRepo, nil, nil, nil, tenantRepo)
+	appDomain := appDomain.NewAppDomain(appRepo, webAppRepo, gormIns)
+	budgetDomain := budgetDomain.NewBudgetDomain(budgetRepo, redisIns, email, config)
+
+	// service
+	budgetService := service.NewBudgetService(budgetDomain, accountDomain, appDomain)
+
+	budgetController := controller.NewBudgetController(budgetService)
+
+	v1 := g.Group("/v1")
+	authV1 := v1.Group("/console/api/workspaces/current")
+	authV1.Use(middleware.TokenAuthMiddleware())
+
+	authV1.GET("/budgets", budgetController.List)
+	authV1.POST("/budgets", budgetController.Save)
+	authV1.DELETE("/budgets/:budgetID", budgetController.Delete)
+
+	return nil
+}
+
+func (r *BudgetRoutes) GetModule() string {
+	return "budgets"
+}
@@ -58,6 +58,8 @@ func (r *ModelProviderRoutes) Register(g *gin.Engine) error {
 	modelProviderAuthV1.GET("/model-providers", modelProviderController.List)
 	modelProviderNoAuthV1.GET("/model-providers/:provider/:iconType/:lang", modelProviderController.ListIcons)
 	modelProviderAuthV1.POST("/model-providers/:provider", modelProviderController.SaveProviderCredential)
+	modelProviderAuthV1.GET("/model-providers/:provider/quota", modelProviderController.GetProviderQuota)
+	modelProviderAuthV1.POST("/model-providers/:provider/quota/reset", modelProviderController.ResetProviderQuota)
 
 	return nil
 }
@@ -55,6 +55,11 @@ func (r *ModelRoutes) Register(g *gin.Engine) error {
 	modelProviderV1.POST("/model-providers/:provider/models", modelController.SaveModelCredential)
 	modelProviderV1.GET("/model-providers/:provider/models", modelController.GetProviderModels)
 	modelProviderV1.GET("/model-providers/:provider/models/parameter-rules", modelController.ParameterRules)
+	modelProviderV1.PATCH("/model-providers/:provider/models/load-balancing", modelController.UpdateModelLoadBalancing)
+	modelProviderV1.GET("/model-providers/:provider/models/load-balancing-configs", modelController.GetLoadBalancingConfigs)
+	modelProviderV1.POST("/model-providers/:provider/models/load-balancing-configs", modelController.CreateLoadBalancingConfig)
+	modelProviderV1.PATCH("/model-providers/:provider/models/load-balancing-configs/:configID", modelController.UpdateLoadBalancingConfig)
+	modelProviderV1.DELETE("/model-providers/:provider/models/load-balancing-configs/:configID", modelController.DeleteLoadBalancingConfig)
 	modelProviderV1.GET("/models/model-types/:modelType", modelController.GetAccountAvailableModels)
 
 	modelProviderV1.GET("/default-model", modelController.GetDefaultModelByType)
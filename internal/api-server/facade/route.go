@@ -27,6 +27,7 @@ func init() {
 	server.RegisterRoute(&consoleWorkSpaceRoute.AccountRoute{})
 	server.RegisterRoute(&consoleWorkSpaceRoute.WorkspaceRoutes{})
 	server.RegisterRoute(&consoleWorkSpaceRoute.TagRoutes{})
+	server.RegisterRoute(&consoleWorkSpaceRoute.BudgetRoutes{})
 
 	// console/app
 	server.RegisterRoute(&consoleAppRoute.ChatRoutes{})
@@ -10,6 +10,7 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/config"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
 	webAppDomain "github.com/lunarianss/Luna/internal/api-server/domain/web_app/domain_service"
@@ -69,7 +70,8 @@ func (a *ServiceChatRoutes) Register(g *gin.Engine) error {
 
 	// domain
 	providerDomain := domain_service.NewProviderDomain(providerRepo, modelProviderRepo, tenantRepo, providerConfigurationsManager)
-	serviceChatService := service.NewServiceChatService(webAppDomain, accountDomain, appDomain, config, providerDomain, chatDomain)
+	budgetDomain := budgetDomain.NewBudgetDomain(repo_impl.NewBudgetRepoImpl(gormIns), redisIns, email, config)
+	serviceChatService := service.NewServiceChatService(webAppDomain, accountDomain, appDomain, config, providerDomain, chatDomain, budgetDomain)
 
 	serviceChatController := controller.NewServiceChatController(serviceChatService)
 	v1 := g.Group("/v1")
@@ -10,6 +10,7 @@ import (
 	"github.com/lunarianss/Luna/internal/api-server/config"
 	accountDomain "github.com/lunarianss/Luna/internal/api-server/domain/account/domain_service"
 	appDomain "github.com/lunarianss/Luna/internal/api-server/domain/app/domain_service"
+	budgetDomain "github.com/lunarianss/Luna/internal/api-server/domain/budget/domain_service"
 	chatDomain "github.com/lunarianss/Luna/internal/api-server/domain/chat/domain_service"
 	datasetDomain "github.com/lunarianss/Luna/internal/api-server/domain/dataset/domain_service"
 	"github.com/lunarianss/Luna/internal/api-server/domain/provider/domain_service"
@@ -71,7 +72,8 @@ func (a *WebChatRoutes) Register(g *gin.Engine) error {
 	// domain
 	providerDomain := domain_service.NewProviderDomain(providerRepo, modelProviderRepo, tenantRepo, providerConfigurationsManager)
 	datasetDomain := datasetDomain.NewDatasetDomain(datasetRepo)
-	webChatService := service.NewWebChatService(webAppDomain, accountDomain, appDomain, config, providerDomain, chatDomain, datasetDomain, redisIns)
+	budgetDomain := budgetDomain.NewBudgetDomain(repo_impl.NewBudgetRepoImpl(gormIns), redisIns, email, config)
+	webChatService := service.NewWebChatService(webAppDomain, accountDomain, appDomain, config, providerDomain, chatDomain, datasetDomain, budgetDomain, redisIns)
 
 	webSiteController := controller.NewWebChatController(webChatService)
 	v1 := g.Group("/v1")
@@ -0,0 +1,78 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/budget"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+func (bc *BudgetController) List(c *gin.Context) {
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	budgets, err := bc.budgetService.ListBudgets(c, userID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, budgets)
+}
+
+func (bc *BudgetController) Save(c *gin.Context) {
+	params := &dto.SaveBudgetBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	budget, err := bc.budgetService.SaveBudget(c, userID, params)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, budget)
+}
+
+func (bc *BudgetController) Delete(c *gin.Context) {
+	params := &dto.BudgetUri{}
+
+	if err := c.ShouldBindUri(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := bc.budgetService.DeleteBudget(c, userID, params.BudgetID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
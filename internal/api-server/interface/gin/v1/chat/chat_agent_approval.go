@@ -0,0 +1,42 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	biz_entity_app_generate "github.com/lunarianss/Luna/internal/api-server/domain/provider/entity/biz_entity/provider_app_generate"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/chat"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+// AgentApproval approves or rejects the tool call of the agent waiting for approval, the resumed agent is streamed
+// as the chat message is.
+func (ac *ChatController) AgentApproval(c *gin.Context) {
+
+	params := &dto.AgentApprovalBody{}
+	paramsUrl := &dto.AgentApprovalUri{}
+
+	if err := c.ShouldBindUri(paramsUrl); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := ac.chatService.ResumeAgentChat(c, paramsUrl.AppID, userID, paramsUrl.MessageID, params, biz_entity_app_generate.Debugger); err != nil {
+		core.WriteResponse(c, err, nil)
+	}
+}
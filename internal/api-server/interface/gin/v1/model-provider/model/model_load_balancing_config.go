@@ -0,0 +1,153 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+func (mc *ModelController) GetLoadBalancingConfigs(c *gin.Context) {
+	paramsUri := &dto.CreateModelCredentialUri{}
+	paramsQuery := &dto.LoadBalancingConfigsQuery{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBind(paramsQuery); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	loadBalancingConfigs, err := mc.modelProviderService.GetLoadBalancingConfigs(c, userID, paramsUri.Provider, paramsQuery.Model, paramsQuery.ModelType)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, loadBalancingConfigs)
+}
+
+func (mc *ModelController) CreateLoadBalancingConfig(c *gin.Context) {
+	paramsUri := &dto.CreateModelCredentialUri{}
+	paramsBody := &dto.CreateLoadBalancingConfigBody{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(paramsBody); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := mc.modelProviderService.CreateLoadBalancingConfig(c, userID, paramsUri.Provider, paramsBody); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
+
+func (mc *ModelController) UpdateLoadBalancingConfig(c *gin.Context) {
+	paramsUri := &dto.LoadBalancingConfigUri{}
+	paramsBody := &dto.UpdateLoadBalancingConfigBody{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(paramsBody); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := mc.modelProviderService.UpdateLoadBalancingConfig(c, userID, paramsUri.Provider, paramsUri.ConfigID, paramsBody); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
+
+func (mc *ModelController) DeleteLoadBalancingConfig(c *gin.Context) {
+	paramsUri := &dto.LoadBalancingConfigUri{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := mc.modelProviderService.DeleteLoadBalancingConfig(c, userID, paramsUri.Provider, paramsUri.ConfigID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
+
+func (mc *ModelController) UpdateModelLoadBalancing(c *gin.Context) {
+	paramsUri := &dto.CreateModelCredentialUri{}
+	paramsBody := &dto.UpdateModelLoadBalancingBody{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(paramsBody); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := mc.modelProviderService.UpdateModelLoadBalancing(c, userID, paramsUri.Provider, paramsBody); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
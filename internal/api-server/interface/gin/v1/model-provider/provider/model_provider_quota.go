@@ -0,0 +1,66 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/provider"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+func (mc *ModelProviderController) GetProviderQuota(c *gin.Context) {
+	paramsUri := &dto.ProviderQuotaUri{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	quota, err := mc.modelProviderService.GetProviderQuota(c, userID, paramsUri.Provider)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, quota)
+}
+
+func (mc *ModelProviderController) ResetProviderQuota(c *gin.Context) {
+	paramsUri := &dto.ProviderQuotaUri{}
+	paramsBody := &dto.ResetProviderQuotaBody{}
+
+	if err := c.ShouldBindUri(paramsUri); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	if err := c.ShouldBindJSON(paramsBody); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := mc.modelProviderService.ResetProviderQuota(c, userID, paramsUri.Provider, paramsBody); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
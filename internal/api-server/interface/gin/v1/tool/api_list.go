@@ -7,8 +7,28 @@ package controller
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
 )
 
 func (tc *ToolController) ListAPI(c *gin.Context) {
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	providers, err := tc.toolService.GetApiToolProviders(c, userID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, providers)
+}
+
+// ListWorkflow lists the workflow tool providers, which are not supported yet.
+func (tc *ToolController) ListWorkflow(c *gin.Context) {
 	core.WriteResponse(c, nil, make([]any, 0))
 }
@@ -0,0 +1,171 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/agent"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+func (tc *ToolController) AddApiProvider(c *gin.Context) {
+	params := &dto.AddApiToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.AddApiToolProvider(c, userID, params)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) UpdateApiProvider(c *gin.Context) {
+	params := &dto.UpdateApiToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.UpdateApiToolProvider(c, userID, params)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) DeleteApiProvider(c *gin.Context) {
+	params := &dto.DeleteApiToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := tc.toolService.DeleteApiToolProvider(c, userID, params.Provider); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
+
+func (tc *ToolController) GetApiProvider(c *gin.Context) {
+	params := &dto.ApiToolProviderQuery{}
+
+	if err := c.ShouldBind(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.GetApiToolProvider(c, userID, params.Provider)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) ListApiProviderTools(c *gin.Context) {
+	params := &dto.ApiToolProviderQuery{}
+
+	if err := c.ShouldBind(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	tools, err := tc.toolService.GetApiProviderTools(c, userID, params.Provider)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, tools)
+}
+
+func (tc *ToolController) ParseApiSchema(c *gin.Context) {
+	params := &dto.ParseApiSchemaBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	result, err := tc.toolService.ParseApiSchema(c, params.Schema)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, result)
+}
+
+func (tc *ToolController) GetRemoteApiSchema(c *gin.Context) {
+	params := &dto.RemoteApiSchemaQuery{}
+
+	if err := c.ShouldBind(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	schema, err := tc.toolService.GetRemoteApiSchema(c, params.URL)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, map[string]string{"schema": schema})
+}
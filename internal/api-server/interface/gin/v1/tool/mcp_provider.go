@@ -0,0 +1,178 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	dto "github.com/lunarianss/Luna/internal/api-server/dto/agent"
+	"github.com/lunarianss/Luna/internal/infrastructure/core"
+	"github.com/lunarianss/Luna/internal/infrastructure/util"
+)
+
+func (tc *ToolController) ListMcp(c *gin.Context) {
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	providers, err := tc.toolService.GetMcpToolProviders(c, userID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, providers)
+}
+
+func (tc *ToolController) AddMcpProvider(c *gin.Context) {
+	params := &dto.AddMcpToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.AddMcpToolProvider(c, userID, params)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) UpdateMcpProvider(c *gin.Context) {
+	params := &dto.UpdateMcpToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.UpdateMcpToolProvider(c, userID, params)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) RefreshMcpProvider(c *gin.Context) {
+	params := &dto.McpToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.RefreshMcpToolProvider(c, userID, params.ProviderID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) DeleteMcpProvider(c *gin.Context) {
+	params := &dto.McpToolProviderBody{}
+
+	if err := c.ShouldBindJSON(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	if err := tc.toolService.DeleteMcpToolProvider(c, userID, params.ProviderID); err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, core.GetSuccessResponse())
+}
+
+func (tc *ToolController) GetMcpProvider(c *gin.Context) {
+	params := &dto.McpToolProviderQuery{}
+
+	if err := c.ShouldBind(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	provider, err := tc.toolService.GetMcpToolProvider(c, userID, params.ProviderID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, provider)
+}
+
+func (tc *ToolController) ListMcpProviderTools(c *gin.Context) {
+	params := &dto.McpToolProviderQuery{}
+
+	if err := c.ShouldBind(params); err != nil {
+		core.WriteBindErrResponse(c, err)
+		return
+	}
+
+	userID, err := util.GetUserIDFromGin(c)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	tools, err := tc.toolService.GetMcpProviderTools(c, userID, params.ProviderID)
+
+	if err != nil {
+		core.WriteResponse(c, err, nil)
+		return
+	}
+
+	core.WriteResponse(c, nil, tools)
+}
@@ -99,3 +99,111 @@ func (ar *AgentRepoImpl) UpdateAgentThought(ctx context.Context, agentThought *p
 	}
 	return nil
 }
+
+func (ar *AgentRepoImpl) CreateApiToolProvider(ctx context.Context, provider *po_entity.ToolApiProvider) error {
+	if err := ar.db.Create(provider).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) UpdateApiToolProvider(ctx context.Context, provider *po_entity.ToolApiProvider) error {
+	if err := ar.db.Model(provider).Where("id = ?", provider.ID).Select("name", "icon", "description", "schema_type", "schema", "tools_str", "credentials", "updated_at").Updates(provider).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) DeleteApiToolProvider(ctx context.Context, tenantID, name string) error {
+	if err := ar.db.Where("tenant_id = ? and name = ?", tenantID, name).Delete(&po_entity.ToolApiProvider{}).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) GetApiToolProviderByName(ctx context.Context, tenantID, name string) (*po_entity.ToolApiProvider, error) {
+	var provider *po_entity.ToolApiProvider
+
+	if err := ar.db.Where("tenant_id = ? and name = ?", tenantID, name).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return provider, nil
+}
+
+func (ar *AgentRepoImpl) GetApiToolProviderByID(ctx context.Context, tenantID, providerID string) (*po_entity.ToolApiProvider, error) {
+	var provider *po_entity.ToolApiProvider
+
+	if err := ar.db.Where("tenant_id = ? and id = ?", tenantID, providerID).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return provider, nil
+}
+
+func (ar *AgentRepoImpl) GetApiToolProvidersByTenant(ctx context.Context, tenantID string) ([]*po_entity.ToolApiProvider, error) {
+	var providers []*po_entity.ToolApiProvider
+
+	if err := ar.db.Where("tenant_id = ?", tenantID).Order("created_at asc").Find(&providers).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return providers, nil
+}
+
+func (ar *AgentRepoImpl) CreateMcpToolProvider(ctx context.Context, provider *po_entity.ToolMcpProvider) error {
+	if err := ar.db.Create(provider).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) UpdateMcpToolProvider(ctx context.Context, provider *po_entity.ToolMcpProvider) error {
+	if err := ar.db.Model(provider).Where("id = ?", provider.ID).Select("name", "icon", "description", "transport", "command", "args", "env", "url", "headers", "tools_str", "updated_at").Updates(provider).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) DeleteMcpToolProvider(ctx context.Context, tenantID, providerID string) error {
+	if err := ar.db.Where("tenant_id = ? and id = ?", tenantID, providerID).Delete(&po_entity.ToolMcpProvider{}).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (ar *AgentRepoImpl) GetMcpToolProviderByName(ctx context.Context, tenantID, name string) (*po_entity.ToolMcpProvider, error) {
+	var provider *po_entity.ToolMcpProvider
+
+	if err := ar.db.Where("tenant_id = ? and name = ?", tenantID, name).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return provider, nil
+}
+
+func (ar *AgentRepoImpl) GetMcpToolProviderByID(ctx context.Context, tenantID, providerID string) (*po_entity.ToolMcpProvider, error) {
+	var provider *po_entity.ToolMcpProvider
+
+	if err := ar.db.Where("tenant_id = ? and id = ?", tenantID, providerID).First(&provider).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return provider, nil
+}
+
+func (ar *AgentRepoImpl) GetMcpToolProvidersByTenant(ctx context.Context, tenantID string) ([]*po_entity.ToolMcpProvider, error) {
+	var providers []*po_entity.ToolMcpProvider
+
+	if err := ar.db.Where("tenant_id = ?", tenantID).Order("created_at asc").Find(&providers).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return providers, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2024 Benjamin Lee <cyan0908@163.com>. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package repo_impl
+
+import (
+	"context"
+
+	"github.com/lunarianss/Luna/infrastructure/errors"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/entity/po_entity"
+	"github.com/lunarianss/Luna/internal/api-server/domain/budget/repository"
+	"github.com/lunarianss/Luna/internal/infrastructure/code"
+	"gorm.io/gorm"
+)
+
+type BudgetRepoImpl struct {
+	db *gorm.DB
+}
+
+var _ repository.BudgetRepo = (*BudgetRepoImpl)(nil)
+
+func NewBudgetRepoImpl(db *gorm.DB) *BudgetRepoImpl {
+	return &BudgetRepoImpl{db: db}
+}
+
+func (br *BudgetRepoImpl) CreateBudget(ctx context.Context, budget *po_entity.Budget) error {
+	if err := br.db.Create(budget).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (br *BudgetRepoImpl) UpdateBudget(ctx context.Context, budget *po_entity.Budget) error {
+	if err := br.db.Model(budget).Where("id = ?", budget.ID).Select("period", "currency", "soft_limit", "hard_limit", "notify_emails", "enabled", "updated_at").Updates(budget).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (br *BudgetRepoImpl) DeleteBudget(ctx context.Context, tenantID, budgetID string) error {
+	if err := br.db.Where("tenant_id = ? and id = ?", tenantID, budgetID).Delete(&po_entity.Budget{}).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (br *BudgetRepoImpl) GetBudgetByID(ctx context.Context, tenantID, budgetID string) (*po_entity.Budget, error) {
+	var budget *po_entity.Budget
+
+	if err := br.db.Where("tenant_id = ? and id = ?", tenantID, budgetID).First(&budget).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return budget, nil
+}
+
+func (br *BudgetRepoImpl) GetBudgetByScope(ctx context.Context, tenantID, appID string) (*po_entity.Budget, error) {
+	var budget *po_entity.Budget
+
+	if err := br.db.Where("tenant_id = ? and app_id = ?", tenantID, appID).First(&budget).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return budget, nil
+}
+
+func (br *BudgetRepoImpl) ListTenantBudgets(ctx context.Context, tenantID string) ([]*po_entity.Budget, error) {
+	var budgets []*po_entity.Budget
+
+	if err := br.db.Where("tenant_id = ?", tenantID).Order("created_at asc").Find(&budgets).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return budgets, nil
+}
+
+func (br *BudgetRepoImpl) GetEnabledBudgetsOfApp(ctx context.Context, tenantID, appID string) ([]*po_entity.Budget, error) {
+	var budgets []*po_entity.Budget
+
+	if err := br.db.Where("tenant_id = ? and (app_id = '' or app_id = ?) and enabled = 1", tenantID, appID).Find(&budgets).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return budgets, nil
+}
+
+func (br *BudgetRepoImpl) SumSpend(ctx context.Context, budget *po_entity.Budget, start int64) (float64, error) {
+	var spend float64
+
+	query := br.db.Table("messages").Select("COALESCE(SUM(total_price), 0)").Where("created_at >= ? and currency = ?", start, budget.Currency)
+
+	if budget.IsTenantBudget() {
+		query = query.Where("app_id IN (?)", br.db.Table("apps").Select("id").Where("tenant_id = ?", budget.TenantID))
+	} else {
+		query = query.Where("app_id = ?", budget.AppID)
+	}
+
+	if err := query.Scan(&spend).Error; err != nil {
+		return 0, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return spend, nil
+}
@@ -78,3 +78,91 @@ func (md *ModelProviderRepoImpl) UpdateTenantDefaultModel(ctx context.Context, t
 
 	return nil
 }
+
+func (md *ModelProviderRepoImpl) GetTenantModelSettings(ctx context.Context, tenantId string) ([]*po_entity.ProviderModelSetting, error) {
+	var modelSettings []*po_entity.ProviderModelSetting
+
+	if err := md.db.Where("tenant_id = ?", tenantId).Find(&modelSettings).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return modelSettings, nil
+}
+
+func (md *ModelProviderRepoImpl) GetTenantModelSetting(ctx context.Context, tenantId, providerName, modelName, modelType string) (*po_entity.ProviderModelSetting, error) {
+	var modelSetting *po_entity.ProviderModelSetting
+
+	if err := md.db.Scopes(mysql.IDDesc()).Where("tenant_id = ? and provider_name = ? and model_name = ? and model_type = ?", tenantId, providerName, modelName, modelType).First(&modelSetting).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		} else {
+			return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+		}
+	}
+	return modelSetting, nil
+}
+
+func (md *ModelProviderRepoImpl) CreateModelSetting(ctx context.Context, modelSetting *po_entity.ProviderModelSetting) error {
+	if err := md.db.Create(modelSetting).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (md *ModelProviderRepoImpl) UpdateModelSetting(ctx context.Context, modelSetting *po_entity.ProviderModelSetting) error {
+	if err := md.db.Model(modelSetting).Where("id = ?", modelSetting.ID).Select("enabled", "load_balancing_enabled", "load_balancing_strategy", "updated_at").Updates(modelSetting).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (md *ModelProviderRepoImpl) GetTenantLoadBalancingConfigs(ctx context.Context, tenantId string) ([]*po_entity.LoadBalancingModelConfig, error) {
+	var configs []*po_entity.LoadBalancingModelConfig
+
+	if err := md.db.Where("tenant_id = ?", tenantId).Order("created_at asc").Find(&configs).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return configs, nil
+}
+
+func (md *ModelProviderRepoImpl) GetModelLoadBalancingConfigs(ctx context.Context, tenantId, providerName, modelName, modelType string) ([]*po_entity.LoadBalancingModelConfig, error) {
+	var configs []*po_entity.LoadBalancingModelConfig
+
+	if err := md.db.Where("tenant_id = ? and provider_name = ? and model_name = ? and model_type = ?", tenantId, providerName, modelName, modelType).Order("created_at asc").Find(&configs).Error; err != nil {
+		return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return configs, nil
+}
+
+func (md *ModelProviderRepoImpl) GetLoadBalancingConfigByID(ctx context.Context, tenantId, configID string) (*po_entity.LoadBalancingModelConfig, error) {
+	var config *po_entity.LoadBalancingModelConfig
+
+	if err := md.db.Where("tenant_id = ? and id = ?", tenantId, configID).First(&config).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		} else {
+			return nil, errors.WithSCode(code.ErrDatabase, err.Error())
+		}
+	}
+	return config, nil
+}
+
+func (md *ModelProviderRepoImpl) CreateLoadBalancingConfig(ctx context.Context, config *po_entity.LoadBalancingModelConfig) error {
+	if err := md.db.Create(config).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (md *ModelProviderRepoImpl) UpdateLoadBalancingConfig(ctx context.Context, config *po_entity.LoadBalancingModelConfig) error {
+	if err := md.db.Model(config).Where("id = ?", config.ID).Select("name", "encrypted_config", "weight", "enabled", "updated_at").Updates(config).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
+
+func (md *ModelProviderRepoImpl) DeleteLoadBalancingConfig(ctx context.Context, tenantId, configID string) error {
+	if err := md.db.Where("tenant_id = ? and id = ?", tenantId, configID).Delete(&po_entity.LoadBalancingModelConfig{}).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+	return nil
+}
@@ -6,7 +6,7 @@ package repo_impl
 
 import (
 	"context"
-	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 
@@ -91,13 +91,7 @@ func (mpd *ProviderRepoImpl) GetMapSystemProviders(ctx context.Context) (map[str
 }
 
 func (mpd *ProviderRepoImpl) GetProviderPath(ctx context.Context, provider string) (string, error) {
-	providerPath, err := model_providers.Factory.ResolveProviderDirPath()
-
-	if err != nil {
-		return "", err
-	}
-
-	return fmt.Sprintf("%s/%s", providerPath, provider), nil
+	return model_providers.Factory.GetProviderPath(provider)
 }
 
 func (mpd *ProviderRepoImpl) GetProviderEntity(ctx context.Context, provider string) (*biz_entity.ProviderStaticConfiguration, error) {
@@ -134,3 +128,22 @@ func (mpd *ProviderRepoImpl) CreateProvider(ctx context.Context, provider *po_en
 func (mpd *ProviderRepoImpl) GetProviderInstance(ctx context.Context, provider string) (*biz_entity.ProviderRuntime, error) {
 	return model_providers.Factory.GetProviderInstance(provider)
 }
+
+func (mpd *ProviderRepoImpl) UpdateProviderQuota(ctx context.Context, provider *po_entity.Provider) error {
+	if err := mpd.db.Model(provider).Select("quota_type", "quota_unit", "quota_limit", "quota_used").Updates(provider).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
+
+func (mpd *ProviderRepoImpl) IncreaseProviderQuotaUsed(ctx context.Context, tenantID string, providerName string, quotaType string, used int64) error {
+	if err := mpd.db.Model(&po_entity.Provider{}).Where("tenant_id = ? and provider_name = ? and quota_type = ? and quota_limit is not null", tenantID, providerName, quotaType).Updates(map[string]interface{}{
+		"quota_used": gorm.Expr("IFNULL(quota_used, 0) + ?", used),
+		"last_used":  time.Now().UTC().Unix(),
+	}).Error; err != nil {
+		return errors.WithSCode(code.ErrDatabase, err.Error())
+	}
+
+	return nil
+}
@@ -8,8 +8,13 @@ import (
 	"context"
 
 	"github.com/lunarianss/Luna/internal/api-server/config"
+	"github.com/lunarianss/Luna/internal/api-server/core/app/app_runner/app_agent_chat_runner"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_plugin"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_registry"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/api"
+	"github.com/lunarianss/Luna/internal/api-server/core/tools/provider/mcp"
 
-	_ "github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers"
+	"github.com/lunarianss/Luna/internal/api-server/core/model_runtime/model_providers"
 	_ "github.com/lunarianss/Luna/internal/api-server/core/tools/provider"
 	_ "github.com/lunarianss/Luna/internal/api-server/event"
 	_ "github.com/lunarianss/Luna/internal/api-server/facade"
@@ -97,6 +102,44 @@ func (s *LunaApiServer) Run() error {
 
 	_ = jwt.NewJWT(s.AppRuntimeConfig.JwtOptions.Key)
 
+	modelPlugins, err := model_plugin.LoadModelPlugins(context.Background(), s.AppRuntimeConfig.GRPCOptions)
+
+	if err != nil {
+		return err
+	}
+
+	s.GracefulShutdown.AddShutdownCallback(shutdown.ShutdownFunc(func(s string) error {
+		return model_plugin.CloseModelPlugins(modelPlugins)
+	}))
+
+	// the vocabularies are loaded on start so that a missing one is warned before the first chat
+	model_registry.LoadTokenizers()
+
+	mcp.AllowStdioCommands(s.AppRuntimeConfig.SystemOptions.McpStdioCommands)
+	api.AllowPrivateNetwork(s.AppRuntimeConfig.SystemOptions.ApiToolAllowPrivateNetwork)
+	app_agent_chat_runner.SetToolCallLimits(s.AppRuntimeConfig.SystemOptions.AgentMaxParallelToolCalls, s.AppRuntimeConfig.SystemOptions.AgentToolCallTimeout)
+
+	// the stdio mcp servers are subprocesses which must not outlive the server
+	s.GracefulShutdown.AddShutdownCallback(shutdown.ShutdownFunc(func(s string) error {
+		return mcp.Connections.CloseAll()
+	}))
+
+	if definitionsDir := s.AppRuntimeConfig.SystemOptions.ProviderDefinitionsDir; definitionsDir != "" {
+		if err := model_providers.Factory.LoadDefinitions(definitionsDir); err != nil {
+			return err
+		}
+
+		definitionsWatcher, err := model_providers.Factory.WatchDefinitions(definitionsDir)
+
+		if err != nil {
+			return err
+		}
+
+		s.GracefulShutdown.AddShutdownCallback(shutdown.ShutdownFunc(func(s string) error {
+			return definitionsWatcher.Close()
+		}))
+	}
+
 	if err := s.APIServer.InitRouter(s.APIServer.Engine); err != nil {
 		return err
 	}
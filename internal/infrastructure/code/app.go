@@ -25,4 +25,6 @@ const (
 	ErrVDBQueryError
 	// ErrVDBConstructError - 400: Occurred error when construct vdb response.
 	ErrVDBConstructError
+	// ErrModerationConfig - 400: Sensitive word avoidance config is invalid.
+	ErrModerationConfig
 )
@@ -51,4 +51,24 @@ const (
 	ErrToolParameter
 	// ErrInvokeToolUnConvertAble - 500: Failed to convert to tool message.
 	ErrInvokeToolUnConvertAble
+	// ErrBudgetExceed - 403: Spend budget of the app or workspace has been exhausted, please raise the budget or wait for the next period.
+	ErrBudgetExceed
+	// ErrStructuredOutputSchema - 400: The json schema of the structured output is invalid.
+	ErrStructuredOutputSchema
+	// ErrStructuredOutputInvalid - 500: The answer doesn't conform to the json schema of the structured output after repairing.
+	ErrStructuredOutputInvalid
+	// ErrLLMCacheConfig - 400: The llm response cache config of the app is invalid.
+	ErrLLMCacheConfig
+	// ErrApiToolSchema - 400: The OpenAPI or Swagger schema of the api tool provider is invalid.
+	ErrApiToolSchema
+	// ErrApiToolProviderExist - 400: The api tool provider with the same name already exists.
+	ErrApiToolProviderExist
+	// ErrMcpConnection - 500: Failed to connect to the mcp server of the tool provider.
+	ErrMcpConnection
+	// ErrMcpToolProviderExist - 400: The mcp tool provider with the same name already exists.
+	ErrMcpToolProviderExist
+	// ErrAgentApprovalNotPending - 400: The tool call of the agent is not waiting for approval.
+	ErrAgentApprovalNotPending
+	// ErrAgentApprovalState - 500: Failed to restore the agent waiting for approval.
+	ErrAgentApprovalState
 )
@@ -18,6 +18,7 @@ func init() {
 	errors.Enroll(ErrNotFoundJobID, 400, "Not found job ID")
 	errors.Enroll(ErrVDBQueryError, 400, "Occurred error when vector similarity search")
 	errors.Enroll(ErrVDBConstructError, 400, "Occurred error when construct vdb response")
+	errors.Enroll(ErrModerationConfig, 400, "Sensitive word avoidance config is invalid")
 	errors.Enroll(ErrEmailCode, 500, "Error occurred when email code is incorrect")
 	errors.Enroll(ErrTokenEmail, 500, "Error occurred when email is incorrect")
 	errors.Enroll(ErrTenantAlreadyExist, 500, "Error occurred when tenant is already exist")
@@ -40,6 +41,7 @@ func init() {
 	errors.Enroll(ErrScanToField, 400, "Database scan error to field")
 	errors.Enroll(ErrVDB, 400, "Vector Database error")
 	errors.Enroll(ErrRedis, 400, "Redis error")
+	errors.Enroll(ErrMinio, 400, "storage error")
 	errors.Enroll(ErrEncrypt, 401, "Error occurred while encrypting the user password")
 	errors.Enroll(ErrSignatureInvalid, 401, "Signature is invalid")
 	errors.Enroll(ErrExpired, 401, "Token expired")
@@ -95,6 +97,16 @@ func init() {
 	errors.Enroll(ErrInvokeTool, 500, "Failed to invoke agent tool")
 	errors.Enroll(ErrToolParameter, 500, "Failed to parse tool parameter")
 	errors.Enroll(ErrInvokeToolUnConvertAble, 500, "Failed to convert to tool message")
+	errors.Enroll(ErrBudgetExceed, 403, "Spend budget of the app or workspace has been exhausted, please raise the budget or wait for the next period")
+	errors.Enroll(ErrStructuredOutputSchema, 400, "The json schema of the structured output is invalid")
+	errors.Enroll(ErrStructuredOutputInvalid, 500, "The answer doesn't conform to the json schema of the structured output after repairing")
+	errors.Enroll(ErrLLMCacheConfig, 400, "The llm response cache config of the app is invalid")
+	errors.Enroll(ErrApiToolSchema, 400, "The OpenAPI or Swagger schema of the api tool provider is invalid")
+	errors.Enroll(ErrApiToolProviderExist, 400, "The api tool provider with the same name already exists")
+	errors.Enroll(ErrMcpConnection, 500, "Failed to connect to the mcp server of the tool provider")
+	errors.Enroll(ErrMcpToolProviderExist, 400, "The mcp tool provider with the same name already exists")
+	errors.Enroll(ErrAgentApprovalNotPending, 400, "The tool call of the agent is not waiting for approval")
+	errors.Enroll(ErrAgentApprovalState, 500, "Failed to restore the agent waiting for approval")
 	errors.Enroll(ErrProviderMapModel, 500, "Error occurred while attempt to index from providerMpa using provider")
 	errors.Enroll(ErrProviderNotHaveIcon, 500, "Error occurred while provider entity doesn't have icon property")
 	errors.Enroll(ErrToOriginModelType, 500, "Error occurred while convert to origin model type")
@@ -110,4 +122,12 @@ func init() {
 	errors.Enroll(ErrConvertDelimiterString, 500, "Error occurred when convert delimiter to string")
 	errors.Enroll(ErrNotSetManagerForProvider, 500, "Error occurred when not set manager for provider")
 	errors.Enroll(ErrTTSModelNotVoice, 500, "Error occurred when tts model doesn't have voice")
+	errors.Enroll(ErrInvalidCredentials, 400, "Error occurred when credentials are rejected by the model service")
+	errors.Enroll(ErrModelContentBlocked, 400, "Error occurred when the prompt or the completion is blocked by the safety settings of the model")
+	errors.Enroll(ErrModelServiceUnavailable, 503, "Error occurred when the model service is rate limited or temporarily unavailable")
+	errors.Enroll(ErrModelRateLimited, 429, "Error occurred when the credentials are rate limited by the model service")
+	errors.Enroll(ErrModelParameter, 400, "Error occurred when the model parameters don't satisfy the parameter rules of the model")
+	errors.Enroll(ErrModelPlugin, 500, "Error occurred when call the out-of-process model plugin")
+	errors.Enroll(ErrCredentialSchema, 400, "Error occurred when the credentials don't satisfy the credential schema of the provider")
+	errors.Enroll(ErrProviderQuotaExceed, 403, "Error occurred when the tenant's quota of the provider is used up")
 }
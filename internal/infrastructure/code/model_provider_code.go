@@ -35,4 +35,20 @@ const (
 	ErrNotSetManagerForProvider
 	// ErrTTSModelNotVoice - 500: Error occurred when tts model doesn't have voice.
 	ErrTTSModelNotVoice
+	// ErrInvalidCredentials - 400: Error occurred when credentials are rejected by the model service.
+	ErrInvalidCredentials
+	// ErrModelContentBlocked - 400: Error occurred when the prompt or the completion is blocked by the safety settings of the model.
+	ErrModelContentBlocked
+	// ErrModelServiceUnavailable - 503: Error occurred when the model service is rate limited or temporarily unavailable.
+	ErrModelServiceUnavailable
+	// ErrModelRateLimited - 429: Error occurred when the credentials are rate limited by the model service.
+	ErrModelRateLimited
+	// ErrModelParameter - 400: Error occurred when the model parameters don't satisfy the parameter rules of the model.
+	ErrModelParameter
+	// ErrModelPlugin - 500: Error occurred when call the out-of-process model plugin.
+	ErrModelPlugin
+	// ErrCredentialSchema - 400: Error occurred when the credentials don't satisfy the credential schema of the provider.
+	ErrCredentialSchema
+	// ErrProviderQuotaExceed - 403: Error occurred when the tenant's quota of the provider is used up.
+	ErrProviderQuotaExceed
 )
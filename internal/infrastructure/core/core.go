@@ -29,6 +29,9 @@ type ErrResponse struct {
 
 	// Reference returns the reference document which maybe useful to solve this error.
 	Reference string `json:"reference,omitempty"`
+
+	// Details contains the structured detail of this error, e.g. the errors of every invalid parameter.
+	Details interface{} `json:"details,omitempty"`
 }
 
 func GetSuccessResponse() map[string]string {
@@ -41,13 +44,24 @@ func WriteResponse(c *gin.Context, err error, data interface{}) {
 	if err != nil {
 		log.Errorf("%#+v", err)
 		coder := errors.ParseCode(err)
-		c.JSON(coder.HTTPStatus(), ErrResponse{Code: coder.Code(), Reference: coder.Reference(), Message: err.Error()})
+		c.JSON(coder.HTTPStatus(), ErrResponse{Code: coder.Code(), Reference: coder.Reference(), Message: err.Error(), Details: errorDetails(err)})
 		return
 	}
 
 	c.JSON(http.StatusOK, data)
 }
 
+// errorDetails returns the details of the first error in the chain which carries them.
+func errorDetails(err error) interface{} {
+	var detailed interface{ Details() interface{} }
+
+	if errors.As(err, &detailed) {
+		return detailed.Details()
+	}
+
+	return nil
+}
+
 func WriteBindErrResponse(c *gin.Context, err error) {
 	if errs, ok := err.(validator.ValidationErrors); ok {
 		WriteResponse(c, errors.WithSCode(code.ErrValidation, validation.TranslateValidate(errs)), nil)
@@ -6,25 +6,40 @@ package options
 
 import (
 	"fmt"
+	"path/filepath"
+	"time"
 
 	"github.com/spf13/pflag"
 )
 
-// GRPCOptions are for creating an unauthenticated, unauthorized, insecure port.
-// No one should be using these anymore.
+// GRPCOptions are for creating an unauthenticated, unauthorized, insecure port,
+// and for connecting to the out-of-process model provider plugins.
 type GRPCOptions struct {
-	BindAddress string `json:"bind-address" mapstructure:"bind-address"`
-	BindPort    int    `json:"bind-port"    mapstructure:"bind-port"`
-	MaxMsgSize  int    `json:"max-msg-size" mapstructure:"max-msg-size"`
+	BindAddress string                `json:"bind-address" mapstructure:"bind-address"`
+	BindPort    int                   `json:"bind-port"    mapstructure:"bind-port"`
+	MaxMsgSize  int                   `json:"max-msg-size" mapstructure:"max-msg-size"`
+	Plugins     []*ModelPluginOptions `json:"plugins"      mapstructure:"plugins"`
+}
+
+// ModelPluginOptions describes a model provider plugin process serving the
+// model plugin protocol at Address.
+type ModelPluginOptions struct {
+	Provider string `json:"provider" mapstructure:"provider"`
+	Address  string `json:"address"  mapstructure:"address"`
+	// SchemaDir has the same layout as the directory of a built-in provider,
+	// that is <provider>/<provider>.yaml and the model yaml files of each model type.
+	SchemaDir string `json:"schema-dir" mapstructure:"schema-dir"`
+	// Timeout of connecting to the plugin at startup.
+	Timeout time.Duration `json:"timeout" mapstructure:"timeout"`
 }
 
 // NewGRPCOptions is for creating an unauthenticated, unauthorized, insecure port.
-// No one should be using these anymore.
 func NewGRPCOptions() *GRPCOptions {
 	return &GRPCOptions{
 		BindAddress: "0.0.0.0",
 		BindPort:    8081,
 		MaxMsgSize:  4 * 1024 * 1024,
+		Plugins:     []*ModelPluginOptions{},
 	}
 }
 
@@ -43,6 +58,25 @@ func (s *GRPCOptions) Validate() []error {
 		)
 	}
 
+	providers := make(map[string]bool, len(s.Plugins))
+
+	for _, plugin := range s.Plugins {
+		if plugin.Provider == "" || plugin.Address == "" {
+			errors = append(errors, fmt.Errorf("--grpc.plugins requires both provider and address of the plugin"))
+			continue
+		}
+
+		if providers[plugin.Provider] {
+			errors = append(errors, fmt.Errorf("--grpc.plugins has duplicated provider %s", plugin.Provider))
+		}
+
+		providers[plugin.Provider] = true
+
+		if filepath.Base(plugin.SchemaDir) != plugin.Provider {
+			errors = append(errors, fmt.Errorf("--grpc.plugins schema-dir %s of provider %s must be a directory named after the provider", plugin.SchemaDir, plugin.Provider))
+		}
+	}
+
 	return errors
 }
 
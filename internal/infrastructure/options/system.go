@@ -4,6 +4,8 @@
 
 package options
 
+import "time"
+
 type SystemOptions struct {
 	AppBasePath                  string `mapstructure:"app-base-path"                  json:"-"`
 	SsoEnforcedForSignin         bool   `mapstructure:"sso-enforced-for-signin"        json:"sso_enforced_for_signin"`
@@ -28,6 +30,17 @@ type SystemOptions struct {
 	FileBaseUrl                  string `mapstructure:"file-base-url" json:"file_base_url"`
 	SecretKey                    string `mapstructure:"secret-key" json:"secret_key"`
 	FileTimeout                  int64  `mapstructure:"file-timeout" json:"file_timeout"`
+	// ProviderDefinitionsDir has the provider and model yaml files merged over the built-in ones, it's watched for changes
+	ProviderDefinitionsDir string `mapstructure:"provider-definitions-dir" json:"-"`
+	// McpStdioCommands are the command lines, the command and its arguments, which the stdio mcp servers may be
+	// spawned with, stdio is disabled when empty
+	McpStdioCommands []string `mapstructure:"mcp-stdio-commands" json:"-"`
+	// ApiToolAllowPrivateNetwork lets the api tools fetch the schemas and call the servers on the non public addresses
+	ApiToolAllowPrivateNetwork bool `mapstructure:"api-tool-allow-private-network" json:"-"`
+	// AgentMaxParallelToolCalls caps the tool calls of an agent round running at the same time, 4 when not positive
+	AgentMaxParallelToolCalls int `mapstructure:"agent-max-parallel-tool-calls" json:"-"`
+	// AgentToolCallTimeout bounds every tool call of the agents, 60s when not positive
+	AgentToolCallTimeout time.Duration `mapstructure:"agent-tool-call-timeout" json:"-"`
 }
 
 // NewJwtOptions creates a JwtOptions object with default parameters.
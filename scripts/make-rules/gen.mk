@@ -55,6 +55,24 @@ gen.docgo.add:
 gen.defaultconfigs:
 	@${ROOT_DIR}/scripts/gen_default_config.sh
 
+TOKENIZER_ASSETS_DIR := ${ROOT_DIR}/internal/api-server/core/model_runtime/model_registry/tokenizer_assets
+TOKENIZER_BASE_URL := https://openaipublic.blob.core.windows.net/encodings
+# sha256 of the vocabularies as they are published by tiktoken
+TOKENIZER_SHA256_cl100k_base := 223921b76ee99bde995b7ff738513eef100fb51d18c93597a113bcffe865b2a7
+TOKENIZER_SHA256_o200k_base := 446a9538cb6c348e3516120d7c08b09f57c36495e2acfffe59a5bf8b0cfb1a2d
+
+.PHONY: gen.tokenizer.%
+gen.tokenizer.%:
+	@echo "===========> Downloading tiktoken vocabulary $*"
+	@tmp="$$(mktemp)"; \
+	curl -fsSL -o "$$tmp" $(TOKENIZER_BASE_URL)/$*.tiktoken && \
+	echo "$(TOKENIZER_SHA256_$*)  $$tmp" | sha256sum -c --status && \
+	gzip -9 -n -c "$$tmp" > $(TOKENIZER_ASSETS_DIR)/$*.tiktoken.gz; \
+	status=$$?; rm -f "$$tmp"; exit $$status
+
+.PHONY: gen.tokenizer
+gen.tokenizer: gen.tokenizer.cl100k_base gen.tokenizer.o200k_base
+
 .PHONY: gen.clean
 gen.clean:
 	@rm -rf ./api/client/{clientset,informers,listers}
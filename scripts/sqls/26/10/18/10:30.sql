@@ -0,0 +1,39 @@
+-- ----------------------------
+-- Table structure for provider_model_settings
+-- ----------------------------
+DROP TABLE IF EXISTS `provider_model_settings`;
+CREATE TABLE provider_model_settings (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    tenant_id CHAR(36) NOT NULL,
+    provider_name VARCHAR(255) NOT NULL,
+    model_name VARCHAR(255) NOT NULL,
+    model_type VARCHAR(40) NOT NULL,
+    enabled bit(1) NOT NULL DEFAULT 1,
+    load_balancing_enabled bit(1) NOT NULL DEFAULT 0,
+    load_balancing_strategy VARCHAR(40) NOT NULL DEFAULT 'round_robin',
+    created_at int(10) NOT NULL,
+    updated_at int(10) NOT NULL
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE INDEX provider_model_setting_tenant_provider_model_idx ON provider_model_settings (tenant_id, provider_name, model_type);
+
+
+-- ----------------------------
+-- Table structure for load_balancing_model_configs
+-- ----------------------------
+DROP TABLE IF EXISTS `load_balancing_model_configs`;
+CREATE TABLE load_balancing_model_configs (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    tenant_id CHAR(36) NOT NULL,
+    provider_name VARCHAR(255) NOT NULL,
+    model_name VARCHAR(255) NOT NULL,
+    model_type VARCHAR(40) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    encrypted_config TEXT,
+    weight INT NOT NULL DEFAULT 1,
+    enabled bit(1) NOT NULL DEFAULT 1,
+    created_at int(10) NOT NULL,
+    updated_at int(10) NOT NULL
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE INDEX load_balancing_model_config_tenant_provider_model_idx ON load_balancing_model_configs (tenant_id, provider_name, model_type);
@@ -0,0 +1,4 @@
+-- ----------------------------
+-- Unit of the provider quota
+-- ----------------------------
+ALTER TABLE providers ADD COLUMN quota_unit VARCHAR(40) DEFAULT '';
@@ -0,0 +1,20 @@
+-- ----------------------------
+-- Table structure for budgets
+-- ----------------------------
+DROP TABLE IF EXISTS `budgets`;
+CREATE TABLE budgets (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    tenant_id CHAR(36) NOT NULL,
+    app_id VARCHAR(36) NOT NULL DEFAULT '',
+    period VARCHAR(40) NOT NULL DEFAULT 'monthly',
+    currency VARCHAR(40) NOT NULL DEFAULT 'USD',
+    soft_limit DECIMAL(16, 7) NOT NULL DEFAULT 0,
+    hard_limit DECIMAL(16, 7) NOT NULL DEFAULT 0,
+    notify_emails TEXT,
+    enabled bit(1) NOT NULL DEFAULT 1,
+    created_by CHAR(36) NOT NULL,
+    created_at int(10) NOT NULL,
+    updated_at int(10) NOT NULL
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE UNIQUE INDEX budget_tenant_app_idx ON budgets (tenant_id, app_id);
@@ -0,0 +1,4 @@
+-- ----------------------------
+-- Json schema of the structured output of the app
+-- ----------------------------
+ALTER TABLE app_model_configs ADD COLUMN structured_output TEXT;
@@ -0,0 +1,4 @@
+-- ----------------------------
+-- Llm response cache config of the app
+-- ----------------------------
+ALTER TABLE app_model_configs ADD COLUMN llm_cache TEXT;
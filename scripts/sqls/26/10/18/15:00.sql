@@ -0,0 +1,20 @@
+-- ----------------------------
+-- Table structure for tool_api_providers
+-- ----------------------------
+DROP TABLE IF EXISTS `tool_api_providers`;
+CREATE TABLE tool_api_providers (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    tenant_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    icon VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    schema_type VARCHAR(40) NOT NULL,
+    `schema` LONGTEXT NOT NULL,
+    tools_str LONGTEXT NOT NULL,
+    credentials TEXT,
+    created_at int(10) NOT NULL,
+    updated_at int(10) NOT NULL
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE UNIQUE INDEX tool_api_provider_tenant_name_idx ON tool_api_providers (tenant_id, name);
@@ -0,0 +1,23 @@
+-- ----------------------------
+-- Table structure for tool_mcp_providers
+-- ----------------------------
+DROP TABLE IF EXISTS `tool_mcp_providers`;
+CREATE TABLE tool_mcp_providers (
+    id CHAR(36) NOT NULL PRIMARY KEY,
+    tenant_id CHAR(36) NOT NULL,
+    user_id CHAR(36) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    icon VARCHAR(255) NOT NULL DEFAULT '',
+    description TEXT,
+    transport VARCHAR(40) NOT NULL,
+    command VARCHAR(1024) NOT NULL DEFAULT '',
+    args TEXT,
+    env TEXT,
+    url VARCHAR(2048) NOT NULL DEFAULT '',
+    headers TEXT,
+    tools_str LONGTEXT NOT NULL,
+    created_at int(10) NOT NULL,
+    updated_at int(10) NOT NULL
+) ENGINE = InnoDB CHARACTER SET = utf8mb4 COLLATE = utf8mb4_unicode_ci;
+
+CREATE UNIQUE INDEX tool_mcp_provider_tenant_name_idx ON tool_mcp_providers (tenant_id, name);
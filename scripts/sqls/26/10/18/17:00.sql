@@ -0,0 +1,5 @@
+-- ----------------------------
+-- Approvals of the agent tool calls
+-- ----------------------------
+ALTER TABLE message_agent_thoughts ADD COLUMN tool_approvals TEXT;
+ALTER TABLE message_agent_thoughts ADD COLUMN approval_state LONGTEXT;